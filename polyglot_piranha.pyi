@@ -25,6 +25,24 @@ def execute_piranha(piranha_argument: PiranhaArguments) -> list[PiranhaOutputSum
     """
     ...
 
+def clean(files: dict[str, str], piranha_arguments: PiranhaArguments) -> CleanResult:
+    """
+    Cleans up the `files` (i.e. their content, by path relative to the root of the files) in memory, without writing any file.
+    The `path_to_codebase` of the `piranha_arguments` is ignored.
+    Parameters
+    ------------
+        files: The content of each file, by (relative) path
+        piranha_arguments: Piranha Arguments
+            Configurations for piranha
+    Returns
+    ------------
+    `CleanResult`, i.e. the output summaries and the content of the files after the cleanup
+    Raises
+    ------------
+    `ValueError` if the files cannot be cleaned up in memory (e.g. an absolute path, or with `interactive`)
+    """
+    ...
+
 class PiranhaArguments:
     """
     A class to capture Piranha's configurations
@@ -48,7 +66,39 @@ class PiranhaArguments:
         global_tag_prefix: Optional[str] = 'GLOBAL_TAG',
         delete_file_if_empty: Optional[bool] = None,
         path_to_output: Optional[str] = None,
-        allow_dirty_ast: Optional[bool] = None
+        allow_dirty_ast: Optional[bool] = None,
+        remove_unused_imports: Optional[bool] = None,
+        aggressive_dead_code: Optional[bool] = None,
+        specialize_boolean_parameters: Optional[bool] = None,
+        include_generated: Optional[bool] = None,
+        follow_symlinks: Optional[bool] = None,
+        flag_definition_files: Optional[List[str]] = None,
+        template_files: Optional[List[str]] = None,
+        rule_packs: Optional[List[str]] = None,
+        post_processing_hook: Optional[str] = None,
+        flags: Optional[List[str]] = None,
+        flag_file: Optional[str] = None,
+        substitute_only: Optional[bool] = None,
+        since: Optional[str] = None,
+        report: Optional[str] = None,
+        path_to_report: Optional[str] = None,
+        interactive: Optional[bool] = None,
+        confidence_threshold: Optional[str] = None,
+        check: Optional[bool] = None,
+        log_level: Optional[str] = None,
+        log_format: Optional[str] = None,
+        explain: Optional[str] = None,
+        jobs: Optional[int] = None,
+        cache_dir: Optional[str] = None,
+        package_loader: Optional[str] = None,
+        max_iterations: Optional[int] = None,
+        verify_build: Optional[str] = None,
+        verify_tests: Optional[str] = None,
+        max_changed_files: Optional[int] = None,
+        max_deleted_lines: Optional[int] = None,
+        blast_radius_action: Optional[str] = None,
+        backup: Optional[bool] = None,
+        backup_dir: Optional[str] = None
     ):
         """
         Constructs `PiranhaArguments`
@@ -73,6 +123,38 @@ class PiranhaArguments:
                  delete_file_if_empty (bool): User option that determines whether an empty file will be deleted
                  path_to_output (str): Path to the output json file
                  allow_dirty_ast (bool): Allows syntax errors in the input source code 
+                 remove_unused_imports (bool): Deletes the imports that are no longer referenced after the rewrite (Go only)
+                 aggressive_dead_code (bool): Deletes the functions that become empty after the cleanup, along with their call sites (Go only)
+                 specialize_boolean_parameters (bool): Specializes the functions for the boolean literal passed by all their callers, and removes the parameter (Go only)
+                 include_generated (bool): Rewrites the generated files (i.e. with the `// Code generated ... DO NOT EDIT.` header) as well (Go only)
+                 follow_symlinks (bool): Walks over the symbolic links to directories of the code base as well (they are skipped by default)
+                 flag_definition_files (List[str]): Paths (as glob patterns, relative to the code base) to the YAML or JSON files defining the flags, from which the stanza of the stale flag is deleted
+                 template_files (List[str]): Paths (as glob patterns, relative to the code base) to the (`html/template` or `text/template`) files, from which the conditions on the stale flag (i.e. evaluated with the `template_field` or `template_function` substitution) are simplified
+                 rule_packs (List[str]): The built-in rule packs of the flag SDKs (e.g. `launchdarkly`) to enable (Go only)
+                 post_processing_hook (str): The command post-processing the rewrites of each file (received as JSON on stdin) before they are persisted, which may veto or modify them
+                 flags (List[str]): The flags to clean up in a single run, each as comma-separated substitutions (e.g. `stale_flag_name=SOME_FLAG,treated=true`) extending the common `substitutions`, or only as its name (i.e. its `stale_flag_name`)
+                 flag_file (str): Path to the (JSON or CSV) file listing the flags to clean up in a single run, each with its name, treated value and optionally the paths within which it is cleaned up
+                 substitute_only (bool): Only substitutes the value of the stale flag for its evaluations, leaving the code depending on it as it is (can be set per flag with its `mode`)
+                 since (str): Only cleans up the files changed on the current branch since the given git ref (e.g. `origin/main`), including the uncommitted and the untracked files
+                 report (str): The format (i.e. `json`, `sarif` or `analysis`) of the machine-readable report of the changes (i.e. the rewrites of each file, and the sites that need a manual cleanup), or `html` for a standalone page with the before/after views of the rewritten files
+                 path_to_report (str): Path to the file where the report of the changes is written
+                 interactive (bool): Walks through the hunks of the rewrites of each file in the terminal (like `git add -p`) before they are persisted, to apply, skip or edit each of them
+                 confidence_threshold (str): The confidence (i.e. `low`, `medium` or `high`) below which the edits of the rules are annotated with a comment asking to verify the removal of the stale flag, instead of being applied
+                 check (bool): Only checks whether the stale flags are still used (i.e. the matches of the seed rules), without making any edit. The `treated` value of the flags defaults to `true`
+                 log_level (str): The level of the logs of the CLI (i.e. `off`, `error`, `warn`, `info`, `debug` or `trace`). From Python, the logs are forwarded to the `logging` module instead
+                 log_format (str): The format of the logs of the CLI (i.e. `text` or `json`)
+                 explain (str): Explains why the code at the given position (i.e. `path/file.go:42`) was (or was not) cleaned up, without making any edit (with the CLI)
+                 jobs (int): The number of workers processing the files (i.e. whole packages) concurrently, defaulting to the number of CPUs
+                 cache_dir (str): The directory caching, across runs, the files found clean by the rules (keyed by the hash of their content), so that the unchanged files are not analyzed again
+                 package_loader (str): The loader grouping the Go files into packages, i.e. `directory` (by their package clause, the default) or `go_list` (with `go list`, like `golang.org/x/tools/go/packages`)
+                 max_iterations (int): The maximum number of iterations of the cleanup (5 by default). The rules and the cross-file passes are run again as long as they expose new opportunities to each other (i.e. to a fixed point)
+                 verify_build (str): Builds each rewritten Go package (along with its tests) before persisting the rewrites, i.e. `none` (the default), `rollback` (the rewrites of the packages that no longer build are discarded) or `report` (the packages that no longer build are reported as `build_failure`)
+                 verify_tests (str): Runs the tests of each rewritten Go package before persisting the rewrites, and records their outcome in the report, i.e. `none` (the default), `rollback` (the rewrites of the packages whose tests fail are discarded) or `report` (the packages whose tests fail are reported as `test_failure`)
+                 max_changed_files (int): The maximum number of files changed by the cleanup (unlimited if 0, the default). A cleanup exceeding it is not persisted
+                 max_deleted_lines (int): The maximum number of lines deleted by the cleanup (unlimited if 0, the default). A cleanup exceeding it is not persisted
+                 blast_radius_action (str): The action taken by the CLI when the cleanup exceeds `max_changed_files` or `max_deleted_lines`, i.e. `abort` (the default, the run fails) or `dry_run` (the rewrites are printed as a unified diff instead). In either case, the rewrites are not persisted, but still returned
+                 backup (bool): Keeps a copy of each file before it is rewritten (or deleted), i.e. `<file>.orig` alongside it (or in the `backup_dir`)
+                 backup_dir (str): The directory (outside of the code base) where the copies of the `backup` are kept instead, at the same path as the files relative to the code base
         """
         ...
 
@@ -86,6 +168,7 @@ class PiranhaOutputSummary:
     content: content of the file after all the rewrites
     matches: All the occurrences of "match-only" rules
     rewrites: All the applied edits
+    suppressed_matches: All the matches exempted from the cleanup by the `piranha:ignore` directives
     """
 
     path: str
@@ -103,6 +186,25 @@ class PiranhaOutputSummary:
     rewrites: list[Edit]
     "All the applied edits"
 
+    suppressed_matches: list[tuple[str, Match]]
+    "All the matches exempted from the cleanup by the `piranha:ignore` directives"
+
+class CleanResult:
+    """
+    The outcome of cleaning up files in memory (see `clean`)
+
+    Attributes
+    ----------
+    summaries: The output summary of each file rewritten (or matched), with its path relative to the root of the files
+    files: The content of each file after the cleanup, by path (the files deleted by the cleanup are omitted)
+    """
+
+    summaries: list[PiranhaOutputSummary]
+    "The output summary of each file rewritten (or matched), with its path relative to the root of the files"
+
+    files: dict[str, str]
+    "The content of each file after the cleanup, by path (the files deleted by the cleanup are omitted)"
+
 class Edit:
     """
      A class to represent an edit performed by Piranha
@@ -218,6 +320,8 @@ class Rule:
     "Filters to test before applying a rule"
     is_seed_rule: bool
     "Marks a rule as a seed rule"
+    confidence: str
+    "The confidence (i.e. `low`, `medium` or `high`) that the edits of the rule are safe"
 
     def __init__(
         self,
@@ -229,6 +333,7 @@ class Rule:
         holes: set[str] = set(),
         filters: set[Filter] = set(),
         is_seed_rule: bool = True,
+        confidence: str = "high",
     ):
         """
         Constructs `Rule`
@@ -251,6 +356,8 @@ class Rule:
                 Filters to test before applying a rule
             is_seed_rule: bool
                 Marks a rule as a seed rule
+            confidence: str
+                The confidence (i.e. `low`, `medium` or `high`) that the edits of the rule are safe
         """
         ...
 
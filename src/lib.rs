@@ -11,10 +11,31 @@
   limitations under the License.
 */
 #![allow(deprecated)] // This prevents cargo clippy throwing warning for deprecated use.
+use models::{
+  blast_radius::get_exceeded_limits,
+  build_verification::{
+    build_package, has_test_files, test_package, verify_package, BUILD_FAILURE, ROLLBACK,
+    SKIP_BUILD_VERIFICATION, TESTS_PASSED, TEST_FAILURE,
+  },
+  cache::{get_pass_fingerprint, AnalysisCache},
+  clean::{validate_clean_input, write_files, CleanResult},
+  dynamic_flag_names::{may_build_flag_name, STALE_FLAG_NAME},
+  explain::{explain_not_scanned, is_same_file, parse_explain_position, RuleExplanation},
+  flag_definitions::FlagDefinitionFile,
+  go_packages::{GoPackages, GO_LIST},
+  language::SupportedLanguage,
+  parallel::apply_rules_in_parallel,
+  specialization::BooleanParameter,
+  stranded_functions::{STRANDED_FUNCTION, STRANDED_PROVIDER_SET},
+  suppressions::is_ignored_file,
+  templates::{TemplateFile, TemplateFlag},
+  test_cleanup::FORCE_ELIMINATED_FLAG_VALUE,
+  test_tables::SET_STALE_FLAG_VALUE,
+};
 use models::{
   edit::Edit, filter::Filter, matches::Match, outgoing_edges::OutgoingEdges,
-  piranha_arguments::PiranhaArguments, piranha_output::PiranhaOutputSummary, rule::Rule,
-  rule_graph::RuleGraph, source_code_unit::SourceCodeUnit,
+  piranha_arguments::PiranhaArguments, piranha_output::PiranhaOutputSummary, report::get_report,
+  rule::Rule, rule_graph::RuleGraph, source_code_unit::SourceCodeUnit, statistics::RunStatistics,
 };
 
 pub mod models;
@@ -22,20 +43,34 @@ pub mod models;
 mod tests;
 pub mod utilities;
 
-use std::{collections::HashMap, fs::File, io::Write, path::PathBuf};
+use std::{
+  collections::{hash_map::DefaultHasher, BTreeMap, HashMap, HashSet},
+  fs::File,
+  hash::{Hash, Hasher},
+  io::Write,
+  path::{Path, PathBuf},
+  time::Instant,
+};
 
 use itertools::Itertools;
-use log::{debug, info};
+use log::{debug, info, warn};
 
 use crate::models::rule_store::RuleStore;
 
+use crate::utilities::read_file;
+use jwalk::WalkDir;
+use pyo3::exceptions::PyValueError;
 use pyo3::prelude::{pyfunction, pymodule, wrap_pyfunction, PyModule, PyResult, Python};
+use regex::Regex;
 use tempdir::TempDir;
+use tree_sitter::Parser;
 
 #[pymodule]
 fn polyglot_piranha(_py: Python<'_>, m: &PyModule) -> PyResult<()> {
   pyo3_log::init();
   m.add_function(wrap_pyfunction!(execute_piranha, m)?)?;
+  m.add_function(wrap_pyfunction!(py_clean, m)?)?;
+  m.add_class::<CleanResult>()?;
   m.add_class::<PiranhaArguments>()?;
   m.add_class::<PiranhaOutputSummary>()?;
   m.add_class::<Edit>()?;
@@ -56,35 +91,185 @@ fn polyglot_piranha(_py: Python<'_>, m: &PyModule) -> PyResult<()> {
 /// For each file, it reports its content after the rewrite, the list of matches and the list of rewrites.
 #[pyfunction]
 pub fn execute_piranha(piranha_arguments: &PiranhaArguments) -> Vec<PiranhaOutputSummary> {
+  execute_piranha_with_statistics(piranha_arguments).0
+}
+
+/// Executes piranha for the given `piranha_arguments` (see `execute_piranha`).
+///
+/// Returns the Piranha Output Summaries, along with the summary statistics of the run
+/// (e.g. the number of files scanned and modified, the branches deleted), broken down per flag.
+pub fn execute_piranha_with_statistics(
+  piranha_arguments: &PiranhaArguments,
+) -> (Vec<PiranhaOutputSummary>, RunStatistics) {
   info!("Executing Polyglot Piranha !!!");
 
+  let now = Instant::now();
   let mut piranha = Piranha::new(piranha_arguments);
   piranha.perform_cleanup();
 
-  let summaries = piranha
-    .get_updated_files()
-    .iter()
-    .map(PiranhaOutputSummary::new)
-    .collect_vec();
+  let summaries = piranha.get_summaries();
+  let statistics = RunStatistics::new(
+    &summaries,
+    piranha_arguments,
+    piranha.rule_store.scanned_files().len(),
+    now.elapsed(),
+  );
   log_piranha_output_summaries(&summaries);
-  summaries
+  log_aggressive_dead_code_removals(&summaries, piranha_arguments);
+  write_change_report(&summaries, piranha_arguments, &statistics);
+  (summaries, statistics)
+}
+
+/// Cleans up the `files` (i.e. their content, by path relative to the root of the files) in memory with the given
+/// `piranha_arguments`, e.g. to embed the cleanup in another tool instead of running the CLI.
+/// The files are cleaned up in a temporary directory (i.e. the `path_to_codebase` is ignored), without writing the output
+/// summaries nor the report, and the caller's files are left untouched.
+///
+/// Returns the output summaries (with the paths relative to the root of the files), the content of the files after the
+/// cleanup and the statistics of the run, or an error if the files cannot be cleaned up in memory (e.g. an absolute path).
+pub fn clean(
+  files: &BTreeMap<String, String>, piranha_arguments: &PiranhaArguments,
+) -> Result<CleanResult, String> {
+  validate_clean_input(files, piranha_arguments)?;
+  let temp_dir =
+    TempDir::new("piranha").map_err(|e| format!("Could not create a temporary directory : {e}"))?;
+  // The paths of the summaries are made relative to the canonicalized root (i.e. as walked)
+  let root = temp_dir
+    .path()
+    .canonicalize()
+    .map_err(|e| format!("Could not create a temporary directory : {e}"))?;
+  write_files(files, &root)?;
+  let (summaries, statistics) = execute_piranha_with_statistics(
+    &piranha_arguments.in_memory(root.to_str().unwrap_or_default()),
+  );
+  let result = CleanResult::new(files, &root, summaries, statistics, piranha_arguments);
+  _ = temp_dir.close();
+  Ok(result)
+}
+
+/// Cleans up the `files` in memory with the given `piranha_arguments` (see `clean`).
+#[pyfunction]
+#[pyo3(name = "clean")]
+fn py_clean(
+  files: BTreeMap<String, String>, piranha_arguments: &PiranhaArguments,
+) -> PyResult<CleanResult> {
+  clean(&files, piranha_arguments).map_err(PyValueError::new_err)
+}
+
+/// Explains why the code at the `explain` position (i.e. `path:line`) was (or was not) cleaned up by a (dry) run
+/// with the given `piranha_arguments`, i.e. the rules attempted on the line and the outcome of each of them.
+pub fn explain_piranha(piranha_arguments: &PiranhaArguments) -> Vec<RuleExplanation> {
+  info!("Explaining Polyglot Piranha !!!");
+
+  let mut piranha = Piranha::new(piranha_arguments);
+  piranha.perform_cleanup();
+  piranha.explain(piranha_arguments)
 }
 
 fn log_piranha_output_summaries(summaries: &Vec<PiranhaOutputSummary>) {
   let mut total_number_of_matches: usize = 0;
   let mut total_number_of_rewrites: usize = 0;
+  let mut total_number_of_suppressed_matches: usize = 0;
   for summary in summaries {
     let number_of_rewrites = &summary.rewrites().len();
     let number_of_matches = &summary.matches().len();
+    let number_of_suppressed_matches = &summary.suppressed_matches().len();
     info!("File : {:?}", &summary.path());
     info!("  # Rewrites : {}", number_of_rewrites);
     info!("  # Matches : {}", number_of_matches);
+    info!("  # Suppressed matches : {}", number_of_suppressed_matches);
     total_number_of_rewrites += number_of_rewrites;
     total_number_of_matches += number_of_matches;
+    total_number_of_suppressed_matches += number_of_suppressed_matches;
   }
   info!("Total files affected/matched {}", &summaries.len());
   info!("Total number of matches {}", total_number_of_matches);
   info!("Total number of rewrites {}", total_number_of_rewrites);
+  info!(
+    "Total number of suppressed matches {}",
+    total_number_of_suppressed_matches
+  );
+  // The breakdown of the rewrites per flag (when cleaning up several flags in a single run)
+  let mut rewrites_per_flag: Vec<(String, usize)> = vec![];
+  for (flag_name, number_of_rewrites) in summaries.iter().flat_map(|s| s.rewrites_per_flag()) {
+    match rewrites_per_flag.iter_mut().find(|(f, _)| f == flag_name) {
+      Some((_, n)) => *n += number_of_rewrites,
+      None => rewrites_per_flag.push((flag_name.to_string(), *number_of_rewrites)),
+    }
+  }
+  for (flag_name, number_of_rewrites) in rewrites_per_flag {
+    info!(
+      "Total number of rewrites for the flag {} : {}",
+      flag_name, number_of_rewrites
+    );
+  }
+}
+
+/// Reports the code removed by the second-order dead code elimination (i.e. `aggressive_dead_code`)
+fn log_aggressive_dead_code_removals(
+  summaries: &Vec<PiranhaOutputSummary>, piranha_arguments: &PiranhaArguments,
+) {
+  if !*piranha_arguments.aggressive_dead_code() {
+    return;
+  }
+  let aggressive_rules = piranha_arguments
+    .language()
+    .aggressive_rules()
+    .clone()
+    .unwrap_or_default()
+    .rules
+    .iter()
+    .map(|r| r.name().to_string())
+    .collect_vec();
+  for summary in summaries {
+    for rewrite in summary.rewrites().iter().filter(|r| {
+      aggressive_rules.contains(r.matched_rule())
+        || [STRANDED_FUNCTION, STRANDED_PROVIDER_SET].contains(&r.matched_rule().as_str())
+    }) {
+      info!(
+        "Aggressive dead code elimination ({}) removed in {:?} :\n{}",
+        rewrite.matched_rule(),
+        summary.path(),
+        rewrite.p_match().matched_string()
+      );
+    }
+  }
+}
+
+/// Writes the report of the changes (i.e. `report`) to the `path_to_report` (or prints it to stdout)
+fn write_change_report(
+  summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+  statistics: &RunStatistics,
+) {
+  if piranha_arguments.report().is_empty() {
+    return;
+  }
+  let report = get_report(summaries, piranha_arguments, Some(statistics));
+  match piranha_arguments.path_to_report() {
+    Some(path) => {
+      if let Err(e) = std::fs::write(path, report) {
+        warn!(
+          "Could not write the report of the changes to {} : {}",
+          path, e
+        );
+      }
+    }
+    None => println!("{report}"),
+  }
+}
+
+/// Lists the Go packages of the code base with the `go_list` package loader.
+/// Falls back to the packages declared by the package clauses (i.e. the `directory` loader) if `go list` fails (e.g. without a `go.mod`).
+fn load_go_packages(piranha_arguments: &PiranhaArguments) -> GoPackages {
+  if piranha_arguments.package_loader() != GO_LIST
+    || *piranha_arguments.language().supported_language() != SupportedLanguage::Go
+  {
+    return GoPackages::default();
+  }
+  GoPackages::load(piranha_arguments.path_to_codebase()).unwrap_or_else(|e| {
+    warn!("Falling back to the package clauses to group the files into packages : {e}");
+    GoPackages::default()
+  })
 }
 
 // Maintains the state of Piranha and the updated content of files in the source code.
@@ -95,14 +280,119 @@ struct Piranha {
   relevant_files: HashMap<PathBuf, SourceCodeUnit>,
   // Piranha Arguments
   piranha_arguments: PiranhaArguments,
+  // The files of the packages containing the relevant files that are not rewritten (i.e. the generated files, the files
+  // excluded from the cleanup or exempted by `piranha:ignore-file`, and the unparsable ones), only searched for references
+  read_only_files: HashMap<PathBuf, String>,
+  // The (YAML or JSON) files defining the flags, from which the stale flag is deleted
+  flag_definition_files: Vec<FlagDefinitionFile>,
+  // The (`html/template` or `text/template`) files, from which the conditions on the stale flag are simplified
+  template_files: Vec<TemplateFile>,
+  // The files found clean by the rules in the previous runs (if a `cache_dir` is given)
+  cache: Option<AnalysisCache>,
+  // The packages listed by `go list` (with the `go_list` package loader)
+  go_packages: GoPackages,
 }
 
 impl Piranha {
+  /// Returns the files that are rewritten or matched, sorted by path (i.e. regardless of the order they are processed in)
   fn get_updated_files(&self) -> Vec<SourceCodeUnit> {
     self
       .relevant_files
       .values()
-      .filter(|r| !r.matches().is_empty() || !r.rewrites().is_empty())
+      .filter(|r| {
+        !r.matches().is_empty() || !r.rewrites().is_empty() || !r.suppressed_matches().is_empty()
+      })
+      .sorted_by(|a, b| a.path().cmp(b.path()))
+      .cloned()
+      .collect_vec()
+  }
+
+  /// Explains the outcome of the rules of each flag of the `piranha_arguments` on the line of the `explain` position
+  fn explain(&mut self, piranha_arguments: &PiranhaArguments) -> Vec<RuleExplanation> {
+    let (path, line) = match parse_explain_position(piranha_arguments.explain()) {
+      Ok(position) => position,
+      Err(_) => return vec![],
+    };
+    let path = PathBuf::from(path);
+    if !self
+      .rule_store
+      .scanned_files()
+      .iter()
+      .any(|f| is_same_file(f, &path))
+    {
+      return vec![explain_not_scanned(
+        &path.to_string_lossy(),
+        piranha_arguments,
+      )];
+    }
+    let mut parser = piranha_arguments.language().parser();
+    // The file may not be relevant, i.e. not contain the grep pattern of the seed rules
+    let source_code_unit = match self
+      .relevant_files
+      .iter()
+      .find(|(p, _)| is_same_file(p, &path))
+    {
+      Some((_, scu)) => scu.clone(),
+      None => SourceCodeUnit::new(
+        &mut parser,
+        read_file(&path).unwrap_or_default(),
+        &HashMap::new(),
+        path.as_path(),
+        piranha_arguments,
+      ),
+    };
+    let arguments = if piranha_arguments.flag_arguments().is_empty() {
+      vec![piranha_arguments.clone()]
+    } else {
+      piranha_arguments.flag_arguments().clone()
+    };
+    arguments
+      .iter()
+      .flat_map(|args| {
+        let mut scu = source_code_unit.clone();
+        scu.set_flag_arguments(args);
+        scu.explain(line, &mut RuleStore::new(args))
+      })
+      .unique()
+      .collect_vec()
+  }
+
+  /// Returns the output summaries of the updated files, followed by the ones of the updated flag definition files and
+  /// template files
+  fn get_summaries(&self) -> Vec<PiranhaOutputSummary> {
+    self
+      .get_updated_files()
+      .iter()
+      .map(PiranhaOutputSummary::new)
+      .chain(
+        self
+          .get_updated_flag_definition_files()
+          .iter()
+          .map(PiranhaOutputSummary::from_flag_definition_file),
+      )
+      .chain(
+        self
+          .get_updated_template_files()
+          .iter()
+          .map(PiranhaOutputSummary::from_template_file),
+      )
+      .collect_vec()
+  }
+
+  fn get_updated_flag_definition_files(&self) -> Vec<FlagDefinitionFile> {
+    self
+      .flag_definition_files
+      .iter()
+      .filter(|f| !f.rewrites().is_empty())
+      .cloned()
+      .collect_vec()
+  }
+
+  fn get_updated_template_files(&self) -> Vec<TemplateFile> {
+    self
+      .template_files
+      .iter()
+      .filter(|f| !f.rewrites().is_empty())
       .cloned()
       .collect_vec()
   }
@@ -110,9 +400,7 @@ impl Piranha {
   /// Performs cleanup related to stale flags
   fn perform_cleanup(&mut self) {
     // Setup the parser for the specific language
-    let piranha_args = &self.piranha_arguments;
-
-    let mut parser = piranha_args.language().parser();
+    let mut parser = self.piranha_arguments.language().parser();
 
     let mut path_to_codebase = self.piranha_arguments.path_to_codebase().to_string();
 
@@ -124,70 +412,878 @@ impl Piranha {
       None
     };
 
-    let mut current_global_substitutions = piranha_args.input_substitutions();
+    let flag_arguments = self.piranha_arguments.flag_arguments().clone();
+    if flag_arguments.is_empty() {
+      self.perform_cleanup_for_flag(&path_to_codebase, &mut parser);
+    }
+    // Clean up the flags one after the other, on top of the rewrites of the previous ones (i.e. on the same source code units),
+    // so that each file is parsed once and its rewrites do not conflict with each other
+    for arguments in flag_arguments {
+      let flag_name = arguments.get_flag_name();
+      info!("Cleaning up the flag {}", flag_name);
+      self.set_flag_arguments(arguments);
+      self.perform_cleanup_for_flag(&path_to_codebase, &mut parser);
+      for source_code_unit in self.relevant_files.values_mut() {
+        source_code_unit.record_rewrites_for_flag(&flag_name);
+      }
+    }
+    self.report_skipped_generated_files(&mut parser);
+    self.perform_post_processing_hook();
+    self.perform_build_constraints_preservation();
+    self.perform_encoding_preservation();
+    self.perform_interactive_review();
+    // The code snippet is not part of a Go module
+    if temp_dir.is_none() {
+      self.perform_package_verification();
+    }
+    // Delete the temp dir inside which the input code snippet was copied
+    if let Some(t) = temp_dir {
+      _ = t.close();
+    } else if self.exceeds_blast_radius() {
+      warn!("The rewrites are not persisted, since the cleanup exceeds its blast radius");
+    } else {
+      let source_code_units = self.get_updated_files();
+
+      for scu in source_code_units.iter() {
+        scu.persist();
+      }
+      for file in self.get_updated_flag_definition_files() {
+        file.persist(&self.piranha_arguments);
+      }
+      for file in self.get_updated_template_files() {
+        file.persist(&self.piranha_arguments);
+      }
+    }
+    if let Some(cache) = &self.cache {
+      cache.persist();
+    }
+  }
+
+  /// Checks if the rewritten files (and flag definition files) exceed the blast radius of the cleanup (see `get_exceeded_limits`),
+  /// and reports the exceeded limits.
+  fn exceeds_blast_radius(&self) -> bool {
+    let exceeded_limits = get_exceeded_limits(&self.get_summaries(), &self.piranha_arguments);
+    for limit in &exceeded_limits {
+      warn!("{limit}");
+    }
+    !exceeded_limits.is_empty()
+  }
+
+  /// Performs the cleanup of the stale flag of the current `piranha_arguments` (i.e. its substitutions).
+  /// The rules and the cross-file passes are run to a fixed point (up to `max_iterations` times), since a pass may expose
+  /// new opportunities to the rules, e.g. the specialization of a function turns one of its variables into a constant,
+  /// which makes a branch calling a function of another file dead.
+  fn perform_cleanup_for_flag(&mut self, path_to_codebase: &str, parser: &mut Parser) {
+    let mut current_global_substitutions = self.piranha_arguments.input_substitutions();
+    // The fingerprints of the code base after each iteration, to detect the rewrites undoing each other
+    let mut fingerprints = HashSet::new();
+    let max_iterations = *self.piranha_arguments.max_iterations();
+    for iteration in 1..=max_iterations {
+      debug!("Iteration {iteration} of the cleanup");
+      self.apply_global_rules(path_to_codebase, parser, &mut current_global_substitutions);
+      let number_of_global_rules = self.rule_store.global_rules().len();
+      // The code depending on the flag is left as it is in the `substitute_only` mode
+      if *self.piranha_arguments.specialize_boolean_parameters()
+        && !*self.piranha_arguments.substitute_only()
+      {
+        self.perform_specialize_boolean_parameters(parser);
+      }
+      // The fixed point is reached when the passes found no new rule to apply across the files
+      if self.rule_store.global_rules().len() == number_of_global_rules {
+        break;
+      }
+      if !fingerprints.insert(self.get_fingerprint()) {
+        warn!("The cleanup cycles (i.e. the rewrites of iteration {iteration} undo those of a previous one). Stopping.");
+        break;
+      }
+      if iteration == max_iterations {
+        warn!(
+          "The cleanup did not reach a fixed point after {max_iterations} iterations (see `--max-iterations`). Run Piranha again to continue."
+        );
+      }
+    }
+    // The code depending on the flag, its tests and its definitions are left as they are in the `substitute_only` mode
+    let substitute_only = *self.piranha_arguments.substitute_only();
+    if !substitute_only {
+      self.perform_test_cleanup(parser);
+      self.perform_stranded_functions_cleanup(parser);
+    }
+    self.report_dynamic_flag_names(path_to_codebase, parser);
+    self.report_suppressed_matches(parser);
+    if !substitute_only {
+      self.perform_flag_definitions_cleanup(path_to_codebase);
+      self.perform_templates_cleanup(path_to_codebase);
+    }
+  }
+
+  /// Applies the global rules (i.e. the seed rules, and the global rules found along the way) to the relevant files,
+  /// until no new global rule is found.
+  fn apply_global_rules(
+    &mut self, path_to_codebase: &str, parser: &mut Parser,
+    current_global_substitutions: &mut HashMap<String, String>,
+  ) {
+    let piranha_args = &self.piranha_arguments;
     // Keep looping until new `global` rules are added.
     loop {
       let current_rules = self.rule_store.global_rules().clone();
 
       debug!("\n # Global rules {}", current_rules.len());
-      // Iterate over each file containing the usage of the feature flag API
-
-      for (path, content) in self.rule_store.get_relevant_files(
-        &path_to_codebase,
+      let mut relevant_files = self.rule_store.get_relevant_files(
+        path_to_codebase,
         piranha_args.include(),
         piranha_args.exclude(),
-      ) {
-        // Get the `SourceCodeUnit` for the file `path` from the cache `relevant_files`.
-        // In case of miss, lazily insert a new `SourceCodeUnit`.
-        let source_code_unit = self
-          .relevant_files
-          .entry(path.to_path_buf())
-          .or_insert_with(|| {
-            SourceCodeUnit::new(
-              &mut parser,
-              content,
-              &current_global_substitutions,
-              path.as_path(),
-              piranha_args,
-            )
-          });
+      );
+      // Skip the files found clean by the same pass in the previous run
+      let pass = get_pass_fingerprint(&current_rules, &current_global_substitutions, piranha_args);
+      let candidates = self
+        .cache
+        .as_mut()
+        .map(|c| c.skip_clean_files(&pass, &mut relevant_files, &self.relevant_files))
+        .unwrap_or_default();
+      let mut processed_files = HashSet::new();
+      // The packages are processed concurrently by a pool of workers, the global rules they add are applied in the next pass
+      if *piranha_args.jobs() > 1 {
+        processed_files.extend(relevant_files.keys().cloned());
+        apply_rules_in_parallel(
+          relevant_files,
+          &current_rules,
+          &mut self.relevant_files,
+          &mut self.rule_store,
+          &mut current_global_substitutions,
+          piranha_args,
+        );
+      } else {
+        // Iterate over each file containing the usage of the feature flag API
+        for (path, content) in relevant_files {
+          // Get the `SourceCodeUnit` for the file `path` from the cache `relevant_files`.
+          // In case of miss, lazily insert a new `SourceCodeUnit`.
+          let source_code_unit = self
+            .relevant_files
+            .entry(path.to_path_buf())
+            .or_insert_with(|| {
+              SourceCodeUnit::new(
+                parser,
+                content,
+                &current_global_substitutions,
+                path.as_path(),
+                piranha_args,
+              )
+            });
 
-        // Apply the rules in this `SourceCodeUnit`
-        source_code_unit.apply_rules(&mut self.rule_store, &current_rules, &mut parser, None);
+          // Apply the rules in this `SourceCodeUnit`
+          source_code_unit.apply_rules(&mut self.rule_store, &current_rules, parser, None);
 
-        // Add the substitutions for the global tags to the `current_global_substitutions`
-        current_global_substitutions.extend(source_code_unit.global_substitutions());
+          // Add the substitutions for the global tags to the `current_global_substitutions`
+          current_global_substitutions.extend(source_code_unit.global_substitutions());
+          processed_files.insert(path);
 
-        // Break when a new `global` rule is added
-        if self.rule_store.global_rules().len() > current_rules.len() {
-          debug!("Found a new global rule. Will start scanning all the files again.");
-          break;
+          // Break when a new `global` rule is added
+          if self.rule_store.global_rules().len() > current_rules.len() {
+            debug!("Found a new global rule. Will start scanning all the files again.");
+            break;
+          }
         }
       }
+      if let Some(cache) = self.cache.as_mut() {
+        cache.record_clean_files(&pass, candidates, &processed_files, &self.relevant_files);
+      }
       // If no new `global_rules` were added, break.
       if self.rule_store.global_rules().len() == current_rules.len() {
         break;
       }
     }
-    // Delete the temp dir inside which the input code snippet was copied
-    if let Some(t) = temp_dir {
-      _ = t.close();
+  }
+
+  /// Returns the fingerprint of the (current) content of the relevant files
+  fn get_fingerprint(&self) -> u64 {
+    let mut hasher = DefaultHasher::new();
+    for (path, source_code_unit) in self.relevant_files.iter().sorted_by_key(|(p, _)| *p) {
+      path.hash(&mut hasher);
+      source_code_unit.code().hash(&mut hasher);
+    }
+    hasher.finish()
+  }
+
+  /// Switches to the `piranha_arguments` of the next flag to clean up, i.e. its rules and substitutions
+  fn set_flag_arguments(&mut self, piranha_arguments: PiranhaArguments) {
+    let previous_rule_store =
+      std::mem::replace(&mut self.rule_store, RuleStore::new(&piranha_arguments));
+    self.rule_store.keep_skipped_files(previous_rule_store);
+    for source_code_unit in self.relevant_files.values_mut() {
+      source_code_unit.set_flag_arguments(&piranha_arguments);
+    }
+    self.piranha_arguments = piranha_arguments;
+  }
+
+  /// Runs the `post_processing_hook` (if any) on each rewritten file, which may veto or modify its rewrites
+  fn perform_post_processing_hook(&mut self) {
+    if self.piranha_arguments.post_processing_hook().is_empty() {
+      return;
+    }
+    for source_code_unit in self.relevant_files.values_mut() {
+      source_code_unit.apply_post_processing_hook();
+    }
+  }
+
+  /// Restores the header of each rewritten Go file with build constraints (e.g. `//go:build linux`) if the rewrites changed it
+  /// (see `preserve_build_constraints`), so that the constraints are still honored.
+  fn perform_build_constraints_preservation(&mut self) {
+    for source_code_unit in self.relevant_files.values_mut() {
+      source_code_unit.preserve_build_constraints();
+    }
+  }
+
+  /// Restores the BOM and the line endings (e.g. CRLF) of each rewritten file in its edited lines (see `preserve_encoding`),
+  /// so that a file with CRLF line endings is not rewritten with LF (i.e. a whole-file diff).
+  fn perform_encoding_preservation(&mut self) {
+    for source_code_unit in self.relevant_files.values_mut() {
+      source_code_unit.preserve_encoding();
+    }
+  }
+
+  /// Walks the user through the hunks of the rewrites of each file (sorted by path) in the terminal (see `interactive`).
+  /// The prompts are written to stderr, since stdout may be used by the diff (i.e. `dry_run`) or the report.
+  fn perform_interactive_review(&mut self) {
+    if !*self.piranha_arguments.interactive()
+      || *self.piranha_arguments.check()
+      || !self.piranha_arguments.explain().is_empty()
+    {
+      return;
+    }
+    let editor = std::env::var("VISUAL")
+      .or_else(|_| std::env::var("EDITOR"))
+      .unwrap_or_else(|_| "vi".to_string());
+    let (stdin, mut stderr) = (std::io::stdin(), std::io::stderr());
+    let mut input = stdin.lock();
+    let mut quit = false;
+    for path in self.relevant_files.keys().cloned().sorted().collect_vec() {
+      let source_code_unit = self.relevant_files.get_mut(&path).unwrap();
+      if quit {
+        source_code_unit.discard_rewrites();
+      } else {
+        quit = source_code_unit.review_rewrites(&mut input, &mut stderr, &editor);
+      }
+    }
+  }
+
+  /// Verifies each rewritten Go package (i.e. directory) before the rewrites are persisted, by building it (see `verify_build`),
+  /// and then running its tests (see `verify_tests`), with the rewritten content of its files (see `build_package` and
+  /// `test_package`). The rewrites of the packages failing the verification are rolled back (or only reported), so that
+  /// the code base is never left broken, and the outcome of the tests is reported for each rewritten file.
+  /// The packages that did not build (or pass their tests) before the cleanup are not verified (see `verify_package`).
+  fn perform_package_verification(&mut self) {
+    let verify_build = self.piranha_arguments.verify_build().to_string();
+    let verify_tests = self.piranha_arguments.verify_tests().to_string();
+    if (verify_build == SKIP_BUILD_VERIFICATION && verify_tests == SKIP_BUILD_VERIFICATION)
+      || *self.piranha_arguments.language().supported_language() != SupportedLanguage::Go
+    {
+      return;
+    }
+    let rewritten_files = self
+      .relevant_files
+      .iter()
+      .filter(|(_, s)| s.code() != s.original_content())
+      .map(|(p, _)| p.to_path_buf())
+      .sorted()
+      .collect_vec();
+    let packages = rewritten_files
+      .iter()
+      .into_group_map_by(|p| p.parent().map(Path::to_path_buf).unwrap_or_default());
+    for (directory, paths) in packages.into_iter().sorted() {
+      let files = paths
+        .iter()
+        .map(|p| (p.to_path_buf(), self.relevant_files[*p].code().to_string()))
+        .collect_vec();
+      if verify_build != SKIP_BUILD_VERIFICATION {
+        match verify_package(&directory, &files, build_package, "build") {
+          Some(Ok(())) => {}
+          Some(Err(e)) => {
+            self.reject_package(
+              &directory,
+              &paths,
+              &verify_build,
+              BUILD_FAILURE,
+              "no longer builds",
+              &e,
+            );
+            continue;
+          }
+          // Nor can its tests be verified
+          None => continue,
+        }
+      }
+      if verify_tests == SKIP_BUILD_VERIFICATION || !has_test_files(&directory) {
+        continue;
+      }
+      match verify_package(&directory, &files, test_package, "pass its tests") {
+        Some(Ok(())) => {
+          for path in paths {
+            self
+              .relevant_files
+              .get_mut(path)
+              .unwrap()
+              .report_package_verification(TESTS_PASSED, None);
+          }
+        }
+        Some(Err(e)) => self.reject_package(
+          &directory,
+          &paths,
+          &verify_tests,
+          TEST_FAILURE,
+          "fails its tests",
+          &e,
+        ),
+        None => {}
+      }
+    }
+  }
+
+  /// Reports the rewritten files (i.e. `paths`) of the package of the `directory` failing its verification (i.e. the `rule`, along with the
+  /// `output` of the go command), and rolls back their rewrites with the `rollback` verification.
+  fn reject_package(
+    &mut self, directory: &Path, paths: &[&PathBuf], verification: &str, rule: &str, failure: &str,
+    output: &str,
+  ) {
+    let rollback = verification == ROLLBACK;
+    if rollback {
+      warn!(
+        "The rewrites of the package {:?} are rolled back, since it {} after the cleanup :\n{}",
+        directory, failure, output
+      );
     } else {
-      let source_code_units = self.get_updated_files();
+      warn!(
+        "The package {:?} {} after the cleanup :\n{}",
+        directory, failure, output
+      );
+    }
+    for path in paths {
+      let source_code_unit = self.relevant_files.get_mut(*path).unwrap();
+      if rollback {
+        source_code_unit.discard_rewrites();
+      }
+      source_code_unit.report_package_verification(rule, Some(output));
+    }
+  }
 
-      for scu in source_code_units.iter() {
-        scu.persist();
+  /// Deletes the stanza of the stale flag (i.e. the `stale_flag_name` substitution) from the (YAML or JSON) files
+  /// defining the flags (i.e. matching the `flag_definition_files` patterns), so that the flag is fully retired in this run.
+  fn perform_flag_definitions_cleanup(&mut self, path_to_codebase: &str) {
+    if self.piranha_arguments.flag_definition_files().is_empty() {
+      return;
+    }
+    let flag_name = match self
+      .piranha_arguments
+      .input_substitutions()
+      .get(STALE_FLAG_NAME)
+    {
+      Some(f) => f.to_string(),
+      None => {
+        warn!("The flag definition files are not cleaned up, since `{STALE_FLAG_NAME}` is not substituted");
+        return;
+      }
+    };
+    for pattern in self.piranha_arguments.flag_definition_files() {
+      let pattern = Path::new(path_to_codebase).join(pattern);
+      let paths = match glob::glob(pattern.to_str().unwrap_or_default()) {
+        Ok(paths) => paths.flatten().filter(|p| p.is_file()).collect_vec(),
+        Err(e) => {
+          warn!(
+            "Invalid flag definition files pattern {:?} : {}",
+            pattern, e
+          );
+          continue;
+        }
+      };
+      for path in paths {
+        // The file may already be loaded (i.e. for a previous flag, or by another pattern)
+        if let Some(file) = self
+          .flag_definition_files
+          .iter_mut()
+          .find(|f| *f.path() == path)
+        {
+          file.delete_flag(&flag_name);
+          continue;
+        }
+        if let Ok(content) = read_file(&path) {
+          let mut file = FlagDefinitionFile::new(path, content);
+          file.delete_flag(&flag_name);
+          self.flag_definition_files.push(file);
+        }
+      }
+    }
+  }
+
+  /// Simplifies the conditions on the stale flag (i.e. evaluated with the `template_field` or `template_function`
+  /// substitution) in the (`html/template` or `text/template`) files matching the `template_files` patterns,
+  /// so that the branches of the server-rendered templates are retired along with the Go code.
+  fn perform_templates_cleanup(&mut self, path_to_codebase: &str) {
+    if self.piranha_arguments.template_files().is_empty() {
+      return;
+    }
+    let flag = match TemplateFlag::new(&self.piranha_arguments.input_substitutions()) {
+      Ok(f) => f,
+      Err(e) => {
+        warn!("The template files are not cleaned up, since {e}");
+        return;
+      }
+    };
+    for pattern in self.piranha_arguments.template_files() {
+      let pattern = Path::new(path_to_codebase).join(pattern);
+      let paths = match glob::glob(pattern.to_str().unwrap_or_default()) {
+        Ok(paths) => paths.flatten().filter(|p| p.is_file()).collect_vec(),
+        Err(e) => {
+          warn!("Invalid template files pattern {:?} : {}", pattern, e);
+          continue;
+        }
+      };
+      for path in paths {
+        // The file may already be loaded (i.e. for a previous flag, or by another pattern)
+        if let Some(file) = self.template_files.iter_mut().find(|f| *f.path() == path) {
+          file.cleanup_flag(&flag);
+          continue;
+        }
+        if let Ok(content) = read_file(&path) {
+          let mut file = TemplateFile::new(path, content);
+          file.cleanup_flag(&flag);
+          self.template_files.push(file);
+        }
+      }
+    }
+  }
+
+  /// Specializes the functions whose `bool` parameter receives the same literal at all the call sites in
+  /// the package (see `get_packages`), by propagating the literal into the body of the function and
+  /// removing the parameter from its signature and call sites.
+  /// Currently, only supported for Go.
+  fn perform_specialize_boolean_parameters(&mut self, parser: &mut Parser) {
+    if *self.piranha_arguments.language().supported_language() != SupportedLanguage::Go {
+      return;
+    }
+    self.load_package_files(parser);
+    // Specialize one parameter at a time, since each specialization updates the call sites
+    let packages = self.get_packages();
+    while let Some((path, parameter, value)) = self.find_specializable_parameter(&packages) {
+      debug!(
+        "Specializing {} for {} = {}",
+        parameter.function_name, parameter.parameter_name, value
+      );
+      for (p, source_code_unit) in self
+        .relevant_files
+        .iter_mut()
+        .filter(|(p, _)| packages.get(*p) == packages.get(&path))
+      {
+        if p == &path {
+          source_code_unit.delete_parameter(&parameter, parser);
+        }
+        while source_code_unit.delete_argument(&parameter, parser) {}
+      }
+      if let Some(source_code_unit) = self.relevant_files.get_mut(&path) {
+        source_code_unit.propagate_value_into_body(
+          &parameter,
+          &value,
+          &mut self.rule_store,
+          parser,
+        );
+      }
+    }
+  }
+
+  /// Deletes (or marks with a TODO) the tests forcing the stale flag to its eliminated value,
+  /// i.e. matching the rules of the `force_eliminated_flag_value` group, since they only exercise the removed branch.
+  /// The rows of the tables of test cases setting the flag to its eliminated value (i.e. through the rules of the
+  /// `set_stale_flag_value` group) are deleted beforehand.
+  /// Currently, only supported for Go.
+  fn perform_test_cleanup(&mut self, parser: &mut Parser) {
+    let rules_of_group = |group: &str| {
+      self
+        .rule_store
+        .global_rules()
+        .iter()
+        .filter(|r| r.rule().groups().contains(group))
+        .cloned()
+        .collect_vec()
+    };
+    let table_rules = rules_of_group(SET_STALE_FLAG_VALUE);
+    let rules = rules_of_group(FORCE_ELIMINATED_FLAG_VALUE);
+    if table_rules.is_empty() && rules.is_empty() {
+      return;
+    }
+    for source_code_unit in self.relevant_files.values_mut() {
+      source_code_unit.cleanup_test_tables(&table_rules, &mut self.rule_store, parser);
+      source_code_unit.cleanup_tests_for_eliminated_flag_value(
+        &rules,
+        &mut self.rule_store,
+        parser,
+      );
+    }
+  }
+
+  /// Reports the (unexported) functions and provider sets referenced by the code removed by the cleanup, that are no longer
+  /// referenced in their package (e.g. the old middleware of `if exp.BoolValue(staleFlag) { r.Use(newMiddleware) } else { r.Use(oldMiddleware) }`).
+  /// They are deleted instead when `aggressive_dead_code` is set, which may strand the declarations they referenced in turn.
+  /// The wrappers reduced to returning a boolean literal (see `is_boolean_literal_wrapper`) are deleted regardless,
+  /// since their calls were replaced by the literal.
+  /// The types declared in the package that are only referenced by the stranded functions (e.g. the type built by the losing
+  /// constructor of `fx.Provide(newImpl)` vs `fx.Provide(oldImpl)`) are reported as removal candidates as well.
+  /// Currently, only supported for Go.
+  fn perform_stranded_functions_cleanup(&mut self, parser: &mut Parser) {
+    if *self.piranha_arguments.language().supported_language() != SupportedLanguage::Go {
+      return;
+    }
+    let delete = *self.piranha_arguments.aggressive_dead_code();
+    let mut retired = HashSet::new();
+    let mut referenced_types = HashSet::new();
+    loop {
+      let removed: HashSet<String> = self
+        .relevant_files
+        .values()
+        .flat_map(|s| s.get_removed_identifiers())
+        .collect();
+      if removed.is_empty() {
+        return;
+      }
+      self.load_package_files(parser);
+      let packages = self.get_packages();
+      let stranded = self
+        .find_stranded_declarations(&packages, &removed)
+        .into_iter()
+        .filter(|s| !retired.contains(s))
+        .collect_vec();
+      for (path, name, rule) in &stranded {
+        debug!("{name} is stranded in {path:?}");
+        if let Some(source_code_unit) = self.relevant_files.get_mut(path) {
+          if *rule == STRANDED_FUNCTION {
+            referenced_types.extend(
+              source_code_unit
+                .get_referenced_types(name)
+                .into_iter()
+                .map(|t| (path.to_path_buf(), t)),
+            );
+          }
+          let delete = delete
+            || (*rule == STRANDED_FUNCTION && source_code_unit.is_boolean_literal_wrapper(name));
+          source_code_unit.retire_stranded_declaration(name, rule, delete, parser);
+        }
+      }
+      retired.extend(stranded.iter().cloned());
+      // The stranded declarations are only reported (i.e. the package is left as it is) without `aggressive_dead_code`
+      if stranded.is_empty() || !delete {
+        self.report_stranded_types(&packages, &retired, &referenced_types);
+        return;
+      }
+    }
+  }
+
+  /// Returns the (unexported) declarations among the `removed` identifiers (see `get_stranded_candidates`),
+  /// along with the file declaring them and their (pseudo) rule,
+  /// that are only referenced by their declaration in the package (including its read-only files, e.g. the skipped generated ones).
+  fn find_stranded_declarations(
+    &self, packages: &HashMap<PathBuf, String>, removed: &HashSet<String>,
+  ) -> Vec<(PathBuf, String, &'static str)> {
+    let mut stranded = vec![];
+    for (path, source_code_unit) in self.relevant_files.iter().sorted_by_key(|(p, _)| *p) {
+      for (name, rule) in source_code_unit.get_stranded_candidates() {
+        if !removed.contains(&name) {
+          continue;
+        }
+        let references: usize = self
+          .relevant_files
+          .iter()
+          .filter(|(p, _)| packages.get(*p) == packages.get(path))
+          .map(|(_, s)| s.count_references(&name))
+          .sum();
+        let referenced_by_read_only_file = self
+          .read_only_files
+          .iter()
+          .filter(|(p, _)| packages.get(*p) == packages.get(path))
+          .any(|(_, content)| {
+            Regex::new(&format!(r"\b{name}\b"))
+              .unwrap()
+              .is_match(content)
+          });
+        if references == 1 && !referenced_by_read_only_file {
+          stranded.push((path.to_path_buf(), name, rule));
+        }
+      }
+    }
+    stranded
+  }
+
+  /// Reports the (unexported) `referenced_types` of the stranded functions, declared in their package,
+  /// that are not referenced in the package other than by the `stranded` declarations and by their own methods.
+  fn report_stranded_types(
+    &mut self, packages: &HashMap<PathBuf, String>,
+    stranded: &HashSet<(PathBuf, String, &'static str)>,
+    referenced_types: &HashSet<(PathBuf, String)>,
+  ) {
+    let stranded: HashSet<String> = stranded
+      .iter()
+      .map(|(_, name, _)| name.to_string())
+      .collect();
+    for (path, type_name) in referenced_types.iter().sorted() {
+      if !type_name.starts_with(|c: char| c.is_lowercase() || c == '_') {
+        continue;
+      }
+      let package = self
+        .relevant_files
+        .iter()
+        .filter(|(p, _)| packages.get(*p) == packages.get(path))
+        .map(|(p, s)| {
+          (
+            p.to_path_buf(),
+            s.declares_type(type_name),
+            s.count_type_references(type_name, &stranded),
+          )
+        })
+        .collect_vec();
+      let referenced_by_read_only_file = self
+        .read_only_files
+        .iter()
+        .filter(|(p, _)| packages.get(*p) == packages.get(path))
+        .any(|(_, content)| {
+          Regex::new(&format!(r"\b{type_name}\b"))
+            .unwrap()
+            .is_match(content)
+        });
+      if referenced_by_read_only_file || package.iter().any(|(_, _, references)| *references > 0) {
+        continue;
+      }
+      if let Some((declaring_path, _, _)) = package.iter().find(|(_, declares, _)| *declares) {
+        if let Some(source_code_unit) = self.relevant_files.get_mut(declaring_path) {
+          source_code_unit.report_stranded_type(type_name);
+        }
+      }
+    }
+  }
+
+  /// Reports the strings built at runtime that may denote the stale flag (i.e. the `stale_flag_name` substitution),
+  /// across all the files of the code base, since they are not matched by the rules.
+  /// Only the files that may build the flag name (see `may_build_flag_name`) are parsed, and the syntactically incorrect
+  /// ones are skipped. The files are only kept (i.e. in the output summaries) when a string is reported.
+  /// Currently, only supported for Go.
+  fn report_dynamic_flag_names(&mut self, path_to_codebase: &str, parser: &mut Parser) {
+    if *self.piranha_arguments.language().supported_language() != SupportedLanguage::Go {
+      return;
+    }
+    let flag_name = match self
+      .piranha_arguments
+      .input_substitutions()
+      .get(STALE_FLAG_NAME)
+    {
+      Some(f) => f.to_string(),
+      None => return,
+    };
+    for (path, content) in self.rule_store.get_files(
+      path_to_codebase,
+      self.piranha_arguments.include(),
+      self.piranha_arguments.exclude(),
+    ) {
+      if let Some(source_code_unit) = self.relevant_files.get_mut(&path) {
+        source_code_unit.report_dynamic_flag_names(&flag_name);
+        continue;
+      }
+      if !may_build_flag_name(&content, &flag_name) {
+        continue;
+      }
+      if let Some(mut source_code_unit) = SourceCodeUnit::try_new(
+        parser,
+        content,
+        &HashMap::new(),
+        path.as_path(),
+        &self.piranha_arguments,
+      ) {
+        source_code_unit.report_dynamic_flag_names(&flag_name);
+        if !source_code_unit.matches().is_empty() {
+          self.relevant_files.insert(path, source_code_unit);
+        }
+      }
+    }
+  }
+
+  /// Reports the generated files that were skipped (i.e. not rewritten), unless `include_generated` is set, once all the
+  /// flags are cleaned up. The files are the ones skipped while walking over the code base (see `get_relevant_files`).
+  /// Currently, only supported for Go.
+  fn report_skipped_generated_files(&mut self, parser: &mut Parser) {
+    if *self.piranha_arguments.language().supported_language() != SupportedLanguage::Go {
+      return;
+    }
+    let skipped_generated_files = self.rule_store.skipped_generated_files().clone();
+    for (path, content) in skipped_generated_files.into_iter().sorted() {
+      if self.relevant_files.contains_key(&path) {
+        continue;
+      }
+      if let Some(mut source_code_unit) = SourceCodeUnit::try_new(
+        parser,
+        content,
+        &HashMap::new(),
+        path.as_path(),
+        &self.piranha_arguments,
+      ) {
+        source_code_unit.report_skipped_generated_file();
+        self.relevant_files.insert(path, source_code_unit);
+      }
+    }
+  }
+
+  /// Reports the matches of the global (i.e. seed) rules exempted from the cleanup by the `piranha:ignore` directives.
+  /// The files are the ones with directives found while walking over the code base (see `get_relevant_files`).
+  fn report_suppressed_matches(&mut self, parser: &mut Parser) {
+    let global_rules = self.rule_store.global_rules().clone();
+    let files_with_ignore_directives = self.rule_store.files_with_ignore_directives().clone();
+    for (path, content) in files_with_ignore_directives.into_iter().sorted() {
+      if !self.relevant_files.contains_key(&path) {
+        if let Some(source_code_unit) = SourceCodeUnit::try_new(
+          parser,
+          content,
+          &HashMap::new(),
+          path.as_path(),
+          &self.piranha_arguments,
+        ) {
+          self.relevant_files.insert(path.clone(), source_code_unit);
+        }
+      }
+      if let Some(source_code_unit) = self.relevant_files.get_mut(&path) {
+        source_code_unit.report_suppressed_matches(&global_rules, &mut self.rule_store);
+      }
+    }
+  }
+
+  /// Returns the first `bool` parameter receiving the same literal at all the call sites in the package,
+  /// along with the file declaring it and the literal.
+  /// Functions that are referenced other than being called (e.g. passed as a value),
+  /// or referenced by a read-only file of the package (e.g. a skipped generated file), are not specialized.
+  fn find_specializable_parameter(
+    &self, packages: &HashMap<PathBuf, String>,
+  ) -> Option<(PathBuf, BooleanParameter, String)> {
+    for (path, source_code_unit) in self.relevant_files.iter().sorted_by_key(|(p, _)| *p) {
+      let package = self
+        .relevant_files
+        .iter()
+        .filter(|(p, _)| packages.get(*p) == packages.get(path))
+        .map(|(_, s)| s)
+        .collect_vec();
+      for parameter in source_code_unit.get_boolean_parameters() {
+        let calls = package
+          .iter()
+          .flat_map(|s| s.get_call_arguments(&parameter.function_name))
+          .collect_vec();
+        let references: usize = package
+          .iter()
+          .map(|s| s.count_references(&parameter.function_name))
+          .sum();
+        let referenced_by_read_only_file = self
+          .read_only_files
+          .iter()
+          .filter(|(p, _)| packages.get(*p) == packages.get(path))
+          .any(|(_, content)| {
+            Regex::new(&format!(r"\b{}\b", parameter.function_name))
+              .unwrap()
+              .is_match(content)
+          });
+        if calls.is_empty()
+          || referenced_by_read_only_file
+          || references != calls.len() + 1
+          || calls.iter().any(|c| c.len() != parameter.arity)
+        {
+          continue;
+        }
+        let values = calls
+          .iter()
+          .map(|c| c[parameter.position].to_string())
+          .unique()
+          .collect_vec();
+        if values.len() == 1 && ["true", "false"].contains(&values[0].as_str()) {
+          return Some((path.to_path_buf(), parameter, values[0].to_string()));
+        }
+      }
+    }
+    None
+  }
+
+  /// Returns the package of each relevant (and read-only) file, i.e. its import path with the `go_list` loader,
+  /// or else its directory along with the name declared by its package clause (see `GoPackages`),
+  /// so that the external test package (i.e. `foo_test`) is not mistaken for the package under test.
+  fn get_packages(&self) -> HashMap<PathBuf, String> {
+    self
+      .relevant_files
+      .iter()
+      .map(|(p, s)| (p, s.original_content()))
+      .chain(self.read_only_files.iter())
+      .map(|(p, content)| (p.to_path_buf(), self.go_packages.get_package(p, content)))
+      .collect()
+  }
+
+  /// Loads the other files of the packages (i.e. directories) containing the relevant files,
+  /// since the call sites of the functions declared in a package may be in any of its files.
+  /// Only the files of the code base walked over (i.e. the `scanned_files`, selected by `include`, `exclude`, the ignore
+  /// files and `since`) are rewritten; the others, along with the generated files, the files exempted by
+  /// `piranha:ignore-file` and the unparsable ones, are loaded as `read_only_files`.
+  fn load_package_files(&mut self, parser: &mut Parser) {
+    let directories = self
+      .relevant_files
+      .keys()
+      .filter_map(|p| p.parent().map(Path::to_path_buf))
+      .unique()
+      .collect_vec();
+    for directory in directories {
+      let paths = WalkDir::new(&directory)
+        .max_depth(1)
+        .into_iter()
+        .filter_map(|e| e.ok())
+        .filter(|de| self.piranha_arguments.language().can_parse(de))
+        .map(|de| de.path())
+        .collect_vec();
+      for path in paths {
+        if self.relevant_files.contains_key(&path) || self.read_only_files.contains_key(&path) {
+          continue;
+        }
+        let content = match read_file(&path) {
+          Ok(content) => content,
+          Err(_) => continue,
+        };
+        if !self.rule_store.scanned_files().contains(&path)
+          || self.rule_store.is_skipped_generated_file(&content)
+          || is_ignored_file(&content)
+        {
+          self.read_only_files.insert(path, content);
+          continue;
+        }
+        match SourceCodeUnit::try_new(
+          parser,
+          content.to_string(),
+          &HashMap::new(),
+          path.as_path(),
+          &self.piranha_arguments,
+        ) {
+          Some(source_code_unit) => {
+            self.relevant_files.insert(path, source_code_unit);
+          }
+          None => {
+            self.read_only_files.insert(path, content);
+          }
+        }
       }
     }
   }
 
   /// Instantiate Flag-cleaner
   fn new(piranha_arguments: &PiranhaArguments) -> Self {
-    let graph_rule_store = RuleStore::new(piranha_arguments);
+    // When cleaning up several flags, the rules are instantiated for each flag (see `set_flag_arguments`)
+    let graph_rule_store = RuleStore::new(
+      piranha_arguments
+        .flag_arguments()
+        .first()
+        .unwrap_or(piranha_arguments),
+    );
     Self {
       rule_store: graph_rule_store,
       relevant_files: HashMap::new(),
       piranha_arguments: piranha_arguments.clone(),
+      read_only_files: HashMap::new(),
+      flag_definition_files: vec![],
+      template_files: vec![],
+      cache: AnalysisCache::load(piranha_arguments),
+      go_packages: load_go_packages(piranha_arguments),
     }
   }
 
@@ -12,30 +12,328 @@
 */
 
 //! Defines the entry-point for Piranha.
-use std::{fs, time::Instant};
+use std::{fs, io, process, time::Instant};
 
-use log::{debug, info};
+use itertools::Itertools;
+use log::{debug, error, info, warn};
 use polyglot_piranha::{
-  execute_piranha, models::piranha_arguments::PiranhaArguments,
-  models::piranha_output::PiranhaOutputSummary,
+  execute_piranha_with_statistics, explain_piranha, models::archive::archive_flags,
+  models::blast_radius::get_exceeded_limits, models::blast_radius::ABORT,
+  models::check::get_remaining_flag_usages, models::diff_preview::get_terminal_width,
+  models::diff_preview::init_color, models::diff_preview::render_diff_preview,
+  models::hook::stage_rewritten_files, models::http::serve_http,
+  models::inventory::get_flag_inventory, models::journal::record_journal,
+  models::journal::revert_journal, models::lsp::serve_lsp, models::notify::notify,
+  models::notify::Notification, models::piranha_arguments::PiranhaArguments,
+  models::piranha_output::PiranhaOutputSummary, models::pull_request::open_pull_requests,
+  models::scan::scan_stale_flags, models::split::write_patches, models::watch::watch_piranha,
 };
 
 fn main() {
   let now = Instant::now();
-  env_logger::init();
-
-  info!("Executing Polyglot Piranha");
 
+  // The logger is initialized along with the arguments (see `log_level` and `log_format`)
   let args = PiranhaArguments::from_cli();
 
+  info!("Executing Polyglot Piranha");
+
   debug!("Piranha Arguments are \n{:#?}", args);
-  let piranha_output_summaries = execute_piranha(&args);
+  // The colors of the output (e.g. of the diff) are enabled or disabled once for the run (see `color`)
+  let color = init_color(args.color());
+
+  // The cleanup jobs are served over HTTP, until the process is interrupted
+  if *args.serve() && !args.http_address().is_empty() {
+    if let Err(e) = serve_http(&args) {
+      error!("The HTTP server failed : {e}");
+      process::exit(1);
+    }
+    return;
+  }
+
+  // The messages of the Language Server Protocol are exchanged over stdin and stdout, until the client exits
+  if *args.serve() {
+    if let Err(e) = serve_lsp(&args, &mut io::stdin().lock(), &mut io::stdout().lock()) {
+      error!("The language server failed : {e}");
+      process::exit(1);
+    }
+    return;
+  }
+
+  // The edits of the journal are reverted instead of cleaning up, and the conflicts (if any) are printed to stderr
+  if *args.revert() {
+    match revert_journal(&args) {
+      Ok(outcome) => {
+        for conflict in outcome.conflicts() {
+          eprintln!("{conflict}");
+        }
+        info!("Number of reverted edits : {}", outcome.reverted_edits());
+        if !outcome.conflicts().is_empty() {
+          process::exit(1);
+        }
+      }
+      Err(e) => {
+        error!("Could not revert the journal : {e}");
+        process::exit(1);
+      }
+    }
+    return;
+  }
+
+  // The call sites of the flag APIs are printed (to stdout) instead of cleaning up, as a JSON array for the `json` report
+  if *args.inventory() {
+    let sites = get_flag_inventory(&args);
+    if args.report() == "json" {
+      println!("{}", serde_json::to_string_pretty(&sites).unwrap());
+    } else {
+      for site in &sites {
+        println!("{site}");
+      }
+    }
+    info!(
+      "Number of call sites : {} (of {} flags)",
+      sites.len(),
+      sites.iter().map(|s| s.flag()).unique().count()
+    );
+    return;
+  }
+
+  // The likely stale flags are printed (to stdout) instead of cleaning up, as a JSON array for the `json` report
+  if *args.scan() {
+    match scan_stale_flags(&args) {
+      Ok(flags) => {
+        if args.report() == "json" {
+          println!("{}", serde_json::to_string_pretty(&flags).unwrap());
+        } else {
+          for flag in &flags {
+            println!("{flag}");
+          }
+        }
+        info!("Number of likely stale flags : {}", flags.len());
+      }
+      Err(e) => {
+        error!("Could not scan the flags : {e}");
+        process::exit(1);
+      }
+    }
+    return;
+  }
+
+  // The explanation of the position is printed (to stdout) instead of the rewrites
+  if !args.explain().is_empty() {
+    for explanation in explain_piranha(&args) {
+      println!("{explanation}");
+    }
+    return;
+  }
+
+  // The code base is cleaned up again (in dry-run) on each change, and the updated diff is printed (to stdout), until interrupted
+  if *args.watch() {
+    watch_piranha(&args, |summaries| {
+      print_unified_diff(summaries, &args, color);
+      eprintln!(
+        "Number of files changed : {} - watching for changes (press Ctrl+C to stop)",
+        summaries.len()
+      );
+    });
+    return;
+  }
+
+  // Each flag is cleaned up on its own branch, and the URLs of the opened pull requests are printed (to stdout)
+  if *args.pull_request() {
+    match open_pull_requests(&args) {
+      Ok((pull_requests, failures)) => {
+        for pull_request in &pull_requests {
+          println!("{}", pull_request.url());
+        }
+        send_notification(
+          &Notification::of_pull_requests(&pull_requests, &args).with_failures(&failures),
+          &args,
+        );
+      }
+      Err(e) => {
+        error!("Could not open the pull requests : {e}");
+        send_notification(
+          &Notification::empty(&args)
+            .with_failures(&[format!("Could not open the pull requests : {e}")]),
+          &args,
+        );
+        process::exit(1);
+      }
+    }
+    return;
+  }
+
+  let (piranha_output_summaries, statistics) = execute_piranha_with_statistics(&args);
+
+  // The rewrites of a cleanup exceeding its blast radius are not persisted (see `max_changed_files` and `max_deleted_lines`),
+  // thus the run either fails, or goes on as a dry run
+  let exceeded_limits = get_exceeded_limits(&piranha_output_summaries, &args);
+  if !exceeded_limits.is_empty() && args.blast_radius_action() == ABORT {
+    error!("Aborting, since the cleanup exceeds its blast radius (see `--blast-radius-action`)");
+    send_notification(
+      &Notification::new(&piranha_output_summaries, &statistics, &args)
+        .with_failures(&exceeded_limits),
+      &args,
+    );
+    process::exit(1);
+  }
+  let dry_run = *args.dry_run() || !exceeded_limits.is_empty();
+
+  // The proposed rewrites are printed (to stdout) as a unified diff, since they are not persisted,
+  // unless the report of the changes (or the usages of the stale flags) is printed instead
+  if dry_run
+    && !*args.check()
+    && args.path_to_patches().is_none()
+    && (args.report().is_empty() || args.path_to_report().is_some())
+  {
+    print_unified_diff(&piranha_output_summaries, &args, color);
+  }
+
+  // The chunks of the rewrites are written as patches instead, whose paths are printed (to stdout)
+  if args.path_to_patches().is_some() {
+    match write_patches(&piranha_output_summaries, &args) {
+      Ok(paths) => {
+        for path in &paths {
+          println!("{path}");
+        }
+      }
+      Err(e) => {
+        error!("Could not write the patches : {e}");
+        process::exit(1);
+      }
+    }
+  }
+
+  // The reasons why (a part of) the run failed, reported in the notification (see `notify_url`)
+  let mut failures = exceeded_limits.clone();
+
+  let number_of_usages = if *args.check() {
+    print_remaining_flag_usages(&piranha_output_summaries, &args)
+  } else {
+    0
+  };
+  if number_of_usages > 0 {
+    failures.push(format!(
+      "Number of remaining usages of the stale flags : {number_of_usages}"
+    ));
+  }
+
+  // The files rewritten by the pre-commit hook are staged again, so that their cleanup is part of the commit
+  let number_of_unstaged_files = if *args.hook() && !dry_run {
+    stage_rewritten_files_of_hook(&piranha_output_summaries)
+  } else {
+    0
+  };
+  if number_of_unstaged_files > 0 {
+    failures.push(format!(
+      "Number of rewritten files not staged : {number_of_unstaged_files}"
+    ));
+  }
+
+  // The flags whose references are all removed are archived in their provider (e.g. LaunchDarkly), once the cleanup is persisted
+  if !args.archive().is_empty() && exceeded_limits.is_empty() {
+    for (flag, outcome) in archive_flags(&piranha_output_summaries, &args) {
+      match outcome {
+        Ok(()) => info!("Archived the flag {flag}"),
+        Err(e) => {
+          warn!("The flag {flag} is not archived : {e}");
+          failures.push(format!("The flag {flag} is not archived : {e}"));
+        }
+      }
+    }
+  }
+
+  // The edits are recorded once persisted, so that they can be reverted (i.e. `piranha revert`)
+  let journal_error = if args.path_to_journal().is_some() && !dry_run {
+    record_journal(&piranha_output_summaries, &args).err()
+  } else {
+    None
+  };
+  if let Some(e) = &journal_error {
+    error!("Could not record the journal : {e}");
+    failures.push(format!("Could not record the journal : {e}"));
+  }
+
+  send_notification(
+    &Notification::new(&piranha_output_summaries, &statistics, &args).with_failures(&failures),
+    &args,
+  );
+  if journal_error.is_some() {
+    process::exit(1);
+  }
 
   if let Some(path) = args.path_to_output_summary() {
     write_output_summary(piranha_output_summaries, path);
   }
 
+  // The statistics are printed to stderr, since stdout may be piped (e.g. the unified diff into `git apply`)
+  eprint!("{statistics}");
+
   info!("Time elapsed - {:?}", now.elapsed().as_secs());
+
+  // The check fails (e.g. in CI) when the stale flags are still used, as does the hook when a cleanup could not be staged
+  if number_of_usages > 0 || number_of_unstaged_files > 0 {
+    process::exit(1);
+  }
+}
+
+/// Posts the notification of the run to the `notify_url` (if any). A notification that could not be posted does not fail the run.
+fn send_notification(notification: &Notification, args: &PiranhaArguments) {
+  if args.notify_url().is_empty() {
+    return;
+  }
+  if let Err(e) = notify(notification, args) {
+    warn!("Could not post the notification : {e}");
+  }
+}
+
+/// Prints the usages of the stale flags remaining in the code base (i.e. for `check`), and returns their number.
+fn print_remaining_flag_usages(
+  piranha_output_summaries: &[PiranhaOutputSummary], args: &PiranhaArguments,
+) -> usize {
+  let usages = get_remaining_flag_usages(piranha_output_summaries, args);
+  for usage in &usages {
+    println!("{usage}");
+  }
+  info!(
+    "Number of remaining usages of the stale flags : {}",
+    usages.len()
+  );
+  usages.len()
+}
+
+/// Stages the files rewritten by the pre-commit hook again (see `hook`), and returns the number of the ones that could not be
+/// staged (i.e. with unstaged changes), which are printed to stderr along with the reason.
+fn stage_rewritten_files_of_hook(piranha_output_summaries: &[PiranhaOutputSummary]) -> usize {
+  match stage_rewritten_files(piranha_output_summaries) {
+    Ok(not_staged) => {
+      for path in &not_staged {
+        eprintln!("{path} has unstaged changes, its cleanup is not staged - review and stage it before committing");
+      }
+      not_staged.len()
+    }
+    Err(e) => {
+      error!("Could not stage the rewritten files : {e}");
+      process::exit(1);
+    }
+  }
+}
+
+/// Prints the diff of the rewritten files (sorted by path), i.e. the unified diff (e.g. to be piped into `git apply` or
+/// `patch -p1`), or the side-by-side diff fitting in the width of the terminal (see `diff_style`), colored if `color` is set.
+fn print_unified_diff(
+  piranha_output_summaries: &[PiranhaOutputSummary], args: &PiranhaArguments, color: bool,
+) {
+  let width = get_terminal_width();
+  for summary in piranha_output_summaries
+    .iter()
+    .sorted_by(|a, b| a.path().cmp(b.path()))
+  {
+    print!(
+      "{}",
+      render_diff_preview(summary, args.diff_style(), color, width)
+    );
+  }
 }
 
 /// Writes the output summaries to a Json file named `path_to_output_summaries` .
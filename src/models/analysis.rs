@@ -0,0 +1,154 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashMap;
+
+use serde_derive::Serialize;
+
+use super::{
+  check::{get_remaining_flag_usages, FlagUsage},
+  piranha_arguments::PiranhaArguments,
+  piranha_output::PiranhaOutputSummary,
+};
+use crate::utilities::{get_hunks, Hunk};
+
+/// The report of the usages of the stale flags remaining in the files (see `check`), in the shape of the diagnostics
+/// of `golang.org/x/tools/go/analysis` (i.e. `--report analysis`). It is consumed by the `staleflag` analyzer (see `staleflag/`),
+/// reporting the usages under `go vet`, nogo or golangci-lint.
+///
+/// The offsets are bytes of the original content of the files (i.e. as scanned, before the rewrites).
+#[derive(Serialize, Debug, Clone, Default)]
+pub(crate) struct AnalysisReport {
+  diagnostics: Vec<AnalysisDiagnostic>,
+}
+
+/// A usage of a stale flag, i.e. an `analysis.Diagnostic`
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Eq)]
+struct AnalysisDiagnostic {
+  path: String,
+  start: usize,
+  end: usize,
+  // The seed rule matching the usage
+  category: String,
+  message: String,
+  // The cleanup of the usage computed by Piranha (if it is a rewrite), i.e. the hunks of the diff it is part of
+  #[serde(skip_serializing_if = "Vec::is_empty")]
+  suggested_fixes: Vec<AnalysisSuggestedFix>,
+}
+
+/// An `analysis.SuggestedFix`
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Eq)]
+struct AnalysisSuggestedFix {
+  message: String,
+  text_edits: Vec<AnalysisTextEdit>,
+}
+
+/// An `analysis.TextEdit`, i.e. the bytes `start..end` of the original content replaced with `new_text`
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Eq)]
+struct AnalysisTextEdit {
+  start: usize,
+  end: usize,
+  new_text: String,
+}
+
+impl AnalysisReport {
+  /// Builds the report of the remaining usages of the stale flags of the output summaries of a run with the given `piranha_arguments`
+  pub(crate) fn new(
+    summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+  ) -> AnalysisReport {
+    let files: HashMap<&str, (&str, Vec<Hunk>)> = summaries
+      .iter()
+      .map(|s| {
+        (
+          s.path().as_str(),
+          (
+            s.original_content().as_str(),
+            get_hunks(s.original_content(), s.content()),
+          ),
+        )
+      })
+      .collect();
+    let diagnostics = get_remaining_flag_usages(summaries, piranha_arguments)
+      .iter()
+      .map(|usage| {
+        let (content, hunks) = files
+          .get(usage.path().as_str())
+          .map(|(c, h)| (*c, h.as_slice()))
+          .unwrap_or_default();
+        let (start, end) = get_usage_range(usage, content);
+        let text_edits: Vec<AnalysisTextEdit> = hunks
+          .iter()
+          .filter(|h| usage.original_range().is_some() && h.start <= end && start <= h.end)
+          .map(|h| AnalysisTextEdit {
+            start: h.start,
+            end: h.end,
+            new_text: h.text.to_string(),
+          })
+          .collect();
+        let suggested_fixes = if text_edits.is_empty() {
+          vec![]
+        } else {
+          vec![AnalysisSuggestedFix {
+            message: match usage.flag() {
+              Some(flag) => format!("Clean up the stale flag `{flag}`"),
+              None => "Clean up the stale flag".to_string(),
+            },
+            text_edits,
+          }]
+        };
+        AnalysisDiagnostic {
+          path: usage.path().to_string(),
+          start,
+          end,
+          category: usage.rule().to_string(),
+          message: usage.message(),
+          suggested_fixes,
+        }
+      })
+      .collect();
+    AnalysisReport { diagnostics }
+  }
+
+  pub(crate) fn to_json(&self) -> String {
+    serde_json::to_string_pretty(self).unwrap_or_default()
+  }
+}
+
+/// Returns the byte range of the usage in the original `content` of its file
+pub(super) fn get_usage_range(usage: &FlagUsage, content: &str) -> (usize, usize) {
+  usage
+    .original_range()
+    .as_ref()
+    .copied()
+    .unwrap_or_else(|| get_line_range(content, *usage.line()))
+}
+
+/// Returns the byte range of the (1-based) line of the content, without its line break.
+/// The matches reported after the cleanup have no range in the original content, thus they are reported on their line.
+fn get_line_range(content: &str, line: usize) -> (usize, usize) {
+  let start = content
+    .split_inclusive('\n')
+    .take(line - 1)
+    .map(|l| l.len())
+    .sum::<usize>()
+    .min(content.len());
+  let end = content[start..]
+    .find('\n')
+    .map(|i| start + i)
+    .unwrap_or(content.len());
+  (start, end)
+}
+
+#[cfg(test)]
+#[path = "unit_tests/analysis_test.rs"]
+mod analysis_test;
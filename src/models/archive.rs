@@ -0,0 +1,158 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{env, fs};
+
+use jwalk::WalkDir;
+use log::debug;
+use regex::Regex;
+use serde_json::json;
+
+use super::{
+  check::get_remaining_flag_usages, piranha_arguments::PiranhaArguments,
+  piranha_output::PiranhaOutputSummary, scm::call_api,
+};
+
+/// The flag providers whose flags can be archived after their cleanup (i.e. `archive`)
+pub(crate) static ARCHIVE_PROVIDERS: [&str; 3] = ["launchdarkly", "unleash", "webhook"];
+
+static LAUNCHDARKLY_URL: &str = "https://app.launchdarkly.com";
+
+/// Archives (or retires) the definition of each cleaned up flag with the management API of its provider (i.e. `archive`), once
+/// all its references are removed from the code base, i.e. the seed rules match no remaining usage of the flag and its name
+/// does not occur in the files of the language anymore. Returns the outcome of each flag, i.e. the reason why it is not archived.
+pub fn archive_flags(
+  summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+) -> Vec<(String, Result<(), String>)> {
+  let usages = get_remaining_flag_usages(summaries, piranha_arguments);
+  let flag_arguments = if piranha_arguments.flag_arguments().is_empty() {
+    vec![piranha_arguments.clone()]
+  } else {
+    piranha_arguments.flag_arguments().clone()
+  };
+  flag_arguments
+    .iter()
+    .map(|arguments| {
+      let flag = arguments.get_flag_name();
+      // The rewrites are applied, i.e. only the matches (which have no original range) remain
+      let outcome = match usages
+        .iter()
+        .find(|u| u.original_range().is_none() && u.flag().as_deref() == Some(flag.as_str()))
+      {
+        Some(usage) => Err(format!(
+          "it is still used at {}:{}",
+          usage.path(),
+          usage.line()
+        )),
+        None => match find_reference(piranha_arguments, &flag) {
+          Some(path) => Err(format!("it is still referenced in {path}")),
+          None => archive_flag(piranha_arguments, arguments, &flag),
+        },
+      };
+      (flag, outcome)
+    })
+    .collect()
+}
+
+/// Returns the first file of the code base (with the extension of the language) whose content contains the name of the flag (as a word)
+fn find_reference(piranha_arguments: &PiranhaArguments, flag: &str) -> Option<String> {
+  let name = Regex::new(&format!(r"\b{}\b", regex::escape(flag))).ok()?;
+  let extension = piranha_arguments.language().extension();
+  WalkDir::new(piranha_arguments.path_to_codebase())
+    .into_iter()
+    .filter_map(|e| e.ok())
+    .map(|e| e.path())
+    .filter(|p| p.is_file() && p.extension().map_or(false, |e| e == extension.as_str()))
+    .find(|p| {
+      fs::read_to_string(p)
+        .map(|c| name.is_match(&c))
+        .unwrap_or(false)
+    })
+    .map(|p| p.to_string_lossy().to_string())
+}
+
+/// Archives the flag with the API of the provider, authenticated with the token of its environment variable
+/// (i.e. `LAUNCHDARKLY_ACCESS_TOKEN` or `UNLEASH_API_TOKEN`, and the optional `ARCHIVE_WEBHOOK_TOKEN`)
+fn archive_flag(
+  piranha_arguments: &PiranhaArguments, arguments: &PiranhaArguments, flag: &str,
+) -> Result<(), String> {
+  let url = piranha_arguments.archive_url().trim_end_matches('/');
+  let project = piranha_arguments.archive_project();
+  let (encoded_project, encoded_flag) = (encode_component(project), encode_component(flag));
+  let token = |variable: &str| {
+    env::var(variable).map_err(|_| format!("The `{variable}` environment variable is not set"))
+  };
+  match piranha_arguments.archive().as_str() {
+    "launchdarkly" => {
+      let url = if url.is_empty() {
+        LAUNCHDARKLY_URL
+      } else {
+        url
+      };
+      call_api(
+        "PATCH",
+        &format!("{url}/api/v2/flags/{encoded_project}/{encoded_flag}"),
+        &[format!(
+          "Authorization: {}",
+          token("LAUNCHDARKLY_ACCESS_TOKEN")?
+        )],
+        Some(&json!([{"op": "replace", "path": "/archived", "value": true}])),
+      )?;
+    }
+    "unleash" => {
+      call_api(
+        "DELETE",
+        &format!("{url}/api/admin/projects/{encoded_project}/features/{encoded_flag}"),
+        &[format!("Authorization: {}", token("UNLEASH_API_TOKEN")?)],
+        None,
+      )?;
+    }
+    _ => {
+      let headers = env::var("ARCHIVE_WEBHOOK_TOKEN")
+        .map(|t| vec![format!("Authorization: Bearer {t}")])
+        .unwrap_or_default();
+      call_api(
+        "POST",
+        url,
+        &headers,
+        Some(&json!({
+          "event": "flag_cleaned_up",
+          "flag": flag,
+          "project": project,
+          "substitutions": arguments.input_substitutions(),
+          "path_to_codebase": piranha_arguments.path_to_codebase(),
+        })),
+      )?;
+    }
+  }
+  debug!("Archived the flag {flag}");
+  Ok(())
+}
+
+/// Percent-encodes the bytes of a component of the path (or query) of a URL, other than the unreserved characters
+fn encode_component(component: &str) -> String {
+  component
+    .bytes()
+    .map(|b| {
+      if b.is_ascii_alphanumeric() || b"-._~".contains(&b) {
+        (b as char).to_string()
+      } else {
+        format!("%{b:02X}")
+      }
+    })
+    .collect()
+}
+
+#[cfg(test)]
+#[path = "unit_tests/archive_test.rs"]
+mod archive_test;
@@ -0,0 +1,55 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::{piranha_arguments::PiranhaArguments, piranha_output::PiranhaOutputSummary};
+use crate::utilities::count_changed_lines;
+
+/// The actions taken when the cleanup exceeds its blast radius (i.e. `blast_radius_action`)
+pub(crate) static BLAST_RADIUS_ACTIONS: [&str; 2] = ["abort", "dry_run"];
+/// Aborts the run (i.e. nothing is persisted, and the run fails)
+pub static ABORT: &str = "abort";
+
+/// Returns the limits of the blast radius of the cleanup (i.e. `max_changed_files` and `max_deleted_lines`, unlimited if 0)
+/// exceeded by the output summaries, e.g. when a mis-specified rule rewrites much more than the code of the stale flag.
+/// The rewrites of a cleanup exceeding any of them are not persisted (see `blast_radius_action`).
+pub fn get_exceeded_limits(
+  summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+) -> Vec<String> {
+  let (mut changed_files, mut deleted_lines) = (0, 0);
+  for summary in summaries {
+    let (lines_added, lines_removed) =
+      count_changed_lines(summary.original_content(), summary.content());
+    if lines_added + lines_removed > 0 {
+      changed_files += 1;
+    }
+    deleted_lines += lines_removed;
+  }
+  let mut exceeded_limits = vec![];
+  let max_changed_files = *piranha_arguments.max_changed_files();
+  if max_changed_files > 0 && changed_files > max_changed_files {
+    exceeded_limits.push(format!(
+      "The cleanup changes {changed_files} files, more than the limit of {max_changed_files} (see `max_changed_files`)"
+    ));
+  }
+  let max_deleted_lines = *piranha_arguments.max_deleted_lines();
+  if max_deleted_lines > 0 && deleted_lines > max_deleted_lines {
+    exceeded_limits.push(format!(
+      "The cleanup deletes {deleted_lines} lines, more than the limit of {max_deleted_lines} (see `max_deleted_lines`)"
+    ));
+  }
+  exceeded_limits
+}
+
+#[cfg(test)]
+#[path = "unit_tests/blast_radius_test.rs"]
+mod blast_radius_test;
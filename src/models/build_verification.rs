@@ -0,0 +1,248 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::BTreeMap,
+  fs,
+  path::{Path, PathBuf},
+  process::Command,
+};
+
+use log::warn;
+use regex::Regex;
+use serde_derive::Serialize;
+use tempdir::TempDir;
+
+use super::{matches::Match, source_code_unit::SourceCodeUnit};
+
+/// The verifications of the rewritten packages, i.e. of their build (`verify_build`) or of their tests (`verify_tests`)
+pub(crate) static BUILD_VERIFICATIONS: [&str; 3] = ["none", "rollback", "report"];
+/// Skips the verification
+pub(crate) static SKIP_BUILD_VERIFICATION: &str = "none";
+/// Discards the rewrites of the packages that fail the verification
+pub(crate) static ROLLBACK: &str = "rollback";
+/// The name of the (pseudo) rule reported for the files of the packages that no longer build after the cleanup
+pub(crate) static BUILD_FAILURE: &str = "build_failure";
+/// The name of the (pseudo) rule reported for the files of the packages whose tests fail after the cleanup
+pub(crate) static TEST_FAILURE: &str = "test_failure";
+/// The name of the (pseudo) rule reported for the files of the packages whose tests pass after the cleanup
+pub(crate) static TESTS_PASSED: &str = "tests_passed";
+/// The tag of the `build_failure` and `test_failure` matches holding the output of the go command
+pub(crate) static GO_OUTPUT: &str = "output";
+
+/// The `-overlay` file of the go command, i.e. the file replacing each file of the package (see `go help build`).
+/// An empty replacement deletes the file.
+#[derive(Serialize, Debug, Default, PartialEq, Eq)]
+#[serde(rename_all = "PascalCase")]
+struct Overlay {
+  replace: BTreeMap<String, String>,
+}
+
+/// Builds the Go package of the `directory` along with its tests (i.e. `go build` and `go test -c`), with the `files`
+/// (i.e. the rewritten content of its files, by path) in place of their content on disk, so that the package is
+/// verified before the rewrites are persisted (or without persisting them at all, i.e. `dry_run`).
+/// An empty content stands for a deleted file (see `delete_file_if_empty`).
+/// The binaries are written to a temporary directory, i.e. the code base is left untouched.
+///
+/// Returns the output of the compiler if the package does not build.
+pub(crate) fn build_package(directory: &Path, files: &[(PathBuf, String)]) -> Result<(), String> {
+  let temp_dir = TempDir::new("piranha_build")
+    .map_err(|e| format!("Could not create a temporary directory : {e}"))?;
+  let overlay = write_overlay(temp_dir.path(), files)?;
+  let (binary, test_binary) = (
+    temp_dir.path().join("package"),
+    temp_dir.path().join("package.test"),
+  );
+  let mut commands = vec![vec![
+    "build",
+    "-o",
+    binary.to_str().unwrap_or_default(),
+    "-overlay",
+    &overlay,
+    ".",
+  ]];
+  if has_test_files(directory) {
+    commands.push(vec![
+      "test",
+      "-c",
+      "-o",
+      test_binary.to_str().unwrap_or_default(),
+      "-overlay",
+      &overlay,
+      ".",
+    ]);
+  }
+  run_go(directory, &commands, files)
+}
+
+/// Runs the tests of the Go package of the `directory` (i.e. `go test`, without caching the results), with the `files`
+/// in place of their content on disk (see `build_package`).
+///
+/// Returns the output of the tests if they fail (or do not build).
+pub(crate) fn test_package(directory: &Path, files: &[(PathBuf, String)]) -> Result<(), String> {
+  let temp_dir = TempDir::new("piranha_test")
+    .map_err(|e| format!("Could not create a temporary directory : {e}"))?;
+  let overlay = write_overlay(temp_dir.path(), files)?;
+  run_go(
+    directory,
+    &[vec!["test", "-count=1", "-overlay", &overlay, "."]],
+    files,
+  )
+}
+
+/// Runs the go `commands` one after the other in the `directory`, and stops at the first failing one.
+/// Returns the output of the failing command (i.e. its stdout, e.g. the failing tests, and its stderr, e.g. the compiler errors).
+fn run_go(
+  directory: &Path, commands: &[Vec<&str>], files: &[(PathBuf, String)],
+) -> Result<(), String> {
+  for args in commands {
+    let output = Command::new("go")
+      .args(args)
+      .current_dir(directory)
+      .output()
+      .map_err(|e| format!("Could not run go : {e}"))?;
+    if !output.status.success() {
+      let output = format!(
+        "{}\n{}",
+        String::from_utf8_lossy(&output.stdout).trim(),
+        String::from_utf8_lossy(&output.stderr).trim()
+      );
+      return Err(restore_file_names(output.trim(), files));
+    }
+  }
+  Ok(())
+}
+
+/// Verifies the package of the `directory` with the `files` (i.e. `verify`, e.g. `build_package`), if it passes the
+/// verification with its content on disk, i.e. before the cleanup (`what` describes the verification, e.g. `build`).
+/// Returns `None` (with a warning) if the package fails before the cleanup, since its failure would not be due to the rewrites.
+pub(crate) fn verify_package(
+  directory: &Path, files: &[(PathBuf, String)],
+  verify: fn(&Path, &[(PathBuf, String)]) -> Result<(), String>, what: &str,
+) -> Option<Result<(), String>> {
+  if let Err(e) = verify(directory, &[]) {
+    warn!(
+      "The package {:?} is not verified, since it does not {} before the cleanup : {}",
+      directory, what, e
+    );
+    return None;
+  }
+  Some(verify(directory, files))
+}
+
+/// Replaces the paths of the copies of the `files` in the output of the compiler with the files they replace
+/// (i.e. relative to the directory of the package, like the compiler reports them), e.g. `../tmp/0_flags.go:3:2` with `./flags.go:3:2`.
+fn restore_file_names(output: &str, files: &[(PathBuf, String)]) -> String {
+  let mut output = output.to_string();
+  for (index, (path, _)) in files.iter().enumerate() {
+    let name = path.file_name().unwrap_or_default().to_string_lossy();
+    let copy = Regex::new(&format!(r"[^\s:]*/{}_{}", index, regex::escape(&name))).unwrap();
+    output = copy
+      .replace_all(&output, format!("./{name}").as_str())
+      .to_string();
+  }
+  output
+}
+
+/// Checks if the `directory` contains test files, i.e. the package is built (and tested) along with its tests
+pub(crate) fn has_test_files(directory: &Path) -> bool {
+  directory
+    .read_dir()
+    .map(|entries| {
+      entries
+        .flatten()
+        .any(|e| e.file_name().to_string_lossy().ends_with("_test.go"))
+    })
+    .unwrap_or(false)
+}
+
+/// Writes the content of the `files` into the `directory`, along with the overlay replacing the files with them.
+/// Returns the path of the overlay.
+fn write_overlay(directory: &Path, files: &[(PathBuf, String)]) -> Result<String, String> {
+  let overlay = get_overlay(directory, files);
+  for ((_, content), replacement) in files.iter().zip(get_replacements(directory, files)) {
+    if let Some(replacement) = replacement {
+      fs::write(&replacement, content)
+        .map_err(|e| format!("Could not write {:?} : {e}", replacement))?;
+    }
+  }
+  let path = directory.join("overlay.json");
+  let json = serde_json::to_string(&overlay).map_err(|e| e.to_string())?;
+  fs::write(&path, json).map_err(|e| format!("Could not write the overlay : {e}"))?;
+  Ok(path.to_string_lossy().to_string())
+}
+
+/// Returns the overlay replacing each of the `files` (by its absolute path) with its copy in the `directory`
+fn get_overlay(directory: &Path, files: &[(PathBuf, String)]) -> Overlay {
+  Overlay {
+    replace: files
+      .iter()
+      .zip(get_replacements(directory, files))
+      .map(|((path, _), replacement)| {
+        let path = path.canonicalize().unwrap_or_else(|_| path.to_path_buf());
+        (
+          path.to_string_lossy().to_string(),
+          replacement
+            .map(|r| r.to_string_lossy().to_string())
+            .unwrap_or_default(),
+        )
+      })
+      .collect(),
+  }
+}
+
+/// Returns the path of the copy of each of the `files` in the `directory`, or `None` for the deleted files (i.e. empty)
+fn get_replacements(directory: &Path, files: &[(PathBuf, String)]) -> Vec<Option<PathBuf>> {
+  files
+    .iter()
+    .enumerate()
+    .map(|(index, (path, content))| {
+      // The copies keep the name of the files, since the go command selects the files by their suffix (e.g. `_test.go`)
+      let name = path.file_name().unwrap_or_default().to_string_lossy();
+      (!content.trim().is_empty()).then(|| directory.join(format!("{index}_{name}")))
+    })
+    .collect()
+}
+
+// Implements instance methods related to verifying the build and the tests of the rewritten packages
+impl SourceCodeUnit {
+  /// Reports the package clause of this file, as the outcome of the verification of its package (i.e. the `rule`,
+  /// e.g. `build_failure`), along with the `output` of the go command (if any). A failure is reported whether the
+  /// rewrites are kept or rolled back (see `verify_build` and `verify_tests`).
+  pub(crate) fn report_package_verification(&mut self, rule: &str, output: Option<&str>) {
+    let package_clause = {
+      let root_node = self.root_node();
+      let mut cursor = root_node.walk();
+      let node = root_node
+        .named_children(&mut cursor)
+        .find(|n| n.kind() == "package_clause")
+        .unwrap_or(root_node);
+      Match::new(
+        node
+          .utf8_text(self.code().as_bytes())
+          .unwrap_or_default()
+          .to_string(),
+        node.range(),
+        output
+          .map(|o| (GO_OUTPUT.to_string(), o.to_string()))
+          .into_iter()
+          .collect(),
+      )
+    };
+    self.matches_mut().push((rule.to_string(), package_clause));
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/build_verification_test.rs"]
+mod build_verification_test;
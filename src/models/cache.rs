@@ -0,0 +1,253 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::{hash_map::DefaultHasher, BTreeMap, BTreeSet, HashMap, HashSet},
+  fs,
+  hash::{Hash, Hasher},
+  path::{Path, PathBuf},
+};
+
+use itertools::Itertools;
+use log::{debug, info, warn};
+
+use super::{
+  piranha_arguments::PiranhaArguments,
+  rule::{InstantiatedRule, Rule},
+  source_code_unit::SourceCodeUnit,
+};
+use crate::utilities::read_file;
+
+/// The name of the file (in the `cache_dir`) caching the analysis of the files across runs
+static CACHE_FILE_NAME: &str = "analysis_cache.json";
+
+/// Caches, across runs, the files found clean by each pass of the rules (i.e. none of the rules matched them),
+/// keyed by the hash of their content, so that the unchanged files are not parsed and queried again.
+/// A pass is identified by the fingerprint of its rules, substitutions and of the arguments affecting the matches
+/// (see `get_pass_fingerprint`), thus any change of the configuration (or of the version of Piranha) invalidates the cache.
+/// Only the entries of the passes of the current run are persisted, so that the cache does not grow across runs.
+#[derive(Debug, Default)]
+pub(crate) struct AnalysisCache {
+  // The path of the cache file
+  path: PathBuf,
+  // The hashes of the files found clean by each pass of the previous run
+  previous: BTreeMap<String, BTreeSet<String>>,
+  // The hashes of the files found clean (or skipped) by each pass of this run
+  current: BTreeMap<String, BTreeSet<String>>,
+  // The number of files skipped so far, since found clean by the previous run
+  hits: usize,
+}
+
+impl AnalysisCache {
+  /// Loads the cache from the `cache_dir` (if any).
+  /// A missing or unreadable cache file is treated as an empty cache.
+  pub(crate) fn load(piranha_arguments: &PiranhaArguments) -> Option<AnalysisCache> {
+    // The analysis is not skipped when explaining a line, so that all the rules are reported
+    if piranha_arguments.cache_dir().is_empty() || !piranha_arguments.explain().is_empty() {
+      return None;
+    }
+    let path = Path::new(piranha_arguments.cache_dir()).join(CACHE_FILE_NAME);
+    let previous = read_file(&path)
+      .ok()
+      .and_then(|content| match serde_json::from_str(&content) {
+        Ok(entries) => Some(entries),
+        Err(e) => {
+          warn!("Ignoring the invalid cache file {:?} : {}", path, e);
+          None
+        }
+      })
+      .unwrap_or_default();
+    debug!("Loaded the analysis cache {:?}", path);
+    Some(AnalysisCache {
+      path,
+      previous,
+      ..Default::default()
+    })
+  }
+
+  /// Removes from the `files` of the `pass` the untouched ones (see `is_untouched`) found clean by the same pass
+  /// in the previous run. Returns the hashes of the remaining untouched files, i.e. the ones to record once processed.
+  pub(crate) fn skip_clean_files(
+    &mut self, pass: &str, files: &mut HashMap<PathBuf, String>,
+    source_code_units: &HashMap<PathBuf, SourceCodeUnit>,
+  ) -> HashMap<PathBuf, String> {
+    let mut candidates = HashMap::new();
+    for (path, content) in files.iter() {
+      if source_code_units
+        .get(path)
+        .map(|s| !s.is_untouched())
+        .unwrap_or(false)
+      {
+        continue;
+      }
+      candidates.insert(path.to_path_buf(), hash_file(path, content));
+    }
+    let clean = candidates
+      .iter()
+      .filter(|(_, h)| {
+        self
+          .previous
+          .get(pass)
+          .map(|p| p.contains(*h))
+          .unwrap_or(false)
+      })
+      .map(|(p, _)| p.to_path_buf())
+      .collect_vec();
+    for path in clean {
+      files.remove(&path);
+      if let Some(hash) = candidates.remove(&path) {
+        self
+          .current
+          .entry(pass.to_string())
+          .or_default()
+          .insert(hash);
+      }
+      self.hits += 1;
+    }
+    candidates
+  }
+
+  /// Records the `candidates` (see `skip_clean_files`) that were processed by the `pass` and left untouched.
+  pub(crate) fn record_clean_files(
+    &mut self, pass: &str, candidates: HashMap<PathBuf, String>, processed: &HashSet<PathBuf>,
+    source_code_units: &HashMap<PathBuf, SourceCodeUnit>,
+  ) {
+    for (path, hash) in candidates {
+      if processed.contains(&path)
+        && source_code_units
+          .get(&path)
+          .map(|s| s.is_untouched())
+          .unwrap_or(false)
+      {
+        self
+          .current
+          .entry(pass.to_string())
+          .or_default()
+          .insert(hash);
+      }
+    }
+  }
+
+  /// Writes the entries of this run to the cache file (through a temporary file, so that a concurrent run never reads a partial cache).
+  pub(crate) fn persist(&self) {
+    if self.hits > 0 {
+      info!(
+        "Skipped {} files found clean by a previous run (see `cache_dir`)",
+        self.hits
+      );
+    }
+    let temp_path = self.path.with_extension("json.tmp");
+    let result = self
+      .path
+      .parent()
+      .map(fs::create_dir_all)
+      .unwrap_or(Ok(()))
+      .and_then(|_| fs::write(&temp_path, serde_json::to_string(&self.current).unwrap()))
+      .and_then(|_| fs::rename(&temp_path, &self.path));
+    match result {
+      Ok(_) => debug!("Writing the analysis cache {:?}", self.path),
+      Err(e) => warn!("Could not write the analysis cache {:?} : {}", self.path, e),
+    }
+  }
+}
+
+// Implements instance methods related to caching the analysis of the files across runs
+impl SourceCodeUnit {
+  /// Checks if none of the rules applied so far matched this file (i.e. it is neither rewritten, nor matched by a match-only rule).
+  pub(crate) fn is_untouched(&self) -> bool {
+    self.rewrites().is_empty()
+      && self.matches().is_empty()
+      && self.suppressed_matches().is_empty()
+      && self.code() == self.original_content()
+  }
+}
+
+/// Returns the fingerprint of a pass applying the (global) `rules` with the `global_substitutions` found so far,
+/// i.e. the hash of everything (but the content of the files) the outcome of the pass depends on:
+/// the version of Piranha, the rule graph, the rules and substitutions of the pass, and the arguments affecting the matches.
+/// The sets (e.g. the filters of a rule) are sorted, so that the fingerprint is stable across runs.
+pub(crate) fn get_pass_fingerprint(
+  rules: &[InstantiatedRule], global_substitutions: &HashMap<String, String>,
+  piranha_arguments: &PiranhaArguments,
+) -> String {
+  let mut hasher = DefaultHasher::new();
+  env!("CARGO_PKG_VERSION").hash(&mut hasher);
+  format!("{:?}", piranha_arguments.language().supported_language()).hash(&mut hasher);
+  format!(
+    "{:?}",
+    (
+      piranha_arguments.delete_file_if_empty(),
+      piranha_arguments.delete_consecutive_new_lines(),
+      piranha_arguments.global_tag_prefix(),
+      piranha_arguments.number_of_ancestors_in_parent_scope(),
+      piranha_arguments.cleanup_comments_buffer(),
+      piranha_arguments.cleanup_comments(),
+      piranha_arguments.allow_dirty_ast(),
+      piranha_arguments.remove_unused_imports(),
+      piranha_arguments.aggressive_dead_code(),
+      piranha_arguments.substitute_only(),
+      piranha_arguments.confidence_threshold(),
+    )
+  )
+  .hash(&mut hasher);
+  for rule in piranha_arguments.rule_graph().rules() {
+    describe_rule(rule).hash(&mut hasher);
+  }
+  format!("{:?}", piranha_arguments.rule_graph().edges()).hash(&mut hasher);
+  for rule in rules {
+    describe_rule(rule.rule()).hash(&mut hasher);
+    rule.query().get_query().hash(&mut hasher);
+    rule.replace().hash(&mut hasher);
+    describe_substitutions(rule.substitutions()).hash(&mut hasher);
+  }
+  describe_substitutions(global_substitutions).hash(&mut hasher);
+  format!("{:016x}", hasher.finish())
+}
+
+/// Returns the hash of the file, i.e. of its path and content
+pub(crate) fn hash_file(path: &Path, content: &str) -> String {
+  let mut hasher = DefaultHasher::new();
+  path.hash(&mut hasher);
+  content.hash(&mut hasher);
+  format!("{:016x}", hasher.finish())
+}
+
+/// Describes the rule, with its sets (i.e. groups, holes and filters) sorted
+fn describe_rule(rule: &Rule) -> String {
+  format!(
+    "{} {} {} {} {} {:?} {:?} {:?} {}",
+    rule.name(),
+    rule.query().get_query(),
+    rule.replace_node(),
+    rule.replace(),
+    rule.is_seed_rule(),
+    rule.groups().iter().sorted().collect_vec(),
+    rule.holes().iter().sorted().collect_vec(),
+    rule
+      .filters()
+      .iter()
+      .map(|f| format!("{f:?}"))
+      .sorted()
+      .collect_vec(),
+    rule.confidence(),
+  )
+}
+
+/// Describes the substitutions, sorted by tag
+fn describe_substitutions(substitutions: &HashMap<String, String>) -> String {
+  format!("{:?}", substitutions.iter().sorted().collect_vec())
+}
+
+#[cfg(test)]
+#[path = "unit_tests/cache_test.rs"]
+mod cache_test;
@@ -0,0 +1,120 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::HashSet,
+  path::{Path, PathBuf},
+  process::Command,
+};
+
+use log::debug;
+
+/// Returns the (canonicalized) paths of the files changed on the current branch of the git repository containing the code base,
+/// i.e. since its merge base with the `since` ref (e.g. `origin/main`), including the uncommitted and the untracked (but not ignored) files.
+/// The deleted files are skipped.
+pub(crate) fn get_changed_files(
+  path_to_codebase: &str, since: &str,
+) -> Result<HashSet<PathBuf>, String> {
+  let directory = get_directory(path_to_codebase);
+  let top_level = get_top_level(directory)?;
+  let merge_base = run_git(directory, &["merge-base", since, "HEAD"])?;
+  let changed = run_git(
+    &top_level,
+    &[
+      "diff",
+      "--name-only",
+      "-z",
+      "--diff-filter=d",
+      merge_base.trim(),
+    ],
+  )?;
+  let untracked = run_git(
+    &top_level,
+    &["ls-files", "--others", "--exclude-standard", "-z"],
+  )?;
+  let changed_files = get_paths(&top_level, &[&changed, &untracked]);
+  debug!(
+    "{} files changed since `{since}` (i.e. {})",
+    changed_files.len(),
+    merge_base.trim()
+  );
+  Ok(changed_files)
+}
+
+/// Returns the (canonicalized) paths of the files staged in the git repository containing the code base (i.e. for `hook`),
+/// i.e. added, copied, modified or renamed in its index. The deleted files are skipped.
+pub(crate) fn get_staged_files(path_to_codebase: &str) -> Result<HashSet<PathBuf>, String> {
+  let top_level = get_top_level(get_directory(path_to_codebase))?;
+  let staged = run_git(
+    &top_level,
+    &[
+      "diff",
+      "--cached",
+      "--name-only",
+      "-z",
+      "--diff-filter=ACMR",
+    ],
+  )?;
+  let staged_files = get_paths(&top_level, &[&staged]);
+  debug!("{} files staged", staged_files.len());
+  Ok(staged_files)
+}
+
+/// Returns the directory of the code base (i.e. the parent directory of a single file)
+pub(super) fn get_directory(path_to_codebase: &str) -> &Path {
+  let path = Path::new(path_to_codebase);
+  if path.is_file() {
+    path.parent().unwrap_or(path)
+  } else {
+    path
+  }
+}
+
+/// Returns the root of the working tree of the git repository containing the directory
+pub(super) fn get_top_level(directory: &Path) -> Result<PathBuf, String> {
+  Ok(PathBuf::from(
+    run_git(directory, &["rev-parse", "--show-toplevel"])?.trim(),
+  ))
+}
+
+/// Returns the (canonicalized) paths of the NUL-separated lists of paths (relative to the root of the working tree) output by git
+fn get_paths(top_level: &Path, outputs: &[&str]) -> HashSet<PathBuf> {
+  outputs
+    .iter()
+    .flat_map(|o| o.split('\0'))
+    .filter(|p| !p.is_empty())
+    .filter_map(|p| top_level.join(p).canonicalize().ok())
+    .collect()
+}
+
+/// Runs the git command in the given directory, and returns its stdout
+pub(super) fn run_git(directory: &Path, args: &[&str]) -> Result<String, String> {
+  let output = Command::new("git")
+    .arg("-C")
+    .arg(directory)
+    .args(args)
+    .output()
+    .map_err(|e| format!("Could not run git : {e}"))?;
+  if !output.status.success() {
+    return Err(format!(
+      "`git {}` failed : {}",
+      args.join(" "),
+      String::from_utf8_lossy(&output.stderr).trim()
+    ));
+  }
+  Ok(String::from_utf8_lossy(&output.stdout).to_string())
+}
+
+#[cfg(test)]
+#[path = "unit_tests/changed_files_test.rs"]
+mod changed_files_test;
@@ -0,0 +1,151 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::fmt;
+
+use getset::Getters;
+use itertools::Itertools;
+use serde_derive::Serialize;
+
+use super::{
+  dynamic_flag_names::STALE_FLAG_NAME, piranha_arguments::PiranhaArguments,
+  piranha_output::PiranhaOutputSummary, report::get_flags_of_rewrites, sarif::map_to_original,
+};
+
+/// A usage of a stale flag remaining in the code base (see `check`), i.e. a rewrite or a match of a seed rule
+#[derive(Serialize, Debug, Clone, PartialEq, Eq, Getters)]
+pub struct FlagUsage {
+  // The path of the file
+  #[get = "pub"]
+  path: String,
+  // The (1-based) line and column of the usage, in the original content of the file
+  #[get = "pub"]
+  line: usize,
+  #[get = "pub"]
+  column: usize,
+  // The stale flag used (i.e. its `stale_flag_name`, if any)
+  #[get = "pub"]
+  flag: Option<String>,
+  // The seed rule matching the usage
+  #[get = "pub"]
+  rule: String,
+  // The (first line of the) code of the usage
+  #[get = "pub"]
+  code: String,
+  // The byte range of the usage in the original content of the file, if it is a rewrite (i.e. the range of a match
+  // refers to the content of the file after all the rewrites)
+  #[serde(skip)]
+  #[get = "pub(crate)"]
+  original_range: Option<(usize, usize)>,
+}
+
+impl FlagUsage {
+  fn new(
+    summary: &PiranhaOutputSummary, offset: usize, original_range: Option<(usize, usize)>,
+    flag: Option<String>, rule: &str, code: &str,
+  ) -> FlagUsage {
+    let content = summary.original_content();
+    let prefix = content.get(..offset.min(content.len())).unwrap_or(content);
+    let line_start = prefix.rfind('\n').map(|i| i + 1).unwrap_or(0);
+    FlagUsage {
+      path: summary.path().to_string(),
+      line: prefix.matches('\n').count() + 1,
+      column: prefix[line_start..].chars().count() + 1,
+      flag,
+      rule: rule.to_string(),
+      code: code.lines().next().unwrap_or_default().trim().to_string(),
+      original_range,
+    }
+  }
+
+  /// Returns the message of the usage (i.e. without its position)
+  pub fn message(&self) -> String {
+    let flag = self
+      .flag
+      .as_ref()
+      .map(|f| format!("the stale flag `{f}`"))
+      .unwrap_or_else(|| "a stale flag".to_string());
+    format!(
+      "{flag} is still used (matched by `{}`) - {}",
+      self.rule, self.code
+    )
+  }
+}
+
+impl fmt::Display for FlagUsage {
+  /// Formats the usage like a compiler diagnostic, i.e. `path:line:column: message`
+  fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+    write!(
+      f,
+      "{}:{}:{}: {}",
+      self.path,
+      self.line,
+      self.column,
+      self.message()
+    )
+  }
+}
+
+/// Returns the usages of the stale flags remaining in the files (sorted by path and position), i.e. the rewrites
+/// and the matches of the seed rules. The matches exempted by the `piranha:ignore` directives are not usages.
+pub fn get_remaining_flag_usages(
+  summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+) -> Vec<FlagUsage> {
+  let seed_rules = piranha_arguments.get_seed_rule_names();
+  let default_flag = piranha_arguments
+    .input_substitutions()
+    .get(STALE_FLAG_NAME)
+    .cloned();
+  let mut usages = vec![];
+  for summary in summaries {
+    let rewrites = summary.rewrites();
+    for (index, (edit, flag)) in rewrites
+      .iter()
+      .zip(get_flags_of_rewrites(summary, piranha_arguments))
+      .enumerate()
+      .filter(|(_, (e, _))| seed_rules.contains(e.matched_rule()))
+    {
+      let (start, end) = map_to_original(rewrites, index, edit.p_match());
+      usages.push(FlagUsage::new(
+        summary,
+        start,
+        Some((start, end)),
+        flag,
+        edit.matched_rule(),
+        edit.p_match().matched_string(),
+      ));
+    }
+    for (rule, p_match) in summary
+      .matches()
+      .iter()
+      .filter(|(r, _)| seed_rules.contains(r))
+    {
+      usages.push(FlagUsage::new(
+        summary,
+        p_match.range().start_byte,
+        None,
+        default_flag.clone(),
+        rule,
+        p_match.matched_string(),
+      ));
+    }
+  }
+  usages
+    .into_iter()
+    .sorted_by(|a, b| (&a.path, a.line, a.column).cmp(&(&b.path, b.line, b.column)))
+    .collect()
+}
+
+#[cfg(test)]
+#[path = "unit_tests/check_test.rs"]
+mod check_test;
@@ -0,0 +1,128 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::BTreeMap,
+  fs,
+  path::{Component, Path},
+};
+
+use getset::Getters;
+use pyo3::prelude::{pyclass, pymethods};
+use serde_derive::Serialize;
+
+use super::{
+  piranha_arguments::PiranhaArguments, piranha_output::PiranhaOutputSummary,
+  statistics::RunStatistics,
+};
+use crate::utilities::gen_py_str_methods;
+
+/// The outcome of cleaning up files in memory (see `clean`)
+#[derive(Serialize, Debug, Clone, Default, Getters)]
+#[pyclass]
+pub struct CleanResult {
+  /// The output summary of each file rewritten (or matched), with its path relative to the root of the input files
+  #[pyo3(get)]
+  #[get = "pub"]
+  summaries: Vec<PiranhaOutputSummary>,
+  /// The content of each input file after the cleanup, by path (the files deleted by the cleanup are omitted)
+  #[pyo3(get)]
+  #[get = "pub"]
+  files: BTreeMap<String, String>,
+  /// The summary statistics of the cleanup
+  #[get = "pub"]
+  #[serde(skip)]
+  statistics: RunStatistics,
+}
+
+gen_py_str_methods!(CleanResult);
+
+impl CleanResult {
+  /// Builds the outcome of the cleanup of the input `files` (materialized under `root`) from the output summaries of the run
+  pub(crate) fn new(
+    files: &BTreeMap<String, String>, root: &Path, summaries: Vec<PiranhaOutputSummary>,
+    statistics: RunStatistics, piranha_arguments: &PiranhaArguments,
+  ) -> CleanResult {
+    let mut files = files.clone();
+    let summaries = summaries
+      .into_iter()
+      .map(|s| {
+        let path = Path::new(s.path())
+          .strip_prefix(root)
+          .map(|p| p.to_string_lossy().to_string())
+          .unwrap_or_else(|_| s.path().to_string());
+        if s.content().is_empty() && *piranha_arguments.delete_file_if_empty() {
+          files.remove(&path);
+        } else {
+          files.insert(path.to_string(), s.content().to_string());
+        }
+        s.with_path(&path)
+      })
+      .collect();
+    CleanResult {
+      summaries,
+      files,
+      statistics,
+    }
+  }
+}
+
+/// Checks that the input files of `clean` are compatible with the `piranha_arguments`, and that their paths are relative
+/// paths within the root of the files (e.g. `payments/payments.go`, not `/tmp/payments.go` nor `../payments.go`)
+pub(crate) fn validate_clean_input(
+  files: &BTreeMap<String, String>, piranha_arguments: &PiranhaArguments,
+) -> Result<(), String> {
+  if !piranha_arguments.code_snippet().is_empty() {
+    return Err(
+      "The files are cleaned up in memory, the `code_snippet` should be empty".to_string(),
+    );
+  }
+  if *piranha_arguments.interactive() || !piranha_arguments.explain().is_empty() {
+    return Err(
+      "The files are cleaned up in memory, neither `interactive` nor `explain` are supported"
+        .to_string(),
+    );
+  }
+  if !piranha_arguments.since().is_empty() || *piranha_arguments.hook() {
+    return Err(
+      "The files are cleaned up in memory, neither `since` nor `hook` are supported".to_string(),
+    );
+  }
+  if let Some(path) = files.keys().find(|p| {
+    p.is_empty()
+      || !Path::new(p)
+        .components()
+        .all(|c| matches!(c, Component::Normal(_) | Component::CurDir))
+  }) {
+    return Err(format!(
+      "The path `{path}` of an input file should be relative, within the root of the files"
+    ));
+  }
+  Ok(())
+}
+
+/// Writes the input `files` of `clean` under `root`
+pub(crate) fn write_files(files: &BTreeMap<String, String>, root: &Path) -> Result<(), String> {
+  for (path, content) in files {
+    let file = root.join(path);
+    if let Some(parent) = file.parent() {
+      fs::create_dir_all(parent).map_err(|e| format!("Could not create {parent:?} : {e}"))?;
+    }
+    fs::write(&file, content).map_err(|e| format!("Could not write {file:?} : {e}"))?;
+  }
+  Ok(())
+}
+
+#[cfg(test)]
+#[path = "unit_tests/clean_test.rs"]
+mod clean_test;
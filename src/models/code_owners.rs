@@ -0,0 +1,233 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, fs, path::Path};
+
+use glob::{MatchOptions, Pattern};
+use itertools::Itertools;
+use jwalk::WalkDir;
+use log::debug;
+
+/// The locations of the `CODEOWNERS` file (relative to the root of the repository), in the order GitHub looks them up
+static CODEOWNERS_PATHS: [&str; 3] = [".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"];
+/// The name of the files declaring the owners of their directory (e.g. in Chromium, Kubernetes or Bazel repositories)
+static OWNERS_FILE: &str = "OWNERS";
+
+/// The rules of a `CODEOWNERS` file, i.e. the owners of the files matching each pattern,
+/// along with the `OWNERS` files of the directories of the repository (if any)
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub(crate) struct CodeOwners {
+  rules: Vec<(String, Vec<String>)>,
+  // The `OWNERS` files, keyed by their directory (relative to the root of the repository, i.e. empty for the root)
+  owners_files: HashMap<String, OwnersFile>,
+}
+
+/// The owners declared by an `OWNERS` file for the files of its directory (and its subdirectories)
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+struct OwnersFile {
+  owners: Vec<String>,
+  // The owners of the files (of the directory) matching each pattern, i.e. `per-file pattern=owners`
+  per_file: Vec<(String, Vec<String>)>,
+}
+
+impl CodeOwners {
+  /// Reads the `CODEOWNERS` file of the repository rooted at `top_level` (if any), and its `OWNERS` files (if any)
+  pub(crate) fn read(top_level: &Path) -> CodeOwners {
+    let mut code_owners = CODEOWNERS_PATHS
+      .iter()
+      .map(|p| top_level.join(p))
+      .find(|p| p.is_file())
+      .and_then(|p| {
+        debug!("Reading the code owners from {p:?}");
+        fs::read_to_string(p).ok()
+      })
+      .map(|content| CodeOwners::parse(&content))
+      .unwrap_or_default();
+    for entry in WalkDir::new(top_level)
+      .into_iter()
+      .filter_map(|e| e.ok())
+      .filter(|e| e.file_name() == OWNERS_FILE && e.path().is_file())
+    {
+      let path = entry.path();
+      let directory = path
+        .parent()
+        .and_then(|d| d.strip_prefix(top_level).ok())
+        .map(|d| d.to_string_lossy().to_string())
+        .unwrap_or_default();
+      debug!("Reading the owners from {path:?}");
+      if let Ok(content) = fs::read_to_string(&path) {
+        code_owners.add_owners_file(&directory, &content);
+      }
+    }
+    code_owners
+  }
+
+  /// Adds the `OWNERS` file of the directory (relative to the root of the repository), in the format of Chromium
+  /// (i.e. an owner on each line, along with the `per-file pattern=owners` lines) or of Kubernetes (i.e. the `approvers`,
+  /// or the `reviewers`, of its YAML content, which are GitHub users). The other directives (e.g. `set noparent`) are skipped.
+  pub(crate) fn add_owners_file(&mut self, directory: &str, content: &str) {
+    let mut owners_file = OwnersFile::default();
+    let (mut section, mut approvers, mut reviewers) = (String::new(), vec![], vec![]);
+    for line in content
+      .lines()
+      .map(|l| l.split('#').next().unwrap_or_default().trim())
+      .filter(|l| !l.is_empty())
+    {
+      if let Some(rule) = line.strip_prefix("per-file ") {
+        if let Some((patterns, owners)) = rule.split_once('=') {
+          let owners = owners
+            .split(',')
+            .map(|o| o.trim().to_string())
+            .collect_vec();
+          for pattern in patterns.split(',') {
+            owners_file
+              .per_file
+              .push((pattern.trim().to_string(), owners.clone()));
+          }
+        }
+      } else if let Some(item) = line.strip_prefix("- ") {
+        let user = item.trim().trim_matches(|c| c == '\'' || c == '"');
+        let user = format!("@{}", user.trim_start_matches('@'));
+        match section.as_str() {
+          "approvers" => approvers.push(user),
+          "reviewers" => reviewers.push(user),
+          _ => {}
+        }
+      } else if let Some(name) = line.strip_suffix(':') {
+        section = name.trim().to_string();
+      } else if !line.contains(char::is_whitespace)
+        && !line.contains(':')
+        && !line.contains("://")
+        && line != "*"
+      {
+        owners_file.owners.push(line.to_string());
+      }
+    }
+    owners_file.owners.extend(if approvers.is_empty() {
+      reviewers
+    } else {
+      approvers
+    });
+    if !owners_file.owners.is_empty() || !owners_file.per_file.is_empty() {
+      self
+        .owners_files
+        .insert(directory.trim_matches('/').to_string(), owners_file);
+    }
+  }
+
+  /// Parses the content of a `CODEOWNERS` file, i.e. a pattern followed by its owners on each line, skipping the comments
+  pub(crate) fn parse(content: &str) -> CodeOwners {
+    let rules = content
+      .lines()
+      .map(|l| l.split('#').next().unwrap_or_default().trim())
+      .filter(|l| !l.is_empty())
+      .filter_map(|l| {
+        let mut fields = l.split_whitespace();
+        let pattern = fields.next()?.to_string();
+        Some((pattern, fields.map(|o| o.to_string()).collect()))
+      })
+      .collect();
+    CodeOwners {
+      rules,
+      ..Default::default()
+    }
+  }
+
+  /// Returns the owners (e.g. `@user`, `@org/team` or an email) of the file at `path` (relative to the root of the repository),
+  /// i.e. the ones of the last matching pattern. A pattern without owners makes the file unowned.
+  /// The files matching no pattern are owned by the nearest `OWNERS` file of their directories (if any).
+  pub(crate) fn get_owners(&self, path: &str) -> Vec<String> {
+    self
+      .rules
+      .iter()
+      .rev()
+      .find(|(pattern, _)| matches_pattern(pattern, path))
+      .map(|(_, owners)| owners.clone())
+      .unwrap_or_else(|| self.get_owners_from_owners_files(path))
+  }
+
+  /// Returns the owners of the file at `path` declared by the nearest `OWNERS` file of its directories (i.e. from its own
+  /// directory up to the root), i.e. the ones of its matching `per-file` patterns (if any) along with the ones of the directory.
+  fn get_owners_from_owners_files(&self, path: &str) -> Vec<String> {
+    let path = Path::new(path.trim_start_matches("./"));
+    let file_name = path
+      .file_name()
+      .map(|f| f.to_string_lossy().to_string())
+      .unwrap_or_default();
+    for directory in path.ancestors().skip(1) {
+      if let Some(owners_file) = self.owners_files.get(directory.to_string_lossy().as_ref()) {
+        let per_file = owners_file
+          .per_file
+          .iter()
+          .filter(|(pattern, _)| {
+            Pattern::new(pattern)
+              .map(|p| p.matches(&file_name))
+              .unwrap_or(false)
+          })
+          .flat_map(|(_, owners)| owners.clone());
+        let owners = per_file
+          .chain(owners_file.owners.clone())
+          .unique()
+          .collect_vec();
+        if !owners.is_empty() {
+          return owners;
+        }
+      }
+    }
+    vec![]
+  }
+
+  /// Returns the owners of the files, sorted and without duplicates
+  pub(crate) fn get_owners_of_files<'a>(
+    &self, paths: impl Iterator<Item = &'a str>,
+  ) -> Vec<String> {
+    paths
+      .flat_map(|p| self.get_owners(p))
+      .unique()
+      .sorted()
+      .collect()
+  }
+}
+
+/// Checks if the `CODEOWNERS` pattern (i.e. with the syntax of `.gitignore`) matches the path, relative to the root of the repository.
+/// A pattern starting with (or containing) a `/` is relative to the root, and matches at any depth otherwise.
+/// A pattern matching a directory matches all the files within it, and a pattern ending with a `/` only matches directories.
+fn matches_pattern(pattern: &str, path: &str) -> bool {
+  let anchored = pattern.trim_end_matches('/').contains('/');
+  let directory_only = pattern.ends_with('/');
+  let pattern = pattern.trim_start_matches('/').trim_end_matches('/');
+  let path = path.trim_start_matches("./");
+  let options = MatchOptions {
+    require_literal_separator: true,
+    ..Default::default()
+  };
+  let candidates = if anchored {
+    vec![pattern.to_string()]
+  } else {
+    vec![pattern.to_string(), format!("**/{pattern}")]
+  };
+  candidates.iter().any(|c| {
+    let file_match = !directory_only
+      && Pattern::new(c)
+        .map(|p| p.matches_with(path, options))
+        .unwrap_or(false);
+    let directory_match = Pattern::new(&format!("{c}/**"))
+      .map(|p| p.matches_with(path, options))
+      .unwrap_or(false);
+    file_match || directory_match
+  })
+}
+
+#[cfg(test)]
+#[path = "unit_tests/code_owners_test.rs"]
+mod code_owners_test;
@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashMap;
+
+use log::info;
+use tree_sitter::{Parser, Point, Range};
+
+use super::{
+  dynamic_flag_names::STALE_FLAG_NAME, edit::Edit, language::SupportedLanguage, matches::Match,
+  source_code_unit::SourceCodeUnit,
+};
+
+/// The confidence levels of the rules, in increasing order
+pub(crate) static CONFIDENCE_LEVELS: [&str; 3] = ["low", "medium", "high"];
+/// The name of the (pseudo) rule reported for the comments added instead of the edits below the `confidence_threshold`
+pub(crate) static VERIFY_LOW_CONFIDENCE_EDIT: &str = "verify_low_confidence_edit";
+/// The marker of the comments added instead of the edits below the `confidence_threshold`
+static VERIFY_MARKER: &str = "PIRANHA: verify removal of";
+
+/// Returns the rank of the confidence level (see `CONFIDENCE_LEVELS`)
+pub(crate) fn get_confidence_rank(confidence: &str) -> Result<usize, String> {
+  CONFIDENCE_LEVELS
+    .iter()
+    .position(|c| *c == confidence)
+    .ok_or(format!(
+      "The confidence `{confidence}` is not supported (supported: {CONFIDENCE_LEVELS:?})"
+    ))
+}
+
+// Implements instance methods related to the confidence of the rules (i.e. `confidence_threshold`)
+impl SourceCodeUnit {
+  /// Checks if the confidence of the rule (named `rule_name`) is below the `confidence_threshold`,
+  /// i.e. its edits should be verified manually instead of being applied.
+  /// The pseudo rules (e.g. the deletion of the tests) are not part of the rule graph, and are always applied.
+  pub(crate) fn is_low_confidence(&self, rule_name: &str) -> bool {
+    let threshold =
+      get_confidence_rank(self.piranha_arguments().confidence_threshold()).unwrap_or(0);
+    self
+      .piranha_arguments()
+      .rule_graph()
+      .get_rule_named(&rule_name.to_string())
+      .and_then(|r| get_confidence_rank(r.confidence()).ok())
+      .map(|rank| rank < threshold)
+      .unwrap_or(false)
+  }
+
+  /// Checks if the match is already annotated with the comment asking to verify the removal,
+  /// i.e. among the comments on the lines right above it (the annotation precedes the comments associated with the match).
+  pub(crate) fn is_annotated_for_verification(&self, p_match: &Match) -> bool {
+    let row = p_match.range().start_point.row;
+    self
+      .code()
+      .lines()
+      .take(row)
+      .collect::<Vec<_>>()
+      .into_iter()
+      .rev()
+      .map(|l| l.trim_start())
+      .take_while(|l| l.starts_with("//") || l.starts_with('#'))
+      .any(|l| l.contains(VERIFY_MARKER))
+  }
+
+  /// Annotates the line of the edit with a comment asking to verify the removal of the stale flag
+  /// (e.g. `// PIRANHA: verify removal of stale_flag`), instead of applying it.
+  pub(crate) fn annotate_for_verification(&mut self, edit: &Edit, parser: &mut Parser) {
+    let range = edit.p_match().range();
+    let line_start = self.code()[..range.start_byte]
+      .rfind('\n')
+      .map(|i| i + 1)
+      .unwrap_or(0);
+    let indentation: String = self.code()[line_start..]
+      .chars()
+      .take_while(|c| *c == ' ' || *c == '\t')
+      .collect();
+    let comment_prefix = match self.piranha_arguments().language().supported_language() {
+      SupportedLanguage::Python => "#",
+      _ => "//",
+    };
+    let flag_name = self
+      .piranha_arguments()
+      .input_substitutions()
+      .get(STALE_FLAG_NAME)
+      .cloned()
+      .unwrap_or_else(|| "the stale flag".to_string());
+    let position = Point::new(range.start_point.row, 0);
+    let annotation = Edit::new(
+      Match::new(
+        String::new(),
+        Range {
+          start_byte: line_start,
+          end_byte: line_start,
+          start_point: position,
+          end_point: position,
+        },
+        HashMap::new(),
+      ),
+      format!("{indentation}{comment_prefix} {VERIFY_MARKER} {flag_name}\n"),
+      VERIFY_LOW_CONFIDENCE_EDIT.to_string(),
+      self.code(),
+    );
+    info!(
+      "{:?}: the confidence of {} is below the threshold, annotated line {} instead of rewriting - {}",
+      self.path(),
+      edit.matched_rule(),
+      range.start_point.row + 1,
+      edit.p_match().matched_string()
+    );
+    self.rewrites_mut().push(annotation.clone());
+    self.apply_edit(&annotation, parser);
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/confidence_test.rs"]
+mod confidence_test;
@@ -31,6 +31,11 @@ pub const TSX: &str = "tsx";
 pub const THRIFT: &str = "thrift";
 pub const STRINGS: &str = "strings";
 
+pub const LAUNCHDARKLY: &str = "launchdarkly";
+pub const OPENFEATURE: &str = "openfeature";
+pub const UNLEASH: &str = "unleash";
+pub const FLAGSMITH: &str = "flagsmith";
+
 #[cfg(test)]
 //FIXME: Remove this  hack by not passing PiranhaArguments to SourceCodeUnit
 pub(crate) const UNUSED_CODE_PATH: &str = "/dev/null";
@@ -179,6 +184,245 @@ pub(crate) fn default_is_seed_rule() -> bool {
   true
 }
 
+pub(crate) fn default_confidence() -> String {
+  "high".to_string()
+}
+
 pub(crate) fn default_allow_dirty_ast() -> bool {
   false
 }
+
+pub(crate) fn default_remove_unused_imports() -> bool {
+  false
+}
+
+pub(crate) fn default_aggressive_dead_code() -> bool {
+  false
+}
+
+pub(crate) fn default_specialize_boolean_parameters() -> bool {
+  false
+}
+
+pub(crate) fn default_include_generated() -> bool {
+  false
+}
+
+pub(crate) fn default_follow_symlinks() -> bool {
+  false
+}
+
+pub(crate) fn default_flag_definition_files() -> Vec<String> {
+  Vec::new()
+}
+
+pub(crate) fn default_template_files() -> Vec<String> {
+  Vec::new()
+}
+
+pub(crate) fn default_rule_packs() -> Vec<String> {
+  Vec::new()
+}
+
+pub(crate) fn default_post_processing_hook() -> String {
+  String::new()
+}
+
+pub(crate) fn default_flags() -> Vec<String> {
+  Vec::new()
+}
+
+pub(crate) fn default_flag_file() -> String {
+  String::new()
+}
+
+pub(crate) fn default_substitute_only() -> bool {
+  false
+}
+
+pub(crate) fn default_since() -> String {
+  String::new()
+}
+
+pub(crate) fn default_report() -> String {
+  String::new()
+}
+
+pub(crate) fn default_path_to_report() -> Option<String> {
+  None
+}
+
+pub(crate) fn default_interactive() -> bool {
+  false
+}
+
+pub(crate) fn default_confidence_threshold() -> String {
+  "low".to_string()
+}
+
+pub(crate) fn default_check() -> bool {
+  false
+}
+
+pub(crate) fn default_log_level() -> String {
+  String::new()
+}
+
+pub(crate) fn default_log_format() -> String {
+  "text".to_string()
+}
+
+pub(crate) fn default_explain() -> String {
+  String::new()
+}
+
+/// The number of CPUs available
+pub(crate) fn default_jobs() -> usize {
+  std::thread::available_parallelism()
+    .map(|n| n.get())
+    .unwrap_or(1)
+}
+
+pub(crate) fn default_cache_dir() -> String {
+  String::new()
+}
+
+pub(crate) fn default_package_loader() -> String {
+  "directory".to_string()
+}
+
+pub(crate) fn default_max_iterations() -> usize {
+  5
+}
+
+pub(crate) fn default_verify_build() -> String {
+  "none".to_string()
+}
+
+pub(crate) fn default_verify_tests() -> String {
+  "none".to_string()
+}
+
+pub(crate) fn default_max_changed_files() -> usize {
+  0
+}
+
+pub(crate) fn default_max_deleted_lines() -> usize {
+  0
+}
+
+pub(crate) fn default_blast_radius_action() -> String {
+  "abort".to_string()
+}
+
+pub(crate) fn default_backup() -> bool {
+  false
+}
+
+pub(crate) fn default_backup_dir() -> String {
+  String::new()
+}
+
+pub(crate) fn default_serve() -> bool {
+  false
+}
+
+pub(crate) fn default_lsp() -> bool {
+  false
+}
+
+pub(crate) fn default_hook() -> bool {
+  false
+}
+
+pub(crate) fn default_pull_request() -> bool {
+  false
+}
+
+pub(crate) fn default_pull_request_base() -> String {
+  String::new()
+}
+
+pub(crate) fn default_branch_template() -> String {
+  "piranha/{flag}".to_string()
+}
+
+pub(crate) fn default_commit_message_template() -> String {
+  "Clean up the stale flag {flag}".to_string()
+}
+
+pub(crate) fn default_scm() -> String {
+  "github".to_string()
+}
+
+pub(crate) fn default_scm_url() -> String {
+  String::new()
+}
+
+pub(crate) fn default_split_by() -> String {
+  String::new()
+}
+
+pub(crate) fn default_path_to_patches() -> Option<String> {
+  None
+}
+
+pub(crate) fn default_path_to_journal() -> Option<String> {
+  None
+}
+
+pub(crate) fn default_revert() -> bool {
+  false
+}
+
+pub(crate) fn default_archive() -> String {
+  String::new()
+}
+
+pub(crate) fn default_archive_url() -> String {
+  String::new()
+}
+
+pub(crate) fn default_archive_project() -> String {
+  "default".to_string()
+}
+
+pub(crate) fn default_scan() -> bool {
+  false
+}
+
+pub(crate) fn default_stale_after() -> String {
+  "180d".to_string()
+}
+
+pub(crate) fn default_inventory() -> bool {
+  false
+}
+
+pub(crate) fn default_notify_url() -> String {
+  String::new()
+}
+
+pub(crate) fn default_notify_format() -> String {
+  "json".to_string()
+}
+
+pub(crate) fn default_watch() -> bool {
+  false
+}
+
+pub(crate) fn default_diff_style() -> String {
+  "unified".to_string()
+}
+
+pub(crate) fn default_color() -> String {
+  "auto".to_string()
+}
+
+pub(crate) fn default_http_address() -> String {
+  String::new()
+}
+
+pub(crate) fn default_http_root() -> String {
+  String::new()
+}
@@ -0,0 +1,253 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  env,
+  fs::File,
+  io::{stdout, IsTerminal},
+  process::Command,
+};
+
+use colored::Colorize;
+use similar::{DiffTag, TextDiff};
+
+use super::piranha_output::PiranhaOutputSummary;
+
+/// The styles of the diff printed in dry-run (i.e. `diff_style`)
+pub(crate) static DIFF_STYLES: [&str; 2] = ["unified", "side_by_side"];
+/// Prints the original and the rewritten code in two columns
+static SIDE_BY_SIDE: &str = "side_by_side";
+/// The modes of the coloring of the diff (i.e. `color`)
+pub(crate) static COLORS: [&str; 3] = ["auto", "always", "never"];
+/// The width of the diff when the width of the terminal is unknown
+static DEFAULT_WIDTH: usize = 80;
+/// The minimum width of each column of the side-by-side diff
+static MIN_COLUMN_WIDTH: usize = 20;
+/// The number of lines of context printed around each hunk
+static CONTEXT_LINES: usize = 3;
+/// Separates the columns of the side-by-side diff
+static SEPARATOR: &str = " │ ";
+/// The number of columns a tab is expanded to
+static TAB_WIDTH: usize = 4;
+
+/// Decides whether the diff is colored (i.e. `always`, `never`, or `auto` if stdout is a terminal and `NO_COLOR` is not set),
+/// and enables (or disables) the colors of the output of the CLI accordingly (e.g. also of the interactive review).
+pub fn init_color(color: &str) -> bool {
+  let no_color = env::var("NO_COLOR").map(|v| !v.is_empty()).unwrap_or(false);
+  let enabled = is_color_enabled(color, no_color, stdout().is_terminal());
+  colored::control::set_override(enabled);
+  enabled
+}
+
+/// Returns the width of the terminal, i.e. the `COLUMNS` variable, or the size reported by `stty` for the controlling terminal,
+/// or 80 if neither is available (e.g. in CI).
+pub fn get_terminal_width() -> usize {
+  if let Some(width) = env::var("COLUMNS")
+    .ok()
+    .and_then(|c| c.trim().parse::<usize>().ok())
+    .filter(|w| *w > 0)
+  {
+    return width;
+  }
+  File::open("/dev/tty")
+    .ok()
+    .and_then(|tty| Command::new("stty").arg("size").stdin(tty).output().ok())
+    .filter(|output| output.status.success())
+    .and_then(|output| {
+      String::from_utf8_lossy(&output.stdout)
+        .split_whitespace()
+        .nth(1)
+        .and_then(|c| c.parse::<usize>().ok())
+    })
+    .filter(|w| *w > 0)
+    .unwrap_or(DEFAULT_WIDTH)
+}
+
+/// Renders the diff of the rewritten file for reviewing it in the terminal, i.e. as a unified diff (still applicable with
+/// `git apply` when not colored) or as two columns (the original and the rewritten code) fitting in `width`.
+/// The deleted lines are colored in red and the inserted ones in green if `color` is set (see `init_color`).
+pub fn render_diff_preview(
+  summary: &PiranhaOutputSummary, diff_style: &str, color: bool, width: usize,
+) -> String {
+  if diff_style == SIDE_BY_SIDE {
+    let path = summary
+      .path()
+      .trim_start_matches("./")
+      .trim_start_matches('/');
+    return render_side_by_side(
+      path,
+      summary.original_content(),
+      summary.content(),
+      color,
+      width,
+    );
+  }
+  let diff = summary.unified_diff();
+  if color {
+    colorize_unified_diff(&diff)
+  } else {
+    diff
+  }
+}
+
+/// Colors the lines of the unified diff, i.e. the headers in bold, the hunk headers in cyan, the deleted lines in red and the inserted ones in green
+fn colorize_unified_diff(diff: &str) -> String {
+  diff
+    .lines()
+    .map(|line| {
+      let line = if line.starts_with("---") || line.starts_with("+++") {
+        line.bold().to_string()
+      } else if line.starts_with("@@") {
+        line.cyan().to_string()
+      } else if line.starts_with('-') {
+        line.red().to_string()
+      } else if line.starts_with('+') {
+        line.green().to_string()
+      } else {
+        line.to_string()
+      };
+      format!("{line}\n")
+    })
+    .collect()
+}
+
+/// A hunk of the side-by-side diff, i.e. its header (as in the unified diff) and its rows
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub(crate) struct SideBySideHunk {
+  pub(crate) header: String,
+  pub(crate) rows: Vec<SideBySideRow>,
+}
+
+/// A row of the side-by-side diff, i.e. the (1-based) number and the text of the line of the original code and of the
+/// corresponding line of the rewritten code. A deleted line has no counterpart on the right, an inserted one none on the left.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub(crate) struct SideBySideRow {
+  pub(crate) left: Option<(usize, String)>,
+  pub(crate) right: Option<(usize, String)>,
+  // Whether the row is deleted, inserted or replaced (i.e. not a line of context)
+  pub(crate) changed: bool,
+}
+
+/// Returns the hunks of the (line) diff of the original content and the content, along with their context,
+/// with the lines of each hunk paired in rows (e.g. for the side-by-side diff, or the before/after views of the HTML report).
+pub(crate) fn get_side_by_side_hunks(original_content: &str, content: &str) -> Vec<SideBySideHunk> {
+  let diff = TextDiff::from_lines(original_content, content);
+  let (old_lines, new_lines) = (diff.old_slices(), diff.new_slices());
+  let line = |lines: &[&str], index: usize| {
+    (
+      index + 1,
+      lines[index].trim_end_matches(['\n', '\r']).to_string(),
+    )
+  };
+  let mut hunks = vec![];
+  for group in diff.grouped_ops(CONTEXT_LINES) {
+    let (first, last) = match (group.first(), group.last()) {
+      (Some(f), Some(l)) => (f, l),
+      _ => continue,
+    };
+    let (old_start, new_start) = (first.old_range().start, first.new_range().start);
+    let (old_end, new_end) = (last.old_range().end, last.new_range().end);
+    let header = format!(
+      "@@ -{},{} +{},{} @@",
+      old_start + 1,
+      old_end - old_start,
+      new_start + 1,
+      new_end - new_start
+    );
+    let mut rows = vec![];
+    for op in &group {
+      let (old_range, new_range) = (op.old_range(), op.new_range());
+      for row in 0..old_range.len().max(new_range.len()) {
+        rows.push(SideBySideRow {
+          left: old_range.clone().nth(row).map(|l| line(old_lines, l)),
+          right: new_range.clone().nth(row).map(|l| line(new_lines, l)),
+          changed: op.tag() != DiffTag::Equal,
+        });
+      }
+    }
+    hunks.push(SideBySideHunk { header, rows });
+  }
+  hunks
+}
+
+/// Renders the hunks of the diff in two columns, i.e. each line of the original code (prefixed with its number) next to the
+/// corresponding line of the rewritten code (see `SideBySideRow`), the lines wider than the column being truncated.
+pub(crate) fn render_side_by_side(
+  path: &str, original_content: &str, content: &str, color: bool, width: usize,
+) -> String {
+  if original_content == content {
+    return String::new();
+  }
+  let column_width = (width.saturating_sub(SEPARATOR.chars().count()) / 2).max(MIN_COLUMN_WIDTH);
+  let mut text = format!("{}\n", paint(path, color, |l| l.bold().to_string()));
+  for hunk in get_side_by_side_hunks(original_content, content) {
+    text.push_str(&format!(
+      "{}\n",
+      paint(&hunk.header, color, |l| l.cyan().to_string())
+    ));
+    for row in &hunk.rows {
+      let cell = |line: &Option<(usize, String)>| {
+        line
+          .as_ref()
+          .map(|(number, text)| format_cell(*number, text, column_width))
+          .unwrap_or_else(|| " ".repeat(column_width))
+      };
+      let (left, right) = (cell(&row.left), cell(&row.right));
+      if row.changed {
+        text.push_str(&format!(
+          "{}{SEPARATOR}{}\n",
+          paint(&left, color, |l| l.red().to_string()),
+          paint(&right, color, |l| l.green().to_string())
+        ));
+      } else {
+        text.push_str(&format!("{left}{SEPARATOR}{right}\n"));
+      }
+    }
+  }
+  text
+}
+
+/// Formats the line (prefixed with its number) as a cell of `width` characters, i.e. padded or truncated (marked with `…`)
+fn format_cell(number: usize, line: &str, width: usize) -> String {
+  let line = line.replace('\t', &" ".repeat(TAB_WIDTH));
+  let cell = format!("{number:>4} {line}");
+  if cell.chars().count() > width {
+    let mut truncated: String = cell.chars().take(width - 1).collect();
+    truncated.push('…');
+    truncated
+  } else {
+    format!("{cell:<width$}")
+  }
+}
+
+/// Applies the `style` to the text if `color` is set
+fn paint(text: &str, color: bool, style: impl Fn(&str) -> String) -> String {
+  if color {
+    style(text)
+  } else {
+    text.to_string()
+  }
+}
+
+/// Colors are forced (`always`) or disabled (`never`), or enabled only when printing to a terminal without `NO_COLOR` (`auto`)
+pub(crate) fn is_color_enabled(color: &str, no_color: bool, is_terminal: bool) -> bool {
+  match color {
+    "always" => true,
+    "never" => false,
+    _ => is_terminal && !no_color,
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/diff_preview_test.rs"]
+mod diff_preview_test;
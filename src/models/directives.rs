@@ -0,0 +1,87 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use log::debug;
+use regex::Regex;
+
+use super::{language::SupportedLanguage, source_code_unit::SourceCodeUnit};
+
+/// The Go directives anchored to their position in the file rather than to the code following them, i.e. the build
+/// constraints, the `go generate` commands and the line directives. They are never deleted along with the comments of the
+/// deleted code. The other `//go:` directives (e.g. `//go:noinline`, `//go:embed`) belong to the declaration following
+/// them, thus they are deleted along with it.
+static ANCHORED_DIRECTIVES: [&str; 5] = [
+  "//go:build",
+  "// +build",
+  "//go:generate",
+  "//line ",
+  "/*line ",
+];
+/// The build constraints, which are only honored before the package clause (followed by a blank line)
+static BUILD_CONSTRAINTS: [&str; 2] = ["//go:build", "// +build"];
+
+// Implements instance methods related to preserving the Go directives (e.g. `//go:build`)
+impl SourceCodeUnit {
+  /// Restores the header of this file (i.e. the comments above its package clause) if the rewrites changed the header
+  /// of a file with build constraints (see `restore_build_constraints`), e.g. by deleting the blank line after them.
+  pub(crate) fn preserve_build_constraints(&mut self) {
+    if *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go
+      || self.code() == self.original_content()
+    {
+      return;
+    }
+    if let Some(code) = restore_build_constraints(self.original_content(), self.code()) {
+      debug!(
+        "Restoring the build constraints of the file {:?}",
+        self.path()
+      );
+      self.set_code(code);
+    }
+  }
+}
+
+/// Checks if the comment is a directive anchored to its position in the file (see `ANCHORED_DIRECTIVES`)
+pub(crate) fn is_anchored_directive(comment: &str) -> bool {
+  ANCHORED_DIRECTIVES.iter().any(|d| comment.starts_with(d))
+}
+
+/// Returns the content with the header of the original content (i.e. everything above the package clause), if the original
+/// header has build constraints and the header of the content differs from it, so that the constraints stay the first
+/// non-comment content of the file. Returns `None` if the header is unchanged, or the content has no package clause
+/// (e.g. the file is emptied).
+pub(crate) fn restore_build_constraints(original_content: &str, content: &str) -> Option<String> {
+  let original_header = &original_content[..find_package_clause(original_content)?];
+  if !original_header
+    .lines()
+    .any(|l| BUILD_CONSTRAINTS.iter().any(|c| l.starts_with(c)))
+  {
+    return None;
+  }
+  let package_clause = find_package_clause(content)?;
+  if &content[..package_clause] == original_header {
+    return None;
+  }
+  Some(format!("{original_header}{}", &content[package_clause..]))
+}
+
+/// Returns the byte offset of the package clause (i.e. the first line starting with `package`)
+fn find_package_clause(content: &str) -> Option<usize> {
+  Regex::new(r"(?m)^package\s")
+    .ok()?
+    .find(content)
+    .map(|m| m.start())
+}
+
+#[cfg(test)]
+#[path = "unit_tests/directives_test.rs"]
+mod directives_test;
@@ -0,0 +1,229 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashMap;
+
+use log::warn;
+use regex::Regex;
+use tree_sitter::Node;
+
+use super::{matches::Match, source_code_unit::SourceCodeUnit, specialization::collect_nodes};
+
+/// The name of the (pseudo) rule reported for the potential dynamic references to the stale flag
+pub(crate) static DYNAMIC_FLAG_NAME: &str = "dynamic_flag_name";
+/// The substitution containing the name of the stale flag
+pub(crate) static STALE_FLAG_NAME: &str = "stale_flag_name";
+/// The least number of characters a fragment should have to be considered as a part of the flag name
+static MIN_FRAGMENT_LENGTH: usize = 3;
+
+/// The string literals and the identifiers a string is built with
+#[derive(Debug, Default)]
+struct Fragments {
+  literals: Vec<String>,
+  identifiers: Vec<String>,
+  // Whether a part of the string is only known at runtime
+  dynamic: bool,
+}
+
+// Implements instance methods related to detecting the flag names built at runtime
+impl SourceCodeUnit {
+  /// Reports the strings built at runtime (i.e. with `fmt.Sprintf` or concatenations) that may denote `flag_name`,
+  /// e.g. `fmt.Sprintf("stale_%s", suffix)` or `"stale_" + suffix` for the flag `stale_checkout`.
+  /// Such references are not matched by the rules, hence they need a manual follow-up.
+  /// A string is reported when all its literal fragments are substrings of the flag name,
+  /// and at least one of its fragments, or a word of its identifiers (e.g. `checkout` in `checkoutSuffix`), is a part of the flag name.
+  pub(crate) fn report_dynamic_flag_names(&mut self, flag_name: &str) {
+    let candidates: Vec<Match> = collect_nodes(self.root_node(), |n| {
+      self
+        .get_fragments(*n)
+        .map(|f| resembles_flag_name(&f, flag_name))
+        .unwrap_or(false)
+    })
+    .iter()
+    .map(|n| {
+      Match::new(
+        n.utf8_text(self.code().as_bytes())
+          .unwrap_or_default()
+          .to_string(),
+        n.range(),
+        HashMap::new(),
+      )
+    })
+    .collect();
+    for candidate in candidates {
+      warn!(
+        "{:?}: `{}` may build the name of the stale flag `{flag_name}` at runtime. It needs to be cleaned up manually.",
+        self.path(),
+        candidate.matched_string()
+      );
+      self
+        .matches_mut()
+        .push((DYNAMIC_FLAG_NAME.to_string(), candidate));
+    }
+  }
+
+  /// Returns the fragments of the string built by `node`, if it is a call to `Sprintf` with a literal format,
+  /// or the outermost concatenation of a string literal.
+  fn get_fragments(&self, node: Node) -> Option<Fragments> {
+    match node.kind() {
+      "call_expression" => {
+        let function = node.child_by_field_name("function")?;
+        let function_name = match function.kind() {
+          "selector_expression" => function.child_by_field_name("field")?,
+          _ => function,
+        };
+        if self.text_of(Some(function_name)) != "Sprintf" {
+          return None;
+        }
+        let arguments = node.child_by_field_name("arguments")?;
+        let mut cursor = arguments.walk();
+        let mut arguments = arguments.named_children(&mut cursor);
+        let format = self.string_content(arguments.next()?)?;
+        let verb = Regex::new(r"%[-+# 0-9.*\[\]]*[a-zA-Z]").unwrap();
+        let mut fragments = Fragments {
+          literals: verb
+            .split(&format)
+            .filter(|f| !f.is_empty())
+            .map(str::to_string)
+            .collect(),
+          dynamic: verb.is_match(&format),
+          ..Default::default()
+        };
+        for argument in arguments {
+          self.collect_operand(argument, &mut fragments);
+        }
+        Some(fragments)
+      }
+      "binary_expression" => {
+        if !self.is_concatenation(node)
+          || node.parent().map(|p| self.is_concatenation(p)) == Some(true)
+        {
+          return None;
+        }
+        let mut fragments = Fragments::default();
+        self.collect_operand(node, &mut fragments);
+        if fragments.literals.is_empty() {
+          return None;
+        }
+        Some(fragments)
+      }
+      _ => None,
+    }
+  }
+
+  /// Collects the fragments of an operand of a concatenation (or an argument of `Sprintf`)
+  fn collect_operand(&self, operand: Node, fragments: &mut Fragments) {
+    if self.is_concatenation(operand) {
+      for field in ["left", "right"] {
+        if let Some(o) = operand.child_by_field_name(field) {
+          self.collect_operand(o, fragments);
+        }
+      }
+      return;
+    }
+    if let Some(content) = self.string_content(operand) {
+      fragments.literals.push(content);
+      return;
+    }
+    fragments.dynamic = true;
+    match operand.kind() {
+      "identifier" => fragments.identifiers.push(self.text_of(Some(operand))),
+      "selector_expression" => {
+        if let Some(field) = operand.child_by_field_name("field") {
+          fragments.identifiers.push(self.text_of(Some(field)));
+        }
+      }
+      _ => {}
+    }
+  }
+
+  /// Checks if `node` is a `+` binary expression
+  fn is_concatenation(&self, node: Node) -> bool {
+    node.kind() == "binary_expression"
+      && node
+        .child_by_field_name("operator")
+        .map(|o| self.text_of(Some(o)) == "+")
+        .unwrap_or(false)
+  }
+
+  /// Returns the content of a string literal (i.e. without the quotes)
+  fn string_content(&self, node: Node) -> Option<String> {
+    match node.kind() {
+      "interpreted_string_literal" | "raw_string_literal" => {
+        let text = self.text_of(Some(node));
+        Some(text[1..text.len() - 1].to_string())
+      }
+      _ => None,
+    }
+  }
+}
+
+/// Checks if the `content` of a file may build `flag_name` at runtime (see `resembles_flag_name`), i.e. it calls `Sprintf`
+/// or concatenates strings, and contains a fragment of `MIN_FRAGMENT_LENGTH` characters of the flag name (regardless of its case).
+/// It is a cheap textual check, so that only the candidate files of the code base are parsed.
+pub(crate) fn may_build_flag_name(content: &str, flag_name: &str) -> bool {
+  if !content.contains("Sprintf") && !content.contains('+') {
+    return false;
+  }
+  let content = content.to_lowercase();
+  let flag_name: Vec<char> = flag_name.to_lowercase().chars().collect();
+  flag_name
+    .windows(MIN_FRAGMENT_LENGTH)
+    .any(|w| content.contains(&w.iter().collect::<String>()))
+}
+
+/// Checks if the fragments may build `flag_name`
+fn resembles_flag_name(fragments: &Fragments, flag_name: &str) -> bool {
+  let flag_words = split_words(flag_name);
+  fragments.dynamic
+    && fragments
+      .literals
+      .iter()
+      .all(|l| flag_name.contains(l.as_str()))
+    && (fragments
+      .literals
+      .iter()
+      .any(|l| l.len() >= MIN_FRAGMENT_LENGTH)
+      || fragments.identifiers.iter().any(|i| {
+        split_words(i)
+          .iter()
+          .any(|w| w.len() >= MIN_FRAGMENT_LENGTH && flag_words.contains(w))
+      }))
+}
+
+/// Splits a (snake, camel or pascal case) name into lower case words (e.g. `staleCheckout` and `STALE_CHECKOUT` become `[stale, checkout]`)
+fn split_words(name: &str) -> Vec<String> {
+  let mut words = vec![];
+  let mut current = String::new();
+  let mut previous_is_lowercase = false;
+  for c in name.chars() {
+    if !c.is_alphanumeric() || (c.is_uppercase() && previous_is_lowercase) {
+      if !current.is_empty() {
+        words.push(current.to_lowercase());
+      }
+      current = String::new();
+    }
+    if c.is_alphanumeric() {
+      current.push(c);
+    }
+    previous_is_lowercase = c.is_lowercase() || c.is_ascii_digit();
+  }
+  if !current.is_empty() {
+    words.push(current.to_lowercase());
+  }
+  words
+}
+
+#[cfg(test)]
+#[path = "unit_tests/dynamic_flag_names_test.rs"]
+mod dynamic_flag_names_test;
@@ -20,7 +20,8 @@ use serde_derive::{Deserialize, Serialize};
 use tree_sitter::{Node, Range};
 
 use super::{
-  matches::Match, rule::InstantiatedRule, rule_store::RuleStore, source_code_unit::SourceCodeUnit,
+  language::SupportedLanguage, matches::Match, rule::InstantiatedRule, rule_store::RuleStore,
+  source_code_unit::SourceCodeUnit,
 };
 use crate::utilities::{
   gen_py_str_methods,
@@ -80,6 +81,13 @@ impl Edit {
   pub(crate) fn is_delete(&self) -> bool {
     self.replacement_string.trim().is_empty()
   }
+
+  /// Checks if the matched code is replaced by a part of itself (e.g. `if true { ... }` by its consequence)
+  pub(crate) fn is_unwrap(&self) -> bool {
+    let replacement = self.replacement_string.trim();
+    let matched_string = self.p_match.matched_string().trim();
+    !replacement.is_empty() && replacement != matched_string && matched_string.contains(replacement)
+  }
 }
 
 impl fmt::Display for Edit {
@@ -146,14 +154,41 @@ impl SourceCodeUnit {
       .first()
       .map(|p_match| {
         let replacement_string = rule.replace().instantiate(p_match.matches());
-        let edit = Edit::new(
+        let mut edit = Edit::new(
           p_match.clone(),
           replacement_string,
           rule.name(),
           self.code(),
         );
+        // The comments associated with a statement replaced by a part of itself (e.g. `// TODO: remove after the
+        // experiment ships` above `if true { ... }`) would be left dangling, thus they are deleted along with it.
+        if edit.is_unwrap()
+          && self.spans_entire_lines(edit.p_match().range())
+          && *self.piranha_arguments().language().supported_language() == SupportedLanguage::Go
+        {
+          edit
+            .p_match_mut()
+            .expand_to_associated_comments(self.code());
+        }
         trace!("Rewrite found : {:#?}", edit);
         edit
       });
   }
+
+  /// Checks if the code in `range` occupies entire lines (i.e. a statement),
+  /// that is, it is only preceded by whitespace and followed by whitespace or a comment on its first and last lines.
+  fn spans_entire_lines(&self, range: Range) -> bool {
+    let code = self.code();
+    let line_start = code[..range.start_byte]
+      .rfind('\n')
+      .map(|i| i + 1)
+      .unwrap_or(0);
+    let line_end = code[range.end_byte..]
+      .find('\n')
+      .map(|i| range.end_byte + i)
+      .unwrap_or(code.len());
+    let rest = code[range.end_byte..line_end].trim();
+    code[line_start..range.start_byte].trim().is_empty()
+      && (rest.is_empty() || rest.starts_with("//") || rest.starts_with("/*"))
+  }
 }
@@ -0,0 +1,94 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use log::debug;
+use similar::{DiffTag, TextDiff};
+
+use super::source_code_unit::SourceCodeUnit;
+
+/// The byte order mark (BOM) of UTF-8
+static BOM: &str = "\u{feff}";
+static CRLF: &str = "\r\n";
+static LF: &str = "\n";
+
+// Implements instance methods related to preserving the encoding details of the files (i.e. their BOM and line endings)
+impl SourceCodeUnit {
+  /// Restores the BOM and the line endings of the original content of this file in its rewritten code (see `restore_encoding`),
+  /// e.g. when the replacements of the rules (or the post-processing hook) use LF in a file with CRLF line endings.
+  pub(crate) fn preserve_encoding(&mut self) {
+    if self.code() == self.original_content() {
+      return;
+    }
+    let code = restore_encoding(self.original_content(), self.code());
+    if &code != self.code() {
+      debug!(
+        "Restoring the BOM and the line endings of the file {:?}",
+        self.path()
+      );
+      self.set_code(code);
+    }
+  }
+}
+
+/// Returns the content with the BOM (if any) and the line endings (i.e. CRLF or LF, whichever most lines of the original
+/// content end with) of the original content. Only the lines that differ from the original content (i.e. the edited ones)
+/// are changed, so that the lines left untouched keep their own line endings (e.g. in a file with mixed line endings).
+/// A content left with the BOM only is empty (e.g. so that the file is deleted, see `delete_file_if_empty`).
+pub(crate) fn restore_encoding(original_content: &str, content: &str) -> String {
+  let bom = original_content.starts_with(BOM) || content.starts_with(BOM);
+  let (original_content, content) = (
+    original_content
+      .strip_prefix(BOM)
+      .unwrap_or(original_content),
+    content.strip_prefix(BOM).unwrap_or(content),
+  );
+  if content.is_empty() {
+    return String::new();
+  }
+  let line_ending = get_line_ending(original_content);
+  let diff = TextDiff::from_lines(original_content, content);
+  let new_lines = diff.new_slices();
+  let mut restored = if bom { BOM.to_string() } else { String::new() };
+  for op in diff.ops() {
+    for line in &new_lines[op.new_range()] {
+      if op.tag() == DiffTag::Equal {
+        restored.push_str(line);
+      } else {
+        restored.push_str(&with_line_ending(line, line_ending));
+      }
+    }
+  }
+  restored
+}
+
+/// Returns the line ending of most lines of the content, i.e. CRLF or LF (also for a content without line endings)
+fn get_line_ending(content: &str) -> &'static str {
+  let crlf = content.matches(CRLF).count();
+  if crlf > 0 && crlf * 2 > content.matches(LF).count() {
+    CRLF
+  } else {
+    LF
+  }
+}
+
+/// Replaces the line ending of the line (if any, i.e. not the last line of a file without a trailing line ending)
+fn with_line_ending(line: &str, line_ending: &str) -> String {
+  match line.strip_suffix(LF) {
+    Some(line) => format!("{}{line_ending}", line.trim_end_matches('\r')),
+    None => line.to_string(),
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/encoding_test.rs"]
+mod encoding_test;
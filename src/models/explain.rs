@@ -0,0 +1,212 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, fmt, path::Path};
+
+use getset::Getters;
+use itertools::Itertools;
+use serde_derive::Serialize;
+
+use super::{
+  piranha_arguments::PiranhaArguments, rule::InstantiatedRule, rule_store::RuleStore,
+  sarif::map_to_original, source_code_unit::SourceCodeUnit,
+};
+use crate::utilities::tree_sitter_utilities::{get_all_matches_for_query, get_node_for_range};
+
+/// The outcomes of a rule at the explained position (see `RuleExplanation`)
+pub(crate) static APPLIED: &str = "applied";
+pub(crate) static REJECTED: &str = "rejected";
+pub(crate) static NOT_MATCHED: &str = "not matched";
+pub(crate) static NOT_SCANNED: &str = "not scanned";
+
+/// The outcome of a rule at the position explained by `explain` (i.e. `path:line`), along with its reason
+#[derive(Serialize, Debug, Clone, PartialEq, Eq, Hash, Getters)]
+pub struct RuleExplanation {
+  // The rule attempted at the position (or the flag, for the outcomes of the whole file)
+  #[get = "pub"]
+  rule: String,
+  // The outcome of the rule, i.e. `applied`, `rejected`, `not matched` or `not scanned`
+  #[get = "pub"]
+  outcome: String,
+  // Why the rule did (or did not) apply
+  #[get = "pub"]
+  reason: String,
+}
+
+impl RuleExplanation {
+  fn new(rule: &str, outcome: &str, reason: &str) -> RuleExplanation {
+    RuleExplanation {
+      rule: rule.to_string(),
+      outcome: outcome.to_string(),
+      reason: reason.to_string(),
+    }
+  }
+}
+
+impl fmt::Display for RuleExplanation {
+  fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+    write!(f, "{} ({}) : {}", self.rule, self.outcome, self.reason)
+  }
+}
+
+/// Parses the position to explain (i.e. `explain`), i.e. `path:line` with a 1-based line
+pub(crate) fn parse_explain_position(position: &str) -> Result<(String, usize), String> {
+  position
+    .rsplit_once(':')
+    .and_then(|(path, line)| {
+      line
+        .parse::<usize>()
+        .ok()
+        .filter(|l| *l > 0 && !path.is_empty())
+        .map(|l| (path.to_string(), l))
+    })
+    .ok_or(format!(
+      "The position `{position}` is not of the form `path:line`"
+    ))
+}
+
+/// Checks if the two paths refer to the same file (e.g. `./src/a.go` and `src/a.go`)
+pub(crate) fn is_same_file(path: &Path, other: &Path) -> bool {
+  match (path.canonicalize(), other.canonicalize()) {
+    (Ok(p), Ok(o)) => p == o,
+    _ => path == other,
+  }
+}
+
+// Implements instance methods related to explaining why the code on a line was (or was not) cleaned up
+impl SourceCodeUnit {
+  /// Explains the outcome of the rules attempted on the (1-based) `line` of the original content of the file:
+  /// * the rewrites applied on the line (by the run, i.e. `rewrites`),
+  /// * the rules whose query matches the code on the line, along with the reason their match is rejected
+  ///   (e.g. a filter is not satisfied, a `piranha:ignore` directive, a shadowed identifier),
+  /// * the seed rules (i.e. the flag APIs) whose query does not match the code on the line.
+  ///
+  /// The rules with holes that are not bound by the substitutions are skipped, since they are only
+  /// triggered by the match of a parent rule (their rewrites are reported as applied though).
+  pub(crate) fn explain(&self, line: usize, rule_store: &mut RuleStore) -> Vec<RuleExplanation> {
+    let mut explanations = vec![];
+    for (index, edit) in self.rewrites().iter().enumerate() {
+      let (start, _) = map_to_original(self.rewrites(), index, edit.p_match());
+      if get_line(self.original_content(), start) == line {
+        explanations.push(RuleExplanation::new(
+          edit.matched_rule(),
+          APPLIED,
+          &format!(
+            "`{}` is replaced with `{}`",
+            first_line(edit.p_match().matched_string()),
+            first_line(edit.replacement_string())
+          ),
+        ));
+      }
+    }
+
+    // The rules are attempted on the original content, as it was before the run
+    let mut parser = self.piranha_arguments().language().parser();
+    let original = SourceCodeUnit::new(
+      &mut parser,
+      self.original_content().to_string(),
+      &HashMap::new(),
+      self.path(),
+      self.piranha_arguments(),
+    );
+    let substitutions = self.piranha_arguments().input_substitutions();
+    for rule in self.piranha_arguments().rule_graph().rules() {
+      if rule.is_dummy_rule() || rule.holes().iter().any(|h| !substitutions.contains_key(h)) {
+        continue;
+      }
+      let instantiated_rule = InstantiatedRule::new(rule, &substitutions);
+      let replace_node = if rule.is_match_only_rule() {
+        None
+      } else {
+        Some(instantiated_rule.replace_node())
+      };
+      let matches_on_line = get_all_matches_for_query(
+        &original.root_node(),
+        original.code().to_string(),
+        rule_store.query(&original.resolve_package_aliases(&instantiated_rule)),
+        true,
+        replace_node,
+      )
+      .into_iter()
+      .filter(|m| m.range().start_point.row + 1 == line)
+      .collect_vec();
+      if matches_on_line.is_empty() {
+        if *rule.is_seed_rule() {
+          explanations.push(RuleExplanation::new(
+            rule.name(),
+            NOT_MATCHED,
+            "the query does not match the code on this line (e.g. a different API, arity of the arguments, or flag name)",
+          ));
+        }
+        continue;
+      }
+      let is_low_confidence = original.is_low_confidence(rule.name());
+      for p_match in matches_on_line {
+        let matched_node = get_node_for_range(
+          original.root_node(),
+          p_match.range().start_byte,
+          p_match.range().end_byte,
+        );
+        let reason = original
+          .get_rejection_reason(matched_node, &instantiated_rule, &p_match, rule_store)
+          .or_else(|| original.get_exemption_reason(&p_match, is_low_confidence));
+        if let Some(reason) = reason {
+          explanations.push(RuleExplanation::new(rule.name(), REJECTED, &reason));
+        } else if !explanations
+          .iter()
+          .any(|e| e.rule == *rule.name() && e.outcome == APPLIED)
+        {
+          explanations.push(RuleExplanation::new(
+            rule.name(),
+            NOT_MATCHED,
+            "the query matches, but the match was not reached by the run (e.g. it is covered by the rewrite of another rule)",
+          ));
+        }
+      }
+    }
+    explanations
+  }
+}
+
+/// Returns the explanation of a file that is not scanned, e.g. excluded by the `exclude` patterns
+pub(crate) fn explain_not_scanned(
+  path: &str, piranha_arguments: &PiranhaArguments,
+) -> RuleExplanation {
+  RuleExplanation::new(
+    path,
+    NOT_SCANNED,
+    &format!(
+      "the file is not walked over from `{}` (e.g. it is excluded, ignored by `.piranhaignore`, generated, or without the `{}` extension)",
+      piranha_arguments.path_to_codebase(),
+      piranha_arguments.language().extension()
+    ),
+  )
+}
+
+/// Returns the (1-based) line of the byte offset in the content
+fn get_line(content: &str, offset: usize) -> usize {
+  content
+    .get(..offset.min(content.len()))
+    .unwrap_or(content)
+    .matches('\n')
+    .count()
+    + 1
+}
+
+fn first_line(code: &str) -> &str {
+  code.lines().next().unwrap_or_default().trim()
+}
+
+#[cfg(test)]
+#[path = "unit_tests/explain_test.rs"]
+mod explain_test;
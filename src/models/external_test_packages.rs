@@ -0,0 +1,103 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashSet, path::PathBuf};
+
+use log::debug;
+
+use super::{
+  go_packages::get_package_name, language::SupportedLanguage, rule::InstantiatedRule,
+  source_code_unit::SourceCodeUnit,
+};
+
+// Implements instance methods related to telling apart the packages declared by the files of the same directory,
+// i.e. the package under test `foo` and its external test package `foo_test`
+impl SourceCodeUnit {
+  /// Returns the global rule found in this file, restricted to the files of its package (see `is_restricted_to_another_package`)
+  /// when it references a name declared at the top level of this file (e.g. the function `isEnabled`, or the variable `useNewPath`).
+  /// The external test package `foo_test` is a compilation unit of its own, importing `foo` like any other package,
+  /// thus the (unqualified) names of `foo` do not resolve in its files, and conversely.
+  /// The rules referencing the package by its name (i.e. its qualified references, like `foo.IsEnabled()`) are not restricted,
+  /// since `foo_test` references the public API of `foo` (and the test hooks exported by its `export_test.go`) that way.
+  /// Currently, only supported for Go.
+  pub(crate) fn restrict_to_package(&self, rule: &InstantiatedRule) -> InstantiatedRule {
+    if *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go {
+      return rule.clone();
+    }
+    let package = match self.get_package() {
+      Some(package) => package,
+      None => return rule.clone(),
+    };
+    let values: Vec<&String> = rule.substitutions().values().collect();
+    let top_level_names = self.get_top_level_names();
+    if values.contains(&&package.1) || !values.iter().any(|v| top_level_names.contains(*v)) {
+      return rule.clone();
+    }
+    debug!(
+      "Restricting the global rule {} to the files of the package {} in {}",
+      rule.name(),
+      package.1,
+      package.0.display()
+    );
+    rule.restricted_to(package)
+  }
+
+  /// Checks if the `rule` is restricted to another package declared in the directory of this file,
+  /// e.g. a rule found in `package foo` while this file declares `package foo_test`, or conversely.
+  /// The rules found in the other directories are not restricted.
+  pub(crate) fn is_restricted_to_another_package(&self, rule: &InstantiatedRule) -> bool {
+    match (rule.package(), self.get_package()) {
+      (Some((directory, name)), Some((d, n))) => *directory == d && *name != n,
+      _ => false,
+    }
+  }
+
+  /// Returns the directory of this file, along with the name of its package (i.e. declared by its package clause)
+  fn get_package(&self) -> Option<(PathBuf, String)> {
+    let directory = self.path().parent()?.to_path_buf();
+    get_package_name(self.code()).map(|name| (directory, name))
+  }
+
+  /// Returns the names declared at the top level of this file, i.e. its functions, types, variables and constants (but not its methods)
+  fn get_top_level_names(&self) -> HashSet<String> {
+    let root_node = self.root_node();
+    let mut cursor = root_node.walk();
+    let mut names = HashSet::new();
+    for declaration in root_node.named_children(&mut cursor) {
+      match declaration.kind() {
+        "function_declaration" => {
+          names.insert(self.text_of(declaration.child_by_field_name("name")));
+        }
+        "type_declaration" => {
+          let mut cursor = declaration.walk();
+          names.extend(
+            declaration
+              .named_children(&mut cursor)
+              .filter(|s| ["type_spec", "type_alias"].contains(&s.kind()))
+              .map(|s| self.text_of(s.child_by_field_name("name"))),
+          );
+        }
+        "var_declaration" | "const_declaration" => {
+          names.extend(self.get_declared_names(declaration));
+        }
+        _ => {}
+      }
+    }
+    names.remove("_");
+    names
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/external_test_packages_test.rs"]
+mod external_test_packages_test;
@@ -16,6 +16,7 @@ use std::collections::HashMap;
 use derive_builder::Builder;
 use getset::Getters;
 use itertools::Itertools;
+use log::trace;
 use pyo3::prelude::{pyclass, pymethods};
 
 use serde_derive::Deserialize;
@@ -122,6 +123,41 @@ impl Filter {
   gen_py_str_methods!();
 }
 
+impl Filter {
+  /// Describes the (non-default) conditions of the filter, e.g. `(enclosing_node: (block) @block)`
+  pub(crate) fn describe(&self) -> String {
+    let query = |q: &TSQuery| q.get_query().split_whitespace().join(" ");
+    let mut conditions = vec![];
+    if !self.enclosing_node().get_query().is_empty() {
+      conditions.push(format!("enclosing_node: {}", query(self.enclosing_node())));
+    }
+    if !self.not_enclosing_node().get_query().is_empty() {
+      conditions.push(format!(
+        "not_enclosing_node: {}",
+        query(self.not_enclosing_node())
+      ));
+    }
+    for not_contains in self.not_contains() {
+      conditions.push(format!("not_contains: {}", query(not_contains)));
+    }
+    if !self.contains().get_query().is_empty() {
+      conditions.push(format!(
+        "contains: {} (at least {}, at most {})",
+        query(self.contains()),
+        self.at_least(),
+        self.at_most()
+      ));
+    }
+    if *self.child_count() != default_child_count() {
+      conditions.push(format!("child_count: {}", self.child_count()));
+    }
+    if *self.sibling_count() != default_sibling_count() {
+      conditions.push(format!("sibling_count: {}", self.sibling_count()));
+    }
+    format!("({})", conditions.join(", "))
+  }
+}
+
 impl Validator for Filter {
   fn validate(&self) -> Result<(), String> {
     // Only allow users to set either contains or not_contains, but not both
@@ -283,12 +319,31 @@ impl SourceCodeUnit {
     &self, node: Node, rule: &InstantiatedRule, substitutions: &HashMap<String, String>,
     rule_store: &mut RuleStore,
   ) -> bool {
+    self
+      .get_unsatisfied_filter(node, rule, substitutions, rule_store)
+      .is_none()
+  }
+
+  /// Returns the first filter of the rule that the `node` does not satisfy (if any)
+  pub(crate) fn get_unsatisfied_filter(
+    &self, node: Node, rule: &InstantiatedRule, substitutions: &HashMap<String, String>,
+    rule_store: &mut RuleStore,
+  ) -> Option<Filter> {
     let mut updated_substitutions = self.piranha_arguments().input_substitutions();
     updated_substitutions.extend(substitutions.clone());
-    rule
+    let filter = rule
       .filters()
       .iter()
-      .all(|filter| self._check(filter.clone(), node, rule_store, &updated_substitutions))
+      .find(|filter| !self._check((*filter).clone(), node, rule_store, &updated_substitutions))
+      .cloned();
+    if let Some(f) = &filter {
+      trace!(
+        "The filter of the rule {} is not satisfied : {:?}",
+        rule.name(),
+        f
+      );
+    }
+    filter
   }
 
   /// Determines if the given `node` meets the conditions specified by the `filter`.
@@ -0,0 +1,338 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashSet, path::Path};
+
+use serde_derive::Deserialize;
+
+use super::{
+  imports::get_assumed_package_name,
+  rule::{Rule, RuleBuilder},
+};
+use crate::utilities::{read_toml, tree_sitter_utilities::TSQuery};
+
+/// The name of the file (in `path_to_configurations`) declaring the flag APIs
+static FLAG_APIS_FILE: &str = "flag_apis.toml";
+
+#[derive(Deserialize, Debug, Clone, Default, PartialEq)]
+// Represents the `flag_apis.toml` file
+pub(crate) struct FlagApis {
+  #[serde(default)]
+  pub(crate) flag_apis: Vec<FlagApi>,
+}
+
+/// The type of the value returned by a flag API (along with an error, if any)
+#[derive(Deserialize, Debug, Clone, Default, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub(crate) enum ReturnType {
+  #[default]
+  Bool,
+  String,
+  Int,
+  Float,
+}
+
+/// The value returned by a boolean flag API when the flag is treated
+#[derive(Deserialize, Debug, Clone, Default, PartialEq, Eq)]
+#[serde(rename_all = "lowercase")]
+pub(crate) enum Semantics {
+  // Returns true when the flag is treated (e.g. `IsEnabled("stale_flag")`)
+  #[default]
+  Treated,
+  // Returns true when the flag is in control (e.g. `IsDisabled("stale_flag")`)
+  Control,
+}
+
+/// Captures an entry from the `flag_apis.toml` file, i.e. the shape of an (in-house) API evaluating a flag.
+/// For instance, `exp.BoolValue(ctx, "stale_flag")` is declared as:
+/// ```toml
+/// [[flag_apis]]
+/// package = "github.com/company/exp"
+/// function = "BoolValue"
+/// flag_argument_index = 1
+/// ```
+#[derive(Deserialize, Debug, Clone, Default, PartialEq)]
+pub(crate) struct FlagApi {
+  /// The name of the generated rule (e.g. to define edges from it), `flag_api_<package name>_<function>` by default
+  #[serde(default)]
+  name: String,
+  /// The path of the package declaring the function (e.g. `github.com/company/exp`).
+  /// When empty, the function is matched as a method on any receiver (e.g. `client.BoolValue("stale_flag")`).
+  #[serde(default)]
+  package: String,
+  /// The name of the function (or method) evaluating the flag
+  function: String,
+  /// The index of the argument naming the flag (as a string literal)
+  #[serde(default)]
+  flag_argument_index: usize,
+  /// The field of the struct literal (or of its address) passed as the flag argument, naming the flag
+  /// (e.g. `Flag` in `exp.Bool(exp.Options{Flag: "stale_flag", Default: false})`)
+  #[serde(default)]
+  flag_field: String,
+  /// The function (or method) constructing the option naming the flag, passed as any of the arguments
+  /// (e.g. `WithFlag` in `exp.Bool(ctx, exp.WithFlag("stale_flag"))`). The `flag_argument_index` is then ignored.
+  #[serde(default)]
+  flag_option: String,
+  /// The number of arguments of the calls (any number by default, e.g. for the variadic options)
+  #[serde(default)]
+  argument_count: Option<usize>,
+  #[serde(default)]
+  return_type: ReturnType,
+  #[serde(default)]
+  semantics: Semantics,
+}
+
+impl FlagApis {
+  /// Returns the (seed) rules replacing the calls to the flag APIs, with the literal corresponding to the
+  /// return type (i.e. `@treated`, `@treated_complement`, `"@treatment"`, `@rollout` or `@rollout_fraction`).
+  /// The rules belong to the groups (e.g. `replace_expression_with_boolean_literal`) triggering the built-in cleanups.
+  pub(crate) fn rules(&self) -> Vec<Rule> {
+    self.flag_apis.iter().map(FlagApi::rule).collect()
+  }
+
+  /// Returns the rules folding the boolean expressions that combine a call to a (boolean) flag API with a literal
+  /// (e.g. `exp.BoolValue("live_flag") && false`, left by the cleanup of the stale flag), see `FlagApi::operand_rules`.
+  pub(crate) fn operand_rules(&self) -> Vec<Rule> {
+    self
+      .flag_apis
+      .iter()
+      .flat_map(FlagApi::operand_rules)
+      .collect()
+  }
+}
+
+/// Returns the rules generated for the flag APIs declared in the `flag_apis.toml` file of `path_to_configurations` (if any).
+/// Panics if the file cannot be parsed.
+pub(crate) fn read_flag_apis(path_to_configurations: &str) -> Vec<Rule> {
+  let path_to_flag_apis = Path::new(path_to_configurations).join(FLAG_APIS_FILE);
+  if !path_to_flag_apis.exists() {
+    return vec![];
+  }
+  let flag_apis = read_toml::<FlagApis>(&path_to_flag_apis, false);
+  [flag_apis.rules(), flag_apis.operand_rules()].concat()
+}
+
+impl FlagApi {
+  /// Returns the rule replacing the calls to this API with the value of the stale flag
+  pub(crate) fn rule(&self) -> Rule {
+    let (replace, hole, group) = match (&self.return_type, &self.semantics) {
+      (ReturnType::Bool, Semantics::Treated) => (
+        "@treated",
+        "treated",
+        "replace_expression_with_boolean_literal",
+      ),
+      (ReturnType::Bool, Semantics::Control) => (
+        "@treated_complement",
+        "treated_complement",
+        "replace_expression_with_boolean_literal",
+      ),
+      (ReturnType::String, _) => (
+        "\"@treatment\"",
+        "treatment",
+        "replace_expression_with_string_literal",
+      ),
+      (ReturnType::Int, _) => (
+        "@rollout",
+        "rollout",
+        "replace_expression_with_numeric_literal",
+      ),
+      (ReturnType::Float, _) => (
+        "@rollout_fraction",
+        "rollout_fraction",
+        "replace_expression_with_numeric_literal",
+      ),
+    };
+    RuleBuilder::default()
+      .name(self.rule_name())
+      .query(TSQuery::new(self.query()))
+      .replace_node("flag_api_call".to_string())
+      .replace(replace.to_string())
+      .holes(HashSet::from([
+        "stale_flag_name".to_string(),
+        hole.to_string(),
+      ]))
+      .groups(HashSet::from([group.to_string()]))
+      .build()
+      .unwrap()
+  }
+
+  /// Returns the rules dropping the (live) call to this boolean API, when it is the left operand of `&& false` or `|| true`.
+  /// Unlike `simplify_something_and_false` and `simplify_something_or_true`, which leave the operands with calls
+  /// as they are (since they may have side effects), the call is dropped since evaluating a flag has none.
+  /// For instance, `exp.BoolValue("live_flag") && exp.BoolValue("stale_flag")` is then simplified to `false`
+  /// when the stale flag is in control.
+  pub(crate) fn operand_rules(&self) -> Vec<Rule> {
+    if self.return_type != ReturnType::Bool {
+      return vec![];
+    }
+    let (call, predicates) = self.call_pattern();
+    [("&&", "false", "and_false"), ("||", "true", "or_true")]
+      .iter()
+      .map(|(operator, literal, suffix)| {
+        RuleBuilder::default()
+          .name(format!("{}_{suffix}", self.rule_name()))
+          .query(TSQuery::new(format!(
+            r#"(
+    (binary_expression
+        left: {call}
+        operator: "{operator}"
+        right: [({literal}) (parenthesized_expression ({literal}))]
+    ) @binary_expression{predicates}
+)"#
+          )))
+          .replace_node("binary_expression".to_string())
+          .replace(literal.to_string())
+          .groups(HashSet::from(["boolean_expression_simplify".to_string()]))
+          .is_seed_rule(false)
+          .build()
+          .unwrap()
+      })
+      .collect()
+  }
+
+  fn rule_name(&self) -> String {
+    if !self.name.is_empty() {
+      return self.name.to_string();
+    }
+    if self.package.is_empty() {
+      return format!("flag_api_{}", self.function);
+    }
+    format!(
+      "flag_api_{}_{}",
+      get_assumed_package_name(&self.package),
+      self.function
+    )
+  }
+
+  /// Returns the query matching the calls to this API with the stale flag.
+  /// The package is matched by the name assumed from its path, which is then resolved against the
+  /// imports of each file (see `resolve_package_aliases`).
+  fn query(&self) -> String {
+    let (call, predicates) = self.call_pattern();
+    format!(
+      r#"(
+    {call} @flag_api_call{predicates}
+    (#eq? @flag_api_flag_name "\"@stale_flag_name\"")
+)"#
+    )
+  }
+
+  /// Returns the pattern of the calls to this API (with any flag), along with the predicates on its captures
+  fn call_pattern(&self) -> (String, String) {
+    let (flag_argument, flag_predicate) = self.flag_argument();
+    let mut arguments = vec![];
+    if self.flag_option.is_empty() {
+      for _ in 0..self.flag_argument_index {
+        arguments.push("(_)".to_string());
+      }
+    }
+    arguments.push(flag_argument);
+    if let Some(argument_count) = self.argument_count.filter(|_| self.flag_option.is_empty()) {
+      for _ in self.flag_argument_index + 1..argument_count {
+        arguments.push("(_)".to_string());
+      }
+      arguments.push(String::new());
+    }
+    let (operand, package_predicate) = if self.package.is_empty() {
+      ("(_)".to_string(), String::new())
+    } else {
+      (
+        "(_) @flag_api_package".to_string(),
+        format!(
+          "\n    (#eq? @flag_api_package \"{}\")",
+          get_assumed_package_name(&self.package)
+        ),
+      )
+    };
+    (
+      format!(
+        r#"(call_expression
+        function: (selector_expression
+            operand: {operand}
+            field: (field_identifier) @flag_api_function
+        )
+        arguments: (argument_list{}
+            {}
+        )
+    )"#,
+        if self.flag_option.is_empty() {
+          "\n            ."
+        } else {
+          ""
+        },
+        arguments.join("\n            .\n            "),
+      ),
+      format!(
+        "\n    (#eq? @flag_api_function \"{}\"){package_predicate}{flag_predicate}",
+        self.function
+      ),
+    )
+  }
+
+  /// Returns the pattern of the argument naming the flag, i.e. the string literal, the struct literal with the `flag_field`,
+  /// or the call to the `flag_option`, along with the predicate on the field (or the option)
+  fn flag_argument(&self) -> (String, String) {
+    let literal = "(interpreted_string_literal) @flag_api_flag_name";
+    if !self.flag_option.is_empty() {
+      return (
+        format!(
+          r#"(call_expression
+                function: [
+                    (identifier) @flag_api_option
+                    (selector_expression
+                        field: (field_identifier) @flag_api_option
+                    )
+                ]
+                arguments: (argument_list
+                    .
+                    {literal}
+                    .
+                )
+            )"#
+        ),
+        format!("\n    (#eq? @flag_api_option \"{}\")", self.flag_option),
+      );
+    }
+    if !self.flag_field.is_empty() {
+      let composite_literal = format!(
+        r#"(composite_literal
+                    body: (literal_value
+                        (keyed_element
+                            .
+                            (field_identifier) @flag_api_flag_field
+                            .
+                            {literal}
+                            .
+                        )
+                    )
+                )"#
+      );
+      return (
+        format!(
+          r#"[
+                {composite_literal}
+                (unary_expression
+                    operand: {composite_literal}
+                )
+            ]"#
+        ),
+        format!("\n    (#eq? @flag_api_flag_field \"{}\")", self.flag_field),
+      );
+    }
+    (literal.to_string(), String::new())
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/flag_apis_test.rs"]
+mod flag_apis_test;
@@ -0,0 +1,400 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use getset::Getters;
+use log::debug;
+use regex::Regex;
+use tree_sitter::Range;
+
+use super::{
+  edit::Edit,
+  matches::Match,
+  persist::{back_up_file, write_atomically},
+  piranha_arguments::PiranhaArguments,
+};
+use crate::utilities::tree_sitter_utilities::{get_tree_sitter_edit, position_for_offset};
+
+/// The name of the (pseudo) rule reported for the stanzas deleted from the flag definition files
+pub(crate) static DELETE_FLAG_DEFINITION: &str = "delete_flag_definition";
+/// The fields naming the flag in the entries of a list of flag definitions (e.g. `- name: stale_flag`)
+static NAME_FIELDS: [&str; 4] = ["name", "key", "id", "flag"];
+
+/// A (YAML or JSON) file defining the flags alongside the code (e.g. `flags.yaml`)
+#[derive(Debug, Clone, Getters)]
+pub(crate) struct FlagDefinitionFile {
+  #[get = "pub(crate)"]
+  path: PathBuf,
+  #[get = "pub(crate)"]
+  original_content: String,
+  #[get = "pub(crate)"]
+  content: String,
+  // The stanzas deleted from the file
+  #[get = "pub(crate)"]
+  rewrites: Vec<Edit>,
+}
+
+impl FlagDefinitionFile {
+  pub(crate) fn new(path: PathBuf, content: String) -> Self {
+    FlagDefinitionFile {
+      path,
+      original_content: content.to_string(),
+      content,
+      rewrites: vec![],
+    }
+  }
+
+  /// Deletes the stanzas defining the flag, i.e. :
+  /// * the entries keyed by the flag (e.g. `stale_flag: {...}`),
+  /// * the elements of a list naming the flag (e.g. `- stale_flag` or `- name: stale_flag`),
+  /// along with the comments directly above them (YAML only).
+  /// The files other than `.yaml`, `.yml` and `.json` (or with invalid JSON) are left as they are.
+  pub(crate) fn delete_flag(&mut self, flag_name: &str) {
+    // Delete one stanza at a time, since each deletion shifts the ranges of the remaining ones
+    while let Some((start_byte, end_byte)) = self.get_stanza(flag_name) {
+      let range = Range {
+        start_byte,
+        end_byte,
+        start_point: position_for_offset(self.content.as_bytes(), start_byte),
+        end_point: position_for_offset(self.content.as_bytes(), end_byte),
+      };
+      let edit = Edit::new(
+        Match::new(
+          self.content[start_byte..end_byte].to_string(),
+          range,
+          HashMap::new(),
+        ),
+        String::new(),
+        DELETE_FLAG_DEFINITION.to_string(),
+        &self.content,
+      );
+      debug!(
+        "Deleting the definition of the flag in {:?} {}",
+        self.path,
+        edit.p_match().matched_string()
+      );
+      self.content = get_tree_sitter_edit(self.content.to_string(), &edit).0;
+      self.rewrites.push(edit);
+    }
+  }
+
+  /// Writes the updated content to the file (unless it is a dry run), atomically and after backing it up (see `backup`)
+  pub(crate) fn persist(&self, piranha_arguments: &PiranhaArguments) {
+    if *piranha_arguments.dry_run() || self.content == self.original_content {
+      return;
+    }
+    if let Some(backup_path) =
+      back_up_file(&self.path, piranha_arguments).expect("Unable to Back up file")
+    {
+      debug!(
+        "Backed up the flag definition file {:?} to {:?}",
+        self.path, backup_path
+      );
+    }
+    debug!("Writing the flag definition file {:?}", self.path);
+    write_atomically(&self.path, &self.content).expect("Unable to Write file");
+  }
+
+  /// Returns the byte range of the first stanza defining the flag (if any)
+  fn get_stanza(&self, flag_name: &str) -> Option<(usize, usize)> {
+    match self.path.extension().and_then(|e| e.to_str()) {
+      Some("yaml") | Some("yml") => get_yaml_stanza(&self.content, flag_name),
+      Some("json") => JsonParser::new(&self.content)
+        .parse()
+        .and_then(|value| get_json_stanza(&value, flag_name)),
+      _ => None,
+    }
+  }
+}
+
+/// Returns the byte range of the first stanza defining the flag in the YAML content, i.e. the lines of the entry keyed by
+/// the flag or of the list item naming it, along with the lines indented deeper and the comments directly above.
+fn get_yaml_stanza(content: &str, flag_name: &str) -> Option<(usize, usize)> {
+  // The start and end (i.e. after the new line) bytes of each line, along with its text
+  let mut lines = vec![];
+  let mut start_byte = 0;
+  for line in content.split_inclusive('\n') {
+    lines.push((start_byte, start_byte + line.len(), line.trim_end()));
+    start_byte += line.len();
+  }
+  let flag = regex::escape(flag_name);
+  let flag = format!(r#"(?:{flag}|"{flag}"|'{flag}')"#);
+  let key = Regex::new(&format!(r"^(\s*)(- +)?{flag}\s*:(\s|$)")).unwrap();
+  let item = Regex::new(&format!(r"^(\s*)- +{flag}\s*(#.*)?$")).unwrap();
+  let name = Regex::new(&format!(
+    r"^(\s*)(- +)?(?:{})\s*:\s*{flag}\s*(#.*)?$",
+    NAME_FIELDS.join("|")
+  ))
+  .unwrap();
+  let dash = Regex::new(r"^(\s*)- +").unwrap();
+
+  for (index, (_, _, line)) in lines.iter().enumerate() {
+    // The first line of the stanza, along with its indentation
+    let (first, indent) = if let Some(c) = key.captures(line).or_else(|| item.captures(line)) {
+      (index, c[1].len())
+    } else if let Some(c) = name.captures(line) {
+      if c.get(2).is_some() {
+        (index, c[1].len())
+      } else {
+        // The name is not the first field of the list item (i.e. `- owner: ...\n  name: stale_flag`)
+        let field_indent = c[1].len();
+        match lines[..index]
+          .iter()
+          .enumerate()
+          .rev()
+          .find(|(_, (_, _, l))| {
+            !l.trim().is_empty() && !is_comment(l) && indentation(l) < field_indent
+          })
+          .and_then(|(i, (_, _, l))| dash.captures(l).map(|d| (i, d)))
+          .filter(|(_, d)| d[0].len() == field_indent)
+        {
+          Some((i, d)) => (i, d[1].len()),
+          None => continue,
+        }
+      }
+    } else {
+      continue;
+    };
+    // The stanza spans the following lines indented deeper (along with the blank lines in between)
+    let mut last = first;
+    for (i, (_, _, l)) in lines.iter().enumerate().skip(first + 1) {
+      if l.trim().is_empty() {
+        continue;
+      }
+      if indentation(l) <= indent {
+        break;
+      }
+      last = i;
+    }
+    // Along with the comments directly above
+    let mut first = first;
+    while first > 0 && is_comment(lines[first - 1].2) && indentation(lines[first - 1].2) == indent {
+      first -= 1;
+    }
+    return Some((lines[first].0, lines[last].1));
+  }
+  None
+}
+
+/// Returns the number of spaces indenting the line
+fn indentation(line: &str) -> usize {
+  line.len() - line.trim_start().len()
+}
+
+fn is_comment(line: &str) -> bool {
+  line.trim_start().starts_with('#')
+}
+
+/// A JSON value, along with its byte range
+#[derive(Debug, Clone, PartialEq, Eq)]
+enum JsonValue {
+  // The members, i.e. the key along with the byte range from the key to the end of the value
+  Object((usize, usize), Vec<(String, (usize, usize), JsonValue)>),
+  Array((usize, usize), Vec<JsonValue>),
+  // The (unescaped) content of the string
+  String((usize, usize), String),
+  // Numbers, booleans and `null`
+  Literal((usize, usize)),
+}
+
+impl JsonValue {
+  fn range(&self) -> (usize, usize) {
+    match self {
+      JsonValue::Object(r, _)
+      | JsonValue::Array(r, _)
+      | JsonValue::String(r, _)
+      | JsonValue::Literal(r) => *r,
+    }
+  }
+
+  /// Checks if the value names the flag, i.e. is the flag or an object whose name field is the flag
+  fn names(&self, flag_name: &str) -> bool {
+    match self {
+      JsonValue::String(_, s) => s == flag_name,
+      JsonValue::Object(_, members) => members.iter().any(|(key, _, value)| {
+        NAME_FIELDS.contains(&key.as_str())
+          && matches!(value, JsonValue::String(_, s) if s == flag_name)
+      }),
+      _ => false,
+    }
+  }
+}
+
+/// Returns the byte range of the first stanza defining the flag in the JSON value (see `get_yaml_stanza`),
+/// i.e. the member keyed by the flag or the array element naming it, along with its separating comma.
+fn get_json_stanza(value: &JsonValue, flag_name: &str) -> Option<(usize, usize)> {
+  match value {
+    JsonValue::Object(range, members) => {
+      let elements = members.iter().map(|(_, r, _)| *r).collect::<Vec<_>>();
+      members
+        .iter()
+        .position(|(key, _, _)| key == flag_name)
+        .map(|index| get_element_deletion_range(*range, &elements, index))
+        .or_else(|| {
+          members
+            .iter()
+            .find_map(|(_, _, v)| get_json_stanza(v, flag_name))
+        })
+    }
+    JsonValue::Array(range, values) => {
+      let elements = values.iter().map(|v| v.range()).collect::<Vec<_>>();
+      values
+        .iter()
+        .position(|v| v.names(flag_name))
+        .map(|index| get_element_deletion_range(*range, &elements, index))
+        .or_else(|| values.iter().find_map(|v| get_json_stanza(v, flag_name)))
+    }
+    _ => None,
+  }
+}
+
+/// Returns the byte range to delete in order to remove the `index`-th element of the object or array, along with its
+/// separating comma. The content of the object or array is deleted entirely when the element is the only one.
+fn get_element_deletion_range(
+  container: (usize, usize), elements: &[(usize, usize)], index: usize,
+) -> (usize, usize) {
+  match (elements.get(index + 1), index) {
+    (Some(next), _) => (elements[index].0, next.0),
+    (None, 0) => (container.0 + 1, container.1 - 1),
+    (None, _) => (elements[index - 1].1, elements[index].1),
+  }
+}
+
+/// A minimal JSON parser, keeping track of the byte ranges of the values in order to preserve the formatting
+/// of the content when deleting them.
+struct JsonParser<'a> {
+  content: &'a [u8],
+  position: usize,
+}
+
+impl<'a> JsonParser<'a> {
+  fn new(content: &'a str) -> Self {
+    JsonParser {
+      content: content.as_bytes(),
+      position: 0,
+    }
+  }
+
+  /// Parses the content as a single JSON value. Returns None if the content is not valid JSON.
+  fn parse(&mut self) -> Option<JsonValue> {
+    let value = self.parse_value()?;
+    self.skip_whitespace();
+    Some(value).filter(|_| self.position == self.content.len())
+  }
+
+  fn parse_value(&mut self) -> Option<JsonValue> {
+    self.skip_whitespace();
+    let start = self.position;
+    match self.content.get(self.position)? {
+      b'{' => {
+        self.position += 1;
+        let mut members = vec![];
+        while self.next_element(b'}', members.is_empty())? {
+          self.skip_whitespace();
+          let key_start = self.position;
+          let key = match self.parse_value()? {
+            JsonValue::String(_, key) => key,
+            _ => return None,
+          };
+          self.skip_whitespace();
+          self.expect(b':')?;
+          let value = self.parse_value()?;
+          members.push((key, (key_start, value.range().1), value));
+        }
+        Some(JsonValue::Object((start, self.position), members))
+      }
+      b'[' => {
+        self.position += 1;
+        let mut values = vec![];
+        while self.next_element(b']', values.is_empty())? {
+          values.push(self.parse_value()?);
+        }
+        Some(JsonValue::Array((start, self.position), values))
+      }
+      b'"' => {
+        self.position += 1;
+        let mut value = vec![];
+        loop {
+          match *self.content.get(self.position)? {
+            b'"' => break,
+            b'\\' => {
+              self.position += 1;
+              value.push(match *self.content.get(self.position)? {
+                b'n' => b'\n',
+                b't' => b'\t',
+                b'r' => b'\r',
+                c => c,
+              });
+            }
+            c => value.push(c),
+          }
+          self.position += 1;
+        }
+        self.position += 1;
+        Some(JsonValue::String(
+          (start, self.position),
+          String::from_utf8(value).ok()?,
+        ))
+      }
+      _ => {
+        while self
+          .content
+          .get(self.position)
+          .map(|c| c.is_ascii_alphanumeric() || b"+-.".contains(c))
+          .unwrap_or(false)
+        {
+          self.position += 1;
+        }
+        Some(JsonValue::Literal((start, self.position))).filter(|_| self.position > start)
+      }
+    }
+  }
+
+  /// Consumes the separator before the next element of the object or array, or its closing character.
+  /// Returns whether there is a next element.
+  fn next_element(&mut self, closing: u8, is_first: bool) -> Option<bool> {
+    self.skip_whitespace();
+    if self.content.get(self.position) == Some(&closing) {
+      self.position += 1;
+      return Some(false);
+    }
+    if !is_first {
+      self.expect(b',')?;
+    }
+    Some(true)
+  }
+
+  fn expect(&mut self, c: u8) -> Option<()> {
+    if self.content.get(self.position) != Some(&c) {
+      return None;
+    }
+    self.position += 1;
+    Some(())
+  }
+
+  fn skip_whitespace(&mut self) {
+    while self
+      .content
+      .get(self.position)
+      .map(|c| c.is_ascii_whitespace())
+      .unwrap_or(false)
+    {
+      self.position += 1;
+    }
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/flag_definitions_test.rs"]
+mod flag_definitions_test;
@@ -0,0 +1,196 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::path::Path;
+
+use serde_derive::Deserialize;
+
+use super::{
+  dynamic_flag_names::STALE_FLAG_NAME,
+  templates::{TEMPLATE_FIELD, TEMPLATE_FUNCTION},
+};
+use crate::utilities::read_file;
+
+/// The substitution for the value of the stale flag
+pub(crate) static TREATED: &str = "treated";
+/// The substitution for the complement of the (boolean) value of the stale flag
+pub(crate) static TREATED_COMPLEMENT: &str = "treated_complement";
+/// The substitution for the variant (or the string value) of the stale flag
+static TREATMENT: &str = "treatment";
+
+/// The key of the cleanup mode of a flag, in the flag file and in the `flags` (e.g. `stale_flag_name=SOME_FLAG,mode=substitute-only`)
+pub(crate) static MODE: &str = "mode";
+/// The default mode, cleaning up the code depending on the flag (e.g. the branches that are no longer taken)
+static CLEANUP: &str = "cleanup";
+/// The mode only substituting the value of the flag for its evaluations (e.g. during a staged rollback plan)
+static SUBSTITUTE_ONLY: &str = "substitute-only";
+
+/// Parses the cleanup mode of a flag, i.e. returns true for `substitute-only` and false for `cleanup`
+pub(crate) fn is_substitute_only(mode: &str) -> Result<bool, String> {
+  match mode.trim() {
+    m if m == CLEANUP => Ok(false),
+    m if m == SUBSTITUTE_ONLY => Ok(true),
+    m => Err(format!(
+      "The mode `{m}` is not supported (supported: `{CLEANUP}`, `{SUBSTITUTE_ONLY}`)"
+    )),
+  }
+}
+
+/// The value of the stale flag, as a JSON boolean (e.g. `true`) or string (e.g. `"true"`)
+#[derive(Deserialize, Debug, Clone, PartialEq, Eq)]
+#[serde(untagged)]
+enum Treated {
+  Bool(bool),
+  String(String),
+}
+
+/// An entry of the flag file (e.g. exported by the flag management system), i.e. a stale flag to clean up
+#[derive(Deserialize, Debug, Clone, PartialEq, Eq)]
+pub(crate) struct FlagEntry {
+  // The name of the flag (i.e. the `stale_flag_name` substitution)
+  name: String,
+  // The value of the flag (i.e. the `treated` substitution)
+  #[serde(default)]
+  treated: Option<Treated>,
+  // The variant of the flag (i.e. the `treatment` substitution)
+  #[serde(default)]
+  treatment: Option<String>,
+  // The cleanup mode of the flag (i.e. `cleanup` or `substitute-only`), the one of the `piranha_arguments` by default
+  #[serde(default)]
+  mode: Option<String>,
+  // The field evaluating the flag in the templates (i.e. the `template_field` substitution)
+  #[serde(default)]
+  template_field: Option<String>,
+  // The function evaluating the flag in the templates (i.e. the `template_function` substitution)
+  #[serde(default)]
+  template_function: Option<String>,
+  // The paths (as glob patterns, relative to the code base) within which the flag is cleaned up (anywhere, if empty)
+  #[serde(default)]
+  pub(crate) paths: Vec<String>,
+}
+
+impl FlagEntry {
+  /// Returns the substitutions for cleaning up the flag.
+  /// For a boolean value, `treated_complement` is substituted with its negation as well.
+  pub(crate) fn substitutions(&self) -> Vec<(String, String)> {
+    let mut substitutions = vec![(STALE_FLAG_NAME.to_string(), self.name.to_string())];
+    let treated = match &self.treated {
+      Some(Treated::Bool(b)) => Some(b.to_string()),
+      // The booleans are normalized (e.g. `TRUE` exported from a spreadsheet)
+      Some(Treated::String(s)) if ["true", "false"].contains(&s.trim().to_lowercase().as_str()) => {
+        Some(s.trim().to_lowercase())
+      }
+      Some(Treated::String(s)) => Some(s.trim().to_string()).filter(|s| !s.is_empty()),
+      None => None,
+    };
+    if let Some(treated) = treated {
+      match treated.as_str() {
+        "true" => substitutions.push((TREATED_COMPLEMENT.to_string(), "false".to_string())),
+        "false" => substitutions.push((TREATED_COMPLEMENT.to_string(), "true".to_string())),
+        _ => {}
+      }
+      substitutions.push((TREATED.to_string(), treated));
+    }
+    if let Some(treatment) = self.treatment.as_ref().filter(|t| !t.is_empty()) {
+      substitutions.push((TREATMENT.to_string(), treatment.to_string()));
+    }
+    for (key, value) in [
+      (TEMPLATE_FIELD, &self.template_field),
+      (TEMPLATE_FUNCTION, &self.template_function),
+    ] {
+      if let Some(value) = value.as_ref().filter(|v| !v.is_empty()) {
+        substitutions.push((key.to_string(), value.to_string()));
+      }
+    }
+    substitutions
+  }
+
+  /// Returns whether only the value of the flag is substituted (if the mode is given)
+  pub(crate) fn substitute_only(&self) -> Option<bool> {
+    self.mode.as_ref().and_then(|m| is_substitute_only(m).ok())
+  }
+}
+
+/// Reads the entries of the flag file, i.e.
+/// * a JSON array of objects with the `name`, `treated` and (optionally) `treatment`, `mode`, `paths`, `template_field` and
+///   `template_function` fields, or
+/// * a CSV file with a header naming the columns (i.e. `name`, `treated`, `treatment`, `mode`, `paths`, `template_field`
+///   and `template_function`),
+///   where the paths are separated by `;`.
+pub(crate) fn read_flag_file(path: &str) -> Result<Vec<FlagEntry>, String> {
+  let content = read_file(&Path::new(path).to_path_buf())?;
+  let entries: Vec<FlagEntry> = if path.ends_with(".csv") {
+    parse_csv_flag_file(&content)?
+  } else {
+    serde_json::from_str(&content).map_err(|e| e.to_string())?
+  };
+  for entry in &entries {
+    if let Some(mode) = &entry.mode {
+      is_substitute_only(mode).map_err(|e| format!("{e} for the flag `{}`", entry.name))?;
+    }
+  }
+  Ok(entries)
+}
+
+/// Parses the CSV flag file. The fields are not quoted (i.e. they cannot contain a comma).
+fn parse_csv_flag_file(content: &str) -> Result<Vec<FlagEntry>, String> {
+  let mut lines = content
+    .lines()
+    .map(str::trim)
+    .filter(|l| !l.is_empty() && !l.starts_with('#'));
+  let header: Vec<String> = match lines.next() {
+    Some(h) => h.split(',').map(|c| c.trim().to_lowercase()).collect(),
+    None => return Ok(vec![]),
+  };
+  let column = |name: &str| header.iter().position(|c| c == name);
+  let name_column = column("name").ok_or("The CSV flag file has no `name` column")?;
+  let (treated_column, treatment_column, mode_column, paths_column) = (
+    column(TREATED),
+    column(TREATMENT),
+    column(MODE),
+    column("paths"),
+  );
+  let (template_field_column, template_function_column) =
+    (column(TEMPLATE_FIELD), column(TEMPLATE_FUNCTION));
+  let mut entries = vec![];
+  for line in lines {
+    let fields: Vec<&str> = line.split(',').map(str::trim).collect();
+    let field = |i: Option<usize>| {
+      i.and_then(|i| fields.get(i))
+        .map(|f| f.to_string())
+        .filter(|f| !f.is_empty())
+    };
+    let name = field(Some(name_column)).ok_or(format!("Missing flag name in `{line}`"))?;
+    entries.push(FlagEntry {
+      name,
+      treated: field(treated_column).map(Treated::String),
+      treatment: field(treatment_column),
+      mode: field(mode_column),
+      template_field: field(template_field_column),
+      template_function: field(template_function_column),
+      paths: field(paths_column)
+        .map(|p| {
+          p.split(';')
+            .map(|p| p.trim().to_string())
+            .filter(|p| !p.is_empty())
+            .collect()
+        })
+        .unwrap_or_default(),
+    });
+  }
+  Ok(entries)
+}
+
+#[cfg(test)]
+#[path = "unit_tests/flag_file_test.rs"]
+mod flag_file_test;
@@ -0,0 +1,79 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashMap;
+
+use log::info;
+use regex::Regex;
+
+use super::{matches::Match, source_code_unit::SourceCodeUnit};
+
+/// The name of the (pseudo) rule reported for the generated files skipped by Piranha
+pub(crate) static SKIPPED_GENERATED_FILE: &str = "skipped_generated_file";
+
+/// Checks if the file is generated (e.g. mocks, protobufs or `stringer` output), following the Go convention, i.e.
+/// it contains a line comment matching `^// Code generated .* DO NOT EDIT\.$` before the package clause.
+pub(crate) fn is_generated(content: &str) -> bool {
+  content
+    .lines()
+    .take_while(|l| !l.trim_start().starts_with("package "))
+    .any(is_generated_header)
+}
+
+fn is_generated_header(line: &str) -> bool {
+  Regex::new(r"^// Code generated .* DO NOT EDIT\.$")
+    .unwrap()
+    .is_match(line.trim_end())
+}
+
+// Implements instance methods related to reporting the generated files
+impl SourceCodeUnit {
+  /// Reports the header of this generated file, since it is skipped by the rules (see `include_generated`).
+  pub(crate) fn report_skipped_generated_file(&mut self) {
+    let header = {
+      let root_node = self.root_node();
+      let mut cursor = root_node.walk();
+      let header = root_node
+        .named_children(&mut cursor)
+        .take_while(|n| n.kind() != "package_clause")
+        .find(|n| {
+          n.kind() == "comment"
+            && n
+              .utf8_text(self.code().as_bytes())
+              .map(is_generated_header)
+              .unwrap_or(false)
+        })
+        .map(|n| {
+          Match::new(
+            n.utf8_text(self.code().as_bytes()).unwrap().to_string(),
+            n.range(),
+            HashMap::new(),
+          )
+        });
+      header
+    };
+    if let Some(header) = header {
+      info!(
+        "Skipped {:?}, since it is generated. Use `--include-generated` to rewrite it.",
+        self.path()
+      );
+      self
+        .matches_mut()
+        .push((SKIPPED_GENERATED_FILE.to_string(), header));
+    }
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/generated_files_test.rs"]
+mod generated_files_test;
@@ -0,0 +1,164 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::path::{Path, PathBuf};
+
+use glob::{MatchOptions, Pattern};
+use log::{debug, warn};
+
+use crate::utilities::read_file;
+
+/// The files listing the paths ignored by git (e.g. the build outputs), in any directory of the repository
+pub(crate) static GIT_IGNORE_FILE: &str = ".gitignore";
+/// The directory marking the top-level of a git repository
+static GIT_DIRECTORY: &str = ".git";
+
+/// The wildcards of a `.gitignore` pattern do not match the path separator (i.e. `*` only matches within a file name)
+static MATCH_OPTIONS: MatchOptions = MatchOptions {
+  case_sensitive: true,
+  require_literal_separator: true,
+  require_literal_leading_dot: false,
+};
+
+/// A pattern of a `.gitignore` file
+#[derive(Debug, Clone)]
+struct GitIgnoreRule {
+  // The (canonical) directory of the `.gitignore` file, which the pattern is relative to
+  base: PathBuf,
+  pattern: Pattern,
+  // Whether the pattern (i.e. `!pattern`) re-includes the paths ignored by the previous ones
+  negated: bool,
+  // Whether the pattern (i.e. `pattern/`) only matches directories
+  directory_only: bool,
+}
+
+/// The `.gitignore` rules applying to a directory of the code base being walked over, i.e. the ones of the `.gitignore`
+/// files of the directory, of its parents in the code base, and of the parents of the code base (up to the top-level of the
+/// git repository, if any).
+#[derive(Debug, Clone, Default)]
+pub(crate) struct GitIgnore {
+  // The path to the code base (as walked over) and its canonical path, to resolve the paths walked over
+  root: PathBuf,
+  canonical_root: PathBuf,
+  rules: Vec<GitIgnoreRule>,
+}
+
+impl GitIgnore {
+  /// Returns the rules of the `.gitignore` files of the parents of the code base, up to the top-level of the git repository
+  /// containing it (if any). The ones of the code base itself are read while walking over it (see `read_directory`).
+  pub(crate) fn new(path_to_codebase: &Path) -> GitIgnore {
+    let canonical_root = path_to_codebase
+      .canonicalize()
+      .unwrap_or_else(|_| path_to_codebase.to_path_buf());
+    let mut git_ignore = GitIgnore {
+      root: path_to_codebase.to_path_buf(),
+      canonical_root: canonical_root.clone(),
+      rules: vec![],
+    };
+    if canonical_root.join(GIT_DIRECTORY).exists() {
+      return git_ignore;
+    }
+    let parents: Vec<&Path> = canonical_root.ancestors().skip(1).collect();
+    if let Some(top_level) = parents.iter().position(|p| p.join(GIT_DIRECTORY).exists()) {
+      for parent in parents[..=top_level].iter().rev() {
+        git_ignore.read_rules(parent);
+      }
+    }
+    git_ignore
+  }
+
+  /// Adds the rules of the `.gitignore` file of the `directory` (as walked over), if any.
+  pub(crate) fn read_directory(&mut self, directory: &Path) {
+    let directory = self.get_canonical_path(directory);
+    self.read_rules(&directory);
+  }
+
+  /// Checks if the path (as walked over) is ignored, i.e. the last pattern matching it is not negated.
+  pub(crate) fn is_ignored(&self, path: &Path, is_dir: bool) -> bool {
+    let path = self.get_canonical_path(path);
+    self
+      .rules
+      .iter()
+      .rev()
+      .find(|r| {
+        (is_dir || !r.directory_only)
+          && path
+            .strip_prefix(&r.base)
+            .map(|p| r.pattern.matches_path_with(p, MATCH_OPTIONS))
+            .unwrap_or(false)
+      })
+      .map(|r| !r.negated)
+      .unwrap_or(false)
+  }
+
+  fn read_rules(&mut self, directory: &Path) {
+    let path_to_ignore_file = directory.join(GIT_IGNORE_FILE);
+    if !path_to_ignore_file.is_file() {
+      return;
+    }
+    debug!("Reading the ignored paths from {path_to_ignore_file:?}");
+    if let Ok(content) = read_file(&path_to_ignore_file) {
+      self.rules.extend(parse_git_ignore(&content, directory));
+    }
+  }
+
+  fn get_canonical_path(&self, path: &Path) -> PathBuf {
+    path
+      .strip_prefix(&self.root)
+      .map(|p| self.canonical_root.join(p))
+      .unwrap_or_else(|_| path.to_path_buf())
+  }
+}
+
+/// Parses the content of a `.gitignore` file in the `base` directory, i.e. one glob pattern per line, with `#` starting
+/// a comment. A pattern without a `/` (except a trailing one, e.g. `bin/`, which only matches directories) matches at any
+/// depth, and the others are relative to the `base` directory. Unlike `.piranhaignore`, negated patterns (i.e. `!pattern`)
+/// are supported, the last pattern matching a path deciding whether it is ignored.
+fn parse_git_ignore(content: &str, base: &Path) -> Vec<GitIgnoreRule> {
+  let mut rules = vec![];
+  for line in content.lines().map(str::trim_end) {
+    if line.is_empty() || line.starts_with('#') {
+      continue;
+    }
+    let (negated, pattern) = match line.strip_prefix('!') {
+      Some(p) => (true, p),
+      None => (false, line),
+    };
+    let (directory_only, pattern) = match pattern.strip_suffix('/') {
+      Some(p) => (true, p),
+      None => (false, pattern),
+    };
+    let pattern = match pattern.strip_prefix('/') {
+      Some(anchored) => anchored.to_string(),
+      None if pattern.contains('/') => pattern.to_string(),
+      None => format!("**/{pattern}"),
+    };
+    match Pattern::new(&pattern) {
+      Ok(pattern) => rules.push(GitIgnoreRule {
+        base: base.to_path_buf(),
+        pattern,
+        negated,
+        directory_only,
+      }),
+      Err(e) => warn!(
+        "Invalid pattern `{line}` in {:?} : {e}",
+        base.join(GIT_IGNORE_FILE)
+      ),
+    }
+  }
+  rules
+}
+
+#[cfg(test)]
+#[path = "unit_tests/git_ignore_test.rs"]
+mod git_ignore_test;
@@ -0,0 +1,147 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  fs,
+  path::{Path, PathBuf},
+};
+
+use getset::Getters;
+use jwalk::WalkDir;
+use log::debug;
+use regex::Regex;
+
+/// The name of the file tying several modules together into a workspace
+static GO_WORK: &str = "go.work";
+/// The name of the file declaring a module
+static GO_MOD: &str = "go.mod";
+/// The directories that never contain a module of the code base
+static SKIPPED_DIRECTORIES: [&str; 3] = ["vendor", "testdata", "node_modules"];
+
+/// A Go module of the code base, i.e. a directory containing a `go.mod` file
+#[derive(Debug, Clone, PartialEq, Eq, Getters)]
+pub(crate) struct GoModule {
+  // The (canonicalized) directory of the module
+  #[get = "pub"]
+  directory: PathBuf,
+  // The path declared by the `module` directive (e.g. `github.com/company/shop/payments`)
+  #[get = "pub"]
+  module_path: String,
+}
+
+/// Returns the Go modules of the code base, sorted by directory.
+/// If the code base contains a `go.work` file, the modules are the ones of its `use` directives (within the code base),
+/// otherwise they are the directories containing a `go.mod` file (skipping the `vendor` and `testdata` directories).
+pub(crate) fn find_go_modules(path_to_codebase: &str) -> Vec<GoModule> {
+  let root = match Path::new(path_to_codebase).canonicalize() {
+    Ok(r) if r.is_dir() => r,
+    _ => return vec![],
+  };
+  let directories = match fs::read_to_string(root.join(GO_WORK)) {
+    Ok(go_work) => parse_use_directives(&go_work)
+      .iter()
+      .filter_map(|d| root.join(d).canonicalize().ok())
+      .filter(|d| d.starts_with(&root))
+      .collect(),
+    Err(_) => WalkDir::new(&root)
+      .process_read_dir(|_, _, _, children| {
+        children.retain(|c| {
+          c.as_ref()
+            .map(|e| {
+              let name = e.file_name().to_string_lossy();
+              !(e.file_type().is_dir()
+                && (name.starts_with('.') || SKIPPED_DIRECTORIES.contains(&name.as_ref())))
+            })
+            .unwrap_or(false)
+        })
+      })
+      .into_iter()
+      .filter_map(|e| e.ok())
+      .filter(|e| e.file_type().is_file() && e.file_name().to_str() == Some(GO_MOD))
+      .filter_map(|e| e.path().parent().map(Path::to_path_buf))
+      .collect::<Vec<_>>(),
+  };
+  let mut modules: Vec<GoModule> = directories
+    .into_iter()
+    .filter_map(|directory| {
+      let module_path = fs::read_to_string(directory.join(GO_MOD))
+        .ok()
+        .and_then(|c| parse_module_directive(&c))?;
+      Some(GoModule {
+        directory,
+        module_path,
+      })
+    })
+    .collect();
+  modules.sort_by(|a, b| a.directory.cmp(&b.directory));
+  modules.dedup();
+  debug!("Found the Go modules {:?}", modules);
+  modules
+}
+
+/// Returns the innermost module containing the file (if any)
+pub(crate) fn get_module_of<'a>(modules: &'a [GoModule], path: &Path) -> Option<&'a GoModule> {
+  let path = path.canonicalize().unwrap_or_else(|_| path.to_path_buf());
+  modules
+    .iter()
+    .filter(|m| path.starts_with(&m.directory))
+    .max_by_key(|m| m.directory.components().count())
+}
+
+/// Returns the directories of the `use` directives of the `go.work` file, i.e. `use ./payments` or
+/// `use ( ./payments ./search )`, with the comments stripped
+fn parse_use_directives(go_work: &str) -> Vec<String> {
+  let mut directories = vec![];
+  let mut in_block = false;
+  for line in go_work.lines() {
+    let line = line.split("//").next().unwrap_or_default().trim();
+    if in_block {
+      if line.starts_with(')') {
+        in_block = false;
+      } else if !line.is_empty() {
+        directories.push(unquote(line));
+      }
+      continue;
+    }
+    if let Some(rest) = line.strip_prefix("use") {
+      let rest = rest.trim();
+      if rest.starts_with('(') {
+        let rest = rest.trim_start_matches('(').trim();
+        in_block = !rest.ends_with(')');
+        let rest = rest.trim_end_matches(')').trim();
+        if !rest.is_empty() {
+          directories.push(unquote(rest));
+        }
+      } else if !rest.is_empty() {
+        directories.push(unquote(rest));
+      }
+    }
+  }
+  directories
+}
+
+/// Returns the path declared by the `module` directive of the `go.mod` file
+fn parse_module_directive(go_mod: &str) -> Option<String> {
+  Regex::new(r#"(?m)^\s*module\s+"?([^\s"]+)"?"#)
+    .unwrap()
+    .captures(go_mod)
+    .map(|c| c[1].to_string())
+}
+
+fn unquote(s: &str) -> String {
+  s.trim_matches(|c| c == '"' || c == '`').to_string()
+}
+
+#[cfg(test)]
+#[path = "unit_tests/go_modules_test.rs"]
+mod go_modules_test;
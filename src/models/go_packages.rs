@@ -0,0 +1,197 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::HashMap,
+  path::{Path, PathBuf},
+  process::Command,
+};
+
+use log::{debug, warn};
+use regex::Regex;
+use serde_derive::Deserialize;
+
+use super::go_modules::find_go_modules;
+
+/// The loaders of the Go packages (i.e. `package_loader`)
+pub(crate) static PACKAGE_LOADERS: [&str; 2] = ["directory", "go_list"];
+/// The loader listing the packages with `go list`, i.e. the driver of `golang.org/x/tools/go/packages`
+pub(crate) static GO_LIST: &str = "go_list";
+
+/// A package, as listed by `go list -json` (the names of the files are relative to its directory)
+#[derive(Deserialize, Debug, Default)]
+#[serde(rename_all = "PascalCase", default)]
+struct GoListPackage {
+  dir: String,
+  import_path: String,
+  name: String,
+  go_files: Vec<String>,
+  cgo_files: Vec<String>,
+  test_go_files: Vec<String>,
+  x_test_go_files: Vec<String>,
+  // The files excluded by the build context (e.g. `//go:build windows`, `_test.go` files of another `GOOS`)
+  ignored_go_files: Vec<String>,
+}
+
+/// The package each Go file of the code base belongs to, i.e. its import path (with the `_test` suffix for the
+/// external test packages), as listed by `go list`.
+/// The files missing from the listing (or all of them, with the `directory` loader) belong to the package named
+/// by their package clause in their directory.
+#[derive(Debug, Default, Clone)]
+pub(crate) struct GoPackages {
+  // The (canonicalized) path of each listed file, and its package
+  packages: HashMap<PathBuf, String>,
+}
+
+impl GoPackages {
+  /// Lists the packages of the code base with `go list -e -json ./...` (i.e. the way `golang.org/x/tools/go/packages` loads them),
+  /// so that the files of the same directory declaring different packages (e.g. the external test package `foo_test`,
+  /// or a `//go:build ignore` tool in `package main`) are told apart.
+  /// The packages of each module of the code base (see `find_go_modules`, e.g. the modules of a `go.work` workspace)
+  /// are listed from the directory of the module, i.e. with its own dependencies. The modules whose listing fails are skipped.
+  pub(crate) fn load(path_to_codebase: &str) -> Result<GoPackages, String> {
+    let path = Path::new(path_to_codebase);
+    let directory = if path.is_file() {
+      path.parent().unwrap_or(path)
+    } else {
+      path
+    };
+    let modules = find_go_modules(directory.to_str().unwrap_or_default());
+    if modules.is_empty() {
+      return GoPackages::list(directory);
+    }
+    let mut go_packages = GoPackages::default();
+    let mut errors = vec![];
+    for module in &modules {
+      match GoPackages::list(module.directory()) {
+        Ok(p) => go_packages.packages.extend(p.packages),
+        Err(e) => {
+          warn!(
+            "Could not list the packages of the module {} : {e}",
+            module.module_path()
+          );
+          errors.push(e);
+        }
+      }
+    }
+    if errors.len() == modules.len() {
+      return Err(errors.join("\n"));
+    }
+    Ok(go_packages)
+  }
+
+  /// Lists the packages of the module containing the `directory` with `go list`
+  fn list(directory: &Path) -> Result<GoPackages, String> {
+    let output = Command::new("go")
+      .args(["list", "-e", "-json", "./..."])
+      .current_dir(directory)
+      .output()
+      .map_err(|e| format!("Could not run go : {e}"))?;
+    if !output.status.success() {
+      return Err(format!(
+        "`go list` failed : {}",
+        String::from_utf8_lossy(&output.stderr).trim()
+      ));
+    }
+    GoPackages::from_go_list_output(&String::from_utf8_lossy(&output.stdout))
+  }
+
+  /// Builds the packages from the output of `go list -json`, i.e. a stream of JSON objects (one per package)
+  fn from_go_list_output(output: &str) -> Result<GoPackages, String> {
+    let mut packages = HashMap::new();
+    for go_list_package in serde_json::Deserializer::from_str(output).into_iter::<GoListPackage>() {
+      let go_list_package =
+        go_list_package.map_err(|e| format!("Could not parse the output of `go list` : {e}"))?;
+      let directory = PathBuf::from(&go_list_package.dir);
+      let canonicalize = |f: &String| {
+        let file = directory.join(f);
+        file.canonicalize().unwrap_or(file)
+      };
+      for file in go_list_package
+        .go_files
+        .iter()
+        .chain(&go_list_package.cgo_files)
+        .chain(&go_list_package.test_go_files)
+      {
+        packages.insert(canonicalize(file), go_list_package.import_path.to_string());
+      }
+      for file in &go_list_package.x_test_go_files {
+        packages.insert(
+          canonicalize(file),
+          format!("{}_test", go_list_package.import_path),
+        );
+      }
+      // The files of the other build contexts are listed as well, unless they declare another package (e.g. `main`)
+      for file in &go_list_package.ignored_go_files {
+        let path = canonicalize(file);
+        let package_name = std::fs::read_to_string(&path)
+          .ok()
+          .and_then(|c| get_package_name(&c));
+        match package_name {
+          Some(n) if n == go_list_package.name => {
+            packages.insert(path, go_list_package.import_path.to_string());
+          }
+          Some(n) if n == format!("{}_test", go_list_package.name) => {
+            packages.insert(path, format!("{}_test", go_list_package.import_path));
+          }
+          _ => {}
+        }
+      }
+    }
+    debug!("`go list` listed {} Go files", packages.len());
+    Ok(GoPackages { packages })
+  }
+
+  /// Returns the package of the file (with the given content), i.e. its import path if listed by `go list`,
+  /// or else its directory along with the name declared by its package clause.
+  pub(crate) fn get_package(&self, path: &Path, content: &str) -> String {
+    if !self.packages.is_empty() {
+      if let Some(package) = path.canonicalize().ok().and_then(|p| self.packages.get(&p)) {
+        return package.to_string();
+      }
+    }
+    format!(
+      "{}:{}",
+      path.parent().unwrap_or(Path::new("")).display(),
+      get_package_name(content).unwrap_or_default()
+    )
+  }
+}
+
+/// Returns the name declared by the package clause of the Go file (e.g. `foo_test` for `package foo_test`)
+pub(crate) fn get_package_name(content: &str) -> Option<String> {
+  let mut in_block_comment = false;
+  for line in content.lines() {
+    let line = line.trim();
+    if in_block_comment {
+      in_block_comment = !line.contains("*/");
+      continue;
+    }
+    if line.starts_with("/*") {
+      in_block_comment = !line.contains("*/");
+      continue;
+    }
+    if line.is_empty() || line.starts_with("//") {
+      continue;
+    }
+    return Regex::new(r"^package\s+(\w+)")
+      .unwrap()
+      .captures(line)
+      .map(|c| c[1].to_string());
+  }
+  None
+}
+
+#[cfg(test)]
+#[path = "unit_tests/go_packages_test.rs"]
+mod go_packages_test;
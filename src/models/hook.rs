@@ -0,0 +1,56 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::path::Path;
+
+use itertools::Itertools;
+use log::debug;
+
+use super::{changed_files::run_git, piranha_output::PiranhaOutputSummary};
+
+/// Stages the files rewritten by the pre-commit hook again (i.e. `hook`), so that their cleanup is part of the commit.
+/// A file is only staged if its staged content is its content before the cleanup, since its unstaged changes (if any)
+/// would be committed along with the cleanup otherwise.
+/// Returns the paths of the rewritten files that were not staged (i.e. with unstaged changes).
+pub fn stage_rewritten_files(summaries: &[PiranhaOutputSummary]) -> Result<Vec<String>, String> {
+  let mut not_staged = vec![];
+  for summary in summaries
+    .iter()
+    .filter(|s| s.content() != s.original_content())
+    .sorted_by(|a, b| a.path().cmp(b.path()))
+  {
+    let path = Path::new(summary.path());
+    let directory = path
+      .parent()
+      .filter(|p| !p.as_os_str().is_empty())
+      .unwrap_or_else(|| Path::new("."));
+    let name = path
+      .file_name()
+      .map(|n| n.to_string_lossy().to_string())
+      .unwrap_or_default();
+    // The path is relative to the directory (i.e. `:./`), rather than to the root of the working tree
+    let staged_content = run_git(directory, &["show", &format!(":./{name}")]);
+    if staged_content.as_deref() != Ok(summary.original_content().as_str()) {
+      not_staged.push(summary.path().to_string());
+      continue;
+    }
+    // The files deleted by the cleanup (i.e. `delete_file_if_empty`) are removed from the index
+    run_git(directory, &["add", "--all", "--", &name])?;
+    debug!("Staged the rewritten file {}", summary.path());
+  }
+  Ok(not_staged)
+}
+
+#[cfg(test)]
+#[path = "unit_tests/hook_test.rs"]
+mod hook_test;
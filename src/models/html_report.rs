@@ -0,0 +1,311 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::BTreeMap, path::Path};
+
+use crate::utilities::count_changed_lines;
+
+use super::{
+  changed_files::get_directory,
+  diff_preview::{get_side_by_side_hunks, SideBySideHunk},
+  piranha_arguments::PiranhaArguments,
+  piranha_output::PiranhaOutputSummary,
+  provenance::Provenance,
+  report::get_flags_of_rewrites,
+  split::get_relative_path,
+  statistics::RunStatistics,
+};
+
+static TITLE: &str = "Piranha cleanup report";
+/// The group of the files rewritten without any flag (e.g. by a rule without a `stale_flag_name`)
+static NO_FLAG: &str = "(no flag)";
+/// The package of the files at the root of the code base
+static ROOT_PACKAGE: &str = ".";
+static STYLE: &str = "body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+details { margin: 0.5em 0 0.5em 1em; }
+summary { cursor: pointer; }
+summary.flag { font-size: 1.2em; font-weight: bold; }
+summary.package { font-weight: bold; }
+table.hunk { width: 100%; font-family: monospace; margin-bottom: 1em; }
+table.hunk td { border: none; white-space: pre; vertical-align: top; }
+table.hunk th { background: #f0f0ff; font-weight: normal; }
+td.number { color: #888; text-align: right; width: 3em; }
+td.deleted { background: #ffecec; }
+td.inserted { background: #eaffea; }
+td.empty { background: #f8f8f8; }";
+
+/// A standalone HTML report of the changes of a Piranha run (i.e. `report = "html"`), e.g. to be attached to the ticket of
+/// the cleanup for the reviewers who do not use the CLI. The summary statistics come first, followed by the before/after
+/// views of the hunks of each rewritten file, collapsible by flag and by package (i.e. the directory of the file).
+#[derive(Debug, Clone, Default)]
+pub(crate) struct HtmlReport {
+  path_to_codebase: String,
+  // The rewritten files, grouped by flag (i.e. the flags whose cleanup rewrote the file), then by package
+  flags: BTreeMap<String, BTreeMap<String, Vec<HtmlFile>>>,
+  // The total number of files changed, and of lines added and removed
+  files_modified: usize,
+  lines_added: usize,
+  lines_removed: usize,
+  // The summary statistics of the run (if computed), broken down per flag
+  statistics: Option<RunStatistics>,
+}
+
+/// A rewritten file, i.e. its rewrites and the hunks of its diff
+#[derive(Debug, Clone, Default)]
+struct HtmlFile {
+  path: String,
+  // The rule, the provenance and the (1-based) line of each rewrite, in the order they are applied
+  rewrites: Vec<(String, Provenance, usize)>,
+  hunks: Vec<SideBySideHunk>,
+  lines_added: usize,
+  lines_removed: usize,
+}
+
+impl HtmlReport {
+  /// Builds the HTML report of the output summaries of a run with the given `piranha_arguments`
+  pub(crate) fn new(
+    summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+  ) -> HtmlReport {
+    let directory = get_directory(piranha_arguments.path_to_codebase());
+    let mut report = HtmlReport {
+      path_to_codebase: piranha_arguments.path_to_codebase().to_string(),
+      ..Default::default()
+    };
+    for summary in summaries
+      .iter()
+      .filter(|s| s.original_content() != s.content())
+    {
+      let flags = get_flags_of_rewrites(summary, piranha_arguments);
+      let mut flag_names: Vec<String> = flags.iter().flatten().cloned().collect();
+      flag_names.sort();
+      flag_names.dedup();
+      let flag = if flag_names.is_empty() {
+        NO_FLAG.to_string()
+      } else {
+        flag_names.join(", ")
+      };
+      let path = get_relative_path(directory, summary.path())
+        .unwrap_or_else(|| summary.path().trim_start_matches("./").to_string());
+      let package = Path::new(&path)
+        .parent()
+        .map(|p| p.to_string_lossy().to_string())
+        .filter(|p| !p.is_empty())
+        .unwrap_or_else(|| ROOT_PACKAGE.to_string());
+      let (lines_added, lines_removed) =
+        count_changed_lines(summary.original_content(), summary.content());
+      report.files_modified += 1;
+      report.lines_added += lines_added;
+      report.lines_removed += lines_removed;
+      report
+        .flags
+        .entry(flag)
+        .or_default()
+        .entry(package)
+        .or_default()
+        .push(HtmlFile {
+          path,
+          rewrites: summary
+            .rewrites()
+            .iter()
+            .map(|e| {
+              (
+                e.matched_rule().to_string(),
+                Provenance::of(e.matched_rule(), piranha_arguments),
+                e.p_match().range().start_point.row + 1,
+              )
+            })
+            .collect(),
+          hunks: get_side_by_side_hunks(summary.original_content(), summary.content()),
+          lines_added,
+          lines_removed,
+        });
+    }
+    for packages in report.flags.values_mut() {
+      for files in packages.values_mut() {
+        files.sort_by(|a, b| a.path.cmp(&b.path));
+      }
+    }
+    report
+  }
+
+  /// Includes the summary statistics of the run in the report
+  pub(crate) fn with_statistics(mut self, statistics: &RunStatistics) -> HtmlReport {
+    self.statistics = Some(statistics.clone());
+    self
+  }
+
+  pub(crate) fn to_html(&self) -> String {
+    let mut html = format!(
+      "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>{TITLE}</title>\n<style>\n{STYLE}\n</style>\n</head>\n<body>\n<h1>{TITLE}</h1>\n"
+    );
+    if !self.path_to_codebase.is_empty() {
+      html.push_str(&format!(
+        "<p>Code base: <code>{}</code></p>\n",
+        escape_html(&self.path_to_codebase)
+      ));
+    }
+    html.push_str(&self.summary_to_html());
+    html.push_str("<h2>Changes</h2>\n");
+    if self.flags.is_empty() {
+      html.push_str("<p>No file was changed.</p>\n");
+    }
+    for (flag, packages) in &self.flags {
+      let number_of_files: usize = packages.values().map(|f| f.len()).sum();
+      html.push_str(&format!(
+        "<details open>\n<summary class=\"flag\">{} ({number_of_files} files)</summary>\n",
+        escape_html(flag)
+      ));
+      for (package, files) in packages {
+        html.push_str(&format!(
+          "<details open>\n<summary class=\"package\">{} ({} files)</summary>\n",
+          escape_html(package),
+          files.len()
+        ));
+        for file in files {
+          html.push_str(&file.to_html());
+        }
+        html.push_str("</details>\n");
+      }
+      html.push_str("</details>\n");
+    }
+    html.push_str("</body>\n</html>\n");
+    html
+  }
+
+  /// Renders the totals of the changes, along with the summary statistics of the run (and of each flag), if any
+  fn summary_to_html(&self) -> String {
+    let mut rows = vec![
+      ("Files modified", self.files_modified.to_string()),
+      ("Lines added", self.lines_added.to_string()),
+      ("Lines removed", self.lines_removed.to_string()),
+    ];
+    if let Some(statistics) = &self.statistics {
+      rows.extend([
+        ("Files scanned", statistics.files_scanned().to_string()),
+        ("Rewrites", statistics.rewrites().to_string()),
+        (
+          "Branches deleted",
+          statistics.branches_deleted().to_string(),
+        ),
+        (
+          "Constants deleted",
+          statistics.constants_deleted().to_string(),
+        ),
+        ("Helpers inlined", statistics.helpers_inlined().to_string()),
+        (
+          "Elapsed time",
+          format!("{:.2}s", statistics.elapsed_seconds()),
+        ),
+      ]);
+    }
+    let mut html = "<h2>Summary</h2>\n<table class=\"summary\">\n".to_string();
+    for (name, value) in rows {
+      html.push_str(&format!("<tr><th>{name}</th><td>{value}</td></tr>\n"));
+    }
+    html.push_str("</table>\n");
+    if let Some(statistics) = self.statistics.as_ref().filter(|s| !s.flags().is_empty()) {
+      html.push_str("<h3>Flags</h3>\n<table class=\"flags\">\n<tr><th>Flag</th><th>Files modified</th><th>Rewrites</th><th>Lines added</th><th>Lines removed</th><th>Branches deleted</th><th>Constants deleted</th><th>Helpers inlined</th></tr>\n");
+      for flag in statistics.flags() {
+        html.push_str(&format!(
+          "<tr><td>{}</td><td>{}</td><td>{}</td><td>{}</td><td>{}</td><td>{}</td><td>{}</td><td>{}</td></tr>\n",
+          escape_html(flag.flag()),
+          flag.files_modified(),
+          flag.rewrites(),
+          flag.lines_added(),
+          flag.lines_removed(),
+          flag.branches_deleted(),
+          flag.constants_deleted(),
+          flag.helpers_inlined()
+        ));
+      }
+      html.push_str("</table>\n");
+    }
+    html
+  }
+}
+
+impl HtmlFile {
+  /// Renders the rewrites of the file, and the before (left) and after (right) views of each of its hunks
+  fn to_html(&self) -> String {
+    let mut html = format!(
+      "<details>\n<summary><code>{}</code> (+{} -{})</summary>\n",
+      escape_html(&self.path),
+      self.lines_added,
+      self.lines_removed
+    );
+    if !self.rewrites.is_empty() {
+      html.push_str("<table class=\"rewrites\">\n<tr><th>Line</th><th>Rule</th><th>Origin</th><th>Inter-procedural</th></tr>\n");
+      for (rule, provenance, line) in &self.rewrites {
+        html.push_str(&format!(
+          "<tr><td>{line}</td><td><code>{}</code></td><td>{}</td><td>{}</td></tr>\n",
+          escape_html(rule),
+          provenance.origin,
+          if provenance.inter_procedural {
+            "yes"
+          } else {
+            "no"
+          }
+        ));
+      }
+      html.push_str("</table>\n");
+    }
+    for hunk in &self.hunks {
+      html.push_str(&format!(
+        "<table class=\"hunk\">\n<tr><th colspan=\"4\">{}</th></tr>\n",
+        escape_html(&hunk.header)
+      ));
+      for row in &hunk.rows {
+        html.push_str(&format!(
+          "<tr>{}{}</tr>\n",
+          cell_to_html(&row.left, row.changed, "deleted"),
+          cell_to_html(&row.right, row.changed, "inserted")
+        ));
+      }
+      html.push_str("</table>\n");
+    }
+    html.push_str("</details>\n");
+    html
+  }
+}
+
+/// Renders the number and the text of a line of a hunk (or an empty cell, e.g. on the right of a deleted line)
+fn cell_to_html(line: &Option<(usize, String)>, changed: bool, class: &str) -> String {
+  match line {
+    Some((number, text)) => format!(
+      "<td class=\"number\">{number}</td><td{}>{}</td>",
+      if changed {
+        format!(" class=\"{class}\"")
+      } else {
+        String::new()
+      },
+      escape_html(text)
+    ),
+    None => "<td class=\"number\"></td><td class=\"empty\"></td>".to_string(),
+  }
+}
+
+/// Escapes the text for the content (or an attribute value) of an HTML element
+fn escape_html(text: &str) -> String {
+  text
+    .replace('&', "&amp;")
+    .replace('<', "&lt;")
+    .replace('>', "&gt;")
+    .replace('"', "&quot;")
+    .replace('\'', "&#39;")
+}
+
+#[cfg(test)]
+#[path = "unit_tests/html_report_test.rs"]
+mod html_report_test;
@@ -0,0 +1,632 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::{BTreeMap, HashMap},
+  ffi::OsStr,
+  fs,
+  io::{BufRead, BufReader, Read, Write},
+  net::TcpListener,
+  panic::{catch_unwind, AssertUnwindSafe},
+  path::{Path, PathBuf},
+  process::Command,
+  sync::{
+    mpsc::{self, TrySendError},
+    Arc, Mutex,
+  },
+  thread,
+  time::Duration,
+};
+
+use itertools::Itertools;
+use log::{debug, info, warn};
+use serde_derive::{Deserialize, Serialize};
+use serde_json::{json, Value};
+use tempdir::TempDir;
+
+use crate::execute_piranha_with_statistics;
+
+use super::{
+  clean::validate_clean_input, flag_file::FlagEntry, piranha_arguments::PiranhaArguments,
+  report::ChangeReport,
+};
+
+/// The maximum size of the body of a request (e.g. of the archive of a code base), larger requests are rejected with `413`
+const MAX_BODY_SIZE: usize = 128 * 1024 * 1024;
+
+/// The timeout of the reads and the writes on a connection, so that a stalled client does not block the server
+const CONNECTION_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// The maximum number of completed jobs kept by the server, the oldest ones are dropped beyond it
+const MAX_COMPLETED_JOBS: usize = 100;
+
+/// The maximum number of jobs waiting for the worker, the jobs submitted beyond it are rejected with `503`
+const MAX_QUEUED_JOBS: usize = 16;
+
+/// The maximum length of the request line and of each header (along with its line terminator), longer ones are rejected
+/// with `414` and `431` respectively
+const MAX_LINE_LENGTH: usize = 8 * 1024;
+
+/// The maximum number of headers of a request, requests with more headers are rejected with `431`
+const MAX_HEADERS: usize = 100;
+
+/// The status of a cleanup job
+#[derive(Serialize, Debug, Clone, Copy, PartialEq, Eq)]
+#[serde(rename_all = "snake_case")]
+enum JobStatus {
+  Queued,
+  Running,
+  Succeeded,
+  Failed,
+}
+
+impl JobStatus {
+  fn is_completed(&self) -> bool {
+    matches!(self, JobStatus::Succeeded | JobStatus::Failed)
+  }
+}
+
+/// A cleanup job submitted to the server (i.e. `POST /jobs`)
+#[derive(Deserialize, Debug, Clone, Default)]
+struct JobRequest {
+  // The code base to clean up, on the host of the server
+  #[serde(default)]
+  path_to_codebase: Option<String>,
+  // The code base to clean up, as a base64 encoded `.tar.gz` archive
+  #[serde(default)]
+  archive: Option<String>,
+  // The stale flags to clean up, as the entries of a flag file (i.e. their `name`, `treated`, `treatment`, `mode` and `paths`)
+  #[serde(default)]
+  flags: Vec<FlagEntry>,
+}
+
+/// A cleanup job, along with its outcome once completed
+#[derive(Debug, Clone)]
+struct Job {
+  status: JobStatus,
+  // The reason why the job failed
+  error: Option<String>,
+  // The unified diff of the rewrites (with the paths relative to the code base)
+  diff: String,
+  // The report of the changes, along with the statistics of the run (see `ChangeReport`)
+  report: Value,
+  files_changed: usize,
+}
+
+/// The outcome of a completed job, i.e. its diff, its report and the number of changed files
+type JobOutcome = (String, Value, usize);
+
+/// The jobs of the server, by id, shared with the worker running them
+type Jobs = Arc<Mutex<HashMap<u64, Job>>>;
+
+/// A request received by the server
+struct Request {
+  method: String,
+  // The path of the request, without its query string
+  path: String,
+  body: Vec<u8>,
+}
+
+/// A response of the server
+#[derive(Debug, Clone, PartialEq, Eq)]
+struct Response {
+  status: u16,
+  content_type: &'static str,
+  body: String,
+}
+
+impl Response {
+  fn json(status: u16, body: &Value) -> Response {
+    Response {
+      status,
+      content_type: "application/json",
+      body: body.to_string(),
+    }
+  }
+
+  fn error(status: u16, message: &str) -> Response {
+    Response::json(status, &json!({ "error": message }))
+  }
+}
+
+/// A (minimal) HTTP server, queuing the cleanup jobs submitted by the clients, and running them one at a time (i.e. `serve`)
+struct HttpServer {
+  jobs: Jobs,
+  next_id: u64,
+  queue: mpsc::SyncSender<(u64, JobRequest)>,
+  // The directory the code bases of the jobs (i.e. their `path_to_codebase`) must be in, if any (see `http_root`)
+  root: Option<PathBuf>,
+}
+
+/// Serves the HTTP API on the `http_address` (e.g. `127.0.0.1:8080`), until the process is interrupted, i.e.
+/// * `POST /jobs` submits a cleanup job, i.e. a JSON object with the `flags` to clean up (as the entries of a flag file)
+///   and the code base, i.e. its `path_to_codebase` on the host of the server or a base64 encoded `.tar.gz` `archive`,
+/// * `GET /jobs/{id}` returns the status of the job (i.e. `queued`, `running`, `succeeded` or `failed`),
+/// * `GET /jobs/{id}/diff` and `GET /jobs/{id}/report` return the unified diff and the report (along with the statistics)
+///   of a succeeded job.
+///
+/// The jobs clean up in dry-run with the rules of the `piranha_arguments` (i.e. the code base is never rewritten).
+/// The `path_to_codebase` of a job must be in the `http_root` of the `piranha_arguments`, the requests are limited to
+/// `MAX_HEADERS` headers of `MAX_LINE_LENGTH` bytes and to bodies of `MAX_BODY_SIZE` bytes, at most `MAX_QUEUED_JOBS`
+/// jobs are queued, and only the latest `MAX_COMPLETED_JOBS` completed jobs are kept. The reads and the writes on a
+/// connection time out after `CONNECTION_TIMEOUT`, so that a slow client does not stall the others.
+pub fn serve_http(piranha_arguments: &PiranhaArguments) -> Result<(), String> {
+  let address = piranha_arguments.http_address();
+  let listener =
+    TcpListener::bind(address).map_err(|e| format!("Could not listen on {address} : {e}"))?;
+  info!("Serving the HTTP API on {address}");
+  let mut server = HttpServer::new(piranha_arguments);
+  for stream in listener.incoming() {
+    let mut stream = match stream {
+      Ok(s) => s,
+      Err(e) => {
+        warn!("Could not accept the connection : {e}");
+        continue;
+      }
+    };
+    if let Err(e) = stream
+      .set_read_timeout(Some(CONNECTION_TIMEOUT))
+      .and_then(|_| stream.set_write_timeout(Some(CONNECTION_TIMEOUT)))
+    {
+      warn!("Could not set the timeouts of the connection : {e}");
+      continue;
+    }
+    let response = match read_request(&mut BufReader::new(&stream)) {
+      Ok(request) => server.handle(&request),
+      Err(response) => response,
+    };
+    if let Err(e) = write_response(&mut stream, &response) {
+      warn!("Could not write the response : {e}");
+    }
+  }
+  Ok(())
+}
+
+impl HttpServer {
+  /// Creates the server, along with the worker running the queued jobs (one at a time) with the `piranha_arguments`
+  fn new(piranha_arguments: &PiranhaArguments) -> HttpServer {
+    let jobs: Jobs = Arc::default();
+    let (queue, queued) = mpsc::sync_channel::<(u64, JobRequest)>(MAX_QUEUED_JOBS);
+    let worker_jobs = Arc::clone(&jobs);
+    let piranha_arguments = piranha_arguments.clone();
+    thread::spawn(move || {
+      for (id, request) in queued {
+        update_job(&worker_jobs, id, |j| j.status = JobStatus::Running);
+        info!("Running the job {id}");
+        let outcome = catch_unwind(AssertUnwindSafe(|| run_job(&piranha_arguments, &request)))
+          .unwrap_or_else(|_| Err("The cleanup failed".to_string()));
+        update_job(&worker_jobs, id, |j| match outcome {
+          Ok((diff, report, files_changed)) => {
+            j.status = JobStatus::Succeeded;
+            j.diff = diff;
+            j.report = report;
+            j.files_changed = files_changed;
+          }
+          Err(e) => {
+            j.status = JobStatus::Failed;
+            j.error = Some(e);
+          }
+        });
+      }
+    });
+    HttpServer {
+      jobs,
+      next_id: 1,
+      queue,
+      root: Some(piranha_arguments.http_root())
+        .filter(|r| !r.is_empty())
+        .map(PathBuf::from),
+    }
+  }
+
+  /// Handles a request of a client
+  fn handle(&mut self, request: &Request) -> Response {
+    debug!("{} {}", request.method, request.path);
+    let segments = request.path.trim_matches('/').split('/').collect_vec();
+    match (request.method.as_str(), segments.as_slice()) {
+      ("GET", ["health"]) => Response::json(200, &json!({ "status": "ok" })),
+      ("POST", ["jobs"]) => self.submit(&request.body),
+      ("GET", ["jobs", id]) => self.with_job(id, |id, job| {
+        let mut status = json!({ "id": id, "status": job.status });
+        if let Some(error) = &job.error {
+          status["error"] = json!(error);
+        }
+        if job.status == JobStatus::Succeeded {
+          status["files_changed"] = json!(job.files_changed);
+        }
+        Response::json(200, &status)
+      }),
+      ("GET", ["jobs", id, "diff"]) => self.with_completed_job(id, |job| Response {
+        status: 200,
+        content_type: "text/x-diff",
+        body: job.diff.to_string(),
+      }),
+      ("GET", ["jobs", id, "report"]) => {
+        self.with_completed_job(id, |job| Response::json(200, &job.report))
+      }
+      _ => Response::error(
+        404,
+        &format!("No such endpoint : {} {}", request.method, request.path),
+      ),
+    }
+  }
+
+  /// Queues the job of the `body` (see `JobRequest`), and returns its id
+  fn submit(&mut self, body: &[u8]) -> Response {
+    let mut request: JobRequest = match serde_json::from_slice(body) {
+      Ok(r) => r,
+      Err(e) => return Response::error(400, &format!("Invalid job : {e}")),
+    };
+    if request.path_to_codebase.is_some() == request.archive.is_some() {
+      return Response::error(
+        400,
+        "Invalid job : either `path_to_codebase` or `archive` is required",
+      );
+    }
+    if request.flags.is_empty() {
+      return Response::error(400, "Invalid job : `flags` is empty");
+    }
+    if let Some(path) = &request.path_to_codebase {
+      match self.resolve_codebase(path) {
+        Ok(path) => request.path_to_codebase = Some(path.to_string_lossy().to_string()),
+        Err(e) => return Response::error(400, &format!("Invalid job : {e}")),
+      }
+    }
+    let id = self.next_id;
+    self.next_id += 1;
+    let mut jobs = self.jobs.lock().unwrap();
+    prune_jobs(&mut jobs, MAX_COMPLETED_JOBS);
+    jobs.insert(
+      id,
+      Job {
+        status: JobStatus::Queued,
+        error: None,
+        diff: String::new(),
+        report: Value::Null,
+        files_changed: 0,
+      },
+    );
+    drop(jobs);
+    match self.queue.try_send((id, request)) {
+      Ok(()) => {}
+      Err(TrySendError::Full(_)) => {
+        self.jobs.lock().unwrap().remove(&id);
+        return Response::error(
+          503,
+          &format!("The server already has {MAX_QUEUED_JOBS} queued jobs, retry later"),
+        );
+      }
+      Err(TrySendError::Disconnected(_)) => update_job(&self.jobs, id, |j| {
+        j.status = JobStatus::Failed;
+        j.error = Some("The worker of the server stopped".to_string());
+      }),
+    }
+    info!("Queued the job {id}");
+    Response::json(202, &json!({ "id": id, "status": JobStatus::Queued }))
+  }
+
+  /// Resolves the `path` of a code base relative to the `root` of the server, and checks that it is in the `root`
+  /// (i.e. once canonicalized, so that neither `..` nor the symbolic links escape it)
+  fn resolve_codebase(&self, path: &str) -> Result<PathBuf, String> {
+    let root = self
+      .root
+      .as_ref()
+      .ok_or("`path_to_codebase` is not allowed, since the server has no `http_root`")?;
+    let root = root
+      .canonicalize()
+      .map_err(|e| format!("Could not read {} : {e}", root.display()))?;
+    let codebase = root
+      .join(path)
+      .canonicalize()
+      .map_err(|_| format!("{path} does not exist"))?;
+    if !codebase.starts_with(&root) {
+      return Err(format!("{path} is not in {}", root.display()));
+    }
+    Ok(codebase)
+  }
+
+  /// Responds with the job of the `id` (if any)
+  fn with_job(&self, id: &str, respond: impl Fn(u64, &Job) -> Response) -> Response {
+    let jobs = self.jobs.lock().unwrap();
+    match id
+      .parse::<u64>()
+      .ok()
+      .and_then(|i| Some((i, jobs.get(&i)?)))
+    {
+      Some((i, job)) => respond(i, job),
+      None => Response::error(404, &format!("No such job : {id}")),
+    }
+  }
+
+  /// Responds with the job of the `id`, if it succeeded
+  fn with_completed_job(&self, id: &str, respond: impl Fn(&Job) -> Response) -> Response {
+    self.with_job(id, |i, job| match job.status {
+      JobStatus::Succeeded => respond(job),
+      JobStatus::Failed => Response::error(
+        409,
+        &format!(
+          "The job {i} failed : {}",
+          job.error.as_deref().unwrap_or_default()
+        ),
+      ),
+      _ => Response::error(409, &format!("The job {i} is not completed yet")),
+    })
+  }
+}
+
+/// Drops the oldest completed jobs (i.e. of the lowest ids), so that at most `max_completed_jobs` of them are kept
+fn prune_jobs(jobs: &mut HashMap<u64, Job>, max_completed_jobs: usize) {
+  let completed = jobs
+    .iter()
+    .filter(|(_, j)| j.status.is_completed())
+    .map(|(id, _)| *id)
+    .sorted()
+    .collect_vec();
+  for id in &completed[..completed.len().saturating_sub(max_completed_jobs)] {
+    jobs.remove(id);
+  }
+}
+
+/// Updates the job of the `id` (if any)
+fn update_job(jobs: &Jobs, id: u64, update: impl FnOnce(&mut Job)) {
+  if let Some(job) = jobs.lock().unwrap().get_mut(&id) {
+    update(job);
+  }
+}
+
+/// Cleans up the flags of the job in dry-run, in its code base or in its archive (extracted to a temporary directory)
+fn run_job(
+  piranha_arguments: &PiranhaArguments, request: &JobRequest,
+) -> Result<JobOutcome, String> {
+  let mut temp_dir = None;
+  let root = match (&request.path_to_codebase, &request.archive) {
+    (Some(path), _) => PathBuf::from(path),
+    (None, Some(archive)) => {
+      let dir = TempDir::new("piranha")
+        .map_err(|e| format!("Could not create a temporary directory : {e}"))?;
+      let codebase = dir.path().join("codebase");
+      extract_archive(&decode_base64(archive)?, dir.path(), &codebase)?;
+      temp_dir = Some(dir);
+      codebase
+    }
+    (None, None) => return Err("Either `path_to_codebase` or `archive` is required".to_string()),
+  };
+  // The paths of the summaries are made relative to the canonicalized root (i.e. as walked)
+  let root = root
+    .canonicalize()
+    .map_err(|e| format!("Could not read {} : {e}", root.display()))?;
+  let arguments = piranha_arguments.for_flags(root.to_str().unwrap_or_default(), &request.flags);
+  // The job is cleaned up in memory, as the files of `clean` (i.e. without their own paths)
+  validate_clean_input(&BTreeMap::new(), &arguments)?;
+  let (summaries, statistics) = execute_piranha_with_statistics(&arguments);
+  let relative_path = |path: &str| {
+    Path::new(path)
+      .strip_prefix(&root)
+      .map(|p| p.to_string_lossy().to_string())
+      .unwrap_or_else(|_| path.to_string())
+  };
+
+  let mut report =
+    serde_json::to_value(ChangeReport::new(&summaries, &arguments).with_statistics(&statistics))
+      .map_err(|e| e.to_string())?;
+  if let Some(files) = report["files"].as_array_mut() {
+    for file in files {
+      if let Some(path) = file["path"].as_str().map(relative_path) {
+        file["path"] = json!(path);
+      }
+    }
+  }
+  let diff = summaries
+    .iter()
+    .map(|s| s.clone().with_path(&relative_path(s.path())))
+    .sorted_by(|a, b| a.path().cmp(b.path()))
+    .map(|s| s.unified_diff())
+    .join("");
+  let files_changed = summaries
+    .iter()
+    .filter(|s| s.content() != s.original_content())
+    .count();
+  if let Some(dir) = temp_dir {
+    _ = dir.close();
+  }
+  Ok((diff, report, files_changed))
+}
+
+/// Extracts the `.tar.gz` archive into the `codebase` directory (the archive is written to the `directory` first).
+/// The archive is rejected unless its entries are regular files and directories within the `codebase` (see `check_archive_entries`).
+fn extract_archive(archive: &[u8], directory: &Path, codebase: &Path) -> Result<(), String> {
+  let path = directory.join("archive.tar.gz");
+  fs::write(&path, archive).map_err(|e| format!("Could not write the archive : {e}"))?;
+  check_archive_entries(
+    &run_tar(&[OsStr::new("-tzf"), path.as_os_str()])?,
+    &run_tar(&[OsStr::new("-tvzf"), path.as_os_str()])?,
+  )?;
+  fs::create_dir_all(codebase).map_err(|e| format!("Could not extract the archive : {e}"))?;
+  run_tar(&[
+    OsStr::new("-xzf"),
+    path.as_os_str(),
+    OsStr::new("--no-same-owner"),
+    OsStr::new("-C"),
+    codebase.as_os_str(),
+  ])
+  .map(|_| ())
+}
+
+/// Runs `tar` with the `arguments`, and returns its output
+fn run_tar(arguments: &[&OsStr]) -> Result<String, String> {
+  let output = Command::new("tar")
+    .args(arguments)
+    .output()
+    .map_err(|e| format!("Could not run tar : {e}"))?;
+  if !output.status.success() {
+    return Err(format!(
+      "Could not extract the archive : {}",
+      String::from_utf8_lossy(&output.stderr).trim()
+    ));
+  }
+  Ok(String::from_utf8_lossy(&output.stdout).to_string())
+}
+
+/// Checks the entries of an archive, i.e. its `names` (as listed by `tar -t`) and their `listing` (by `tar -tv`, whose
+/// first character is the type of the entry), so that its extraction cannot write outside of the directory it is extracted
+/// to, i.e. neither absolute paths, nor `..` components, nor (symbolic or hard) links.
+fn check_archive_entries(names: &str, listing: &str) -> Result<(), String> {
+  let names = names.lines().collect_vec();
+  let types = listing
+    .lines()
+    .map(|l| l.chars().next().unwrap_or_default())
+    .collect_vec();
+  if names.len() != types.len() {
+    return Err("Invalid archive : its entries could not be listed".to_string());
+  }
+  for (name, entry_type) in names.iter().zip(types) {
+    if name.starts_with('/') || name.split('/').any(|c| c == "..") {
+      return Err(format!(
+        "Invalid archive : {name} is outside of the code base"
+      ));
+    }
+    if entry_type != '-' && entry_type != 'd' {
+      return Err(format!(
+        "Invalid archive : {name} is neither a file nor a directory"
+      ));
+    }
+  }
+  Ok(())
+}
+
+/// Decodes the base64 (standard or URL-safe, with or without padding) `encoded` string, ignoring the whitespace
+fn decode_base64(encoded: &str) -> Result<Vec<u8>, String> {
+  let mut bytes = vec![];
+  let (mut buffer, mut bits) = (0u32, 0);
+  for c in encoded.chars().filter(|c| !c.is_whitespace() && *c != '=') {
+    let value = match c {
+      'A'..='Z' => c as u32 - 'A' as u32,
+      'a'..='z' => c as u32 - 'a' as u32 + 26,
+      '0'..='9' => c as u32 - '0' as u32 + 52,
+      '+' | '-' => 62,
+      '/' | '_' => 63,
+      _ => {
+        return Err(format!(
+          "The archive is not base64 encoded (invalid character `{c}`)"
+        ))
+      }
+    };
+    buffer = (buffer << 6) | value;
+    bits += 6;
+    if bits >= 8 {
+      bits -= 8;
+      bytes.push((buffer >> bits) as u8);
+      buffer &= (1 << bits) - 1;
+    }
+  }
+  Ok(bytes)
+}
+
+/// Reads a request, i.e. its request line, its headers (at most `MAX_HEADERS` of them) and its body (of `Content-Length`
+/// bytes, at most `MAX_BODY_SIZE`). Returns the error response (i.e. `400`, `413`, `414` or `431`) if the request cannot be read.
+fn read_request(input: &mut impl BufRead) -> Result<Request, Response> {
+  let bad_request = |e: String| Response::error(400, &e);
+  let request_line =
+    read_line(input)?.ok_or_else(|| Response::error(414, "The request line is too long"))?;
+  let (method, target) = match request_line.split_whitespace().collect_vec().as_slice() {
+    [method, target, ..] => (method.to_string(), target.to_string()),
+    _ => {
+      return Err(bad_request(format!(
+        "Invalid request line : {}",
+        request_line.trim()
+      )))
+    }
+  };
+  let mut content_length = 0;
+  for number_of_headers in 0.. {
+    let line = read_line(input)?
+      .ok_or_else(|| Response::error(431, "A header of the request is too long"))?;
+    let line = line.trim_end();
+    if line.is_empty() {
+      break;
+    }
+    if number_of_headers == MAX_HEADERS {
+      return Err(Response::error(
+        431,
+        &format!("The request has more than {MAX_HEADERS} headers"),
+      ));
+    }
+    if let Some((name, value)) = line.split_once(':') {
+      if name.trim().eq_ignore_ascii_case("Content-Length") {
+        content_length = value
+          .trim()
+          .parse::<usize>()
+          .map_err(|e| bad_request(format!("Invalid Content-Length : {e}")))?;
+      }
+    }
+  }
+  if content_length > MAX_BODY_SIZE {
+    return Err(Response::error(
+      413,
+      &format!("The body is larger than {MAX_BODY_SIZE} bytes"),
+    ));
+  }
+  let mut body = vec![0; content_length];
+  input
+    .read_exact(&mut body)
+    .map_err(|e| bad_request(e.to_string()))?;
+  Ok(Request {
+    method,
+    path: target.split('?').next().unwrap_or_default().to_string(),
+    body,
+  })
+}
+
+/// Reads a line (along with its line terminator) of at most `MAX_LINE_LENGTH` bytes, i.e. `None` if it is longer.
+/// The line is empty at the end of the input.
+fn read_line(input: &mut impl BufRead) -> Result<Option<String>, Response> {
+  let mut line = String::new();
+  (&mut *input)
+    .take(MAX_LINE_LENGTH as u64)
+    .read_line(&mut line)
+    .map_err(|e| Response::error(400, &e.to_string()))?;
+  if line.len() == MAX_LINE_LENGTH && !line.ends_with('\n') {
+    return Ok(None);
+  }
+  Ok(Some(line))
+}
+
+/// Writes the response, closing the connection
+fn write_response(output: &mut impl Write, response: &Response) -> Result<(), String> {
+  let reason = match response.status {
+    200 => "OK",
+    202 => "Accepted",
+    400 => "Bad Request",
+    404 => "Not Found",
+    409 => "Conflict",
+    413 => "Payload Too Large",
+    414 => "URI Too Long",
+    431 => "Request Header Fields Too Large",
+    503 => "Service Unavailable",
+    _ => "Internal Server Error",
+  };
+  write!(
+    output,
+    "HTTP/1.1 {} {reason}\r\nContent-Type: {}\r\nContent-Length: {}\r\nConnection: close\r\n\r\n{}",
+    response.status,
+    response.content_type,
+    response.body.len(),
+    response.body
+  )
+  .and_then(|_| output.flush())
+  .map_err(|e| e.to_string())
+}
+
+#[cfg(test)]
+#[path = "unit_tests/http_test.rs"]
+mod http_test;
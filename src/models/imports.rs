@@ -0,0 +1,184 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::{HashMap, HashSet};
+
+use log::debug;
+use tree_sitter::{Node, Parser, Range};
+
+use super::{
+  edit::Edit, language::SupportedLanguage, matches::Match, source_code_unit::SourceCodeUnit,
+};
+
+/// The name of the (pseudo) rule reported for the deleted imports
+pub(crate) static REMOVE_UNUSED_IMPORTS: &str = "remove_unused_imports";
+
+// Implements instance methods related to removing the imports stranded by the rewrites
+impl SourceCodeUnit {
+  /// Deletes the imports that were referenced in the original content of the file, but are no longer
+  /// referenced after the rewrites (e.g. `fmt` only used inside a deleted branch).
+  /// Blank (`_`) and dot (`.`) imports, and the imports that were not referenced to begin with, are left untouched.
+  /// Currently, only supported for Go.
+  pub(crate) fn perform_remove_unused_imports(&mut self, parser: &mut Parser) {
+    if !*self.piranha_arguments().remove_unused_imports()
+      || *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go
+      || self.rewrites().is_empty()
+    {
+      return;
+    }
+    let original_ast = parser
+      .parse(self.original_content(), None)
+      .expect("Could not parse code");
+    let originally_referenced =
+      get_referenced_packages(original_ast.root_node(), self.original_content());
+
+    // Delete one import at a time, since each deletion invalidates the ranges of the remaining ones
+    while let Some(range) = self.get_stranded_import(&originally_referenced) {
+      let edit = Edit::new(
+        Match::new(
+          self.code()[range.start_byte..range.end_byte].to_string(),
+          range,
+          HashMap::new(),
+        ),
+        String::new(),
+        REMOVE_UNUSED_IMPORTS.to_string(),
+        self.code(),
+      );
+      debug!(
+        "Deleting the unused import {}",
+        edit.p_match().matched_string()
+      );
+      self.rewrites_mut().push(edit.clone());
+      self.apply_edit(&edit, parser);
+    }
+  }
+
+  /// Returns the range of the first import whose package was referenced in the original content,
+  /// but is not referenced in the current content.
+  /// If it is the only import of the declaration, the range of the declaration is returned instead.
+  fn get_stranded_import(&self, originally_referenced: &HashSet<String>) -> Option<Range> {
+    let referenced = get_referenced_packages(self.root_node(), self.code());
+    let root_node = self.root_node();
+    let mut cursor = root_node.walk();
+    let declarations = root_node
+      .named_children(&mut cursor)
+      .filter(|n| n.kind() == "import_declaration");
+    for declaration in declarations {
+      for import_spec in get_import_specs(declaration) {
+        let package_name = match import_spec.child_by_field_name("name") {
+          Some(name) => name.utf8_text(self.code().as_bytes()).unwrap().to_string(),
+          None => get_assumed_package_name(
+            import_spec
+              .child_by_field_name("path")
+              .and_then(|p| p.utf8_text(self.code().as_bytes()).ok())
+              .unwrap_or_default(),
+          ),
+        };
+        if package_name.is_empty()
+          || ["_", "."].contains(&package_name.as_str())
+          || !originally_referenced.contains(&package_name)
+          || referenced.contains(&package_name)
+        {
+          continue;
+        }
+        let is_only_import = import_spec
+          .parent()
+          .filter(|p| p.kind() == "import_spec_list")
+          .map(|p| {
+            let mut list_cursor = p.walk();
+            let count = p
+              .named_children(&mut list_cursor)
+              .filter(|n| n.kind() == "import_spec")
+              .count();
+            count == 1
+          })
+          .unwrap_or(true);
+        return Some(if is_only_import {
+          declaration.range()
+        } else {
+          import_spec.range()
+        });
+      }
+    }
+    None
+  }
+}
+
+/// Returns the import specs of the given import declaration (i.e. `import "fmt"` or `import ( "fmt" ... )`)
+pub(super) fn get_import_specs(declaration: Node) -> Vec<Node> {
+  let mut specs = vec![];
+  let mut cursor = declaration.walk();
+  for child in declaration.named_children(&mut cursor) {
+    if child.kind() == "import_spec" {
+      specs.push(child);
+    } else if child.kind() == "import_spec_list" {
+      let mut list_cursor = child.walk();
+      specs.extend(
+        child
+          .named_children(&mut list_cursor)
+          .filter(|n| n.kind() == "import_spec"),
+      );
+    }
+  }
+  specs
+}
+
+/// Collects the names of the packages referenced in the tree rooted at `node`
+/// i.e. the qualifiers of selector expressions (`fmt.Println`) and qualified types (`http.Request`).
+fn get_referenced_packages(node: Node, code: &str) -> HashSet<String> {
+  let mut referenced = HashSet::new();
+  let mut stack = vec![node];
+  while let Some(current) = stack.pop() {
+    let qualifier = match current.kind() {
+      "selector_expression" => current.child_by_field_name("operand"),
+      "qualified_type" => current.child_by_field_name("package"),
+      _ => None,
+    };
+    if let Some(q) = qualifier.filter(|q| ["identifier", "package_identifier"].contains(&q.kind()))
+    {
+      referenced.insert(q.utf8_text(code.as_bytes()).unwrap().to_string());
+    }
+    let mut cursor = current.walk();
+    stack.extend(current.children(&mut cursor));
+  }
+  referenced
+}
+
+/// Returns the package name assumed for an import path (same heuristic as `goimports`), i.e.
+/// the last element of the path, skipping major version suffixes, dropping the `go-` prefix
+/// and truncating it at the first character that is not valid in an identifier.
+/// e.g. `"github.com/go-redis/redis/v8"` -> `redis`, `"gopkg.in/yaml.v2"` -> `yaml`
+pub(crate) fn get_assumed_package_name(import_path: &str) -> String {
+  let is_major_version =
+    |s: &str| s.len() > 1 && s.starts_with('v') && s[1..].chars().all(|c| c.is_ascii_digit());
+
+  let mut elements = import_path
+    .trim_matches(|c| c == '"' || c == '`')
+    .rsplit('/');
+  let mut name = elements.next().unwrap_or_default();
+  if is_major_version(name) {
+    if let Some(previous) = elements.next() {
+      name = previous;
+    }
+  }
+  name
+    .strip_prefix("go-")
+    .unwrap_or(name)
+    .chars()
+    .take_while(|c| c.is_alphanumeric() || *c == '_')
+    .collect()
+}
+
+#[cfg(test)]
+#[path = "unit_tests/imports_test.rs"]
+mod imports_test;
@@ -0,0 +1,202 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  fs,
+  io::{BufRead, Write},
+  process::Command,
+};
+
+use colored::Colorize;
+use log::{debug, warn};
+use tempdir::TempDir;
+
+use super::source_code_unit::SourceCodeUnit;
+use crate::utilities::{get_hunks, Hunk};
+
+/// The number of lines of context printed around each hunk
+static CONTEXT_LINES: usize = 3;
+static PROMPT: &str = "Apply this hunk [y,n,e,a,d,q,?]? ";
+static HELP: &str = "y - apply this hunk
+n - do not apply this hunk
+e - manually edit the proposed code of this hunk
+a - apply this hunk and all the later hunks of the file
+d - do not apply this hunk nor any of the later hunks of the file
+q - quit, i.e. do not apply this hunk nor any of the remaining ones
+? - print help
+";
+
+/// The decision of the reviewer for a hunk
+enum Decision {
+  Apply(String),
+  Skip,
+  ApplyRest,
+  SkipRest,
+  Quit,
+}
+
+// Implements instance methods related to reviewing the rewrites interactively (i.e. `interactive`)
+impl SourceCodeUnit {
+  /// Walks the reviewer through the hunks of the rewrites of this file (like `git add -p`), reading the decisions from the `input`.
+  /// Each hunk can be applied, skipped, or edited (with the `editor` command) before it is applied.
+  /// The content of the file is replaced with the applied hunks only, and the rewrites are discarded if none is applied.
+  /// Returns true if the reviewer quit, i.e. the remaining files should not be rewritten either.
+  pub(crate) fn review_rewrites(
+    &mut self, input: &mut impl BufRead, output: &mut impl Write, editor: &str,
+  ) -> bool {
+    if self.code() == self.original_content() {
+      return false;
+    }
+    let original_content = self.original_content().to_string();
+    let hunks = get_hunks(&original_content, self.code());
+    let mut reviewed_content = String::new();
+    let mut position = 0;
+    let (mut apply_rest, mut skip_rest, mut quit) = (false, false, false);
+    let mut applied = 0;
+    for (index, hunk) in hunks.iter().enumerate() {
+      let text = if apply_rest {
+        Some(hunk.text.to_string())
+      } else if skip_rest || quit {
+        None
+      } else {
+        write_hunk(
+          output,
+          &self.path().to_string_lossy(),
+          &original_content,
+          hunk,
+          index,
+          hunks.len(),
+        );
+        match read_decision(input, output, hunk, editor) {
+          Decision::Apply(text) => Some(text),
+          Decision::Skip => None,
+          Decision::ApplyRest => {
+            apply_rest = true;
+            Some(hunk.text.to_string())
+          }
+          Decision::SkipRest => {
+            skip_rest = true;
+            None
+          }
+          Decision::Quit => {
+            quit = true;
+            None
+          }
+        }
+      };
+      reviewed_content.push_str(&original_content[position..hunk.start]);
+      match text {
+        Some(text) => {
+          reviewed_content.push_str(&text);
+          applied += 1;
+        }
+        None => reviewed_content.push_str(&original_content[hunk.start..hunk.end]),
+      }
+      position = hunk.end;
+    }
+    reviewed_content.push_str(&original_content[position..]);
+    debug!(
+      "Applied {applied} of the {} hunks of {:?}",
+      hunks.len(),
+      self.path()
+    );
+    if applied == 0 {
+      self.discard_rewrites();
+    } else {
+      let mut parser = self.piranha_arguments().language().parser();
+      self._replace_file_contents_and_re_parse(&reviewed_content, &mut parser, false);
+    }
+    quit
+  }
+}
+
+/// Prints the hunk (i.e. the original and the proposed code), along with its context
+fn write_hunk(
+  output: &mut impl Write, path: &str, original_content: &str, hunk: &Hunk, index: usize,
+  number_of_hunks: usize,
+) {
+  let lines: Vec<&str> = original_content.lines().collect();
+  let start_line = original_content[..hunk.start].matches('\n').count();
+  let end_line = original_content[..hunk.end].matches('\n').count();
+  let mut text = format!(
+    "{}\n",
+    format!(
+      "{path} (line {}, hunk {}/{number_of_hunks})",
+      start_line + 1,
+      index + 1
+    )
+    .bold()
+  );
+  for line in &lines[start_line.saturating_sub(CONTEXT_LINES)..start_line] {
+    text.push_str(&format!(" {line}\n"));
+  }
+  for line in original_content[hunk.start..hunk.end].lines() {
+    text.push_str(&format!("{}\n", format!("-{line}").red()));
+  }
+  for line in hunk.text.lines() {
+    text.push_str(&format!("{}\n", format!("+{line}").green()));
+  }
+  for line in &lines[end_line.min(lines.len())..(end_line + CONTEXT_LINES).min(lines.len())] {
+    text.push_str(&format!(" {line}\n"));
+  }
+  _ = output.write_all(text.as_bytes());
+}
+
+/// Prompts the reviewer until a valid decision is read. The end of the input is read as `q`.
+fn read_decision(
+  input: &mut impl BufRead, output: &mut impl Write, hunk: &Hunk, editor: &str,
+) -> Decision {
+  loop {
+    _ = output.write_all(PROMPT.blue().bold().to_string().as_bytes());
+    _ = output.flush();
+    let mut answer = String::new();
+    if input.read_line(&mut answer).unwrap_or(0) == 0 {
+      return Decision::Quit;
+    }
+    match answer.trim() {
+      "y" => return Decision::Apply(hunk.text.to_string()),
+      "n" => return Decision::Skip,
+      "a" => return Decision::ApplyRest,
+      "d" => return Decision::SkipRest,
+      "q" => return Decision::Quit,
+      "e" => match edit_hunk(&hunk.text, editor) {
+        Ok(text) => return Decision::Apply(text),
+        Err(e) => warn!("Could not edit the hunk : {e}"),
+      },
+      _ => {
+        _ = output.write_all(HELP.as_bytes());
+      }
+    }
+  }
+}
+
+/// Opens the proposed code of the hunk with the `editor` command (run with `sh -c`), and returns the edited code
+fn edit_hunk(text: &str, editor: &str) -> Result<String, String> {
+  let temp_dir = TempDir::new("piranha_hunk").map_err(|e| e.to_string())?;
+  let path = temp_dir.path().join("hunk");
+  fs::write(&path, text).map_err(|e| e.to_string())?;
+  let status = Command::new("sh")
+    .arg("-c")
+    .arg(format!("{editor} \"$0\""))
+    .arg(&path)
+    .status()
+    .map_err(|e| e.to_string())?;
+  if !status.success() {
+    return Err(format!("`{editor}` exited with {status}"));
+  }
+  fs::read_to_string(&path).map_err(|e| e.to_string())
+}
+
+#[cfg(test)]
+#[path = "unit_tests/interactive_review_test.rs"]
+mod interactive_review_test;
@@ -0,0 +1,388 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::{HashMap, HashSet},
+  fmt,
+  path::Path,
+};
+
+use getset::Getters;
+use itertools::Itertools;
+use regex::Regex;
+use serde_derive::Serialize;
+use tree_sitter::{Node, Query};
+
+use super::{
+  changed_files::{get_directory, get_top_level},
+  code_owners::CodeOwners,
+  dynamic_flag_names::STALE_FLAG_NAME,
+  piranha_arguments::PiranhaArguments,
+  rule::InstantiatedRule,
+  rule_store::RuleStore,
+  source_code_unit::SourceCodeUnit,
+  split::get_relative_path,
+};
+use crate::utilities::tree_sitter_utilities::{get_all_matches_for_query, get_node_for_range};
+
+/// The prefix of the values substituted for the holes of the seed rules (see `placeholder`)
+static PLACEHOLDER_PREFIX: &str = "__piranha_inventory_";
+/// The kinds of the nodes evaluating to the value of the call site they contain (e.g. `(exp.IsEnabled(ctx, "flag"))`)
+static TRANSPARENT_KINDS: [&str; 3] = [
+  "parenthesized_expression",
+  "expression_list",
+  "literal_element",
+];
+/// The kinds of the nodes checking the value of the call site (e.g. `if exp.IsEnabled(ctx, "flag") { ... }`)
+static CHECK_KINDS: [&str; 16] = [
+  "if_statement",
+  "if_expression",
+  "guard_statement",
+  "while_statement",
+  "for_statement",
+  "do_statement",
+  "expression_switch_statement",
+  "switch_statement",
+  "binary_expression",
+  "unary_expression",
+  "prefix_expression",
+  "ternary_expression",
+  "conditional_expression",
+  "boolean_operator",
+  "not_operator",
+  "comparison_operator",
+];
+/// The kinds of the nodes assigning the value of the call site (e.g. `enabled := exp.IsEnabled(ctx, "flag")`)
+static ASSIGNMENT_KINDS: [&str; 10] = [
+  "assignment_statement",
+  "short_var_declaration",
+  "var_spec",
+  "const_spec",
+  "keyed_element",
+  "variable_declarator",
+  "assignment_expression",
+  "assignment",
+  "property_declaration",
+  "pair",
+];
+/// The kinds of the nodes passing the value of the call site through (e.g. `render(exp.IsEnabled(ctx, "flag"))`)
+static PASS_THROUGH_KINDS: [&str; 7] = [
+  "argument_list",
+  "arguments",
+  "value_argument",
+  "value_arguments",
+  "return_statement",
+  "jump_expression",
+  "control_transfer_statement",
+];
+/// The kinds of the (named) function declarations
+static FUNCTION_KINDS: [&str; 5] = [
+  "function_declaration",
+  "method_declaration",
+  "constructor_declaration",
+  "function_definition",
+  "method_definition",
+];
+
+/// How the value of a call site of a flag API is used
+#[derive(Serialize, Debug, Clone, Copy, PartialEq, Eq, Hash)]
+#[serde(rename_all = "snake_case")]
+pub enum Usage {
+  // The value is checked, e.g. by a condition or an operator
+  Check,
+  // The value is assigned, e.g. to a variable or a field
+  Assignment,
+  // The value is passed through, i.e. as an argument or the returned value
+  PassThrough,
+  Other,
+}
+
+impl fmt::Display for Usage {
+  fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+    let usage = match self {
+      Usage::Check => "direct check",
+      Usage::Assignment => "assignment",
+      Usage::PassThrough => "pass-through",
+      Usage::Other => "other",
+    };
+    write!(f, "{usage}")
+  }
+}
+
+/// A call site of a flag API, i.e. a match of a seed rule for any flag name (see `piranha report`)
+#[derive(Serialize, Debug, Clone, PartialEq, Eq, Hash, Getters)]
+pub struct FlagSite {
+  // The name of the flag
+  #[get = "pub"]
+  flag: String,
+  // The path of the file, relative to the root of the repository
+  #[get = "pub"]
+  path: String,
+  // The (1-based) line and column of the call site
+  #[get = "pub"]
+  line: usize,
+  #[get = "pub"]
+  column: usize,
+  // The function enclosing the call site (empty at the top level)
+  #[get = "pub"]
+  function: String,
+  // The API called, e.g. `exp.IsEnabled`
+  #[get = "pub"]
+  api: String,
+  #[get = "pub"]
+  usage: Usage,
+  // The seed rule matching the call site
+  #[get = "pub"]
+  rule: String,
+  // The owners of the file (see `CODEOWNERS` and `OWNERS`), only listed by the inventory
+  #[serde(skip_serializing_if = "Vec::is_empty")]
+  #[get = "pub"]
+  owners: Vec<String>,
+}
+
+impl fmt::Display for FlagSite {
+  /// Formats the call site like a compiler diagnostic, i.e. `path:line:column: message`
+  fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+    let function = if self.function.is_empty() {
+      "at the top level".to_string()
+    } else {
+      format!("in {}", self.function)
+    };
+    write!(
+      f,
+      "{}:{}:{}: `{}` - {} {function} ({})",
+      self.path, self.line, self.column, self.flag, self.api, self.usage
+    )?;
+    if !self.owners.is_empty() {
+      write!(f, " - owners: {}", self.owners.join(" "))?;
+    }
+    Ok(())
+  }
+}
+
+/// Returns the call sites of the flag APIs for the stale flags (i.e. the `stale_flag_name` of the arguments and of their `flags`),
+/// or for all the flags when none is given (i.e. `piranha report`), sorted by path and position, along with the owners of their files.
+/// The paths are relative to the root of the git repository containing the code base (or to the code base, outside of a git repository).
+pub fn get_flag_inventory(piranha_arguments: &PiranhaArguments) -> Vec<FlagSite> {
+  let directory = get_directory(piranha_arguments.path_to_codebase());
+  let root = get_top_level(directory).unwrap_or_else(|_| directory.to_path_buf());
+  let flags: HashSet<String> = std::iter::once(piranha_arguments)
+    .chain(piranha_arguments.flag_arguments())
+    .filter_map(|a| a.input_substitutions().get(STALE_FLAG_NAME).cloned())
+    .collect();
+  let code_owners = CodeOwners::read(&root);
+  get_flag_sites(piranha_arguments, &root)
+    .into_iter()
+    .filter(|s| flags.is_empty() || flags.contains(&s.flag))
+    .map(|s| FlagSite {
+      owners: code_owners.get_owners(&s.path),
+      ..s
+    })
+    .collect()
+}
+
+/// Returns the call sites of the flag APIs in the files of the code base, with their paths relative to `root`.
+/// The seed rules are instantiated without the predicate comparing their capture with the `stale_flag_name`, which
+/// captures the name of the flag instead. The other seed rules (i.e. requiring other substitutions) are skipped.
+pub(crate) fn get_flag_sites(piranha_arguments: &PiranhaArguments, root: &Path) -> Vec<FlagSite> {
+  let flag_placeholder = placeholder(STALE_FLAG_NAME);
+  let mut substitutions = piranha_arguments.input_substitutions();
+  substitutions.insert(STALE_FLAG_NAME.to_string(), flag_placeholder.to_string());
+  let seed_rules = piranha_arguments
+    .rule_graph()
+    .rules()
+    .iter()
+    .filter(|r| *r.is_seed_rule())
+    .map(|r| {
+      let mut substitutions = substitutions.clone();
+      for hole in r.holes() {
+        substitutions
+          .entry(hole.to_string())
+          .or_insert_with(|| placeholder(hole));
+      }
+      InstantiatedRule::new(r, &substitutions)
+    })
+    .collect_vec();
+
+  let mut parser = piranha_arguments.language().parser();
+  let mut queries: HashMap<String, Option<(Query, String)>> = HashMap::new();
+  let mut sites = HashSet::new();
+  for (path, content) in RuleStore::without_rules(piranha_arguments).get_files(
+    piranha_arguments.path_to_codebase(),
+    piranha_arguments.include(),
+    piranha_arguments.exclude(),
+  ) {
+    let source_code_unit = SourceCodeUnit::new(
+      &mut parser,
+      content,
+      &HashMap::new(),
+      path.as_path(),
+      piranha_arguments,
+    );
+    let relative_path = match get_relative_path(root, &path.to_string_lossy()) {
+      Some(p) => p,
+      None => continue,
+    };
+    for rule in &seed_rules {
+      let query = source_code_unit.resolve_package_aliases(rule).get_query();
+      let compiled = queries.entry(query.to_string()).or_insert_with(|| {
+        let (query, capture) = remove_flag_predicate(&query, &flag_placeholder)?;
+        // The rules comparing other captures with the flag (or with other substitutions) are not API calls
+        if query.contains(PLACEHOLDER_PREFIX) {
+          return None;
+        }
+        Query::new(*piranha_arguments.language().language(), &query)
+          .ok()
+          .map(|q| (q, capture))
+      });
+      let (query, capture) = match compiled {
+        Some(c) => c,
+        None => continue,
+      };
+      for p_match in get_all_matches_for_query(
+        &source_code_unit.root_node(),
+        source_code_unit.code().to_string(),
+        query,
+        true,
+        None,
+      ) {
+        if let Some(flag) = p_match.matches().get(capture.as_str()) {
+          let range = p_match.range();
+          let node = get_node_for_range(
+            source_code_unit.root_node(),
+            range.start_byte,
+            range.end_byte,
+          );
+          sites.insert(FlagSite {
+            flag: flag
+              .trim_matches(|c| c == '"' || c == '\'' || c == '`')
+              .to_string(),
+            path: relative_path.to_string(),
+            line: range.start_point.row + 1,
+            column: range.start_point.column + 1,
+            function: source_code_unit.get_enclosing_function(node),
+            api: get_api(p_match.matched_string()),
+            usage: get_usage(node),
+            rule: rule.name(),
+            owners: vec![],
+          });
+        }
+      }
+    }
+  }
+  // Each call site is reported once, even when matched by several seed rules
+  sites
+    .into_iter()
+    .sorted_by(|a, b| {
+      (&a.path, a.line, a.column, &a.rule).cmp(&(&b.path, b.line, b.column, &b.rule))
+    })
+    .unique_by(|s| (s.flag.to_string(), s.path.to_string(), s.line))
+    .collect()
+}
+
+// Implements instance methods related to the inventory of the call sites of the flag APIs
+impl SourceCodeUnit {
+  /// Returns the name of the (named) function declaration enclosing the node (e.g. `Server.search` for a Go method),
+  /// or an empty string at the top level. The anonymous functions are skipped, i.e. the function declaring them is returned.
+  fn get_enclosing_function(&self, node: Node) -> String {
+    let mut current = node.parent();
+    while let Some(n) = current {
+      if FUNCTION_KINDS.contains(&n.kind()) {
+        let name = n.child_by_field_name("name").or_else(|| {
+          let mut cursor = n.walk();
+          let name = n
+            .named_children(&mut cursor)
+            .find(|c| c.kind().ends_with("identifier"));
+          name
+        });
+        let name = self.text_of(name);
+        // The methods of Go are qualified with the type of their receiver, e.g. `(s *Server)`
+        let receiver = n
+          .child_by_field_name("receiver")
+          .and_then(|r| r.named_child(0))
+          .and_then(|p| p.child_by_field_name("type"));
+        return match receiver {
+          Some(r) => format!("{}.{name}", self.text_of(Some(r)).trim_start_matches('*')),
+          None => name,
+        };
+      }
+      current = n.parent();
+    }
+    String::new()
+  }
+}
+
+/// Returns the usage of the value of the call site, according to the node it is part of (e.g. an `if_statement` checks it)
+fn get_usage(node: Node) -> Usage {
+  let mut current = node.parent();
+  while let Some(n) = current {
+    let kind = n.kind();
+    if TRANSPARENT_KINDS.contains(&kind) {
+      current = n.parent();
+    } else if CHECK_KINDS.contains(&kind) {
+      return Usage::Check;
+    } else if ASSIGNMENT_KINDS.contains(&kind) {
+      return Usage::Assignment;
+    } else if PASS_THROUGH_KINDS.contains(&kind) {
+      return Usage::PassThrough;
+    } else {
+      break;
+    }
+  }
+  Usage::Other
+}
+
+/// Returns the API called by the code of the call site, i.e. the code before its (last) arguments without whitespace
+/// (e.g. `exp.IsEnabled` or `client.experiments().isEnabled`)
+fn get_api(code: &str) -> String {
+  let code = code.trim();
+  let mut end = code.len();
+  if code.ends_with(')') {
+    let mut depth = 0;
+    for (i, c) in code.char_indices().rev() {
+      match c {
+        ')' => depth += 1,
+        '(' => {
+          depth -= 1;
+          if depth == 0 {
+            end = i;
+            break;
+          }
+        }
+        _ => {}
+      }
+    }
+  }
+  code[..end].split_whitespace().join("")
+}
+
+/// Returns the value substituted for the hole when instantiating the seed rules for the inventory
+fn placeholder(hole: &str) -> String {
+  format!("{PLACEHOLDER_PREFIX}{hole}__")
+}
+
+/// Removes the predicate comparing a capture of the query with the placeholder of the flag name
+/// (e.g. `(#eq? @flag_name "\"__piranha_inventory_stale_flag_name__\"")`), and returns the query along with the capture
+fn remove_flag_predicate(query: &str, flag_placeholder: &str) -> Option<(String, String)> {
+  let predicate = Regex::new(&format!(
+    r#"\(#eq\?\s+@(\w+)\s+"(?:\\")?{}(?:\\")?"\s*\)"#,
+    regex::escape(flag_placeholder)
+  ))
+  .unwrap();
+  let capture = predicate.captures(query)?[1].to_string();
+  Some((predicate.replace_all(query, "").into_owned(), capture))
+}
+
+#[cfg(test)]
+#[path = "unit_tests/inventory_test.rs"]
+mod inventory_test;
@@ -0,0 +1,75 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use log::warn;
+use tree_sitter::Node;
+
+use super::{
+  language::SupportedLanguage, rule::InstantiatedRule, source_code_unit::SourceCodeUnit,
+};
+
+// Implements instance methods related to preserving the values of the constants declared with `iota`
+impl SourceCodeUnit {
+  /// Checks if applying the `rule` to the `matched_node` deletes a constant that is followed by other constants,
+  /// in a `const ( ... )` block using `iota` (e.g. deleting `B` from `const ( A = iota; B; C )` changes `C` from 2 to 1).
+  /// Such a deletion would silently shift the values of the subsequent constants, hence it is reported instead.
+  /// Currently, only supported for Go.
+  pub(crate) fn shifts_iota_values(&self, matched_node: Node, rule: &InstantiatedRule) -> bool {
+    if *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go
+      || rule.rule().is_match_only_rule()
+      || rule.rule().is_dummy_rule()
+      || !rule.replace().is_empty()
+      || matched_node.kind() != "const_spec"
+    {
+      return false;
+    }
+    let declaration = match matched_node.parent() {
+      Some(parent) if parent.kind() == "const_declaration" => parent,
+      _ => return false,
+    };
+    let mut sibling = matched_node.next_named_sibling();
+    while let Some(s) = sibling {
+      if s.kind() == "const_spec" {
+        if !contains_iota(declaration) {
+          return false;
+        }
+        warn!(
+          "{:?}: Skipping the deletion of `{}` by the rule `{}`, since it would shift the values of the subsequent constants of the `iota` block",
+          self.path(),
+          matched_node.utf8_text(self.code().as_bytes()).unwrap_or_default(),
+          rule.name()
+        );
+        return true;
+      }
+      sibling = s.next_named_sibling();
+    }
+    false
+  }
+}
+
+/// Checks if `iota` is used in the tree rooted at `node`
+fn contains_iota(node: Node) -> bool {
+  let mut stack = vec![node];
+  while let Some(current) = stack.pop() {
+    if current.kind() == "iota" {
+      return true;
+    }
+    let mut cursor = current.walk();
+    stack.extend(current.children(&mut cursor));
+  }
+  false
+}
+
+#[cfg(test)]
+#[path = "unit_tests/iota_test.rs"]
+mod iota_test;
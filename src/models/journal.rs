@@ -0,0 +1,281 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{fs, path::Path};
+
+use getset::Getters;
+use log::debug;
+use serde_derive::{Deserialize, Serialize};
+
+use crate::utilities::get_hunks;
+
+use super::{
+  persist::write_atomically, piranha_arguments::PiranhaArguments,
+  piranha_output::PiranhaOutputSummary,
+};
+
+/// The number of lines around each edit recorded in the journal, i.e. to find its region again after unrelated edits
+static CONTEXT_LINES: usize = 3;
+
+/// The journal of the edits of the runs of Piranha (i.e. `path_to_journal`), in the order they were applied
+#[derive(Serialize, Deserialize, Debug, Clone, Default, PartialEq, Eq)]
+pub(crate) struct Journal {
+  runs: Vec<JournalRun>,
+}
+
+/// The edits of a run, i.e. of each rewritten file
+#[derive(Serialize, Deserialize, Debug, Clone, Default, PartialEq, Eq)]
+pub(crate) struct JournalRun {
+  files: Vec<JournalFile>,
+}
+
+/// The edits of a rewritten file, i.e. the hunks of its diff
+#[derive(Serialize, Deserialize, Debug, Clone, Default, PartialEq, Eq)]
+pub(crate) struct JournalFile {
+  // The (absolute) path of the file
+  path: String,
+  // The content of the file before the cleanup, if it was deleted (i.e. `delete_file_if_empty`)
+  #[serde(default, skip_serializing_if = "Option::is_none")]
+  deleted_content: Option<String>,
+  // The edits of the file, sorted by their range
+  #[serde(default)]
+  edits: Vec<JournalEdit>,
+}
+
+/// An edit of a file, i.e. the bytes `start..end` of the original content (`original_text`) replaced with `text`
+#[derive(Serialize, Deserialize, Debug, Clone, Default, PartialEq, Eq)]
+pub(crate) struct JournalEdit {
+  start: usize,
+  end: usize,
+  original_text: String,
+  text: String,
+  // The lines before the edit (in the rewritten content), and after it (in the original content)
+  before: String,
+  after: String,
+}
+
+/// The outcome of reverting the journal (i.e. `revert`)
+#[derive(Debug, Clone, Default, PartialEq, Eq, Getters)]
+pub struct RevertOutcome {
+  /// The number of edits reverted (the ones already reverted are not counted)
+  #[get = "pub"]
+  reverted_edits: usize,
+  /// The edits that could not be reverted, since their region was changed after the cleanup (i.e. `path:line: reason`)
+  #[get = "pub"]
+  conflicts: Vec<String>,
+}
+
+impl JournalFile {
+  /// Returns the edits of the rewritten file, or `None` if its content is unchanged
+  fn new(summary: &PiranhaOutputSummary) -> Option<JournalFile> {
+    if summary.content() == summary.original_content() {
+      return None;
+    }
+    let path = get_absolute_path(summary.path());
+    if !Path::new(&path).exists() {
+      return Some(JournalFile {
+        path,
+        deleted_content: Some(summary.original_content().to_string()),
+        edits: vec![],
+      });
+    }
+    let (original_content, content) = (summary.original_content(), summary.content());
+    let mut delta: isize = 0;
+    let mut edits = vec![];
+    for hunk in get_hunks(original_content, content) {
+      let start = (hunk.start as isize + delta) as usize;
+      delta += hunk.text.len() as isize - (hunk.end - hunk.start) as isize;
+      edits.push(JournalEdit {
+        start: hunk.start,
+        end: hunk.end,
+        original_text: original_content[hunk.start..hunk.end].to_string(),
+        before: get_last_lines(&content[..start], CONTEXT_LINES),
+        after: get_first_lines(&original_content[hunk.end..], CONTEXT_LINES),
+        text: hunk.text,
+      });
+    }
+    Some(JournalFile {
+      path,
+      deleted_content: None,
+      edits,
+    })
+  }
+
+  /// Reverts the edits of the file (in reverse order), as long as their region (and its context) is unchanged.
+  /// The edits whose region is found in its original state are already reverted, and skipped.
+  fn revert(&self, outcome: &mut RevertOutcome) -> Result<(), String> {
+    if let Some(deleted_content) = &self.deleted_content {
+      match fs::read_to_string(&self.path) {
+        Err(_) => {
+          write_atomically(Path::new(&self.path), deleted_content)
+            .map_err(|e| format!("Could not restore {} : {e}", self.path))?;
+          outcome.reverted_edits += 1;
+        }
+        Ok(content) if content == *deleted_content => (),
+        Ok(_) => outcome.conflicts.push(format!(
+          "{}:1: the deleted file was created again",
+          self.path
+        )),
+      }
+      return Ok(());
+    }
+    let mut content = match fs::read_to_string(&self.path) {
+      Ok(content) => content,
+      Err(_) => {
+        outcome
+          .conflicts
+          .push(format!("{}:1: the file no longer exists", self.path));
+        return Ok(());
+      }
+    };
+    // The (expected) start of each edit in the rewritten content, i.e. shifted by the previous edits
+    let mut delta: isize = 0;
+    let starts: Vec<usize> = self
+      .edits
+      .iter()
+      .map(|e| {
+        let start = (e.start as isize + delta) as usize;
+        delta += e.text.len() as isize - (e.end - e.start) as isize;
+        start
+      })
+      .collect();
+    let mut reverted_edits = 0;
+    for (edit, start) in self.edits.iter().zip(starts).rev() {
+      let rewritten = format!("{}{}{}", edit.before, edit.text, edit.after);
+      let original = format!("{}{}{}", edit.before, edit.original_text, edit.after);
+      let expected = start.saturating_sub(edit.before.len());
+      if let Some(position) = find_nearest(&content, &rewritten, expected) {
+        content.replace_range(position..position + rewritten.len(), &original);
+        reverted_edits += 1;
+      } else if find_nearest(&content, &original, expected).is_none() {
+        let line = content[..expected.min(content.len())].matches('\n').count() + 1;
+        outcome.conflicts.push(format!(
+          "{}:{line}: the region of the edit was changed after the cleanup",
+          self.path
+        ));
+      }
+    }
+    if reverted_edits > 0 {
+      write_atomically(Path::new(&self.path), &content)
+        .map_err(|e| format!("Could not write {} : {e}", self.path))?;
+      debug!("Reverted {reverted_edits} edits of {}", self.path);
+    }
+    outcome.reverted_edits += reverted_edits;
+    Ok(())
+  }
+}
+
+/// Appends the edits of the rewritten files of the run to the journal at `path_to_journal` (i.e. created if it does not exist)
+pub fn record_journal(
+  summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+) -> Result<(), String> {
+  let path_to_journal = piranha_arguments
+    .path_to_journal()
+    .clone()
+    .unwrap_or_default();
+  let mut journal = if Path::new(&path_to_journal).exists() {
+    read_journal(&path_to_journal)?
+  } else {
+    Journal::default()
+  };
+  let mut files: Vec<JournalFile> = summaries.iter().filter_map(JournalFile::new).collect();
+  files.sort_by(|a, b| a.path.cmp(&b.path));
+  if files.is_empty() {
+    return Ok(());
+  }
+  journal.runs.push(JournalRun { files });
+  let content = serde_json::to_string_pretty(&journal).map_err(|e| e.to_string())?;
+  fs::write(&path_to_journal, content)
+    .map_err(|e| format!("Could not write the journal {path_to_journal} : {e}"))
+}
+
+/// Reverts the edits recorded in the journal at `path_to_journal` for the files under the code base (i.e. `revert`),
+/// the last run first, restoring the content of the files before the cleanup even after other (unrelated) edits.
+/// The edits whose region was changed after the cleanup are not reverted, and reported as conflicts.
+/// Reverting the journal again has no effect, since the edits already reverted are skipped.
+pub fn revert_journal(piranha_arguments: &PiranhaArguments) -> Result<RevertOutcome, String> {
+  let journal = read_journal(
+    &piranha_arguments
+      .path_to_journal()
+      .clone()
+      .unwrap_or_default(),
+  )?;
+  let codebase = get_absolute_path(piranha_arguments.path_to_codebase());
+  let mut outcome = RevertOutcome::default();
+  for run in journal.runs.iter().rev() {
+    for file in run
+      .files
+      .iter()
+      .filter(|f| Path::new(&f.path).starts_with(&codebase))
+    {
+      file.revert(&mut outcome)?;
+    }
+  }
+  Ok(outcome)
+}
+
+/// Reads the journal at `path_to_journal`
+pub(crate) fn read_journal(path_to_journal: &str) -> Result<Journal, String> {
+  let content = fs::read_to_string(path_to_journal)
+    .map_err(|e| format!("Could not read the journal {path_to_journal} : {e}"))?;
+  serde_json::from_str(&content)
+    .map_err(|e| format!("Could not parse the journal {path_to_journal} : {e}"))
+}
+
+/// Returns the occurrence of the `text` in the `content` nearest to the `expected` position, if any.
+/// An empty `text` only occurs in an empty `content`.
+fn find_nearest(content: &str, text: &str, expected: usize) -> Option<usize> {
+  if text.is_empty() {
+    return content.is_empty().then_some(0);
+  }
+  content
+    .match_indices(text)
+    .map(|(i, _)| i)
+    .min_by_key(|i| i.abs_diff(expected))
+}
+
+/// Returns the last `n` lines of the text
+fn get_last_lines(text: &str, n: usize) -> String {
+  let lines: Vec<&str> = text.split_inclusive('\n').collect();
+  lines[lines.len().saturating_sub(n)..].concat()
+}
+
+/// Returns the first `n` lines of the text
+fn get_first_lines(text: &str, n: usize) -> String {
+  text.split_inclusive('\n').take(n).collect()
+}
+
+/// Returns the absolute path of the file (resolving its directory, since the file may have been deleted)
+fn get_absolute_path(path: &str) -> String {
+  let path = Path::new(path);
+  match (path.parent(), path.file_name()) {
+    (Some(directory), Some(name)) => {
+      let directory = if directory.as_os_str().is_empty() {
+        Path::new(".")
+      } else {
+        directory
+      };
+      directory
+        .canonicalize()
+        .map(|d| d.join(name))
+        .unwrap_or_else(|_| path.to_path_buf())
+    }
+    _ => path.canonicalize().unwrap_or_else(|_| path.to_path_buf()),
+  }
+  .to_string_lossy()
+  .to_string()
+}
+
+#[cfg(test)]
+#[path = "unit_tests/journal_test.rs"]
+mod journal_test;
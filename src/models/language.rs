@@ -11,7 +11,7 @@
  limitations under the License.
 */
 
-use std::str::FromStr;
+use std::{collections::HashMap, str::FromStr};
 
 use getset::Getters;
 use serde_derive::Deserialize;
@@ -21,7 +21,8 @@ use crate::utilities::parse_toml;
 
 use super::{
   default_configs::{
-    default_language, GO, JAVA, KOTLIN, PYTHON, STRINGS, SWIFT, THRIFT, TSX, TYPESCRIPT,
+    default_language, FLAGSMITH, GO, JAVA, KOTLIN, LAUNCHDARKLY, OPENFEATURE, PYTHON, STRINGS,
+    SWIFT, THRIFT, TSX, TYPESCRIPT, UNLEASH,
   },
   outgoing_edges::Edges,
   rule::Rules,
@@ -45,6 +46,15 @@ pub struct PiranhaLanguage {
   /// Built-in edges for the language
   #[get = "pub(crate)"]
   edges: Option<Edges>,
+  /// Built-in rules for the second-order dead code elimination (i.e. `aggressive_dead_code`)
+  #[get = "pub(crate)"]
+  aggressive_rules: Option<Rules>,
+  /// Built-in edges for the second-order dead code elimination (i.e. `aggressive_dead_code`)
+  #[get = "pub(crate)"]
+  aggressive_edges: Option<Edges>,
+  /// Built-in rules and edges of the flag SDKs (i.e. `rule_packs`), keyed by the name of the rule pack
+  #[get = "pub(crate)"]
+  rule_packs: HashMap<String, (Rules, Edges)>,
   /// Scope configurations for the language
   #[get = "pub(crate)"]
   scopes: Vec<ScopeGenerator>,
@@ -88,7 +98,7 @@ impl PiranhaLanguage {
     parser
   }
 
-  pub(crate) fn can_parse(&self, de: &jwalk::DirEntry<((), ())>) -> bool {
+  pub(crate) fn can_parse<C: jwalk::ClientState>(&self, de: &jwalk::DirEntry<C>) -> bool {
     de.path()
       .extension()
       .and_then(|e| e.to_str().filter(|x| x.eq(&self.extension())))
@@ -128,6 +138,9 @@ impl std::str::FromStr for PiranhaLanguage {
           language: tree_sitter_java::language(),
           rules: Some(rules),
           edges: Some(edges),
+          aggressive_rules: None,
+          aggressive_edges: None,
+          rule_packs: HashMap::new(),
           scopes: parse_toml::<ScopeConfig>(include_str!(
             "../cleanup_rules/java/scope_config.toml"
           ))
@@ -139,12 +152,64 @@ impl std::str::FromStr for PiranhaLanguage {
       GO => {
         let rules: Rules = parse_toml(include_str!("../cleanup_rules/go/rules.toml"));
         let edges: Edges = parse_toml(include_str!("../cleanup_rules/go/edges.toml"));
+        let aggressive_rules: Rules =
+          parse_toml(include_str!("../cleanup_rules/go/aggressive_rules.toml"));
+        let aggressive_edges: Edges =
+          parse_toml(include_str!("../cleanup_rules/go/aggressive_edges.toml"));
         Ok(PiranhaLanguage {
           extension: language.to_string(),
           supported_language: SupportedLanguage::Go,
           language: tree_sitter_go::language(),
           rules: Some(rules),
           edges: Some(edges),
+          aggressive_rules: Some(aggressive_rules),
+          aggressive_edges: Some(aggressive_edges),
+          rule_packs: HashMap::from([
+            (
+              LAUNCHDARKLY.to_string(),
+              (
+                parse_toml(include_str!(
+                  "../cleanup_rules/go/rule_packs/launchdarkly/rules.toml"
+                )),
+                parse_toml(include_str!(
+                  "../cleanup_rules/go/rule_packs/launchdarkly/edges.toml"
+                )),
+              ),
+            ),
+            (
+              OPENFEATURE.to_string(),
+              (
+                parse_toml(include_str!(
+                  "../cleanup_rules/go/rule_packs/openfeature/rules.toml"
+                )),
+                parse_toml(include_str!(
+                  "../cleanup_rules/go/rule_packs/openfeature/edges.toml"
+                )),
+              ),
+            ),
+            (
+              UNLEASH.to_string(),
+              (
+                parse_toml(include_str!(
+                  "../cleanup_rules/go/rule_packs/unleash/rules.toml"
+                )),
+                parse_toml(include_str!(
+                  "../cleanup_rules/go/rule_packs/unleash/edges.toml"
+                )),
+              ),
+            ),
+            (
+              FLAGSMITH.to_string(),
+              (
+                parse_toml(include_str!(
+                  "../cleanup_rules/go/rule_packs/flagsmith/rules.toml"
+                )),
+                parse_toml(include_str!(
+                  "../cleanup_rules/go/rule_packs/flagsmith/edges.toml"
+                )),
+              ),
+            ),
+          ]),
           scopes: parse_toml::<ScopeConfig>(include_str!("../cleanup_rules/go/scope_config.toml"))
             .scopes()
             .to_vec(),
@@ -160,6 +225,9 @@ impl std::str::FromStr for PiranhaLanguage {
           language: tree_sitter_kotlin::language(),
           rules: Some(rules),
           edges: Some(edges),
+          aggressive_rules: None,
+          aggressive_edges: None,
+          rule_packs: HashMap::new(),
           scopes: parse_toml::<ScopeConfig>(include_str!("../cleanup_rules/kt/scope_config.toml"))
             .scopes()
             .to_vec(),
@@ -172,6 +240,9 @@ impl std::str::FromStr for PiranhaLanguage {
         language: tree_sitter_python::language(),
         rules: None,
         edges: None,
+        aggressive_rules: None,
+        aggressive_edges: None,
+        rule_packs: HashMap::new(),
         scopes: vec![],
         comment_nodes: vec![],
       }),
@@ -190,6 +261,9 @@ impl std::str::FromStr for PiranhaLanguage {
           comment_nodes: vec!["comment".to_string(), "multiline_comment".to_string()],
           rules: Some(rules),
           edges: Some(edges),
+          aggressive_rules: None,
+          aggressive_edges: None,
+          rule_packs: HashMap::new(),
         })
       }
       TYPESCRIPT => Ok(PiranhaLanguage {
@@ -198,6 +272,9 @@ impl std::str::FromStr for PiranhaLanguage {
         language: tree_sitter_typescript::language_typescript(),
         rules: None,
         edges: None,
+        aggressive_rules: None,
+        aggressive_edges: None,
+        rule_packs: HashMap::new(),
         scopes: vec![],
         comment_nodes: vec![],
       }),
@@ -207,6 +284,9 @@ impl std::str::FromStr for PiranhaLanguage {
         language: tree_sitter_typescript::language_tsx(),
         rules: None,
         edges: None,
+        aggressive_rules: None,
+        aggressive_edges: None,
+        rule_packs: HashMap::new(),
         scopes: vec![],
         comment_nodes: vec![],
       }),
@@ -216,6 +296,9 @@ impl std::str::FromStr for PiranhaLanguage {
         language: tree_sitter_thrift::language(),
         rules: None,
         edges: None,
+        aggressive_rules: None,
+        aggressive_edges: None,
+        rule_packs: HashMap::new(),
         scopes: vec![],
         comment_nodes: vec![],
       }),
@@ -225,6 +308,9 @@ impl std::str::FromStr for PiranhaLanguage {
         language: tree_sitter_strings::language(),
         rules: None,
         edges: None,
+        aggressive_rules: None,
+        aggressive_edges: None,
+        rule_packs: HashMap::new(),
         scopes: vec![],
         comment_nodes: vec![],
       }),
@@ -0,0 +1,55 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::io::Write;
+
+use log::{LevelFilter, Record};
+use serde_json::json;
+
+/// The levels of the logs (i.e. `log_level`), from the least to the most verbose
+pub(crate) static LOG_LEVELS: [&str; 6] = ["off", "error", "warn", "info", "debug", "trace"];
+/// The formats of the logs (i.e. `log_format`), i.e. plain text or a JSON object per line
+pub(crate) static LOG_FORMATS: [&str; 2] = ["text", "json"];
+
+/// Initializes the logger of the CLI. The `log_level` (if any) overrides the default level of `RUST_LOG`,
+/// whose per-module directives still apply (e.g. `RUST_LOG=polyglot_piranha::models::matches=trace`).
+/// With the `json` format, each record is written to stderr as a JSON object on its own line.
+pub(crate) fn init_logger(log_level: &str, log_format: &str) {
+  let mut builder = env_logger::Builder::from_default_env();
+  if let Ok(level) = log_level.parse::<LevelFilter>() {
+    builder.filter_level(level);
+  }
+  if log_format == "json" {
+    builder.format(|buf, record| {
+      let timestamp = buf.timestamp_millis().to_string();
+      writeln!(buf, "{}", format_json_record(&timestamp, record))
+    });
+  }
+  // The logger may already be initialized (e.g. by the tests)
+  _ = builder.try_init();
+}
+
+/// Formats the log record as a (single line) JSON object
+fn format_json_record(timestamp: &str, record: &Record) -> String {
+  json!({
+    "timestamp": timestamp,
+    "level": record.level().to_string(),
+    "target": record.target(),
+    "message": record.args().to_string(),
+  })
+  .to_string()
+}
+
+#[cfg(test)]
+#[path = "unit_tests/logging_test.rs"]
+mod logging_test;
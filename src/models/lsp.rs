@@ -0,0 +1,538 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::{BTreeMap, HashMap},
+  fs,
+  io::{BufRead, Read, Write},
+  path::{Path, PathBuf},
+};
+
+use itertools::Itertools;
+use log::{debug, info, warn};
+use serde_json::{json, Map, Value};
+
+use super::{
+  analysis::get_usage_range,
+  check::{get_remaining_flag_usages, FlagUsage},
+  dynamic_flag_names::STALE_FLAG_NAME,
+  piranha_arguments::PiranhaArguments,
+};
+use crate::{
+  clean,
+  utilities::{get_hunks, Hunk},
+};
+
+/// The command cleaning up a stale flag, triggered by the code actions
+static REMOVE_STALE_FLAG: &str = "piranha.removeStaleFlag";
+/// The source of the diagnostics
+static SOURCE: &str = "piranha";
+/// The error codes of JSON-RPC and of the Language Server Protocol
+static METHOD_NOT_FOUND: i64 = -32601;
+static REQUEST_FAILED: i64 = -32803;
+/// The severity (i.e. `Warning`) of the diagnostics
+static WARNING: u64 = 2;
+/// The type (i.e. `Info`) of the messages shown to the user
+static INFO: u64 = 3;
+
+/// The scope of the cleanup of a stale flag, i.e. the file, or its package (i.e. the files of its directory)
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum CleanupScope {
+  File,
+  Package,
+}
+
+/// A (minimal) language server, cleaning up the stale flags of the open files on request (i.e. `serve` and `lsp`)
+struct LanguageServer<'a> {
+  piranha_arguments: &'a PiranhaArguments,
+  // The content of the open documents, by URI
+  documents: HashMap<String, String>,
+  // The usages of the stale flags in the open documents (by URI), along with the content they were found in
+  usages: HashMap<String, (String, Vec<FlagUsage>)>,
+  // The id of the next request sent to the client (i.e. `workspace/applyEdit`)
+  next_request_id: u64,
+  exited: bool,
+}
+
+/// Serves the Language Server Protocol, reading the messages from the `input` and writing the responses (and the
+/// requests and notifications of the server) to the `output`, until the client exits.
+/// The usages of the stale flags in the open documents are published as diagnostics (when they are opened or saved), and
+/// the cleanup of each flag, in the document or its package, is exposed as a code action (i.e. `Remove stale flag: SOME_FLAG`).
+/// The cleanup runs in memory (see `clean`), and its edits are applied by the client (i.e. `workspace/applyEdit`).
+pub fn serve_lsp(
+  piranha_arguments: &PiranhaArguments, input: &mut impl BufRead, output: &mut impl Write,
+) -> Result<(), String> {
+  info!("Serving the Language Server Protocol");
+  let mut server = LanguageServer::new(piranha_arguments);
+  while let Some(message) = read_message(input)? {
+    for outgoing in server.handle(&message) {
+      write_message(output, &outgoing)?;
+    }
+    if server.exited {
+      break;
+    }
+  }
+  Ok(())
+}
+
+impl<'a> LanguageServer<'a> {
+  fn new(piranha_arguments: &'a PiranhaArguments) -> Self {
+    LanguageServer {
+      piranha_arguments,
+      documents: HashMap::new(),
+      usages: HashMap::new(),
+      next_request_id: 0,
+      exited: false,
+    }
+  }
+
+  /// Handles a message of the client, and returns the messages to send back
+  fn handle(&mut self, message: &Value) -> Vec<Value> {
+    let params = &message["params"];
+    let uri = params["textDocument"]["uri"]
+      .as_str()
+      .unwrap_or_default()
+      .to_string();
+    let id = message.get("id").cloned();
+    debug!("Handling the message {} of the client", message["method"]);
+    match (message["method"].as_str(), id) {
+      // The responses of the client (i.e. to `workspace/applyEdit`)
+      (None, _) => vec![],
+      (Some("initialize"), Some(id)) => vec![get_response(id, get_capabilities())],
+      (Some("shutdown"), Some(id)) => vec![get_response(id, Value::Null)],
+      (Some("exit"), _) => {
+        self.exited = true;
+        vec![]
+      }
+      (Some("textDocument/didOpen"), _) => {
+        let text = params["textDocument"]["text"].as_str().unwrap_or_default();
+        self.documents.insert(uri.to_string(), text.to_string());
+        self.publish_diagnostics(&uri)
+      }
+      // The documents are synchronized in full (see `get_capabilities`), i.e. the last change is the content of the document
+      (Some("textDocument/didChange"), _) => {
+        if let Some(text) = params["contentChanges"]
+          .as_array()
+          .and_then(|changes| changes.last())
+          .and_then(|change| change["text"].as_str())
+        {
+          self.documents.insert(uri, text.to_string());
+        }
+        vec![]
+      }
+      (Some("textDocument/didSave"), _) => self.publish_diagnostics(&uri),
+      (Some("textDocument/didClose"), _) => {
+        self.documents.remove(&uri);
+        self.usages.remove(&uri);
+        vec![get_notification(
+          "textDocument/publishDiagnostics",
+          json!({ "uri": uri, "diagnostics": [] }),
+        )]
+      }
+      (Some("textDocument/codeAction"), Some(id)) => {
+        let actions = self.get_code_actions(&uri, &params["range"]);
+        vec![get_response(id, json!(actions))]
+      }
+      (Some("workspace/executeCommand"), Some(id)) => self.execute_command(id, params),
+      (Some(method), Some(id)) => vec![get_error(
+        id,
+        METHOD_NOT_FOUND,
+        &format!("The method `{method}` is not supported"),
+      )],
+      // The other notifications (e.g. `initialized`)
+      _ => vec![],
+    }
+  }
+
+  /// Returns the notification publishing the diagnostics of the document, i.e. the usages of the stale flags
+  fn publish_diagnostics(&mut self, uri: &str) -> Vec<Value> {
+    match self.get_usages(uri) {
+      Ok((content, usages)) => vec![get_notification(
+        "textDocument/publishDiagnostics",
+        json!({
+          "uri": uri,
+          "diagnostics": usages.iter().map(|u| get_diagnostic(u, &content)).collect_vec(),
+        }),
+      )],
+      Err(e) => {
+        warn!("Could not find the usages of the stale flags in {uri} : {e}");
+        vec![]
+      }
+    }
+  }
+
+  /// Returns the code actions cleaning up the stale flags used in the `range` of the document, in the document or in its package
+  fn get_code_actions(&mut self, uri: &str, range: &Value) -> Vec<Value> {
+    let (content, usages) = match self.get_usages(uri) {
+      Ok(u) => u,
+      Err(e) => {
+        warn!("Could not find the usages of the stale flags in {uri} : {e}");
+        return vec![];
+      }
+    };
+    let (first_line, last_line) = (
+      range["start"]["line"].as_u64().unwrap_or_default() as usize,
+      range["end"]["line"].as_u64().unwrap_or(u64::MAX) as usize,
+    );
+    let usages = usages
+      .iter()
+      .filter(|u| (first_line..=last_line).contains(&(*u.line() - 1)))
+      .collect_vec();
+    let mut actions = vec![];
+    for flag in usages.iter().map(|u| u.flag().clone()).unique() {
+      let diagnostics = usages
+        .iter()
+        .filter(|u| *u.flag() == flag)
+        .map(|u| get_diagnostic(u, &content))
+        .collect_vec();
+      for scope in [CleanupScope::File, CleanupScope::Package] {
+        let title = get_title(flag.as_deref(), scope);
+        actions.push(json!({
+          "title": title,
+          "kind": "quickfix",
+          "diagnostics": diagnostics,
+          "isPreferred": scope == CleanupScope::File,
+          "command": {
+            "title": title,
+            "command": REMOVE_STALE_FLAG,
+            "arguments": [{
+              "uri": uri,
+              "flag": flag,
+              "scope": if scope == CleanupScope::File { "file" } else { "package" },
+            }],
+          },
+        }));
+      }
+    }
+    actions
+  }
+
+  /// Executes the command of a code action, i.e. cleans up the stale flag, and asks the client to apply the edits
+  fn execute_command(&mut self, id: Value, params: &Value) -> Vec<Value> {
+    if params["command"].as_str() != Some(REMOVE_STALE_FLAG) {
+      return vec![get_error(
+        id,
+        METHOD_NOT_FOUND,
+        &format!("The command {} is not supported", params["command"]),
+      )];
+    }
+    let arguments = &params["arguments"][0];
+    let uri = arguments["uri"].as_str().unwrap_or_default();
+    let flag = arguments["flag"].as_str();
+    let scope = if arguments["scope"].as_str() == Some("package") {
+      CleanupScope::Package
+    } else {
+      CleanupScope::File
+    };
+    match self.get_cleanup_edit(uri, flag, scope) {
+      Ok(Some(edit)) => {
+        self.next_request_id += 1;
+        vec![
+          get_response(id, Value::Null),
+          json!({
+            "jsonrpc": "2.0",
+            "id": self.next_request_id,
+            "method": "workspace/applyEdit",
+            "params": { "label": get_title(flag, scope), "edit": edit },
+          }),
+        ]
+      }
+      Ok(None) => vec![
+        get_response(id, Value::Null),
+        get_notification(
+          "window/showMessage",
+          json!({ "type": INFO, "message": "There is nothing to clean up" }),
+        ),
+      ],
+      Err(e) => vec![get_error(id, REQUEST_FAILED, &e)],
+    }
+  }
+
+  /// Returns the content of the document and the usages of the stale flags in it, i.e. of all the flags.
+  /// The usages are found by cleaning up the document (alone) in memory, and are cached until its content changes.
+  fn get_usages(&mut self, uri: &str) -> Result<(String, Vec<FlagUsage>), String> {
+    let content = self
+      .documents
+      .get(uri)
+      .ok_or_else(|| format!("The document {uri} is not open"))?
+      .to_string();
+    if let Some((cached_content, usages)) = self.usages.get(uri) {
+      if *cached_content == content {
+        return Ok((content, usages.clone()));
+      }
+    }
+    let path = uri_to_path(uri)?;
+    let name = get_file_name(&path)?;
+    let result = clean(
+      &BTreeMap::from([(name, content.to_string())]),
+      self.piranha_arguments,
+    )?;
+    let usages = get_remaining_flag_usages(result.summaries(), self.piranha_arguments);
+    self
+      .usages
+      .insert(uri.to_string(), (content.to_string(), usages.clone()));
+    Ok((content, usages))
+  }
+
+  /// Returns the edit (i.e. a `WorkspaceEdit`) cleaning up the stale `flag` in the document, or in its package,
+  /// or None if there is nothing to clean up. The open documents are cleaned up as they are in the editor.
+  fn get_cleanup_edit(
+    &self, uri: &str, flag: Option<&str>, scope: CleanupScope,
+  ) -> Result<Option<Value>, String> {
+    let piranha_arguments = self.get_flag_arguments(flag)?;
+    let path = uri_to_path(uri)?;
+    let directory = path.parent().unwrap_or_else(|| Path::new("/"));
+    let paths = match scope {
+      CleanupScope::File => vec![path.clone()],
+      CleanupScope::Package => self.get_package_files(directory),
+    };
+    let mut files = BTreeMap::new();
+    for p in &paths {
+      let content = match self.get_document(p) {
+        Some((_, text)) => Some(text.to_string()),
+        None => fs::read_to_string(p).ok(),
+      };
+      if let Some(content) = content {
+        files.insert(get_file_name(p)?, content);
+      }
+    }
+    let result = clean(&files, piranha_arguments)?;
+    let mut changes = Map::new();
+    for (name, original_content) in &files {
+      // The files deleted by the cleanup (i.e. `delete_file_if_empty`) are emptied
+      let content = result
+        .files()
+        .get(name)
+        .map(String::as_str)
+        .unwrap_or_default();
+      if content == original_content {
+        continue;
+      }
+      let file = directory.join(name);
+      let file_uri = self
+        .get_document(&file)
+        .map(|(u, _)| u.to_string())
+        .unwrap_or_else(|| path_to_uri(&file));
+      let edits = get_hunks(original_content, content)
+        .iter()
+        .map(|h| get_text_edit(original_content, h))
+        .collect_vec();
+      changes.insert(file_uri, json!(edits));
+    }
+    Ok(if changes.is_empty() {
+      None
+    } else {
+      Some(json!({ "changes": changes }))
+    })
+  }
+
+  /// Returns the arguments cleaning up the stale `flag` alone (i.e. one of the `flags`, or the only flag otherwise)
+  fn get_flag_arguments(&self, flag: Option<&str>) -> Result<&PiranhaArguments, String> {
+    if self.piranha_arguments.flag_arguments().is_empty() {
+      return Ok(self.piranha_arguments);
+    }
+    self
+      .piranha_arguments
+      .flag_arguments()
+      .iter()
+      .find(|a| {
+        a.input_substitutions()
+          .get(STALE_FLAG_NAME)
+          .map(String::as_str)
+          == flag
+      })
+      .ok_or_else(|| format!("The flag {flag:?} is not configured"))
+  }
+
+  /// Returns the files of the package (i.e. of the directory) in the language of the arguments, sorted by name
+  fn get_package_files(&self, directory: &Path) -> Vec<PathBuf> {
+    let extension = self.piranha_arguments.get_language();
+    fs::read_dir(directory)
+      .map(|entries| {
+        entries
+          .filter_map(|e| e.ok().map(|e| e.path()))
+          .filter(|p| {
+            p.is_file() && p.extension().and_then(|e| e.to_str()) == Some(extension.as_str())
+          })
+          .sorted()
+          .collect()
+      })
+      .unwrap_or_default()
+  }
+
+  /// Returns the URI and the content of the open document at the path (if any)
+  fn get_document(&self, path: &Path) -> Option<(&String, &String)> {
+    self
+      .documents
+      .iter()
+      .find(|(uri, _)| uri_to_path(uri).map(|p| p == path).unwrap_or(false))
+  }
+}
+
+/// Returns the capabilities of the server, i.e. the result of `initialize`
+fn get_capabilities() -> Value {
+  json!({
+    "capabilities": {
+      "textDocumentSync": { "openClose": true, "change": 1, "save": { "includeText": false } },
+      "codeActionProvider": { "codeActionKinds": ["quickfix"] },
+      "executeCommandProvider": { "commands": [REMOVE_STALE_FLAG] },
+    },
+    "serverInfo": { "name": SOURCE, "version": env!("CARGO_PKG_VERSION") },
+  })
+}
+
+/// Returns the title of the code action cleaning up the flag in the scope, e.g. `Remove stale flag: SOME_FLAG`
+fn get_title(flag: Option<&str>, scope: CleanupScope) -> String {
+  let title = match flag {
+    Some(flag) => format!("Remove stale flag: {flag}"),
+    None => "Remove stale flag".to_string(),
+  };
+  match scope {
+    CleanupScope::File => title,
+    CleanupScope::Package => format!("{title} (package)"),
+  }
+}
+
+/// Returns the diagnostic of a usage of a stale flag in the content of its document
+fn get_diagnostic(usage: &FlagUsage, content: &str) -> Value {
+  let (start, end) = get_usage_range(usage, content);
+  json!({
+    "range": { "start": get_position(content, start), "end": get_position(content, end) },
+    "severity": WARNING,
+    "source": SOURCE,
+    "code": usage.rule(),
+    "message": usage.message(),
+  })
+}
+
+/// Returns the `TextEdit` of the hunk of the diff of the content
+fn get_text_edit(content: &str, hunk: &Hunk) -> Value {
+  json!({
+    "range": { "start": get_position(content, hunk.start), "end": get_position(content, hunk.end) },
+    "newText": hunk.text,
+  })
+}
+
+/// Returns the position of the byte offset in the content, i.e. its 0-based line, and its column in UTF-16 code units
+fn get_position(content: &str, offset: usize) -> Value {
+  let prefix = content.get(..offset.min(content.len())).unwrap_or(content);
+  let line_start = prefix.rfind('\n').map(|i| i + 1).unwrap_or(0);
+  json!({
+    "line": prefix.matches('\n').count(),
+    "character": prefix[line_start..].encode_utf16().count(),
+  })
+}
+
+fn get_response(id: Value, result: Value) -> Value {
+  json!({ "jsonrpc": "2.0", "id": id, "result": result })
+}
+
+fn get_error(id: Value, code: i64, message: &str) -> Value {
+  json!({ "jsonrpc": "2.0", "id": id, "error": { "code": code, "message": message } })
+}
+
+fn get_notification(method: &str, params: Value) -> Value {
+  json!({ "jsonrpc": "2.0", "method": method, "params": params })
+}
+
+/// Reads a message (i.e. its `Content-Length` header, and its JSON content), or None at the end of the input.
+/// A message that is not valid JSON is read as `null`, i.e. ignored.
+fn read_message(input: &mut impl BufRead) -> Result<Option<Value>, String> {
+  let mut content_length = None;
+  loop {
+    let mut line = String::new();
+    if input.read_line(&mut line).map_err(|e| e.to_string())? == 0 {
+      return Ok(None);
+    }
+    let line = line.trim_end();
+    if line.is_empty() {
+      if content_length.is_some() {
+        break;
+      }
+      continue;
+    }
+    if let Some((name, value)) = line.split_once(':') {
+      if name.trim().eq_ignore_ascii_case("Content-Length") {
+        content_length = value.trim().parse::<usize>().ok();
+      }
+    }
+  }
+  let mut content = vec![0; content_length.unwrap_or_default()];
+  input.read_exact(&mut content).map_err(|e| e.to_string())?;
+  Ok(Some(serde_json::from_slice(&content).unwrap_or_else(|e| {
+    warn!("Could not parse the message of the client : {e}");
+    Value::Null
+  })))
+}
+
+/// Writes a message, along with its `Content-Length` header
+fn write_message(output: &mut impl Write, message: &Value) -> Result<(), String> {
+  let content = message.to_string();
+  write!(output, "Content-Length: {}\r\n\r\n{content}", content.len())
+    .and_then(|_| output.flush())
+    .map_err(|e| e.to_string())
+}
+
+/// Returns the path of a `file://` URI, decoding its percent-encoded bytes
+fn uri_to_path(uri: &str) -> Result<PathBuf, String> {
+  let encoded = uri
+    .strip_prefix("file://")
+    .ok_or_else(|| format!("The URI {uri} is not a file"))?;
+  let (bytes, mut decoded) = (encoded.as_bytes(), vec![]);
+  let mut i = 0;
+  while i < bytes.len() {
+    let escaped = encoded
+      .get(i + 1..i + 3)
+      .and_then(|hex| u8::from_str_radix(hex, 16).ok());
+    match (bytes[i], escaped) {
+      (b'%', Some(b)) => {
+        decoded.push(b);
+        i += 3;
+      }
+      (b, _) => {
+        decoded.push(b);
+        i += 1;
+      }
+    }
+  }
+  String::from_utf8(decoded)
+    .map(PathBuf::from)
+    .map_err(|e| format!("The URI {uri} is not valid : {e}"))
+}
+
+/// Returns the `file://` URI of a path, percent-encoding its bytes other than the unreserved characters and `/`
+fn path_to_uri(path: &Path) -> String {
+  let encoded: String = path
+    .to_string_lossy()
+    .bytes()
+    .map(|b| {
+      if b.is_ascii_alphanumeric() || b"-._~/".contains(&b) {
+        (b as char).to_string()
+      } else {
+        format!("%{b:02X}")
+      }
+    })
+    .collect();
+  format!("file://{encoded}")
+}
+
+fn get_file_name(path: &Path) -> Result<String, String> {
+  path
+    .file_name()
+    .map(|n| n.to_string_lossy().to_string())
+    .ok_or_else(|| format!("The path {path:?} is not a file"))
+}
+
+#[cfg(test)]
+#[path = "unit_tests/lsp_test.rs"]
+mod lsp_test;
@@ -15,7 +15,7 @@ use std::collections::HashMap;
 
 use getset::{Getters, MutGetters};
 use itertools::Itertools;
-use log::trace;
+use log::{debug, trace};
 use pyo3::prelude::{pyclass, pymethods};
 use serde_derive::{Deserialize, Serialize};
 use tree_sitter::Node;
@@ -26,6 +26,7 @@ use crate::utilities::{
 };
 
 use super::{
+  directives::is_anchored_directive, language::SupportedLanguage,
   piranha_arguments::PiranhaArguments, rule::InstantiatedRule, rule_store::RuleStore,
   source_code_unit::SourceCodeUnit,
 };
@@ -43,6 +44,7 @@ pub(crate) struct Match {
   // The mapping between tags and string representation of the AST captured.
   #[pyo3(get)]
   #[get = "pub"]
+  #[get_mut]
   matches: HashMap<String, String>,
   // Captures the range of the associated comma
   #[get]
@@ -103,6 +105,13 @@ impl Match {
     self.matched_string = code[self.range.start_byte..self.range.end_byte].to_string()
   }
 
+  /// Merge the associated comments (but not the associated comma) of the given match into the current match.
+  /// It is used when the matched node is replaced by a part of itself, thus the comma is still needed.
+  pub(crate) fn expand_to_associated_comments(&mut self, code: &String) {
+    self.associated_comma = None;
+    self.expand_to_associated_matches(code);
+  }
+
   /// Get the edit's replacement range.
   pub(crate) fn range(&self) -> tree_sitter::Range {
     tree_sitter::Range {
@@ -120,7 +129,7 @@ impl Match {
   }
 
   // Populates the leading and trailing comma and comment ranges for the match.
-  fn populate_associated_elements(
+  pub(super) fn populate_associated_elements(
     &mut self, node: &Node, code: &String, piranha_arguments: &PiranhaArguments,
   ) {
     self.get_associated_elements(node, code, piranha_arguments, true);
@@ -152,7 +161,8 @@ impl Match {
           current_node = sibling;
           found_comma = true;
           continue; // Continue the inner loop (i.e. evaluate next sibling)
-        } else if self._is_comment_safe_to_delete(&sibling, node, piranha_arguments, trailing) {
+        } else if self._is_comment_safe_to_delete(&sibling, node, code, piranha_arguments, trailing)
+        {
           // Add the comment to the associated matches
           self.associated_comments.push(Range::from(sibling.range()));
           current_node = sibling;
@@ -181,13 +191,19 @@ impl Match {
 
   /// Checks if the given comment is safe to delete.
   fn _is_comment_safe_to_delete(
-    &mut self, comment: &Node, deleted_node: &Node, piranha_arguments: &PiranhaArguments,
-    trailing: bool,
+    &mut self, comment: &Node, deleted_node: &Node, code: &str,
+    piranha_arguments: &PiranhaArguments, trailing: bool,
   ) -> bool {
     // Check if the comment is a comment in the language
     if !self.is_comment(comment.kind().to_string(), piranha_arguments) {
       return false;
     }
+    // The directives anchored to their position in the file (e.g. `//go:generate`) do not belong to the deleted code
+    if *piranha_arguments.language().supported_language() == SupportedLanguage::Go
+      && is_anchored_directive(comment.utf8_text(code.as_bytes()).unwrap_or_default())
+    {
+      return false;
+    }
     // If trailing, check if the comment is on the same line as the deleted node
     // i.e. where the deleted node ends or starts
     let is_on_same_line = comment.range().start_point.row == deleted_node.range().end_point.row
@@ -206,6 +222,13 @@ impl Match {
       if self.overlaps(comment, &previous_node) {
         return false;
       }
+      // The comment trails the previous statement (i.e. `x := f() // comment`), thus it belongs to it
+      if previous_node.is_named()
+        && previous_node.kind() != comment.kind()
+        && previous_node.end_position().row == comment.start_position().row
+      {
+        return false;
+      }
     }
     // Check if there exists no node between the comment and the deleted node
     if let Some(next_node) = comment.next_sibling() {
@@ -279,9 +302,42 @@ gen_py_str_methods!(Point);
 
 // Implements instance methods related to getting matches for rule
 impl SourceCodeUnit {
-  /// Gets the first match for the rule in `self`
+  /// Gets the matches for the rule in `self`, that are not exempted by a `piranha:ignore` directive,
+  /// nor already annotated for a manual verification (for the rules below the `confidence_threshold`)
   pub(crate) fn get_matches(
     &self, rule: &InstantiatedRule, rule_store: &mut RuleStore, node: Node, recursive: bool,
+  ) -> Vec<Match> {
+    let is_low_confidence = self.is_low_confidence(&rule.name());
+    self
+      .get_candidate_matches(rule, rule_store, node, recursive)
+      .into_iter()
+      .filter(|m| match self.get_exemption_reason(m, is_low_confidence) {
+        Some(reason) => {
+          self.log_rejected_match(rule, m, &reason);
+          false
+        }
+        None => true,
+      })
+      .collect()
+  }
+
+  /// Returns the reason why the (candidate) match is exempted from the cleanup (if so), i.e. it is suppressed by a
+  /// `piranha:ignore` directive, or already annotated for a manual verification (for a `low_confidence` rule)
+  pub(crate) fn get_exemption_reason(
+    &self, p_match: &Match, is_low_confidence: bool,
+  ) -> Option<String> {
+    if self.is_suppressed(p_match) {
+      return Some("it is suppressed by a `piranha:ignore` directive".to_string());
+    }
+    if is_low_confidence && self.is_annotated_for_verification(p_match) {
+      return Some("it is already annotated for a manual verification".to_string());
+    }
+    None
+  }
+
+  /// Gets the matches for the rule in `self`, regardless of the `piranha:ignore` directives
+  pub(crate) fn get_candidate_matches(
+    &self, rule: &InstantiatedRule, rule_store: &mut RuleStore, node: Node, recursive: bool,
   ) -> Vec<Match> {
     let mut output: Vec<Match> = vec![];
     // Get all matches for the query in the given scope `node`.
@@ -293,7 +349,7 @@ impl SourceCodeUnit {
     let mut all_query_matches = get_all_matches_for_query(
       &node,
       self.code().to_string(),
-      rule_store.query(&rule.query()),
+      rule_store.query(&self.resolve_package_aliases(rule)),
       recursive,
       replace_node_tag,
     );
@@ -305,13 +361,58 @@ impl SourceCodeUnit {
         p_match.range().start_byte,
         p_match.range().end_byte,
       );
-      if self.is_satisfied(matched_node, rule, p_match.matches(), rule_store) {
-        p_match.populate_associated_elements(&matched_node, self.code(), self.piranha_arguments());
-        trace!("Found match {:#?}", p_match);
-        output.push(p_match.clone());
+      if let Some(reason) = self.get_rejection_reason(matched_node, rule, p_match, rule_store) {
+        self.log_rejected_match(rule, p_match, &reason);
+        continue;
       }
+      p_match.populate_associated_elements(&matched_node, self.code(), self.piranha_arguments());
+      debug!(
+        "Rule {} matched at {}:{} : {}",
+        rule.name(),
+        self.path().display(),
+        p_match.range().start_point.row + 1,
+        p_match.matched_string()
+      );
+      trace!("Found match {:#?}", p_match);
+      output.push(p_match.clone());
     }
     trace!("Matches found {}", output.len());
     output
   }
+
+  /// Returns the reason why the match of the query of the rule is rejected (if so), e.g. a filter of the rule is not satisfied
+  pub(crate) fn get_rejection_reason(
+    &self, matched_node: Node, rule: &InstantiatedRule, p_match: &Match, rule_store: &mut RuleStore,
+  ) -> Option<String> {
+    if let Some(filter) =
+      self.get_unsatisfied_filter(matched_node, rule, p_match.matches(), rule_store)
+    {
+      return Some(format!("the filter {} is not satisfied", filter.describe()));
+    }
+    if self.shifts_iota_values(matched_node, rule) {
+      return Some(
+        "deleting the constant would shift the values of the subsequent `iota` constants"
+          .to_string(),
+      );
+    }
+    if self.references_shadowed_identifier(matched_node, rule) {
+      return Some("the matched identifier is shadowed by a local declaration".to_string());
+    }
+    if !self.evaluate_numeric_comparison(p_match, rule) {
+      return Some("the numeric comparison does not hold".to_string());
+    }
+    None
+  }
+
+  /// Logs why the match of the rule is not cleaned up (e.g. to debug why a site was not cleaned up)
+  fn log_rejected_match(&self, rule: &InstantiatedRule, p_match: &Match, reason: &str) {
+    debug!(
+      "Rule {} matched at {}:{}, but the match is rejected ({}) : {}",
+      rule.name(),
+      self.path().display(),
+      p_match.range().start_point.row + 1,
+      reason,
+      p_match.matched_string()
+    );
+  }
 }
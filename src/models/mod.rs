@@ -11,19 +11,75 @@ Copyright (c) 2023 Uber Technologies, Inc.
  limitations under the License.
 */
 
+pub(crate) mod analysis;
+pub mod archive;
+pub mod blast_radius;
+pub(crate) mod build_verification;
+pub(crate) mod cache;
+pub(crate) mod changed_files;
+pub mod check;
+pub mod clean;
+pub(crate) mod code_owners;
+pub(crate) mod confidence;
 pub(crate) mod default_configs;
+pub mod diff_preview;
+pub(crate) mod directives;
+pub(crate) mod dynamic_flag_names;
 pub(crate) mod edit;
+pub(crate) mod encoding;
+pub mod explain;
+pub(crate) mod external_test_packages;
 pub(crate) mod filter;
+pub(crate) mod flag_apis;
+pub(crate) mod flag_definitions;
+pub(crate) mod flag_file;
+pub(crate) mod generated_files;
+pub(crate) mod git_ignore;
+pub(crate) mod go_modules;
+pub(crate) mod go_packages;
+pub mod hook;
+pub(crate) mod html_report;
+pub mod http;
+pub(crate) mod imports;
+pub(crate) mod interactive_review;
+pub mod inventory;
+pub(crate) mod iota;
+pub mod journal;
 pub(crate) mod language;
+pub(crate) mod logging;
+pub mod lsp;
 pub(crate) mod matches;
+pub mod notify;
+pub(crate) mod numeric;
 pub(crate) mod outgoing_edges;
+pub(crate) mod package_aliases;
+pub(crate) mod parallel;
+pub(crate) mod persist;
 pub mod piranha_arguments;
+pub(crate) mod piranha_ignore;
 pub mod piranha_output;
+pub(crate) mod post_processing_hook;
+pub(crate) mod provenance;
+pub mod pull_request;
+pub mod report;
 pub(crate) mod rule;
 pub(crate) mod rule_graph;
 pub(crate) mod rule_store;
+pub(crate) mod sarif;
+pub mod scan;
+pub(crate) mod scm;
 pub(crate) mod scopes;
+pub(crate) mod shadowing;
 pub(crate) mod source_code_unit;
+pub(crate) mod specialization;
+pub mod split;
+pub mod statistics;
+pub(crate) mod stranded_functions;
+pub(crate) mod suppressions;
+pub(crate) mod templates;
+pub(crate) mod test_cleanup;
+pub(crate) mod test_tables;
+pub mod watch;
 
 pub(crate) trait Validator {
   fn validate(&self) -> Result<(), String>;
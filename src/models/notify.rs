@@ -0,0 +1,213 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::env;
+
+use getset::Getters;
+use itertools::Itertools;
+use log::debug;
+use serde_derive::Serialize;
+use serde_json::{json, Value};
+
+use super::{
+  piranha_arguments::PiranhaArguments, piranha_output::PiranhaOutputSummary,
+  pull_request::PullRequest, scm::call_api, statistics::RunStatistics,
+};
+
+/// The formats of the payload posted to the `notify_url`, i.e. the `Notification` as JSON, or a Slack message
+pub(crate) static NOTIFY_FORMATS: [&str; 2] = ["json", "slack"];
+
+/// The summary of a run posted to the `notify_url` (e.g. by a scheduled CI job), so that the teams see the cleanups
+/// without checking the logs of the job
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Getters)]
+pub struct Notification {
+  #[get = "pub"]
+  path_to_codebase: String,
+  // Whether the rewrites were only proposed (i.e. `dry_run`)
+  #[get = "pub"]
+  dry_run: bool,
+  // The cleaned up flags, i.e. the ones with rewrites
+  #[get = "pub"]
+  flags: Vec<String>,
+  // The paths of the changed files
+  #[get = "pub"]
+  files_changed: Vec<String>,
+  #[get = "pub"]
+  lines_added: usize,
+  #[get = "pub"]
+  lines_removed: usize,
+  // The URLs of the opened pull requests (see `pull_request`)
+  #[get = "pub"]
+  pull_requests: Vec<String>,
+  // The reasons why (a part of) the run failed, e.g. a pull request that could not be opened
+  #[get = "pub"]
+  failures: Vec<String>,
+  // The URL of the CI job running Piranha (i.e. of GitHub Actions or GitLab CI), if any
+  #[serde(skip_serializing_if = "Option::is_none")]
+  #[get = "pub"]
+  run_url: Option<String>,
+}
+
+impl Notification {
+  /// Builds the notification of the output summaries and the statistics of a run with the given `piranha_arguments`
+  pub fn new(
+    summaries: &[PiranhaOutputSummary], statistics: &RunStatistics,
+    piranha_arguments: &PiranhaArguments,
+  ) -> Notification {
+    Notification {
+      flags: statistics
+        .flags()
+        .iter()
+        .filter(|f| *f.rewrites() > 0)
+        .map(|f| f.flag().to_string())
+        .collect(),
+      files_changed: summaries
+        .iter()
+        .filter(|s| s.content() != s.original_content())
+        .map(|s| s.path().to_string())
+        .sorted()
+        .collect(),
+      lines_added: *statistics.lines_added(),
+      lines_removed: *statistics.lines_removed(),
+      ..Notification::empty(piranha_arguments)
+    }
+  }
+
+  /// Builds the notification of the pull requests opened for the cleanups (see `pull_request`)
+  pub fn of_pull_requests(
+    pull_requests: &[PullRequest], piranha_arguments: &PiranhaArguments,
+  ) -> Notification {
+    Notification {
+      flags: pull_requests
+        .iter()
+        .map(|p| p.flag().to_string())
+        .unique()
+        .collect(),
+      files_changed: pull_requests
+        .iter()
+        .flat_map(|p| p.files().clone())
+        .unique()
+        .sorted()
+        .collect(),
+      pull_requests: pull_requests.iter().map(|p| p.url().to_string()).collect(),
+      ..Notification::empty(piranha_arguments)
+    }
+  }
+
+  /// Builds the notification of a run that cleaned up nothing (e.g. since it failed)
+  pub fn empty(piranha_arguments: &PiranhaArguments) -> Notification {
+    Notification {
+      path_to_codebase: piranha_arguments.path_to_codebase().to_string(),
+      dry_run: *piranha_arguments.dry_run(),
+      run_url: get_run_url(),
+      ..Default::default()
+    }
+  }
+
+  /// Adds the reasons why (a part of) the run failed
+  pub fn with_failures(mut self, failures: &[String]) -> Notification {
+    self.failures.extend(failures.iter().cloned());
+    self
+  }
+
+  /// Returns the payload of the notification in the `notify_format`, i.e. the notification itself (for `json`),
+  /// or a message in the `mrkdwn` format of the incoming webhooks of Slack (for `slack`)
+  pub fn to_payload(&self, notify_format: &str) -> Value {
+    if notify_format != "slack" {
+      return serde_json::to_value(self).unwrap_or_default();
+    }
+    let verb = if self.dry_run {
+      "proposed the cleanup of"
+    } else {
+      "cleaned up"
+    };
+    let mut lines = vec![if self.flags.is_empty() {
+      format!(
+        "*Piranha* cleaned up no flag in `{}`",
+        self.path_to_codebase
+      )
+    } else {
+      format!(
+        "*Piranha* {verb} {} flag(s) in `{}`: {}",
+        self.flags.len(),
+        self.path_to_codebase,
+        self.flags.iter().map(|f| format!("`{f}`")).join(", ")
+      )
+    }];
+    if !self.files_changed.is_empty() {
+      let lines_changed = if self.lines_added + self.lines_removed > 0 {
+        format!(" (+{} -{})", self.lines_added, self.lines_removed)
+      } else {
+        String::new()
+      };
+      lines.push(format!(
+        "{} file(s) changed{lines_changed}",
+        self.files_changed.len()
+      ));
+    }
+    if !self.pull_requests.is_empty() {
+      lines.push("*Pull requests*".to_string());
+      lines.extend(self.pull_requests.iter().map(|u| format!("• <{u}>")));
+    }
+    if !self.failures.is_empty() {
+      lines.push(format!(":warning: *{} failure(s)*", self.failures.len()));
+      lines.extend(self.failures.iter().map(|f| format!("• {f}")));
+    }
+    if let Some(run_url) = &self.run_url {
+      lines.push(format!("<{run_url}|View the run>"));
+    }
+    json!({ "text": lines.join("\n") })
+  }
+}
+
+/// Posts the notification to the `notify_url` (in the `notify_format`), authenticated with the optional
+/// `NOTIFY_WEBHOOK_TOKEN` environment variable as a bearer token (the incoming webhooks of Slack embed their secret in the URL)
+pub fn notify(
+  notification: &Notification, piranha_arguments: &PiranhaArguments,
+) -> Result<(), String> {
+  let headers = env::var("NOTIFY_WEBHOOK_TOKEN")
+    .map(|t| vec![format!("Authorization: Bearer {t}")])
+    .unwrap_or_default();
+  call_api(
+    "POST",
+    piranha_arguments.notify_url(),
+    &headers,
+    Some(&notification.to_payload(piranha_arguments.notify_format())),
+  )?;
+  debug!(
+    "Posted the notification to {}",
+    piranha_arguments.notify_url()
+  );
+  Ok(())
+}
+
+/// Returns the URL of the CI job running Piranha, i.e. of the run of GitHub Actions or of the job of GitLab CI (if any)
+fn get_run_url() -> Option<String> {
+  if let Ok(url) = env::var("CI_JOB_URL") {
+    return Some(url);
+  }
+  match (
+    env::var("GITHUB_SERVER_URL"),
+    env::var("GITHUB_REPOSITORY"),
+    env::var("GITHUB_RUN_ID"),
+  ) {
+    (Ok(server), Ok(repository), Ok(run_id)) => {
+      Some(format!("{server}/{repository}/actions/runs/{run_id}"))
+    }
+    _ => None,
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/notify_test.rs"]
+mod notify_test;
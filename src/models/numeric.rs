@@ -0,0 +1,177 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::cmp::Ordering;
+
+use log::debug;
+use tree_sitter::Node;
+
+use super::{
+  matches::Match, rule::InstantiatedRule, source_code_unit::SourceCodeUnit,
+  specialization::collect_nodes,
+};
+
+/// The tag bound to the value (i.e. `true` or `false`) of the comparison captured by `@lhs`, `@operator` and `@rhs`.
+/// It can be used in the `replace` of a rule (see `simplify_numeric_comparison` in `cleanup_rules/go/rules.toml`).
+pub(crate) static EVALUATED_COMPARISON: &str = "evaluated_comparison";
+
+/// The value of a numeric literal
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub(crate) enum Number {
+  Int(i128),
+  Float(f64),
+}
+
+// Implements instance methods related to evaluating the comparisons of numeric values (e.g. rollout percentages)
+impl SourceCodeUnit {
+  /// Evaluates the comparison captured by the match and binds its value to `@evaluated_comparison`,
+  /// if the `replace` of the rule refers to it.
+  /// The operands are numeric literals, or constants declared (once) in this file with a numeric literal.
+  /// Returns false if the comparison cannot be evaluated, i.e. the match should be discarded.
+  pub(crate) fn evaluate_numeric_comparison(
+    &self, p_match: &mut Match, rule: &InstantiatedRule,
+  ) -> bool {
+    if !rule.replace().contains(&format!("@{EVALUATED_COMPARISON}")) {
+      return true;
+    }
+    let tags = p_match.matches();
+    let value = match (
+      tags.get("lhs").and_then(|l| self.resolve_number(l)),
+      tags.get("operator"),
+      tags.get("rhs").and_then(|r| self.resolve_number(r)),
+    ) {
+      (Some(lhs), Some(operator), Some(rhs)) => compare(lhs, operator, rhs),
+      _ => None,
+    };
+    match value {
+      Some(v) => {
+        debug!("Evaluated `{}` to {v}", p_match.matched_string());
+        p_match
+          .matches_mut()
+          .insert(EVALUATED_COMPARISON.to_string(), v.to_string());
+        true
+      }
+      None => false,
+    }
+  }
+
+  /// Returns the value of the numeric literal, or of the constant named `operand`
+  fn resolve_number(&self, operand: &str) -> Option<Number> {
+    parse_number(operand).or_else(|| self.get_numeric_constant(operand))
+  }
+
+  /// Returns the value of the package-level constant `name` (e.g. `const threshold = 50`).
+  /// Returns `None` when `name` is declared more than once in this file (e.g. shadowed by a local variable),
+  /// since the reference could then denote another declaration.
+  fn get_numeric_constant(&self, name: &str) -> Option<Number> {
+    let declarations = collect_nodes(self.root_node(), |n| {
+      n.kind() == "identifier"
+        && n.utf8_text(self.code().as_bytes()).ok() == Some(name)
+        && is_declaration(*n)
+    });
+    let spec = match declarations.as_slice() {
+      [declaration] => declaration.parent()?,
+      _ => return None,
+    };
+    let is_package_level = spec
+      .parent()
+      .and_then(|d| d.parent())
+      .map(|s| s.kind() == "source_file")
+      .unwrap_or(false);
+    let value = spec.child_by_field_name("value")?;
+    if spec.kind() != "const_spec" || !is_package_level || value.named_child_count() != 1 {
+      return None;
+    }
+    let literal = value.named_child(0)?;
+    if !["int_literal", "float_literal"].contains(&literal.kind()) {
+      return None;
+    }
+    parse_number(literal.utf8_text(self.code().as_bytes()).ok()?)
+  }
+}
+
+/// Checks if the identifier is the name declared by a constant, variable or parameter.
+pub(super) fn is_declaration(identifier: Node) -> bool {
+  match identifier.parent() {
+    Some(p) => match p.kind() {
+      "const_spec" | "var_spec" | "parameter_declaration" | "variadic_parameter_declaration" => {
+        true
+      }
+      "expression_list" => p
+        .parent()
+        .filter(|pp| ["short_var_declaration", "range_clause"].contains(&pp.kind()))
+        .and_then(|pp| pp.child_by_field_name("left"))
+        .map(|l| l.id() == p.id())
+        .unwrap_or(false),
+      _ => false,
+    },
+    None => false,
+  }
+}
+
+/// Parses a Go integer (e.g. `100`, `0x64`, `1_000`) or floating-point (e.g. `0.5`, `1e2`) literal.
+/// Hexadecimal floating-point and imaginary literals are not supported.
+pub(crate) fn parse_number(literal: &str) -> Option<Number> {
+  let literal = literal.trim().replace('_', "");
+  let lower = literal.to_lowercase();
+  let (digits, radix) = if let Some(d) = lower.strip_prefix("0x") {
+    (d, 16)
+  } else if let Some(d) = lower.strip_prefix("0b") {
+    (d, 2)
+  } else if let Some(d) = lower.strip_prefix("0o") {
+    (d, 8)
+  } else if lower.len() > 1 && lower.starts_with('0') && lower.chars().all(|c| c.is_ascii_digit()) {
+    (&lower[1..], 8)
+  } else {
+    (lower.as_str(), 10)
+  };
+  if let Ok(i) = i128::from_str_radix(digits, radix) {
+    return Some(Number::Int(i));
+  }
+  if radix == 10
+    && lower
+      .chars()
+      .all(|c| c.is_ascii_digit() || "+-.e".contains(c))
+  {
+    return lower.parse::<f64>().ok().map(Number::Float);
+  }
+  None
+}
+
+/// Evaluates `lhs <operator> rhs`, where `operator` is one of Go's comparison operators.
+pub(crate) fn compare(lhs: Number, operator: &str, rhs: Number) -> Option<bool> {
+  let ordering = match (lhs, rhs) {
+    (Number::Int(l), Number::Int(r)) => l.cmp(&r),
+    _ => as_float(lhs).partial_cmp(&as_float(rhs))?,
+  };
+  match operator {
+    "==" => Some(ordering == Ordering::Equal),
+    "!=" => Some(ordering != Ordering::Equal),
+    "<" => Some(ordering == Ordering::Less),
+    "<=" => Some(ordering != Ordering::Greater),
+    ">" => Some(ordering == Ordering::Greater),
+    ">=" => Some(ordering != Ordering::Less),
+    _ => None,
+  }
+}
+
+fn as_float(number: Number) -> f64 {
+  match number {
+    Number::Int(i) => i as f64,
+    Number::Float(f) => f,
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/numeric_test.rs"]
+mod numeric_test;
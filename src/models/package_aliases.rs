@@ -0,0 +1,218 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::{HashMap, HashSet};
+
+use itertools::Itertools;
+use regex::{Captures, Regex};
+
+use crate::utilities::tree_sitter_utilities::TSQuery;
+
+use super::{
+  imports::{get_assumed_package_name, get_import_specs},
+  language::SupportedLanguage,
+  rule::InstantiatedRule,
+  source_code_unit::SourceCodeUnit,
+};
+
+// Implements instance methods related to resolving the names under which the packages are imported
+impl SourceCodeUnit {
+  /// Returns the query of the rule, adapted to the names under which the packages are imported in this file.
+  /// The predicates comparing a capture with the name of a package (i.e. `(#eq? @pkg "exp")`, where `exp`
+  /// is the name assumed from the import path) are rewritten such that:
+  /// * for `import fx "company/exp"`, the capture is compared with `fx` instead,
+  /// * for `import . "company/exp"`, the qualified pattern (i.e. `(selector_expression operand: (_) @pkg field: (field_identifier) @name)`)
+  ///   is replaced with the unqualified one (i.e. `(identifier) @name`), unless `@pkg` is referenced elsewhere in the rule.
+  ///
+  /// Currently, only supported for Go.
+  pub(crate) fn resolve_package_aliases(&self, rule: &InstantiatedRule) -> TSQuery {
+    let query = rule.query();
+    if *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go {
+      return query;
+    }
+    let imported_names = self.get_imported_names();
+    if imported_names.is_empty() {
+      return query;
+    }
+    let mut dot_imported_captures = vec![];
+    let package_predicate = Regex::new(r#"\(#eq\?\s+@(\w+)\s+"(\w+)"\s*\)"#).unwrap();
+    let mut resolved = package_predicate
+      .replace_all(&query.get_query(), |caps: &Captures| {
+        let (capture, package) = (&caps[1], &caps[2]);
+        let names = match imported_names.get(package) {
+          Some(names) => names,
+          None => return caps[0].to_string(),
+        };
+        if names.len() == 1 && names.contains(".") {
+          dot_imported_captures.push(capture.to_string());
+          return caps[0].to_string();
+        }
+        let names = names.iter().filter(|n| *n != ".").sorted().collect_vec();
+        if names.len() == 1 && names[0] == package {
+          caps[0].to_string()
+        } else {
+          format!("(#match? @{capture} \"^({})$\")", names.iter().join("|"))
+        }
+      })
+      .into_owned();
+
+    for capture in dot_imported_captures {
+      let predicate = Regex::new(&format!(r#"\(#eq\?\s+@{capture}\s+"\w+"\s*\)"#)).unwrap();
+      let candidate = unqualify_selector_patterns(&predicate.replace_all(&resolved, ""), &capture);
+      // The capture may still be referenced, e.g. by another predicate or by the replacement
+      if !references_capture(&candidate, &capture) && !references_capture(&rule.replace(), &capture)
+      {
+        resolved = candidate;
+      }
+    }
+    TSQuery::new(resolved)
+  }
+
+  /// Returns the names under which the packages are imported in this file, by the package name assumed from
+  /// their import path i.e. `{"exp": {"fx"}}` for `import fx "company/exp"`. Blank (`_`) imports are skipped.
+  fn get_imported_names(&self) -> HashMap<String, HashSet<String>> {
+    let mut imported_names: HashMap<String, HashSet<String>> = HashMap::new();
+    let root_node = self.root_node();
+    let mut cursor = root_node.walk();
+    let declarations = root_node
+      .named_children(&mut cursor)
+      .filter(|n| n.kind() == "import_declaration");
+    for declaration in declarations {
+      for import_spec in get_import_specs(declaration) {
+        let package =
+          get_assumed_package_name(&self.text_of(import_spec.child_by_field_name("path")));
+        let name = match import_spec.child_by_field_name("name") {
+          Some(name) => self.text_of(Some(name)),
+          None => package.to_string(),
+        };
+        if !package.is_empty() && name != "_" {
+          imported_names.entry(package).or_default().insert(name);
+        }
+      }
+    }
+    imported_names
+  }
+}
+
+/// Replaces the `selector_expression` patterns whose operand is captured as `capture` with the `identifier` pattern
+/// of their field, i.e. `(selector_expression operand: (_) @pkg field: (field_identifier) @name)` -> `(identifier) @name`
+fn unqualify_selector_patterns(query: &str, capture: &str) -> String {
+  let mut output = query.to_string();
+  let mut search_from = 0;
+  while let Some(offset) = output[search_from..].find("(selector_expression") {
+    let start = search_from + offset;
+    let end = match get_pattern_end(&output, start) {
+      Some(end) => end,
+      None => break,
+    };
+    let pattern = &output[start..end];
+    let replacement = get_field_pattern(pattern, "operand")
+      .filter(|(operand, captures)| {
+        references_capture(operand, capture) || references_capture(captures, capture)
+      })
+      .and_then(|_| get_field_pattern(pattern, "field"))
+      .map(|(_, captures)| format!("(identifier){captures}"));
+    match replacement {
+      Some(replacement) => {
+        output.replace_range(start..end, &replacement);
+        search_from = start + replacement.len();
+      }
+      None => search_from = start + 1,
+    }
+  }
+  output
+}
+
+/// Returns the sub-pattern of the given field of the pattern (i.e. a direct child), along with the captures following it
+/// i.e. `(field_identifier)` and ` @name` for `field: (field_identifier) @name`
+fn get_field_pattern<'a>(pattern: &'a str, field: &str) -> Option<(&'a str, &'a str)> {
+  let field_regex = Regex::new(&format!(r"\b{field}:\s*")).unwrap();
+  let field_match = field_regex
+    .find_iter(pattern)
+    .find(|m| get_depth(&pattern[..m.start()]) == 1)?;
+  let start = field_match.end();
+  let end = if pattern[start..].starts_with(|c| c == '(' || c == '[') {
+    get_pattern_end(pattern, start)?
+  } else {
+    start + pattern[start..].find(|c: char| c.is_whitespace() || c == ')')?
+  };
+  let captures = Regex::new(r"^(\s*@\w+)*")
+    .unwrap()
+    .find(&pattern[end..])
+    .map(|m| m.as_str())
+    .unwrap_or_default();
+  Some((&pattern[start..end], captures))
+}
+
+/// Returns the (exclusive) end of the parenthesized or bracketed pattern starting at `start`, skipping the string literals
+fn get_pattern_end(query: &str, start: usize) -> Option<usize> {
+  let mut depth = 0;
+  for (index, c) in iterate_outside_strings(&query[start..]) {
+    match c {
+      '(' | '[' => depth += 1,
+      ')' | ']' => {
+        depth -= 1;
+        if depth == 0 {
+          return Some(start + index + 1);
+        }
+      }
+      _ => {}
+    }
+  }
+  None
+}
+
+/// Returns the number of parentheses and brackets left open in `query`
+fn get_depth(query: &str) -> i32 {
+  iterate_outside_strings(query)
+    .map(|(_, c)| match c {
+      '(' | '[' => 1,
+      ')' | ']' => -1,
+      _ => 0,
+    })
+    .sum()
+}
+
+/// Iterates over the characters of `query` (along with their byte index) that are not part of a string literal
+fn iterate_outside_strings(query: &str) -> impl Iterator<Item = (usize, char)> + '_ {
+  let mut in_string = false;
+  let mut escaped = false;
+  query.char_indices().filter(move |(_, c)| {
+    if in_string {
+      if escaped {
+        escaped = false;
+      } else if *c == '\\' {
+        escaped = true;
+      } else if *c == '"' {
+        in_string = false;
+      }
+      return false;
+    }
+    if *c == '"' {
+      in_string = true;
+      return false;
+    }
+    true
+  })
+}
+
+/// Checks if `text` (i.e. a query or a replacement) references `@capture`
+fn references_capture(text: &str, capture: &str) -> bool {
+  Regex::new(&format!(r"@{capture}\b"))
+    .unwrap()
+    .is_match(text)
+}
+
+#[cfg(test)]
+#[path = "unit_tests/package_aliases_test.rs"]
+mod package_aliases_test;
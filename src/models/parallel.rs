@@ -0,0 +1,141 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::{BTreeMap, HashMap},
+  path::{Path, PathBuf},
+  thread,
+};
+
+use itertools::Itertools;
+use log::debug;
+
+use super::{
+  piranha_arguments::PiranhaArguments, rule::InstantiatedRule, rule_store::RuleStore,
+  source_code_unit::SourceCodeUnit,
+};
+
+/// The files of a package (i.e. a directory), processed by the same worker
+type Package = Vec<(PathBuf, String)>;
+
+/// Applies the (global) `rules` to the `files` with a pool of `jobs` workers, each processing whole packages
+/// (i.e. the files of a directory) with its own parser and rule store.
+/// The source code units of the files are (lazily) created in, and returned to, the `relevant_files`.
+/// Once all the workers are done, the global rules added by each of them are added to the `rule_store`,
+/// and their global substitutions to the `global_substitutions`, so that the next pass sees a consistent view.
+pub(crate) fn apply_rules_in_parallel(
+  files: HashMap<PathBuf, String>, rules: &[InstantiatedRule],
+  relevant_files: &mut HashMap<PathBuf, SourceCodeUnit>, rule_store: &mut RuleStore,
+  global_substitutions: &mut HashMap<String, String>, piranha_arguments: &PiranhaArguments,
+) {
+  let batches = partition_into_batches(get_packages(files), *piranha_arguments.jobs());
+  debug!(
+    "Applying the rules with {} workers to {} files",
+    batches.len(),
+    batches.iter().flatten().map(|p| p.len()).sum::<usize>()
+  );
+  // Each worker owns the source code units of its files during the pass
+  let work = batches
+    .into_iter()
+    .map(|batch| {
+      batch
+        .into_iter()
+        .flatten()
+        .map(|(path, content)| {
+          let source_code_unit = relevant_files.remove(&path);
+          (path, content, source_code_unit)
+        })
+        .collect_vec()
+    })
+    .collect_vec();
+  let substitutions = &*global_substitutions;
+  let results = thread::scope(|s| {
+    work
+      .into_iter()
+      .map(|files| {
+        let mut worker_rule_store = rule_store.fork();
+        s.spawn(move || {
+          let mut parser = piranha_arguments.language().parser();
+          let mut worker_substitutions = HashMap::new();
+          let mut source_code_units = vec![];
+          for (path, content, source_code_unit) in files {
+            let mut source_code_unit = source_code_unit.unwrap_or_else(|| {
+              SourceCodeUnit::new(
+                &mut parser,
+                content,
+                substitutions,
+                path.as_path(),
+                piranha_arguments,
+              )
+            });
+            source_code_unit.apply_rules(&mut worker_rule_store, rules, &mut parser, None);
+            worker_substitutions.extend(source_code_unit.global_substitutions());
+            source_code_units.push((path, source_code_unit));
+          }
+          (
+            source_code_units,
+            worker_rule_store.global_rules().clone(),
+            worker_substitutions,
+          )
+        })
+      })
+      .collect_vec()
+      .into_iter()
+      .map(|handle| handle.join().expect("A worker of the pool panicked"))
+      .collect_vec()
+  });
+  // The results are merged in the order of the batches, so that the global rules are added deterministically
+  for (source_code_units, global_rules, worker_substitutions) in results {
+    relevant_files.extend(source_code_units);
+    for rule in &global_rules {
+      rule_store.add_to_global_rules(rule);
+    }
+    global_substitutions.extend(worker_substitutions);
+  }
+}
+
+/// Groups the files by package (i.e. by directory), sorted by the path of the package and of the files
+fn get_packages(files: HashMap<PathBuf, String>) -> Vec<Package> {
+  let mut packages: BTreeMap<PathBuf, Package> = BTreeMap::new();
+  for (path, content) in files {
+    let package = path.parent().map(Path::to_path_buf).unwrap_or_default();
+    packages.entry(package).or_default().push((path, content));
+  }
+  packages
+    .into_values()
+    .map(|mut package| {
+      package.sort_by(|a, b| a.0.cmp(&b.0));
+      package
+    })
+    .collect()
+}
+
+/// Partitions the packages into (at most) `jobs` batches of similar sizes (i.e. number of files),
+/// assigning the largest packages first, each to the smallest batch so far.
+fn partition_into_batches(packages: Vec<Package>, jobs: usize) -> Vec<Vec<Package>> {
+  let mut batches: Vec<Vec<Package>> = vec![vec![]; jobs.max(1).min(packages.len().max(1))];
+  for package in packages
+    .into_iter()
+    .sorted_by(|a, b| b.len().cmp(&a.len()).then_with(|| a[0].0.cmp(&b[0].0)))
+  {
+    let smallest = (0..batches.len())
+      .min_by_key(|i| batches[*i].iter().map(|p| p.len()).sum::<usize>())
+      .unwrap_or_default();
+    batches[smallest].push(package);
+  }
+  batches
+}
+
+#[cfg(test)]
+#[path = "unit_tests/parallel_test.rs"]
+mod parallel_test;
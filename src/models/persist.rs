@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  fs::{self, File},
+  io::{self, Write},
+  path::{Path, PathBuf},
+  process,
+};
+
+use super::{changed_files::get_directory, piranha_arguments::PiranhaArguments};
+
+/// The extension appended to the backups kept alongside the rewritten files (see `backup`)
+static BACKUP_EXTENSION: &str = "orig";
+
+/// Writes the content to the file through a temporary file in the same directory, renamed over the file once it is fully
+/// written (and synced), so that an interrupted run never leaves a truncated file. The permissions of the file are kept,
+/// and a symbolic link is written through (i.e. its target is rewritten, rather than replaced with a regular file).
+pub(crate) fn write_atomically(path: &Path, content: &str) -> io::Result<()> {
+  let target = fs::canonicalize(path).unwrap_or_else(|_| path.to_path_buf());
+  let file_name = target
+    .file_name()
+    .map(|n| n.to_string_lossy().to_string())
+    .unwrap_or_default();
+  let temp_path = target.with_file_name(format!(".{file_name}.{}.piranha.tmp", process::id()));
+  let result = write_and_sync(&temp_path, content)
+    .and_then(|_| match fs::metadata(&target) {
+      Ok(metadata) => fs::set_permissions(&temp_path, metadata.permissions()),
+      Err(_) => Ok(()),
+    })
+    .and_then(|_| fs::rename(&temp_path, &target));
+  if result.is_err() {
+    _ = fs::remove_file(&temp_path);
+  }
+  result
+}
+
+fn write_and_sync(path: &Path, content: &str) -> io::Result<()> {
+  let mut file = File::create(path)?;
+  file.write_all(content.as_bytes())?;
+  file.sync_all()
+}
+
+/// Keeps a copy of the file before it is rewritten (or deleted), if `backup` is set (see `get_backup_path`).
+/// Returns the path of the backup, if any.
+pub(crate) fn back_up_file(
+  path: &Path, piranha_arguments: &PiranhaArguments,
+) -> Result<Option<PathBuf>, String> {
+  if !*piranha_arguments.backup() {
+    return Ok(None);
+  }
+  let backup_path = get_backup_path(
+    path,
+    piranha_arguments.path_to_codebase(),
+    piranha_arguments.backup_dir(),
+  );
+  if let Some(parent) = backup_path.parent() {
+    fs::create_dir_all(parent).map_err(|e| format!("Could not create {parent:?} : {e}"))?;
+  }
+  fs::copy(path, &backup_path)
+    .map_err(|e| format!("Could not back up {path:?} to {backup_path:?} : {e}"))?;
+  Ok(Some(backup_path))
+}
+
+/// Returns the path of the backup of the file, i.e. `<file>.orig` alongside it, or the file at the same path (relative to
+/// the code base) under the `backup_dir` (i.e. a shadow directory of the code base, e.g. for `diff -r`) if any.
+pub(crate) fn get_backup_path(path: &Path, path_to_codebase: &str, backup_dir: &str) -> PathBuf {
+  if backup_dir.is_empty() {
+    let mut backup_path = path.as_os_str().to_os_string();
+    backup_path.push(format!(".{BACKUP_EXTENSION}"));
+    return PathBuf::from(backup_path);
+  }
+  let directory = get_directory(path_to_codebase);
+  let relative_path = path
+    .strip_prefix(directory)
+    .map(|p| p.to_path_buf())
+    .ok()
+    .or_else(|| {
+      let (path, directory) = (path.canonicalize().ok()?, directory.canonicalize().ok()?);
+      path.strip_prefix(directory).map(|p| p.to_path_buf()).ok()
+    })
+    .or_else(|| path.file_name().map(PathBuf::from))
+    .unwrap_or_default();
+  Path::new(backup_dir).join(relative_path)
+}
+
+#[cfg(test)]
+#[path = "unit_tests/persist_test.rs"]
+mod persist_test;
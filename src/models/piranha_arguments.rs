@@ -12,33 +12,71 @@ Copyright (c) 2023 Uber Technologies, Inc.
 */
 
 use super::{
+  archive::ARCHIVE_PROVIDERS,
+  blast_radius::BLAST_RADIUS_ACTIONS,
+  build_verification::BUILD_VERIFICATIONS,
+  changed_files::{get_changed_files, get_staged_files},
+  confidence::get_confidence_rank,
   default_configs::{
-    default_allow_dirty_ast, default_cleanup_comments, default_cleanup_comments_buffer,
-    default_code_snippet, default_delete_consecutive_new_lines, default_delete_file_if_empty,
-    default_dry_run, default_exclude, default_global_tag_prefix, default_include,
-    default_number_of_ancestors_in_parent_scope, default_path_to_codebase,
-    default_path_to_configurations, default_path_to_output_summaries, default_piranha_language,
-    default_rule_graph, default_substitutions, GO, JAVA, KOTLIN, PYTHON, SWIFT, TSX, TYPESCRIPT,
+    default_aggressive_dead_code, default_allow_dirty_ast, default_archive,
+    default_archive_project, default_archive_url, default_backup, default_backup_dir,
+    default_blast_radius_action, default_branch_template, default_cache_dir, default_check,
+    default_cleanup_comments, default_cleanup_comments_buffer, default_code_snippet, default_color,
+    default_commit_message_template, default_confidence_threshold,
+    default_delete_consecutive_new_lines, default_delete_file_if_empty, default_diff_style,
+    default_dry_run, default_exclude, default_explain, default_flag_definition_files,
+    default_flag_file, default_flags, default_follow_symlinks, default_global_tag_prefix,
+    default_hook, default_http_address, default_http_root, default_include,
+    default_include_generated, default_interactive, default_inventory, default_jobs,
+    default_log_format, default_log_level, default_lsp, default_max_changed_files,
+    default_max_deleted_lines, default_max_iterations, default_notify_format, default_notify_url,
+    default_number_of_ancestors_in_parent_scope, default_package_loader, default_path_to_codebase,
+    default_path_to_configurations, default_path_to_journal, default_path_to_output_summaries,
+    default_path_to_patches, default_path_to_report, default_piranha_language,
+    default_post_processing_hook, default_pull_request, default_pull_request_base,
+    default_remove_unused_imports, default_report, default_revert, default_rule_graph,
+    default_rule_packs, default_scan, default_scm, default_scm_url, default_serve, default_since,
+    default_specialize_boolean_parameters, default_split_by, default_stale_after,
+    default_substitute_only, default_substitutions, default_template_files, default_verify_build,
+    default_verify_tests, default_watch, GO, JAVA, KOTLIN, PYTHON, SWIFT, TSX, TYPESCRIPT,
   },
-  language::PiranhaLanguage,
+  diff_preview::{COLORS, DIFF_STYLES},
+  dynamic_flag_names::STALE_FLAG_NAME,
+  explain::parse_explain_position,
+  flag_apis::read_flag_apis,
+  flag_file::{is_substitute_only, read_flag_file, FlagEntry, MODE, TREATED, TREATED_COMPLEMENT},
+  go_packages::PACKAGE_LOADERS,
+  journal::read_journal,
+  language::{PiranhaLanguage, SupportedLanguage},
+  logging::{init_logger, LOG_FORMATS, LOG_LEVELS},
+  notify::NOTIFY_FORMATS,
+  persist::{back_up_file, write_atomically},
+  report::REPORT_FORMATS,
   rule_graph::{read_user_config_files, RuleGraph, RuleGraphBuilder},
+  scan::parse_duration,
+  scm::SCMS,
   source_code_unit::SourceCodeUnit,
+  split::SPLIT_BY,
 };
-use crate::utilities::{parse_glob_pattern, parse_key_val};
+use crate::utilities::{parse_glob_pattern, parse_key_val, parse_key_vals};
 use clap::builder::TypedValueParser;
 use clap::Parser;
 use derive_builder::Builder;
 use getset::{CopyGetters, Getters};
 use glob::Pattern;
 use itertools::Itertools;
-use log::{info, warn};
+use log::{debug, info, warn};
 use pyo3::{
   prelude::{pyclass, pymethods},
   types::PyDict,
 };
 use regex::Regex;
 
-use std::collections::HashMap;
+use std::{
+  collections::{HashMap, HashSet},
+  error::Error,
+  path::{Path, PathBuf},
+};
 
 /// A refactoring tool that eliminates dead code related to stale feature flags
 #[derive(Clone, Getters, CopyGetters, Debug, Parser, Builder)]
@@ -52,13 +90,13 @@ pub struct PiranhaArguments {
   #[clap(short = 'c', long, required = true)]
   path_to_codebase: String,
 
-  /// Paths to include (as glob patterns)
+  /// Paths to include (as glob patterns, relative to the code base or not)
   #[get = "pub"]
   #[builder(default = "default_include()")]
   #[clap(long, value_parser = parse_glob_pattern, num_args = 0.., required=false)]
   include: Vec<Pattern>,
 
-  /// Paths to exclude (as glob patterns)
+  /// Paths to exclude (as glob patterns, relative to the code base or not), on top of the ones listed in its `.piranhaignore` file
   #[get = "pub"]
   #[builder(default = "default_exclude()")]
   #[clap(long, value_parser = parse_glob_pattern, num_args = 0.., required=false)]
@@ -129,7 +167,7 @@ pub struct PiranhaArguments {
   #[clap(long, default_value_t = default_cleanup_comments())]
   cleanup_comments: bool,
 
-  /// Disables in-place rewriting of code
+  /// Disables in-place rewriting of code. The command line interface prints the unified diff of the rewrites to stdout instead
   #[get = "pub"]
   #[builder(default = "default_dry_run()")]
   #[clap(long, default_value_t = false)]
@@ -146,6 +184,430 @@ pub struct PiranhaArguments {
   #[builder(default = "default_allow_dirty_ast()")]
   #[clap(long, default_value_t = default_allow_dirty_ast())]
   allow_dirty_ast: bool,
+
+  /// Deletes the imports that are no longer referenced after the rewrite (Go only)
+  #[get = "pub"]
+  #[builder(default = "default_remove_unused_imports()")]
+  #[clap(long, default_value_t = default_remove_unused_imports())]
+  remove_unused_imports: bool,
+
+  /// Deletes the functions that become empty after the cleanup, along with their call sites
+  #[get = "pub"]
+  #[builder(default = "default_aggressive_dead_code()")]
+  #[clap(long, default_value_t = default_aggressive_dead_code())]
+  aggressive_dead_code: bool,
+
+  /// Specializes the functions for the boolean literal passed by all their callers, and removes the parameter (Go only)
+  #[get = "pub"]
+  #[builder(default = "default_specialize_boolean_parameters()")]
+  #[clap(long, default_value_t = default_specialize_boolean_parameters())]
+  specialize_boolean_parameters: bool,
+
+  /// Rewrites the generated files (i.e. with the `// Code generated ... DO NOT EDIT.` header) as well (Go only)
+  #[get = "pub"]
+  #[builder(default = "default_include_generated()")]
+  #[clap(long, default_value_t = default_include_generated())]
+  include_generated: bool,
+
+  /// Walks over the symbolic links to directories of the code base as well (they are skipped by default)
+  #[get = "pub"]
+  #[builder(default = "default_follow_symlinks()")]
+  #[clap(long, default_value_t = default_follow_symlinks())]
+  follow_symlinks: bool,
+
+  /// Paths (as glob patterns, relative to the code base) to the YAML or JSON files defining the flags (e.g. `flags.yaml`),
+  /// from which the stanza of the stale flag (i.e. the `stale_flag_name` substitution) is deleted
+  #[get = "pub"]
+  #[builder(default = "default_flag_definition_files()")]
+  #[clap(long, num_args = 0.., required = false)]
+  flag_definition_files: Vec<String>,
+
+  /// Paths (as glob patterns, relative to the code base) to the (`html/template` or `text/template`) files, from which the
+  /// conditions on the stale flag (i.e. evaluated with the `template_field` or `template_function` substitution) are simplified
+  #[get = "pub"]
+  #[builder(default = "default_template_files()")]
+  #[clap(long, num_args = 0.., required = false)]
+  template_files: Vec<String>,
+
+  /// The built-in rule packs of the flag SDKs (e.g. `launchdarkly`) to enable, matching and replacing the SDK's flag APIs
+  /// with the `stale_flag_name` and `treated` substitutions (instead of hand-rolled rules)
+  #[get = "pub"]
+  #[builder(default = "default_rule_packs()")]
+  #[clap(long, num_args = 0.., required = false)]
+  rule_packs: Vec<String>,
+
+  /// The command (run with `sh -c`) post-processing the rewrites of each file before they are persisted.
+  /// It receives the file and its edits as JSON on stdin, and may veto or modify them (see `post_processing_hook.rs`)
+  #[get = "pub"]
+  #[builder(default = "default_post_processing_hook()")]
+  #[clap(long, default_value_t = default_post_processing_hook())]
+  post_processing_hook: String,
+
+  /// The flags to clean up in a single run, each as comma-separated substitutions (e.g. `stale_flag_name=SOME_FLAG,treated=true`)
+  /// extending (or overriding) the common `substitutions`, or only as its name (i.e. `SOME_FLAG` for `stale_flag_name=SOME_FLAG`).
+  /// The flags are cleaned up one after the other, on the same files
+  #[get = "pub"]
+  #[builder(default = "default_flags()")]
+  #[clap(long, alias = "flag", num_args = 0.., required = false)]
+  flags: Vec<String>,
+
+  /// Path to the (JSON or CSV) file listing the flags to clean up in a single run (e.g. exported by the flag management system),
+  /// each with its name, treated value and optionally the paths (as glob patterns, relative to the code base) within which it is cleaned up
+  #[get = "pub"]
+  #[builder(default = "default_flag_file()")]
+  #[clap(long, default_value_t = default_flag_file())]
+  flag_file: String,
+
+  /// Only substitutes the value of the stale flag for its evaluations (i.e. the flag APIs), leaving the code depending on it as it is
+  /// (e.g. `if true { ... }`), instead of cleaning it up. Can be set per flag with the `mode` (i.e. `substitute-only` or `cleanup`) of the flag
+  #[get = "pub"]
+  #[builder(default = "default_substitute_only()")]
+  #[clap(long, default_value_t = default_substitute_only())]
+  substitute_only: bool,
+
+  /// Only cleans up the files changed on the current branch since the given git ref (e.g. `origin/main`),
+  /// i.e. since their merge base, including the uncommitted and the untracked files
+  #[get = "pub"]
+  #[builder(default = "default_since()")]
+  #[clap(long, default_value_t = default_since())]
+  since: String,
+
+  // The (canonicalized) paths of the files changed since the `since` ref, or staged (i.e. `hook`), if any, i.e. the only files cleaned up
+  #[get = "pub(crate)"]
+  #[builder(default, setter(skip))]
+  #[clap(skip)]
+  changed_files: Option<HashSet<PathBuf>>,
+
+  /// The format (i.e. `json`, `sarif` or `analysis`) of the machine-readable report of the changes, listing the rewrites of each file, i.e. the flag,
+  /// the rule that fired, the edited range and the lines added and removed, and the sites that need a manual cleanup.
+  /// `html` is a standalone page with the before/after views of the rewritten files instead (e.g. for the non-CLI reviewers)
+  #[get = "pub"]
+  #[builder(default = "default_report()")]
+  #[clap(long, default_value_t = default_report())]
+  report: String,
+
+  /// Path to the file where the report of the changes is written (it is printed to stdout otherwise)
+  #[get = "pub"]
+  #[builder(default = "default_path_to_report()")]
+  #[clap(short = 'o', long)]
+  path_to_report: Option<String>,
+
+  /// Walks through the hunks of the rewrites of each file in the terminal (like `git add -p`) before they are persisted,
+  /// to apply, skip or edit (with `$VISUAL` or `$EDITOR`) each of them
+  #[get = "pub"]
+  #[builder(default = "default_interactive()")]
+  #[clap(long, default_value_t = default_interactive())]
+  interactive: bool,
+
+  /// The confidence (i.e. `low`, `medium` or `high`) below which the edits of the rules are not applied.
+  /// The code is annotated with a comment asking to verify the removal of the stale flag instead
+  #[get = "pub"]
+  #[builder(default = "default_confidence_threshold()")]
+  #[clap(long, default_value_t = default_confidence_threshold())]
+  confidence_threshold: String,
+
+  /// Only checks whether the stale flags are still used (i.e. the matches of the seed rules), without making any edit.
+  /// The usages are printed to stdout, and Piranha exits with a non-zero code if any remains (e.g. to gate CI).
+  /// The `treated` value of the flags defaults to `true`, since it does not matter. It is also invoked as `piranha check ...`.
+  #[get = "pub"]
+  #[builder(default = "default_check()")]
+  #[clap(long, default_value_t = default_check())]
+  check: bool,
+
+  /// The level of the logs (i.e. `off`, `error`, `warn`, `info`, `debug` or `trace`), overriding the default level of `RUST_LOG`.
+  /// At the `debug` level, each match of a rule is logged, along with the reason why it is rejected (if so), and the files written
+  #[get = "pub"]
+  #[builder(default = "default_log_level()")]
+  #[clap(long, default_value_t = default_log_level())]
+  log_level: String,
+
+  /// The format of the logs (i.e. `text` or `json`, i.e. a JSON object per line), written to stderr
+  #[get = "pub"]
+  #[builder(default = "default_log_format()")]
+  #[clap(long, default_value_t = default_log_format())]
+  log_format: String,
+
+  /// Explains why the code at the given position (i.e. `path/file.go:42`) was (or was not) cleaned up, without making any edit,
+  /// i.e. the rules attempted on the line and why each of them did not apply (e.g. a filter, a `piranha:ignore` directive)
+  #[get = "pub"]
+  #[builder(default = "default_explain()")]
+  #[clap(long, default_value_t = default_explain())]
+  explain: String,
+
+  /// The number of workers processing the files (i.e. whole packages) concurrently, defaulting to the number of CPUs.
+  /// The cross-file passes (e.g. the specialization of the boolean parameters) run once all the workers are done.
+  /// Its short name is `-J`, since `-j` is the (pre-existing) short name of `--path-to-output-summary`
+  #[get = "pub"]
+  #[builder(default = "default_jobs()")]
+  #[clap(short = 'J', long, default_value_t = default_jobs())]
+  jobs: usize,
+
+  /// The directory caching, across runs, the files found clean by the rules (keyed by the hash of their content),
+  /// so that the unchanged files are not analyzed again. The cache is invalidated by any change of the configuration
+  #[get = "pub"]
+  #[builder(default = "default_cache_dir()")]
+  #[clap(long, default_value_t = default_cache_dir())]
+  cache_dir: String,
+
+  /// The loader grouping the Go files into packages (i.e. `directory` or `go_list`), e.g. for the specialization of the boolean parameters.
+  /// `directory` groups the files of a directory by their package clause, `go_list` lists the packages with `go list`
+  /// (i.e. like `golang.org/x/tools/go/packages`), and falls back to `directory` if `go list` fails
+  #[get = "pub"]
+  #[builder(default = "default_package_loader()")]
+  #[clap(long, default_value_t = default_package_loader())]
+  package_loader: String,
+
+  /// The maximum number of iterations of the cleanup. The rules and the cross-file passes (e.g. the specialization of the
+  /// boolean parameters) are run again as long as they expose new opportunities to each other (i.e. to a fixed point),
+  /// and stopped early if the rewrites undo each other
+  #[get = "pub"]
+  #[builder(default = "default_max_iterations()")]
+  #[clap(long, default_value_t = default_max_iterations())]
+  max_iterations: usize,
+
+  /// Builds each rewritten Go package (along with its tests) before persisting the rewrites, i.e. `none`, `rollback`
+  /// (the rewrites of the packages that no longer build are discarded) or `report` (the packages that no longer build
+  /// are reported as `build_failure`). The packages that did not build before the cleanup are not verified
+  #[get = "pub"]
+  #[builder(default = "default_verify_build()")]
+  #[clap(long, default_value_t = default_verify_build())]
+  verify_build: String,
+
+  /// Runs the tests of each rewritten Go package (i.e. `go test`, after its build is verified) before persisting the
+  /// rewrites, and records their outcome in the report, i.e. `none`, `rollback` (the rewrites of the packages whose tests
+  /// fail are discarded) or `report` (the packages whose tests fail are reported as `test_failure`).
+  /// The packages whose tests did not pass before the cleanup are not verified
+  #[get = "pub"]
+  #[builder(default = "default_verify_tests()")]
+  #[clap(long, default_value_t = default_verify_tests())]
+  verify_tests: String,
+
+  /// The maximum number of files changed by the cleanup (unlimited if 0). A cleanup exceeding it (e.g. a mis-specified
+  /// rule rewriting much more than the code of the stale flag) is not persisted (see `--blast-radius-action`)
+  #[get = "pub"]
+  #[builder(default = "default_max_changed_files()")]
+  #[clap(long, default_value_t = default_max_changed_files())]
+  max_changed_files: usize,
+
+  /// The maximum number of lines deleted by the cleanup (unlimited if 0). A cleanup exceeding it is not persisted
+  /// (see `--blast-radius-action`)
+  #[get = "pub"]
+  #[builder(default = "default_max_deleted_lines()")]
+  #[clap(long, default_value_t = default_max_deleted_lines())]
+  max_deleted_lines: usize,
+
+  /// The action taken when the cleanup exceeds `--max-changed-files` or `--max-deleted-lines`, i.e. `abort` (the run
+  /// fails) or `dry_run` (the rewrites are printed as a unified diff instead, see `--dry-run`). In either case, the
+  /// rewrites are not persisted
+  #[get = "pub"]
+  #[builder(default = "default_blast_radius_action()")]
+  #[clap(long, default_value_t = default_blast_radius_action())]
+  blast_radius_action: String,
+
+  /// Keeps a copy of each file before it is rewritten (or deleted), i.e. `<file>.orig` alongside it (or in the `--backup-dir`),
+  /// e.g. to compare the rewrites without git
+  #[get = "pub"]
+  #[builder(default = "default_backup()")]
+  #[clap(long, default_value_t = default_backup())]
+  backup: bool,
+
+  /// The directory (outside of the code base) where the copies of the `--backup` are kept instead, at the same path as the
+  /// files relative to the code base (i.e. a shadow directory of the code base, e.g. to compare with `diff -r`)
+  #[get = "pub"]
+  #[builder(default = "default_backup_dir()")]
+  #[clap(long, default_value_t = default_backup_dir())]
+  backup_dir: String,
+
+  /// Runs Piranha as a long-running server cleaning up the flags on request, instead of a single run (see `--lsp` and
+  /// `--http-address`). It is also invoked as `piranha serve ...`
+  #[get = "pub"]
+  #[builder(default = "default_serve()")]
+  #[clap(long, default_value_t = default_serve())]
+  serve: bool,
+
+  /// Serves the Language Server Protocol over stdio (i.e. `piranha serve --lsp`), e.g. for the editors. The usages of the flags
+  /// in the open files are published as diagnostics, and their cleanup in the file or its package (i.e. its directory) is exposed
+  /// as a code action (i.e. `Remove stale flag: SOME_FLAG`), applied by the editor
+  #[get = "pub"]
+  #[builder(default = "default_lsp()")]
+  #[clap(long, default_value_t = default_lsp())]
+  lsp: bool,
+
+  /// Serves the HTTP API on the address (e.g. `127.0.0.1:8080`, i.e. `piranha serve --http-address ...`), e.g. as an internal
+  /// service. The cleanup jobs (i.e. the flags and the path of the code base, or its `.tar.gz` archive) are submitted to `POST /jobs`,
+  /// and their status, diff and report are fetched from `GET /jobs/{id}`, `GET /jobs/{id}/diff` and `GET /jobs/{id}/report`
+  #[get = "pub"]
+  #[builder(default = "default_http_address()")]
+  #[clap(long, default_value_t = default_http_address())]
+  http_address: String,
+
+  /// The directory on the host of the HTTP server the code bases of its jobs (i.e. their `path_to_codebase`, resolved relative
+  /// to it) must be in. Without it, the jobs can only submit their code base as an archive
+  #[get = "pub"]
+  #[builder(default = "default_http_root()")]
+  #[clap(long, default_value_t = default_http_root())]
+  http_root: String,
+
+  /// Runs as a git pre-commit hook (i.e. `piranha hook ...`), only cleaning up the staged files (or checking them, along with `--check`),
+  /// and staging the rewritten files again. The rewritten files with unstaged changes are not staged, and the hook fails
+  #[get = "pub"]
+  #[builder(default = "default_hook()")]
+  #[clap(long, default_value_t = default_hook())]
+  hook: bool,
+
+  /// Cleans up each of the flags on its own branch (created from `pull_request_base`), committed (with the `commit_message_template`)
+  /// and pushed to `origin`, and opens a pull request for it on the `scm` (i.e. GitHub, GitLab or Bitbucket).
+  /// The code owners (i.e. `CODEOWNERS`) of the rewritten files are requested as reviewers
+  #[get = "pub"]
+  #[builder(default = "default_pull_request()")]
+  #[clap(long, default_value_t = default_pull_request())]
+  pull_request: bool,
+
+  /// The branch the pull requests are based on (i.e. `pull_request`). Defaults to the current branch
+  #[get = "pub"]
+  #[builder(default = "default_pull_request_base()")]
+  #[clap(long, default_value_t = default_pull_request_base())]
+  pull_request_base: String,
+
+  /// The template of the name of the branch of each flag (i.e. `pull_request`), where `{flag}` is the name of the flag,
+  /// and the names of the substitutions of the flag (e.g. `{treated}`) their value
+  #[get = "pub"]
+  #[builder(default = "default_branch_template()")]
+  #[clap(long, default_value_t = default_branch_template())]
+  branch_template: String,
+
+  /// The template of the commit message of each flag (i.e. `pull_request`), with the placeholders of the `branch_template`.
+  /// Its first line is the title of the pull request, and the rest is prepended to its body (i.e. the summary of the change)
+  #[get = "pub"]
+  #[builder(default = "default_commit_message_template()")]
+  #[clap(long, default_value_t = default_commit_message_template())]
+  commit_message_template: String,
+
+  /// The platform hosting the repository the pull requests are opened on (i.e. `pull_request`), i.e. `github`, `gitlab`
+  /// (merge requests) or `bitbucket`. Its token is read from the `GITHUB_TOKEN`, `GITLAB_TOKEN` or `BITBUCKET_TOKEN` environment variable
+  #[get = "pub"]
+  #[builder(default = "default_scm()")]
+  #[clap(long, default_value_t = default_scm())]
+  scm: String,
+
+  /// The URL of the API of the `scm`, e.g. of a self-hosted instance (`https://gitlab.example.com/api/v4`, or the root URL of
+  /// Bitbucket Data Center). Defaults to the one of the public instance (or the `GITHUB_API_URL` or `CI_API_V4_URL` environment variable)
+  #[get = "pub"]
+  #[builder(default = "default_scm_url()")]
+  #[clap(long, default_value_t = default_scm_url())]
+  scm_url: String,
+
+  /// Splits the rewritten files into chunks reviewable on their own, i.e. `package` (the directory of each file), `directory`
+  /// (its top-level directory under the code base) or `owner` (its code owners). The chunks sharing a deleted declaration are merged.
+  /// Each chunk is written as a patch under `path_to_patches`, or opened as its own pull request (i.e. `pull_request`)
+  #[get = "pub"]
+  #[builder(default = "default_split_by()")]
+  #[clap(long, default_value_t = default_split_by())]
+  split_by: String,
+
+  /// Path to the directory where the patch of each chunk (i.e. `split_by`) is written, instead of rewriting the files
+  #[get = "pub"]
+  #[builder(default = "default_path_to_patches()")]
+  #[clap(long)]
+  path_to_patches: Option<String>,
+
+  /// Path to the journal file where the edits of the run (i.e. the original byte ranges and the replaced text) are recorded,
+  /// appended to the ones of the previous runs, so that they can be reverted (i.e. `revert`)
+  #[get = "pub"]
+  #[builder(default = "default_path_to_journal()")]
+  #[clap(long)]
+  path_to_journal: Option<String>,
+
+  /// Reverts the edits recorded in the journal (i.e. `piranha revert --path-to-journal ...`) for the files under the code base,
+  /// even after other edits, as long as the regions of the edits are unchanged (the other ones are reported as conflicts)
+  #[get = "pub"]
+  #[builder(default = "default_revert()")]
+  #[clap(long, default_value_t = default_revert())]
+  revert: bool,
+
+  /// Archives the definition of each cleaned up flag with the management API of its provider, i.e. `launchdarkly`, `unleash`
+  /// or `webhook` (posting the flag to the `archive_url`), once all its references are removed from the code base.
+  /// The token is read from the `LAUNCHDARKLY_ACCESS_TOKEN`, `UNLEASH_API_TOKEN` or `ARCHIVE_WEBHOOK_TOKEN` environment variable
+  #[get = "pub"]
+  #[builder(default = "default_archive()")]
+  #[clap(long, default_value_t = default_archive())]
+  archive: String,
+
+  /// The URL of the API of the flag provider (i.e. `archive`), e.g. of the Unleash instance or of the webhook.
+  /// Defaults to `https://app.launchdarkly.com` for LaunchDarkly
+  #[get = "pub"]
+  #[builder(default = "default_archive_url()")]
+  #[clap(long, default_value_t = default_archive_url())]
+  archive_url: String,
+
+  /// The project of the flags in the flag provider (i.e. `archive`), i.e. the key of the LaunchDarkly project or the id of the Unleash one
+  #[get = "pub"]
+  #[builder(default = "default_archive_project()")]
+  #[clap(long, default_value_t = default_archive_project())]
+  archive_project: String,
+
+  /// Lists the call sites of the flag APIs (i.e. the seed rules) for the stale flag, or for all the flags without `stale_flag_name`,
+  /// instead of cleaning up, i.e. their position, enclosing function, API and usage (i.e. a direct check, an assignment or
+  /// a pass-through parameter) (i.e. `piranha report`)
+  #[get = "pub"]
+  #[builder(default = "default_inventory()")]
+  #[clap(long, default_value_t = default_inventory())]
+  inventory: bool,
+
+  /// Inventories the call sites of the flag APIs (i.e. the seed rules, for any flag name) instead of cleaning up, and prints
+  /// the flags not modified for `stale_after` (according to git), the most stale ones first, along with their owners (i.e. `piranha scan`)
+  #[get = "pub"]
+  #[builder(default = "default_scan()")]
+  #[clap(long, default_value_t = default_scan())]
+  scan: bool,
+
+  /// The duration after which an unmodified flag is likely stale (i.e. `scan`), e.g. `180d`, `26w`, `6m` or `1y`
+  #[get = "pub"]
+  #[builder(default = "default_stale_after()")]
+  #[clap(long, default_value_t = default_stale_after())]
+  stale_after: String,
+
+  /// The URL of the webhook the summary of the run is posted to (e.g. from a scheduled CI job), i.e. the cleaned up flags,
+  /// the changed files, the opened pull requests and the failures (if any), along with the URL of the CI job.
+  /// The token (if any) is read from the `NOTIFY_WEBHOOK_TOKEN` environment variable
+  #[get = "pub"]
+  #[builder(default = "default_notify_url()")]
+  #[clap(long, default_value_t = default_notify_url())]
+  notify_url: String,
+
+  /// The format of the summary posted to the `notify_url`, i.e. `json` or `slack` (i.e. a message for an incoming webhook of Slack)
+  #[get = "pub"]
+  #[builder(default = "default_notify_format()")]
+  #[clap(long, default_value_t = default_notify_format())]
+  notify_format: String,
+
+  /// Cleans up in dry-run, and cleans up again whenever the files of the language in the code base or the rule configuration
+  /// (i.e. the files of `path_to_configurations`) change, printing the updated diff, until interrupted
+  #[get = "pub"]
+  #[builder(default = "default_watch()")]
+  #[clap(long, default_value_t = default_watch())]
+  watch: bool,
+
+  /// The style of the diff printed in dry-run (see `--dry-run` and `--watch`), i.e. `unified` (e.g. to be piped into
+  /// `git apply`) or `side_by_side` (the original and the rewritten code in two columns fitting in the width of the
+  /// terminal, i.e. `COLUMNS`)
+  #[get = "pub"]
+  #[builder(default = "default_diff_style()")]
+  #[clap(long, default_value_t = default_diff_style())]
+  diff_style: String,
+
+  /// Colors the diff (and the interactive review), i.e. `auto` (only when stdout is a terminal and `NO_COLOR` is not set),
+  /// `always` or `never`
+  #[get = "pub"]
+  #[builder(default = "default_color()")]
+  #[clap(long, default_value_t = default_color())]
+  color: String,
+
+  // The arguments for cleaning up each of the `flags` and the flags of the `flag_file` (i.e. with the substitutions and the rule graph of the flag)
+  #[get = "pub(crate)"]
+  #[builder(default, setter(skip))]
+  #[clap(skip)]
+  flag_arguments: Vec<PiranhaArguments>,
 }
 
 impl Default for PiranhaArguments {
@@ -174,6 +636,38 @@ impl PiranhaArguments {
   /// * delete_file_if_empty (bool): User option that determines whether an empty file will be deleted
   /// * path_to_output_summary : Path to the file where the Piranha output summary should be persisted
   /// * allow_dirty_ast : Allows syntax errors in the input source code
+  /// * remove_unused_imports (bool) : Deletes the imports that are no longer referenced after the rewrite (Go only)
+  /// * aggressive_dead_code (bool) : Deletes the functions that become empty after the cleanup, along with their call sites
+  /// * specialize_boolean_parameters (bool) : Specializes the functions for the boolean literal passed by all their callers, and removes the parameter (Go only)
+  /// * include_generated (bool) : Rewrites the generated files (i.e. with the `// Code generated ... DO NOT EDIT.` header) as well (Go only)
+  /// * follow_symlinks (bool) : Walks over the symbolic links to directories of the code base as well
+  /// * flag_definition_files : Paths (as glob patterns, relative to the code base) to the YAML or JSON files defining the flags, from which the stanza of the stale flag is deleted
+  /// * template_files : Paths (as glob patterns, relative to the code base) to the template files, from which the conditions on the stale flag are simplified
+  /// * rule_packs : The built-in rule packs of the flag SDKs (e.g. `launchdarkly`) to enable (Go only)
+  /// * post_processing_hook : The command post-processing the rewrites of each file before they are persisted
+  /// * flags : The flags to clean up in a single run, each as comma-separated substitutions (e.g. `stale_flag_name=SOME_FLAG,treated=true`)
+  /// * flag_file : Path to the (JSON or CSV) file listing the flags to clean up in a single run
+  /// * substitute_only (bool) : Only substitutes the value of the stale flag for its evaluations, leaving the code depending on it as it is
+  /// * since : Only cleans up the files changed on the current branch since the given git ref (e.g. `origin/main`)
+  /// * report : The format (i.e. `json`, `sarif`, `analysis` or `html`) of the report of the changes
+  /// * path_to_report : Path to the file where the report of the changes is written
+  /// * interactive (bool) : Walks through the hunks of the rewrites of each file in the terminal before they are persisted
+  /// * confidence_threshold : The confidence (i.e. `low`, `medium` or `high`) below which the edits of the rules are annotated instead of applied
+  /// * check (bool) : Only checks whether the stale flags are still used, without making any edit
+  /// * log_level : The level of the logs of the CLI (from Python, the logs are forwarded to the `logging` module)
+  /// * log_format : The format (i.e. `text` or `json`) of the logs of the CLI
+  /// * explain : Explains why the code at the given position (i.e. `path:line`) was (or was not) cleaned up (see `explain_piranha`)
+  /// * jobs : The number of workers processing the files concurrently (defaults to the number of CPUs)
+  /// * cache_dir : The directory caching the files found clean by the rules across runs
+  /// * package_loader : The loader grouping the Go files into packages (i.e. `directory` or `go_list`)
+  /// * max_iterations : The maximum number of iterations of the cleanup, run to a fixed point
+  /// * verify_build : Builds the rewritten Go packages, and rolls back (`rollback`) or reports (`report`) those that no longer build
+  /// * verify_tests : Runs the tests of the rewritten Go packages, and rolls back (`rollback`) or reports (`report`) those whose tests fail
+  /// * max_changed_files : The maximum number of files changed by the cleanup, beyond which it is not persisted (unlimited if 0)
+  /// * max_deleted_lines : The maximum number of lines deleted by the cleanup, beyond which it is not persisted (unlimited if 0)
+  /// * blast_radius_action : The action taken when the cleanup exceeds its limits, i.e. `abort` or `dry_run`
+  /// * backup (bool) : Keeps a copy of each file before it is rewritten (or deleted), i.e. `<file>.orig` alongside it
+  /// * backup_dir : The directory (outside of the code base) where the copies of the `backup` are kept instead
   /// Returns PiranhaArgument.
   #[new]
   fn py_new(
@@ -184,7 +678,19 @@ impl PiranhaArguments {
     cleanup_comments_buffer: Option<i32>, number_of_ancestors_in_parent_scope: Option<u8>,
     delete_consecutive_new_lines: Option<bool>, global_tag_prefix: Option<String>,
     delete_file_if_empty: Option<bool>, path_to_output_summary: Option<String>,
-    allow_dirty_ast: Option<bool>,
+    allow_dirty_ast: Option<bool>, remove_unused_imports: Option<bool>,
+    aggressive_dead_code: Option<bool>, specialize_boolean_parameters: Option<bool>,
+    include_generated: Option<bool>, follow_symlinks: Option<bool>,
+    flag_definition_files: Option<Vec<String>>, template_files: Option<Vec<String>>,
+    rule_packs: Option<Vec<String>>, post_processing_hook: Option<String>,
+    flags: Option<Vec<String>>, flag_file: Option<String>, substitute_only: Option<bool>,
+    since: Option<String>, report: Option<String>, path_to_report: Option<String>,
+    interactive: Option<bool>, confidence_threshold: Option<String>, check: Option<bool>,
+    log_level: Option<String>, log_format: Option<String>, explain: Option<String>,
+    jobs: Option<usize>, cache_dir: Option<String>, package_loader: Option<String>,
+    max_iterations: Option<usize>, verify_build: Option<String>, verify_tests: Option<String>,
+    max_changed_files: Option<usize>, max_deleted_lines: Option<usize>,
+    blast_radius_action: Option<String>, backup: Option<bool>, backup_dir: Option<String>,
   ) -> Self {
     let subs = if substitutions.is_some() {
       substitutions
@@ -234,6 +740,40 @@ impl PiranhaArguments {
       .delete_file_if_empty(delete_file_if_empty.unwrap_or_else(default_delete_file_if_empty))
       .path_to_output_summary(path_to_output_summary)
       .allow_dirty_ast(allow_dirty_ast.unwrap_or_else(default_allow_dirty_ast))
+      .remove_unused_imports(remove_unused_imports.unwrap_or_else(default_remove_unused_imports))
+      .aggressive_dead_code(aggressive_dead_code.unwrap_or_else(default_aggressive_dead_code))
+      .specialize_boolean_parameters(
+        specialize_boolean_parameters.unwrap_or_else(default_specialize_boolean_parameters),
+      )
+      .include_generated(include_generated.unwrap_or_else(default_include_generated))
+      .follow_symlinks(follow_symlinks.unwrap_or_else(default_follow_symlinks))
+      .flag_definition_files(flag_definition_files.unwrap_or_else(default_flag_definition_files))
+      .template_files(template_files.unwrap_or_else(default_template_files))
+      .rule_packs(rule_packs.unwrap_or_else(default_rule_packs))
+      .post_processing_hook(post_processing_hook.unwrap_or_else(default_post_processing_hook))
+      .flags(flags.unwrap_or_else(default_flags))
+      .flag_file(flag_file.unwrap_or_else(default_flag_file))
+      .substitute_only(substitute_only.unwrap_or_else(default_substitute_only))
+      .since(since.unwrap_or_else(default_since))
+      .report(report.unwrap_or_else(default_report))
+      .path_to_report(path_to_report)
+      .interactive(interactive.unwrap_or_else(default_interactive))
+      .confidence_threshold(confidence_threshold.unwrap_or_else(default_confidence_threshold))
+      .check(check.unwrap_or_else(default_check))
+      .log_level(log_level.unwrap_or_else(default_log_level))
+      .log_format(log_format.unwrap_or_else(default_log_format))
+      .explain(explain.unwrap_or_else(default_explain))
+      .jobs(jobs.unwrap_or_else(default_jobs))
+      .cache_dir(cache_dir.unwrap_or_else(default_cache_dir))
+      .package_loader(package_loader.unwrap_or_else(default_package_loader))
+      .max_iterations(max_iterations.unwrap_or_else(default_max_iterations))
+      .verify_build(verify_build.unwrap_or_else(default_verify_build))
+      .verify_tests(verify_tests.unwrap_or_else(default_verify_tests))
+      .max_changed_files(max_changed_files.unwrap_or_else(default_max_changed_files))
+      .max_deleted_lines(max_deleted_lines.unwrap_or_else(default_max_deleted_lines))
+      .blast_radius_action(blast_radius_action.unwrap_or_else(default_blast_radius_action))
+      .backup(backup.unwrap_or_else(default_backup))
+      .backup_dir(backup_dir.unwrap_or_else(default_backup_dir))
       .build()
   }
 }
@@ -243,10 +783,20 @@ impl PiranhaArguments {
     self.language.extension().to_string()
   }
 
+  /// Parses the arguments of the CLI. The logger is initialized (see `log_level` and `log_format`)
+  /// before the arguments are built, so that the logs of the build (e.g. reading the flag file) are recorded.
   pub fn from_cli() -> Self {
-    let p = PiranhaArguments::parse();
+    PiranhaArguments::from_args(std::env::args())
+  }
+
+  /// Parses the given command line (i.e. the name of the binary, followed by the arguments), see `from_cli`.
+  pub(crate) fn from_args(args: impl Iterator<Item = String>) -> Self {
+    let p = PiranhaArguments::parse_from(expand_commands(args));
+    init_logger(p.log_level(), p.log_format());
     PiranhaArgumentsBuilder::default()
       .path_to_codebase(p.path_to_codebase().to_string())
+      .include(p.include().clone())
+      .exclude(p.exclude().clone())
       .substitutions(p.substitutions.clone())
       .language(p.language().clone())
       .path_to_configurations(p.path_to_configurations().to_string())
@@ -258,12 +808,183 @@ impl PiranhaArguments {
       .cleanup_comments_buffer(*p.cleanup_comments_buffer())
       .cleanup_comments(*p.cleanup_comments())
       .dry_run(*p.dry_run())
+      .remove_unused_imports(*p.remove_unused_imports())
+      .aggressive_dead_code(*p.aggressive_dead_code())
+      .specialize_boolean_parameters(*p.specialize_boolean_parameters())
+      .include_generated(*p.include_generated())
+      .follow_symlinks(*p.follow_symlinks())
+      .flag_definition_files(p.flag_definition_files().clone())
+      .template_files(p.template_files().clone())
+      .rule_packs(p.rule_packs().clone())
+      .post_processing_hook(p.post_processing_hook().to_string())
+      .flags(p.flags().clone())
+      .flag_file(p.flag_file().to_string())
+      .substitute_only(*p.substitute_only())
+      .since(p.since().to_string())
+      .report(p.report().to_string())
+      .path_to_report(p.path_to_report().clone())
+      .interactive(*p.interactive())
+      .confidence_threshold(p.confidence_threshold().to_string())
+      .check(*p.check())
+      .log_level(p.log_level().to_string())
+      .log_format(p.log_format().to_string())
+      .explain(p.explain().to_string())
+      .jobs(*p.jobs())
+      .cache_dir(p.cache_dir().to_string())
+      .package_loader(p.package_loader().to_string())
+      .max_iterations(*p.max_iterations())
+      .verify_build(p.verify_build().to_string())
+      .verify_tests(p.verify_tests().to_string())
+      .max_changed_files(*p.max_changed_files())
+      .max_deleted_lines(*p.max_deleted_lines())
+      .blast_radius_action(p.blast_radius_action().to_string())
+      .backup(*p.backup())
+      .backup_dir(p.backup_dir().to_string())
+      .serve(*p.serve())
+      .lsp(*p.lsp())
+      .http_address(p.http_address().to_string())
+      .http_root(p.http_root().to_string())
+      .hook(*p.hook())
+      .pull_request(*p.pull_request())
+      .pull_request_base(p.pull_request_base().to_string())
+      .branch_template(p.branch_template().to_string())
+      .commit_message_template(p.commit_message_template().to_string())
+      .scm(p.scm().to_string())
+      .scm_url(p.scm_url().to_string())
+      .split_by(p.split_by().to_string())
+      .path_to_patches(p.path_to_patches().clone())
+      .path_to_journal(p.path_to_journal().clone())
+      .revert(*p.revert())
+      .archive(p.archive().to_string())
+      .archive_url(p.archive_url().to_string())
+      .archive_project(p.archive_project().to_string())
+      .inventory(*p.inventory())
+      .scan(*p.scan())
+      .stale_after(p.stale_after().to_string())
+      .notify_url(p.notify_url().to_string())
+      .notify_format(p.notify_format().to_string())
+      .watch(*p.watch())
+      .diff_style(p.diff_style().to_string())
+      .color(p.color().to_string())
       .build()
   }
 
   pub(crate) fn input_substitutions(&self) -> HashMap<String, String> {
     self.substitutions.iter().cloned().collect()
   }
+
+  /// Returns the arguments for cleaning up a flag, whose `substitutions` extend (or override) the common ones.
+  /// When `paths` are given (i.e. glob patterns relative to the code base), only the matching files are searched for the flag.
+  /// The `substitute_only` mode of the flag (if given) overrides the common one.
+  fn get_flag_arguments(
+    &self, flag_substitutions: Vec<(String, String)>, paths: &[String],
+    substitute_only: Option<bool>,
+  ) -> PiranhaArguments {
+    let mut substitutions = self.substitutions.clone();
+    for (key, value) in flag_substitutions {
+      substitutions.retain(|(k, _)| *k != key);
+      substitutions.push((key, value));
+    }
+    let include = if paths.is_empty() {
+      self.include.clone()
+    } else {
+      paths
+        .iter()
+        .filter_map(|p| Pattern::new(Path::new(&self.path_to_codebase).join(p).to_str()?).ok())
+        .collect_vec()
+    };
+    let flag_arguments = PiranhaArguments {
+      substitutions,
+      include,
+      substitute_only: substitute_only.unwrap_or(self.substitute_only),
+      flags: vec![],
+      flag_file: String::new(),
+      ..self.clone()
+    };
+    PiranhaArguments {
+      rule_graph: get_rule_graph(&flag_arguments),
+      ..flag_arguments
+    }
+  }
+
+  /// Returns these arguments (and the ones of each of the `flags`) for the code base at `path_to_codebase`, without writing
+  /// the files (i.e. `dry_run`), nor the output summaries and the report, e.g. to clean up the files of `clean` in memory.
+  pub(crate) fn in_memory(&self, path_to_codebase: &str) -> PiranhaArguments {
+    PiranhaArguments {
+      path_to_codebase: path_to_codebase.to_string(),
+      dry_run: true,
+      path_to_output_summary: None,
+      report: String::new(),
+      path_to_report: None,
+      flag_arguments: self
+        .flag_arguments
+        .iter()
+        .map(|a| a.in_memory(path_to_codebase))
+        .collect(),
+      ..self.clone()
+    }
+  }
+
+  /// Returns these arguments (and the ones of each of the `flags`) with the rule graph read again from the `path_to_configurations`,
+  /// e.g. once the rules are edited (see `watch`).
+  pub(crate) fn reload_rule_graph(&self) -> PiranhaArguments {
+    if self.path_to_configurations.is_empty() {
+      return self.clone();
+    }
+    PiranhaArguments {
+      rule_graph: get_rule_graph(self),
+      flag_arguments: self
+        .flag_arguments
+        .iter()
+        .map(|a| a.reload_rule_graph())
+        .collect(),
+      ..self.clone()
+    }
+  }
+
+  /// Returns these arguments for cleaning up the flags of the `entries` (i.e. of a flag file) in the code base at `path_to_codebase`,
+  /// without writing the files (see `in_memory`), e.g. for a job of the HTTP server (see `http_address`).
+  pub(crate) fn for_flags(
+    &self, path_to_codebase: &str, entries: &[FlagEntry],
+  ) -> PiranhaArguments {
+    let arguments = PiranhaArguments {
+      flag_arguments: vec![],
+      ..self.in_memory(path_to_codebase)
+    };
+    PiranhaArguments {
+      flag_arguments: entries
+        .iter()
+        .map(|e| arguments.get_flag_arguments(e.substitutions(), &e.paths, e.substitute_only()))
+        .collect(),
+      ..arguments
+    }
+  }
+
+  /// Returns the names of the seed rules of these arguments and of each of the `flags`.
+  /// The seed rules match the flag APIs, i.e. their rewrites (and matches) are the evaluations of the stale flags.
+  pub(crate) fn get_seed_rule_names(&self) -> HashSet<String> {
+    std::iter::once(self)
+      .chain(self.flag_arguments())
+      .flat_map(|a| a.rule_graph().rules())
+      .filter(|r| *r.is_seed_rule())
+      .map(|r| r.name().to_string())
+      .collect()
+  }
+
+  /// Returns the name of the flag cleaned up with these arguments, i.e. its `stale_flag_name` (or its substitutions, if not substituted)
+  pub(crate) fn get_flag_name(&self) -> String {
+    self
+      .input_substitutions()
+      .get(STALE_FLAG_NAME)
+      .cloned()
+      .unwrap_or_else(|| {
+        self
+          .substitutions
+          .iter()
+          .map(|(k, v)| format!("{k}={v}"))
+          .join(",")
+      })
+  }
 }
 
 impl PiranhaArgumentsBuilder {
@@ -277,9 +998,60 @@ impl PiranhaArgumentsBuilder {
     };
 
     let mut _arg = self.create().unwrap();
+    // No edit is made when explaining a position
+    if !_arg.explain.is_empty() {
+      _arg.dry_run = true;
+    }
+    // The chunks are written as patches instead of rewriting the files
+    if _arg.path_to_patches.is_some() {
+      _arg.dry_run = true;
+    }
+    // No edit is made in the check mode, where the value of the flags does not matter
+    if _arg.check {
+      _arg.dry_run = true;
+      for (key, value) in [(TREATED, "true"), (TREATED_COMPLEMENT, "false")] {
+        if !_arg.substitutions.iter().any(|(k, _)| k == key) {
+          _arg
+            .substitutions
+            .push((key.to_string(), value.to_string()));
+        }
+      }
+    }
+    if !_arg.since().is_empty() {
+      _arg.changed_files = get_changed_files(_arg.path_to_codebase(), _arg.since()).ok();
+    }
+    if _arg.hook {
+      _arg.changed_files = get_staged_files(_arg.path_to_codebase()).ok();
+    }
 
+    let mut flag_arguments = _arg
+      .flags()
+      .iter()
+      .map(|flag| {
+        // The mode of the flag (if any) is not a substitution
+        let (modes, substitutions): (Vec<_>, Vec<_>) = parse_flag(flag)
+          .unwrap_or_default()
+          .into_iter()
+          .partition(|(k, _)| k == MODE);
+        let substitute_only = modes.last().and_then(|(_, m)| is_substitute_only(m).ok());
+        _arg.get_flag_arguments(substitutions, &[], substitute_only)
+      })
+      .collect_vec();
+    if !_arg.flag_file().is_empty() {
+      for flag_entry in read_flag_file(_arg.flag_file()).unwrap_or_default() {
+        flag_arguments.push(_arg.get_flag_arguments(
+          flag_entry.substitutions(),
+          &flag_entry.paths,
+          flag_entry.substitute_only(),
+        ));
+      }
+    }
     let rule_graph = get_rule_graph(&_arg);
-    _arg = PiranhaArguments { rule_graph, .._arg };
+    _arg = PiranhaArguments {
+      rule_graph,
+      flag_arguments,
+      .._arg
+    };
     #[rustfmt::skip]
     info!( "Number of rules and edges loaded : {:?}", _arg.rule_graph().get_number_of_rules_and_edges());
     _arg
@@ -287,7 +1059,11 @@ impl PiranhaArgumentsBuilder {
 
   fn _validate(&self) -> Result<bool, String> {
     let _arg: PiranhaArguments = self.create().unwrap();
-    if _arg.code_snippet().is_empty() && _arg.path_to_codebase().is_empty() {
+    // The code base of the HTTP server is the one of each job
+    if _arg.code_snippet().is_empty()
+      && _arg.path_to_codebase().is_empty()
+      && _arg.http_address().is_empty()
+    {
       return Err(
         "Invalid Piranha Argument. Missing `path_to_codebase` or `code_snippet`. 
       Please specify the `path_to_codebase` or `code_snippet` when creating PiranhaArgument !!!"
@@ -302,10 +1078,323 @@ impl PiranhaArgumentsBuilder {
       );
     }
 
+    if let Some(flag) = _arg.flags().iter().find(|f| parse_flag(f).is_err()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The flag `{flag}` is not a comma-separated list of substitutions (e.g. `stale_flag_name=SOME_FLAG,treated=true`) !!!"
+      ));
+    }
+
+    for flag in _arg.flags() {
+      for (_, mode) in parse_flag(flag)
+        .unwrap_or_default()
+        .iter()
+        .filter(|(k, _)| k == MODE)
+      {
+        if let Err(e) = is_substitute_only(mode) {
+          return Err(format!(
+            "Invalid Piranha arguments. {e} for the flag `{flag}` !!!"
+          ));
+        }
+      }
+    }
+
+    if !_arg.flag_file().is_empty() {
+      if let Err(e) = read_flag_file(_arg.flag_file()) {
+        return Err(format!(
+          "Invalid Piranha arguments. Could not read the flag file `{}` : {e} !!!",
+          _arg.flag_file()
+        ));
+      }
+    }
+
+    if !_arg.since().is_empty() {
+      if let Err(e) = get_changed_files(_arg.path_to_codebase(), _arg.since()) {
+        return Err(format!(
+          "Invalid Piranha arguments. Could not get the files changed since `{}` : {e} !!!",
+          _arg.since()
+        ));
+      }
+    }
+
+    if *_arg.hook() {
+      if !_arg.since().is_empty() {
+        return Err(
+          "Invalid Piranha arguments. The hook only cleans up the staged files, i.e. `hook` and `since` are exclusive !!!"
+            .to_string(),
+        );
+      }
+      if let Err(e) = get_staged_files(_arg.path_to_codebase()) {
+        return Err(format!(
+          "Invalid Piranha arguments. Could not get the staged files : {e} !!!"
+        ));
+      }
+    }
+
+    if *_arg.pull_request()
+      && (*_arg.dry_run() || *_arg.check() || *_arg.hook() || _arg.path_to_patches().is_some())
+    {
+      return Err(
+        "Invalid Piranha arguments. The pull requests commit the rewritten files, i.e. `pull_request` is exclusive with `dry_run`, `check`, `hook` and `path_to_patches` !!!"
+          .to_string(),
+      );
+    }
+
+    if !_arg.archive().is_empty() {
+      if !ARCHIVE_PROVIDERS.contains(&_arg.archive().as_str()) {
+        return Err(format!(
+          "Invalid Piranha arguments. The flag provider `{}` is not supported (supported: {:?}) !!!",
+          _arg.archive(),
+          ARCHIVE_PROVIDERS
+        ));
+      }
+      if _arg.archive() != "launchdarkly" && _arg.archive_url().is_empty() {
+        return Err(format!(
+          "Invalid Piranha arguments. The `{}` flag provider requires `archive_url` !!!",
+          _arg.archive()
+        ));
+      }
+      if *_arg.dry_run() || *_arg.check() {
+        return Err(
+          "Invalid Piranha arguments. The flags are archived once cleaned up, i.e. `archive` is exclusive with `dry_run` and `check` !!!"
+            .to_string(),
+        );
+      }
+    }
+
+    if *_arg.revert() {
+      match _arg.path_to_journal() {
+        None => {
+          return Err(
+            "Invalid Piranha arguments. The edits are reverted from the journal, i.e. `revert` requires `path_to_journal` !!!"
+              .to_string(),
+          )
+        }
+        Some(path) => {
+          if let Err(e) = read_journal(path) {
+            return Err(format!("Invalid Piranha arguments. {e} !!!"));
+          }
+        }
+      }
+    }
+
+    if *_arg.scan() {
+      if let Err(e) = parse_duration(_arg.stale_after()) {
+        return Err(format!("Invalid Piranha arguments. {e} !!!"));
+      }
+    }
+
+    if *_arg.watch()
+      && (!_arg.code_snippet().is_empty()
+        || *_arg.pull_request()
+        || *_arg.hook()
+        || *_arg.interactive()
+        || _arg.path_to_patches().is_some())
+    {
+      return Err(
+        "Invalid Piranha arguments. The cleanup of the code base is watched in dry-run, i.e. `watch` requires `path_to_codebase` and is exclusive with `pull_request`, `hook`, `interactive` and `path_to_patches` !!!"
+          .to_string(),
+      );
+    }
+
+    if !NOTIFY_FORMATS.contains(&_arg.notify_format().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The notification format `{}` is not supported (supported: {:?}) !!!",
+        _arg.notify_format(),
+        NOTIFY_FORMATS
+      ));
+    }
+
+    if !DIFF_STYLES.contains(&_arg.diff_style().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The diff style `{}` is not supported (supported: {:?}) !!!",
+        _arg.diff_style(),
+        DIFF_STYLES
+      ));
+    }
+
+    if !COLORS.contains(&_arg.color().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The color `{}` is not supported (supported: {:?}) !!!",
+        _arg.color(),
+        COLORS
+      ));
+    }
+
+    if !_arg.split_by().is_empty() && !SPLIT_BY.contains(&_arg.split_by().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The split `{}` is not supported (supported: {:?}) !!!",
+        _arg.split_by(),
+        SPLIT_BY
+      ));
+    }
+
+    if _arg.split_by().is_empty() != _arg.path_to_patches().is_none() && !*_arg.pull_request() {
+      return Err(
+        "Invalid Piranha arguments. The chunks (i.e. `split_by`) are written as patches, i.e. `split_by` requires `path_to_patches` (or `pull_request`) and vice versa !!!"
+          .to_string(),
+      );
+    }
+
+    if !SCMS.contains(&_arg.scm().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The scm `{}` is not supported (supported: {:?}) !!!",
+        _arg.scm(),
+        SCMS
+      ));
+    }
+
+    if !_arg.report().is_empty() && !REPORT_FORMATS.contains(&_arg.report().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The report format `{}` is not supported (supported: {:?}) !!!",
+        _arg.report(),
+        REPORT_FORMATS
+      ));
+    }
+
+    if let Err(e) = get_confidence_rank(_arg.confidence_threshold()) {
+      return Err(format!(
+        "Invalid Piranha arguments. {e} as the confidence threshold !!!"
+      ));
+    }
+
+    if !_arg.log_level().is_empty() && !LOG_LEVELS.contains(&_arg.log_level().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The log level `{}` is not supported (supported: {:?}) !!!",
+        _arg.log_level(),
+        LOG_LEVELS
+      ));
+    }
+
+    if !LOG_FORMATS.contains(&_arg.log_format().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The log format `{}` is not supported (supported: {:?}) !!!",
+        _arg.log_format(),
+        LOG_FORMATS
+      ));
+    }
+
+    if !PACKAGE_LOADERS.contains(&_arg.package_loader().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The package loader `{}` is not supported (supported: {:?}) !!!",
+        _arg.package_loader(),
+        PACKAGE_LOADERS
+      ));
+    }
+
+    if !BUILD_VERIFICATIONS.contains(&_arg.verify_build().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The build verification `{}` is not supported (supported: {:?}) !!!",
+        _arg.verify_build(),
+        BUILD_VERIFICATIONS
+      ));
+    }
+
+    if !BUILD_VERIFICATIONS.contains(&_arg.verify_tests().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The test verification `{}` is not supported (supported: {:?}) !!!",
+        _arg.verify_tests(),
+        BUILD_VERIFICATIONS
+      ));
+    }
+
+    if !BLAST_RADIUS_ACTIONS.contains(&_arg.blast_radius_action().as_str()) {
+      return Err(format!(
+        "Invalid Piranha arguments. The blast radius action `{}` is not supported (supported: {:?}) !!!",
+        _arg.blast_radius_action(),
+        BLAST_RADIUS_ACTIONS
+      ));
+    }
+
+    if !_arg.backup_dir().is_empty() && !*_arg.backup() {
+      return Err(
+        "Invalid Piranha arguments. The backup directory is only used with `backup` !!!"
+          .to_string(),
+      );
+    }
+
+    if *_arg.jobs() == 0 {
+      return Err(
+        "Invalid Piranha arguments. The number of jobs should be at least 1 !!!".to_string(),
+      );
+    }
+
+    if *_arg.max_iterations() == 0 {
+      return Err(
+        "Invalid Piranha arguments. The number of iterations should be at least 1 !!!".to_string(),
+      );
+    }
+
+    if !_arg.explain().is_empty() {
+      if let Err(e) = parse_explain_position(_arg.explain()) {
+        return Err(format!("Invalid Piranha arguments. {e} !!!"));
+      }
+    }
+
+    if *_arg.serve() != (*_arg.lsp() || !_arg.http_address().is_empty())
+      || (*_arg.lsp() && !_arg.http_address().is_empty())
+    {
+      return Err(
+        "Invalid Piranha arguments. The server serves either the Language Server Protocol or the HTTP API, i.e. `serve` requires `lsp` or `http_address` (and vice versa) !!!"
+          .to_string(),
+      );
+    }
+
+    // The jobs of the HTTP server are cleaned up in memory, on the code bases of the clients
+    if !_arg.http_address().is_empty()
+      && (*_arg.interactive()
+        || *_arg.verify_build() != default_verify_build()
+        || *_arg.verify_tests() != default_verify_tests()
+        || !_arg.post_processing_hook().is_empty()
+        || !_arg.cache_dir().is_empty())
+    {
+      return Err(
+        "Invalid Piranha arguments. The jobs of the HTTP server are cleaned up in memory, neither `interactive`, `verify_build`, `verify_tests`, `post_processing_hook` nor `cache_dir` are supported with `http_address` !!!"
+          .to_string(),
+      );
+    }
+
+    if let Some(rule_pack) = _arg
+      .rule_packs()
+      .iter()
+      .find(|r| !_arg.language().rule_packs().contains_key(*r))
+    {
+      return Err(format!(
+        "Invalid Piranha arguments. The rule pack `{rule_pack}` is not supported for the language `{}` (supported: {:?}) !!!",
+        _arg.language().extension(),
+        _arg.language().rule_packs().keys().sorted().collect_vec()
+      ));
+    }
+
     Ok(true)
   }
 }
 
+/// Parses a flag of the `flags`, i.e. its comma-separated substitutions, or only its name (i.e. its `stale_flag_name`)
+fn parse_flag(flag: &str) -> Result<Vec<(String, String)>, Box<dyn Error + Send + Sync + 'static>> {
+  if !flag.contains('=') && !flag.trim().is_empty() {
+    return Ok(vec![(STALE_FLAG_NAME.to_string(), flag.trim().to_string())]);
+  }
+  parse_key_vals(flag)
+}
+
+/// Expands the `check`, `serve`, `hook`, `revert` and `scan` commands (i.e. `piranha check ...`) into the `--check`, `--serve`,
+/// `--hook`, `--revert` and `--scan` arguments, and the `report` command into the `--inventory` argument (since `--report` is the
+/// format of the report of the changes)
+fn expand_commands(args: impl Iterator<Item = String>) -> Vec<String> {
+  args
+    .enumerate()
+    .map(|(i, a)| {
+      if i == 1 && a == "report" {
+        "--inventory".to_string()
+      } else if i == 1 && ["check", "serve", "hook", "revert", "scan"].contains(&a.as_str()) {
+        format!("--{a}")
+      } else {
+        a
+      }
+    })
+    .collect()
+}
+
 /// Gets rule graph for PiranhaArguments
 ///   * Loads the language specific graphs
 ///   * Merges these with the user defined graphs
@@ -314,17 +1403,77 @@ fn get_rule_graph(_arg: &PiranhaArguments) -> RuleGraph {
   // Get the built-in rule -graph for the language
   let piranha_language = _arg.language();
 
-  let built_in_rules = RuleGraphBuilder::default()
-    .edges(piranha_language.edges().clone().unwrap_or_default().edges)
+  // Only the flag APIs are substituted in the `substitute_only` mode, i.e. the built-in cleanup rules are not triggered
+  let built_in_edges = if *_arg.substitute_only() {
+    vec![]
+  } else {
+    piranha_language.edges().clone().unwrap_or_default().edges
+  };
+  let mut built_in_rules = RuleGraphBuilder::default()
+    .edges(built_in_edges)
     .rules(piranha_language.rules().clone().unwrap_or_default().rules)
     .build();
 
+  // Add the built-in rules of the enabled flag SDKs.
+  // The seed rules requiring a substitution that is not provided (e.g. `treatment` for the variants of a flag) are skipped,
+  // unless the call sites of all the flags are listed (i.e. `inventory` and `scan`, which do not substitute the flag).
+  let substitutions = _arg.input_substitutions();
+  let inventory = *_arg.inventory() || *_arg.scan();
+  for rule_pack in _arg.rule_packs() {
+    if let Some((rules, edges)) = piranha_language.rule_packs().get(rule_pack) {
+      let rule_pack_rules = RuleGraphBuilder::default()
+        .edges(edges.edges.clone())
+        .rules(
+          rules
+            .rules
+            .iter()
+            .filter(|r| {
+              !*r.is_seed_rule()
+                || inventory
+                || r.holes().iter().all(|h| substitutions.contains_key(h))
+            })
+            .cloned()
+            .collect_vec(),
+        )
+        .build();
+      built_in_rules = built_in_rules.merge(&rule_pack_rules);
+    }
+  }
+
+  // Add the built-in rules for the second-order dead code elimination (if enabled)
+  if *_arg.aggressive_dead_code() && !*_arg.substitute_only() {
+    let aggressive_rules = RuleGraphBuilder::default()
+      .edges(
+        piranha_language
+          .aggressive_edges()
+          .clone()
+          .unwrap_or_default()
+          .edges,
+      )
+      .rules(
+        piranha_language
+          .aggressive_rules()
+          .clone()
+          .unwrap_or_default()
+          .rules,
+      )
+      .build();
+    built_in_rules = built_in_rules.merge(&aggressive_rules);
+  }
+
   // TODO: Move to `PiranhaArgumentBuilder`'s _validate - https://github.com/uber/piranha/issues/387
   // Get the user-defined rule graph (if any) via the Python/Rust API
   let mut user_defined_rules: RuleGraph = _arg.rule_graph().clone();
   // In the scenario when rules/edges are passed as toml files
   if !_arg.path_to_configurations().is_empty() {
-    user_defined_rules = read_user_config_files(_arg.path_to_configurations())
+    user_defined_rules = read_user_config_files(_arg.path_to_configurations());
+    // Add the rules generated for the flag APIs declared in `flag_apis.toml` (if any)
+    if *piranha_language.supported_language() == SupportedLanguage::Go {
+      let flag_api_rules = RuleGraphBuilder::default()
+        .rules(read_flag_apis(_arg.path_to_configurations()))
+        .build();
+      user_defined_rules = user_defined_rules.merge(&flag_api_rules);
+    }
   }
 
   if user_defined_rules.graph().is_empty() {
@@ -340,24 +1489,58 @@ mod piranha_arguments_test;
 
 // Implements instance methods related to applying the user options provided in  piranha arguments
 impl SourceCodeUnit {
-  /// Replaces three consecutive newline characters with two
+  /// Replaces three consecutive newline characters with two.
+  /// Only the consecutive newlines overlapping the code modified by the edits are replaced,
+  /// so that the rest of the file is left as it is (i.e. the diff is confined to the rewritten code).
   pub(crate) fn perform_delete_consecutive_new_lines(&mut self) {
     if *self.piranha_arguments().delete_consecutive_new_lines() {
       let regex = Regex::new(r"\n(\s*\n)+(\s*\n)").unwrap();
-      let x = &regex.replace_all(self.code(), "\n${2}").into_owned();
-      self.set_code(x.clone());
+      let code = self.code().to_string();
+      let mut new_code = String::new();
+      let mut last_end = 0;
+      for captures in regex.captures_iter(&code) {
+        let consecutive_new_lines = captures.get(0).unwrap();
+        if !self.is_edited(consecutive_new_lines.start(), consecutive_new_lines.end()) {
+          continue;
+        }
+        new_code.push_str(&code[last_end..consecutive_new_lines.start()]);
+        new_code.push('\n');
+        new_code.push_str(captures.get(2).map(|m| m.as_str()).unwrap_or_default());
+        last_end = consecutive_new_lines.end();
+      }
+      new_code.push_str(&code[last_end..]);
+      // The recorded ranges do not hold anymore once the newlines are deleted
+      self.edited_ranges_mut().clear();
+      self.set_code(new_code);
     }
   }
 
-  /// Writes the current contents of `code` to the file system and deletes a file if empty.
+  /// Writes the current contents of `code` to the file system (atomically, see `write_atomically`) and deletes a file if empty.
+  /// The file is backed up beforehand with `backup` (see `back_up_file`).
   pub(crate) fn persist(&self) {
     if *self.piranha_arguments().dry_run() {
       return;
     }
     if self.code().as_str().is_empty() && *self.piranha_arguments().delete_file_if_empty() {
+      self.back_up();
+      debug!("Deleting the (empty) file {:?}", self.path());
       std::fs::remove_file(self.path()).expect("Unable to Delete file");
       return;
     }
-    std::fs::write(self.path(), self.code()).expect("Unable to Write file");
+    // The files that are only matched (i.e. not rewritten) are left untouched
+    if self.code() == self.original_content() {
+      return;
+    }
+    self.back_up();
+    debug!("Writing the file {:?}", self.path());
+    write_atomically(self.path(), self.code()).expect("Unable to Write file");
+  }
+
+  fn back_up(&self) {
+    if let Some(backup_path) =
+      back_up_file(self.path(), self.piranha_arguments()).expect("Unable to Back up file")
+    {
+      debug!("Backed up the file {:?} to {:?}", self.path(), backup_path);
+    }
   }
 }
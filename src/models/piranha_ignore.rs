@@ -0,0 +1,69 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::path::Path;
+
+use glob::Pattern;
+use log::{debug, warn};
+
+use crate::utilities::read_file;
+
+/// The file at the root of the code base listing the paths Piranha should not rewrite (e.g. `vendor/`)
+pub(crate) static PIRANHA_IGNORE_FILE: &str = ".piranhaignore";
+
+/// Reads the `.piranhaignore` file at the root of the code base (if any), and returns the patterns of the ignored paths.
+pub(crate) fn read_piranha_ignore(path_to_codebase: &str) -> Vec<Pattern> {
+  let path_to_ignore_file = Path::new(path_to_codebase).join(PIRANHA_IGNORE_FILE);
+  if !path_to_ignore_file.is_file() {
+    return vec![];
+  }
+  debug!("Reading the ignored paths from {path_to_ignore_file:?}");
+  read_file(&path_to_ignore_file)
+    .map(|content| parse_piranha_ignore(&content))
+    .unwrap_or_default()
+}
+
+/// Parses the content of a `.piranhaignore` file, following (a subset of) the `.gitignore` syntax:
+/// one glob pattern per line, relative to the code base, with `#` starting a comment.
+/// A pattern without a `/` (except a trailing one, e.g. `vendor/`) matches at any depth (i.e. `**/vendor`),
+/// and a pattern matching a directory ignores everything beneath it.
+/// Negated patterns (i.e. `!pattern`) are not supported, and skipped.
+pub(crate) fn parse_piranha_ignore(content: &str) -> Vec<Pattern> {
+  let mut patterns = vec![];
+  for line in content.lines().map(str::trim) {
+    if line.is_empty() || line.starts_with('#') {
+      continue;
+    }
+    if line.starts_with('!') {
+      warn!("Negated patterns are not supported in {PIRANHA_IGNORE_FILE}, skipping `{line}`");
+      continue;
+    }
+    let pattern = line.trim_end_matches('/');
+    let pattern = match pattern.strip_prefix('/') {
+      Some(anchored) => anchored.to_string(),
+      None if pattern.contains('/') => pattern.to_string(),
+      None => format!("**/{pattern}"),
+    };
+    for p in [pattern.clone(), format!("{pattern}/**")] {
+      match Pattern::new(&p) {
+        Ok(p) => patterns.push(p),
+        Err(e) => warn!("Invalid pattern `{line}` in {PIRANHA_IGNORE_FILE} : {e}"),
+      }
+    }
+  }
+  patterns
+}
+
+#[cfg(test)]
+#[path = "unit_tests/piranha_ignore_test.rs"]
+mod piranha_ignore_test;
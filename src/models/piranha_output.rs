@@ -14,10 +14,14 @@ Copyright (c) 2023 Uber Technologies, Inc.
 use getset::Getters;
 use itertools::Itertools;
 use serde_derive::{Deserialize, Serialize};
+use similar::TextDiff;
 
 use crate::utilities::gen_py_str_methods;
 
-use super::{edit::Edit, matches::Match, source_code_unit::SourceCodeUnit};
+use super::{
+  edit::Edit, flag_definitions::FlagDefinitionFile, matches::Match,
+  source_code_unit::SourceCodeUnit, templates::TemplateFile,
+};
 use pyo3::{prelude::pyclass, pymethods};
 
 /// A class to represent Piranha's output
@@ -26,7 +30,7 @@ use pyo3::{prelude::pyclass, pymethods};
 pub struct PiranhaOutputSummary {
   /// Path to the file
   #[pyo3(get)]
-  #[get = "pub(crate)"]
+  #[get = "pub"]
   path: String,
   /// Original content of the file after all the rewrites
   #[pyo3(get)]
@@ -45,6 +49,20 @@ pub struct PiranhaOutputSummary {
   #[pyo3(get)]
   #[get = "pub(crate)"]
   rewrites: Vec<Edit>,
+  /// All the matches exempted from the cleanup by the `piranha:ignore` directives
+  #[pyo3(get)]
+  #[get = "pub(crate)"]
+  suppressed_matches: Vec<(String, Match)>,
+  /// The number of rewrites for each of the flags (when cleaning up several `flags` in a single run)
+  #[pyo3(get)]
+  #[get = "pub(crate)"]
+  #[serde(default)]
+  rewrites_per_flag: Vec<(String, usize)>,
+  /// The number of lines added and removed for each of the flags (i.e. `(flag, lines_added, lines_removed)`)
+  #[pyo3(get)]
+  #[get = "pub(crate)"]
+  #[serde(default)]
+  lines_per_flag: Vec<(String, usize, usize)>,
 }
 
 gen_py_str_methods!(PiranhaOutputSummary);
@@ -57,6 +75,57 @@ impl PiranhaOutputSummary {
       content: source_code_unit.code().to_string(),
       matches: source_code_unit.matches().iter().cloned().collect_vec(),
       rewrites: source_code_unit.rewrites().iter().cloned().collect_vec(),
+      suppressed_matches: source_code_unit
+        .suppressed_matches()
+        .iter()
+        .cloned()
+        .collect_vec(),
+      rewrites_per_flag: source_code_unit.rewrites_per_flag().to_vec(),
+      lines_per_flag: source_code_unit.lines_per_flag().to_vec(),
     };
   }
+
+  pub(crate) fn from_flag_definition_file(file: &FlagDefinitionFile) -> PiranhaOutputSummary {
+    PiranhaOutputSummary {
+      path: String::from(file.path().as_os_str().to_str().unwrap()),
+      original_content: file.original_content().to_string(),
+      content: file.content().to_string(),
+      rewrites: file.rewrites().to_vec(),
+      ..Default::default()
+    }
+  }
+
+  pub(crate) fn from_template_file(file: &TemplateFile) -> PiranhaOutputSummary {
+    PiranhaOutputSummary {
+      path: String::from(file.path().as_os_str().to_str().unwrap()),
+      original_content: file.original_content().to_string(),
+      content: file.content().to_string(),
+      rewrites: file.rewrites().to_vec(),
+      ..Default::default()
+    }
+  }
+
+  /// Returns this summary for the file at `path` (e.g. relative to the root of the files cleaned up in memory, see `clean`)
+  pub(crate) fn with_path(mut self, path: &str) -> PiranhaOutputSummary {
+    self.path = path.to_string();
+    self
+  }
+
+  /// Returns the unified diff (i.e. `git apply` compatible, with the `a/` and `b/` prefixes) of the rewrites of the file,
+  /// or an empty string if its content is unchanged.
+  pub fn unified_diff(&self) -> String {
+    if self.original_content == self.content {
+      return String::new();
+    }
+    let path = self.path.trim_start_matches("./").trim_start_matches('/');
+    TextDiff::from_lines(&self.original_content, &self.content)
+      .unified_diff()
+      .context_radius(3)
+      .header(&format!("a/{path}"), &format!("b/{path}"))
+      .to_string()
+  }
 }
+
+#[cfg(test)]
+#[path = "unit_tests/piranha_output_test.rs"]
+mod piranha_output_test;
@@ -0,0 +1,131 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  io::Write,
+  process::{Command, Stdio},
+  thread,
+};
+
+use log::{debug, warn};
+use serde_derive::{Deserialize, Serialize};
+
+use super::{edit::Edit, source_code_unit::SourceCodeUnit};
+
+/// The input of the post-processing hook (written as JSON on its stdin), i.e. a file rewritten by Piranha
+#[derive(Serialize, Debug, Clone)]
+pub(crate) struct PostProcessingRequest<'a> {
+  // The path of the file
+  path: String,
+  // The content of the file before the rewrite
+  original_content: &'a str,
+  // The content of the file after the rewrite
+  content: &'a str,
+  // The rewrites applied to the file
+  rewrites: &'a [Edit],
+  // Whether the rewrites are only reported, i.e. not persisted (the hook should not update other files either, e.g. a changelog)
+  dry_run: bool,
+}
+
+/// The output of the post-processing hook (read as JSON from its stdout).
+/// An empty output accepts the rewrites as they are.
+#[derive(Deserialize, Debug, Clone, Default, PartialEq, Eq)]
+pub(crate) struct PostProcessingResponse {
+  // Discards the rewrites of the file (i.e. it is left as it is)
+  #[serde(default)]
+  veto: bool,
+  // The content replacing the rewritten content of the file (e.g. reformatted, or with additional edits)
+  #[serde(default)]
+  content: Option<String>,
+}
+
+// Implements instance methods related to post-processing the rewrites with a user-provided command
+impl SourceCodeUnit {
+  /// Runs the `post_processing_hook` command on the rewrites of this file, before they are persisted.
+  /// The hook receives the file as JSON on its stdin (see `PostProcessingRequest`), and may veto the rewrites
+  /// or replace the rewritten content (see `PostProcessingResponse`).
+  /// When the hook fails (i.e. non-zero exit status or invalid output), the rewrites are kept as they are.
+  pub(crate) fn apply_post_processing_hook(&mut self) {
+    let command = self.piranha_arguments().post_processing_hook().to_string();
+    if command.is_empty() || self.rewrites().is_empty() {
+      return;
+    }
+    let request = PostProcessingRequest {
+      path: self.path().to_string_lossy().to_string(),
+      original_content: self.original_content(),
+      content: self.code(),
+      rewrites: self.rewrites(),
+      dry_run: *self.piranha_arguments().dry_run(),
+    };
+    let response = match run_post_processing_hook(&command, &request) {
+      Ok(response) => response,
+      Err(e) => {
+        warn!(
+          "The post-processing hook failed for {:?}, the rewrites are kept as they are : {}",
+          self.path(),
+          e
+        );
+        return;
+      }
+    };
+    if response.veto {
+      debug!(
+        "The post-processing hook vetoed the rewrites of {:?}",
+        self.path()
+      );
+      self.discard_rewrites();
+    } else if let Some(content) = response.content {
+      debug!("The post-processing hook modified {:?}", self.path());
+      // The content is parsed again, so that the rules applied afterwards match it
+      let mut parser = self.piranha_arguments().language().parser();
+      self._replace_file_contents_and_re_parse(&content, &mut parser, false);
+    }
+  }
+}
+
+/// Runs the `command` (with `sh -c`), writing the `request` as JSON on its stdin, and parses its output
+pub(crate) fn run_post_processing_hook(
+  command: &str, request: &PostProcessingRequest,
+) -> Result<PostProcessingResponse, String> {
+  let input = serde_json::to_string(request).map_err(|e| e.to_string())?;
+  let mut child = Command::new("sh")
+    .arg("-c")
+    .arg(command)
+    .stdin(Stdio::piped())
+    .stdout(Stdio::piped())
+    .spawn()
+    .map_err(|e| e.to_string())?;
+  // The input is written from another thread while the output is read, so that neither blocks when the other pipe is full
+  // (i.e. when the hook streams its output). The hook may exit without reading its input (e.g. to accept all the rewrites).
+  let writer = child.stdin.take().map(|mut stdin| {
+    thread::spawn(move || {
+      _ = stdin.write_all(input.as_bytes());
+    })
+  });
+  let output = child.wait_with_output().map_err(|e| e.to_string())?;
+  if let Some(writer) = writer {
+    _ = writer.join();
+  }
+  if !output.status.success() {
+    return Err(format!("`{command}` exited with {}", output.status));
+  }
+  let stdout = String::from_utf8_lossy(&output.stdout);
+  if stdout.trim().is_empty() {
+    return Ok(PostProcessingResponse::default());
+  }
+  serde_json::from_str(&stdout).map_err(|e| e.to_string())
+}
+
+#[cfg(test)]
+#[path = "unit_tests/post_processing_hook_test.rs"]
+mod post_processing_hook_test;
@@ -0,0 +1,114 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use serde_derive::Serialize;
+
+use super::{
+  confidence::VERIFY_LOW_CONFIDENCE_EDIT,
+  flag_definitions::DELETE_FLAG_DEFINITION,
+  imports::REMOVE_UNUSED_IMPORTS,
+  piranha_arguments::PiranhaArguments,
+  rule::Rules,
+  rule_graph::GLOBAL,
+  specialization::SPECIALIZE_BOOLEAN_PARAMETER,
+  stranded_functions::{STRANDED_FUNCTION, STRANDED_PROVIDER_SET},
+  test_cleanup::{FORCE_ELIMINATED_FLAG_VALUE, MARK_TEST_FOR_ELIMINATED_FLAG_VALUE},
+  test_tables::{DELETE_CONSTANT_TABLE_FIELD, SET_STALE_FLAG_VALUE},
+};
+
+/// A rule defined by the user (i.e. in the `path_to_configurations`, or through the API)
+static USER: &str = "user";
+/// A built-in cleanup rule of the language
+static BUILT_IN: &str = "built_in";
+/// A built-in rule of a flag SDK (see `rule_packs`)
+static RULE_PACK: &str = "rule_pack";
+/// A built-in rule of the second-order dead code elimination (see `aggressive_dead_code`)
+static AGGRESSIVE_DEAD_CODE: &str = "aggressive_dead_code";
+/// A (pseudo) rule of a pass of Piranha itself (e.g. the specialization of the boolean parameters, the test cleanup)
+static PASS: &str = "pass";
+
+/// The (pseudo) rules of the passes of Piranha, i.e. not defined by a rule graph
+static PASS_RULES: [&str; 10] = [
+  VERIFY_LOW_CONFIDENCE_EDIT,
+  DELETE_FLAG_DEFINITION,
+  REMOVE_UNUSED_IMPORTS,
+  SPECIALIZE_BOOLEAN_PARAMETER,
+  STRANDED_FUNCTION,
+  STRANDED_PROVIDER_SET,
+  FORCE_ELIMINATED_FLAG_VALUE,
+  MARK_TEST_FOR_ELIMINATED_FLAG_VALUE,
+  SET_STALE_FLAG_VALUE,
+  DELETE_CONSTANT_TABLE_FIELD,
+];
+/// The passes reasoning across the functions (and files) of a package
+static INTER_PROCEDURAL_PASSES: [&str; 3] = [
+  SPECIALIZE_BOOLEAN_PARAMETER,
+  STRANDED_FUNCTION,
+  STRANDED_PROVIDER_SET,
+];
+
+/// Where the rule of a rewrite comes from, e.g. to audit the rewrites of the inter-procedural rules apart from the
+/// substitutions of the flag APIs.
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Eq)]
+pub(crate) struct Provenance {
+  // `user`, `built_in`, `rule_pack`, `aggressive_dead_code` or `pass`
+  pub(crate) origin: String,
+  // Whether the rewrite depends on the code of other functions (or files), i.e. the rules triggered by a `Global` edge,
+  // the second-order dead code elimination and the inter-procedural passes (e.g. `specialize_boolean_parameter`)
+  pub(crate) inter_procedural: bool,
+}
+
+impl Provenance {
+  /// Returns the provenance of the `rule` of a rewrite of a run with the given `piranha_arguments` (or any of its `flags`)
+  pub(crate) fn of(rule: &str, piranha_arguments: &PiranhaArguments) -> Provenance {
+    let language = piranha_arguments.language();
+    let is_named = |rules: &Option<Rules>| {
+      rules
+        .as_ref()
+        .map(|r| r.rules.iter().any(|r| r.name() == rule))
+        .unwrap_or(false)
+    };
+    let origin = if PASS_RULES.contains(&rule) {
+      PASS
+    } else if is_named(language.aggressive_rules()) {
+      AGGRESSIVE_DEAD_CODE
+    } else if piranha_arguments.rule_packs().iter().any(|p| {
+      language
+        .rule_packs()
+        .get(p)
+        .map(|(rules, _)| rules.rules.iter().any(|r| r.name() == rule))
+        .unwrap_or(false)
+    }) {
+      RULE_PACK
+    } else if is_named(language.rules()) {
+      BUILT_IN
+    } else {
+      USER
+    };
+    let is_global_target = std::iter::once(piranha_arguments)
+      .chain(piranha_arguments.flag_arguments().iter())
+      .flat_map(|a| a.rule_graph().graph().values())
+      .flatten()
+      .any(|(scope, to)| scope == GLOBAL && to == rule);
+    Provenance {
+      origin: origin.to_string(),
+      inter_procedural: origin == AGGRESSIVE_DEAD_CODE
+        || INTER_PROCEDURAL_PASSES.contains(&rule)
+        || is_global_target,
+    }
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/provenance_test.rs"]
+mod provenance_test;
@@ -0,0 +1,310 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::HashMap,
+  fs,
+  path::{Path, PathBuf},
+  time::Duration,
+};
+
+use getset::Getters;
+use itertools::Itertools;
+use log::{debug, info, warn};
+
+use crate::{execute_piranha_with_statistics, utilities::count_changed_lines};
+
+use super::{
+  blast_radius::get_exceeded_limits,
+  changed_files::{get_directory, get_top_level, run_git},
+  code_owners::CodeOwners,
+  flag_file::TREATED,
+  persist::write_atomically,
+  piranha_arguments::PiranhaArguments,
+  piranha_output::PiranhaOutputSummary,
+  scm::{get_scm, Scm},
+  split::{get_relative_path, split_summaries, Chunk},
+  statistics::RunStatistics,
+};
+
+/// The remote the branches are pushed to, and whose repository the pull requests are opened against
+static REMOTE: &str = "origin";
+/// The placeholder of the name of the flag in the templates (along with the ones of the substitutions, e.g. `{treated}`)
+static FLAG_PLACEHOLDER: &str = "flag";
+/// The placeholder of the name of the chunk (see `split_by`) in the templates
+static CHUNK_PLACEHOLDER: &str = "chunk";
+
+/// A pull (or merge) request opened for the cleanup of a flag
+#[derive(Debug, Clone, PartialEq, Eq, Getters)]
+pub struct PullRequest {
+  /// The name of the cleaned up flag
+  #[get = "pub"]
+  flag: String,
+  /// The name of the chunk of the cleanup (see `split_by`), if split
+  #[get = "pub"]
+  chunk: String,
+  /// The branch of the cleanup
+  #[get = "pub"]
+  branch: String,
+  /// The URL of the pull request
+  #[get = "pub"]
+  url: String,
+  /// The reviewers requested for the pull request (i.e. the code owners of the rewritten files)
+  #[get = "pub"]
+  reviewers: Vec<String>,
+  /// The rewritten files (relative to the root of the repository)
+  #[get = "pub"]
+  files: Vec<String>,
+}
+
+/// The repository the pull requests are opened against, the platform hosting it, and the branch they are based on
+struct Repository {
+  top_level: PathBuf,
+  scm: Box<dyn Scm>,
+  base: String,
+  code_owners: CodeOwners,
+}
+
+/// Cleans up each of the flags (or the substitutions, without `flags`) on its own branch, and opens a pull request for it on the
+/// `scm` (i.e. `pull_request`), or for each of its chunks (see `split_by`). The branch is created from `pull_request_base` (or the current branch), committed with the
+/// `commit_message_template` and pushed to `origin`, and the code owners of the rewritten files are requested as reviewers.
+/// The flags without rewrites are skipped, and the reasons why a pull request could not be opened are returned along with
+/// the opened ones (and logged). The authentication token is read from the environment variable of the `scm` (see `get_scm`).
+pub fn open_pull_requests(
+  piranha_arguments: &PiranhaArguments,
+) -> Result<(Vec<PullRequest>, Vec<String>), String> {
+  let top_level = get_top_level(get_directory(piranha_arguments.path_to_codebase()))?;
+  // The rewritten files are committed as a whole, which would include the uncommitted changes
+  if !run_git(&top_level, &["status", "--porcelain"])?
+    .trim()
+    .is_empty()
+  {
+    return Err(format!(
+      "The working tree of {top_level:?} has uncommitted changes"
+    ));
+  }
+  let scm = get_scm(
+    piranha_arguments.scm(),
+    piranha_arguments.scm_url(),
+    run_git(&top_level, &["remote", "get-url", REMOTE])?.trim(),
+  )?;
+  let base = if piranha_arguments.pull_request_base().is_empty() {
+    run_git(&top_level, &["rev-parse", "--abbrev-ref", "HEAD"])?
+      .trim()
+      .to_string()
+  } else {
+    piranha_arguments.pull_request_base().to_string()
+  };
+  let repository = Repository {
+    code_owners: CodeOwners::read(&top_level),
+    top_level,
+    scm,
+    base,
+  };
+
+  let flag_arguments = if piranha_arguments.flag_arguments().is_empty() {
+    vec![piranha_arguments.clone()]
+  } else {
+    piranha_arguments.flag_arguments().clone()
+  };
+  let (mut pull_requests, mut failures) = (vec![], vec![]);
+  for arguments in &flag_arguments {
+    let flag = arguments.get_flag_name();
+    // The flag is cleaned up without writing the files, which are written on the branch of each chunk instead
+    let (summaries, statistics) =
+      execute_piranha_with_statistics(&arguments.in_memory(arguments.path_to_codebase()));
+    // The pull requests of a cleanup exceeding its blast radius are not opened, whatever the `blast_radius_action`
+    let exceeded_limits = get_exceeded_limits(&summaries, arguments);
+    if !exceeded_limits.is_empty() {
+      let failure = format!(
+        "The pull request for the flag {flag} is not opened : {}",
+        exceeded_limits.join(", ")
+      );
+      warn!("{failure}");
+      failures.push(failure);
+      continue;
+    }
+    let chunks = split_summaries(
+      &summaries,
+      piranha_arguments,
+      &repository.top_level,
+      &repository.code_owners,
+    );
+    if chunks.is_empty() {
+      info!("Nothing to clean up for the flag {flag}");
+    }
+    for chunk in &chunks {
+      let result = open_pull_request(
+        arguments,
+        piranha_arguments,
+        &repository,
+        chunk,
+        &statistics,
+      );
+      // The base branch is checked out again (discarding the cleanup if it failed), before the next chunk
+      run_git(
+        &repository.top_level,
+        &["checkout", "-q", "-f", &repository.base],
+      )?;
+      match result {
+        Ok(pull_request) => {
+          info!("Opened {} for the flag {flag}", pull_request.url());
+          pull_requests.push(pull_request);
+        }
+        Err(e) => {
+          let failure = format!("Could not open the pull request for the flag {flag} : {e}");
+          warn!("{failure}");
+          failures.push(failure);
+        }
+      }
+    }
+  }
+  Ok((pull_requests, failures))
+}
+
+/// Writes the rewritten files of the chunk of the cleanup of the flag (of the `arguments`) on its own branch, commits and pushes
+/// them, and opens the pull request. The name of the chunk (if split, see `split_by`) is appended to the name of the branch and
+/// to the title, unless the templates use its placeholder (i.e. `{chunk}`).
+fn open_pull_request(
+  arguments: &PiranhaArguments, piranha_arguments: &PiranhaArguments, repository: &Repository,
+  chunk: &Chunk, statistics: &RunStatistics,
+) -> Result<PullRequest, String> {
+  let top_level = &repository.top_level;
+  let flag = arguments.get_flag_name();
+  let mut substitutions = get_template_substitutions(arguments);
+  substitutions.insert(CHUNK_PLACEHOLDER.to_string(), chunk.slug());
+  let placeholder = format!("{{{CHUNK_PLACEHOLDER}}}");
+  let mut branch = render_template(piranha_arguments.branch_template(), &substitutions);
+  let message = render_template(piranha_arguments.commit_message_template(), &substitutions);
+  let (mut title, description) = message
+    .split_once('\n')
+    .map(|(t, d)| (t.trim().to_string(), d.trim()))
+    .unwrap_or((message.trim().to_string(), ""));
+  if !chunk.name().is_empty() {
+    if !piranha_arguments.branch_template().contains(&placeholder) {
+      branch = format!("{branch}-{}", chunk.slug());
+    }
+    if !piranha_arguments
+      .commit_message_template()
+      .contains(&placeholder)
+    {
+      title = format!("{title} ({})", chunk.name());
+    }
+  }
+  let message = if description.is_empty() {
+    title.to_string()
+  } else {
+    format!("{title}\n\n{description}")
+  };
+
+  run_git(
+    top_level,
+    &["checkout", "-q", "-b", &branch, &repository.base],
+  )?;
+  let mut rewritten = vec![];
+  for summary in chunk.summaries() {
+    let written = if summary.content().is_empty() && *piranha_arguments.delete_file_if_empty() {
+      fs::remove_file(summary.path())
+    } else {
+      write_atomically(Path::new(summary.path()), summary.content())
+    };
+    written.map_err(|e| format!("Could not write {} : {e}", summary.path()))?;
+    if let Some(path) = get_relative_path(top_level, summary.path()) {
+      rewritten.push((path, *summary));
+    }
+  }
+  let paths = rewritten.iter().map(|(p, _)| p.as_str()).collect_vec();
+  // The files deleted by the cleanup (i.e. `delete_file_if_empty`) are removed from the index
+  run_git(
+    top_level,
+    &[&["add", "--all", "--"], paths.as_slice()].concat(),
+  )?;
+  run_git(top_level, &["commit", "-q", "-m", &message])?;
+  run_git(top_level, &["push", "-q", "-u", REMOTE, &branch])?;
+  debug!("Pushed the branch {branch}");
+
+  // The statistics of the chunk, rather than the ones of the whole cleanup of the flag
+  let chunk_statistics = RunStatistics::new(
+    &chunk.summaries().iter().map(|s| (*s).clone()).collect_vec(),
+    arguments,
+    *statistics.files_scanned(),
+    Duration::from_secs_f64(*statistics.elapsed_seconds()),
+  );
+  let body = get_body(
+    description,
+    &flag,
+    substitutions.get(TREATED).map(|t| t.as_str()),
+    &rewritten,
+    &chunk_statistics,
+  );
+  let owners = repository
+    .code_owners
+    .get_owners_of_files(paths.iter().copied());
+  let url = repository
+    .scm
+    .open_pull_request(&branch, &repository.base, &title, &body, &owners)?;
+  Ok(PullRequest {
+    flag,
+    chunk: chunk.name().to_string(),
+    branch,
+    url,
+    reviewers: owners,
+    files: paths.iter().map(|p| p.to_string()).collect(),
+  })
+}
+
+/// Returns the values of the placeholders of the templates, i.e. the substitutions of the arguments and the name of the flag
+fn get_template_substitutions(arguments: &PiranhaArguments) -> HashMap<String, String> {
+  let mut substitutions = arguments.input_substitutions();
+  substitutions.insert(FLAG_PLACEHOLDER.to_string(), arguments.get_flag_name());
+  substitutions
+}
+
+/// Replaces the placeholders (i.e. `{name}`) of the template with their value. Unknown placeholders are left as they are.
+pub(crate) fn render_template(template: &str, substitutions: &HashMap<String, String>) -> String {
+  substitutions
+    .iter()
+    .fold(template.to_string(), |rendered, (name, value)| {
+      rendered.replace(&format!("{{{name}}}"), value)
+    })
+}
+
+/// Returns the body of the pull request, i.e. the description of the commit message followed by the summary of the change
+/// (the lines added and removed in each file, and the statistics of the cleanup).
+pub(crate) fn get_body(
+  description: &str, flag: &str, treated: Option<&str>,
+  rewritten: &[(String, &PiranhaOutputSummary)], statistics: &RunStatistics,
+) -> String {
+  let mut body = String::new();
+  if !description.is_empty() {
+    body.push_str(&format!("{description}\n\n"));
+  }
+  body.push_str(&format!("Cleans up the stale flag `{flag}`"));
+  if let Some(treated) = treated {
+    body.push_str(&format!(" (treated as `{treated}`)"));
+  }
+  body.push_str(" with Piranha.\n\n| File | Added | Removed |\n|---|---:|---:|\n");
+  for (path, summary) in rewritten {
+    let (lines_added, lines_removed) =
+      count_changed_lines(summary.original_content(), summary.content());
+    body.push_str(&format!("| `{path}` | {lines_added} | {lines_removed} |\n"));
+  }
+  body.push_str(&format!(
+    "\n<details>\n<summary>Statistics</summary>\n\n```\n{statistics}```\n</details>\n"
+  ));
+  body
+}
+
+#[cfg(test)]
+#[path = "unit_tests/pull_request_test.rs"]
+mod pull_request_test;
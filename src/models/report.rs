@@ -0,0 +1,372 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::path::Path;
+
+use serde_derive::Serialize;
+
+use crate::utilities::count_changed_lines;
+
+use super::{
+  analysis::AnalysisReport,
+  build_verification::{BUILD_FAILURE, GO_OUTPUT, TESTS_PASSED, TEST_FAILURE},
+  changed_files::{get_directory, get_top_level},
+  code_owners::CodeOwners,
+  confidence::VERIFY_LOW_CONFIDENCE_EDIT,
+  dynamic_flag_names::{DYNAMIC_FLAG_NAME, STALE_FLAG_NAME},
+  edit::Edit,
+  generated_files::SKIPPED_GENERATED_FILE,
+  go_modules::{find_go_modules, get_module_of},
+  html_report::HtmlReport,
+  language::SupportedLanguage,
+  matches::Match,
+  piranha_arguments::PiranhaArguments,
+  piranha_output::PiranhaOutputSummary,
+  provenance::Provenance,
+  sarif::SarifLog,
+  split::get_relative_path,
+  statistics::RunStatistics,
+  stranded_functions::{STRANDED_FUNCTION, STRANDED_PROVIDER_SET, STRANDED_TYPE},
+  test_cleanup::MARK_TEST_FOR_ELIMINATED_FLAG_VALUE,
+};
+
+/// The formats of the change report (i.e. `report`), i.e. `ChangeReport`, `SarifLog`, `AnalysisReport` or `HtmlReport`
+pub(crate) static REPORT_FORMATS: [&str; 4] = ["json", "sarif", "analysis", "html"];
+
+/// A machine-readable report of the changes of a Piranha run (e.g. to open tickets or track the cleanup debt),
+/// built from the output summaries.
+#[derive(Serialize, Debug, Clone, Default)]
+pub struct ChangeReport {
+  // The files rewritten by Piranha, or with sites that need a manual cleanup (see `ReportWarning`)
+  files: Vec<FileReport>,
+  // The total number of lines added and removed
+  lines_added: usize,
+  lines_removed: usize,
+  // The changes of each Go module of the code base (e.g. of a `go.work` workspace), if any
+  #[serde(skip_serializing_if = "Vec::is_empty")]
+  modules: Vec<ModuleReport>,
+  // The summary statistics of the run (if computed), broken down per flag
+  #[serde(skip_serializing_if = "Option::is_none")]
+  statistics: Option<RunStatistics>,
+}
+
+/// The changes of a Go module, i.e. the totals of its files
+#[derive(Serialize, Debug, Clone, Default)]
+struct ModuleReport {
+  // The path declared by the `module` directive of its `go.mod`
+  module: String,
+  // The number of files of the report within the module
+  files: usize,
+  lines_added: usize,
+  lines_removed: usize,
+}
+
+/// The changes of a file
+#[derive(Serialize, Debug, Clone, Default)]
+struct FileReport {
+  path: String,
+  // The Go module containing the file (if any)
+  #[serde(skip_serializing_if = "Option::is_none")]
+  module: Option<String>,
+  // The owners of the file (see `CODEOWNERS` and `OWNERS`), if any
+  #[serde(skip_serializing_if = "Vec::is_empty")]
+  owners: Vec<String>,
+  // The rewrites of the file, in the order they are applied
+  rewrites: Vec<ReportRewrite>,
+  // The sites that were not cleaned up, or need a manual review
+  warnings: Vec<ReportWarning>,
+  // The outcome of the tests of the package of the file (i.e. `passed` or `failed`), if they were run (see `verify_tests`)
+  #[serde(skip_serializing_if = "Option::is_none")]
+  tests: Option<String>,
+  // The number of lines added and removed (i.e. the ones of the unified diff of the file)
+  lines_added: usize,
+  lines_removed: usize,
+}
+
+/// A rewrite of a file
+#[derive(Serialize, Debug, Clone, Default)]
+struct ReportRewrite {
+  // The flag whose cleanup applied the rewrite (i.e. its `stale_flag_name`), if any
+  flag: Option<String>,
+  // The rule that fired
+  rule: String,
+  // Where the rule comes from (e.g. `user`, `built_in`), and whether the rewrite is inter-procedural
+  #[serde(flatten)]
+  provenance: Provenance,
+  // The range of the rewritten code, in the content of the file at the time of the rewrite
+  range: ReportRange,
+  // The rewritten code (i.e. the matched snippet), and its replacement
+  code: String,
+  replacement: String,
+  // The number of lines of the rewritten code, and of its replacement
+  lines_removed: usize,
+  lines_added: usize,
+}
+
+/// A site that was not cleaned up (e.g. suppressed by a `piranha:ignore` directive), or needs a manual review
+#[derive(Serialize, Debug, Clone, Default)]
+struct ReportWarning {
+  // The kind of the site, i.e. `suppressed_match` (along with the rule of the suppressed match),
+  // or the pseudo rule reporting it (e.g. `dynamic_flag_name`, `skipped_generated_file`)
+  kind: String,
+  rule: Option<String>,
+  range: ReportRange,
+  code: String,
+  // The cause of the warning, if any (e.g. the output of the go command for a `build_failure` or a `test_failure`)
+  #[serde(skip_serializing_if = "Option::is_none")]
+  message: Option<String>,
+}
+
+/// A range, with 1-based lines (and 0-based bytes)
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Eq)]
+struct ReportRange {
+  start_byte: usize,
+  end_byte: usize,
+  start_line: usize,
+  end_line: usize,
+}
+
+impl ChangeReport {
+  /// Builds the change report of the output summaries of a run with the given `piranha_arguments`
+  pub fn new(
+    summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+  ) -> ChangeReport {
+    let go_modules = if *piranha_arguments.language().supported_language() == SupportedLanguage::Go
+    {
+      find_go_modules(piranha_arguments.path_to_codebase())
+    } else {
+      vec![]
+    };
+    // The paths of the owners are relative to the root of the git repository (or to the code base, outside of a git repository)
+    let directory = get_directory(piranha_arguments.path_to_codebase());
+    let root = get_top_level(directory).unwrap_or_else(|_| directory.to_path_buf());
+    let code_owners = if piranha_arguments.path_to_codebase().is_empty() {
+      CodeOwners::default()
+    } else {
+      CodeOwners::read(&root)
+    };
+    let mut files: Vec<FileReport> = summaries
+      .iter()
+      .map(|s| FileReport::new(s, piranha_arguments))
+      .filter(|f| !f.rewrites.is_empty() || !f.warnings.is_empty())
+      .map(|mut f| {
+        f.module =
+          get_module_of(&go_modules, Path::new(&f.path)).map(|m| m.module_path().to_string());
+        f.owners = get_relative_path(&root, &f.path)
+          .map(|p| code_owners.get_owners(&p))
+          .unwrap_or_default();
+        f
+      })
+      .collect();
+    files.sort_by(|a, b| a.path.cmp(&b.path));
+    let mut modules: Vec<ModuleReport> = go_modules
+      .iter()
+      .map(|m| ModuleReport {
+        module: m.module_path().to_string(),
+        ..Default::default()
+      })
+      .collect();
+    for file in &files {
+      if let Some(module) = modules
+        .iter_mut()
+        .find(|m| Some(&m.module) == file.module.as_ref())
+      {
+        module.files += 1;
+        module.lines_added += file.lines_added;
+        module.lines_removed += file.lines_removed;
+      }
+    }
+    modules.retain(|m| m.files > 0);
+    ChangeReport {
+      lines_added: files.iter().map(|f| f.lines_added).sum(),
+      lines_removed: files.iter().map(|f| f.lines_removed).sum(),
+      files,
+      modules,
+      statistics: None,
+    }
+  }
+
+  /// Includes the summary statistics of the run in the report
+  pub fn with_statistics(mut self, statistics: &RunStatistics) -> ChangeReport {
+    self.statistics = Some(statistics.clone());
+    self
+  }
+
+  pub fn to_json(&self) -> String {
+    serde_json::to_string_pretty(self).unwrap_or_default()
+  }
+}
+
+/// Returns the report of the changes of the output summaries, in the format of the `report` argument (see `REPORT_FORMATS`)
+/// along with the summary statistics of the run (if any, only for the `json` and `html` formats).
+pub fn get_report(
+  summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+  statistics: Option<&RunStatistics>,
+) -> String {
+  match (piranha_arguments.report().as_str(), statistics) {
+    ("sarif", _) => SarifLog::new(summaries, piranha_arguments).to_json(),
+    ("analysis", _) => AnalysisReport::new(summaries, piranha_arguments).to_json(),
+    ("html", Some(s)) => HtmlReport::new(summaries, piranha_arguments)
+      .with_statistics(s)
+      .to_html(),
+    ("html", None) => HtmlReport::new(summaries, piranha_arguments).to_html(),
+    (_, Some(s)) => ChangeReport::new(summaries, piranha_arguments)
+      .with_statistics(s)
+      .to_json(),
+    (_, None) => ChangeReport::new(summaries, piranha_arguments).to_json(),
+  }
+}
+
+/// Returns the flag whose cleanup applied each of the rewrites of the file (i.e. its `stale_flag_name`, if any).
+/// The rewrites of each flag are contiguous (see `rewrites_per_flag`), and they all belong to the only flag otherwise.
+pub(super) fn get_flags_of_rewrites(
+  summary: &PiranhaOutputSummary, piranha_arguments: &PiranhaArguments,
+) -> Vec<Option<String>> {
+  let mut flags = summary
+    .rewrites_per_flag()
+    .iter()
+    .flat_map(|(flag, n)| std::iter::repeat(flag.to_string()).take(*n));
+  let default_flag = piranha_arguments
+    .input_substitutions()
+    .get(STALE_FLAG_NAME)
+    .cloned();
+  summary
+    .rewrites()
+    .iter()
+    .map(|_| flags.next().or_else(|| default_flag.clone()))
+    .collect()
+}
+
+impl FileReport {
+  fn new(summary: &PiranhaOutputSummary, piranha_arguments: &PiranhaArguments) -> FileReport {
+    let rewrites = summary
+      .rewrites()
+      .iter()
+      .zip(get_flags_of_rewrites(summary, piranha_arguments))
+      .map(|(e, flag)| ReportRewrite::new(e, flag, piranha_arguments))
+      .collect();
+
+    let mut warnings = summary
+      .suppressed_matches()
+      .iter()
+      .map(|(rule, m)| ReportWarning::new("suppressed_match", Some(rule), m))
+      .chain(
+        summary
+          .matches()
+          .iter()
+          .filter(|(rule, _)| {
+            [
+              BUILD_FAILURE,
+              TEST_FAILURE,
+              DYNAMIC_FLAG_NAME,
+              SKIPPED_GENERATED_FILE,
+              STRANDED_FUNCTION,
+              STRANDED_PROVIDER_SET,
+              STRANDED_TYPE,
+            ]
+            .contains(&rule.as_str())
+          })
+          .map(|(rule, m)| ReportWarning::new(rule, None, m)),
+      )
+      .chain(
+        summary
+          .rewrites()
+          .iter()
+          .filter(|e| {
+            [
+              MARK_TEST_FOR_ELIMINATED_FLAG_VALUE,
+              VERIFY_LOW_CONFIDENCE_EDIT,
+            ]
+            .contains(&e.matched_rule().as_str())
+          })
+          .map(|e| ReportWarning::new(e.matched_rule(), None, e.p_match())),
+      )
+      .collect::<Vec<_>>();
+    warnings.sort_by_key(|w| w.range.start_byte);
+
+    let tests = summary.matches().iter().find_map(|(rule, _)| {
+      if rule == TESTS_PASSED {
+        Some("passed".to_string())
+      } else if rule == TEST_FAILURE {
+        Some("failed".to_string())
+      } else {
+        None
+      }
+    });
+
+    let (lines_added, lines_removed) =
+      count_changed_lines(summary.original_content(), summary.content());
+    FileReport {
+      path: summary.path().to_string(),
+      module: None,
+      owners: vec![],
+      rewrites,
+      warnings,
+      tests,
+      lines_added,
+      lines_removed,
+    }
+  }
+}
+
+impl ReportRewrite {
+  fn new(edit: &Edit, flag: Option<String>, piranha_arguments: &PiranhaArguments) -> ReportRewrite {
+    ReportRewrite {
+      flag,
+      rule: edit.matched_rule().to_string(),
+      provenance: Provenance::of(edit.matched_rule(), piranha_arguments),
+      range: ReportRange::from(edit.p_match()),
+      code: edit.p_match().matched_string().to_string(),
+      replacement: edit.replacement_string().to_string(),
+      lines_removed: count_lines(edit.p_match().matched_string()),
+      lines_added: count_lines(edit.replacement_string()),
+    }
+  }
+}
+
+impl ReportWarning {
+  fn new(kind: &str, rule: Option<&String>, p_match: &Match) -> ReportWarning {
+    ReportWarning {
+      kind: kind.to_string(),
+      rule: rule.cloned(),
+      range: ReportRange::from(p_match),
+      code: p_match.matched_string().to_string(),
+      message: if kind == BUILD_FAILURE || kind == TEST_FAILURE {
+        p_match.matches().get(GO_OUTPUT).cloned()
+      } else {
+        None
+      },
+    }
+  }
+}
+
+impl From<&Match> for ReportRange {
+  fn from(p_match: &Match) -> Self {
+    let range = p_match.range();
+    ReportRange {
+      start_byte: range.start_byte,
+      end_byte: range.end_byte,
+      start_line: range.start_point.row + 1,
+      end_line: range.end_point.row + 1,
+    }
+  }
+}
+
+/// Returns the number of (possibly partial) lines of the code, i.e. 0 for an empty string
+fn count_lines(code: &str) -> usize {
+  code.lines().count()
+}
+
+#[cfg(test)]
+#[path = "unit_tests/report_test.rs"]
+mod report_test;
@@ -11,7 +11,10 @@ Copyright (c) 2023 Uber Technologies, Inc.
  limitations under the License.
 */
 
-use std::collections::{HashMap, HashSet};
+use std::{
+  collections::{HashMap, HashSet},
+  path::PathBuf,
+};
 
 use colored::Colorize;
 use derive_builder::Builder;
@@ -22,9 +25,10 @@ use serde_derive::Deserialize;
 use crate::utilities::{gen_py_str_methods, tree_sitter_utilities::TSQuery, Instantiate};
 
 use super::{
+  confidence::get_confidence_rank,
   default_configs::{
-    default_filters, default_groups, default_holes, default_is_seed_rule, default_query,
-    default_replace, default_replace_node, default_rule_name,
+    default_confidence, default_filters, default_groups, default_holes, default_is_seed_rule,
+    default_query, default_replace, default_replace_node, default_rule_name,
   },
   filter::Filter,
   Validator,
@@ -87,6 +91,14 @@ pub struct Rule {
   #[get = "pub"]
   #[pyo3(get)]
   is_seed_rule: bool,
+
+  /// The confidence (i.e. `low`, `medium` or `high`) that the edits of the rule are safe.
+  /// The edits of the rules below the `confidence_threshold` are annotated for a manual verification instead of being applied.
+  #[builder(default = "default_confidence()")]
+  #[serde(default = "default_confidence")]
+  #[get = "pub"]
+  #[pyo3(get)]
+  confidence: String,
 }
 
 impl Rule {
@@ -132,6 +144,7 @@ macro_rules! piranha_rule {
                 $(, is_seed_rule = $is_seed_rule:expr)?
                 $(, groups = [$($group_name: expr)*])?
                 $(, filters = [$($filter:tt)*])?
+                $(, confidence = $confidence:expr)?
               ) => {
     $crate::models::rule::RuleBuilder::default()
     .name($name.to_string())
@@ -141,6 +154,7 @@ macro_rules! piranha_rule {
     $(.holes(std::collections::HashSet::from([$($hole.to_string(),)*])))?
     $(.groups(std::collections::HashSet::from([$($group_name.to_string(),)*])))?
     $(.filters(std::collections::HashSet::from([$($filter)*])))?
+    $(.confidence($confidence.to_string()))?
     .build().unwrap()
   };
 }
@@ -151,7 +165,7 @@ impl Rule {
   fn py_new(
     name: String, query: Option<String>, replace: Option<String>, replace_node: Option<String>,
     holes: Option<HashSet<String>>, groups: Option<HashSet<String>>,
-    filters: Option<HashSet<Filter>>, is_seed_rule: Option<bool>,
+    filters: Option<HashSet<Filter>>, is_seed_rule: Option<bool>, confidence: Option<String>,
   ) -> Self {
     let mut rule_builder = RuleBuilder::default();
 
@@ -184,6 +198,10 @@ impl Rule {
       rule_builder.is_seed_rule(is_seed_rule);
     }
 
+    if let Some(confidence) = confidence {
+      rule_builder.confidence(confidence);
+    }
+
     rule_builder.build().unwrap()
   }
 
@@ -195,7 +213,12 @@ impl Validator for Rule {
     let validation = self
       .query()
       .validate()
-      .and_then(|_: ()| self.filters().iter().try_for_each(|f| f.validate()));
+      .and_then(|_: ()| self.filters().iter().try_for_each(|f| f.validate()))
+      .and_then(|_: ()| {
+        get_confidence_rank(self.confidence())
+          .map(|_| ())
+          .map_err(|e| format!("{e} for the rule `{}`", self.name()))
+      });
     validation
   }
 }
@@ -208,6 +231,9 @@ pub(crate) struct InstantiatedRule {
   rule: Rule,
   #[get = "pub"]
   substitutions: HashMap<String, String>,
+  // The package (i.e. its directory and name) whose files the rule is restricted to, if any (see `restrict_to_package`)
+  #[get = "pub"]
+  package: Option<(PathBuf, String)>,
 }
 
 impl InstantiatedRule {
@@ -226,6 +252,15 @@ impl InstantiatedRule {
     InstantiatedRule {
       rule: rule.instantiate(&substitutions_for_holes),
       substitutions: substitutions_for_holes,
+      package: None,
+    }
+  }
+
+  /// Returns a copy of the rule, restricted to the files of the given package (i.e. its directory and name)
+  pub(crate) fn restricted_to(&self, package: (PathBuf, String)) -> Self {
+    InstantiatedRule {
+      package: Some(package),
+      ..self.clone()
     }
   }
 
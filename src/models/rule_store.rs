@@ -12,14 +12,14 @@ Copyright (c) 2023 Uber Technologies, Inc.
 */
 
 use std::{
-  collections::HashMap,
+  collections::{HashMap, HashSet},
   path::{Path, PathBuf},
 };
 
 use colored::Colorize;
 use getset::Getters;
 use itertools::Itertools;
-use jwalk::WalkDir;
+use jwalk::WalkDirGeneric;
 use log::{debug, trace};
 use regex::Regex;
 use tree_sitter::Query;
@@ -30,7 +30,14 @@ use crate::{
   utilities::{read_file, tree_sitter_utilities::TSQuery},
 };
 
-use super::{language::PiranhaLanguage, rule::InstantiatedRule};
+use super::{
+  generated_files::is_generated,
+  git_ignore::GitIgnore,
+  language::{PiranhaLanguage, SupportedLanguage},
+  piranha_ignore::read_piranha_ignore,
+  rule::InstantiatedRule,
+  suppressions::has_ignore_directive,
+};
 use glob::Pattern;
 
 /// This maintains the state for Piranha.
@@ -44,14 +51,32 @@ pub(crate) struct RuleStore {
 
   #[get = "pub"]
   language: PiranhaLanguage,
+
+  // Whether the generated files are rewritten as well
+  include_generated: bool,
+
+  // The files changed since the `since` ref (if any), i.e. the only files walked over
+  changed_files: Option<HashSet<PathBuf>>,
+
+  // Whether the symbolic links to directories are walked over
+  follow_symlinks: bool,
+
+  // The files walked over so far (i.e. before filtering them with the grep heuristics)
+  #[get = "pub"]
+  scanned_files: HashSet<PathBuf>,
+
+  // The generated files skipped while walking over the code base so far (see `is_skipped_generated_file`)
+  #[get = "pub"]
+  skipped_generated_files: HashMap<PathBuf, String>,
+
+  // The files with `piranha:ignore` directives walked over so far, whose suppressed matches are reported (see `suppressions`)
+  #[get = "pub"]
+  files_with_ignore_directives: HashMap<PathBuf, String>,
 }
 
 impl RuleStore {
   pub(crate) fn new(args: &PiranhaArguments) -> RuleStore {
-    let mut rule_store = RuleStore {
-      language: args.language().clone(),
-      ..Default::default()
-    };
+    let mut rule_store = RuleStore::without_rules(args);
 
     for rule in args.rule_graph().rules().clone() {
       if *rule.is_seed_rule() {
@@ -62,11 +87,47 @@ impl RuleStore {
     rule_store
   }
 
+  /// Returns a rule store without global rules, e.g. to get the files of the code base (see `get_files`) without instantiating
+  /// the seed rules (i.e. when their holes are not substituted)
+  pub(crate) fn without_rules(args: &PiranhaArguments) -> RuleStore {
+    RuleStore {
+      language: args.language().clone(),
+      include_generated: *args.include_generated(),
+      changed_files: args.changed_files().clone(),
+      follow_symlinks: *args.follow_symlinks(),
+      ..Default::default()
+    }
+  }
+
+  /// Keeps the files skipped while walking over the code base for the previous flags (see `get_relevant_files`),
+  /// so that they are reported once all the flags are cleaned up
+  pub(crate) fn keep_skipped_files(&mut self, previous_rule_store: RuleStore) {
+    self
+      .skipped_generated_files
+      .extend(previous_rule_store.skipped_generated_files);
+  }
+
+  /// Returns a copy of this rule store for a worker of the pool (see `jobs`), i.e. with the same global rules,
+  /// but its own cache of compiled queries
+  pub(crate) fn fork(&self) -> RuleStore {
+    RuleStore {
+      global_rules: self.global_rules.clone(),
+      language: self.language.clone(),
+      include_generated: self.include_generated,
+      changed_files: self.changed_files.clone(),
+      follow_symlinks: self.follow_symlinks,
+      ..Default::default()
+    }
+  }
+
   /// Add a new global rule, along with grep heuristics (If it doesn't already exist)
   pub(crate) fn add_to_global_rules(&mut self, rule: &InstantiatedRule) {
     let r = rule.clone();
     if !self.global_rules.iter().any(|r| {
-      r.name().eq(&rule.name()) && r.replace().eq(&rule.replace()) && r.query().eq(&rule.query())
+      r.name().eq(&rule.name())
+        && r.replace().eq(&rule.replace())
+        && r.query().eq(&rule.query())
+        && r.package().eq(rule.package())
     }) {
       #[rustfmt::skip]
       debug!("{}", format!("Added Global Rule : {:?} - {}", r.name(), r.query().get_query()).bright_blue());
@@ -127,7 +188,68 @@ impl RuleStore {
   /// Gets all the files from the code base that (i) have the language appropriate file extension, and (ii) contains the grep pattern.
   /// Note that `WalkDir` traverses the directory with parallelism.
   /// If all the global rules have no holes (i.e. we will have no grep patterns), we will try to find a match for each global rule in every file in the target.
+  /// The files walked over are recorded as the `scanned_files`, the generated files skipped along the way as the
+  /// `skipped_generated_files` and the files with `piranha:ignore` directives as the `files_with_ignore_directives`,
+  /// so that they are reported without walking over the code base again.
   pub(crate) fn get_relevant_files(
+    &mut self, path_to_codebase: &str, include: &Vec<Pattern>, exclude: &Vec<Pattern>,
+  ) -> HashMap<PathBuf, String> {
+    let (generated_files, mut files): (HashMap<_, _>, HashMap<_, _>) = self
+      .walk_files(path_to_codebase, include, exclude)
+      .into_iter()
+      .partition(|(_, content)| self.is_skipped_generated_file(content));
+    self.scanned_files.extend(files.keys().cloned());
+    self.skipped_generated_files.extend(generated_files);
+    self.files_with_ignore_directives.extend(
+      files
+        .iter()
+        .filter(|(_, content)| has_ignore_directive(content))
+        .map(|(path, content)| (path.clone(), content.clone())),
+    );
+
+    //If the path_to_codebase is a file, then execute piranha on it
+    if Path::new(path_to_codebase).is_file() {
+      return files;
+    }
+
+    if self.any_global_rules_has_holes() {
+      let pattern = self.get_grep_heuristics();
+      files = files
+        .iter()
+        // Filter the files containing the desired regex pattern
+        .filter(|x| pattern.is_match(x.1.as_str()))
+        .map(|(x, y)| (x.clone(), y.clone()))
+        .collect();
+    }
+    debug!(
+      "{}",
+      format!("{} files will be analyzed.", files.len()).green()
+    );
+    files
+  }
+
+  /// Gets all the files from the code base that have the language appropriate file extension (regardless of their content).
+  /// The generated files are skipped (see `is_skipped_generated_file`), and so are the paths ignored by the `.gitignore` files
+  /// of the code base (and of its git repository) and the symbolic links to directories (unless `follow_symlinks` is set).
+  pub(crate) fn get_files(
+    &self, path_to_codebase: &str, include: &Vec<Pattern>, exclude: &Vec<Pattern>,
+  ) -> HashMap<PathBuf, String> {
+    self
+      .walk_files(path_to_codebase, include, exclude)
+      .into_iter()
+      .filter(|(_, content)| !self.is_skipped_generated_file(content))
+      .collect()
+  }
+
+  /// Checks if the file is generated (i.e. it has the `// Code generated ... DO NOT EDIT.` header),
+  /// and thus not rewritten unless `include_generated` is set. Currently, only supported for Go.
+  pub(crate) fn is_skipped_generated_file(&self, content: &str) -> bool {
+    !self.include_generated
+      && *self.language.supported_language() == SupportedLanguage::Go
+      && is_generated(content)
+  }
+
+  fn walk_files(
     &self, path_to_codebase: &str, include: &Vec<Pattern>, exclude: &Vec<Pattern>,
   ) -> HashMap<PathBuf, String> {
     let _path_to_codebase = Path::new(path_to_codebase).to_path_buf();
@@ -140,34 +262,56 @@ impl RuleStore {
       )]);
     }
 
-    let mut files: HashMap<PathBuf, String> = WalkDir::new(path_to_codebase)
+    let ignored = read_piranha_ignore(path_to_codebase);
+    // The patterns are matched against the path of the file, and against its path relative to the code base
+    let matches = |p: &Pattern, f: &Path| {
+      p.matches_path(f)
+        || f
+          .strip_prefix(path_to_codebase)
+          .map(|r| p.matches_path(r))
+          .unwrap_or(false)
+    };
+
+    WalkDirGeneric::<(GitIgnore, ())>::new(path_to_codebase)
+      // do not descend into the symbolic links to directories (e.g. to a `node_modules` tree), unless `follow_symlinks` is set
+      .follow_links(self.follow_symlinks)
+      // skip the paths ignored by the `.gitignore` files, without descending into the ignored directories (e.g. the build outputs)
+      .root_read_dir_state(GitIgnore::new(Path::new(path_to_codebase)))
+      .process_read_dir(|_, directory, git_ignore, children| {
+        git_ignore.read_directory(directory);
+        children.retain(|c| {
+          c.as_ref()
+            .map(|e| !git_ignore.is_ignored(&e.path(), e.file_type().is_dir()))
+            .unwrap_or(false)
+        })
+      })
       // walk over the entire code base
       .into_iter()
       // ignore errors
       .filter_map(|e| e.ok())
       // only retain the included paths (if any)
-      .filter(|f| include.is_empty() || include.iter().any(|p| p.matches_path(&f.path())))
+      .filter(|f| include.is_empty() || include.iter().any(|p| matches(p, &f.path())))
       // filter out all excluded paths (if any)
-      .filter(|f| exclude.is_empty() || exclude.iter().all(|p| !p.matches_path(&f.path())))
+      .filter(|f| exclude.is_empty() || exclude.iter().all(|p| !matches(p, &f.path())))
+      // filter out the paths ignored by the `.piranhaignore` file (if any)
+      .filter(|f| ignored.iter().all(|p| !matches(p, &f.path())))
       // filter files with the desired extension
       .filter(|de| self.language().can_parse(de))
+      // only retain the files changed since the `since` ref (if any)
+      .filter(|f| {
+        self
+          .changed_files
+          .as_ref()
+          .map(|c| {
+            f.path()
+              .canonicalize()
+              .map(|p| c.contains(&p))
+              .unwrap_or(false)
+          })
+          .unwrap_or(true)
+      })
       // read the file
       .map(|f| (f.path(), read_file(&f.path()).unwrap()))
-      .collect();
-
-    if self.any_global_rules_has_holes() {
-      let pattern = self.get_grep_heuristics();
-      files = files
-        .iter()
-        // Filter the files containing the desired regex pattern
-        .filter(|x| pattern.is_match(x.1.as_str()))
-        .map(|(x, y)| (x.clone(), y.clone()))
-        .collect();
-    }
-    debug!(
-      "{}",
-      format!("{} files will be analyzed.", files.len()).green()
-    );
-    files
+      .collect()
   }
 }
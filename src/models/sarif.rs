@@ -0,0 +1,471 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashSet;
+
+use itertools::Itertools;
+use serde_derive::Serialize;
+
+use super::{
+  build_verification::{BUILD_FAILURE, GO_OUTPUT, TEST_FAILURE},
+  confidence::VERIFY_LOW_CONFIDENCE_EDIT,
+  dynamic_flag_names::DYNAMIC_FLAG_NAME,
+  edit::Edit,
+  generated_files::SKIPPED_GENERATED_FILE,
+  matches::Match,
+  piranha_arguments::PiranhaArguments,
+  piranha_output::PiranhaOutputSummary,
+  report::get_flags_of_rewrites,
+  stranded_functions::{STRANDED_FUNCTION, STRANDED_PROVIDER_SET, STRANDED_TYPE},
+  test_cleanup::MARK_TEST_FOR_ELIMINATED_FLAG_VALUE,
+};
+use crate::utilities::get_hunks;
+
+static SARIF_SCHEMA: &str = "https://json.schemastore.org/sarif-2.1.0.json";
+static SARIF_VERSION: &str = "2.1.0";
+static TOOL_NAME: &str = "Piranha";
+static TOOL_URI: &str = "https://github.com/uber/piranha";
+
+/// The SARIF (2.1.0) log of the findings of a Piranha run (e.g. for GitHub Code Scanning), i.e.
+/// * the evaluations of the stale flags (i.e. the rewrites of the seed rules), with the fix computed by Piranha,
+/// * the sites that were not cleaned up, or need a manual review (see `ReportWarning`).
+///
+/// The regions refer to the original content of the files (i.e. as scanned, before the rewrites).
+#[derive(Serialize, Debug, Clone, Default)]
+pub(crate) struct SarifLog {
+  #[serde(rename = "$schema")]
+  schema: String,
+  version: String,
+  runs: Vec<SarifRun>,
+}
+
+#[derive(Serialize, Debug, Clone, Default)]
+struct SarifRun {
+  tool: SarifTool,
+  results: Vec<SarifResult>,
+  #[serde(rename = "columnKind")]
+  column_kind: String,
+}
+
+#[derive(Serialize, Debug, Clone, Default)]
+struct SarifTool {
+  driver: SarifDriver,
+}
+
+#[derive(Serialize, Debug, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+struct SarifDriver {
+  name: String,
+  information_uri: String,
+  rules: Vec<SarifRule>,
+}
+
+#[derive(Serialize, Debug, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+struct SarifRule {
+  id: String,
+  short_description: SarifMessage,
+}
+
+#[derive(Serialize, Debug, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+struct SarifResult {
+  rule_id: String,
+  level: String,
+  message: SarifMessage,
+  locations: Vec<SarifLocation>,
+  #[serde(skip_serializing_if = "Vec::is_empty")]
+  fixes: Vec<SarifFix>,
+}
+
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Eq)]
+struct SarifMessage {
+  text: String,
+}
+
+#[derive(Serialize, Debug, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+struct SarifLocation {
+  physical_location: SarifPhysicalLocation,
+}
+
+#[derive(Serialize, Debug, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+struct SarifPhysicalLocation {
+  artifact_location: SarifArtifactLocation,
+  region: SarifRegion,
+}
+
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Eq)]
+struct SarifArtifactLocation {
+  uri: String,
+}
+
+/// A region of the original content of a file, with 1-based lines and columns (in unicode code points).
+/// The regions replaced by the fixes are given as byte offsets instead.
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Eq)]
+#[serde(rename_all = "camelCase")]
+struct SarifRegion {
+  #[serde(skip_serializing_if = "Option::is_none")]
+  start_line: Option<usize>,
+  #[serde(skip_serializing_if = "Option::is_none")]
+  start_column: Option<usize>,
+  #[serde(skip_serializing_if = "Option::is_none")]
+  end_line: Option<usize>,
+  #[serde(skip_serializing_if = "Option::is_none")]
+  end_column: Option<usize>,
+  #[serde(skip_serializing_if = "Option::is_none")]
+  byte_offset: Option<usize>,
+  #[serde(skip_serializing_if = "Option::is_none")]
+  byte_length: Option<usize>,
+}
+
+#[derive(Serialize, Debug, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+struct SarifFix {
+  description: SarifMessage,
+  artifact_changes: Vec<SarifArtifactChange>,
+}
+
+#[derive(Serialize, Debug, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+struct SarifArtifactChange {
+  artifact_location: SarifArtifactLocation,
+  replacements: Vec<SarifReplacement>,
+}
+
+#[derive(Serialize, Debug, Clone, Default)]
+#[serde(rename_all = "camelCase")]
+struct SarifReplacement {
+  deleted_region: SarifRegion,
+  inserted_content: SarifMessage,
+}
+
+impl SarifLog {
+  /// Builds the SARIF log of the output summaries of a run with the given `piranha_arguments`
+  pub(crate) fn new(
+    summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+  ) -> SarifLog {
+    let seed_rules = piranha_arguments.get_seed_rule_names();
+    let mut results = vec![];
+    for summary in summaries.iter().sorted_by(|a, b| a.path().cmp(b.path())) {
+      results.extend(get_results(summary, piranha_arguments, &seed_rules));
+    }
+    let rules = results
+      .iter()
+      .map(|r| r.rule_id.to_string())
+      .unique()
+      .sorted()
+      .map(|id| SarifRule {
+        short_description: SarifMessage {
+          text: get_rule_description(&id, &seed_rules),
+        },
+        id,
+      })
+      .collect();
+    SarifLog {
+      schema: SARIF_SCHEMA.to_string(),
+      version: SARIF_VERSION.to_string(),
+      runs: vec![SarifRun {
+        tool: SarifTool {
+          driver: SarifDriver {
+            name: TOOL_NAME.to_string(),
+            information_uri: TOOL_URI.to_string(),
+            rules,
+          },
+        },
+        results,
+        column_kind: "unicodeCodePoints".to_string(),
+      }],
+    }
+  }
+
+  pub(crate) fn to_json(&self) -> String {
+    serde_json::to_string_pretty(self).unwrap_or_default()
+  }
+}
+
+/// Returns the results of the file, i.e. the rewrites of the seed rules (with the hunks of the diff they are part of as the fix),
+/// and the sites that were not cleaned up, or need a manual review.
+fn get_results(
+  summary: &PiranhaOutputSummary, piranha_arguments: &PiranhaArguments,
+  seed_rules: &HashSet<String>,
+) -> Vec<SarifResult> {
+  let original_content = summary.original_content();
+  let uri = summary.path().trim_start_matches("./").to_string();
+  let hunks = get_hunks(original_content, summary.content());
+  let rewrites = summary.rewrites();
+  let mut results = vec![];
+  for (index, (edit, flag)) in rewrites
+    .iter()
+    .zip(get_flags_of_rewrites(summary, piranha_arguments))
+    .enumerate()
+  {
+    let (start, end) = map_to_original(rewrites, index, edit.p_match());
+    if edit.matched_rule() == MARK_TEST_FOR_ELIMINATED_FLAG_VALUE {
+      results.push(get_result(
+        edit.matched_rule(),
+        "warning",
+        "The test forces the eliminated value of the stale flag, delete it if it only exercises the removed branch".to_string(),
+        &uri,
+        original_content,
+        (start, end),
+        vec![],
+      ));
+      continue;
+    }
+    if edit.matched_rule() == VERIFY_LOW_CONFIDENCE_EDIT {
+      results.push(get_result(
+        edit.matched_rule(),
+        "warning",
+        "The code depends on the stale flag, but the confidence of its cleanup is below the threshold, verify its removal".to_string(),
+        &uri,
+        original_content,
+        (start, end),
+        vec![],
+      ));
+      continue;
+    }
+    if !seed_rules.contains(edit.matched_rule()) {
+      continue;
+    }
+    let flag = flag.unwrap_or_else(|| "stale flag".to_string());
+    let message = if edit.replacement_string().is_empty() {
+      format!(
+        "`{}` depends on the stale flag `{flag}`, and can be deleted",
+        edit.p_match().matched_string()
+      )
+    } else {
+      format!(
+        "`{}` evaluates the stale flag `{flag}`, and can be replaced with `{}`",
+        edit.p_match().matched_string(),
+        edit.replacement_string()
+      )
+    };
+    let replacements = hunks
+      .iter()
+      .filter(|h| h.start <= end && start <= h.end)
+      .map(|h| SarifReplacement {
+        deleted_region: SarifRegion {
+          byte_offset: Some(h.start),
+          byte_length: Some(h.end - h.start),
+          ..Default::default()
+        },
+        inserted_content: SarifMessage {
+          text: h.text.to_string(),
+        },
+      })
+      .collect_vec();
+    let fixes = if replacements.is_empty() {
+      vec![]
+    } else {
+      vec![SarifFix {
+        description: SarifMessage {
+          text: format!("Clean up the stale flag `{flag}`"),
+        },
+        artifact_changes: vec![SarifArtifactChange {
+          artifact_location: SarifArtifactLocation {
+            uri: uri.to_string(),
+          },
+          replacements,
+        }],
+      }]
+    };
+    results.push(get_result(
+      edit.matched_rule(),
+      "warning",
+      message,
+      &uri,
+      original_content,
+      (start, end),
+      fixes,
+    ));
+  }
+
+  // The matches reported after the cleanup refer to the content of the file after all the rewrites
+  let mut reported = vec![];
+  for (rule, p_match) in summary.suppressed_matches() {
+    reported.push((
+      rule.to_string(),
+      "note",
+      format!("The match of `{rule}` is exempted from the cleanup by a `piranha:ignore` directive"),
+      p_match,
+    ));
+  }
+  for (rule, p_match) in summary.matches() {
+    if rule == DYNAMIC_FLAG_NAME {
+      reported.push((
+        rule.to_string(),
+        "warning",
+        format!("`{}` may build the name of the stale flag at runtime. It needs to be cleaned up manually", p_match.matched_string()),
+        p_match,
+      ));
+    } else if rule == STRANDED_FUNCTION || rule == STRANDED_PROVIDER_SET {
+      reported.push((
+        rule.to_string(),
+        "warning",
+        "The declaration is no longer referenced after the cleanup. It may be deleted (see `aggressive_dead_code`)".to_string(),
+        p_match,
+      ));
+    } else if rule == STRANDED_TYPE {
+      reported.push((
+        rule.to_string(),
+        "warning",
+        "The type is only referenced by the code stranded by the cleanup. It may be deleted along with its methods".to_string(),
+        p_match,
+      ));
+    } else if rule == BUILD_FAILURE {
+      reported.push((
+        rule.to_string(),
+        "error",
+        format!(
+          "The package no longer builds after the cleanup : {}",
+          p_match
+            .matches()
+            .get(GO_OUTPUT)
+            .cloned()
+            .unwrap_or_default()
+        ),
+        p_match,
+      ));
+    } else if rule == TEST_FAILURE {
+      reported.push((
+        rule.to_string(),
+        "error",
+        format!(
+          "The tests of the package fail after the cleanup : {}",
+          p_match
+            .matches()
+            .get(GO_OUTPUT)
+            .cloned()
+            .unwrap_or_default()
+        ),
+        p_match,
+      ));
+    } else if rule == SKIPPED_GENERATED_FILE {
+      reported.push((
+        rule.to_string(),
+        "note",
+        "The generated file is not rewritten (see `include_generated`)".to_string(),
+        p_match,
+      ));
+    }
+  }
+  for (rule, level, message, p_match) in reported {
+    let range = map_to_original(rewrites, rewrites.len(), p_match);
+    results.push(get_result(
+      &rule,
+      level,
+      message,
+      &uri,
+      original_content,
+      range,
+      vec![],
+    ));
+  }
+  results
+}
+
+fn get_result(
+  rule_id: &str, level: &str, message: String, uri: &str, original_content: &str,
+  range: (usize, usize), fixes: Vec<SarifFix>,
+) -> SarifResult {
+  SarifResult {
+    rule_id: rule_id.to_string(),
+    level: level.to_string(),
+    message: SarifMessage { text: message },
+    locations: vec![SarifLocation {
+      physical_location: SarifPhysicalLocation {
+        artifact_location: SarifArtifactLocation {
+          uri: uri.to_string(),
+        },
+        region: get_region(original_content, range),
+      },
+    }],
+    fixes,
+  }
+}
+
+fn get_rule_description(rule_id: &str, seed_rules: &HashSet<String>) -> String {
+  if seed_rules.contains(rule_id) {
+    format!("Evaluation of a stale flag (matched by `{rule_id}`)")
+  } else if rule_id == DYNAMIC_FLAG_NAME {
+    "Name of a stale flag built at runtime".to_string()
+  } else if rule_id == BUILD_FAILURE {
+    "Package no longer building after the cleanup of a stale flag".to_string()
+  } else if rule_id == TEST_FAILURE {
+    "Package whose tests fail after the cleanup of a stale flag".to_string()
+  } else if rule_id == SKIPPED_GENERATED_FILE {
+    "Generated file skipped by the cleanup".to_string()
+  } else if rule_id == STRANDED_FUNCTION {
+    "Function no longer referenced after the cleanup of a stale flag".to_string()
+  } else if rule_id == STRANDED_PROVIDER_SET {
+    "Provider set no longer referenced after the cleanup of a stale flag".to_string()
+  } else if rule_id == STRANDED_TYPE {
+    "Type only referenced by the code stranded by the cleanup of a stale flag".to_string()
+  } else if rule_id == MARK_TEST_FOR_ELIMINATED_FLAG_VALUE {
+    "Test forcing the eliminated value of a stale flag".to_string()
+  } else if rule_id == VERIFY_LOW_CONFIDENCE_EDIT {
+    "Cleanup of a stale flag whose confidence is below the threshold".to_string()
+  } else {
+    format!("Match of `{rule_id}` exempted from the cleanup")
+  }
+}
+
+/// Maps the byte range of the match, in the content of the file after its first `index` rewrites, to its original content.
+/// A bound within the replacement of a previous rewrite is widened to the code that rewrite replaced.
+pub(super) fn map_to_original(rewrites: &[Edit], index: usize, p_match: &Match) -> (usize, usize) {
+  let range = p_match.range();
+  let (mut start, mut end) = (range.start_byte as i64, range.end_byte as i64);
+  for edit in rewrites[..index].iter().rev() {
+    let edit_range = edit.p_match().range();
+    let (old_start, old_end) = (edit_range.start_byte as i64, edit_range.end_byte as i64);
+    let new_end = old_start + edit.replacement_string().len() as i64;
+    let delta = old_end - new_end;
+    if start >= new_end && start > old_start {
+      start += delta;
+    } else if start > old_start {
+      start = old_start;
+    }
+    if end >= new_end && end > old_start {
+      end += delta;
+    } else if end > old_start {
+      end = old_end;
+    }
+  }
+  (start.max(0) as usize, end.max(start) as usize)
+}
+
+/// Returns the region of the byte range in the content, with 1-based lines and columns
+fn get_region(content: &str, (start, end): (usize, usize)) -> SarifRegion {
+  let position = |offset: usize| {
+    let prefix = content.get(..offset.min(content.len())).unwrap_or(content);
+    let line_start = prefix.rfind('\n').map(|i| i + 1).unwrap_or(0);
+    (
+      prefix.matches('\n').count() + 1,
+      prefix[line_start..].chars().count() + 1,
+    )
+  };
+  let ((start_line, start_column), (end_line, end_column)) = (position(start), position(end));
+  SarifRegion {
+    start_line: Some(start_line),
+    start_column: Some(start_column),
+    end_line: Some(end_line),
+    end_column: Some(end_column),
+    ..Default::default()
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/sarif_test.rs"]
+mod sarif_test;
@@ -0,0 +1,252 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::HashMap,
+  fmt,
+  path::Path,
+  time::{SystemTime, UNIX_EPOCH},
+};
+
+use getset::Getters;
+use itertools::Itertools;
+use log::debug;
+use serde_derive::Serialize;
+
+use super::{
+  changed_files::{get_directory, get_top_level, run_git},
+  code_owners::CodeOwners,
+  inventory::{get_flag_sites, FlagSite},
+  piranha_arguments::PiranhaArguments,
+};
+
+/// The number of seconds of the units of the durations (e.g. `180d`), where a month is 30 days and a year is 365 days
+static DURATION_UNITS: [(char, u64); 5] = [
+  ('h', 3600),
+  ('d', 86400),
+  ('w', 7 * 86400),
+  ('m', 30 * 86400),
+  ('y', 365 * 86400),
+];
+
+/// A flag of the inventory of the `scan`, along with the dates (i.e. UNIX timestamps) of its introduction and last modification
+#[derive(Serialize, Debug, Clone, PartialEq, Eq, Getters)]
+pub struct ScannedFlag {
+  #[get = "pub"]
+  flag: String,
+  #[get = "pub"]
+  sites: Vec<FlagSite>,
+  // The oldest commit adding the name of the flag to the files of its call sites (or the oldest call site, if not found)
+  #[get = "pub"]
+  introduced: u64,
+  // The most recent change of the lines of its call sites
+  #[get = "pub"]
+  last_modified: u64,
+  // The number of days since its last modification
+  #[get = "pub"]
+  age_in_days: u64,
+  // The code owners of the files of its call sites (see `CODEOWNERS`)
+  #[get = "pub"]
+  owners: Vec<String>,
+  // The authors of the last changes of its call sites
+  #[get = "pub"]
+  authors: Vec<String>,
+}
+
+impl fmt::Display for ScannedFlag {
+  fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+    let owners = if self.owners.is_empty() {
+      &self.authors
+    } else {
+      &self.owners
+    };
+    write!(
+      f,
+      "{} - last modified {} ({} days ago), introduced {}, {} call site(s) - owners: {}",
+      self.flag,
+      format_date(self.last_modified),
+      self.age_in_days,
+      format_date(self.introduced),
+      self.sites.len(),
+      if owners.is_empty() {
+        "none".to_string()
+      } else {
+        owners.join(" ")
+      }
+    )
+  }
+}
+
+/// Inventories the call sites of the flag APIs (i.e. the matches of the seed rules, for any flag name) in the code base,
+/// and returns the flags whose call sites are not modified for `stale_after` (i.e. `scan`), the most stale ones first.
+/// The dates are determined with `git blame` (i.e. the last change of the lines of the call sites) and `git log -S`
+/// (i.e. the oldest commit adding the name of the flag to their files).
+pub fn scan_stale_flags(piranha_arguments: &PiranhaArguments) -> Result<Vec<ScannedFlag>, String> {
+  let top_level = get_top_level(get_directory(piranha_arguments.path_to_codebase()))?;
+  let stale_after = parse_duration(piranha_arguments.stale_after())?;
+  let now = SystemTime::now()
+    .duration_since(UNIX_EPOCH)
+    .map_err(|e| e.to_string())?
+    .as_secs();
+  let code_owners = CodeOwners::read(&top_level);
+  let sites = get_flag_sites(piranha_arguments, &top_level);
+  debug!("Found {} call sites of the flag APIs", sites.len());
+
+  let mut blames = HashMap::new();
+  let mut flags = vec![];
+  for (flag, sites) in sites
+    .into_iter()
+    .map(|s| (s.flag().to_string(), s))
+    .into_group_map()
+    .into_iter()
+    .sorted_by(|a, b| a.0.cmp(&b.0))
+  {
+    let mut changes = vec![];
+    for site in &sites {
+      let blame = blames
+        .entry(site.path().to_string())
+        .or_insert_with(|| blame_file(&top_level, site.path()).unwrap_or_default());
+      // The lines not committed yet (or the files not tracked) are modified now
+      changes.push(
+        blame
+          .get(site.line())
+          .cloned()
+          .unwrap_or_else(|| (now, String::new())),
+      );
+    }
+    let last_modified = changes.iter().map(|(t, _)| *t).max().unwrap_or(now);
+    let oldest = changes.iter().map(|(t, _)| *t).min().unwrap_or(now);
+    let paths = sites
+      .iter()
+      .map(|s| s.path().as_str())
+      .unique()
+      .collect_vec();
+    let introduced =
+      get_introduction_time(&top_level, &flag, &paths).map_or(oldest, |t| t.min(oldest));
+    let age_in_days = now.saturating_sub(last_modified) / 86400;
+    if now.saturating_sub(last_modified) < stale_after {
+      continue;
+    }
+    flags.push(ScannedFlag {
+      owners: code_owners.get_owners_of_files(paths.iter().copied()),
+      authors: changes
+        .into_iter()
+        .map(|(_, a)| a)
+        .filter(|a| !a.is_empty())
+        .unique()
+        .sorted()
+        .collect(),
+      flag,
+      sites,
+      introduced,
+      last_modified,
+      age_in_days,
+    });
+  }
+  Ok(
+    flags
+      .into_iter()
+      .sorted_by(|a, b| a.last_modified.cmp(&b.last_modified))
+      .collect(),
+  )
+}
+
+/// Returns the UNIX timestamp and the author (i.e. their email) of the last change of each (1-based) line of the file
+fn blame_file(top_level: &Path, path: &str) -> Result<HashMap<usize, (u64, String)>, String> {
+  Ok(parse_blame(&run_git(
+    top_level,
+    &["blame", "--line-porcelain", "--", path],
+  )?))
+}
+
+/// Parses the output of `git blame --line-porcelain`, i.e. the header of each line (its commit, original and final line numbers)
+/// followed by the information of its commit (e.g. `author-mail` and `author-time`) and the content of the line (after a tab)
+fn parse_blame(output: &str) -> HashMap<usize, (u64, String)> {
+  let mut lines = HashMap::new();
+  let (mut line, mut time, mut author) = (0, 0, String::new());
+  for l in output.lines() {
+    if l.starts_with('\t') {
+      lines.insert(line, (time, author.to_string()));
+    } else if let Some(t) = l.strip_prefix("author-time ") {
+      time = t.trim().parse().unwrap_or_default();
+    } else if let Some(a) = l.strip_prefix("author-mail ") {
+      author = a
+        .trim_matches(|c| c == '<' || c == '>')
+        .replace("not.committed.yet", "");
+    } else {
+      let fields = l.split_whitespace().collect_vec();
+      if fields.len() >= 3
+        && fields[0].len() == 40
+        && fields[0].chars().all(|c| c.is_ascii_hexdigit())
+      {
+        line = fields[2].parse().unwrap_or_default();
+      }
+    }
+  }
+  lines
+}
+
+/// Returns the UNIX timestamp of the oldest commit changing the number of occurrences of the flag name in the files (i.e. `git log -S`)
+fn get_introduction_time(top_level: &Path, flag: &str, paths: &[&str]) -> Option<u64> {
+  let pickaxe = format!("-S{flag}");
+  let mut args = vec!["log", "--format=%at", pickaxe.as_str(), "--"];
+  args.extend(paths);
+  run_git(top_level, &args)
+    .ok()?
+    .lines()
+    .filter_map(|l| l.trim().parse().ok())
+    .min()
+}
+
+/// Parses a duration (e.g. `180d`), i.e. a number followed by its unit (`h`, `d`, `w`, `m` or `y`), into seconds
+pub(crate) fn parse_duration(duration: &str) -> Result<u64, String> {
+  let duration = duration.trim();
+  let invalid = || {
+    format!(
+      "The duration `{duration}` is not a number followed by its unit (i.e. `h`, `d`, `w`, `m` or `y`, e.g. `180d`)"
+    )
+  };
+  let unit = duration.chars().last().ok_or_else(invalid)?;
+  let (_, seconds) = DURATION_UNITS
+    .iter()
+    .find(|(u, _)| *u == unit)
+    .ok_or_else(invalid)?;
+  let number: u64 = duration[..duration.len() - 1]
+    .parse()
+    .map_err(|_| invalid())?;
+  Ok(number * seconds)
+}
+
+/// Formats the UNIX timestamp as a (UTC) date, i.e. `YYYY-MM-DD`
+fn format_date(timestamp: u64) -> String {
+  // See http://howardhinnant.github.io/date_algorithms.html#civil_from_days
+  let days = (timestamp / 86400) as i64 + 719468;
+  let era = days.div_euclid(146097);
+  let day_of_era = days.rem_euclid(146097);
+  let year_of_era =
+    (day_of_era - day_of_era / 1460 + day_of_era / 36524 - day_of_era / 146096) / 365;
+  let day_of_year = day_of_era - (365 * year_of_era + year_of_era / 4 - year_of_era / 100);
+  let month_index = (5 * day_of_year + 2) / 153;
+  let day = day_of_year - (153 * month_index + 2) / 5 + 1;
+  let month = if month_index < 10 {
+    month_index + 3
+  } else {
+    month_index - 9
+  };
+  let year = year_of_era + era * 400 + i64::from(month <= 2);
+  format!("{year:04}-{month:02}-{day:02}")
+}
+
+#[cfg(test)]
+#[path = "unit_tests/scan_test.rs"]
+mod scan_test;
@@ -0,0 +1,438 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  env, fs,
+  io::Write,
+  process::{Command, Stdio},
+};
+
+use itertools::Itertools;
+use log::{debug, warn};
+use serde_json::{json, Value};
+use tempdir::TempDir;
+
+/// The source code management platforms the pull requests can be opened on (i.e. `scm`)
+pub(crate) static SCMS: [&str; 3] = ["github", "gitlab", "bitbucket"];
+
+static GITHUB_API_URL: &str = "https://api.github.com";
+static GITLAB_API_URL: &str = "https://gitlab.com/api/v4";
+static BITBUCKET_API_URL: &str = "https://api.bitbucket.org/2.0";
+
+/// A source code management platform, on which the pull (or merge) requests of the cleanups are opened
+pub(crate) trait Scm {
+  /// Opens the pull request of the `branch` into the `base` branch, and returns its URL.
+  /// The `owners` of the rewritten files (i.e. of `CODEOWNERS`) are requested as reviewers, skipping the ones that cannot
+  /// be resolved to a user (or a team) of the platform. The pull request is opened even if they cannot be requested.
+  fn open_pull_request(
+    &self, branch: &str, base: &str, title: &str, body: &str, owners: &[String],
+  ) -> Result<String, String>;
+}
+
+/// Returns the `scm` hosting the repository of the `remote_url` (i.e. of `origin`), whose API is at `scm_url`
+/// (or the one of the public instance, for an empty `scm_url`). The token is read from the environment variable of the
+/// platform, i.e. `GITHUB_TOKEN`, `GITLAB_TOKEN` or `BITBUCKET_TOKEN`.
+pub(crate) fn get_scm(scm: &str, scm_url: &str, remote_url: &str) -> Result<Box<dyn Scm>, String> {
+  let path = get_remote_path(remote_url)
+    .ok_or_else(|| format!("Could not parse the remote URL `{remote_url}`"))?;
+  let token_variable = format!("{}_TOKEN", scm.to_uppercase());
+  let token = env::var(&token_variable)
+    .map_err(|_| format!("The `{token_variable}` environment variable is not set"))?;
+  // The API URLs of the continuous integration of the platform (if any) point to its instance
+  let api_url = |variable: &str, default: &str| {
+    if scm_url.is_empty() {
+      env::var(variable).unwrap_or_else(|_| default.to_string())
+    } else {
+      scm_url.trim_end_matches('/').to_string()
+    }
+  };
+  let (namespace, name) = path
+    .rsplit_once('/')
+    .map(|(n, r)| (n.rsplit('/').next().unwrap_or(n).to_string(), r.to_string()))
+    .unwrap_or_default();
+  match scm {
+    "github" => Ok(Box::new(GitHub {
+      api_url: api_url("GITHUB_API_URL", GITHUB_API_URL),
+      token,
+      owner: namespace,
+      name,
+    })),
+    "gitlab" => Ok(Box::new(GitLab {
+      api_url: api_url("CI_API_V4_URL", GITLAB_API_URL),
+      token,
+      project: path.replace('/', "%2F"),
+    })),
+    // The self-hosted instances (i.e. Bitbucket Data Center) expose another API than Bitbucket Cloud
+    "bitbucket" if scm_url.is_empty() || scm_url.trim_end_matches('/') == BITBUCKET_API_URL => {
+      Ok(Box::new(BitbucketCloud {
+        api_url: BITBUCKET_API_URL.to_string(),
+        token,
+        workspace: namespace,
+        slug: name,
+      }))
+    }
+    "bitbucket" => Ok(Box::new(BitbucketDataCenter {
+      api_url: scm_url.trim_end_matches('/').to_string(),
+      token,
+      project: namespace,
+      slug: name,
+    })),
+    _ => Err(format!(
+      "The scm `{scm}` is not supported (supported: {SCMS:?})"
+    )),
+  }
+}
+
+/// Opens the pull requests with the REST API of GitHub (or GitHub Enterprise)
+struct GitHub {
+  api_url: String,
+  token: String,
+  owner: String,
+  name: String,
+}
+
+impl Scm for GitHub {
+  fn open_pull_request(
+    &self, branch: &str, base: &str, title: &str, body: &str, owners: &[String],
+  ) -> Result<String, String> {
+    let url = format!("{}/repos/{}/{}/pulls", self.api_url, self.owner, self.name);
+    let headers = [
+      format!("Authorization: Bearer {}", self.token),
+      "Accept: application/vnd.github+json".to_string(),
+      "X-GitHub-Api-Version: 2022-11-28".to_string(),
+    ];
+    let response = call_api(
+      "POST",
+      &url,
+      &headers,
+      Some(&json!({"title": title, "head": branch, "base": base, "body": body})),
+    )?;
+    let pull_request_url = response["html_url"]
+      .as_str()
+      .unwrap_or_default()
+      .to_string();
+    let (reviewers, team_reviewers) = get_github_reviewers(owners);
+    if !reviewers.is_empty() || !team_reviewers.is_empty() {
+      if let Err(e) = call_api(
+        "POST",
+        &format!("{url}/{}/requested_reviewers", response["number"]),
+        &headers,
+        Some(&json!({"reviewers": reviewers, "team_reviewers": team_reviewers})),
+      ) {
+        warn!("Could not request the reviewers of {pull_request_url} : {e}");
+      }
+    }
+    Ok(pull_request_url)
+  }
+}
+
+/// Splits the code owners into the users and the teams (i.e. their slug) to request as reviewers on GitHub.
+/// The owners given by email are skipped, since they cannot be requested through the API.
+pub(crate) fn get_github_reviewers(owners: &[String]) -> (Vec<String>, Vec<String>) {
+  let mut reviewers = vec![];
+  let mut team_reviewers = vec![];
+  for owner in owners.iter().filter_map(|o| o.strip_prefix('@')) {
+    match owner.split_once('/') {
+      Some((_, team)) => team_reviewers.push(team.to_string()),
+      None => reviewers.push(owner.to_string()),
+    }
+  }
+  (reviewers, team_reviewers)
+}
+
+/// Opens the merge requests with the REST API (v4) of GitLab (or a self-hosted instance)
+struct GitLab {
+  api_url: String,
+  token: String,
+  // The URL-encoded path of the project, i.e. including its (nested) groups
+  project: String,
+}
+
+impl Scm for GitLab {
+  fn open_pull_request(
+    &self, branch: &str, base: &str, title: &str, body: &str, owners: &[String],
+  ) -> Result<String, String> {
+    let headers = [format!("PRIVATE-TOKEN: {}", self.token)];
+    // The reviewers are requested by id, and the groups (i.e. `@group/subgroup`) are left to the approval rules of the code owners
+    let reviewer_ids = get_usernames(owners)
+      .iter()
+      .filter_map(|username| {
+        let users = call_api(
+          "GET",
+          &format!("{}/users?username={username}", self.api_url),
+          &headers,
+          None,
+        );
+        match users.map(|u| u[0]["id"].as_u64()) {
+          Ok(Some(id)) => Some(id),
+          _ => {
+            warn!("Could not find the reviewer @{username}");
+            None
+          }
+        }
+      })
+      .collect_vec();
+    let response = call_api(
+      "POST",
+      &format!("{}/projects/{}/merge_requests", self.api_url, self.project),
+      &headers,
+      Some(&json!({
+        "source_branch": branch,
+        "target_branch": base,
+        "title": title,
+        "description": body,
+        "reviewer_ids": reviewer_ids,
+        "remove_source_branch": true,
+      })),
+    )?;
+    Ok(response["web_url"].as_str().unwrap_or_default().to_string())
+  }
+}
+
+/// Opens the pull requests with the REST API (2.0) of Bitbucket Cloud
+struct BitbucketCloud {
+  api_url: String,
+  token: String,
+  workspace: String,
+  slug: String,
+}
+
+impl Scm for BitbucketCloud {
+  fn open_pull_request(
+    &self, branch: &str, base: &str, title: &str, body: &str, owners: &[String],
+  ) -> Result<String, String> {
+    let headers = [format!("Authorization: Bearer {}", self.token)];
+    // The reviewers are requested by UUID, i.e. the one of the member of the workspace with the nickname of the owner
+    let usernames = get_usernames(owners);
+    let reviewers = if usernames.is_empty() {
+      vec![]
+    } else {
+      call_api(
+        "GET",
+        &format!(
+          "{}/workspaces/{}/members?pagelen=100",
+          self.api_url, self.workspace
+        ),
+        &headers,
+        None,
+      )
+      .map(|members| {
+        members["values"]
+          .as_array()
+          .cloned()
+          .unwrap_or_default()
+          .iter()
+          .filter(|m| {
+            m["user"]["nickname"]
+              .as_str()
+              .map_or(false, |n| usernames.contains(&n.to_string()))
+          })
+          .map(|m| json!({"uuid": m["user"]["uuid"]}))
+          .collect_vec()
+      })
+      .unwrap_or_else(|e| {
+        warn!("Could not find the reviewers : {e}");
+        vec![]
+      })
+    };
+    let response = call_api(
+      "POST",
+      &format!(
+        "{}/repositories/{}/{}/pullrequests",
+        self.api_url, self.workspace, self.slug
+      ),
+      &headers,
+      Some(&json!({
+        "title": title,
+        "description": body,
+        "source": {"branch": {"name": branch}},
+        "destination": {"branch": {"name": base}},
+        "reviewers": reviewers,
+        "close_source_branch": true,
+      })),
+    )?;
+    Ok(
+      response["links"]["html"]["href"]
+        .as_str()
+        .unwrap_or_default()
+        .to_string(),
+    )
+  }
+}
+
+/// Opens the pull requests with the REST API (1.0) of Bitbucket Data Center (i.e. self-hosted)
+struct BitbucketDataCenter {
+  api_url: String,
+  token: String,
+  project: String,
+  slug: String,
+}
+
+impl Scm for BitbucketDataCenter {
+  fn open_pull_request(
+    &self, branch: &str, base: &str, title: &str, body: &str, owners: &[String],
+  ) -> Result<String, String> {
+    let headers = [format!("Authorization: Bearer {}", self.token)];
+    let url = format!(
+      "{}/rest/api/1.0/projects/{}/repos/{}/pull-requests",
+      self.api_url, self.project, self.slug
+    );
+    let pull_request = |reviewers: Vec<Value>| {
+      json!({
+        "title": title,
+        "description": body,
+        "fromRef": {"id": format!("refs/heads/{branch}")},
+        "toRef": {"id": format!("refs/heads/{base}")},
+        "reviewers": reviewers,
+      })
+    };
+    let reviewers = get_usernames(owners)
+      .iter()
+      .map(|u| json!({"user": {"name": u}}))
+      .collect_vec();
+    // The pull request is rejected as a whole when one of the reviewers is unknown, so it is opened again without them
+    let response = match call_api(
+      "POST",
+      &url,
+      &headers,
+      Some(&pull_request(reviewers.clone())),
+    ) {
+      Err(e) if !reviewers.is_empty() => {
+        warn!("Could not request the reviewers : {e}");
+        call_api("POST", &url, &headers, Some(&pull_request(vec![])))?
+      }
+      response => response?,
+    };
+    Ok(
+      response["links"]["self"][0]["href"]
+        .as_str()
+        .unwrap_or_default()
+        .to_string(),
+    )
+  }
+}
+
+/// Returns the user names of the code owners, i.e. `@user` (skipping the teams and the emails)
+pub(crate) fn get_usernames(owners: &[String]) -> Vec<String> {
+  owners
+    .iter()
+    .filter_map(|o| o.strip_prefix('@'))
+    .filter(|o| !o.contains('/'))
+    .map(|o| o.to_string())
+    .collect()
+}
+
+/// Returns the path of the repository of the remote URL (without the `.git` suffix), i.e. `owner/name` for
+/// `https://github.com/owner/name.git`, `ssh://git@github.com/owner/name.git` or `git@github.com:owner/name.git`.
+/// The path includes the (nested) groups of GitLab, and the `scm/` prefix of the HTTP URLs of Bitbucket Data Center is stripped.
+pub(crate) fn get_remote_path(url: &str) -> Option<String> {
+  let path = match url.split_once("://") {
+    Some((_, rest)) => rest.split_once('/')?.1,
+    None => url.split_once(':')?.1,
+  };
+  let path = path.trim_matches('/');
+  let path = path.strip_suffix(".git").unwrap_or(path);
+  let path = path.strip_prefix("scm/").unwrap_or(path);
+  let (namespace, name) = path.rsplit_once('/')?;
+  if namespace.is_empty() || name.is_empty() {
+    return None;
+  }
+  Some(path.to_string())
+}
+
+/// Calls the endpoint of the API with `curl` (posting the JSON `body`, if any), and returns the JSON response.
+/// The headers (i.e. the token) are passed through the configuration read by `curl` from its stdin,
+/// so that they do not show in the arguments of the process.
+pub(crate) fn call_api(
+  method: &str, url: &str, headers: &[String], body: Option<&Value>,
+) -> Result<Value, String> {
+  let temp_dir =
+    TempDir::new("piranha").map_err(|e| format!("Could not create a temporary directory : {e}"))?;
+  let mut command = Command::new("curl");
+  command.args([
+    "--silent",
+    "--show-error",
+    "--config",
+    "-",
+    "--request",
+    method,
+  ]);
+  if let Some(body) = body {
+    let path_to_body = temp_dir.path().join("body.json");
+    fs::write(&path_to_body, body.to_string()).map_err(|e| e.to_string())?;
+    command
+      .args([
+        "--header",
+        "Content-Type: application/json",
+        "--data-binary",
+      ])
+      .arg(format!("@{}", path_to_body.display()));
+  }
+  let mut child = command
+    .args(["--write-out", "\n%{http_code}"])
+    .arg(url)
+    .stdin(Stdio::piped())
+    .stdout(Stdio::piped())
+    .stderr(Stdio::piped())
+    .spawn()
+    .map_err(|e| format!("Could not run curl : {e}"))?;
+  if let Some(mut stdin) = child.stdin.take() {
+    let config = headers
+      .iter()
+      .map(|h| format!("header = \"{}\"\n", h.replace('"', "\\\"")))
+      .join("");
+    stdin
+      .write_all(config.as_bytes())
+      .map_err(|e| e.to_string())?;
+  }
+  let output = child.wait_with_output().map_err(|e| e.to_string())?;
+  if !output.status.success() {
+    return Err(format!(
+      "{method} {url} failed : {}",
+      String::from_utf8_lossy(&output.stderr).trim()
+    ));
+  }
+  let stdout = String::from_utf8_lossy(&output.stdout);
+  let (content, status) = stdout.rsplit_once('\n').unwrap_or(("", &stdout));
+  let response: Value = serde_json::from_str(content).unwrap_or(Value::Null);
+  if !status.trim().starts_with('2') {
+    return Err(format!(
+      "{method} {url} failed with the status {} : {}",
+      status.trim(),
+      get_error_message(&response).unwrap_or_else(|| content.to_string())
+    ));
+  }
+  debug!("{method} {url} succeeded with the status {}", status.trim());
+  Ok(response)
+}
+
+/// Returns the message of the error response of the API (i.e. of GitHub, GitLab, Bitbucket Cloud or Bitbucket Data Center)
+pub(crate) fn get_error_message(response: &Value) -> Option<String> {
+  [
+    &response["message"],
+    &response["error"]["message"],
+    &response["errors"][0]["message"],
+    &response["error"],
+  ]
+  .iter()
+  .find(|m| !m.is_null())
+  .map(|m| {
+    m.as_str()
+      .map(|s| s.to_string())
+      .unwrap_or_else(|| m.to_string())
+  })
+}
+
+#[cfg(test)]
+#[path = "unit_tests/scm_test.rs"]
+mod scm_test;
@@ -52,6 +52,16 @@ impl SourceCodeUnit {
   pub(crate) fn get_scope_query(
     &self, scope_level: &str, start_byte: usize, end_byte: usize, rules_store: &mut RuleStore,
   ) -> TSQuery {
+    self
+      .find_scope_query(scope_level, start_byte, end_byte, rules_store)
+      .unwrap_or_else(|| panic!("Could not create scope query for {scope_level:?}"))
+  }
+
+  /// Same as `get_scope_query`, but returns `None` when the previous edit is not enclosed by the `scope_level`
+  /// (e.g. the `Function-Method` scope of a package-level declaration in Go).
+  pub(crate) fn find_scope_query(
+    &self, scope_level: &str, start_byte: usize, end_byte: usize, rules_store: &mut RuleStore,
+  ) -> Option<TSQuery> {
     let root_node = self.root_node();
     let mut changed_node = get_node_for_range(root_node, start_byte, end_byte);
     // Get the scope enclosing_nodes for `scope_level` from the `scope_config.toml`.
@@ -73,7 +83,7 @@ impl SourceCodeUnit {
         ) {
           // Generate the scope query for the specific context by substituting the
           // the tags with code snippets appropriately in the `generator` query.
-          return m.scope().instantiate(p_match.matches());
+          return Some(m.scope().instantiate(p_match.matches()));
         }
       }
       if let Some(parent) = changed_node.parent() {
@@ -82,7 +92,7 @@ impl SourceCodeUnit {
         break;
       }
     }
-    panic!("Could not create scope query for {scope_level:?}");
+    None
   }
 }
 
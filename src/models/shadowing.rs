@@ -0,0 +1,168 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use log::debug;
+use tree_sitter::Node;
+
+use super::{
+  language::SupportedLanguage, numeric::is_declaration, rule::InstantiatedRule,
+  source_code_unit::SourceCodeUnit, specialization::collect_nodes,
+};
+
+// Implements instance methods related to resolving the (lexical) scope of the identifiers
+impl SourceCodeUnit {
+  /// Checks if the `matched_node` references one of the identifiers the `rule` was instantiated with
+  /// (e.g. the flag constant `staleFlag`, or the variable `enabled`) while it is shadowed by a local declaration.
+  /// For instance, `staleFlag` in `exp.BoolValue(staleFlag)` does not denote the flag constant within
+  /// `func f(staleFlag string) { ... }`, hence the match should be discarded.
+  /// Currently, only supported for Go.
+  pub(crate) fn references_shadowed_identifier(
+    &self, matched_node: Node, rule: &InstantiatedRule,
+  ) -> bool {
+    if *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go
+      || rule.substitutions().is_empty()
+    {
+      return false;
+    }
+    let names: Vec<&String> = rule.substitutions().values().collect();
+    let shadowed = collect_nodes(matched_node, |n| {
+      n.kind() == "identifier"
+        && !is_declaration(*n)
+        && names
+          .iter()
+          .any(|name| Some(name.as_str()) == n.utf8_text(self.code().as_bytes()).ok())
+        && self.is_shadowed(*n)
+    });
+    if let Some(s) = shadowed.first() {
+      debug!(
+        "Skipping the match of {} at {:?}, since `{}` is shadowed by a local declaration",
+        rule.name(),
+        s.start_position(),
+        self.text_of(Some(*s))
+      );
+    }
+    !shadowed.is_empty()
+  }
+
+  /// Checks if the `identifier` binds to a local declaration (i.e. a variable, constant or parameter),
+  /// declared by an enclosing block or function before the `identifier`.
+  pub(crate) fn is_shadowed(&self, identifier: Node) -> bool {
+    let name = self.text_of(Some(identifier));
+    let mut child = identifier;
+    while let Some(parent) = child.parent() {
+      let mut cursor = parent.walk();
+      let declarations: Vec<Node> = match parent.kind() {
+        // The scope of a local declaration begins after its end
+        "statement_list" => parent
+          .named_children(&mut cursor)
+          .take_while(|s| s.end_byte() <= identifier.start_byte())
+          .collect(),
+        "function_declaration" | "method_declaration" | "func_literal" => {
+          ["receiver", "parameters", "result"]
+            .iter()
+            .filter_map(|f| parent.child_by_field_name(f))
+            .collect()
+        }
+        "if_statement" | "expression_switch_statement" | "type_switch_statement" => {
+          ["initializer", "alias"]
+            .iter()
+            .filter_map(|f| parent.child_by_field_name(f))
+            .filter(|d| d.id() != child.id())
+            .collect()
+        }
+        "for_statement" => parent
+          .named_children(&mut cursor)
+          .filter(|c| ["for_clause", "range_clause"].contains(&c.kind()) && c.id() != child.id())
+          .collect(),
+        _ => vec![],
+      };
+      if declarations
+        .iter()
+        .any(|d| self.get_declared_names(*d).contains(&name))
+      {
+        return true;
+      }
+      if ["function_declaration", "method_declaration"].contains(&parent.kind()) {
+        return false;
+      }
+      child = parent;
+    }
+    false
+  }
+
+  /// Returns the names declared by the `declaration` in its enclosing scope (i.e. not the names declared in its nested scopes)
+  pub(super) fn get_declared_names(&self, declaration: Node) -> Vec<String> {
+    let mut cursor = declaration.walk();
+    let names: Vec<Node> = match declaration.kind() {
+      "short_var_declaration" => declaration
+        .child_by_field_name("left")
+        .map(|l| get_named_children(l))
+        .unwrap_or_default(),
+      "range_clause" => {
+        let is_short_var_declaration = declaration.children(&mut cursor).any(|c| c.kind() == ":=");
+        if !is_short_var_declaration {
+          return vec![];
+        }
+        declaration
+          .child_by_field_name("left")
+          .map(|l| get_named_children(l))
+          .unwrap_or_default()
+      }
+      "for_clause" => {
+        return declaration
+          .child_by_field_name("initializer")
+          .map(|i| self.get_declared_names(i))
+          .unwrap_or_default()
+      }
+      "var_declaration" | "const_declaration" => collect_nodes(declaration, |n| {
+        ["var_spec", "const_spec"].contains(&n.kind())
+          && [n.parent(), n.parent().and_then(|p| p.parent())]
+            .iter()
+            .any(|p| p.map(|p| p.id()) == Some(declaration.id()))
+      })
+      .iter()
+      .flat_map(|spec| get_names(*spec))
+      .collect(),
+      "parameter_list" => get_named_children(declaration)
+        .iter()
+        .flat_map(|parameter| get_names(*parameter))
+        .collect(),
+      // e.g. `v := x.(type)` in a type switch
+      "expression_list" | "identifier" => get_named_children(declaration)
+        .into_iter()
+        .chain(Some(declaration).filter(|d| d.kind() == "identifier"))
+        .collect(),
+      _ => vec![],
+    };
+    names
+      .iter()
+      .filter(|n| n.kind() == "identifier")
+      .map(|n| self.text_of(Some(*n)))
+      .collect()
+  }
+}
+
+/// Returns the `name`s of a specification (e.g. `a, b = 1, 2`) or a parameter declaration
+fn get_names(node: Node) -> Vec<Node> {
+  let mut cursor = node.walk();
+  node.children_by_field_name("name", &mut cursor).collect()
+}
+
+fn get_named_children(node: Node) -> Vec<Node> {
+  let mut cursor = node.walk();
+  node.named_children(&mut cursor).collect()
+}
+
+#[cfg(test)]
+#[path = "unit_tests/shadowing_test.rs"]
+mod shadowing_test;
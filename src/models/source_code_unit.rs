@@ -17,12 +17,13 @@ use std::{
 
 use colored::Colorize;
 use itertools::Itertools;
-use log::{debug, error};
+use log::{debug, error, warn};
 
 use tree_sitter::{InputEdit, Node, Parser, Range, Tree};
 
 use crate::{
   models::rule_graph::{GLOBAL, PARENT},
+  utilities::count_changed_lines,
   utilities::tree_sitter_utilities::{
     get_match_for_query, get_node_for_range, get_replace_range, get_tree_sitter_edit,
     number_of_errors, TSQuery,
@@ -63,6 +64,24 @@ pub(crate) struct SourceCodeUnit {
   #[get = "pub"]
   #[get_mut = "pub"]
   matches: Vec<(String, Match)>,
+  // Matches exempted from the cleanup by the `piranha:ignore` directives
+  #[get = "pub"]
+  #[get_mut = "pub"]
+  suppressed_matches: Vec<(String, Match)>,
+  // The byte ranges of `code` modified by the edits applied so far (i.e. `(start_byte, end_byte)`).
+  // The whitespace cleanup is confined to them, so that the code left untouched is not reformatted.
+  #[get = "pub"]
+  #[get_mut = "pub"]
+  edited_ranges: Vec<(usize, usize)>,
+  // The number of rewrites applied for each of the flags cleaned up so far (i.e. when cleaning up several `flags`)
+  #[get = "pub"]
+  #[get_mut = "pub"]
+  rewrites_per_flag: Vec<(String, usize)>,
+  // The number of lines added and removed by the cleanup of each of the flags (i.e. `(flag, lines_added, lines_removed)`)
+  #[get = "pub"]
+  lines_per_flag: Vec<(String, usize, usize)>,
+  // The content of the file before the cleanup of the current flag
+  content_before_flag: String,
   // Piranha Arguments passed by the user
   #[get = "pub"]
   piranha_arguments: PiranhaArguments,
@@ -74,23 +93,93 @@ impl SourceCodeUnit {
     piranha_arguments: &PiranhaArguments,
   ) -> Self {
     let ast = parser.parse(&code, None).expect("Could not parse code");
-    let source_code_unit = Self {
+    let source_code_unit = Self::from_tree(ast, code, substitutions, path, piranha_arguments);
+    // Panic if allow dirty ast is false and the tree is syntactically incorrect
+    if !piranha_arguments.allow_dirty_ast() && source_code_unit._number_of_errors() > 0 {
+      error!("{}: {}", "Syntax Error".red(), path.to_str().unwrap().red());
+      _ = &source_code_unit._panic_for_syntax_error();
+    }
+
+    source_code_unit
+  }
+
+  /// Same as `new`, but skips the file (with a warning) instead of panicking when it is syntactically incorrect
+  /// (unless `allow_dirty_ast` is set), e.g. for the files of the code base that are only scanned for a report.
+  pub(crate) fn try_new(
+    parser: &mut Parser, code: String, substitutions: &HashMap<String, String>, path: &Path,
+    piranha_arguments: &PiranhaArguments,
+  ) -> Option<Self> {
+    let ast = parser.parse(&code, None)?;
+    if !piranha_arguments.allow_dirty_ast() && number_of_errors(&ast.root_node()) > 0 {
+      warn!(
+        "{}: skipping the file, since it has syntax errors",
+        path.display()
+      );
+      return None;
+    }
+    Some(Self::from_tree(
+      ast,
+      code,
+      substitutions,
+      path,
+      piranha_arguments,
+    ))
+  }
+
+  fn from_tree(
+    ast: Tree, code: String, substitutions: &HashMap<String, String>, path: &Path,
+    piranha_arguments: &PiranhaArguments,
+  ) -> Self {
+    Self {
       ast,
       original_content: code.to_string(),
+      content_before_flag: code.to_string(),
       code,
       substitutions: substitutions.clone(),
       path: path.to_path_buf(),
       rewrites: Vec::new(),
       matches: Vec::new(),
+      suppressed_matches: Vec::new(),
+      edited_ranges: Vec::new(),
+      rewrites_per_flag: Vec::new(),
+      lines_per_flag: Vec::new(),
       piranha_arguments: piranha_arguments.clone(),
-    };
-    // Panic if allow dirty ast is false and the tree is syntactically incorrect
-    if !piranha_arguments.allow_dirty_ast() && source_code_unit._number_of_errors() > 0 {
-      error!("{}: {}", "Syntax Error".red(), path.to_str().unwrap().red());
-      _ = &source_code_unit._panic_for_syntax_error();
     }
+  }
 
-    source_code_unit
+  /// Prepares this file for the cleanup of the next flag (i.e. its `piranha_arguments`), on top of the rewrites of the previous ones
+  pub(crate) fn set_flag_arguments(&mut self, piranha_arguments: &PiranhaArguments) {
+    self.substitutions = piranha_arguments.input_substitutions();
+    self.piranha_arguments = piranha_arguments.clone();
+  }
+
+  /// Discards the rewrites of this file, i.e. restores its original content (and re-parses it, so that the AST matches it)
+  pub(crate) fn discard_rewrites(&mut self) {
+    let mut parser = self.piranha_arguments.language().parser();
+    let original_content = self.original_content.to_string();
+    self._replace_file_contents_and_re_parse(&original_content, &mut parser, false);
+    self.rewrites.clear();
+    self.rewrites_per_flag.clear();
+    self.lines_per_flag.clear();
+    self.content_before_flag = self.original_content.to_string();
+    self.edited_ranges.clear();
+  }
+
+  /// Records the rewrites applied since the previous flag as the rewrites of `flag_name`,
+  /// along with the number of lines they added and removed
+  pub(crate) fn record_rewrites_for_flag(&mut self, flag_name: &str) {
+    let recorded: usize = self.rewrites_per_flag.iter().map(|(_, n)| n).sum();
+    let number_of_rewrites = self.rewrites.len().saturating_sub(recorded);
+    if number_of_rewrites > 0 {
+      self
+        .rewrites_per_flag
+        .push((flag_name.to_string(), number_of_rewrites));
+      let (lines_added, lines_removed) = count_changed_lines(&self.content_before_flag, &self.code);
+      self
+        .lines_per_flag
+        .push((flag_name.to_string(), lines_added, lines_removed));
+      self.content_before_flag = self.code.to_string();
+    }
   }
 
   pub(crate) fn root_node(&self) -> Node<'_> {
@@ -102,6 +191,14 @@ impl SourceCodeUnit {
     &mut self, rule: InstantiatedRule, rules_store: &mut RuleStore, parser: &mut Parser,
     scope_query: &Option<TSQuery>,
   ) {
+    // The rules found in the other package of the directory (i.e. `foo` or `foo_test`) do not resolve in this file
+    if self.is_restricted_to_another_package(&rule) {
+      debug!(
+        "Skipping the rule {}, since it is restricted to another package",
+        rule.name()
+      );
+      return;
+    }
     loop {
       if !self._apply_rule(rule.clone(), rules_store, parser, scope_query) {
         break;
@@ -142,6 +239,11 @@ impl SourceCodeUnit {
     // Propagate each applied edit. The next rule will be applied relative to the application of this edit.
     if !rule.rule().is_match_only_rule() {
       if let Some(edit) = self.get_edit(&rule, rule_store, scope_node, true) {
+        // The edits below the confidence threshold are neither applied nor propagated
+        if self.is_low_confidence(&rule.name()) {
+          self.annotate_for_verification(&edit, parser);
+          return true;
+        }
         self.rewrites_mut().push(edit.clone());
         query_again = true;
 
@@ -231,7 +333,7 @@ impl SourceCodeUnit {
 
       // Add Global rules as seed rules
       for r in &next_rules_by_scope[GLOBAL] {
-        rules_store.add_to_global_rules(r);
+        rules_store.add_to_global_rules(&self.restrict_to_package(r));
       }
 
       // Process the parent
@@ -242,6 +344,10 @@ impl SourceCodeUnit {
         rules_store,
         &next_rules_by_scope[PARENT],
       ) {
+        if self.is_low_confidence(edit.matched_rule()) {
+          self.annotate_for_verification(&edit, parser);
+          break;
+        }
         self.rewrites_mut().push(edit.clone());
         debug!(
           "\n{}",
@@ -278,15 +384,22 @@ impl SourceCodeUnit {
     for (scope_level, rules) in next_rules_by_scope {
       // Scope level is not "PArent" or "Global"
       if ![PARENT, GLOBAL].contains(&scope_level.as_str()) {
+        // Skip the rules when the change is not enclosed by the scope (e.g. a package-level change in Go)
+        let scope_query = match self.find_scope_query(
+          scope_level,
+          current_match_range.start_byte,
+          current_match_range.end_byte,
+          rules_store,
+        ) {
+          Some(scope_query) => scope_query,
+          None => {
+            debug!("The change is not enclosed by the scope {scope_level}");
+            continue;
+          }
+        };
         for rule in rules {
-          let scope_query = self.get_scope_query(
-            scope_level,
-            current_match_range.start_byte,
-            current_match_range.end_byte,
-            rules_store,
-          );
           // Add Method and Class scoped rules to the queue
-          stack.push_front((scope_query, rule.clone()));
+          stack.push_front((scope_query.clone(), rule.clone()));
         }
       }
     }
@@ -322,6 +435,7 @@ impl SourceCodeUnit {
     for rule in rules {
       self.apply_rule(rule.to_owned(), rules_store, parser, &scope_query)
     }
+    self.perform_remove_unused_imports(parser);
     self.perform_delete_consecutive_new_lines();
   }
 
@@ -342,6 +456,7 @@ impl SourceCodeUnit {
     let number_of_errors = self._number_of_errors();
     self.ast.edit(&ts_edit);
     self._replace_file_contents_and_re_parse(&new_source_code, parser, true);
+    self.track_edited_range(&ts_edit);
 
     // Panic if the number of errors increased after the edit
     if self._number_of_errors() > number_of_errors {
@@ -350,6 +465,44 @@ impl SourceCodeUnit {
     ts_edit
   }
 
+  /// Records the range modified by the edit, merging it with the overlapping (or adjacent) ranges
+  /// recorded so far and shifting the ones following it.
+  fn track_edited_range(&mut self, ts_edit: &InputEdit) {
+    let (start_byte, old_end_byte, new_end_byte) = (
+      ts_edit.start_byte,
+      ts_edit.old_end_byte,
+      ts_edit.new_end_byte,
+    );
+    let mut edited_range = (start_byte, new_end_byte);
+    let mut edited_ranges = vec![];
+    for (start, end) in self.edited_ranges.drain(..) {
+      if end < start_byte {
+        edited_ranges.push((start, end));
+      } else if start > old_end_byte {
+        edited_ranges.push((
+          start - old_end_byte + new_end_byte,
+          end - old_end_byte + new_end_byte,
+        ));
+      } else {
+        edited_range.0 = edited_range.0.min(start);
+        if end > old_end_byte {
+          edited_range.1 = edited_range.1.max(end - old_end_byte + new_end_byte);
+        }
+      }
+    }
+    edited_ranges.push(edited_range);
+    edited_ranges.sort();
+    self.edited_ranges = edited_ranges;
+  }
+
+  /// Checks if the code between `start_byte` and `end_byte` overlaps (or is adjacent to) a range modified by an edit
+  pub(crate) fn is_edited(&self, start_byte: usize, end_byte: usize) -> bool {
+    self
+      .edited_ranges
+      .iter()
+      .any(|(start, end)| *start <= end_byte && start_byte <= *end)
+  }
+
   fn _panic_for_syntax_error(&self) {
     let msg = format!(
       "Produced syntactically incorrect source code {}",
@@ -0,0 +1,357 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashMap;
+
+use log::debug;
+use tree_sitter::{Node, Parser, Point, Range};
+
+use super::{
+  edit::Edit, matches::Match, rule::InstantiatedRule, rule_store::RuleStore,
+  source_code_unit::SourceCodeUnit,
+};
+
+/// The name of the (pseudo) rule reported for the parameters and arguments deleted by the specialization
+pub(crate) static SPECIALIZE_BOOLEAN_PARAMETER: &str = "specialize_boolean_parameter";
+/// The built-in rule used to propagate the boolean literal into the body of the specialized function
+static REPLACE_IDENTIFIER_WITH_VALUE: &str = "replace_identifier_with_value";
+/// The scope (see `scope_config.toml`) within which the boolean literal is propagated
+static FUNCTION_SCOPE: &str = "Function-Method";
+
+/// A `bool` parameter of an unexported function, i.e. a candidate for specialization.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub(crate) struct BooleanParameter {
+  // The name of the function declaring the parameter
+  pub(crate) function_name: String,
+  // The name of the parameter
+  pub(crate) parameter_name: String,
+  // The position of the corresponding argument at the call sites
+  pub(crate) position: usize,
+  // The number of arguments expected at the call sites
+  pub(crate) arity: usize,
+  // The index of the parameter declaration within the parameter list
+  declaration_index: usize,
+}
+
+// Implements instance methods related to specializing functions for the boolean literal passed by all their callers
+impl SourceCodeUnit {
+  /// Returns the `bool` parameters of the (top-level, unexported) functions declared in this file,
+  /// that are declared on their own (i.e. `enabled bool`, not `a, b bool`) and never reassigned in the body.
+  /// Functions with variadic parameters, `main` and `init` are skipped.
+  pub(crate) fn get_boolean_parameters(&self) -> Vec<BooleanParameter> {
+    let mut boolean_parameters = vec![];
+    for declaration in self.get_function_declarations() {
+      let function_name = self.text_of(declaration.child_by_field_name("name"));
+      if !function_name.starts_with(|c: char| c.is_lowercase() || c == '_')
+        || ["main", "init"].contains(&function_name.as_str())
+      {
+        continue;
+      }
+      let (parameter_list, body) = match (
+        declaration.child_by_field_name("parameters"),
+        declaration.child_by_field_name("body"),
+      ) {
+        (Some(p), Some(b)) => (p, b),
+        _ => continue,
+      };
+      let parameter_declarations = get_list_elements(parameter_list);
+      if parameter_declarations
+        .iter()
+        .any(|p| p.kind() != "parameter_declaration")
+      {
+        continue;
+      }
+      let mut candidates = vec![];
+      let mut position = 0;
+      for (declaration_index, parameter) in parameter_declarations.iter().enumerate() {
+        let mut cursor = parameter.walk();
+        let names: Vec<Node> = parameter
+          .children_by_field_name("name", &mut cursor)
+          .collect();
+        if names.len() == 1 && self.text_of(parameter.child_by_field_name("type")) == "bool" {
+          candidates.push((
+            self.text_of(names.first().copied()),
+            position,
+            declaration_index,
+          ));
+        }
+        position += names.len().max(1);
+      }
+      let arity = position;
+      for (parameter_name, position, declaration_index) in candidates {
+        if !self.is_reassigned(body, &parameter_name) {
+          boolean_parameters.push(BooleanParameter {
+            function_name: function_name.clone(),
+            parameter_name,
+            position,
+            arity,
+            declaration_index,
+          });
+        }
+      }
+    }
+    boolean_parameters
+  }
+
+  /// Returns the arguments passed by each call to `function_name` in this file.
+  pub(crate) fn get_call_arguments(&self, function_name: &str) -> Vec<Vec<String>> {
+    self
+      .get_argument_lists(function_name)
+      .iter()
+      .map(|arguments| {
+        get_list_elements(*arguments)
+          .iter()
+          .map(|a| self.text_of(Some(*a)))
+          .collect()
+      })
+      .collect()
+  }
+
+  /// Returns the number of identifiers named `name` in this file (i.e. declarations, calls and other references).
+  pub(crate) fn count_references(&self, name: &str) -> usize {
+    collect_nodes(self.root_node(), |n| {
+      n.kind() == "identifier" && self.text_of(Some(*n)) == name
+    })
+    .len()
+  }
+
+  /// Deletes the parameter from the signature of the function declaring it.
+  /// Returns false if the declaration of the function is not found in this file.
+  pub(crate) fn delete_parameter(
+    &mut self, parameter: &BooleanParameter, parser: &mut Parser,
+  ) -> bool {
+    let range = self
+      .get_function_declarations()
+      .into_iter()
+      .find(|d| self.text_of(d.child_by_field_name("name")) == parameter.function_name)
+      .and_then(|d| d.child_by_field_name("parameters"))
+      .and_then(|p| get_element_deletion_range(p, parameter.declaration_index));
+    match range {
+      Some(r) => {
+        self.delete_range(r, parser);
+        true
+      }
+      None => false,
+    }
+  }
+
+  /// Deletes the argument corresponding to the parameter from the first call to the function that still passes it.
+  /// Returns false if there is no such call in this file.
+  pub(crate) fn delete_argument(
+    &mut self, parameter: &BooleanParameter, parser: &mut Parser,
+  ) -> bool {
+    let range = self
+      .get_argument_lists(&parameter.function_name)
+      .into_iter()
+      .find(|a| get_list_elements(*a).len() == parameter.arity)
+      .and_then(|a| get_element_deletion_range(a, parameter.position));
+    match range {
+      Some(r) => {
+        self.delete_range(r, parser);
+        true
+      }
+      None => false,
+    }
+  }
+
+  /// Replaces the references to the parameter inside the body of the function with `value`,
+  /// and triggers the cleanup of the body with the built-in rules (e.g. `if true { ... }`).
+  pub(crate) fn propagate_value_into_body(
+    &mut self, parameter: &BooleanParameter, value: &str, rules_store: &mut RuleStore,
+    parser: &mut Parser,
+  ) {
+    let declaration_range = self
+      .get_function_declarations()
+      .into_iter()
+      .find(|d| self.text_of(d.child_by_field_name("name")) == parameter.function_name)
+      .map(|d| d.range());
+    let substitutions = HashMap::from([
+      (
+        "variable_name".to_string(),
+        parameter.parameter_name.to_string(),
+      ),
+      ("value".to_string(), value.to_string()),
+    ]);
+    let rule = self
+      .piranha_arguments()
+      .rule_graph()
+      .get_rule_named(&REPLACE_IDENTIFIER_WITH_VALUE.to_string())
+      .map(|r| InstantiatedRule::new(r, &substitutions));
+    if let (Some(range), Some(rule)) = (declaration_range, rule) {
+      let scope_query = self.get_scope_query(
+        FUNCTION_SCOPE,
+        range.start_byte,
+        range.end_byte,
+        rules_store,
+      );
+      self.apply_rules(rules_store, &[rule], parser, Some(scope_query));
+    }
+  }
+
+  /// Returns the top-level function declarations (i.e. not methods) of this file
+  pub(super) fn get_function_declarations(&self) -> Vec<Node> {
+    let root_node = self.root_node();
+    let mut cursor = root_node.walk();
+    root_node
+      .named_children(&mut cursor)
+      .filter(|n| n.kind() == "function_declaration")
+      .collect()
+  }
+
+  /// Returns the argument lists of the calls to `function_name` (i.e. `function_name(...)`) in this file
+  fn get_argument_lists(&self, function_name: &str) -> Vec<Node> {
+    collect_nodes(self.root_node(), |n| {
+      n.kind() == "call_expression"
+        && n
+          .child_by_field_name("function")
+          .filter(|f| f.kind() == "identifier")
+          .map(|f| self.text_of(Some(f)) == function_name)
+          .unwrap_or(false)
+    })
+    .iter()
+    .filter_map(|c| c.child_by_field_name("arguments"))
+    .filter(|a| {
+      !get_list_elements(*a)
+        .iter()
+        .any(|e| e.kind() == "variadic_argument")
+    })
+    .collect()
+  }
+
+  /// Checks if the variable `name` is reassigned (or shadowed, or its address is taken) within `body`.
+  fn is_reassigned(&self, body: Node, name: &str) -> bool {
+    !collect_nodes(body, |n| {
+      n.kind() == "identifier"
+        && self.text_of(Some(*n)) == name
+        && n
+          .parent()
+          .map(|p| match p.kind() {
+            "expression_list" => p
+              .parent()
+              .filter(|pp| {
+                [
+                  "assignment_statement",
+                  "short_var_declaration",
+                  "range_clause",
+                ]
+                .contains(&pp.kind())
+              })
+              .and_then(|pp| pp.child_by_field_name("left"))
+              .map(|l| l.id() == p.id())
+              .unwrap_or(false),
+            "inc_statement"
+            | "dec_statement"
+            | "var_spec"
+            | "const_spec"
+            | "parameter_declaration" => true,
+            "unary_expression" => self.text_of(p.child_by_field_name("operator")) == "&",
+            _ => false,
+          })
+          .unwrap_or(false)
+    })
+    .is_empty()
+  }
+
+  /// Deletes the code in `range` and records it as a rewrite
+  fn delete_range(&mut self, range: Range, parser: &mut Parser) {
+    let edit = Edit::new(
+      Match::new(
+        self.code()[range.start_byte..range.end_byte].to_string(),
+        range,
+        HashMap::new(),
+      ),
+      String::new(),
+      SPECIALIZE_BOOLEAN_PARAMETER.to_string(),
+      self.code(),
+    );
+    debug!(
+      "Deleting the specialized parameter {}",
+      edit.p_match().matched_string()
+    );
+    self.rewrites_mut().push(edit.clone());
+    self.apply_edit(&edit, parser);
+  }
+
+  pub(super) fn text_of(&self, node: Option<Node>) -> String {
+    node
+      .and_then(|n| n.utf8_text(self.code().as_bytes()).ok())
+      .unwrap_or_default()
+      .to_string()
+  }
+}
+
+/// Returns the elements (i.e. parameters or arguments) of a parameter or argument list, skipping the comments
+pub(super) fn get_list_elements(list: Node) -> Vec<Node> {
+  let mut cursor = list.walk();
+  list
+    .named_children(&mut cursor)
+    .filter(|n| n.kind() != "comment")
+    .collect()
+}
+
+/// Returns the range to delete in order to remove the `index`-th element of the list, along with its separating comma.
+/// The only element of a list is deleted up to the closing parenthesis (i.e. including a trailing comma).
+pub(super) fn get_element_deletion_range(list: Node, index: usize) -> Option<Range> {
+  let elements = get_list_elements(list);
+  let element = elements.get(index)?;
+  let (start_byte, end_byte, start_point, end_point) = if let Some(next) = elements.get(index + 1) {
+    (
+      element.start_byte(),
+      next.start_byte(),
+      element.start_position(),
+      next.start_position(),
+    )
+  } else if index > 0 {
+    let previous = elements[index - 1];
+    (
+      previous.end_byte(),
+      element.end_byte(),
+      previous.end_position(),
+      element.end_position(),
+    )
+  } else {
+    let closing = list.end_position();
+    (
+      element.start_byte(),
+      list.end_byte() - 1,
+      element.start_position(),
+      Point::new(closing.row, closing.column - 1),
+    )
+  };
+  Some(Range {
+    start_byte,
+    end_byte,
+    start_point,
+    end_point,
+  })
+}
+
+/// Collects the nodes of the tree rooted at `node` satisfying the `predicate`
+pub(super) fn collect_nodes<'a>(
+  node: Node<'a>, predicate: impl Fn(&Node<'a>) -> bool,
+) -> Vec<Node<'a>> {
+  let mut nodes = vec![];
+  let mut stack = vec![node];
+  while let Some(current) = stack.pop() {
+    if predicate(&current) {
+      nodes.push(current);
+    }
+    let mut cursor = current.walk();
+    stack.extend(current.children(&mut cursor));
+  }
+  nodes
+}
+
+#[cfg(test)]
+#[path = "unit_tests/specialization_test.rs"]
+mod specialization_test;
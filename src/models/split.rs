@@ -0,0 +1,284 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::{BTreeMap, HashSet},
+  fs,
+  path::{Component, Path},
+};
+
+use getset::Getters;
+use itertools::Itertools;
+use log::debug;
+use regex::Regex;
+
+use super::{
+  changed_files::{get_directory, get_top_level},
+  code_owners::CodeOwners,
+  piranha_arguments::PiranhaArguments,
+  piranha_output::PiranhaOutputSummary,
+};
+
+/// The ways of splitting the rewritten files into chunks (i.e. `split_by`)
+pub(crate) static SPLIT_BY: [&str; 3] = ["package", "directory", "owner"];
+/// The name of the chunk of the files without code owners (i.e. `owner`)
+static UNOWNED: &str = "unowned";
+
+/// A chunk of the rewritten files, i.e. a patch (or a pull request) reviewable on its own
+#[derive(Debug, Clone, Getters)]
+pub(crate) struct Chunk<'a> {
+  // The name of the chunk, i.e. its package, directory or code owners (joined with `+` for the merged chunks)
+  #[get = "pub(crate)"]
+  name: String,
+  // The summaries of the rewritten files of the chunk, sorted by path
+  #[get = "pub(crate)"]
+  summaries: Vec<&'a PiranhaOutputSummary>,
+}
+
+impl Chunk<'_> {
+  /// Returns the name of the chunk usable in a file (or branch) name, i.e. with the other characters replaced by `-`
+  pub(crate) fn slug(&self) -> String {
+    self
+      .name
+      .chars()
+      .map(|c| {
+        if c.is_ascii_alphanumeric() || c == '_' || c == '.' {
+          c
+        } else {
+          '-'
+        }
+      })
+      .collect::<String>()
+      .trim_matches(|c| c == '-' || c == '.')
+      .to_string()
+  }
+}
+
+/// Splits the rewritten files into chunks by the `split_by` of the arguments, i.e. the directory of each file (its `package`), its top-level directory
+/// under the code base (`directory`), or its code owners (`owner`, relative to the root of the repository `root`).
+/// All the rewritten files are in a single (unnamed) chunk when `split_by` is empty.
+/// Since each chunk is reviewed (and merged) on its own, the chunks sharing a declaration deleted by the cleanup are merged,
+/// i.e. the chunk deleting a declaration also holds the files whose usages of it are deleted (see `get_deleted_names`).
+pub(crate) fn split_summaries<'a>(
+  summaries: &'a [PiranhaOutputSummary], piranha_arguments: &PiranhaArguments, root: &Path,
+  code_owners: &CodeOwners,
+) -> Vec<Chunk<'a>> {
+  let split_by = piranha_arguments.split_by().as_str();
+  let path_to_codebase = Path::new(piranha_arguments.path_to_codebase());
+  let rewritten = summaries
+    .iter()
+    .filter(|s| s.content() != s.original_content())
+    .sorted_by(|a, b| a.path().cmp(b.path()))
+    .collect_vec();
+  if rewritten.is_empty() {
+    return vec![];
+  }
+  if split_by.is_empty() {
+    return vec![Chunk {
+      name: String::new(),
+      summaries: rewritten,
+    }];
+  }
+
+  let mut groups: BTreeMap<String, Vec<&PiranhaOutputSummary>> = BTreeMap::new();
+  for summary in rewritten {
+    let key = get_chunk_key(
+      summary.path(),
+      split_by,
+      path_to_codebase,
+      root,
+      code_owners,
+    );
+    groups.entry(key).or_default().push(summary);
+  }
+  let names = groups.keys().cloned().collect_vec();
+  let mut parents = (0..names.len()).collect_vec();
+
+  // The keywords of the language (e.g. `const` or `true`) are not names
+  let keywords = get_keywords(piranha_arguments.language().language());
+  // The names no longer used by each (group of) files, i.e. whose usages are deleted
+  let removed_names = groups
+    .values()
+    .map(|g| {
+      g.iter()
+        .flat_map(|s| {
+          get_words(s.original_content())
+            .difference(&get_words(s.content()))
+            .cloned()
+            .collect_vec()
+        })
+        .filter(|w| !keywords.contains(w))
+        .collect::<HashSet<String>>()
+    })
+    .collect_vec();
+  for (index, group) in groups.values().enumerate() {
+    let deleted_names: HashSet<String> = group.iter().flat_map(|s| get_deleted_names(s)).collect();
+    for (other, removed) in removed_names.iter().enumerate() {
+      if other != index && !deleted_names.is_disjoint(removed) {
+        let (a, b) = (find(&mut parents, index), find(&mut parents, other));
+        parents[a.max(b)] = a.min(b);
+      }
+    }
+  }
+
+  let mut merged: BTreeMap<usize, (Vec<String>, Vec<&PiranhaOutputSummary>)> = BTreeMap::new();
+  for (index, (name, group)) in groups.into_iter().enumerate() {
+    let entry = merged.entry(find(&mut parents, index)).or_default();
+    entry.0.push(name);
+    entry.1.extend(group);
+  }
+  let chunks = merged
+    .into_values()
+    .map(|(names, summaries)| Chunk {
+      name: names.join("+"),
+      summaries: summaries
+        .into_iter()
+        .sorted_by(|a, b| a.path().cmp(b.path()))
+        .collect(),
+    })
+    .collect_vec();
+  debug!(
+    "Split the {} groups of rewritten files (by {split_by}) into {} chunks",
+    names.len(),
+    chunks.len()
+  );
+  chunks
+}
+
+/// Returns the key of the chunk of the file at `path` (see `split_summaries`)
+fn get_chunk_key(
+  path: &str, split_by: &str, path_to_codebase: &Path, root: &Path, code_owners: &CodeOwners,
+) -> String {
+  let relative_path = get_relative_path(path_to_codebase, path).unwrap_or_else(|| path.to_string());
+  let relative_path = Path::new(&relative_path);
+  match split_by {
+    "package" => relative_path
+      .parent()
+      .filter(|p| !p.as_os_str().is_empty())
+      .map(|p| p.to_string_lossy().to_string())
+      .unwrap_or_else(|| ".".to_string()),
+    "directory" => match relative_path.components().collect_vec().as_slice() {
+      [Component::Normal(directory), _, ..] => directory.to_string_lossy().to_string(),
+      _ => ".".to_string(),
+    },
+    _ => {
+      let owners = get_relative_path(root, path)
+        .map(|p| code_owners.get_owners(&p))
+        .unwrap_or_default();
+      if owners.is_empty() {
+        UNOWNED.to_string()
+      } else {
+        owners.join(" ")
+      }
+    }
+  }
+}
+
+/// Returns the names (i.e. identifiers) that no longer occur in the file after the code deleted by the rewrites (i.e. with an empty
+/// replacement), e.g. the name of a deleted constant or function declaration. The chunks of the files still using them must be
+/// merged with the file, since the declaration cannot be deleted before them.
+fn get_deleted_names(summary: &PiranhaOutputSummary) -> HashSet<String> {
+  let remaining_names = get_words(summary.content());
+  summary
+    .rewrites()
+    .iter()
+    .filter(|e| e.replacement_string().trim().is_empty())
+    .flat_map(|e| get_words(e.p_match().matched_string()))
+    .filter(|w| !remaining_names.contains(w))
+    .collect()
+}
+
+/// Returns the node kinds of the grammar of the language, i.e. including its keywords and literals (e.g. `func`, `true` or `nil`)
+fn get_keywords(language: &tree_sitter::Language) -> HashSet<String> {
+  (0..language.node_kind_count())
+    .filter_map(|id| language.node_kind_for_id(id as u16))
+    .map(|k| k.to_string())
+    .collect()
+}
+
+/// Returns the identifier-like words of the code
+fn get_words(code: &str) -> HashSet<String> {
+  let identifier = Regex::new(r"[A-Za-z_][A-Za-z0-9_]*").unwrap();
+  identifier
+    .find_iter(code)
+    .map(|m| m.as_str().to_string())
+    .collect()
+}
+
+/// Returns the representative of the set of `index` (i.e. union-find), compressing the path to it
+fn find(parents: &mut [usize], index: usize) -> usize {
+  let mut root = index;
+  while parents[root] != root {
+    root = parents[root];
+  }
+  let mut current = index;
+  while parents[current] != root {
+    let next = parents[current];
+    parents[current] = root;
+    current = next;
+  }
+  root
+}
+
+/// Writes the unified diff of each chunk of the rewritten files (see `split_by`) to its own patch file under `path_to_patches`,
+/// i.e. `001-<chunk>.patch`, `002-<chunk>.patch`, ... (applicable with `git apply` in any order), and returns their paths.
+pub fn write_patches(
+  summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments,
+) -> Result<Vec<String>, String> {
+  let path_to_patches = piranha_arguments
+    .path_to_patches()
+    .clone()
+    .unwrap_or_default();
+  // The code owners are looked up from the root of the repository, if the code base is in one
+  let root = get_top_level(get_directory(piranha_arguments.path_to_codebase()))
+    .unwrap_or_else(|_| Path::new(piranha_arguments.path_to_codebase()).to_path_buf());
+  let chunks = split_summaries(
+    summaries,
+    piranha_arguments,
+    &root,
+    &CodeOwners::read(&root),
+  );
+  fs::create_dir_all(&path_to_patches)
+    .map_err(|e| format!("Could not create the directory {path_to_patches} : {e}"))?;
+  let mut paths = vec![];
+  for (index, chunk) in chunks.iter().enumerate() {
+    let path = Path::new(&path_to_patches)
+      .join(format!("{:03}-{}.patch", index + 1, chunk.slug()))
+      .to_string_lossy()
+      .to_string();
+    let patch = chunk.summaries().iter().map(|s| s.unified_diff()).join("");
+    fs::write(&path, patch).map_err(|e| format!("Could not write the patch {path} : {e}"))?;
+    debug!("Wrote the patch of the chunk {} to {path}", chunk.name());
+    paths.push(path);
+  }
+  Ok(paths)
+}
+
+/// Returns the path of the file relative to the directory `root`, resolving the directory of the file rather than the file itself,
+/// since it may have been deleted by the cleanup.
+pub(crate) fn get_relative_path(root: &Path, path: &str) -> Option<String> {
+  let path = Path::new(path);
+  let directory = path
+    .parent()
+    .filter(|p| !p.as_os_str().is_empty())
+    .unwrap_or_else(|| Path::new("."))
+    .canonicalize()
+    .ok()?;
+  let root = root.canonicalize().ok()?;
+  let relative = directory.strip_prefix(root).ok()?.join(path.file_name()?);
+  Some(relative.to_string_lossy().to_string())
+}
+
+#[cfg(test)]
+#[path = "unit_tests/split_test.rs"]
+mod split_test;
@@ -0,0 +1,284 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashSet, fmt, time::Duration};
+
+use getset::Getters;
+use serde_derive::Serialize;
+
+use super::{
+  edit::Edit, piranha_arguments::PiranhaArguments, piranha_output::PiranhaOutputSummary,
+  report::get_flags_of_rewrites,
+};
+use crate::utilities::count_changed_lines;
+
+/// The groups of the built-in rules deleting a branch (i.e. simplifying an `if` or a `switch` on a boolean literal)
+static BRANCH_CLEANUP_GROUPS: [&str; 2] = ["if_cleanup", "switch_cleanup"];
+/// The group of the built-in rules inlining the value of a helper (i.e. a function returning a boolean literal) at its call sites
+static HELPER_INLINING_GROUP: &str = "replace_call_with_boolean_literal";
+
+/// The summary statistics of a Piranha run (i.e. printed at the end of the run, and included in the change report)
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Getters)]
+pub struct RunStatistics {
+  // The number of files walked over (i.e. with the language appropriate file extension)
+  #[get = "pub"]
+  files_scanned: usize,
+  // The number of files whose content is changed
+  #[get = "pub"]
+  files_modified: usize,
+  // The number of rewrites
+  #[get = "pub"]
+  rewrites: usize,
+  // The number of lines added and removed (i.e. the ones of the unified diff)
+  #[get = "pub"]
+  lines_added: usize,
+  #[get = "pub"]
+  lines_removed: usize,
+  // The number of branches deleted (i.e. the simplified `if` and `switch` statements)
+  #[get = "pub"]
+  branches_deleted: usize,
+  // The number of constant declarations deleted
+  #[get = "pub"]
+  constants_deleted: usize,
+  // The number of calls to helpers (i.e. functions returning a boolean literal) replaced with their value
+  #[get = "pub"]
+  helpers_inlined: usize,
+  // The wall-clock time of the run
+  #[get = "pub"]
+  elapsed_seconds: f64,
+  // The breakdown of the statistics per flag (i.e. its `stale_flag_name`)
+  #[get = "pub"]
+  flags: Vec<FlagStatistics>,
+}
+
+/// The statistics of the cleanup of a single flag
+#[derive(Serialize, Debug, Clone, Default, PartialEq, Eq, Getters)]
+pub struct FlagStatistics {
+  #[get = "pub"]
+  flag: String,
+  #[get = "pub"]
+  files_modified: usize,
+  #[get = "pub"]
+  rewrites: usize,
+  #[get = "pub"]
+  lines_added: usize,
+  #[get = "pub"]
+  lines_removed: usize,
+  #[get = "pub"]
+  branches_deleted: usize,
+  #[get = "pub"]
+  constants_deleted: usize,
+  #[get = "pub"]
+  helpers_inlined: usize,
+}
+
+/// The kind of a rewrite, as counted by the statistics
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum RewriteKind {
+  BranchDeletion,
+  ConstantDeletion,
+  HelperInlining,
+  Other,
+}
+
+impl RunStatistics {
+  /// Computes the statistics of the output summaries of a run with the given `piranha_arguments`,
+  /// that walked over `files_scanned` files in `elapsed` time.
+  pub fn new(
+    summaries: &[PiranhaOutputSummary], piranha_arguments: &PiranhaArguments, files_scanned: usize,
+    elapsed: Duration,
+  ) -> RunStatistics {
+    let mut statistics = RunStatistics {
+      files_scanned,
+      elapsed_seconds: elapsed.as_secs_f64(),
+      ..Default::default()
+    };
+    for summary in summaries {
+      let (lines_added, lines_removed) =
+        count_changed_lines(summary.original_content(), summary.content());
+      if lines_added + lines_removed > 0 {
+        statistics.files_modified += 1;
+      }
+      statistics.lines_added += lines_added;
+      statistics.lines_removed += lines_removed;
+
+      let mut flags_of_file = HashSet::new();
+      for (edit, flag) in summary
+        .rewrites()
+        .iter()
+        .zip(get_flags_of_rewrites(summary, piranha_arguments))
+      {
+        let kind = get_rewrite_kind(edit, piranha_arguments);
+        statistics.rewrites += 1;
+        statistics.count(kind);
+        if let Some(flag) = flag {
+          let flag_statistics = statistics.get_flag_statistics(&flag);
+          flag_statistics.rewrites += 1;
+          flag_statistics.count(kind);
+          if flags_of_file.insert(flag) {
+            flag_statistics.files_modified += 1;
+          }
+        }
+      }
+      // The lines changed by each flag are only recorded when cleaning up several flags in a single run
+      if summary.lines_per_flag().is_empty() {
+        for flag in &flags_of_file {
+          let flag_statistics = statistics.get_flag_statistics(flag);
+          flag_statistics.lines_added += lines_added;
+          flag_statistics.lines_removed += lines_removed;
+        }
+      }
+      for (flag, lines_added, lines_removed) in summary.lines_per_flag() {
+        let flag_statistics = statistics.get_flag_statistics(flag);
+        flag_statistics.lines_added += lines_added;
+        flag_statistics.lines_removed += lines_removed;
+      }
+    }
+    statistics
+  }
+
+  fn count(&mut self, kind: RewriteKind) {
+    match kind {
+      RewriteKind::BranchDeletion => self.branches_deleted += 1,
+      RewriteKind::ConstantDeletion => self.constants_deleted += 1,
+      RewriteKind::HelperInlining => self.helpers_inlined += 1,
+      RewriteKind::Other => {}
+    }
+  }
+
+  /// Returns the statistics of the `flag`, lazily adding them (in the order the flags are encountered)
+  fn get_flag_statistics(&mut self, flag: &str) -> &mut FlagStatistics {
+    let index = match self.flags.iter().position(|f| f.flag == flag) {
+      Some(index) => index,
+      None => {
+        self.flags.push(FlagStatistics {
+          flag: flag.to_string(),
+          ..Default::default()
+        });
+        self.flags.len() - 1
+      }
+    };
+    &mut self.flags[index]
+  }
+}
+
+impl FlagStatistics {
+  fn count(&mut self, kind: RewriteKind) {
+    match kind {
+      RewriteKind::BranchDeletion => self.branches_deleted += 1,
+      RewriteKind::ConstantDeletion => self.constants_deleted += 1,
+      RewriteKind::HelperInlining => self.helpers_inlined += 1,
+      RewriteKind::Other => {}
+    }
+  }
+}
+
+/// Classifies the rewrite by the groups of the rule that applied it (in the rule graph of any of the flags).
+/// A constant deletion is a rewrite deleting a `const` declaration (or one of its specs).
+fn get_rewrite_kind(edit: &Edit, piranha_arguments: &PiranhaArguments) -> RewriteKind {
+  let groups: HashSet<String> = std::iter::once(piranha_arguments)
+    .chain(piranha_arguments.flag_arguments().iter())
+    .filter_map(|a| a.rule_graph().get_rule_named(edit.matched_rule()))
+    .flat_map(|r| r.groups().iter().cloned())
+    .collect();
+  if BRANCH_CLEANUP_GROUPS.iter().any(|g| groups.contains(*g)) {
+    return RewriteKind::BranchDeletion;
+  }
+  if groups.contains(HELPER_INLINING_GROUP) {
+    return RewriteKind::HelperInlining;
+  }
+  if edit.replacement_string().trim().is_empty()
+    && (edit
+      .p_match()
+      .matched_string()
+      .trim_start()
+      .starts_with("const")
+      || edit.matched_rule().contains("const"))
+  {
+    return RewriteKind::ConstantDeletion;
+  }
+  RewriteKind::Other
+}
+
+impl fmt::Display for RunStatistics {
+  /// Formats the statistics as a table, with a row per flag and a row for the totals
+  fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+    writeln!(
+      f,
+      "Files scanned: {}, files modified: {}, time elapsed: {:.2}s",
+      self.files_scanned, self.files_modified, self.elapsed_seconds
+    )?;
+    let width = self
+      .flags
+      .iter()
+      .map(|s| s.flag.chars().count())
+      .chain(["Flag".len(), "Total".len()])
+      .max()
+      .unwrap_or_default();
+    let header = [
+      "Files",
+      "Rewrites",
+      "Added",
+      "Removed",
+      "Branches",
+      "Constants",
+      "Helpers",
+    ];
+    write!(f, "{:<width$}", "Flag")?;
+    for column in header {
+      write!(f, "  {column:>9}")?;
+    }
+    writeln!(f)?;
+    let rows = self
+      .flags
+      .iter()
+      .map(|s| {
+        (
+          s.flag.as_str(),
+          [
+            s.files_modified,
+            s.rewrites,
+            s.lines_added,
+            s.lines_removed,
+            s.branches_deleted,
+            s.constants_deleted,
+            s.helpers_inlined,
+          ],
+        )
+      })
+      .chain(std::iter::once((
+        "Total",
+        [
+          self.files_modified,
+          self.rewrites,
+          self.lines_added,
+          self.lines_removed,
+          self.branches_deleted,
+          self.constants_deleted,
+          self.helpers_inlined,
+        ],
+      )));
+    for (flag, values) in rows {
+      write!(f, "{flag:<width$}")?;
+      for value in values {
+        write!(f, "  {value:>9}")?;
+      }
+      writeln!(f)?;
+    }
+    Ok(())
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/statistics_test.rs"]
+mod statistics_test;
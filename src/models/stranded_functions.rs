@@ -0,0 +1,259 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+use std::collections::{HashMap, HashSet};
+
+use log::{debug, warn};
+use regex::Regex;
+use tree_sitter::{Node, Parser};
+
+use super::{
+  edit::Edit, matches::Match, source_code_unit::SourceCodeUnit, specialization::collect_nodes,
+};
+
+/// The name of the (pseudo) rule reported (or applied) for the functions no longer referenced after the cleanup
+pub(crate) static STRANDED_FUNCTION: &str = "stranded_function";
+/// The name of the (pseudo) rule reported (or applied) for the provider sets (i.e. `wire.NewSet(...)` or `fx.Options(...)`)
+/// no longer referenced after the cleanup
+pub(crate) static STRANDED_PROVIDER_SET: &str = "stranded_provider_set";
+/// The name of the (pseudo) rule reported for the types only referenced by the stranded functions (e.g. the losing constructor)
+pub(crate) static STRANDED_TYPE: &str = "stranded_type";
+/// The dependency injection calls used to declare the provider sets, see `get_stranded_candidates`
+static PROVIDER_SET: &str =
+  r"^(wire\.NewSet|fx\.(Options|Provide|Module|Invoke|Supply|Decorate))\(";
+
+// Implements instance methods related to retiring the functions stranded by the cleanup
+impl SourceCodeUnit {
+  /// Returns the identifiers of the code removed by the rewrites of this file,
+  /// i.e. those of the replaced code that do not occur in its replacement
+  /// (e.g. `oldMiddleware` for `if true { r.Use(newMiddleware) } else { r.Use(oldMiddleware) }` replaced by `r.Use(newMiddleware)`).
+  pub(crate) fn get_removed_identifiers(&self) -> HashSet<String> {
+    let identifier = Regex::new(r"\b[A-Za-z_]\w*\b").unwrap();
+    let mut removed = HashSet::new();
+    for rewrite in self.rewrites() {
+      let kept: HashSet<&str> = identifier
+        .find_iter(rewrite.replacement_string())
+        .map(|m| m.as_str())
+        .collect();
+      removed.extend(
+        identifier
+          .find_iter(rewrite.p_match().matched_string())
+          .map(|m| m.as_str())
+          .filter(|i| !kept.contains(i))
+          .map(str::to_string),
+      );
+    }
+    removed
+  }
+
+  /// Returns the (unexported) declarations of this file that may be stranded by the cleanup, along with their (pseudo) rule, i.e.
+  /// * the top-level functions, except `main` and `init`,
+  /// * the package variables declared on their own as a provider set, e.g. `var oldSet = wire.NewSet(newOldStore)`.
+  pub(crate) fn get_stranded_candidates(&self) -> Vec<(String, &'static str)> {
+    let functions = self
+      .get_function_declarations()
+      .iter()
+      .map(|d| self.text_of(d.child_by_field_name("name")))
+      .filter(|n| !["main", "init"].contains(&n.as_str()))
+      .map(|n| (n, STRANDED_FUNCTION))
+      .collect::<Vec<_>>();
+    let provider_sets = self
+      .get_provider_set_declarations()
+      .iter()
+      .map(|(name, _)| (name.to_string(), STRANDED_PROVIDER_SET))
+      .collect::<Vec<_>>();
+    [functions, provider_sets]
+      .concat()
+      .into_iter()
+      .filter(|(n, _)| is_unexported(n))
+      .collect()
+  }
+
+  /// Reports the declaration of `name` (see `get_stranded_candidates`), since it is no longer referenced after the cleanup,
+  /// or deletes it when `delete` is set (see `aggressive_dead_code`).
+  pub(crate) fn retire_stranded_declaration(
+    &mut self, name: &str, rule: &str, delete: bool, parser: &mut Parser,
+  ) {
+    let declaration = if rule == STRANDED_PROVIDER_SET {
+      self
+        .get_provider_set_declarations()
+        .into_iter()
+        .find(|(n, _)| n == name)
+        .map(|(_, d)| d)
+    } else {
+      self
+        .get_function_declarations()
+        .into_iter()
+        .find(|d| self.text_of(d.child_by_field_name("name")) == name)
+    }
+    .map(|d| Match::new(self.text_of(Some(d)), d.range(), HashMap::new()));
+    let declaration = match declaration {
+      Some(d) => d,
+      None => return,
+    };
+    if !delete {
+      warn!(
+        "{:?}: `{name}` is no longer referenced after the cleanup. Use `--aggressive-dead-code` to delete it.",
+        self.path()
+      );
+      self.matches_mut().push((rule.to_string(), declaration));
+      return;
+    }
+    let edit = Edit::new(declaration, String::new(), rule.to_string(), self.code());
+    debug!("Deleting the stranded declaration of {name}");
+    self.rewrites_mut().push(edit.clone());
+    self.apply_edit(&edit, parser);
+  }
+
+  /// Checks if the function `function_name` declared in this file only returns a boolean literal, i.e. it is a wrapper
+  /// around the flag check reduced by the cleanup (e.g. `func isEnabled() bool { return true }`), whose calls are
+  /// replaced by the literal (see `replace_call_to_function_returning_boolean_literal`).
+  pub(crate) fn is_boolean_literal_wrapper(&self, function_name: &str) -> bool {
+    let body = Regex::new(r"^\{\s*return\s+(true|false)\s*\}$").unwrap();
+    self
+      .get_function_declarations()
+      .into_iter()
+      .find(|d| self.text_of(d.child_by_field_name("name")) == function_name)
+      .map(|d| body.is_match(&self.text_of(d.child_by_field_name("body"))))
+      .unwrap_or(false)
+  }
+
+  /// Returns the (named) types referenced by the function `function_name` declared in this file,
+  /// e.g. `oldStore` for `func newOldStore() Store { return &oldStore{} }`.
+  pub(crate) fn get_referenced_types(&self, function_name: &str) -> Vec<String> {
+    self
+      .get_function_declarations()
+      .into_iter()
+      .find(|d| self.text_of(d.child_by_field_name("name")) == function_name)
+      .map(|r| {
+        collect_nodes(r, |n| n.kind() == "type_identifier")
+          .iter()
+          .map(|n| self.text_of(Some(*n)))
+          .collect()
+      })
+      .unwrap_or_default()
+  }
+
+  /// Checks if this file declares the type `type_name` at the top level.
+  pub(crate) fn declares_type(&self, type_name: &str) -> bool {
+    self.get_type_declaration(type_name).is_some()
+  }
+
+  /// Returns the number of references to the type `type_name` in this file, except those in its declaration,
+  /// in the declarations of its methods and in the `stranded` functions and provider sets.
+  pub(crate) fn count_type_references(&self, type_name: &str, stranded: &HashSet<String>) -> usize {
+    let stranded_provider_sets = self
+      .get_provider_set_declarations()
+      .iter()
+      .filter(|(n, _)| stranded.contains(n))
+      .map(|(_, d)| d.id())
+      .collect::<HashSet<_>>();
+    let root_node = self.root_node();
+    let mut cursor = root_node.walk();
+    root_node
+      .named_children(&mut cursor)
+      .filter(|d| match d.kind() {
+        "function_declaration" => !stranded.contains(&self.text_of(d.child_by_field_name("name"))),
+        "var_declaration" => !stranded_provider_sets.contains(&d.id()),
+        "method_declaration" => self.get_receiver_type(*d) != type_name,
+        "type_declaration" => self.get_type_declaration(type_name).map(|t| t.id()) != Some(d.id()),
+        _ => true,
+      })
+      .map(|d| {
+        collect_nodes(d, |n| {
+          n.kind() == "type_identifier" && self.text_of(Some(*n)) == type_name
+        })
+        .len()
+      })
+      .sum()
+  }
+
+  /// Reports the declaration of the type `type_name`, since it is only referenced by the declarations stranded by the cleanup
+  /// (e.g. the losing constructor of a provider selected by the stale flag) and by its methods.
+  pub(crate) fn report_stranded_type(&mut self, type_name: &str) {
+    let declaration = self
+      .get_type_declaration(type_name)
+      .map(|d| Match::new(self.text_of(Some(d)), d.range(), HashMap::new()));
+    if let Some(declaration) = declaration {
+      warn!(
+        "{:?}: the type `{type_name}` is only referenced by the code stranded by the cleanup. It may be deleted along with its methods.",
+        self.path()
+      );
+      self
+        .matches_mut()
+        .push((STRANDED_TYPE.to_string(), declaration));
+    }
+  }
+
+  /// Returns the package variables of this file declared on their own as a provider set (see `PROVIDER_SET`),
+  /// along with their declaration.
+  fn get_provider_set_declarations(&self) -> Vec<(String, Node)> {
+    let provider_set = Regex::new(PROVIDER_SET).unwrap();
+    let root_node = self.root_node();
+    let mut cursor = root_node.walk();
+    root_node
+      .named_children(&mut cursor)
+      .filter(|d| d.kind() == "var_declaration")
+      .filter_map(|d| {
+        let mut cursor = d.walk();
+        let specs: Vec<Node> = d
+          .named_children(&mut cursor)
+          .filter(|n| n.kind() == "var_spec")
+          .collect();
+        let spec = match specs.as_slice() {
+          [spec] => *spec,
+          _ => return None,
+        };
+        let mut cursor = spec.walk();
+        let names: Vec<Node> = spec.children_by_field_name("name", &mut cursor).collect();
+        let value = spec.child_by_field_name("value")?;
+        if names.len() != 1 || !provider_set.is_match(&self.text_of(Some(value))) {
+          return None;
+        }
+        Some((self.text_of(names.first().copied()), d))
+      })
+      .collect()
+  }
+
+  /// Returns the (top-level) declaration of the type `type_name` in this file, if it declares only this type
+  fn get_type_declaration(&self, type_name: &str) -> Option<Node> {
+    let root_node = self.root_node();
+    let mut cursor = root_node.walk();
+    let declaration = root_node
+      .named_children(&mut cursor)
+      .filter(|d| d.kind() == "type_declaration")
+      .find(|d| {
+        let mut cursor = d.walk();
+        let specs: Vec<Node> = d.named_children(&mut cursor).collect();
+        specs.len() == 1 && self.text_of(specs[0].child_by_field_name("name")) == type_name
+      });
+    declaration
+  }
+
+  /// Returns the name of the type of the receiver of the method, e.g. `oldStore` for `func (s *oldStore) Get()`
+  fn get_receiver_type(&self, method: Node) -> String {
+    method
+      .child_by_field_name("receiver")
+      .and_then(|r| collect_nodes(r, |n| n.kind() == "type_identifier").pop())
+      .map(|t| self.text_of(Some(t)))
+      .unwrap_or_default()
+  }
+}
+
+/// Checks if the Go identifier is unexported
+fn is_unexported(name: &str) -> bool {
+  name.starts_with(|c: char| c.is_lowercase() || c == '_')
+}
+
+#[cfg(test)]
+#[path = "unit_tests/stranded_functions_test.rs"]
+mod stranded_functions_test;
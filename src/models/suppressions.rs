@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use log::info;
+use regex::Regex;
+
+use super::{
+  matches::Match, rule::InstantiatedRule, rule_store::RuleStore, source_code_unit::SourceCodeUnit,
+};
+
+/// The prefix of the directives exempting code from the cleanup (i.e. `piranha:ignore` and `piranha:ignore-file`)
+static IGNORE_DIRECTIVE: &str = "piranha:ignore";
+
+/// Checks if the content has a `piranha:ignore` (or `piranha:ignore-file`) directive, i.e. may have suppressed matches
+pub(crate) fn has_ignore_directive(content: &str) -> bool {
+  content.contains(IGNORE_DIRECTIVE)
+}
+
+/// Checks if the whole file is exempted from the cleanup, i.e. has a `piranha:ignore-file` directive
+pub(crate) fn is_ignored_file(content: &str) -> bool {
+  has_ignore_directive(content)
+    && Regex::new(r"(//|#)\s*piranha:ignore-file(\s|$)")
+      .unwrap()
+      .is_match(content)
+}
+
+// Implements instance methods related to the `piranha:ignore` directives
+impl SourceCodeUnit {
+  /// Checks if the match is exempted from the cleanup by a directive, i.e. a line comment with
+  /// * `piranha:ignore` at the end of the line the match starts on, or on its own on the line above it,
+  /// * `piranha:ignore-file` anywhere in the file.
+  ///
+  /// The directive may be followed by an explanation, e.g. `// piranha:ignore until the rollout is audited`.
+  pub(crate) fn is_suppressed(&self, p_match: &Match) -> bool {
+    if !has_ignore_directive(self.code()) {
+      return false;
+    }
+    if is_ignored_file(self.code()) {
+      return true;
+    }
+    let directive = Regex::new(r"(//|#)\s*piranha:ignore(\s|$)").unwrap();
+    let row = p_match.range().start_point.row;
+    let on_same_line = self
+      .code()
+      .lines()
+      .nth(row)
+      .map(|l| directive.is_match(l))
+      .unwrap_or(false);
+    let on_line_above = row > 0
+      && self
+        .code()
+        .lines()
+        .nth(row - 1)
+        .map(|l| {
+          let line = l.trim_start();
+          (line.starts_with("//") || line.starts_with('#')) && directive.is_match(line)
+        })
+        .unwrap_or(false);
+    on_same_line || on_line_above
+  }
+
+  /// Records the matches of the `rules` exempted from the cleanup by the directives (see `is_suppressed`),
+  /// such that they are still reported.
+  pub(crate) fn report_suppressed_matches(
+    &mut self, rules: &[InstantiatedRule], rule_store: &mut RuleStore,
+  ) {
+    if !has_ignore_directive(self.code()) {
+      return;
+    }
+    let mut suppressed_matches = vec![];
+    for rule in rules {
+      for p_match in self.get_candidate_matches(rule, rule_store, self.root_node(), true) {
+        // The match may already be reported (i.e. for a previous flag)
+        let is_reported = self
+          .suppressed_matches()
+          .iter()
+          .any(|(r, m)| *r == rule.name() && m.range() == p_match.range());
+        if !is_reported && self.is_suppressed(&p_match) {
+          suppressed_matches.push((rule.name(), p_match));
+        }
+      }
+    }
+    for (rule_name, p_match) in suppressed_matches {
+      info!(
+        "{:?}: suppressed the match of {} at line {} - {}",
+        self.path(),
+        rule_name,
+        p_match.range().start_point.row + 1,
+        p_match.matched_string()
+      );
+      self.suppressed_matches_mut().push((rule_name, p_match));
+    }
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/suppressions_test.rs"]
+mod suppressions_test;
@@ -0,0 +1,527 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use getset::Getters;
+use log::debug;
+use tree_sitter::Range;
+
+use super::{
+  dynamic_flag_names::STALE_FLAG_NAME,
+  edit::Edit,
+  flag_file::TREATED,
+  matches::Match,
+  persist::{back_up_file, write_atomically},
+  piranha_arguments::PiranhaArguments,
+};
+use crate::utilities::tree_sitter_utilities::{get_tree_sitter_edit, position_for_offset};
+
+/// The name of the (pseudo) rule reported for the conditions on the stale flag simplified in the templates
+pub(crate) static SIMPLIFY_TEMPLATE_CONDITION: &str = "simplify_template_condition";
+/// The substitution for the field evaluating the stale flag in the templates (e.g. `.Features.StaleFlag`)
+pub(crate) static TEMPLATE_FIELD: &str = "template_field";
+/// The substitution for the function evaluating the stale flag, passed by name, in the templates (e.g. `featureEnabled`)
+pub(crate) static TEMPLATE_FUNCTION: &str = "template_function";
+
+/// How the stale flag is evaluated in the templates (i.e. `{{ if .Features.StaleFlag }}` or
+/// `{{ if featureEnabled "stale_flag" }}`), along with its value
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub(crate) struct TemplateFlag {
+  flag_name: String,
+  field: Option<String>,
+  function: Option<String>,
+  value: bool,
+}
+
+impl TemplateFlag {
+  /// Returns how the stale flag is evaluated in the templates from the substitutions, i.e. `template_field` and (or)
+  /// `template_function`. Returns an error if neither is substituted, or if the flag is not boolean.
+  pub(crate) fn new(substitutions: &HashMap<String, String>) -> Result<TemplateFlag, String> {
+    let field = substitutions.get(TEMPLATE_FIELD).cloned();
+    let function = substitutions.get(TEMPLATE_FUNCTION).cloned();
+    if field.is_none() && function.is_none() {
+      return Err(format!(
+        "neither `{TEMPLATE_FIELD}` nor `{TEMPLATE_FUNCTION}` is substituted"
+      ));
+    }
+    let flag_name = substitutions
+      .get(STALE_FLAG_NAME)
+      .cloned()
+      .ok_or(format!("`{STALE_FLAG_NAME}` is not substituted"))?;
+    let value = match substitutions.get(TREATED).map(String::as_str) {
+      Some("true") => true,
+      Some("false") => false,
+      _ => return Err(format!("the flag `{flag_name}` is not boolean")),
+    };
+    Ok(TemplateFlag {
+      flag_name,
+      field: field.map(|f| format!(".{}", f.trim_start_matches(['$', '.']))),
+      function,
+      value,
+    })
+  }
+
+  /// Checks if the expression evaluates the flag, i.e. is the field (e.g. `.Features.StaleFlag` or `$.Features.StaleFlag`),
+  /// or a call to the function with the name of the flag (e.g. `featureEnabled "stale_flag"`)
+  fn is_flag(&self, expression: &str) -> bool {
+    if let Some(field) = &self.field {
+      if expression == field || expression.strip_prefix('$') == Some(field.as_str()) {
+        return true;
+      }
+    }
+    match (&self.function, split_operands(expression).as_slice()) {
+      (Some(function), [f, name]) => {
+        *f == function.as_str()
+          && (*name == format!("\"{}\"", self.flag_name)
+            || *name == format!("`{}`", self.flag_name))
+      }
+      _ => false,
+    }
+  }
+
+  /// Evaluates the condition of an `if` action, i.e. a constant if it only depends on the flag, the remaining condition if
+  /// it combines the flag with other operands (i.e. `not`, `and` and `or`), or `Unrelated` if it does not evaluate the flag.
+  fn evaluate(&self, expression: &str) -> Condition {
+    let expression = strip_parentheses(expression);
+    if self.is_flag(expression) {
+      return Condition::Constant(self.value);
+    }
+    match split_operands(expression).as_slice() {
+      ["not", operand] => match self.evaluate(operand) {
+        Condition::Constant(b) => Condition::Constant(!b),
+        Condition::Residual(r) => Condition::Residual(format!("not {}", parenthesize(&r))),
+        Condition::Unrelated => Condition::Unrelated,
+      },
+      [operator @ ("and" | "or"), operands @ ..] if operands.len() >= 2 => {
+        // `and` is false as soon as an operand is false, and `or` is true as soon as an operand is true
+        let absorbing = *operator == "or";
+        let conditions: Vec<Condition> = operands.iter().map(|o| self.evaluate(o)).collect();
+        if conditions.iter().all(|c| *c == Condition::Unrelated) {
+          return Condition::Unrelated;
+        }
+        if conditions.contains(&Condition::Constant(absorbing)) {
+          return Condition::Constant(absorbing);
+        }
+        let remaining: Vec<String> = operands
+          .iter()
+          .zip(conditions)
+          .filter_map(|(o, c)| match c {
+            Condition::Constant(_) => None,
+            Condition::Residual(r) => Some(parenthesize(&r)),
+            Condition::Unrelated => Some(o.to_string()),
+          })
+          .collect();
+        match remaining.as_slice() {
+          [] => Condition::Constant(!absorbing),
+          [operand] => Condition::Residual(strip_parentheses(operand).to_string()),
+          _ => Condition::Residual(format!("{operator} {}", remaining.join(" "))),
+        }
+      }
+      _ => Condition::Unrelated,
+    }
+  }
+}
+
+/// The evaluation of a condition of a template with the value of the stale flag
+#[derive(Debug, Clone, PartialEq, Eq)]
+enum Condition {
+  Constant(bool),
+  // The condition without the flag (e.g. `.User.Admin` for `and .Features.StaleFlag .User.Admin` when the flag is true)
+  Residual(String),
+  Unrelated,
+}
+
+/// A (`html/template` or `text/template`) file rendered by the code, e.g. `index.html.tmpl`
+#[derive(Debug, Clone, Getters)]
+pub(crate) struct TemplateFile {
+  #[get = "pub(crate)"]
+  path: PathBuf,
+  #[get = "pub(crate)"]
+  original_content: String,
+  #[get = "pub(crate)"]
+  content: String,
+  // The conditions simplified in the file
+  #[get = "pub(crate)"]
+  rewrites: Vec<Edit>,
+}
+
+impl TemplateFile {
+  pub(crate) fn new(path: PathBuf, content: String) -> Self {
+    TemplateFile {
+      path,
+      original_content: content.to_string(),
+      content,
+      rewrites: vec![],
+    }
+  }
+
+  /// Simplifies the conditional blocks (i.e. `{{ if ... }} ... {{ else }} ... {{ end }}`) evaluating the flag, i.e. keeps
+  /// the branch taken with the value of the flag and deletes the others, or removes the flag from the condition.
+  pub(crate) fn cleanup_flag(&mut self, flag: &TemplateFlag) {
+    // Simplify one condition at a time, since each simplification shifts the ranges of the remaining ones
+    while let Some((start_byte, end_byte, replacement)) = get_simplification(&self.content, flag) {
+      let range = Range {
+        start_byte,
+        end_byte,
+        start_point: position_for_offset(self.content.as_bytes(), start_byte),
+        end_point: position_for_offset(self.content.as_bytes(), end_byte),
+      };
+      let edit = Edit::new(
+        Match::new(
+          self.content[start_byte..end_byte].to_string(),
+          range,
+          HashMap::new(),
+        ),
+        replacement,
+        SIMPLIFY_TEMPLATE_CONDITION.to_string(),
+        &self.content,
+      );
+      debug!(
+        "Simplifying the condition on the flag in {:?} {}",
+        self.path,
+        edit.p_match().matched_string()
+      );
+      self.content = get_tree_sitter_edit(self.content.to_string(), &edit).0;
+      self.rewrites.push(edit);
+    }
+  }
+
+  /// Writes the updated content to the file (unless it is a dry run), atomically and after backing it up (see `backup`)
+  pub(crate) fn persist(&self, piranha_arguments: &PiranhaArguments) {
+    if *piranha_arguments.dry_run() || self.content == self.original_content {
+      return;
+    }
+    if let Some(backup_path) =
+      back_up_file(&self.path, piranha_arguments).expect("Unable to Back up file")
+    {
+      debug!(
+        "Backed up the template file {:?} to {:?}",
+        self.path, backup_path
+      );
+    }
+    debug!("Writing the template file {:?}", self.path);
+    write_atomically(&self.path, &self.content).expect("Unable to Write file");
+  }
+}
+
+/// An action of a template (i.e. `{{ ... }}`), along with its byte range
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+struct Action {
+  start: usize,
+  end: usize,
+  // The byte range of the content of the action, without the delimiters, the trim markers (i.e. `{{- ` and ` -}}`)
+  // and the surrounding spaces
+  body: (usize, usize),
+}
+
+impl Action {
+  fn body<'a>(&self, content: &'a str) -> &'a str {
+    &content[self.body.0..self.body.1]
+  }
+
+  fn keyword<'a>(&self, content: &'a str) -> &'a str {
+    self
+      .body(content)
+      .split(|c: char| c.is_whitespace() || c == '(')
+      .next()
+      .unwrap_or_default()
+  }
+
+  /// Returns the byte range of the condition of an `if` (or `else if`) action
+  fn get_condition(&self, content: &str) -> Option<(usize, usize)> {
+    let body = self.body(content);
+    let rest = match body.strip_prefix("else") {
+      Some(rest) if rest.starts_with(char::is_whitespace) => rest.trim_start(),
+      Some(_) => return None,
+      None => body,
+    };
+    let condition = rest.strip_prefix("if")?;
+    if !condition.starts_with(|c: char| c.is_whitespace() || c == '(') {
+      return None;
+    }
+    let start = self.body.1 - condition.trim_start().len();
+    Some((start, self.body.1)).filter(|(s, e)| s < e)
+  }
+
+  /// Returns the text of the action with the `replacement` of its body
+  fn with_body(&self, content: &str, replacement: &str) -> String {
+    format!(
+      "{}{replacement}{}",
+      &content[self.start..self.body.0],
+      &content[self.body.1..self.end]
+    )
+  }
+}
+
+/// A conditional block of a template, i.e. an `if` action along with its branches (i.e. `else if ...` and `else`) and
+/// its `end` action
+#[derive(Debug, Clone, PartialEq, Eq)]
+struct Conditional {
+  branches: Vec<Action>,
+  end: Action,
+}
+
+/// Returns the actions of the template, skipping the delimiters inside the strings and the comments
+fn get_actions(content: &str) -> Vec<Action> {
+  let bytes = content.as_bytes();
+  let mut actions = vec![];
+  let mut position = 0;
+  while let Some(offset) = content[position..].find("{{") {
+    let start = position + offset;
+    let mut i = start + 2;
+    let mut quote = None;
+    let end = loop {
+      match (bytes.get(i), quote) {
+        (None, _) => break None,
+        (Some(b'\\'), Some(b'"' | b'\'')) => i += 1,
+        (Some(c), Some(q)) if *c == q => quote = None,
+        (Some(_), Some(_)) => {}
+        (Some(c @ (b'"' | b'`' | b'\'')), None) => quote = Some(*c),
+        (Some(b'/'), None) if bytes.get(i + 1) == Some(&b'*') => match content[i..].find("*/") {
+          Some(o) => i += o + 1,
+          None => break None,
+        },
+        (Some(b'}'), None) if bytes.get(i + 1) == Some(&b'}') => break Some(i + 2),
+        _ => {}
+      }
+      i += 1;
+    };
+    let end = match end {
+      Some(e) => e,
+      None => break,
+    };
+    let (mut body_start, mut body_end) = (start + 2, end - 2);
+    let inner = &content[body_start..body_end];
+    if is_trim_marker(inner.strip_prefix('-').and_then(|r| r.chars().next())) {
+      body_start += 1;
+    }
+    if body_end > body_start
+      && is_trim_marker(inner.strip_suffix('-').and_then(|r| r.chars().next_back()))
+    {
+      body_end -= 1;
+    }
+    let inner = &content[body_start..body_end];
+    let body_start = body_start + (inner.len() - inner.trim_start().len());
+    let body_end = (body_start + inner.trim().len()).max(body_start);
+    actions.push(Action {
+      start,
+      end,
+      body: (body_start, body_end),
+    });
+    position = end;
+  }
+  actions
+}
+
+/// Checks if the character following (or preceding) the `-` of an action is a space, i.e. the `-` is a trim marker
+/// (e.g. `{{- end }}`) rather than a negative number (e.g. `{{-1}}`)
+fn is_trim_marker(character: Option<char>) -> bool {
+  character.map_or(false, char::is_whitespace)
+}
+
+/// Returns the conditional blocks of the template, in the order of their `if` action (i.e. the outer ones first)
+fn get_conditionals(content: &str) -> Vec<Conditional> {
+  let mut conditionals = vec![];
+  // The blocks being parsed, i.e. the branches of the conditional ones (or None for `range`, `with`, `block` and `define`)
+  let mut blocks: Vec<Option<Vec<Action>>> = vec![];
+  for action in get_actions(content) {
+    match action.keyword(content) {
+      "if" => blocks.push(Some(vec![action])),
+      "range" | "with" | "block" | "define" => blocks.push(None),
+      "else" => {
+        if let Some(Some(branches)) = blocks.last_mut() {
+          branches.push(action);
+        }
+      }
+      "end" => {
+        if let Some(Some(branches)) = blocks.pop() {
+          conditionals.push(Conditional {
+            branches,
+            end: action,
+          });
+        }
+      }
+      _ => {}
+    }
+  }
+  conditionals.sort_by_key(|c| c.branches[0].start);
+  conditionals
+}
+
+/// Returns the first simplification of a condition on the flag in the template, i.e. the byte range to replace along
+/// with its replacement:
+/// * a branch whose condition is true is kept (as the `else` branch, if it is not the first one), along with the previous
+///   branches, while the next ones are deleted,
+/// * a branch whose condition is false is deleted (the next branch becoming the first one, if it was the first one),
+/// * a condition combining the flag with other operands is replaced by the remaining condition.
+fn get_simplification(content: &str, flag: &TemplateFlag) -> Option<(usize, usize, String)> {
+  for Conditional { branches, end } in get_conditionals(content) {
+    for (k, branch) in branches.iter().enumerate() {
+      let condition = match branch.get_condition(content) {
+        Some(c) => c,
+        None => continue,
+      };
+      // The action ending the branch (i.e. the next branch or the `end`)
+      let next = branches.get(k + 1).unwrap_or(&end);
+      // The content of a branch, without the lines of its actions
+      let kept = |action: &Action, next: &Action| {
+        let (from, to) = (
+          get_line_range(content, action).1,
+          get_line_range(content, next).0,
+        );
+        content[from..to.max(from)].to_string()
+      };
+      return Some(
+        match (flag.evaluate(&content[condition.0..condition.1]), k) {
+          (Condition::Unrelated, _) => continue,
+          (Condition::Residual(r), _) => (condition.0, condition.1, r),
+          (Condition::Constant(true), 0) => (
+            get_line_range(content, branch).0,
+            get_line_range(content, &end).1,
+            kept(branch, next),
+          ),
+          (Condition::Constant(true), _) => (
+            branch.start,
+            get_line_range(content, &end).0,
+            format!(
+              "{}{}",
+              branch.with_body(content, "else"),
+              &content[branch.end..get_line_range(content, next).0.max(branch.end)]
+            ),
+          ),
+          (Condition::Constant(false), 0) if k + 1 == branches.len() => {
+            let (from, to) = get_lines(content, branch.start, end.end);
+            (from, to, String::new())
+          }
+          (Condition::Constant(false), 0) if next.body(content) == "else" => (
+            get_line_range(content, branch).0,
+            get_line_range(content, &end).1,
+            kept(next, branches.get(2).unwrap_or(&end)),
+          ),
+          // The next branch (i.e. `else if ...` or `else with ...`) becomes the first one
+          (Condition::Constant(false), 0) => {
+            let indentation = &content[get_line_range(content, next).0.min(next.start)..next.start];
+            let body = next.body(content)["else".len()..].trim_start();
+            (
+              get_line_range(content, branch).0,
+              next.end,
+              format!("{indentation}{}", next.with_body(content, body)),
+            )
+          }
+          (Condition::Constant(false), _) => (
+            get_line_range(content, branch).0,
+            get_line_range(content, next).0,
+            String::new(),
+          ),
+        },
+      );
+    }
+  }
+  None
+}
+
+/// Returns the byte range of the line of the action, if the action is alone on its line (see `get_lines`)
+fn get_line_range(content: &str, action: &Action) -> (usize, usize) {
+  get_lines(content, action.start, action.end)
+}
+
+/// Returns the byte range of the lines spanned by the byte range, along with the new line, if the range is alone on its
+/// lines (i.e. only preceded and followed by spaces), or else the byte range itself
+fn get_lines(content: &str, start: usize, end: usize) -> (usize, usize) {
+  let line_start = content[..start].rfind('\n').map_or(0, |i| i + 1);
+  let line_end = content[end..]
+    .find('\n')
+    .map_or(content.len(), |i| end + i + 1);
+  if content[line_start..start].trim().is_empty() && content[end..line_end].trim().is_empty() {
+    (line_start, line_end)
+  } else {
+    (start, end)
+  }
+}
+
+/// Returns the byte offsets of the characters of the expression outside of its strings, along with their nesting depth
+/// (i.e. the number of enclosing parentheses, an opening or closing parenthesis excluded)
+fn get_unquoted_characters(expression: &str) -> Vec<(usize, char, usize)> {
+  let mut characters = vec![];
+  let (mut depth, mut quote, mut escaped) = (0_usize, None, false);
+  for (i, c) in expression.char_indices() {
+    if let Some(q) = quote {
+      if escaped {
+        escaped = false;
+      } else if c == '\\' && q != '`' {
+        escaped = true;
+      } else if c == q {
+        quote = None;
+      }
+      continue;
+    }
+    match c {
+      '"' | '`' | '\'' => quote = Some(c),
+      '(' => {
+        characters.push((i, c, depth));
+        depth += 1;
+        continue;
+      }
+      ')' => depth = depth.saturating_sub(1),
+      _ => {}
+    }
+    characters.push((i, c, depth));
+  }
+  characters
+}
+
+/// Splits the expression into its operands, i.e. at the spaces outside of its strings and parentheses
+fn split_operands(expression: &str) -> Vec<&str> {
+  let mut operands = vec![];
+  let mut start = 0;
+  for (i, c, depth) in get_unquoted_characters(expression) {
+    if c.is_whitespace() && depth == 0 {
+      if i > start {
+        operands.push(&expression[start..i]);
+      }
+      start = i + c.len_utf8();
+    }
+  }
+  if expression.len() > start {
+    operands.push(&expression[start..]);
+  }
+  operands
+}
+
+/// Strips the parentheses enclosing the whole expression (e.g. `(featureEnabled "stale_flag")`)
+fn strip_parentheses(expression: &str) -> &str {
+  let mut expression = expression.trim();
+  while expression.starts_with('(')
+    && expression.ends_with(')')
+    && get_unquoted_characters(expression)
+      .iter()
+      .all(|(i, c, depth)| *c != ')' || *depth > 0 || *i == expression.len() - 1)
+  {
+    expression = expression[1..expression.len() - 1].trim();
+  }
+  expression
+}
+
+/// Encloses the expression in parentheses if it has several operands (e.g. to pass it as an operand of `and`)
+fn parenthesize(expression: &str) -> String {
+  if split_operands(expression).len() > 1 {
+    format!("({expression})")
+  } else {
+    expression.to_string()
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/templates_test.rs"]
+mod templates_test;
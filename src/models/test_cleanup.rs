@@ -0,0 +1,248 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashMap;
+
+use log::debug;
+use regex::Regex;
+use tree_sitter::{Node, Parser, Range};
+
+use super::{
+  edit::Edit, language::SupportedLanguage, matches::Match, rule::InstantiatedRule,
+  rule_store::RuleStore, source_code_unit::SourceCodeUnit,
+};
+use crate::utilities::tree_sitter_utilities::get_node_for_range;
+
+/// The group of the (user-defined) rules matching the calls that force the stale flag to its eliminated value
+/// in the tests, e.g. `exp.SetBoolValue("stale_flag", false)` (for `treated = true`)
+pub(crate) static FORCE_ELIMINATED_FLAG_VALUE: &str = "force_eliminated_flag_value";
+/// The name of the (pseudo) rule reported for the deleted tests and subtests
+pub(crate) static DELETE_TEST_FOR_ELIMINATED_FLAG_VALUE: &str =
+  "delete_test_for_eliminated_flag_value";
+/// The name of the (pseudo) rule reported for the TODOs added above the tests that may only exercise the removed branch
+pub(crate) static MARK_TEST_FOR_ELIMINATED_FLAG_VALUE: &str = "mark_test_for_eliminated_flag_value";
+/// The marker of the TODOs added above the tests
+static TODO_MARKER: &str = "TODO(piranha)";
+/// The kinds of the (simple) statements that force the flag value for the rest of the test
+static SIMPLE_STATEMENTS: [&str; 3] = [
+  "expression_statement",
+  "assignment_statement",
+  "short_var_declaration",
+];
+
+/// The cleanup of a test forcing the eliminated value of the stale flag
+#[derive(Debug, Clone, PartialEq, Eq)]
+enum TestCleanup {
+  // Delete the test function or the subtest (i.e. the `t.Run(...)` statement) in the range
+  Delete(Range),
+  // Add a TODO above the test function (with the given name) in the range
+  Mark(Range, String),
+}
+
+// Implements instance methods related to cleaning up the tests that only exercise the removed branch of the stale flag
+impl SourceCodeUnit {
+  /// Deletes the test functions (i.e. `func TestXxx(t *testing.T)`) and the subtests (i.e. `t.Run("...", func(t *testing.T) { ... })`)
+  /// whose body forces the stale flag to its eliminated value, i.e. contains a match of the `rules` as a statement.
+  /// Once the flag is cleaned up, they only exercise the removed branch (and would fail).
+  /// When the match is nested deeper inside the test (e.g. within a loop or a conditional), we are not confident that the test
+  /// only exercises the removed branch, thus it is marked with a TODO instead.
+  /// Only the `_test.go` files are considered. Currently, only supported for Go.
+  pub(crate) fn cleanup_tests_for_eliminated_flag_value(
+    &mut self, rules: &[InstantiatedRule], rule_store: &mut RuleStore, parser: &mut Parser,
+  ) {
+    if *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go
+      || !self
+        .path()
+        .to_str()
+        .map(|p| p.ends_with("_test.go"))
+        .unwrap_or(false)
+    {
+      return;
+    }
+    let mut cleaned_up = false;
+    // Clean up one test at a time, since each edit invalidates the ranges of the remaining matches
+    while let Some(test_cleanup) = self.get_test_cleanup(rules, rule_store) {
+      let edit = match test_cleanup {
+        TestCleanup::Delete(range) => {
+          self.get_test_deletion(range, DELETE_TEST_FOR_ELIMINATED_FLAG_VALUE)
+        }
+        TestCleanup::Mark(range, test_name) => Edit::new(
+          Match::new(
+            String::new(),
+            Range {
+              start_byte: range.start_byte,
+              end_byte: range.start_byte,
+              start_point: range.start_point,
+              end_point: range.start_point,
+            },
+            HashMap::new(),
+          ),
+          format!(
+            "// {TODO_MARKER}: {test_name} forces the eliminated value of the stale flag, delete it if it only exercises the removed branch\n"
+          ),
+          MARK_TEST_FOR_ELIMINATED_FLAG_VALUE.to_string(),
+          self.code(),
+        ),
+      };
+      debug!(
+        "Cleaning up the test forcing the eliminated flag value {}",
+        edit.p_match().matched_string()
+      );
+      self.rewrites_mut().push(edit.clone());
+      self.apply_edit(&edit, parser);
+      cleaned_up = true;
+    }
+    if cleaned_up {
+      self.perform_remove_unused_imports(parser);
+      self.perform_delete_consecutive_new_lines();
+    }
+  }
+
+  /// Returns the edit deleting the test (or subtest) in the range, along with its associated comments
+  pub(super) fn get_test_deletion(&self, range: Range, rule_name: &str) -> Edit {
+    let node = get_node_for_range(self.root_node(), range.start_byte, range.end_byte);
+    let mut p_match = Match::new(
+      self.code()[range.start_byte..range.end_byte].to_string(),
+      range,
+      HashMap::new(),
+    );
+    p_match.populate_associated_elements(&node, self.code(), self.piranha_arguments());
+    Edit::new(p_match, String::new(), rule_name.to_string(), self.code())
+  }
+
+  /// Returns the cleanup of the first test forcing the eliminated flag value (i.e. containing a match of the `rules`)
+  /// that is not cleaned up yet.
+  fn get_test_cleanup(
+    &self, rules: &[InstantiatedRule], rule_store: &mut RuleStore,
+  ) -> Option<TestCleanup> {
+    for rule in rules {
+      for p_match in self.get_matches(rule, rule_store, self.root_node(), true) {
+        let node = get_node_for_range(
+          self.root_node(),
+          p_match.range().start_byte,
+          p_match.range().end_byte,
+        );
+        if let Some(test_cleanup) = self.get_test_cleanup_for(node) {
+          return Some(test_cleanup);
+        }
+      }
+    }
+    None
+  }
+
+  /// Returns the cleanup of the test enclosing the `node` forcing the eliminated flag value (if any)
+  fn get_test_cleanup_for(&self, node: Node) -> Option<TestCleanup> {
+    let test_function = get_ancestors(node)
+      .into_iter()
+      .find(|n| self.is_test_function(n))?;
+    // The (simple) statement forcing the flag value, directly inside a test or a subtest
+    let statement = Some(node)
+      .into_iter()
+      .chain(get_ancestors(node))
+      .find(|n| {
+        n.parent()
+          .map(|p| p.kind() == "statement_list")
+          .unwrap_or(false)
+      })
+      .filter(|s| SIMPLE_STATEMENTS.contains(&s.kind()));
+    let body_owner = statement
+      .and_then(|s| s.parent())
+      .and_then(|statement_list| statement_list.parent())
+      .and_then(|block| block.parent());
+    if let Some(owner) = body_owner {
+      if owner.id() == test_function.id() {
+        return Some(TestCleanup::Delete(test_function.range()));
+      }
+      // Only the subtests run directly by the test function (i.e. not in a loop over a table of cases)
+      if let Some(subtest) = self.get_subtest_statement(owner).filter(|s| {
+        s.parent()
+          .and_then(|statement_list| statement_list.parent())
+          .and_then(|block| block.parent())
+          .map(|f| f.id() == test_function.id())
+          .unwrap_or(false)
+      }) {
+        return Some(TestCleanup::Delete(subtest.range()));
+      }
+    }
+    if self.is_marked(&test_function) {
+      return None;
+    }
+    Some(TestCleanup::Mark(
+      test_function.range(),
+      self.text_of(test_function.child_by_field_name("name")),
+    ))
+  }
+
+  /// Checks if the node is a test function, i.e. `func TestXxx(t *testing.T)`
+  pub(super) fn is_test_function(&self, node: &Node) -> bool {
+    node.kind() == "function_declaration"
+      && Regex::new(r"^Test($|[^a-z])")
+        .unwrap()
+        .is_match(&self.text_of(node.child_by_field_name("name")))
+      && self.has_testing_t_parameter(node)
+  }
+
+  /// Returns the statement running the function literal as a subtest, i.e. `t.Run("...", func(t *testing.T) { ... })`
+  fn get_subtest_statement<'a>(&self, node: Node<'a>) -> Option<Node<'a>> {
+    if node.kind() != "func_literal" || !self.has_testing_t_parameter(&node) {
+      return None;
+    }
+    let call = node
+      .parent()
+      .filter(|a| a.kind() == "argument_list")
+      .and_then(|a| a.parent())
+      .filter(|c| c.kind() == "call_expression")?;
+    let is_run = call
+      .child_by_field_name("function")
+      .filter(|f| f.kind() == "selector_expression")
+      .map(|f| self.text_of(f.child_by_field_name("field")) == "Run")
+      .unwrap_or(false);
+    call
+      .parent()
+      .filter(|s| is_run && s.kind() == "expression_statement")
+  }
+
+  /// Checks if the only parameter of the function (or function literal) is a `*testing.T`
+  fn has_testing_t_parameter(&self, node: &Node) -> bool {
+    Regex::new(r"^\(\s*\w+\s+\*testing\.T\s*\)$")
+      .unwrap()
+      .is_match(&self.text_of(node.child_by_field_name("parameters")))
+  }
+
+  /// Checks if the test function is already marked with the TODO (i.e. on the line above it)
+  fn is_marked(&self, test_function: &Node) -> bool {
+    let row = test_function.start_position().row;
+    row > 0
+      && self
+        .code()
+        .lines()
+        .nth(row - 1)
+        .map(|l| l.contains(TODO_MARKER))
+        .unwrap_or(false)
+  }
+}
+
+/// Returns the ancestors of the node, starting from its parent
+pub(super) fn get_ancestors(node: Node) -> Vec<Node> {
+  let mut ancestors = vec![];
+  let mut current = node;
+  while let Some(parent) = current.parent() {
+    ancestors.push(parent);
+    current = parent;
+  }
+  ancestors
+}
+
+#[cfg(test)]
+#[path = "unit_tests/test_cleanup_test.rs"]
+mod test_cleanup_test;
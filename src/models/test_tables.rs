@@ -0,0 +1,345 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, iter::once};
+
+use log::debug;
+use regex::Regex;
+use tree_sitter::{Node, Parser, Range};
+
+use super::{
+  edit::Edit,
+  language::SupportedLanguage,
+  matches::Match,
+  rule::InstantiatedRule,
+  rule_store::RuleStore,
+  source_code_unit::SourceCodeUnit,
+  specialization::{collect_nodes, get_element_deletion_range, get_list_elements},
+  test_cleanup::{get_ancestors, DELETE_TEST_FOR_ELIMINATED_FLAG_VALUE},
+};
+use crate::utilities::tree_sitter_utilities::get_node_for_range;
+
+/// The group of the (user-defined) rules matching the calls setting the stale flag in the tests, that capture the value as `@flag_value`,
+/// e.g. `exp.SetBoolValue("stale_flag", tc.flagOn)`
+pub(crate) static SET_STALE_FLAG_VALUE: &str = "set_stale_flag_value";
+/// The substitution denoting the treated value of the stale flag (i.e. `true` or `false`)
+pub(crate) static TREATED: &str = "treated";
+/// The name of the (pseudo) rule reported for the deleted rows of the tables of test cases
+pub(crate) static DELETE_TABLE_ROW_FOR_ELIMINATED_FLAG_VALUE: &str =
+  "delete_table_row_for_eliminated_flag_value";
+/// The name of the (pseudo) rule reported for the deleted field (i.e. its declaration and initializations) of the tables of test cases
+pub(crate) static DELETE_CONSTANT_TABLE_FIELD: &str = "delete_constant_table_field";
+/// The tag of the value captured by the rules of the `set_stale_flag_value` group
+static FLAG_VALUE: &str = "flag_value";
+/// The built-in rule used to propagate the value of the constant field into the body of the test
+static REPLACE_FIELD_READ_WITH_VALUE: &str = "replace_field_read_with_value";
+/// The scope (see `scope_config.toml`) within which the value of the constant field is propagated
+static FUNCTION_SCOPE: &str = "Function-Method";
+
+/// The next step of the cleanup of a table of test cases (i.e. `[]struct{ ... }{ {...}, ... }`), iterated over by a loop
+/// setting the stale flag to one of its fields (e.g. `for _, tc := range cases { exp.SetBoolValue("stale_flag", tc.flagOn) ... }`)
+#[derive(Debug, Clone, PartialEq, Eq)]
+enum TableCleanup {
+  // Delete the test function in the range, since all the rows set the field to the eliminated value
+  DeleteTest(Range),
+  // Delete the row in the range, since it sets the field to the eliminated value
+  DeleteRow(Range),
+  // Delete the constant field, i.e. its declaration and initializations in the (ascending) ranges,
+  // and propagate the treated value into the test (with the given name)
+  DeleteField(Vec<Range>, String, String),
+}
+
+// Implements instance methods related to cleaning up the tables of test cases parameterized by the stale flag
+impl SourceCodeUnit {
+  /// Deletes the rows of the tables of test cases (e.g. `{name: "flag off", flagOn: false, want: old}`) setting the stale flag
+  /// to its eliminated value, i.e. the rows of the table iterated over by a loop where a match of the `rules` sets the flag to
+  /// a field of the row (e.g. `exp.SetBoolValue("stale_flag", tc.flagOn)`).
+  /// When the remaining rows all set the field to the treated value, the field is deleted from the (anonymous) struct and
+  /// the rows, and the treated value is propagated into the body of the test. The whole test is deleted when no row remains.
+  /// The tables with positional rows (i.e. `{"flag off", false, old}`) are left as they are.
+  /// Only the `_test.go` files are considered. Currently, only supported for Go.
+  pub(crate) fn cleanup_test_tables(
+    &mut self, rules: &[InstantiatedRule], rule_store: &mut RuleStore, parser: &mut Parser,
+  ) {
+    let treated = match self.piranha_arguments().input_substitutions().get(TREATED) {
+      Some(t) if ["true", "false"].contains(&t.as_str()) => t.to_string(),
+      _ => return,
+    };
+    if *self.piranha_arguments().language().supported_language() != SupportedLanguage::Go
+      || !self
+        .path()
+        .to_str()
+        .map(|p| p.ends_with("_test.go"))
+        .unwrap_or(false)
+    {
+      return;
+    }
+    let mut cleaned_up = false;
+    // Perform one step at a time, since each edit invalidates the ranges of the remaining rows and matches
+    while let Some(table_cleanup) = self.get_table_cleanup(rules, rule_store, &treated) {
+      debug!("Cleaning up the table of test cases {:?}", table_cleanup);
+      let edits = match &table_cleanup {
+        TableCleanup::DeleteTest(range) => {
+          vec![self.get_test_deletion(*range, DELETE_TEST_FOR_ELIMINATED_FLAG_VALUE)]
+        }
+        TableCleanup::DeleteRow(range) => {
+          vec![self.get_deletion(*range, DELETE_TABLE_ROW_FOR_ELIMINATED_FLAG_VALUE)]
+        }
+        // The declaration (i.e. the first range) is deleted along with its associated comments
+        TableCleanup::DeleteField(ranges, _, _) => ranges
+          .iter()
+          .enumerate()
+          .map(|(index, range)| match index {
+            0 => self.get_test_deletion(*range, DELETE_CONSTANT_TABLE_FIELD),
+            _ => self.get_deletion(*range, DELETE_CONSTANT_TABLE_FIELD),
+          })
+          .collect(),
+      };
+      // Apply the edits from the bottom, so that the ranges of the remaining ones stay valid
+      for edit in edits.iter().rev() {
+        self.rewrites_mut().push(edit.clone());
+        self.apply_edit(edit, parser);
+      }
+      if let TableCleanup::DeleteField(_, test_name, field_name) = &table_cleanup {
+        self.propagate_field_value_into_test(test_name, field_name, &treated, rule_store, parser);
+      }
+      cleaned_up = true;
+    }
+    if cleaned_up {
+      self.perform_remove_unused_imports(parser);
+      self.perform_delete_consecutive_new_lines();
+    }
+  }
+
+  /// Returns the next step of the cleanup of the first table of test cases parameterized by the stale flag
+  /// (see `cleanup_test_tables`), that is not cleaned up yet.
+  fn get_table_cleanup(
+    &self, rules: &[InstantiatedRule], rule_store: &mut RuleStore, treated: &str,
+  ) -> Option<TableCleanup> {
+    let row_field = Regex::new(r"^(\w+)\.(\w+)$").unwrap();
+    for rule in rules {
+      for p_match in self.get_matches(rule, rule_store, self.root_node(), true) {
+        let captures = match p_match
+          .matches()
+          .get(FLAG_VALUE)
+          .and_then(|v| row_field.captures(v))
+        {
+          Some(c) => c,
+          None => continue,
+        };
+        let node = get_node_for_range(
+          self.root_node(),
+          p_match.range().start_byte,
+          p_match.range().end_byte,
+        );
+        if let Some(table_cleanup) =
+          self.get_table_cleanup_for(node, &captures[1], &captures[2], treated)
+        {
+          return Some(table_cleanup);
+        }
+      }
+    }
+    None
+  }
+
+  /// Returns the next step of the cleanup of the table iterated over by the loop enclosing the `node`,
+  /// where the row variable is `row` and the flag is set to its field `field_name`.
+  /// Returns None if there is no such table, or it is already cleaned up.
+  fn get_table_cleanup_for(
+    &self, node: Node, row: &str, field_name: &str, treated: &str,
+  ) -> Option<TableCleanup> {
+    let ancestors = get_ancestors(node);
+    let test_function = ancestors.iter().find(|n| self.is_test_function(n))?;
+    // The loop `for _, row := range table { ... }`
+    let range_clause = ancestors
+      .iter()
+      .filter(|n| n.kind() == "for_statement")
+      .filter_map(|f| get_named_child_of_kind(f, "range_clause"))
+      .find(|r| {
+        r.child_by_field_name("left")
+          .map(|l| {
+            get_list_elements(l)
+              .iter()
+              .map(|v| self.text_of(Some(*v)))
+              .collect::<Vec<String>>()
+          })
+          .filter(|variables| variables.len() == 2 && variables[1] == row)
+          .is_some()
+      })?;
+    let table =
+      self.get_table_literal(range_clause.child_by_field_name("right")?, test_function)?;
+    let body = table.child_by_field_name("body")?;
+    let rows: Vec<Node> = get_list_elements(body)
+      .into_iter()
+      .map(unwrap_element)
+      .collect();
+
+    let mut eliminated_rows = vec![];
+    let mut initializations = vec![];
+    let mut is_constant = true;
+    for (index, row) in rows.iter().enumerate() {
+      if row.kind() != "literal_value" {
+        return None;
+      }
+      let (value, initialization) = self.get_row_field(*row, field_name)?;
+      if value == treated {
+        initializations.extend(initialization.and_then(|i| get_element_deletion_range(*row, i)));
+      } else if ["true", "false"].contains(&value.as_str()) {
+        eliminated_rows.push(index);
+      } else {
+        is_constant = false;
+      }
+    }
+
+    if !rows.is_empty() && eliminated_rows.len() == rows.len() {
+      return Some(TableCleanup::DeleteTest(test_function.range()));
+    }
+    if let Some(index) = eliminated_rows.first() {
+      return get_element_deletion_range(body, *index).map(TableCleanup::DeleteRow);
+    }
+    if !is_constant {
+      return None;
+    }
+    let declaration = get_field_declaration(table, field_name, self)?;
+    Some(TableCleanup::DeleteField(
+      once(declaration.range()).chain(initializations).collect(),
+      self.text_of(test_function.child_by_field_name("name")),
+      field_name.to_string(),
+    ))
+  }
+
+  /// Returns the edit deleting the code in the range
+  fn get_deletion(&self, range: Range, rule_name: &str) -> Edit {
+    Edit::new(
+      Match::new(
+        self.code()[range.start_byte..range.end_byte].to_string(),
+        range,
+        HashMap::new(),
+      ),
+      String::new(),
+      rule_name.to_string(),
+      self.code(),
+    )
+  }
+
+  /// Returns the composite literal of the table, i.e. the range expression itself or the value of the local variable
+  /// it refers to (e.g. `cases := []struct{ ... }{ ... }`).
+  fn get_table_literal<'a>(
+    &self, expression: Node<'a>, test_function: &Node<'a>,
+  ) -> Option<Node<'a>> {
+    if expression.kind() == "composite_literal" {
+      return Some(expression);
+    }
+    if expression.kind() != "identifier" {
+      return None;
+    }
+    let name = self.text_of(Some(expression));
+    collect_nodes(*test_function, |n| {
+      n.kind() == "short_var_declaration"
+        && n.start_byte() < expression.start_byte()
+        && self.text_of(n.child_by_field_name("left")) == name
+    })
+    .into_iter()
+    .filter_map(|d| d.child_by_field_name("right"))
+    .filter_map(|r| r.named_child(0).filter(|_| r.named_child_count() == 1))
+    .find(|v| v.kind() == "composite_literal")
+  }
+
+  /// Returns the value the row sets to the field (i.e. `false` when it is not initialized), along with the index of
+  /// the initialization (if any). Returns None for positional rows.
+  fn get_row_field(&self, row: Node, field_name: &str) -> Option<(String, Option<usize>)> {
+    let mut field = (String::from("false"), None);
+    for (index, element) in get_list_elements(row).iter().enumerate() {
+      if element.kind() != "keyed_element" {
+        return None;
+      }
+      let key = element.named_child(0).map(unwrap_element);
+      if self.text_of(key) == field_name {
+        let value = element.named_child(1).map(unwrap_element);
+        field = (self.text_of(value), Some(index));
+      }
+    }
+    Some(field)
+  }
+
+  /// Replaces the reads of the (deleted) field inside the test function with its value,
+  /// and triggers the cleanup of the body with the built-in rules (e.g. `if true { ... }`).
+  fn propagate_field_value_into_test(
+    &mut self, test_name: &str, field_name: &str, value: &str, rules_store: &mut RuleStore,
+    parser: &mut Parser,
+  ) {
+    let test_range = collect_nodes(self.root_node(), |n| {
+      n.kind() == "function_declaration" && self.text_of(n.child_by_field_name("name")) == test_name
+    })
+    .first()
+    .map(|f| f.range());
+    let substitutions = HashMap::from([
+      ("field_name".to_string(), field_name.to_string()),
+      ("field_value".to_string(), value.to_string()),
+    ]);
+    let rule = self
+      .piranha_arguments()
+      .rule_graph()
+      .get_rule_named(&REPLACE_FIELD_READ_WITH_VALUE.to_string())
+      .map(|r| InstantiatedRule::new(r, &substitutions));
+    if let (Some(range), Some(rule)) = (test_range, rule) {
+      let scope_query = self.get_scope_query(
+        FUNCTION_SCOPE,
+        range.start_byte,
+        range.end_byte,
+        rules_store,
+      );
+      self.apply_rules(rules_store, &[rule], parser, Some(scope_query));
+    }
+  }
+}
+
+/// Returns the declaration of the `bool` field in the anonymous struct of the rows of the table (i.e. `[]struct{ ... }`)
+fn get_field_declaration<'a>(
+  table: Node<'a>, field_name: &str, source_code_unit: &SourceCodeUnit,
+) -> Option<Node<'a>> {
+  let element_type = table
+    .child_by_field_name("type")
+    .and_then(|t| t.child_by_field_name("element"))
+    .filter(|e| e.kind() == "struct_type")?;
+  collect_nodes(element_type, |n| n.kind() == "field_declaration")
+    .into_iter()
+    .find(|d| {
+      let mut cursor = d.walk();
+      let names: Vec<String> = d
+        .children_by_field_name("name", &mut cursor)
+        .map(|n| source_code_unit.text_of(Some(n)))
+        .collect();
+      names == [field_name] && source_code_unit.text_of(d.child_by_field_name("type")) == "bool"
+    })
+}
+
+/// Returns the first named child of the node with the given kind
+fn get_named_child_of_kind<'a>(node: &Node<'a>, kind: &str) -> Option<Node<'a>> {
+  let mut cursor = node.walk();
+  let child = node.named_children(&mut cursor).find(|c| c.kind() == kind);
+  child
+}
+
+/// Returns the expression (or literal value) wrapped by a `literal_element` (if any)
+fn unwrap_element(node: Node) -> Node {
+  if node.kind() == "literal_element" {
+    node.named_child(0).unwrap_or(node)
+  } else {
+    node
+  }
+}
+
+#[cfg(test)]
+#[path = "unit_tests/test_tables_test.rs"]
+mod test_tables_test;
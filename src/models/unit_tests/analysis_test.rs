@@ -0,0 +1,120 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use tree_sitter::{Point, Range};
+
+use crate::{
+  models::{
+    default_configs::GO, edit::Edit, language::PiranhaLanguage, matches::Match,
+    piranha_arguments::PiranhaArgumentsBuilder, piranha_output::PiranhaOutputSummary,
+    rule_graph::RuleGraphBuilder, source_code_unit::SourceCodeUnit,
+  },
+  piranha_rule,
+};
+
+use super::{
+  get_line_range, AnalysisDiagnostic, AnalysisReport, AnalysisSuggestedFix, AnalysisTextEdit,
+};
+
+static ORIGINAL_CONTENT: &str = "package main
+
+func run() {
+	enabled := isEnabled(\"stale_flag\")
+	println(enabled)
+}
+";
+
+#[test]
+fn test_analysis_report() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![
+      ("stale_flag_name".to_string(), "stale_flag".to_string()),
+      ("treated".to_string(), "true".to_string()),
+    ])
+    .rule_graph(
+      RuleGraphBuilder::default()
+        .rules(vec![piranha_rule! {
+          name = "replace_is_enabled",
+          query = "((call_expression) @call)",
+          replace_node = "call",
+          replace = "@treated",
+          holes = ["treated"]
+        }])
+        .build(),
+    )
+    .check(true)
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    ORIGINAL_CONTENT.to_string(),
+    &HashMap::new(),
+    PathBuf::from("main.go").as_path(),
+    &piranha_arguments,
+  );
+  let start_byte = ORIGINAL_CONTENT.find("isEnabled").unwrap();
+  let end_byte = start_byte + "isEnabled(\"stale_flag\")".len();
+  let edit = Edit::new(
+    Match::new(
+      ORIGINAL_CONTENT[start_byte..end_byte].to_string(),
+      Range {
+        start_byte,
+        end_byte,
+        start_point: Point::new(3, 12),
+        end_point: Point::new(3, 35),
+      },
+      HashMap::new(),
+    ),
+    "true".to_string(),
+    "replace_is_enabled".to_string(),
+    &ORIGINAL_CONTENT.to_string(),
+  );
+  source_code_unit.apply_edit(&edit, &mut parser);
+  source_code_unit.rewrites_mut().push(edit);
+
+  let summaries = vec![PiranhaOutputSummary::new(&source_code_unit)];
+  let report = AnalysisReport::new(&summaries, &piranha_arguments);
+
+  // The fix replaces the hunk of the diff containing the usage
+  let line_start = ORIGINAL_CONTENT.find("\tenabled").unwrap();
+  let line_end = ORIGINAL_CONTENT.find("\tprintln").unwrap();
+  assert_eq!(
+    report.diagnostics,
+    vec![AnalysisDiagnostic {
+      path: "main.go".to_string(),
+      start: start_byte,
+      end: end_byte,
+      category: "replace_is_enabled".to_string(),
+      message: "the stale flag `stale_flag` is still used (matched by `replace_is_enabled`) - isEnabled(\"stale_flag\")".to_string(),
+      suggested_fixes: vec![AnalysisSuggestedFix {
+        message: "Clean up the stale flag `stale_flag`".to_string(),
+        text_edits: vec![AnalysisTextEdit {
+          start: line_start,
+          end: line_end,
+          new_text: "\tenabled := true\n".to_string(),
+        }],
+      }],
+    }]
+  );
+}
+
+#[test]
+fn test_get_line_range() {
+  assert_eq!(get_line_range("ab\ncd\n", 2), (3, 5));
+  assert_eq!(get_line_range("ab\ncd", 2), (3, 5));
+  assert_eq!(get_line_range("ab\n", 1), (0, 2));
+}
@@ -0,0 +1,76 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::fs;
+
+use tempdir::TempDir;
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+};
+
+use super::{archive_flags, encode_component, find_reference};
+
+#[test]
+fn test_archive_flags_still_referenced() {
+  let temp_dir = TempDir::new("archive").unwrap();
+  let codebase = temp_dir.path();
+  fs::write(
+    codebase.join("search.go"),
+    "package main\n\nfunc search() {\n\tif flags.Enabled(\"enable_search\") {\n\t\tquery()\n\t}\n}\n",
+  )
+  .unwrap();
+  // The name of the flag also occurs in other words, and in the files of other languages
+  fs::write(
+    codebase.join("checkout.go"),
+    "package main\n\nvar enable_search_v2 = true\n",
+  )
+  .unwrap();
+  fs::write(codebase.join("flags.yaml"), "enable_checkout: true\n").unwrap();
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(codebase.to_string_lossy().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![(
+      "stale_flag_name".to_string(),
+      "enable_search".to_string(),
+    )])
+    .archive("webhook".to_string())
+    .archive_url("http://localhost/flags".to_string())
+    .build();
+
+  let outcomes = archive_flags(&[], &piranha_arguments);
+  assert_eq!(outcomes.len(), 1);
+  let (flag, outcome) = &outcomes[0];
+  assert_eq!(flag, "enable_search");
+  assert!(outcome
+    .as_ref()
+    .unwrap_err()
+    .contains("it is still referenced in"));
+  assert!(find_reference(&piranha_arguments, "enable_checkout").is_none());
+  assert!(find_reference(&piranha_arguments, "enable_search_v2")
+    .unwrap()
+    .ends_with("checkout.go"));
+}
+
+#[test]
+fn test_encode_component() {
+  assert_eq!(
+    encode_component("enable_search-v2.1~"),
+    "enable_search-v2.1~"
+  );
+  assert_eq!(
+    encode_component("team/flag name?x=1&y#z%"),
+    "team%2Fflag%20name%3Fx%3D1%26y%23z%25"
+  );
+  assert_eq!(encode_component("drapeau_é"), "drapeau_%C3%A9");
+}
@@ -0,0 +1,94 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use crate::models::{
+  default_configs::GO,
+  language::PiranhaLanguage,
+  piranha_arguments::{PiranhaArguments, PiranhaArgumentsBuilder},
+  piranha_output::PiranhaOutputSummary,
+  source_code_unit::SourceCodeUnit,
+};
+
+use super::get_exceeded_limits;
+
+static ORIGINAL_CONTENT: &str = "package main
+
+func run() {
+	if isEnabled(\"stale_flag\") {
+		println(\"new\")
+	} else {
+		println(\"old\")
+	}
+}
+";
+
+static REWRITTEN_CONTENT: &str = "package main
+
+func run() {
+	println(\"new\")
+}
+";
+
+/// Returns the summaries of the `number_of_files` files rewritten by the cleanup (deleting 5 lines each)
+fn get_summaries(
+  number_of_files: usize, piranha_arguments: &PiranhaArguments,
+) -> Vec<PiranhaOutputSummary> {
+  let mut parser = piranha_arguments.language().parser();
+  (0..number_of_files)
+    .map(|index| {
+      let mut source_code_unit = SourceCodeUnit::new(
+        &mut parser,
+        ORIGINAL_CONTENT.to_string(),
+        &HashMap::new(),
+        PathBuf::from(format!("main_{index}.go")).as_path(),
+        piranha_arguments,
+      );
+      source_code_unit.set_code(REWRITTEN_CONTENT.to_string());
+      PiranhaOutputSummary::new(&source_code_unit)
+    })
+    .collect()
+}
+
+#[test]
+fn test_get_exceeded_limits() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .max_changed_files(2)
+    .max_deleted_lines(12)
+    .build();
+  assert!(
+    get_exceeded_limits(&get_summaries(2, &piranha_arguments), &piranha_arguments).is_empty()
+  );
+  assert_eq!(
+    get_exceeded_limits(&get_summaries(3, &piranha_arguments), &piranha_arguments),
+    vec![
+      "The cleanup changes 3 files, more than the limit of 2 (see `max_changed_files`)".to_string(),
+      "The cleanup deletes 15 lines, more than the limit of 12 (see `max_deleted_lines`)"
+        .to_string(),
+    ]
+  );
+}
+
+#[test]
+fn test_get_exceeded_limits_unlimited() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  assert!(
+    get_exceeded_limits(&get_summaries(10, &piranha_arguments), &piranha_arguments).is_empty()
+  );
+}
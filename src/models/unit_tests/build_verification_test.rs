@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, fs, path::PathBuf};
+
+use tempdir::TempDir;
+
+use super::{get_overlay, restore_file_names, BUILD_FAILURE, GO_OUTPUT, TESTS_PASSED};
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+  source_code_unit::SourceCodeUnit,
+};
+
+#[test]
+fn test_get_overlay() {
+  let package = TempDir::new("package").unwrap();
+  let directory = package.path().canonicalize().unwrap();
+  for file in ["checkout.go", "flags.go"] {
+    fs::write(directory.join(file), "package checkout\n").unwrap();
+  }
+  let files = vec![
+    (
+      directory.join("checkout.go"),
+      "package checkout\n\nfunc pay() {}\n".to_string(),
+    ),
+    // Deleted, since it is empty after the cleanup
+    (directory.join("flags.go"), String::new()),
+  ];
+  let overlay = get_overlay(PathBuf::from("/tmp/overlay").as_path(), &files);
+  assert_eq!(
+    overlay.replace,
+    [
+      (
+        directory.join("checkout.go").to_string_lossy().to_string(),
+        "/tmp/overlay/0_checkout.go".to_string()
+      ),
+      (
+        directory.join("flags.go").to_string_lossy().to_string(),
+        String::new()
+      ),
+    ]
+    .into_iter()
+    .collect()
+  );
+}
+
+#[test]
+fn test_restore_file_names() {
+  let files = vec![
+    (PathBuf::from("checkout/checkout.go"), String::new()),
+    (PathBuf::from("checkout/flags.go"), String::new()),
+  ];
+  assert_eq!(
+    restore_file_names(
+      "# example.com/shop/checkout\n../../tmp/piranha_build.x/1_flags.go:3:2: undefined: isEnabled",
+      &files
+    ),
+    "# example.com/shop/checkout\n./flags.go:3:2: undefined: isEnabled"
+  );
+}
+
+#[test]
+fn test_report_package_verification() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let content = "// Package checkout ...\npackage checkout\n\nfunc pay() { charge() }\n";
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    content.to_string(),
+    &HashMap::new(),
+    PathBuf::from("checkout.go").as_path(),
+    &piranha_arguments,
+  );
+  source_code_unit
+    .report_package_verification(BUILD_FAILURE, Some("./checkout.go:4:14: undefined: charge"));
+  source_code_unit.report_package_verification(TESTS_PASSED, None);
+  let (rule, p_match) = &source_code_unit.matches()[0];
+  assert_eq!(rule, BUILD_FAILURE);
+  assert_eq!(p_match.matched_string(), "package checkout");
+  assert_eq!(
+    p_match.matches()[GO_OUTPUT],
+    "./checkout.go:4:14: undefined: charge"
+  );
+  let (rule, p_match) = &source_code_unit.matches()[1];
+  assert_eq!(rule, TESTS_PASSED);
+  assert!(p_match.matches().is_empty());
+}
@@ -0,0 +1,163 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::{BTreeMap, BTreeSet, HashMap},
+  path::{Path, PathBuf},
+};
+
+use tempdir::TempDir;
+
+use crate::{
+  models::{
+    default_configs::GO,
+    language::PiranhaLanguage,
+    piranha_arguments::{PiranhaArguments, PiranhaArgumentsBuilder},
+    rule::InstantiatedRule,
+    rule_graph::RuleGraphBuilder,
+  },
+  piranha_rule,
+};
+
+use super::{get_pass_fingerprint, hash_file, AnalysisCache};
+
+fn get_piranha_arguments(cache_dir: &str, treated: &str) -> PiranhaArguments {
+  PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![
+      ("stale_flag_name".to_string(), "stale_flag".to_string()),
+      ("treated".to_string(), treated.to_string()),
+    ])
+    .rule_graph(
+      RuleGraphBuilder::default()
+        .rules(vec![piranha_rule! {
+          name = "replace_is_enabled",
+          query = "((call_expression) @call)",
+          replace_node = "call",
+          replace = "@treated",
+          holes = ["treated"]
+        }])
+        .build(),
+    )
+    .cache_dir(cache_dir.to_string())
+    .build()
+}
+
+fn get_rules(piranha_arguments: &PiranhaArguments) -> Vec<InstantiatedRule> {
+  piranha_arguments
+    .rule_graph()
+    .rules()
+    .iter()
+    .map(|r| InstantiatedRule::new(r, &piranha_arguments.input_substitutions()))
+    .collect()
+}
+
+#[test]
+fn test_hash_file() {
+  let path = Path::new("checkout/checkout.go");
+  assert_eq!(
+    hash_file(path, "package checkout\n"),
+    hash_file(path, "package checkout\n")
+  );
+  assert_ne!(
+    hash_file(path, "package checkout\n"),
+    hash_file(path, "package checkout\n\nfunc checkout() {}\n")
+  );
+  assert_ne!(
+    hash_file(path, "package checkout\n"),
+    hash_file(Path::new("search/search.go"), "package checkout\n")
+  );
+}
+
+#[test]
+fn test_get_pass_fingerprint() {
+  let piranha_arguments = get_piranha_arguments("", "true");
+  let rules = get_rules(&piranha_arguments);
+  let fingerprint = get_pass_fingerprint(&rules, &HashMap::new(), &piranha_arguments);
+  assert_eq!(
+    fingerprint,
+    get_pass_fingerprint(&rules, &HashMap::new(), &piranha_arguments)
+  );
+  // The fingerprint depends on the substitutions of the rules, and on the global substitutions
+  let other_arguments = get_piranha_arguments("", "false");
+  assert_ne!(
+    fingerprint,
+    get_pass_fingerprint(
+      &get_rules(&other_arguments),
+      &HashMap::new(),
+      &other_arguments
+    )
+  );
+  assert_ne!(
+    fingerprint,
+    get_pass_fingerprint(
+      &rules,
+      &HashMap::from([("GLOBAL_TAG.flag".to_string(), "isEnabled".to_string())]),
+      &piranha_arguments
+    )
+  );
+}
+
+#[test]
+fn test_skip_clean_files() {
+  let checkout = PathBuf::from("checkout/checkout.go");
+  let search = PathBuf::from("search/search.go");
+  let mut files = HashMap::from([
+    (checkout.clone(), "package checkout\n".to_string()),
+    (search.clone(), "package search\n".to_string()),
+  ]);
+  let mut cache = AnalysisCache {
+    previous: BTreeMap::from([(
+      "pass".to_string(),
+      BTreeSet::from([hash_file(&checkout, "package checkout\n")]),
+    )]),
+    ..Default::default()
+  };
+  let candidates = cache.skip_clean_files("pass", &mut files, &HashMap::new());
+  assert_eq!(files.keys().collect::<Vec<_>>(), vec![&search]);
+  assert_eq!(
+    candidates,
+    HashMap::from([(search.clone(), hash_file(&search, "package search\n"))])
+  );
+  assert_eq!(cache.hits, 1);
+  // The skipped file is kept in the cache for the next run
+  assert!(cache.current["pass"].contains(&hash_file(&checkout, "package checkout\n")));
+
+  // The files are not skipped by another pass
+  let mut files = HashMap::from([(checkout.clone(), "package checkout\n".to_string())]);
+  cache.skip_clean_files("other_pass", &mut files, &HashMap::new());
+  assert_eq!(files.len(), 1);
+}
+
+#[test]
+fn test_persist_and_load() {
+  let temp_dir = TempDir::new("analysis_cache").unwrap();
+  let piranha_arguments = get_piranha_arguments(temp_dir.path().to_str().unwrap(), "true");
+  let mut cache = AnalysisCache::load(&piranha_arguments).unwrap();
+  assert!(cache.previous.is_empty());
+  let checkout = PathBuf::from("checkout/checkout.go");
+  let hash = hash_file(&checkout, "package checkout\n");
+  cache
+    .current
+    .insert("pass".to_string(), BTreeSet::from([hash.clone()]));
+  cache.persist();
+
+  let cache = AnalysisCache::load(&piranha_arguments).unwrap();
+  assert_eq!(
+    cache.previous,
+    BTreeMap::from([("pass".to_string(), BTreeSet::from([hash]))])
+  );
+  // The cache is disabled without a `cache_dir`
+  assert!(AnalysisCache::load(&get_piranha_arguments("", "true")).is_none());
+}
@@ -0,0 +1,97 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{fs, path::Path, process::Command};
+
+use tempdir::TempDir;
+
+use super::{get_changed_files, get_staged_files};
+
+fn git(directory: &Path, args: &[&str]) {
+  let status = Command::new("git")
+    .arg("-C")
+    .arg(directory)
+    .args([
+      "-c",
+      "user.name=piranha",
+      "-c",
+      "user.email=piranha@example.com",
+    ])
+    .args(args)
+    .status()
+    .unwrap();
+  assert!(status.success());
+}
+
+/// Creates a git repository whose `main` branch contains `checkout.go`, `search.go` and `payment.go`,
+/// and whose current branch (i.e. `cleanup`) changes `checkout.go`, deletes `payment.go`,
+/// and has an uncommitted change to `search.go` and an untracked `experiment.go`.
+fn create_repository() -> TempDir {
+  let temp_dir = TempDir::new("changed_files").unwrap();
+  let repository = temp_dir.path();
+  git(repository, &["init", "-q", "-b", "main"]);
+  for file in ["checkout.go", "search.go", "payment.go"] {
+    fs::write(repository.join(file), "package main\n").unwrap();
+  }
+  git(repository, &["add", "-A"]);
+  git(repository, &["commit", "-q", "-m", "Initial commit"]);
+  git(repository, &["checkout", "-q", "-b", "cleanup"]);
+  fs::write(
+    repository.join("checkout.go"),
+    "package main\n\nfunc checkout() {}\n",
+  )
+  .unwrap();
+  fs::remove_file(repository.join("payment.go")).unwrap();
+  git(
+    repository,
+    &["commit", "-q", "-a", "-m", "Clean up checkout"],
+  );
+  fs::write(
+    repository.join("search.go"),
+    "package main\n\nfunc search() {}\n",
+  )
+  .unwrap();
+  fs::write(repository.join("experiment.go"), "package main\n").unwrap();
+  temp_dir
+}
+
+#[test]
+fn test_get_changed_files() {
+  let temp_dir = create_repository();
+  let repository = temp_dir.path().canonicalize().unwrap();
+  let changed_files = get_changed_files(temp_dir.path().to_str().unwrap(), "main").unwrap();
+  assert_eq!(changed_files.len(), 3);
+  for file in ["checkout.go", "search.go", "experiment.go"] {
+    assert!(changed_files.contains(&repository.join(file)));
+  }
+}
+
+#[test]
+fn test_get_changed_files_unknown_ref() {
+  let temp_dir = create_repository();
+  let result = get_changed_files(temp_dir.path().to_str().unwrap(), "origin/unknown");
+  assert!(result
+    .unwrap_err()
+    .contains("git merge-base origin/unknown HEAD"));
+}
+
+#[test]
+fn test_get_staged_files() {
+  let temp_dir = create_repository();
+  let repository = temp_dir.path().canonicalize().unwrap();
+  git(temp_dir.path(), &["add", "search.go"]);
+  let staged_files = get_staged_files(temp_dir.path().to_str().unwrap()).unwrap();
+  // The untracked `experiment.go` is not staged
+  assert_eq!(staged_files.len(), 1);
+  assert!(staged_files.contains(&repository.join("search.go")));
+}
@@ -0,0 +1,114 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use tree_sitter::{Point, Range};
+
+use crate::{
+  models::{
+    default_configs::GO, edit::Edit, language::PiranhaLanguage, matches::Match,
+    piranha_arguments::PiranhaArgumentsBuilder, piranha_output::PiranhaOutputSummary,
+    rule_graph::RuleGraphBuilder, source_code_unit::SourceCodeUnit,
+  },
+  piranha_rule,
+};
+
+use super::get_remaining_flag_usages;
+
+static ORIGINAL_CONTENT: &str = "package main
+
+func run() {
+	enabled := isEnabled(\"stale_flag\")
+	println(enabled)
+}
+";
+
+#[test]
+fn test_get_remaining_flag_usages() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![
+      ("stale_flag_name".to_string(), "stale_flag".to_string()),
+      ("treated".to_string(), "true".to_string()),
+    ])
+    .rule_graph(
+      RuleGraphBuilder::default()
+        .rules(vec![piranha_rule! {
+          name = "replace_is_enabled",
+          query = "((call_expression) @call)",
+          replace_node = "call",
+          replace = "@treated",
+          holes = ["treated"]
+        }])
+        .build(),
+    )
+    .check(true)
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    ORIGINAL_CONTENT.to_string(),
+    &HashMap::new(),
+    PathBuf::from("main.go").as_path(),
+    &piranha_arguments,
+  );
+  let start_byte = ORIGINAL_CONTENT.find("isEnabled").unwrap();
+  let end_byte = start_byte + "isEnabled(\"stale_flag\")".len();
+  let edit = Edit::new(
+    Match::new(
+      ORIGINAL_CONTENT[start_byte..end_byte].to_string(),
+      Range {
+        start_byte,
+        end_byte,
+        start_point: Point::new(3, 12),
+        end_point: Point::new(3, 35),
+      },
+      HashMap::new(),
+    ),
+    "true".to_string(),
+    "replace_is_enabled".to_string(),
+    &ORIGINAL_CONTENT.to_string(),
+  );
+  source_code_unit.rewrites_mut().push(edit);
+  // The rewrites of the other rules (e.g. the cleanup of the declaration) are not usages
+  let declaration_start = ORIGINAL_CONTENT.find("\tenabled").unwrap();
+  let cleanup = Edit::new(
+    Match::new(
+      ORIGINAL_CONTENT[declaration_start..end_byte].to_string(),
+      Range {
+        start_byte: declaration_start,
+        end_byte,
+        start_point: Point::new(3, 0),
+        end_point: Point::new(3, 35),
+      },
+      HashMap::new(),
+    ),
+    String::new(),
+    "delete_variable_declaration".to_string(),
+    &ORIGINAL_CONTENT.to_string(),
+  );
+  source_code_unit.rewrites_mut().push(cleanup);
+
+  let summaries = vec![PiranhaOutputSummary::new(&source_code_unit)];
+  let usages = get_remaining_flag_usages(&summaries, &piranha_arguments);
+
+  assert_eq!(usages.len(), 1);
+  assert_eq!(*usages[0].line(), 4);
+  assert_eq!(*usages[0].column(), 13);
+  assert_eq!(
+    usages[0].to_string(),
+    "main.go:4:13: the stale flag `stale_flag` is still used (matched by `replace_is_enabled`) - isEnabled(\"stale_flag\")"
+  );
+}
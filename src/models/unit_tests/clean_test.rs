@@ -0,0 +1,54 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::BTreeMap;
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+};
+
+use super::validate_clean_input;
+
+fn get_files(paths: &[&str]) -> BTreeMap<String, String> {
+  paths
+    .iter()
+    .map(|p| (p.to_string(), "package main\n".to_string()))
+    .collect()
+}
+
+#[test]
+fn test_validate_clean_input() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  assert!(validate_clean_input(
+    &get_files(&["main.go", "./payments/payments.go"]),
+    &piranha_arguments
+  )
+  .is_ok());
+  for path in ["/tmp/main.go", "../main.go", "payments/../../main.go", ""] {
+    assert!(
+      validate_clean_input(&get_files(&[path]), &piranha_arguments).is_err(),
+      "{path}"
+    );
+  }
+}
+
+#[test]
+fn test_validate_clean_input_unsupported_arguments() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .language(PiranhaLanguage::from(GO))
+    .code_snippet("package main\n".to_string())
+    .build();
+  assert!(validate_clean_input(&get_files(&["main.go"]), &piranha_arguments).is_err());
+}
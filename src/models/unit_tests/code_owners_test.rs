@@ -0,0 +1,125 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::{matches_pattern, CodeOwners};
+
+#[test]
+fn test_matches_pattern() {
+  // Matches at any depth
+  assert!(matches_pattern("*.go", "checkout.go"));
+  assert!(matches_pattern("*.go", "payments/refunds.go"));
+  assert!(matches_pattern("payments", "src/payments/refunds.go"));
+  // Relative to the root of the repository
+  assert!(matches_pattern("/payments/", "payments/refunds.go"));
+  assert!(!matches_pattern("/payments/", "src/payments/refunds.go"));
+  assert!(matches_pattern("src/payments", "src/payments/refunds.go"));
+  assert!(!matches_pattern("src/*.go", "src/payments/refunds.go"));
+  assert!(matches_pattern("src/**/*.go", "src/payments/refunds.go"));
+  // Only directories
+  assert!(!matches_pattern("refunds.go/", "payments/refunds.go"));
+}
+
+#[test]
+fn test_get_owners() {
+  let code_owners = CodeOwners::parse(
+    "# The default owners
+*       @org/platform
+/payments/ @alice @org/payments # The payments team
+/payments/generated/
+*.md    docs@example.com
+",
+  );
+  assert_eq!(
+    code_owners.get_owners("search/search.go"),
+    vec!["@org/platform"]
+  );
+  assert_eq!(
+    code_owners.get_owners("payments/refunds.go"),
+    vec!["@alice", "@org/payments"]
+  );
+  // The last matching pattern wins, even without owners
+  assert!(code_owners
+    .get_owners("payments/generated/refunds.pb.go")
+    .is_empty());
+  assert_eq!(
+    code_owners
+      .get_owners_of_files(["payments/refunds.go", "search/search.go", "README.md"].into_iter()),
+    vec![
+      "@alice",
+      "@org/payments",
+      "@org/platform",
+      "docs@example.com"
+    ]
+  );
+}
+
+#[test]
+fn test_get_owners_from_owners_files() {
+  let mut code_owners = CodeOwners::parse("/search/ @org/search\n");
+  // In the format of Chromium
+  code_owners.add_owners_file(
+    "",
+    "# The default owners
+set noparent
+platform@example.com
+per-file *.md=docs@example.com
+file://build/OWNERS
+",
+  );
+  code_owners.add_owners_file(
+    "payments",
+    "alice@example.com\nper-file refunds*.go=bob@example.com,carol@example.com\n",
+  );
+  // In the format of Kubernetes
+  code_owners.add_owners_file(
+    "payments/wallet",
+    "approvers:
+  - dave
+  - '@erin' # Already a GitHub user
+reviewers:
+  - frank
+labels:
+  - sig/payments
+options:
+  no_parent_owners: true
+",
+  );
+  code_owners.add_owners_file("checkout", "reviewers:\n  - grace\n");
+  // A pattern of the `CODEOWNERS` file wins over the `OWNERS` files
+  assert_eq!(
+    code_owners.get_owners("search/search.go"),
+    vec!["@org/search"]
+  );
+  assert_eq!(
+    code_owners.get_owners("main.go"),
+    vec!["platform@example.com"]
+  );
+  assert_eq!(
+    code_owners.get_owners("README.md"),
+    vec!["docs@example.com", "platform@example.com"]
+  );
+  // The nearest `OWNERS` file wins
+  assert_eq!(
+    code_owners.get_owners("payments/api/client.go"),
+    vec!["alice@example.com"]
+  );
+  assert_eq!(
+    code_owners.get_owners("payments/refunds_v2.go"),
+    vec!["bob@example.com", "carol@example.com", "alice@example.com"]
+  );
+  assert_eq!(
+    code_owners.get_owners("payments/wallet/wallet.go"),
+    vec!["@dave", "@erin"]
+  );
+  assert_eq!(code_owners.get_owners("checkout/cart.go"), vec!["@grace"]);
+}
@@ -0,0 +1,133 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use tree_sitter::{Point, Range};
+
+use crate::models::{
+  default_configs::GO, edit::Edit, language::PiranhaLanguage, matches::Match,
+  piranha_arguments::PiranhaArgumentsBuilder, source_code_unit::SourceCodeUnit,
+};
+
+use super::{get_confidence_rank, VERIFY_LOW_CONFIDENCE_EDIT};
+
+static CODE: &str = "package main
+
+func run() {
+	// keep the old flow
+	if isNewFlowEnabled() {
+		newFlow()
+	}
+}
+";
+
+/// Returns the match of the `if` statement of `CODE`
+fn get_if_statement_match(code: &str) -> Match {
+  let start_byte = code.find("if isNewFlowEnabled").unwrap();
+  let end_byte = code.rfind("\t}").unwrap() + 2;
+  let row = code[..start_byte].matches('\n').count();
+  Match::new(
+    code[start_byte..end_byte].to_string(),
+    Range {
+      start_byte,
+      end_byte,
+      start_point: Point::new(row, 1),
+      end_point: Point::new(row + 2, 2),
+    },
+    HashMap::new(),
+  )
+}
+
+#[test]
+fn test_get_confidence_rank() {
+  assert!(get_confidence_rank("low").unwrap() < get_confidence_rank("medium").unwrap());
+  assert!(get_confidence_rank("medium").unwrap() < get_confidence_rank("high").unwrap());
+  assert!(get_confidence_rank("certain").is_err());
+}
+
+#[test]
+fn test_annotate_for_verification() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![
+      ("stale_flag_name".to_string(), "new_flow".to_string()),
+      ("treated".to_string(), "true".to_string()),
+    ])
+    .confidence_threshold("high".to_string())
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    CODE.to_string(),
+    &HashMap::new(),
+    PathBuf::from("main.go").as_path(),
+    &piranha_arguments,
+  );
+  assert!(!source_code_unit.is_annotated_for_verification(&get_if_statement_match(CODE)));
+
+  // The match of the statement, expanded to its associated comments (see `expand_to_associated_comments`)
+  let if_statement = get_if_statement_match(CODE);
+  let start_byte = CODE.find("// keep").unwrap();
+  let range = if_statement.range();
+  let expanded = Match::new(
+    CODE[start_byte..range.end_byte].to_string(),
+    Range {
+      start_byte,
+      start_point: Point::new(range.start_point.row - 1, 1),
+      ..range
+    },
+    HashMap::new(),
+  );
+  let edit = Edit::new(
+    expanded,
+    "newFlow()".to_string(),
+    "simplify_if_statement_true".to_string(),
+    &CODE.to_string(),
+  );
+  source_code_unit.annotate_for_verification(&edit, &mut parser);
+
+  let expected = CODE.replace(
+    "\t// keep the old flow",
+    "\t// PIRANHA: verify removal of new_flow\n\t// keep the old flow",
+  );
+  assert_eq!(source_code_unit.code(), &expected);
+  assert_eq!(
+    source_code_unit.rewrites().last().unwrap().matched_rule(),
+    VERIFY_LOW_CONFIDENCE_EDIT
+  );
+  // The annotation precedes the comments associated with the statement
+  assert!(source_code_unit.is_annotated_for_verification(&get_if_statement_match(&expected)));
+}
+
+#[test]
+fn test_is_low_confidence() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .confidence_threshold("high".to_string())
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    CODE.to_string(),
+    &HashMap::new(),
+    PathBuf::from("main.go").as_path(),
+    &piranha_arguments,
+  );
+  assert!(source_code_unit.is_low_confidence("replace_call_to_function_returning_boolean_literal"));
+  assert!(!source_code_unit.is_low_confidence("simplify_if_statement_true"));
+  // The pseudo rules are always applied
+  assert!(!source_code_unit.is_low_confidence(VERIFY_LOW_CONFIDENCE_EDIT));
+}
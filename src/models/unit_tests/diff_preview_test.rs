@@ -0,0 +1,74 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::{format_cell, is_color_enabled, render_side_by_side};
+
+static ORIGINAL_CONTENT: &str = "package main
+
+func run() {
+	cleanup()
+	println(\"old\")
+	println(\"done\")
+}
+";
+
+static REWRITTEN_CONTENT: &str = "package main
+
+func run() {
+	cleanup()
+	println(\"done\")
+}
+";
+
+/// The deleted line has no counterpart in the right column, and the hunk is printed along with its context
+#[test]
+fn test_render_side_by_side() {
+  let rendered = render_side_by_side("main.go", ORIGINAL_CONTENT, REWRITTEN_CONTENT, false, 60);
+  let row = |left: &str, right: &str| format!("{left:<28} │ {right:<28}");
+  let expected = vec![
+    "main.go".to_string(),
+    "@@ -2,6 +2,5 @@".to_string(),
+    row("   2 ", "   2 "),
+    row("   3 func run() {", "   3 func run() {"),
+    row("   4     cleanup()", "   4     cleanup()"),
+    row("   5     println(\"old\")", ""),
+    row("   6     println(\"done\")", "   5     println(\"done\")"),
+    row("   7 }", "   6 }"),
+  ];
+  assert_eq!(rendered.lines().collect::<Vec<&str>>(), expected);
+}
+
+#[test]
+fn test_render_side_by_side_unchanged() {
+  assert!(render_side_by_side("main.go", ORIGINAL_CONTENT, ORIGINAL_CONTENT, false, 60).is_empty());
+}
+
+/// The lines wider than the column are truncated, and the narrower ones padded
+#[test]
+fn test_format_cell() {
+  assert_eq!(
+    format_cell(12, "\tprintln(\"a very long line\")", 20),
+    "  12     println(\"a…"
+  );
+  assert_eq!(format_cell(1, "}", 10), "   1 }    ");
+}
+
+#[test]
+fn test_is_color_enabled() {
+  assert!(is_color_enabled("always", true, false));
+  assert!(!is_color_enabled("never", false, true));
+  assert!(is_color_enabled("auto", false, true));
+  // `NO_COLOR` is respected, and the output piped to another program is not colored
+  assert!(!is_color_enabled("auto", true, true));
+  assert!(!is_color_enabled("auto", false, false));
+}
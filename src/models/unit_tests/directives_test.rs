@@ -0,0 +1,70 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::{is_anchored_directive, restore_build_constraints};
+
+static ORIGINAL_CONTENT: &str = "//go:build linux && !race
+
+// Package sample is only built on linux
+package sample
+
+var enabled = isEnabled(\"stale_flag\")
+";
+
+#[test]
+fn test_is_anchored_directive() {
+  assert!(is_anchored_directive("//go:build linux"));
+  assert!(is_anchored_directive("// +build linux"));
+  assert!(is_anchored_directive("//go:generate stringer -type=Mode"));
+  assert!(is_anchored_directive("//line sample.tmpl:12"));
+  assert!(!is_anchored_directive("//go:noinline"));
+  assert!(!is_anchored_directive("// go:generate is not a directive"));
+}
+
+/// The blank line separating the build constraint from the package comment is restored
+#[test]
+fn test_restore_build_constraints() {
+  let content = "//go:build linux && !race
+// Package sample is only built on linux
+package sample
+
+var enabled = true
+";
+  assert_eq!(
+    restore_build_constraints(ORIGINAL_CONTENT, content),
+    Some(
+      "//go:build linux && !race
+
+// Package sample is only built on linux
+package sample
+
+var enabled = true
+"
+      .to_string()
+    )
+  );
+}
+
+#[test]
+fn test_restore_build_constraints_unchanged() {
+  let content = ORIGINAL_CONTENT.replace("isEnabled(\"stale_flag\")", "true");
+  assert_eq!(restore_build_constraints(ORIGINAL_CONTENT, &content), None);
+  // The header of a file without build constraints is left as it is
+  let original_content = "// Package sample\npackage sample\n";
+  assert_eq!(
+    restore_build_constraints(original_content, "package sample\n"),
+    None
+  );
+  // The emptied files have no header
+  assert_eq!(restore_build_constraints(ORIGINAL_CONTENT, ""), None);
+}
@@ -0,0 +1,79 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, source_code_unit::SourceCodeUnit,
+};
+
+use super::{may_build_flag_name, DYNAMIC_FLAG_NAME};
+
+#[test]
+fn test_report_dynamic_flag_names() {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let mut source_code_unit = SourceCodeUnit::default(
+    "package main
+
+    func run(suffix string, checkoutSuffix string, name string) {
+      exp.BoolValue(fmt.Sprintf(\"stale_%s\", suffix))
+      exp.BoolValue(\"stale_\" + suffix)
+      exp.BoolValue(prefix + \"_\" + checkoutSuffix)
+      exp.BoolValue(\"stale_checkout\")
+      fmt.Println(fmt.Sprintf(\"Hello %s\", name))
+      fmt.Println(\"Hello \" + name)
+      fmt.Println(\"_\" + name)
+    }",
+    &mut parser,
+    GO.to_string(),
+  );
+  source_code_unit.report_dynamic_flag_names("stale_checkout");
+  let mut reported: Vec<&str> = source_code_unit
+    .matches()
+    .iter()
+    .map(|(rule, m)| {
+      assert_eq!(rule, DYNAMIC_FLAG_NAME);
+      m.matched_string().as_str()
+    })
+    .collect();
+  reported.sort();
+  assert_eq!(
+    reported,
+    vec![
+      "\"stale_\" + suffix",
+      "fmt.Sprintf(\"stale_%s\", suffix)",
+      "prefix + \"_\" + checkoutSuffix",
+    ]
+  );
+}
+
+#[test]
+fn test_may_build_flag_name() {
+  assert!(may_build_flag_name(
+    "fmt.Sprintf(\"stale_%s\", suffix)",
+    "stale_checkout"
+  ));
+  // The words of the identifiers are matched regardless of their case
+  assert!(may_build_flag_name(
+    "prefix + \"_\" + checkoutSuffix",
+    "STALE_CHECKOUT"
+  ));
+  // Neither a call to `Sprintf`, nor a concatenation
+  assert!(!may_build_flag_name(
+    "exp.BoolValue(\"stale_checkout\")",
+    "stale_checkout"
+  ));
+  // No fragment of the flag name
+  assert!(!may_build_flag_name(
+    "fmt.Sprintf(\"new_%s\", suffix)",
+    "stale_checkout"
+  ));
+}
@@ -0,0 +1,73 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::restore_encoding;
+
+static ORIGINAL_CONTENT: &str = "package main\r\n\r\nfunc run() {\r\n\tif isEnabled(\"stale_flag\") {\r\n\t\tprintln(\"new\")\r\n\t}\r\n}\r\n";
+
+/// The lines edited with LF line endings get the CRLF line endings of the file
+#[test]
+fn test_restore_encoding_crlf() {
+  let content = "package main\r\n\r\nfunc run() {\n\tprintln(\"new\")\n}\r\n";
+  assert_eq!(
+    restore_encoding(ORIGINAL_CONTENT, content),
+    "package main\r\n\r\nfunc run() {\r\n\tprintln(\"new\")\r\n}\r\n"
+  );
+}
+
+/// A content whose line endings were all normalized to LF (e.g. by a formatter) is only changed on its edited lines
+#[test]
+fn test_restore_encoding_normalized() {
+  let content = ORIGINAL_CONTENT.replace("\r\n", "\n").replace(
+    "\tif isEnabled(\"stale_flag\") {\n\t\tprintln(\"new\")\n\t}\n",
+    "\tprintln(\"new\")\n",
+  );
+  assert_eq!(
+    restore_encoding(ORIGINAL_CONTENT, &content),
+    "package main\r\n\r\nfunc run() {\r\n\tprintln(\"new\")\r\n}\r\n"
+  );
+}
+
+/// The lines left untouched keep their own line endings
+#[test]
+fn test_restore_encoding_mixed() {
+  let original_content =
+    "package main\n\nvar a = isEnabled(\"stale_flag\")\r\nvar b = 1\r\nvar c = 2\r\n";
+  let content = "package main\n\nvar a = true\nvar b = 1\r\nvar c = 2\r\n";
+  assert_eq!(
+    restore_encoding(original_content, content),
+    "package main\n\nvar a = true\r\nvar b = 1\r\nvar c = 2\r\n"
+  );
+}
+
+#[test]
+fn test_restore_encoding_lf() {
+  let original_content = "package main\n\nvar a = isEnabled(\"stale_flag\")\n";
+  let content = "package main\n\nvar a = true\n";
+  assert_eq!(restore_encoding(original_content, content), content);
+}
+
+/// The BOM of the file is restored, unless nothing but the BOM is left
+#[test]
+fn test_restore_encoding_bom() {
+  let original_content = "\u{feff}package main\n\nvar a = isEnabled(\"stale_flag\")\n";
+  assert_eq!(
+    restore_encoding(original_content, "package main\n\nvar a = true\n"),
+    "\u{feff}package main\n\nvar a = true\n"
+  );
+  assert_eq!(
+    restore_encoding(original_content, "\u{feff}package main\n\nvar a = true\n"),
+    "\u{feff}package main\n\nvar a = true\n"
+  );
+  assert_eq!(restore_encoding(original_content, "\u{feff}"), "");
+}
@@ -0,0 +1,25 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::parse_explain_position;
+
+#[test]
+fn test_parse_explain_position() {
+  assert_eq!(
+    parse_explain_position("path/file.go:42"),
+    Ok(("path/file.go".to_string(), 42))
+  );
+  assert!(parse_explain_position("path/file.go").is_err());
+  assert!(parse_explain_position("path/file.go:0").is_err());
+  assert!(parse_explain_position(":42").is_err());
+}
@@ -0,0 +1,152 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use crate::{
+  models::{
+    default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+    rule::InstantiatedRule, source_code_unit::SourceCodeUnit,
+  },
+  piranha_rule,
+};
+
+static CHECKOUT: &str = "package checkout
+
+  var useNewPath = true
+
+  func isEnabled() bool {
+    return true
+  }
+
+  func IsNewCheckoutEnabled() bool {
+    return true
+  }";
+
+static CHECKOUT_TEST: &str = "package checkout_test
+
+  import (
+    \"testing\"
+
+    \"example.com/checkout\"
+  )
+
+  func isEnabled() bool {
+    return checkout.IsNewCheckoutEnabled()
+  }";
+
+fn get_source_code_unit(content: &str, path: &str) -> SourceCodeUnit {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  SourceCodeUnit::new(
+    &mut parser,
+    content.to_string(),
+    &HashMap::new(),
+    PathBuf::from(path).as_path(),
+    &piranha_arguments,
+  )
+}
+
+fn get_substitutions(substitutions: &[(&str, &str)]) -> HashMap<String, String> {
+  substitutions
+    .iter()
+    .map(|(k, v)| (k.to_string(), v.to_string()))
+    .collect()
+}
+
+fn get_wrapper_rule(substitutions: &[(&str, &str)]) -> InstantiatedRule {
+  let rule = piranha_rule! {
+    name= "replace_call_to_wrapper",
+    query= "((call_expression function: (_) @function_name) @call_expression (#eq? @function_name \"@wrapper_name\"))",
+    replace_node= "call_expression",
+    replace= "@wrapper_value",
+    holes= ["wrapper_name" "wrapper_value"]
+  };
+  InstantiatedRule::new(&rule, &get_substitutions(substitutions))
+}
+
+#[test]
+fn test_restrict_to_package() {
+  let checkout = get_source_code_unit(CHECKOUT, "checkout/checkout.go");
+  let checkout_test = get_source_code_unit(CHECKOUT_TEST, "checkout/checkout_test.go");
+
+  // `isEnabled` is declared by `checkout`, thus the rule is restricted to its files
+  let rule = checkout.restrict_to_package(&get_wrapper_rule(&[
+    ("wrapper_name", "isEnabled"),
+    ("wrapper_value", "true"),
+  ]));
+  assert_eq!(
+    rule.package(),
+    &Some((PathBuf::from("checkout"), "checkout".to_string()))
+  );
+  assert!(!checkout.is_restricted_to_another_package(&rule));
+  assert!(checkout_test.is_restricted_to_another_package(&rule));
+
+  // The files of the other directories are not restricted
+  let other = get_source_code_unit(CHECKOUT_TEST, "payment/payment_test.go");
+  assert!(!other.is_restricted_to_another_package(&rule));
+}
+
+#[test]
+fn test_restrict_to_package_qualified_reference() {
+  let checkout = get_source_code_unit(CHECKOUT, "checkout/checkout.go");
+  let rule = piranha_rule! {
+    name= "replace_qualified_call_to_wrapper",
+    query= "((call_expression
+        function: (selector_expression
+          operand: (identifier) @package_name
+          field: (field_identifier) @function_name)) @call_expression
+      (#eq? @package_name \"@wrapper_package\")
+      (#eq? @function_name \"@wrapper_name\"))",
+    replace_node= "call_expression",
+    replace= "@wrapper_value",
+    holes= ["wrapper_package" "wrapper_name" "wrapper_value"]
+  };
+  // The rule references the package by its name (i.e. `checkout.IsNewCheckoutEnabled()`), thus it is not restricted
+  let rule = checkout.restrict_to_package(&InstantiatedRule::new(
+    &rule,
+    &get_substitutions(&[
+      ("wrapper_package", "checkout"),
+      ("wrapper_name", "IsNewCheckoutEnabled"),
+      ("wrapper_value", "true"),
+    ]),
+  ));
+  assert_eq!(rule.package(), &None);
+}
+
+#[test]
+fn test_restrict_to_package_undeclared_name() {
+  let checkout = get_source_code_unit(CHECKOUT, "checkout/checkout.go");
+  // `isDisabled` is not declared by this file (e.g. a field, or a function of another package)
+  let rule = checkout.restrict_to_package(&get_wrapper_rule(&[
+    ("wrapper_name", "isDisabled"),
+    ("wrapper_value", "false"),
+  ]));
+  assert_eq!(rule.package(), &None);
+}
+
+#[test]
+fn test_restrict_to_external_test_package() {
+  let checkout = get_source_code_unit(CHECKOUT, "checkout/checkout.go");
+  let checkout_test = get_source_code_unit(CHECKOUT_TEST, "checkout/checkout_test.go");
+  // `isEnabled` of `checkout_test` is not the one of `checkout`
+  let rule = checkout_test.restrict_to_package(&get_wrapper_rule(&[
+    ("wrapper_name", "isEnabled"),
+    ("wrapper_value", "true"),
+  ]));
+  assert!(checkout.is_restricted_to_another_package(&rule));
+  assert!(!checkout_test.is_restricted_to_another_package(&rule));
+}
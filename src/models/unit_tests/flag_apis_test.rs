@@ -0,0 +1,308 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::{HashMap, HashSet};
+
+use crate::{
+  models::{
+    default_configs::GO,
+    language::PiranhaLanguage,
+    rule::{InstantiatedRule, Rule},
+  },
+  utilities::{eq_without_whitespace, parse_toml},
+};
+
+use super::FlagApis;
+
+fn get_rules(flag_apis: &str) -> Vec<Rule> {
+  parse_toml::<FlagApis>(flag_apis).rules()
+}
+
+#[test]
+fn test_flag_api_rule_package_function() {
+  let rules = get_rules(
+    r#"
+    [[flag_apis]]
+    package = "github.com/company/exp/v2"
+    function = "IsEnabled"
+    flag_argument_index = 1
+    "#,
+  );
+  assert_eq!(rules.len(), 1);
+  let rule = &rules[0];
+  assert_eq!(rule.name(), "flag_api_exp_IsEnabled");
+  assert_eq!(rule.replace_node(), "flag_api_call");
+  assert_eq!(rule.replace(), "@treated");
+  assert_eq!(
+    rule.holes(),
+    &HashSet::from(["stale_flag_name".to_string(), "treated".to_string()])
+  );
+  assert!(rule
+    .groups()
+    .contains("replace_expression_with_boolean_literal"));
+  assert!(eq_without_whitespace(
+    &rule.query().get_query(),
+    r#"(
+    (call_expression
+        function: (selector_expression
+            operand: (_) @flag_api_package
+            field: (field_identifier) @flag_api_function
+        )
+        arguments: (argument_list
+            .
+            (_)
+            .
+            (interpreted_string_literal) @flag_api_flag_name
+        )
+    ) @flag_api_call
+    (#eq? @flag_api_function "IsEnabled")
+    (#eq? @flag_api_package "exp")
+    (#eq? @flag_api_flag_name "\"@stale_flag_name\"")
+)"#
+  ));
+}
+
+#[test]
+fn test_flag_api_rule_method() {
+  let rules = get_rules(
+    r#"
+    [[flag_apis]]
+    name = "replace_is_disabled"
+    function = "IsDisabled"
+    argument_count = 1
+    semantics = "control"
+
+    [[flag_apis]]
+    function = "Variant"
+    return_type = "string"
+
+    [[flag_apis]]
+    function = "Rollout"
+    return_type = "float"
+    "#,
+  );
+  assert_eq!(
+    rules
+      .iter()
+      .map(|r| (r.name().as_str(), r.replace().as_str()))
+      .collect::<Vec<_>>(),
+    vec![
+      ("replace_is_disabled", "@treated_complement"),
+      ("flag_api_Variant", "\"@treatment\""),
+      ("flag_api_Rollout", "@rollout_fraction"),
+    ]
+  );
+  assert!(eq_without_whitespace(
+    &rules[0].query().get_query(),
+    r#"(
+    (call_expression
+        function: (selector_expression
+            operand: (_)
+            field: (field_identifier) @flag_api_function
+        )
+        arguments: (argument_list
+            .
+            (interpreted_string_literal) @flag_api_flag_name
+            .
+        )
+    ) @flag_api_call
+    (#eq? @flag_api_function "IsDisabled")
+    (#eq? @flag_api_flag_name "\"@stale_flag_name\"")
+)"#
+  ));
+}
+
+#[test]
+fn test_flag_api_rule_flag_field_and_option() {
+  let rules = get_rules(
+    r#"
+    [[flag_apis]]
+    package = "github.com/company/exp"
+    function = "Bool"
+    flag_field = "Flag"
+
+    [[flag_apis]]
+    function = "Enabled"
+    flag_argument_index = 1
+    argument_count = 2
+    flag_option = "WithFlag"
+    "#,
+  );
+  assert_eq!(rules.len(), 2);
+  assert!(eq_without_whitespace(
+    &rules[0].query().get_query(),
+    r#"(
+    (call_expression
+        function: (selector_expression
+            operand: (_) @flag_api_package
+            field: (field_identifier) @flag_api_function
+        )
+        arguments: (argument_list
+            .
+            [
+                (composite_literal
+                    body: (literal_value
+                        (keyed_element
+                            .
+                            (field_identifier) @flag_api_flag_field
+                            .
+                            (interpreted_string_literal) @flag_api_flag_name
+                            .
+                        )
+                    )
+                )
+                (unary_expression
+                    operand: (composite_literal
+                        body: (literal_value
+                            (keyed_element
+                                .
+                                (field_identifier) @flag_api_flag_field
+                                .
+                                (interpreted_string_literal) @flag_api_flag_name
+                                .
+                            )
+                        )
+                    )
+                )
+            ]
+        )
+    ) @flag_api_call
+    (#eq? @flag_api_function "Bool")
+    (#eq? @flag_api_package "exp")
+    (#eq? @flag_api_flag_field "Flag")
+    (#eq? @flag_api_flag_name "\"@stale_flag_name\"")
+)"#
+  ));
+  // The option is matched at any position, regardless of the `flag_argument_index` and the `argument_count`
+  assert!(eq_without_whitespace(
+    &rules[1].query().get_query(),
+    r#"(
+    (call_expression
+        function: (selector_expression
+            operand: (_)
+            field: (field_identifier) @flag_api_function
+        )
+        arguments: (argument_list
+            (call_expression
+                function: [
+                    (identifier) @flag_api_option
+                    (selector_expression
+                        field: (field_identifier) @flag_api_option
+                    )
+                ]
+                arguments: (argument_list
+                    .
+                    (interpreted_string_literal) @flag_api_flag_name
+                    .
+                )
+            )
+        )
+    ) @flag_api_call
+    (#eq? @flag_api_function "Enabled")
+    (#eq? @flag_api_option "WithFlag")
+    (#eq? @flag_api_flag_name "\"@stale_flag_name\"")
+)"#
+  ));
+}
+
+#[test]
+fn test_flag_api_operand_rules() {
+  let flag_apis = parse_toml::<FlagApis>(
+    r#"
+    [[flag_apis]]
+    package = "github.com/company/exp"
+    function = "IsEnabled"
+    flag_argument_index = 1
+
+    [[flag_apis]]
+    function = "Variant"
+    return_type = "string"
+    "#,
+  );
+  let rules = flag_apis.operand_rules();
+  // No operand rules for the string API
+  assert_eq!(
+    rules
+      .iter()
+      .map(|r| (r.name().as_str(), r.replace().as_str()))
+      .collect::<Vec<_>>(),
+    vec![
+      ("flag_api_exp_IsEnabled_and_false", "false"),
+      ("flag_api_exp_IsEnabled_or_true", "true"),
+    ]
+  );
+  assert!(rules.iter().all(|r| !*r.is_seed_rule()
+    && r.holes().is_empty()
+    && r.groups().contains("boolean_expression_simplify")));
+  assert!(eq_without_whitespace(
+    &rules[0].query().get_query(),
+    r#"(
+    (binary_expression
+        left: (call_expression
+            function: (selector_expression
+                operand: (_) @flag_api_package
+                field: (field_identifier) @flag_api_function
+            )
+            arguments: (argument_list
+                .
+                (_)
+                .
+                (interpreted_string_literal) @flag_api_flag_name
+            )
+        )
+        operator: "&&"
+        right: [(false) (parenthesized_expression (false))]
+    ) @binary_expression
+    (#eq? @flag_api_function "IsEnabled")
+    (#eq? @flag_api_package "exp")
+)"#
+  ));
+}
+
+/// The generated queries are valid once the holes are filled
+#[test]
+fn test_flag_api_rule_valid_query() {
+  let piranha_language = PiranhaLanguage::from(GO);
+  let substitutions = HashMap::from([
+    ("stale_flag_name".to_string(), "stale_flag".to_string()),
+    ("treated".to_string(), "true".to_string()),
+    ("treated_complement".to_string(), "false".to_string()),
+    ("treatment".to_string(), "dark".to_string()),
+  ]);
+  let flag_apis = parse_toml::<FlagApis>(
+    r#"
+    [[flag_apis]]
+    package = "github.com/company/exp"
+    function = "Variant"
+    flag_argument_index = 2
+    argument_count = 4
+    return_type = "string"
+
+    [[flag_apis]]
+    function = "IsDisabled"
+    semantics = "control"
+
+    [[flag_apis]]
+    function = "Bool"
+    flag_field = "Flag"
+
+    [[flag_apis]]
+    function = "Enabled"
+    flag_option = "WithFlag"
+    "#,
+  );
+  for rule in [flag_apis.rules(), flag_apis.operand_rules()].concat() {
+    let instantiated_rule = InstantiatedRule::new(&rule, &substitutions);
+    piranha_language.create_query(instantiated_rule.query().get_query());
+  }
+}
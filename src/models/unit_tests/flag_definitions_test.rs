@@ -0,0 +1,112 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::path::PathBuf;
+
+use super::{FlagDefinitionFile, DELETE_FLAG_DEFINITION};
+
+fn delete_flag(file_name: &str, content: &str) -> FlagDefinitionFile {
+  let mut file = FlagDefinitionFile::new(PathBuf::from(file_name), content.to_string());
+  file.delete_flag("stale_flag");
+  file
+}
+
+#[test]
+fn test_delete_flag_keyed_yaml_stanza() {
+  let file = delete_flag(
+    "flags.yaml",
+    r#"flags:
+  # The new checkout flow
+  stale_flag:
+    owner: checkout
+    default: false
+
+  live_flag:
+    owner: search
+"#,
+  );
+  assert_eq!(
+    file.content(),
+    r#"flags:
+
+  live_flag:
+    owner: search
+"#
+  );
+  assert_eq!(file.rewrites().len(), 1);
+  assert_eq!(file.rewrites()[0].matched_rule(), DELETE_FLAG_DEFINITION);
+}
+
+#[test]
+fn test_delete_flag_yaml_list_items() {
+  let file = delete_flag(
+    "flags.yml",
+    r#"enabled:
+  - live_flag
+  - stale_flag
+rollouts:
+  - owner: checkout
+    name: stale_flag
+    percentage: 100
+  - name: live_flag
+"#,
+  );
+  assert_eq!(
+    file.content(),
+    r#"enabled:
+  - live_flag
+rollouts:
+  - name: live_flag
+"#
+  );
+  assert_eq!(file.rewrites().len(), 2);
+}
+
+#[test]
+fn test_delete_flag_json_stanzas() {
+  let file = delete_flag(
+    "flags.json",
+    r#"{
+  "flags": {
+    "live_flag": {"default": true},
+    "stale_flag": {"default": false}
+  },
+  "rollouts": [{"name": "live_flag"}, {"name": "stale_flag", "percentage": 100}],
+  "enabled": ["stale_flag"]
+}
+"#,
+  );
+  assert_eq!(
+    file.content(),
+    r#"{
+  "flags": {
+    "live_flag": {"default": true}
+  },
+  "rollouts": [{"name": "live_flag"}],
+  "enabled": []
+}
+"#
+  );
+  assert_eq!(file.rewrites().len(), 3);
+}
+
+#[test]
+fn test_delete_flag_unsupported_files() {
+  // Invalid JSON
+  let file = delete_flag("flags.json", r#"{"stale_flag": true"#);
+  assert!(file.rewrites().is_empty());
+  // Unsupported format
+  let file = delete_flag("flags.toml", "stale_flag = true\n");
+  assert!(file.rewrites().is_empty());
+  assert_eq!(file.content(), file.original_content());
+}
@@ -0,0 +1,117 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::{is_substitute_only, parse_csv_flag_file, FlagEntry};
+
+fn substitutions(pairs: &[(&str, &str)]) -> Vec<(String, String)> {
+  pairs
+    .iter()
+    .map(|(k, v)| (k.to_string(), v.to_string()))
+    .collect()
+}
+
+#[test]
+fn test_json_flag_file() {
+  let entries: Vec<FlagEntry> = serde_json::from_str(
+    r#"[
+      {"name": "flag_a", "treated": true},
+      {"name": "flag_b", "treated": "false", "paths": ["checkout/**"]},
+      {"name": "flag_c", "treatment": "dark"}
+    ]"#,
+  )
+  .unwrap();
+  assert_eq!(
+    entries
+      .iter()
+      .map(|e| e.substitutions())
+      .collect::<Vec<_>>(),
+    vec![
+      substitutions(&[
+        ("stale_flag_name", "flag_a"),
+        ("treated_complement", "false"),
+        ("treated", "true")
+      ]),
+      substitutions(&[
+        ("stale_flag_name", "flag_b"),
+        ("treated_complement", "true"),
+        ("treated", "false")
+      ]),
+      substitutions(&[("stale_flag_name", "flag_c"), ("treatment", "dark")]),
+    ]
+  );
+  assert_eq!(entries[1].paths, vec!["checkout/**".to_string()]);
+  assert!(entries[0].paths.is_empty());
+}
+
+#[test]
+fn test_csv_flag_file() {
+  let entries = parse_csv_flag_file(
+    "name,treated,paths
+    # exported nightly
+    flag_a,TRUE,
+    flag_b,false,checkout/**;search/**
+    flag_c,,",
+  )
+  .unwrap();
+  assert_eq!(
+    entries
+      .iter()
+      .map(|e| e.substitutions())
+      .collect::<Vec<_>>(),
+    vec![
+      substitutions(&[
+        ("stale_flag_name", "flag_a"),
+        ("treated_complement", "false"),
+        ("treated", "true")
+      ]),
+      substitutions(&[
+        ("stale_flag_name", "flag_b"),
+        ("treated_complement", "true"),
+        ("treated", "false")
+      ]),
+      substitutions(&[("stale_flag_name", "flag_c")]),
+    ]
+  );
+  assert_eq!(
+    entries[1].paths,
+    vec!["checkout/**".to_string(), "search/**".to_string()]
+  );
+}
+
+#[test]
+fn test_csv_flag_file_missing_name() {
+  assert!(parse_csv_flag_file("flag,treated\nflag_a,true").is_err());
+  assert!(parse_csv_flag_file("name,treated\n,true").is_err());
+}
+
+#[test]
+fn test_flag_file_mode() {
+  let entries: Vec<FlagEntry> = serde_json::from_str(
+    r#"[
+      {"name": "flag_a", "treated": true, "mode": "substitute-only"},
+      {"name": "flag_b", "treated": true, "mode": "cleanup"},
+      {"name": "flag_c", "treated": true}
+    ]"#,
+  )
+  .unwrap();
+  assert_eq!(
+    entries
+      .iter()
+      .map(|e| e.substitute_only())
+      .collect::<Vec<_>>(),
+    vec![Some(true), Some(false), None]
+  );
+  let entries = parse_csv_flag_file("name,treated,mode\nflag_a,true,substitute-only").unwrap();
+  assert_eq!(entries[0].substitute_only(), Some(true));
+  assert!(is_substitute_only("keep").is_err());
+}
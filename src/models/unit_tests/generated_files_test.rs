@@ -0,0 +1,54 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::is_generated;
+
+#[test]
+fn test_is_generated() {
+  assert!(is_generated(
+    "// Code generated by MockGen. DO NOT EDIT.
+// Source: client.go
+
+package mocks"
+  ));
+  assert!(is_generated(
+    "//go:build linux\r
+\r
+// Code generated by \"stringer -type=Flag\"; DO NOT EDIT.\r
+\r
+package flags"
+  ));
+}
+
+#[test]
+fn test_is_generated_negative() {
+  // Not a line comment
+  assert!(!is_generated(
+    "/* Code generated by protoc-gen-go. DO NOT EDIT. */
+
+package pb"
+  ));
+  // After the package clause
+  assert!(!is_generated(
+    "package flags
+
+// Code generated by hand. DO NOT EDIT.
+func isEnabled() bool { return true }"
+  ));
+  // Not the standard header
+  assert!(!is_generated(
+    "// Code generated by hand, feel free to edit.
+
+package flags"
+  ));
+}
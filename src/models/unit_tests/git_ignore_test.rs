@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{fs, path::Path};
+
+use tempdir::TempDir;
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+  rule_store::RuleStore,
+};
+
+use super::{parse_git_ignore, GitIgnore};
+
+fn get_git_ignore(content: &str) -> GitIgnore {
+  GitIgnore {
+    root: Path::new("repo").to_path_buf(),
+    canonical_root: Path::new("/repo").to_path_buf(),
+    rules: parse_git_ignore(content, Path::new("/repo")),
+  }
+}
+
+#[test]
+fn test_is_ignored() {
+  let git_ignore = get_git_ignore(
+    "# The build outputs
+bin/
+/dist
+*.pb.go
+!keep.pb.go
+",
+  );
+  assert!(git_ignore.is_ignored(Path::new("repo/bin"), true));
+  assert!(git_ignore.is_ignored(Path::new("repo/services/checkout/bin"), true));
+  // Only the directories match a trailing `/`
+  assert!(!git_ignore.is_ignored(Path::new("repo/services/bin"), false));
+  assert!(git_ignore.is_ignored(Path::new("repo/dist"), true));
+  // Anchored at the directory of the `.gitignore` file
+  assert!(!git_ignore.is_ignored(Path::new("repo/services/dist"), true));
+  assert!(git_ignore.is_ignored(Path::new("repo/services/flags.pb.go"), false));
+  // The last matching pattern wins
+  assert!(!git_ignore.is_ignored(Path::new("repo/services/keep.pb.go"), false));
+  assert!(!git_ignore.is_ignored(Path::new("repo/services/flags.go"), false));
+}
+
+#[test]
+fn test_is_ignored_wildcards() {
+  let git_ignore = get_git_ignore("services/*.go\ndocs/**/*.go\n");
+  assert!(git_ignore.is_ignored(Path::new("repo/services/flags.go"), false));
+  // `*` does not match the path separator, unlike `**`
+  assert!(!git_ignore.is_ignored(Path::new("repo/services/checkout/flags.go"), false));
+  assert!(git_ignore.is_ignored(Path::new("repo/docs/examples/flags.go"), false));
+}
+
+/// The ignored paths (of the nested `.gitignore` files as well) and the symbolic links to directories are not walked over
+#[test]
+fn test_get_files_skips_ignored_paths() {
+  let temp_dir = TempDir::new("git_ignore").unwrap();
+  let codebase = temp_dir.path().join("repo");
+  for directory in ["bin", "services/generated", "shared"] {
+    fs::create_dir_all(codebase.join(directory)).unwrap();
+  }
+  for file in [
+    "main.go",
+    "bin/main.go",
+    "services/checkout.go",
+    "services/generated/flags.go",
+    "services/generated/keep.go",
+    "shared/flags.go",
+  ] {
+    fs::write(codebase.join(file), "package main\n").unwrap();
+  }
+  fs::write(codebase.join(".gitignore"), "bin/\n").unwrap();
+  fs::write(
+    codebase.join("services").join(".gitignore"),
+    "generated/*\n!generated/keep.go\n",
+  )
+  .unwrap();
+  #[cfg(unix)]
+  std::os::unix::fs::symlink(
+    codebase.join("shared"),
+    codebase.join("services").join("shared"),
+  )
+  .unwrap();
+
+  let get_files = |follow_symlinks: bool| {
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(codebase.to_string_lossy().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .follow_symlinks(follow_symlinks)
+      .build();
+    let mut files: Vec<String> = RuleStore::without_rules(&piranha_arguments)
+      .get_files(&codebase.to_string_lossy(), &vec![], &vec![])
+      .keys()
+      .map(|p| {
+        p.strip_prefix(&codebase)
+          .unwrap()
+          .to_string_lossy()
+          .to_string()
+      })
+      .collect();
+    files.sort();
+    files
+  };
+  assert_eq!(
+    get_files(false),
+    vec![
+      "main.go",
+      "services/checkout.go",
+      "services/generated/keep.go",
+      "shared/flags.go"
+    ]
+  );
+  #[cfg(unix)]
+  assert!(get_files(true).contains(&"services/shared/flags.go".to_string()));
+}
@@ -0,0 +1,104 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::fs;
+
+use tempdir::TempDir;
+
+use super::{
+  find_go_modules, get_module_of, parse_module_directive, parse_use_directives, GoModule,
+};
+
+/// Creates a code base with the `payments`, `search` and `tools` modules (along with a module vendored by `search`)
+fn create_code_base(go_work: Option<&str>) -> TempDir {
+  let temp_dir = TempDir::new("go_modules").unwrap();
+  let root = temp_dir.path();
+  for (directory, module_path) in [
+    ("payments", "example.com/shop/payments"),
+    ("search", "example.com/shop/search"),
+    ("search/vendor/example.com/lib", "example.com/lib"),
+    ("tools", "example.com/shop/tools"),
+  ] {
+    fs::create_dir_all(root.join(directory)).unwrap();
+    fs::write(
+      root.join(directory).join("go.mod"),
+      format!("module {module_path}\n\ngo 1.21\n"),
+    )
+    .unwrap();
+  }
+  if let Some(content) = go_work {
+    fs::write(root.join("go.work"), content).unwrap();
+  }
+  temp_dir
+}
+
+fn get_module_paths(modules: &[GoModule]) -> Vec<&str> {
+  modules.iter().map(|m| m.module_path().as_str()).collect()
+}
+
+#[test]
+fn test_parse_use_directives() {
+  assert_eq!(
+    parse_use_directives(
+      "go 1.21\n\nuse (\n\t./payments // the payments\n\t\"./search\"\n)\n\nuse ./tools\n"
+    ),
+    vec!["./payments", "./search", "./tools"]
+  );
+  assert_eq!(
+    parse_use_directives("go 1.21\n\nuse (./payments)\n"),
+    vec!["./payments"]
+  );
+}
+
+#[test]
+fn test_parse_module_directive() {
+  assert_eq!(
+    parse_module_directive("// The payments\nmodule example.com/shop/payments\n\ngo 1.21\n"),
+    Some("example.com/shop/payments".to_string())
+  );
+  assert_eq!(parse_module_directive("go 1.21\n"), None);
+}
+
+#[test]
+fn test_find_go_modules_go_work() {
+  let temp_dir = create_code_base(Some(
+    "go 1.21\n\nuse (\n\t./payments\n\t./search\n\t../outside\n)\n",
+  ));
+  let modules = find_go_modules(temp_dir.path().to_str().unwrap());
+  // Only the modules of the workspace (within the code base)
+  assert_eq!(
+    get_module_paths(&modules),
+    vec!["example.com/shop/payments", "example.com/shop/search"]
+  );
+}
+
+#[test]
+fn test_find_go_modules_go_mod() {
+  let temp_dir = create_code_base(None);
+  let modules = find_go_modules(temp_dir.path().to_str().unwrap());
+  // The vendored modules are skipped
+  assert_eq!(
+    get_module_paths(&modules),
+    vec![
+      "example.com/shop/payments",
+      "example.com/shop/search",
+      "example.com/shop/tools"
+    ]
+  );
+  let root = temp_dir.path().canonicalize().unwrap();
+  assert_eq!(
+    get_module_of(&modules, &root.join("search/index/index.go")).map(|m| m.module_path().as_str()),
+    Some("example.com/shop/search")
+  );
+  assert_eq!(get_module_of(&modules, &root.join("main.go")), None);
+}
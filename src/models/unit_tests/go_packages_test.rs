@@ -0,0 +1,111 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{fs, path::Path};
+
+use tempdir::TempDir;
+
+use super::{get_package_name, GoPackages};
+
+#[test]
+fn test_get_package_name() {
+  assert_eq!(
+    get_package_name("// Package checkout ...\npackage checkout\n"),
+    Some("checkout".to_string())
+  );
+  assert_eq!(
+    get_package_name(
+      "/*\npackage main is not the package clause\n*/\n\n//go:build ignore\n\npackage main_test\n"
+    ),
+    Some("main_test".to_string())
+  );
+  assert_eq!(get_package_name("// no package clause\n"), None);
+}
+
+#[test]
+fn test_get_package_from_package_clause() {
+  let go_packages = GoPackages::default();
+  let package = go_packages.get_package(Path::new("checkout/checkout.go"), "package checkout\n");
+  assert_eq!(
+    package,
+    go_packages.get_package(Path::new("checkout/checkout_test.go"), "package checkout\n")
+  );
+  // The external test package is another package
+  assert_ne!(
+    package,
+    go_packages.get_package(
+      Path::new("checkout/export_test.go"),
+      "package checkout_test\n"
+    )
+  );
+  assert_ne!(
+    package,
+    go_packages.get_package(Path::new("search/checkout.go"), "package checkout\n")
+  );
+}
+
+#[test]
+fn test_from_go_list_output() {
+  let temp_dir = TempDir::new("go_packages").unwrap();
+  let directory = temp_dir.path().canonicalize().unwrap();
+  let files = [
+    ("checkout.go", "package checkout\n"),
+    (
+      "checkout_windows.go",
+      "//go:build windows\n\npackage checkout\n",
+    ),
+    ("gen.go", "//go:build ignore\n\npackage main\n"),
+    ("checkout_test.go", "package checkout\n"),
+    ("export_test.go", "package checkout_test\n"),
+  ];
+  for (file, content) in files {
+    fs::write(directory.join(file), content).unwrap();
+  }
+  let output = format!(
+    r#"{{
+	"Dir": "{}",
+	"ImportPath": "example.com/shop/checkout",
+	"Name": "checkout",
+	"GoFiles": ["checkout.go"],
+	"IgnoredGoFiles": ["checkout_windows.go", "gen.go"],
+	"TestGoFiles": ["checkout_test.go"],
+	"XTestGoFiles": ["export_test.go"]
+}}
+{{
+	"Dir": "{}/search",
+	"ImportPath": "example.com/shop/search",
+	"Name": "search"
+}}"#,
+    directory.display(),
+    directory.display()
+  );
+  let go_packages = GoPackages::from_go_list_output(&output).unwrap();
+  let get_package = |file: &str| {
+    let path = directory.join(file);
+    go_packages.get_package(&path, &fs::read_to_string(&path).unwrap())
+  };
+  assert_eq!(get_package("checkout.go"), "example.com/shop/checkout");
+  assert_eq!(
+    get_package("checkout_windows.go"),
+    "example.com/shop/checkout"
+  );
+  assert_eq!(get_package("checkout_test.go"), "example.com/shop/checkout");
+  assert_eq!(
+    get_package("export_test.go"),
+    "example.com/shop/checkout_test"
+  );
+  // The tool excluded by the build constraint is not part of the package
+  assert_ne!(get_package("gen.go"), "example.com/shop/checkout");
+
+  assert!(GoPackages::from_go_list_output("{ not json").is_err());
+}
@@ -0,0 +1,118 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, fs, path::Path, process::Command};
+
+use tempdir::TempDir;
+use tree_sitter::{Point, Range};
+
+use crate::models::{
+  default_configs::GO, edit::Edit, language::PiranhaLanguage, matches::Match,
+  piranha_arguments::PiranhaArgumentsBuilder, piranha_output::PiranhaOutputSummary,
+  source_code_unit::SourceCodeUnit,
+};
+
+use super::stage_rewritten_files;
+
+fn git(directory: &Path, args: &[&str]) -> String {
+  let output = Command::new("git")
+    .arg("-C")
+    .arg(directory)
+    .args([
+      "-c",
+      "user.name=piranha",
+      "-c",
+      "user.email=piranha@example.com",
+    ])
+    .args(args)
+    .output()
+    .unwrap();
+  assert!(output.status.success());
+  String::from_utf8(output.stdout).unwrap()
+}
+
+/// Returns the summary of the file at `path` rewritten from `original_content` to `content`, and writes it
+fn rewrite(path: &Path, original_content: &str, content: &str) -> PiranhaOutputSummary {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    original_content.to_string(),
+    &HashMap::new(),
+    path,
+    &piranha_arguments,
+  );
+  let lines = original_content.lines().count();
+  let edit = Edit::new(
+    Match::new(
+      original_content.to_string(),
+      Range {
+        start_byte: 0,
+        end_byte: original_content.len(),
+        start_point: Point::new(0, 0),
+        end_point: Point::new(lines, 0),
+      },
+      HashMap::new(),
+    ),
+    content.to_string(),
+    "rewrite".to_string(),
+    &original_content.to_string(),
+  );
+  source_code_unit.apply_edit(&edit, &mut parser);
+  fs::write(path, content).unwrap();
+  PiranhaOutputSummary::new(&source_code_unit)
+}
+
+#[test]
+fn test_stage_rewritten_files() {
+  let temp_dir = TempDir::new("hook").unwrap();
+  let repository = temp_dir.path();
+  git(repository, &["init", "-q", "-b", "main"]);
+  for file in ["checkout.go", "search.go"] {
+    fs::write(repository.join(file), "package main\n").unwrap();
+  }
+  git(repository, &["add", "-A"]);
+  git(repository, &["commit", "-q", "-m", "Initial commit"]);
+  // Both files are staged, and `search.go` has an unstaged change on top of it
+  let staged_content = "package main\n\nfunc run() {\n\tif true {\n\t\tprintln()\n\t}\n}\n";
+  for file in ["checkout.go", "search.go"] {
+    fs::write(repository.join(file), staged_content).unwrap();
+  }
+  git(repository, &["add", "-A"]);
+  let unstaged_content = format!("{staged_content}\nfunc search() {{}}\n");
+  fs::write(repository.join("search.go"), &unstaged_content).unwrap();
+
+  let cleaned_content = "package main\n\nfunc run() {\n\tprintln()\n}\n";
+  let summaries = vec![
+    rewrite(
+      &repository.join("checkout.go"),
+      staged_content,
+      cleaned_content,
+    ),
+    rewrite(
+      &repository.join("search.go"),
+      &unstaged_content,
+      &format!("{cleaned_content}\nfunc search() {{}}\n"),
+    ),
+  ];
+  let not_staged = stage_rewritten_files(&summaries).unwrap();
+
+  assert_eq!(
+    not_staged,
+    vec![repository.join("search.go").to_str().unwrap().to_string()]
+  );
+  assert_eq!(git(repository, &["show", ":checkout.go"]), cleaned_content);
+  assert_eq!(git(repository, &["show", ":search.go"]), staged_content);
+}
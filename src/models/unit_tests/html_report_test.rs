@@ -0,0 +1,111 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use tree_sitter::{Point, Range};
+
+use crate::models::{
+  default_configs::GO, edit::Edit, language::PiranhaLanguage, matches::Match,
+  piranha_arguments::PiranhaArgumentsBuilder, piranha_output::PiranhaOutputSummary,
+  source_code_unit::SourceCodeUnit,
+};
+
+use super::{escape_html, HtmlReport};
+
+static ORIGINAL_CONTENT: &str = "package payments
+
+var enabled = isEnabled(\"stale_flag\") && limit < 10
+";
+
+static REWRITTEN_CONTENT: &str = "package payments
+
+var enabled = limit < 10
+";
+
+/// The rewritten files are grouped by flag and by package, and the code of their hunks is escaped
+#[test]
+fn test_html_report() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![
+      ("stale_flag_name".to_string(), "stale_flag".to_string()),
+      ("treated".to_string(), "true".to_string()),
+    ])
+    .report("html".to_string())
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    ORIGINAL_CONTENT.to_string(),
+    &HashMap::new(),
+    PathBuf::from("payments/main.go").as_path(),
+    &piranha_arguments,
+  );
+  let start_byte = ORIGINAL_CONTENT.find("isEnabled").unwrap();
+  let end_byte = start_byte + "isEnabled(\"stale_flag\")".len();
+  let edit = Edit::new(
+    Match::new(
+      ORIGINAL_CONTENT[start_byte..end_byte].to_string(),
+      Range {
+        start_byte,
+        end_byte,
+        start_point: Point::new(2, 14),
+        end_point: Point::new(2, 37),
+      },
+      HashMap::new(),
+    ),
+    "true".to_string(),
+    "replace_is_enabled".to_string(),
+    &ORIGINAL_CONTENT.to_string(),
+  );
+  source_code_unit.rewrites_mut().push(edit);
+  source_code_unit.set_code(REWRITTEN_CONTENT.to_string());
+
+  let summaries = vec![PiranhaOutputSummary::new(&source_code_unit)];
+  let html = HtmlReport::new(&summaries, &piranha_arguments).to_html();
+
+  assert!(html.starts_with("<!DOCTYPE html>"));
+  assert!(html.contains("<tr><th>Files modified</th><td>1</td></tr>"));
+  assert!(html.contains("<summary class=\"flag\">stale_flag (1 files)</summary>"));
+  assert!(html.contains("<summary class=\"package\">payments (1 files)</summary>"));
+  assert!(html.contains("<summary><code>payments/main.go</code> (+1 -1)</summary>"));
+  assert!(html.contains(
+    "<tr><td>3</td><td><code>replace_is_enabled</code></td><td>user</td><td>no</td></tr>"
+  ));
+  assert!(html.contains("<tr><th colspan=\"4\">@@ -1,3 +1,3 @@</th></tr>"));
+  assert!(html.contains(
+    "<td class=\"number\">3</td><td class=\"deleted\">var enabled = isEnabled(&quot;stale_flag&quot;) &amp;&amp; limit &lt; 10</td><td class=\"number\">3</td><td class=\"inserted\">var enabled = limit &lt; 10</td>"
+  ));
+  // The statistics of the run are only included if they are computed
+  assert!(!html.contains("Files scanned"));
+}
+
+#[test]
+fn test_html_report_empty() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  let html = HtmlReport::new(&[], &piranha_arguments).to_html();
+  assert!(html.contains("<p>No file was changed.</p>"));
+}
+
+#[test]
+fn test_escape_html() {
+  assert_eq!(
+    escape_html("<a href=\"x\">'&'</a>"),
+    "&lt;a href=&quot;x&quot;&gt;&#39;&amp;&#39;&lt;/a&gt;"
+  );
+}
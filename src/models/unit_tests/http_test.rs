@@ -0,0 +1,359 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::HashMap,
+  fs,
+  io::Cursor,
+  os::unix::fs::symlink,
+  path::PathBuf,
+  process::Command,
+  sync::{mpsc, Arc},
+  thread,
+  time::Duration,
+};
+
+use serde_json::{json, Value};
+use tempdir::TempDir;
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+};
+
+use super::{
+  check_archive_entries, decode_base64, extract_archive, prune_jobs, read_request, write_response,
+  HttpServer, Job, JobStatus, Request, Response, MAX_BODY_SIZE, MAX_HEADERS, MAX_LINE_LENGTH,
+  MAX_QUEUED_JOBS,
+};
+
+fn get_request(method: &str, path: &str, body: &str) -> Request {
+  Request {
+    method: method.to_string(),
+    path: path.to_string(),
+    body: body.as_bytes().to_vec(),
+  }
+}
+
+fn get_body(response: &Response) -> Value {
+  serde_json::from_str(&response.body).unwrap()
+}
+
+#[test]
+fn test_read_request() {
+  let mut input = Cursor::new(
+    "POST /jobs?wait=false HTTP/1.1\r\nHost: localhost\r\ncontent-length: 13\r\n\r\n{\"flags\": []}",
+  );
+  let request = read_request(&mut input).unwrap();
+  assert_eq!(request.method, "POST");
+  assert_eq!(request.path, "/jobs");
+  assert_eq!(String::from_utf8(request.body).unwrap(), "{\"flags\": []}");
+
+  assert_eq!(
+    read_request(&mut Cursor::new("\r\n")).err().unwrap().status,
+    400
+  );
+  // The body is not read (nor allocated) beyond the maximum size
+  let mut input = Cursor::new(format!(
+    "POST /jobs HTTP/1.1\r\nContent-Length: {}\r\n\r\n",
+    MAX_BODY_SIZE + 1
+  ));
+  assert_eq!(read_request(&mut input).err().unwrap().status, 413);
+}
+
+#[test]
+fn test_read_request_limits() {
+  // Neither the request line nor the headers are read beyond their maximum length
+  let mut input = Cursor::new(format!(
+    "GET /{} HTTP/1.1\r\n\r\n",
+    "a".repeat(MAX_LINE_LENGTH)
+  ));
+  assert_eq!(read_request(&mut input).err().unwrap().status, 414);
+  let mut input = Cursor::new(format!(
+    "GET /health HTTP/1.1\r\nCookie: {}\r\n\r\n",
+    "a".repeat(MAX_LINE_LENGTH)
+  ));
+  assert_eq!(read_request(&mut input).err().unwrap().status, 431);
+
+  let get_headers = |n: usize| {
+    (0..n)
+      .map(|i| format!("X-Header-{i}: {i}\r\n"))
+      .collect::<String>()
+  };
+  let mut input = Cursor::new(format!(
+    "GET /health HTTP/1.1\r\n{}\r\n",
+    get_headers(MAX_HEADERS)
+  ));
+  assert_eq!(read_request(&mut input).unwrap().path, "/health");
+  let mut input = Cursor::new(format!(
+    "GET /health HTTP/1.1\r\n{}\r\n",
+    get_headers(MAX_HEADERS + 1)
+  ));
+  assert_eq!(read_request(&mut input).err().unwrap().status, 431);
+}
+
+#[test]
+fn test_write_response() {
+  let mut output = vec![];
+  write_response(&mut output, &Response::error(404, "No such job : 7")).unwrap();
+  assert_eq!(
+    String::from_utf8(output).unwrap(),
+    "HTTP/1.1 404 Not Found\r\nContent-Type: application/json\r\nContent-Length: 28\r\nConnection: close\r\n\r\n{\"error\":\"No such job : 7\"}"
+  );
+}
+
+#[test]
+fn test_decode_base64() {
+  assert_eq!(decode_base64("cGlyYW5oYQ==").unwrap(), b"piranha");
+  assert_eq!(decode_base64("cGlyYW5o\nYQ").unwrap(), b"piranha");
+  assert_eq!(decode_base64("-_8").unwrap(), vec![0xfb, 0xff]);
+  assert!(decode_base64("cGly*W5oYQ==").is_err());
+}
+
+#[test]
+fn test_extract_archive() {
+  let temp_dir = TempDir::new("piranha_http").unwrap();
+  let source = temp_dir.path().join("source");
+  fs::create_dir_all(source.join("pkg")).unwrap();
+  fs::write(source.join("pkg").join("main.go"), "package pkg\n").unwrap();
+  let archive = temp_dir.path().join("source.tar.gz");
+  let status = Command::new("tar")
+    .arg("-czf")
+    .arg(&archive)
+    .arg("-C")
+    .arg(&source)
+    .arg(".")
+    .status()
+    .unwrap();
+  assert!(status.success());
+
+  let codebase = temp_dir.path().join("codebase");
+  extract_archive(&fs::read(&archive).unwrap(), temp_dir.path(), &codebase).unwrap();
+  assert_eq!(
+    fs::read_to_string(codebase.join("pkg").join("main.go")).unwrap(),
+    "package pkg\n"
+  );
+  assert!(extract_archive(b"not an archive", temp_dir.path(), &codebase).is_err());
+
+  // The archives escaping the code base are rejected, i.e. with a `..` entry or a symbolic link
+  fs::write(temp_dir.path().join("outside.go"), "package outside\n").unwrap();
+  symlink("/etc", source.join("etc")).unwrap();
+  for entry in ["../outside.go", "./etc"] {
+    let status = Command::new("tar")
+      .arg("-czPf")
+      .arg(&archive)
+      .arg("-C")
+      .arg(&source)
+      .arg(entry)
+      .status()
+      .unwrap();
+    assert!(status.success());
+    let codebase = temp_dir.path().join(format!("codebase_{}", entry.len()));
+    assert!(extract_archive(&fs::read(&archive).unwrap(), temp_dir.path(), &codebase).is_err());
+    assert!(!codebase.exists());
+  }
+}
+
+#[test]
+fn test_check_archive_entries() {
+  let listing = |types: &[char]| {
+    types
+      .iter()
+      .map(|t| format!("{t}rw-r--r-- user/group 0 2023-01-01 00:00 entry\n"))
+      .collect::<String>()
+  };
+  assert!(check_archive_entries("./\n./pkg/main.go\n", &listing(&['d', '-'])).is_ok());
+  for names in ["/etc/passwd\n", "../main.go\n", "./pkg/../../main.go\n"] {
+    assert!(check_archive_entries(names, &listing(&['-'])).is_err());
+  }
+  // The symbolic and hard links
+  for entry_type in ['l', 'h'] {
+    assert!(check_archive_entries("./link\n", &listing(&[entry_type])).is_err());
+  }
+  assert!(check_archive_entries("./a\n./b\n", &listing(&['-'])).is_err());
+}
+
+#[test]
+fn test_prune_jobs() {
+  let job = |status| Job {
+    status,
+    error: None,
+    diff: String::new(),
+    report: Value::Null,
+    files_changed: 0,
+  };
+  let mut jobs = HashMap::from([
+    (1, job(JobStatus::Succeeded)),
+    (2, job(JobStatus::Failed)),
+    (3, job(JobStatus::Running)),
+    (4, job(JobStatus::Succeeded)),
+    (5, job(JobStatus::Queued)),
+  ]);
+  prune_jobs(&mut jobs, 1);
+  // The oldest completed jobs are dropped, but not the pending ones
+  let mut ids = jobs.keys().copied().collect::<Vec<_>>();
+  ids.sort();
+  assert_eq!(ids, vec![3, 4, 5]);
+}
+
+#[test]
+fn test_http_server_jobs() {
+  let path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/parallel_packages");
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_configurations(path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .serve(true)
+    .http_address("127.0.0.1:0".to_string())
+    .http_root(path.to_str().unwrap().to_string())
+    .build();
+  let mut server = HttpServer::new(&piranha_arguments);
+
+  // The invalid jobs are rejected
+  assert_eq!(
+    server.handle(&get_request("POST", "/jobs", "{")).status,
+    400
+  );
+  assert_eq!(
+    get_body(&server.handle(&get_request(
+      "POST",
+      "/jobs",
+      "{\"flags\": [{\"name\": \"stale_flag\"}]}"
+    ))),
+    json!({ "error": "Invalid job : either `path_to_codebase` or `archive` is required" })
+  );
+  assert_eq!(
+    server.handle(&get_request("GET", "/jobs/42", "")).status,
+    404
+  );
+  assert_eq!(
+    server.handle(&get_request("DELETE", "/jobs", "")).status,
+    404
+  );
+
+  // The code bases outside of the root of the server are rejected
+  for path_to_codebase in ["..", "/"] {
+    let job = json!({
+      "path_to_codebase": path_to_codebase,
+      "flags": [{ "name": "stale_flag" }],
+    });
+    assert_eq!(
+      server
+        .handle(&get_request("POST", "/jobs", &job.to_string()))
+        .status,
+      400
+    );
+  }
+
+  let job = json!({
+    "path_to_codebase": "input",
+    "flags": [{ "name": "stale_flag", "treated": true }],
+  });
+  let response = server.handle(&get_request("POST", "/jobs", &job.to_string()));
+  assert_eq!(response.status, 202);
+  assert_eq!(get_body(&response), json!({ "id": 1, "status": "queued" }));
+
+  // The job runs on the worker of the server
+  let mut status = Value::Null;
+  for _ in 0..600 {
+    status = get_body(&server.handle(&get_request("GET", "/jobs/1", "")));
+    if status["status"] != "queued" && status["status"] != "running" {
+      break;
+    }
+    thread::sleep(Duration::from_millis(100));
+  }
+  assert_eq!(
+    status,
+    json!({ "id": 1, "status": "succeeded", "files_changed": 3 })
+  );
+
+  let diff = server.handle(&get_request("GET", "/jobs/1/diff", ""));
+  assert_eq!(diff.content_type, "text/x-diff");
+  assert!(diff
+    .body
+    .contains("--- a/payments/refunds.go\n+++ b/payments/refunds.go\n"));
+  assert!(diff
+    .body
+    .contains("-    enabled := exp.BoolValue(\"stale_flag\")\n"));
+  let report = get_body(&server.handle(&get_request("GET", "/jobs/1/report", "")));
+  assert_eq!(
+    report["files"]
+      .as_array()
+      .unwrap()
+      .iter()
+      .map(|f| f["path"].as_str().unwrap())
+      .collect::<Vec<_>>(),
+    vec![
+      "checkout/checkout.go",
+      "payments/payments.go",
+      "payments/refunds.go"
+    ]
+  );
+  assert_eq!(report["statistics"]["files_modified"], 3);
+  // The code base is cleaned up in dry-run
+  assert!(fs::read_to_string(path.join("input/payments/refunds.go"))
+    .unwrap()
+    .contains("exp.BoolValue(\"stale_flag\")"));
+}
+
+#[test]
+fn test_http_server_full_queue() {
+  // The jobs are not run (i.e. the queue is not consumed by a worker)
+  let (queue, _queued) = mpsc::sync_channel(MAX_QUEUED_JOBS);
+  let mut server = HttpServer {
+    jobs: Arc::default(),
+    next_id: 1,
+    queue,
+    root: Some(PathBuf::from("test-resources")),
+  };
+  let job = json!({
+    "path_to_codebase": GO,
+    "flags": [{ "name": "stale_flag" }],
+  });
+  for _ in 0..MAX_QUEUED_JOBS {
+    assert_eq!(
+      server
+        .handle(&get_request("POST", "/jobs", &job.to_string()))
+        .status,
+      202
+    );
+  }
+  let response = server.handle(&get_request("POST", "/jobs", &job.to_string()));
+  assert_eq!(response.status, 503);
+  // The rejected job is not kept
+  let id = MAX_QUEUED_JOBS + 1;
+  assert_eq!(
+    server
+      .handle(&get_request("GET", &format!("/jobs/{id}"), ""))
+      .status,
+    404
+  );
+}
+
+#[test]
+fn test_http_server_without_root() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .language(PiranhaLanguage::from(GO))
+    .serve(true)
+    .http_address("127.0.0.1:0".to_string())
+    .build();
+  let mut server = HttpServer::new(&piranha_arguments);
+  let job = json!({
+    "path_to_codebase": "test-resources",
+    "flags": [{ "name": "stale_flag" }],
+  });
+  assert_eq!(
+    get_body(&server.handle(&get_request("POST", "/jobs", &job.to_string()))),
+    json!({ "error": "Invalid job : `path_to_codebase` is not allowed, since the server has no `http_root`" })
+  );
+}
@@ -0,0 +1,39 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::get_assumed_package_name;
+
+#[test]
+fn test_get_assumed_package_name() {
+  assert_eq!(get_assumed_package_name("\"fmt\""), "fmt");
+  assert_eq!(get_assumed_package_name("\"net/http\""), "http");
+  assert_eq!(get_assumed_package_name("`net/http`"), "http");
+  assert_eq!(get_assumed_package_name("\"go.uber.org/zap\""), "zap");
+}
+
+#[test]
+fn test_get_assumed_package_name_major_version() {
+  assert_eq!(
+    get_assumed_package_name("\"github.com/go-redis/redis/v8\""),
+    "redis"
+  );
+  assert_eq!(get_assumed_package_name("\"gopkg.in/yaml.v2\""), "yaml");
+}
+
+#[test]
+fn test_get_assumed_package_name_go_prefix() {
+  assert_eq!(
+    get_assumed_package_name("\"github.com/mattn/go-sqlite3\""),
+    "sqlite3"
+  );
+}
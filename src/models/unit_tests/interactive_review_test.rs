@@ -0,0 +1,145 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, io::Cursor, path::PathBuf};
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+  source_code_unit::SourceCodeUnit,
+};
+
+static ORIGINAL_CONTENT: &str = "package main
+
+func main() {
+	enabled := isEnabled(\"stale_flag\")
+	println(enabled)
+	println(\"one\")
+	println(\"two\")
+	println(\"three\")
+	println(\"four\")
+	legacy := isEnabled(\"stale_flag\")
+	println(legacy)
+}
+";
+
+static REWRITTEN_CONTENT: &str = "package main
+
+func main() {
+	enabled := true
+	println(enabled)
+	println(\"one\")
+	println(\"two\")
+	println(\"three\")
+	println(\"four\")
+	legacy := true
+	println(legacy)
+}
+";
+
+/// Reviews the rewrites of the file with the `answers`, and returns whether the reviewer quit along with the reviewed content
+fn review(answers: &str, editor: &str) -> (bool, String) {
+  let (quit, source_code_unit) = review_source_code_unit(answers, editor);
+  (quit, source_code_unit.code().to_string())
+}
+
+/// Same as `review`, but returns the reviewed source code unit itself
+fn review_source_code_unit(answers: &str, editor: &str) -> (bool, SourceCodeUnit) {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    ORIGINAL_CONTENT.to_string(),
+    &HashMap::new(),
+    PathBuf::from("main.go").as_path(),
+    &piranha_arguments,
+  );
+  source_code_unit.set_code(REWRITTEN_CONTENT.to_string());
+  let mut output = vec![];
+  let quit =
+    source_code_unit.review_rewrites(&mut Cursor::new(answers.as_bytes()), &mut output, editor);
+  (quit, source_code_unit)
+}
+
+#[test]
+fn test_review_rewrites_apply_some_hunks() {
+  let (quit, content) = review("y\nn\n", "vi");
+  assert!(!quit);
+  assert_eq!(
+    content,
+    REWRITTEN_CONTENT.replace("legacy := true", "legacy := isEnabled(\"stale_flag\")")
+  );
+}
+
+#[test]
+fn test_review_rewrites_apply_rest() {
+  let (quit, content) = review("a\n", "vi");
+  assert!(!quit);
+  assert_eq!(content, REWRITTEN_CONTENT);
+}
+
+#[test]
+fn test_review_rewrites_skip_rest() {
+  let (quit, content) = review("d\n", "vi");
+  assert!(!quit);
+  assert_eq!(content, ORIGINAL_CONTENT);
+}
+
+#[test]
+fn test_review_rewrites_invalid_answer() {
+  let (quit, content) = review("maybe\nn\ny\n", "vi");
+  assert!(!quit);
+  assert_eq!(
+    content,
+    REWRITTEN_CONTENT.replace("enabled := true", "enabled := isEnabled(\"stale_flag\")")
+  );
+}
+
+#[test]
+fn test_review_rewrites_quit() {
+  for answers in ["q\n", ""] {
+    let (quit, content) = review(answers, "vi");
+    assert!(quit);
+    assert_eq!(content, ORIGINAL_CONTENT);
+  }
+}
+
+#[test]
+fn test_review_rewrites_edit_hunk() {
+  let (quit, content) = review("e\nd\n", "printf '\\tenabled := false\\n' >");
+  assert!(!quit);
+  assert_eq!(
+    content,
+    ORIGINAL_CONTENT.replace("enabled := isEnabled(\"stale_flag\")", "enabled := false")
+  );
+}
+
+#[test]
+fn test_review_rewrites_re_parses_the_reviewed_content() {
+  // Applies some hunks, then none of them (i.e. the rewrites are discarded)
+  for answers in ["y\nn\n", "d\n"] {
+    let (_, source_code_unit) = review_source_code_unit(answers, "vi");
+    let mut parser = PiranhaLanguage::from(GO).parser();
+    let expected_ast = parser.parse(source_code_unit.code(), None).unwrap();
+    assert_eq!(
+      source_code_unit.root_node().to_sexp(),
+      expected_ast.root_node().to_sexp()
+    );
+    assert_eq!(
+      source_code_unit.root_node().range(),
+      expected_ast.root_node().range()
+    );
+  }
+}
@@ -0,0 +1,147 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{fs, path::Path};
+
+use tempdir::TempDir;
+
+use crate::models::{
+  default_configs::GO,
+  language::PiranhaLanguage,
+  piranha_arguments::{PiranhaArguments, PiranhaArgumentsBuilder},
+};
+
+use super::{get_api, get_flag_inventory, remove_flag_predicate, Usage};
+
+static SEARCH: &str = "package main
+
+import \"github.com/company/exp\"
+
+type Server struct{}
+
+func (s *Server) search() {
+\tif !exp.IsEnabled(ctx, \"enable_search\") {
+\t\treturn
+\t}
+\tranking := exp.IsEnabled(ctx, \"enable_ranking\")
+\trender(exp.IsEnabled(ctx, \"enable_search\"), ranking)
+}
+
+func checkout() bool {
+\tgo func() {
+\t\texp.IsEnabled(ctx, \"enable_search\")
+\t}()
+\treturn exp.IsEnabled(ctx, \"enable_wallet\")
+}
+";
+
+/// Returns the arguments for the inventory of the code base, whose flag API is `exp.IsEnabled(ctx, "flag")`
+fn get_arguments(
+  codebase: &Path, configurations: &Path, substitutions: Vec<(String, String)>,
+) -> PiranhaArguments {
+  fs::write(
+    configurations.join("flag_apis.toml"),
+    "[[flag_apis]]\npackage = \"github.com/company/exp\"\nfunction = \"IsEnabled\"\nflag_argument_index = 1\n",
+  )
+  .unwrap();
+  PiranhaArgumentsBuilder::default()
+    .path_to_codebase(codebase.to_string_lossy().to_string())
+    .path_to_configurations(configurations.to_string_lossy().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions)
+    .inventory(true)
+    .build()
+}
+
+#[test]
+fn test_get_flag_inventory() {
+  let codebase = TempDir::new("inventory").unwrap();
+  let configurations = TempDir::new("configurations").unwrap();
+  fs::write(codebase.path().join("search.go"), SEARCH).unwrap();
+  fs::write(codebase.path().join("OWNERS"), "search@example.com\n").unwrap();
+
+  let sites = get_flag_inventory(&get_arguments(
+    codebase.path(),
+    configurations.path(),
+    vec![],
+  ));
+  assert_eq!(
+    sites
+      .iter()
+      .map(|s| (
+        s.flag().as_str(),
+        *s.line(),
+        s.function().as_str(),
+        *s.usage()
+      ))
+      .collect::<Vec<_>>(),
+    vec![
+      ("enable_search", 8, "Server.search", Usage::Check),
+      ("enable_ranking", 11, "Server.search", Usage::Assignment),
+      ("enable_search", 12, "Server.search", Usage::PassThrough),
+      // The anonymous function is declared by `checkout`
+      ("enable_search", 17, "checkout", Usage::Other),
+      ("enable_wallet", 19, "checkout", Usage::PassThrough),
+    ]
+  );
+  assert_eq!(
+    sites[0].to_string(),
+    "search.go:8:6: `enable_search` - exp.IsEnabled in Server.search (direct check) - owners: search@example.com"
+  );
+
+  // Only the call sites of the given flag are listed
+  let sites = get_flag_inventory(&get_arguments(
+    codebase.path(),
+    configurations.path(),
+    vec![("stale_flag_name".to_string(), "enable_search".to_string())],
+  ));
+  assert_eq!(sites.len(), 3);
+  assert!(sites.iter().all(|s| s.flag() == "enable_search"));
+}
+
+#[test]
+fn test_get_api() {
+  assert_eq!(get_api("exp.IsEnabled(ctx, \"flag\")"), "exp.IsEnabled");
+  assert_eq!(
+    get_api("client\n\t.experiments()\n\t.isEnabled(\"flag\")"),
+    "client.experiments().isEnabled"
+  );
+  assert_eq!(get_api("flags.STALE_FLAG"), "flags.STALE_FLAG");
+}
+
+#[test]
+fn test_remove_flag_predicate() {
+  let placeholder = "__piranha_inventory_stale_flag_name__";
+  let (query, capture) = remove_flag_predicate(
+    r#"((call_expression arguments: (argument_list (interpreted_string_literal) @flag_name)) @call
+    (#eq? @flag_name "\"__piranha_inventory_stale_flag_name__\""))"#,
+    placeholder,
+  )
+  .unwrap();
+  assert_eq!(capture, "flag_name");
+  assert!(!query.contains(placeholder));
+  assert!(query.contains("@flag_name"));
+
+  let (_, capture) = remove_flag_predicate(
+    r#"((method_invocation arguments: (argument_list (_) @name)) (#eq? @name "__piranha_inventory_stale_flag_name__"))"#,
+    placeholder,
+  )
+  .unwrap();
+  assert_eq!(capture, "name");
+  // The flag name is not compared with a capture
+  assert!(remove_flag_predicate(
+    "((identifier) @name (#match? @name \"^__piranha_inventory_stale_flag_name__$\"))",
+    placeholder
+  )
+  .is_none());
+}
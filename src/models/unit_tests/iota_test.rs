@@ -0,0 +1,79 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashMap;
+
+use tree_sitter::Node;
+
+use crate::{
+  models::{
+    default_configs::GO, language::PiranhaLanguage, rule::InstantiatedRule,
+    source_code_unit::SourceCodeUnit,
+  },
+  piranha_rule,
+};
+
+fn get_delete_const_spec_rule() -> InstantiatedRule {
+  let rule = piranha_rule! {
+    name= "delete_const_spec",
+    query= "((const_spec) @const_spec)",
+    replace_node= "const_spec",
+    replace= ""
+  };
+  InstantiatedRule::new(&rule, &HashMap::new())
+}
+
+fn get_const_spec<'a>(source_code_unit: &'a SourceCodeUnit, name: &str) -> Node<'a> {
+  let mut stack = vec![source_code_unit.root_node()];
+  while let Some(current) = stack.pop() {
+    if current.kind() == "const_spec"
+      && current
+        .child_by_field_name("name")
+        .and_then(|n| n.utf8_text(source_code_unit.code().as_bytes()).ok())
+        == Some(name)
+    {
+      return current;
+    }
+    let mut cursor = current.walk();
+    stack.extend(current.children(&mut cursor));
+  }
+  panic!("Could not find the constant {name}");
+}
+
+#[test]
+fn test_shifts_iota_values() {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let source_code_unit = SourceCodeUnit::default(
+    "package main
+
+    const (
+      A Flag = iota
+      B
+      C
+    )
+
+    const (
+      D = \"d\"
+      E = \"e\"
+    )",
+    &mut parser,
+    GO.to_string(),
+  );
+  let rule = get_delete_const_spec_rule();
+  assert!(source_code_unit.shifts_iota_values(get_const_spec(&source_code_unit, "A"), &rule));
+  assert!(source_code_unit.shifts_iota_values(get_const_spec(&source_code_unit, "B"), &rule));
+  // Deleting the last constant does not shift any value
+  assert!(!source_code_unit.shifts_iota_values(get_const_spec(&source_code_unit, "C"), &rule));
+  // The block does not use `iota`
+  assert!(!source_code_unit.shifts_iota_values(get_const_spec(&source_code_unit, "D"), &rule));
+}
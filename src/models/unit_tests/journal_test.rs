@@ -0,0 +1,130 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, fs, path::Path};
+
+use tempdir::TempDir;
+use tree_sitter::{Point, Range};
+
+use crate::models::{
+  default_configs::GO, edit::Edit, language::PiranhaLanguage, matches::Match,
+  piranha_arguments::PiranhaArgumentsBuilder, piranha_output::PiranhaOutputSummary,
+  source_code_unit::SourceCodeUnit,
+};
+
+use super::{record_journal, revert_journal};
+
+/// Returns the summary of the file at `path` rewritten from `original_content` to `content`, and writes it
+/// (or deletes it, if `content` is empty)
+fn rewrite(path: &Path, original_content: &str, content: &str) -> PiranhaOutputSummary {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    original_content.to_string(),
+    &HashMap::new(),
+    path,
+    &piranha_arguments,
+  );
+  let edit = Edit::new(
+    Match::new(
+      original_content.to_string(),
+      Range {
+        start_byte: 0,
+        end_byte: original_content.len(),
+        start_point: Point::new(0, 0),
+        end_point: Point::new(original_content.lines().count(), 0),
+      },
+      HashMap::new(),
+    ),
+    content.to_string(),
+    "rewrite".to_string(),
+    &original_content.to_string(),
+  );
+  source_code_unit.apply_edit(&edit, &mut parser);
+  if content.is_empty() {
+    fs::remove_file(path).unwrap();
+  } else {
+    fs::write(path, content).unwrap();
+  }
+  PiranhaOutputSummary::new(&source_code_unit)
+}
+
+#[test]
+fn test_revert_journal() {
+  let temp_dir = TempDir::new("journal").unwrap();
+  let codebase = temp_dir.path();
+  let path_to_journal = codebase.join("journal.json").to_string_lossy().to_string();
+  let original_search = "package main\n\nfunc search() {\n\tif enabled() {\n\t\tquery()\n\t}\n}\n";
+  let original_checkout =
+    "package main\n\nfunc checkout() {\n\tif enabled() {\n\t\tpay()\n\t}\n}\n";
+  let original_flags = "package main\n\nfunc enabled() bool {\n\treturn true\n}\n";
+  let summaries = vec![
+    rewrite(
+      &codebase.join("search.go"),
+      original_search,
+      "package main\n\nfunc search() {\n\tquery()\n}\n",
+    ),
+    rewrite(
+      &codebase.join("checkout.go"),
+      original_checkout,
+      "package main\n\nfunc checkout() {\n\tpay()\n}\n",
+    ),
+    rewrite(&codebase.join("flags.go"), original_flags, ""),
+  ];
+  let record_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(codebase.to_string_lossy().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .path_to_journal(Some(path_to_journal.clone()))
+    .build();
+  record_journal(&summaries, &record_arguments).unwrap();
+
+  // An unrelated edit shifts the cleanup of `search.go`, while the cleanup of `checkout.go` is edited
+  fs::write(
+    codebase.join("search.go"),
+    "// Package main searches\npackage main\n\nfunc search() {\n\tquery()\n}\n\nfunc index() {}\n",
+  )
+  .unwrap();
+  let edited_checkout = "package main\n\nfunc checkout() {\n\tpay(true)\n}\n";
+  fs::write(codebase.join("checkout.go"), edited_checkout).unwrap();
+
+  let revert_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(codebase.to_string_lossy().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .path_to_journal(Some(path_to_journal))
+    .revert(true)
+    .build();
+  let outcome = revert_journal(&revert_arguments).unwrap();
+  assert_eq!(*outcome.reverted_edits(), 2);
+  assert_eq!(outcome.conflicts().len(), 1);
+  assert!(outcome.conflicts()[0].contains("checkout.go:"));
+  assert_eq!(
+    fs::read_to_string(codebase.join("search.go")).unwrap(),
+    format!("// Package main searches\n{original_search}\nfunc index() {{}}\n")
+  );
+  assert_eq!(
+    fs::read_to_string(codebase.join("checkout.go")).unwrap(),
+    edited_checkout
+  );
+  assert_eq!(
+    fs::read_to_string(codebase.join("flags.go")).unwrap(),
+    original_flags
+  );
+
+  // The edits already reverted are skipped
+  let outcome = revert_journal(&revert_arguments).unwrap();
+  assert_eq!(*outcome.reverted_edits(), 0);
+  assert_eq!(outcome.conflicts().len(), 1);
+}
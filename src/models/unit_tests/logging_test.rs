@@ -0,0 +1,40 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use log::{Level, Record};
+use serde_json::{json, Value};
+
+use super::format_json_record;
+
+#[test]
+fn test_format_json_record() {
+  let line = format_json_record(
+    "2023-05-04T12:00:00.000Z",
+    &Record::builder()
+      .args(format_args!("Rule replace_is_enabled matched at main.go:4"))
+      .level(Level::Debug)
+      .target("polyglot_piranha::models::matches")
+      .build(),
+  );
+  assert!(!line.contains('\n'));
+  let record: Value = serde_json::from_str(&line).unwrap();
+  assert_eq!(
+    record,
+    json!({
+      "timestamp": "2023-05-04T12:00:00.000Z",
+      "level": "DEBUG",
+      "target": "polyglot_piranha::models::matches",
+      "message": "Rule replace_is_enabled matched at main.go:4"
+    })
+  );
+}
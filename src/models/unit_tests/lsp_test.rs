@@ -0,0 +1,124 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{io::Cursor, path::Path};
+
+use serde_json::json;
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+};
+
+use super::{
+  get_position, get_title, path_to_uri, read_message, serve_lsp, uri_to_path, write_message,
+  CleanupScope,
+};
+
+#[test]
+fn test_read_and_write_message() {
+  let message = json!({ "jsonrpc": "2.0", "id": 1, "method": "shutdown" });
+  let mut output = vec![];
+  write_message(&mut output, &message).unwrap();
+  assert!(String::from_utf8(output.clone())
+    .unwrap()
+    .starts_with(&format!(
+      "Content-Length: {}\r\n\r\n",
+      message.to_string().len()
+    )));
+
+  let mut input = Cursor::new(output);
+  assert_eq!(read_message(&mut input).unwrap(), Some(message));
+  assert_eq!(read_message(&mut input).unwrap(), None);
+}
+
+#[test]
+fn test_serve_lsp() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  let mut input = vec![];
+  for message in [
+    json!({ "jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {} }),
+    json!({ "jsonrpc": "2.0", "method": "initialized", "params": {} }),
+    json!({ "jsonrpc": "2.0", "id": 2, "method": "textDocument/hover", "params": {} }),
+    json!({ "jsonrpc": "2.0", "id": 3, "method": "shutdown" }),
+    json!({ "jsonrpc": "2.0", "method": "exit" }),
+    // Not read, since the client exited
+    json!({ "jsonrpc": "2.0", "id": 4, "method": "shutdown" }),
+  ] {
+    write_message(&mut input, &message).unwrap();
+  }
+  let mut output = vec![];
+  serve_lsp(&piranha_arguments, &mut Cursor::new(input), &mut output).unwrap();
+
+  let mut output = Cursor::new(output);
+  let mut responses = vec![];
+  while let Some(response) = read_message(&mut output).unwrap() {
+    responses.push(response);
+  }
+  assert_eq!(responses.len(), 3);
+  assert_eq!(
+    responses[0]["result"]["capabilities"]["executeCommandProvider"]["commands"],
+    json!(["piranha.removeStaleFlag"])
+  );
+  assert_eq!(responses[1]["id"], json!(2));
+  assert_eq!(responses[1]["error"]["code"], json!(-32601));
+  assert_eq!(
+    responses[2],
+    json!({ "jsonrpc": "2.0", "id": 3, "result": null })
+  );
+}
+
+#[test]
+fn test_get_position() {
+  let content = "ab\nçd = f(x)\n";
+  assert_eq!(
+    get_position(content, 0),
+    json!({ "line": 0, "character": 0 })
+  );
+  assert_eq!(
+    get_position(content, 3),
+    json!({ "line": 1, "character": 0 })
+  );
+  // `ç` is 2 bytes, but a single UTF-16 code unit
+  assert_eq!(
+    get_position(content, 6),
+    json!({ "line": 1, "character": 2 })
+  );
+  assert_eq!(
+    get_position(content, content.len()),
+    json!({ "line": 2, "character": 0 })
+  );
+}
+
+#[test]
+fn test_uri_to_path() {
+  let path = Path::new("/src/my payments/pay%.go");
+  let uri = path_to_uri(path);
+  assert_eq!(uri, "file:///src/my%20payments/pay%25.go");
+  assert_eq!(uri_to_path(&uri).unwrap(), path);
+  assert!(uri_to_path("untitled:Untitled-1").is_err());
+}
+
+#[test]
+fn test_get_title() {
+  assert_eq!(
+    get_title(Some("stale_flag"), CleanupScope::File),
+    "Remove stale flag: stale_flag"
+  );
+  assert_eq!(
+    get_title(Some("stale_flag"), CleanupScope::Package),
+    "Remove stale flag: stale_flag (package)"
+  );
+  assert_eq!(get_title(None, CleanupScope::File), "Remove stale flag");
+}
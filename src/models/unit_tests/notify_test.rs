@@ -0,0 +1,78 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use serde_json::json;
+
+use super::Notification;
+
+/// Returns the notification of a run cleaning up `stale_flag` in two files, one of which could not be archived
+fn get_notification() -> Notification {
+  Notification {
+    path_to_codebase: "some/test/path/".to_string(),
+    flags: vec!["stale_flag".to_string()],
+    files_changed: vec!["main.go".to_string(), "payments/checkout.go".to_string()],
+    lines_added: 2,
+    lines_removed: 7,
+    pull_requests: vec!["https://github.com/uber/piranha/pull/42".to_string()],
+    run_url: Some("https://github.com/uber/piranha/actions/runs/7".to_string()),
+    ..Default::default()
+  }
+  .with_failures(&["The flag stale_flag is not archived : 404".to_string()])
+}
+
+#[test]
+fn test_notification_json_payload() {
+  assert_eq!(
+    get_notification().to_payload("json"),
+    json!({
+      "path_to_codebase": "some/test/path/",
+      "dry_run": false,
+      "flags": ["stale_flag"],
+      "files_changed": ["main.go", "payments/checkout.go"],
+      "lines_added": 2,
+      "lines_removed": 7,
+      "pull_requests": ["https://github.com/uber/piranha/pull/42"],
+      "failures": ["The flag stale_flag is not archived : 404"],
+      "run_url": "https://github.com/uber/piranha/actions/runs/7",
+    })
+  );
+}
+
+#[test]
+fn test_notification_slack_payload() {
+  assert_eq!(
+    get_notification().to_payload("slack"),
+    json!({
+      "text": "*Piranha* cleaned up 1 flag(s) in `some/test/path/`: `stale_flag`
+2 file(s) changed (+2 -7)
+*Pull requests*
+• <https://github.com/uber/piranha/pull/42>
+:warning: *1 failure(s)*
+• The flag stale_flag is not archived : 404
+<https://github.com/uber/piranha/actions/runs/7|View the run>"
+    })
+  );
+}
+
+#[test]
+fn test_notification_slack_payload_without_cleanup() {
+  let notification = Notification {
+    path_to_codebase: "some/test/path/".to_string(),
+    dry_run: true,
+    ..Default::default()
+  };
+  assert_eq!(
+    notification.to_payload("slack"),
+    json!({ "text": "*Piranha* cleaned up no flag in `some/test/path/`" })
+  );
+}
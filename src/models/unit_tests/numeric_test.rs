@@ -0,0 +1,79 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, source_code_unit::SourceCodeUnit,
+};
+
+use super::{compare, parse_number, Number};
+
+#[test]
+fn test_parse_number() {
+  assert_eq!(parse_number("100"), Some(Number::Int(100)));
+  assert_eq!(parse_number("1_000"), Some(Number::Int(1000)));
+  assert_eq!(parse_number("0x64"), Some(Number::Int(100)));
+  assert_eq!(parse_number("0b11"), Some(Number::Int(3)));
+  assert_eq!(parse_number("0o17"), Some(Number::Int(15)));
+  assert_eq!(parse_number("017"), Some(Number::Int(15)));
+  assert_eq!(parse_number("0.5"), Some(Number::Float(0.5)));
+  assert_eq!(parse_number("1e2"), Some(Number::Float(100.0)));
+  assert_eq!(parse_number("0x1p-2"), None);
+  assert_eq!(parse_number("threshold"), None);
+}
+
+#[test]
+fn test_compare() {
+  assert_eq!(compare(Number::Int(100), ">=", Number::Int(50)), Some(true));
+  assert_eq!(compare(Number::Int(100), "<", Number::Int(50)), Some(false));
+  assert_eq!(
+    compare(Number::Int(50), "==", Number::Float(50.0)),
+    Some(true)
+  );
+  assert_eq!(
+    compare(Number::Float(0.25), "!=", Number::Float(0.5)),
+    Some(true)
+  );
+  assert_eq!(compare(Number::Int(1), "&&", Number::Int(1)), None);
+}
+
+#[test]
+fn test_resolve_number() {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let source_code_unit = SourceCodeUnit::default(
+    "package main
+
+    const threshold = 50
+    const shadowed = 10
+    const computed = threshold * 2
+
+    func run() {
+      shadowed := 20
+      fmt.Println(shadowed)
+    }",
+    &mut parser,
+    GO.to_string(),
+  );
+  assert_eq!(
+    source_code_unit.resolve_number("threshold"),
+    Some(Number::Int(50))
+  );
+  assert_eq!(
+    source_code_unit.resolve_number("0.5"),
+    Some(Number::Float(0.5))
+  );
+  // Declared more than once in the file
+  assert_eq!(source_code_unit.resolve_number("shadowed"), None);
+  // Not declared with a numeric literal
+  assert_eq!(source_code_unit.resolve_number("computed"), None);
+  assert_eq!(source_code_unit.resolve_number("undeclared"), None);
+}
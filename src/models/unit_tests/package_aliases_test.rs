@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashMap;
+
+use crate::{
+  models::{
+    default_configs::GO, language::PiranhaLanguage, rule::InstantiatedRule,
+    source_code_unit::SourceCodeUnit,
+  },
+  piranha_rule,
+  utilities::eq_without_whitespace,
+};
+
+fn get_bool_value_rule(replace: &str) -> InstantiatedRule {
+  let rule = piranha_rule! {
+    name= "replace_bool_value",
+    query= "((call_expression
+        function: (selector_expression
+          operand: (identifier) @pkg
+          field: (field_identifier) @method)
+        arguments: (argument_list)) @call
+      (#eq? @pkg \"exp\")
+      (#eq? @method \"BoolValue\"))",
+    replace_node= "call",
+    replace= replace
+  };
+  InstantiatedRule::new(&rule, &HashMap::new())
+}
+
+fn resolve_package_aliases(code: &str, rule: &InstantiatedRule) -> String {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let source_code_unit = SourceCodeUnit::default(code, &mut parser, GO.to_string());
+  source_code_unit.resolve_package_aliases(rule).get_query()
+}
+
+#[test]
+fn test_resolve_package_aliases_alias() {
+  let query = resolve_package_aliases(
+    "package main
+
+    import (
+      \"fmt\"
+      fx \"github.com/company/exp\"
+    )",
+    &get_bool_value_rule("true"),
+  );
+  assert!(eq_without_whitespace(
+    &query,
+    "((call_expression
+        function: (selector_expression
+          operand: (identifier) @pkg
+          field: (field_identifier) @method)
+        arguments: (argument_list)) @call
+      (#match? @pkg \"^(fx)$\")
+      (#eq? @method \"BoolValue\"))"
+  ));
+}
+
+#[test]
+fn test_resolve_package_aliases_dot_import() {
+  let query = resolve_package_aliases(
+    "package main
+
+    import . \"github.com/company/exp\"",
+    &get_bool_value_rule("true"),
+  );
+  assert!(eq_without_whitespace(
+    &query,
+    "((call_expression
+        function: (identifier) @method
+        arguments: (argument_list)) @call
+      (#eq? @method \"BoolValue\"))"
+  ));
+}
+
+/// The query is left as it is when the package is imported under its own name,
+/// or when the replacement references the package.
+#[test]
+fn test_resolve_package_aliases_unchanged() {
+  let rule = get_bool_value_rule("true");
+  let query = resolve_package_aliases(
+    "package main
+
+    import \"github.com/company/exp\"",
+    &rule,
+  );
+  assert_eq!(query, rule.query().get_query());
+
+  let rule = get_bool_value_rule("@pkg.Enabled()");
+  let query = resolve_package_aliases(
+    "package main
+
+    import . \"github.com/company/exp\"",
+    &rule,
+  );
+  assert_eq!(query, rule.query().get_query());
+}
@@ -0,0 +1,79 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use super::{get_packages, partition_into_batches};
+
+/// Returns the files at the given paths (with an empty content)
+fn get_files(paths: &[&str]) -> HashMap<PathBuf, String> {
+  paths
+    .iter()
+    .map(|p| (PathBuf::from(p), String::new()))
+    .collect()
+}
+
+/// Returns the paths of the files of each package of each batch
+fn get_paths(batches: &[Vec<Vec<(PathBuf, String)>>]) -> Vec<Vec<Vec<&str>>> {
+  batches
+    .iter()
+    .map(|b| {
+      b.iter()
+        .map(|p| p.iter().map(|(f, _)| f.to_str().unwrap()).collect())
+        .collect()
+    })
+    .collect()
+}
+
+#[test]
+fn test_get_packages() {
+  let packages = get_packages(get_files(&[
+    "src/search/search.go",
+    "src/payments/refunds.go",
+    "src/payments/payments.go",
+    "src/main.go",
+  ]));
+  let paths: Vec<Vec<&str>> = packages
+    .iter()
+    .map(|p| p.iter().map(|(f, _)| f.to_str().unwrap()).collect())
+    .collect();
+  assert_eq!(
+    paths,
+    vec![
+      vec!["src/main.go"],
+      vec!["src/payments/payments.go", "src/payments/refunds.go"],
+      vec!["src/search/search.go"],
+    ]
+  );
+}
+
+#[test]
+fn test_partition_into_batches() {
+  let packages = get_packages(get_files(&[
+    "a/1.go", "a/2.go", "a/3.go", "b/1.go", "b/2.go", "c/1.go", "d/1.go",
+  ]));
+  let batches = partition_into_batches(packages, 2);
+  // The largest packages are assigned first, each to the smallest batch so far
+  assert_eq!(
+    get_paths(&batches),
+    vec![
+      vec![vec!["a/1.go", "a/2.go", "a/3.go"], vec!["d/1.go"]],
+      vec![vec!["b/1.go", "b/2.go"], vec!["c/1.go"]],
+    ]
+  );
+  // There are no more batches than packages
+  assert_eq!(
+    partition_into_batches(get_packages(get_files(&["a/1.go"])), 8).len(),
+    1
+  );
+}
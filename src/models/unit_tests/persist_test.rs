@@ -0,0 +1,118 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  fs,
+  path::{Path, PathBuf},
+};
+
+use tempdir::TempDir;
+
+use crate::models::{
+  default_configs::GO,
+  language::PiranhaLanguage,
+  piranha_arguments::{PiranhaArguments, PiranhaArgumentsBuilder},
+};
+
+use super::{back_up_file, get_backup_path, write_atomically};
+
+fn get_arguments(path_to_codebase: &Path, backup: bool, backup_dir: &str) -> PiranhaArguments {
+  PiranhaArgumentsBuilder::default()
+    .path_to_codebase(path_to_codebase.to_string_lossy().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .backup(backup)
+    .backup_dir(backup_dir.to_string())
+    .build()
+}
+
+/// The file is rewritten in place, without leaving the temporary file behind
+#[test]
+fn test_write_atomically() {
+  let temp_dir = TempDir::new("persist").unwrap();
+  let path = temp_dir.path().join("sample.go");
+  fs::write(&path, "package main\n\nvar enabled = true\n").unwrap();
+  write_atomically(&path, "package main\n").unwrap();
+  assert_eq!(fs::read_to_string(&path).unwrap(), "package main\n");
+  assert_eq!(fs::read_dir(temp_dir.path()).unwrap().count(), 1);
+}
+
+/// The permissions of the file are kept
+#[cfg(unix)]
+#[test]
+fn test_write_atomically_permissions() {
+  use std::os::unix::fs::PermissionsExt;
+
+  let temp_dir = TempDir::new("persist").unwrap();
+  let path = temp_dir.path().join("generate.sh");
+  fs::write(&path, "#!/bin/sh\n").unwrap();
+  fs::set_permissions(&path, fs::Permissions::from_mode(0o755)).unwrap();
+  write_atomically(&path, "#!/bin/sh\nexit 0\n").unwrap();
+  assert_eq!(
+    fs::metadata(&path).unwrap().permissions().mode() & 0o777,
+    0o755
+  );
+}
+
+/// A symbolic link is written through, i.e. it still points to its (rewritten) target
+#[cfg(unix)]
+#[test]
+fn test_write_atomically_symlink() {
+  let temp_dir = TempDir::new("persist").unwrap();
+  let target = temp_dir.path().join("target.go");
+  let link = temp_dir.path().join("link.go");
+  fs::write(&target, "package main\n\nvar enabled = true\n").unwrap();
+  std::os::unix::fs::symlink(&target, &link).unwrap();
+  write_atomically(&link, "package main\n").unwrap();
+  assert!(fs::symlink_metadata(&link)
+    .unwrap()
+    .file_type()
+    .is_symlink());
+  assert_eq!(fs::read_to_string(&target).unwrap(), "package main\n");
+}
+
+#[test]
+fn test_get_backup_path() {
+  assert_eq!(
+    get_backup_path(Path::new("/repo/pkg/sample.go"), "/repo", ""),
+    PathBuf::from("/repo/pkg/sample.go.orig")
+  );
+  assert_eq!(
+    get_backup_path(Path::new("/repo/pkg/sample.go"), "/repo", "/backups"),
+    PathBuf::from("/backups/pkg/sample.go")
+  );
+}
+
+#[test]
+fn test_back_up_file() {
+  let temp_dir = TempDir::new("persist").unwrap();
+  let codebase = temp_dir.path().join("repo");
+  let path = codebase.join("pkg").join("sample.go");
+  fs::create_dir_all(path.parent().unwrap()).unwrap();
+  fs::write(&path, "package pkg\n").unwrap();
+
+  // Nothing is kept without `backup`
+  let arguments = get_arguments(&codebase, false, "");
+  assert_eq!(back_up_file(&path, &arguments).unwrap(), None);
+  assert_eq!(fs::read_dir(path.parent().unwrap()).unwrap().count(), 1);
+
+  let arguments = get_arguments(&codebase, true, "");
+  let backup_path = back_up_file(&path, &arguments).unwrap().unwrap();
+  assert_eq!(backup_path, codebase.join("pkg").join("sample.go.orig"));
+  assert_eq!(fs::read_to_string(backup_path).unwrap(), "package pkg\n");
+
+  let backup_dir = temp_dir.path().join("backups");
+  let arguments = get_arguments(&codebase, true, &backup_dir.to_string_lossy());
+  let backup_path = back_up_file(&path, &arguments).unwrap().unwrap();
+  assert_eq!(backup_path, backup_dir.join("pkg").join("sample.go"));
+  assert_eq!(fs::read_to_string(backup_path).unwrap(), "package pkg\n");
+}
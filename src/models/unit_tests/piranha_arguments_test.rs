@@ -11,12 +11,19 @@
  limitations under the License.
 */
 
+use std::collections::HashMap;
+
+use itertools::Itertools;
+
 use crate::{
-  models::{default_configs::JAVA, language::PiranhaLanguage},
+  models::{
+    default_configs::{GO, JAVA},
+    language::PiranhaLanguage,
+  },
   tests::substitutions,
 };
 
-use super::PiranhaArgumentsBuilder;
+use super::{expand_commands, PiranhaArguments, PiranhaArgumentsBuilder};
 
 #[test]
 #[should_panic(expected = "Invalid Piranha Argument. Missing `path_to_codebase` or `code_snippet`")]
@@ -41,3 +48,310 @@ fn piranha_argument_invalid_both_codebase_and_snippet() {
     .substitutions(substitutions! {"super_interface_name" => "SomeInterface"})
     .build();
 }
+
+#[test]
+#[should_panic(expected = "The rule pack `launchdarkly` is not supported for the language `java`")]
+fn piranha_argument_invalid_rule_pack() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("dev/null".to_string())
+    .language(PiranhaLanguage::from(JAVA))
+    .rule_packs(vec!["launchdarkly".to_string()])
+    .build();
+}
+
+#[test]
+#[should_panic(
+  expected = "The flag `stale_flag_name=flag_a,treated` is not a comma-separated list of substitutions"
+)]
+fn piranha_argument_invalid_flag() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("dev/null".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .flags(vec!["stale_flag_name=flag_a,treated".to_string()])
+    .build();
+}
+
+/// The substitutions of each flag extend (or override) the common ones
+#[test]
+fn piranha_argument_flags() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("dev/null".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {"treated" => "true", "namespace" => "checkout"})
+    .flags(vec![
+      "stale_flag_name=flag_a".to_string(),
+      "stale_flag_name=flag_b,treated=false".to_string(),
+    ])
+    .build();
+  let flag_substitutions = piranha_arguments
+    .flag_arguments()
+    .iter()
+    .map(|a| a.input_substitutions())
+    .collect_vec();
+  assert_eq!(
+    flag_substitutions,
+    vec![
+      HashMap::from([
+        ("stale_flag_name".to_string(), "flag_a".to_string()),
+        ("treated".to_string(), "true".to_string()),
+        ("namespace".to_string(), "checkout".to_string()),
+      ]),
+      HashMap::from([
+        ("stale_flag_name".to_string(), "flag_b".to_string()),
+        ("treated".to_string(), "false".to_string()),
+        ("namespace".to_string(), "checkout".to_string()),
+      ]),
+    ]
+  );
+  assert!(piranha_arguments
+    .flag_arguments()
+    .iter()
+    .all(|a| a.flags().is_empty()));
+}
+
+/// The mode of a flag is not a substitution, and overrides the common one
+#[test]
+fn piranha_argument_flags_mode() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("dev/null".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .flags(vec![
+      "stale_flag_name=flag_a,treated=true,mode=substitute-only".to_string(),
+      "stale_flag_name=flag_b,treated=true".to_string(),
+    ])
+    .build();
+  let flag_arguments = piranha_arguments.flag_arguments();
+  assert!(*flag_arguments[0].substitute_only());
+  assert!(!flag_arguments[0].input_substitutions().contains_key("mode"));
+  assert!(!*flag_arguments[1].substitute_only());
+}
+
+#[test]
+#[should_panic(expected = "The mode `keep` is not supported")]
+fn piranha_argument_invalid_flag_mode() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("dev/null".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .flags(vec!["stale_flag_name=flag_a,mode=keep".to_string()])
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "Could not get the files changed since `origin/unknown_ref`")]
+fn piranha_argument_invalid_since() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .since("origin/unknown_ref".to_string())
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "The confidence `certain` is not supported")]
+fn piranha_argument_invalid_confidence_threshold() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .confidence_threshold("certain".to_string())
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "The log format `yaml` is not supported")]
+fn piranha_argument_invalid_log_format() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .log_format("yaml".to_string())
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "The position `main.go` is not of the form `path:line`")]
+fn piranha_argument_invalid_explain_position() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .explain("main.go".to_string())
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "`serve` requires `lsp`")]
+fn piranha_argument_serve_without_lsp() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .serve(true)
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "`pull_request` is exclusive with `dry_run`")]
+fn piranha_argument_pull_request_with_dry_run() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .pull_request(true)
+    .dry_run(true)
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "The scm `gitea` is not supported")]
+fn piranha_argument_unsupported_scm() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .scm("gitea".to_string())
+    .build();
+}
+
+#[test]
+fn piranha_argument_check() {
+  let piranha_argument = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .flags(vec!["stale_flag".to_string()])
+    .check(true)
+    .build();
+  // No edit is made, and the value of the flag defaults to `true`
+  assert!(*piranha_argument.dry_run());
+  let flag_arguments = &piranha_argument.flag_arguments()[0];
+  assert_eq!(
+    flag_arguments.input_substitutions(),
+    HashMap::from([
+      ("stale_flag_name".to_string(), "stale_flag".to_string()),
+      ("treated".to_string(), "true".to_string()),
+      ("treated_complement".to_string(), "false".to_string()),
+    ])
+  );
+}
+
+#[test]
+fn test_expand_commands() {
+  let args = |a: &[&str]| a.iter().map(|s| s.to_string()).collect_vec();
+  assert_eq!(
+    expand_commands(args(&["piranha", "check", "--flag", "stale_flag"]).into_iter()),
+    args(&["piranha", "--check", "--flag", "stale_flag"])
+  );
+  assert_eq!(
+    expand_commands(args(&["piranha", "-c", "check"]).into_iter()),
+    args(&["piranha", "-c", "check"])
+  );
+  assert_eq!(
+    expand_commands(args(&["piranha", "serve", "--lsp"]).into_iter()),
+    args(&["piranha", "--serve", "--lsp"])
+  );
+  assert_eq!(
+    expand_commands(args(&["piranha", "revert", "--path-to-journal", "journal.json"]).into_iter()),
+    args(&["piranha", "--revert", "--path-to-journal", "journal.json"])
+  );
+  assert_eq!(
+    expand_commands(args(&["piranha", "scan", "--stale-after", "180d"]).into_iter()),
+    args(&["piranha", "--scan", "--stale-after", "180d"])
+  );
+  assert_eq!(
+    expand_commands(args(&["piranha", "report", "--report", "json"]).into_iter()),
+    args(&["piranha", "--inventory", "--report", "json"])
+  );
+}
+
+#[test]
+#[should_panic(expected = "The `unleash` flag provider requires `archive_url`")]
+fn piranha_argument_archive_without_url() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .archive("unleash".to_string())
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "`revert` requires `path_to_journal`")]
+fn piranha_argument_revert_without_journal() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .revert(true)
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "The duration `180 days` is not a number followed by its unit")]
+fn piranha_argument_scan_invalid_stale_after() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .scan(true)
+    .stale_after("180 days".to_string())
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "The notification format `teams` is not supported")]
+fn piranha_argument_invalid_notify_format() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .notify_format("teams".to_string())
+    .build();
+}
+
+#[test]
+#[should_panic(
+  expected = "`watch` requires `path_to_codebase` and is exclusive with `pull_request`"
+)]
+fn piranha_argument_watch_with_pull_request() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .watch(true)
+    .pull_request(true)
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "`serve` requires `lsp` or `http_address`")]
+fn piranha_argument_serve_with_lsp_and_http_address() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("test-resources/go".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .serve(true)
+    .lsp(true)
+    .http_address("127.0.0.1:8080".to_string())
+    .build();
+}
+
+#[test]
+#[should_panic(expected = "neither `interactive`, `verify_build`, `verify_tests`")]
+fn piranha_argument_http_address_with_verify_tests() {
+  let _ = PiranhaArgumentsBuilder::default()
+    .language(PiranhaLanguage::from(GO))
+    .serve(true)
+    .http_address("127.0.0.1:8080".to_string())
+    .verify_tests("report".to_string())
+    .build();
+}
+
+#[test]
+fn piranha_argument_jobs_short_name() {
+  // `-J` is the short name of `--jobs`, since `-j` is the one of `--path-to-output-summary`
+  let args = [
+    "polyglot_piranha",
+    "-c",
+    "some/path",
+    "-l",
+    GO,
+    "-J",
+    "2",
+    "-j",
+    "summary.json",
+  ];
+  let piranha_arguments = PiranhaArguments::from_args(args.iter().map(|a| a.to_string()));
+  assert_eq!(*piranha_arguments.jobs(), 2);
+  assert_eq!(
+    piranha_arguments.path_to_output_summary().as_deref(),
+    Some("summary.json")
+  );
+}
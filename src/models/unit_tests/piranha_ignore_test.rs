@@ -0,0 +1,59 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::path::Path;
+
+use super::parse_piranha_ignore;
+
+fn is_ignored(content: &str, path: &str) -> bool {
+  parse_piranha_ignore(content)
+    .iter()
+    .any(|p| p.matches_path(Path::new(path)))
+}
+
+#[test]
+fn test_parse_piranha_ignore_directories() {
+  let content = "# The vendored dependencies
+vendor/
+
+/third_party
+";
+  assert!(is_ignored(content, "vendor/github.com/lib/client.go"));
+  assert!(is_ignored(content, "services/checkout/vendor/client.go"));
+  assert!(is_ignored(content, "third_party/proto/flags.go"));
+  // Anchored at the root of the code base
+  assert!(!is_ignored(content, "services/third_party/flags.go"));
+  assert!(!is_ignored(content, "services/checkout/client.go"));
+}
+
+#[test]
+fn test_parse_piranha_ignore_files() {
+  let content = "sandbox_*.go
+experimental/*.go";
+  assert!(is_ignored(content, "sandbox_checkout.go"));
+  assert!(is_ignored(content, "services/sandbox_checkout.go"));
+  assert!(is_ignored(content, "experimental/search.go"));
+  assert!(!is_ignored(content, "services/experimental/search.go"));
+  assert!(!is_ignored(content, "checkout.go"));
+}
+
+#[test]
+fn test_parse_piranha_ignore_skips_comments_and_negations() {
+  let patterns = parse_piranha_ignore(
+    "# generated trees
+
+!vendor/keep.go
+",
+  );
+  assert!(patterns.is_empty());
+}
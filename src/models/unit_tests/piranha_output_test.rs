@@ -0,0 +1,65 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use super::PiranhaOutputSummary;
+
+fn get_summary(path: &str, original_content: &str, content: &str) -> PiranhaOutputSummary {
+  PiranhaOutputSummary {
+    path: path.to_string(),
+    original_content: original_content.to_string(),
+    content: content.to_string(),
+    ..Default::default()
+  }
+}
+
+#[test]
+fn test_unified_diff() {
+  let summary = get_summary(
+    "./src/main.go",
+    "package main
+
+func run() {
+\tif exp.BoolValue(\"stale_flag\") {
+\t\tfmt.Println(\"enabled\")
+\t}
+}
+",
+    "package main
+
+func run() {
+\tfmt.Println(\"enabled\")
+}
+",
+  );
+  assert_eq!(
+    summary.unified_diff(),
+    "--- a/src/main.go
++++ b/src/main.go
+@@ -1,7 +1,5 @@
+ package main
+ 
+ func run() {
+-\tif exp.BoolValue(\"stale_flag\") {
+-\t\tfmt.Println(\"enabled\")
+-\t}
++\tfmt.Println(\"enabled\")
+ }
+"
+  );
+}
+
+#[test]
+fn test_unified_diff_unchanged() {
+  let summary = get_summary("src/main.go", "package main\n", "package main\n");
+  assert!(summary.unified_diff().is_empty());
+}
@@ -0,0 +1,145 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use tree_sitter::{Point, Range};
+
+use crate::{
+  models::{
+    default_configs::GO, edit::Edit, language::PiranhaLanguage,
+    piranha_arguments::PiranhaArgumentsBuilder, rule::InstantiatedRule, rule_store::RuleStore,
+    source_code_unit::SourceCodeUnit,
+  },
+  piranha_rule,
+};
+
+static ORIGINAL_CONTENT: &str = "package main
+
+var enabled = isEnabled(\"stale_flag\")
+";
+
+static REWRITTEN_CONTENT: &str = "package main
+
+var enabled = true
+";
+
+/// Returns the source code unit of a file rewritten by Piranha, to be post-processed by the `post_processing_hook`
+fn get_rewritten_source_code_unit(post_processing_hook: &str) -> SourceCodeUnit {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .post_processing_hook(post_processing_hook.to_string())
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    ORIGINAL_CONTENT.to_string(),
+    &HashMap::new(),
+    PathBuf::from("main.go").as_path(),
+    &piranha_arguments,
+  );
+  let start_byte = ORIGINAL_CONTENT.find("isEnabled").unwrap();
+  let end_byte = ORIGINAL_CONTENT.rfind(')').unwrap() + 1;
+  let edit = Edit::delete_range(
+    ORIGINAL_CONTENT,
+    Range {
+      start_byte,
+      end_byte,
+      start_point: Point::new(2, start_byte - 14),
+      end_point: Point::new(2, end_byte - 14),
+    },
+  );
+  source_code_unit.rewrites_mut().push(edit);
+  source_code_unit.set_code(REWRITTEN_CONTENT.to_string());
+  source_code_unit
+}
+
+#[test]
+fn test_post_processing_hook_accept() {
+  let mut source_code_unit = get_rewritten_source_code_unit("cat > /dev/null");
+  source_code_unit.apply_post_processing_hook();
+  assert_eq!(source_code_unit.code(), REWRITTEN_CONTENT);
+  assert_eq!(source_code_unit.rewrites().len(), 1);
+}
+
+/// The hook receives the file (along with its rewrites) on its stdin
+#[test]
+fn test_post_processing_hook_veto() {
+  let mut source_code_unit =
+    get_rewritten_source_code_unit(r#"grep -q '"path":"main.go"' && echo '{"veto": true}'"#);
+  source_code_unit.apply_post_processing_hook();
+  assert_eq!(source_code_unit.code(), ORIGINAL_CONTENT);
+  assert!(source_code_unit.rewrites().is_empty());
+}
+
+#[test]
+fn test_post_processing_hook_modify() {
+  let mut source_code_unit = get_rewritten_source_code_unit(
+    r#"cat > /dev/null; printf '%s' '{"content": "package main\n\n// Cleaned up stale_flag\nvar enabled = true\n"}'"#,
+  );
+  source_code_unit.apply_post_processing_hook();
+  assert_eq!(
+    source_code_unit.code(),
+    "package main\n\n// Cleaned up stale_flag\nvar enabled = true\n"
+  );
+  assert_eq!(source_code_unit.rewrites().len(), 1);
+}
+
+/// The content of the hook is parsed again, so that the rules applied afterwards match it
+#[test]
+fn test_post_processing_hook_modify_then_rule() {
+  let mut source_code_unit = get_rewritten_source_code_unit(
+    r#"cat > /dev/null; printf '%s' '{"content": "package main\n\n// Cleaned up stale_flag\nvar enabled = true\n"}'"#,
+  );
+  source_code_unit.apply_post_processing_hook();
+  let rule = piranha_rule! {
+    name= "replace_true",
+    query= "((true) @literal)",
+    replace_node= "literal",
+    replace= "false"
+  };
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  source_code_unit.apply_rules(
+    &mut RuleStore::default(),
+    &[InstantiatedRule::new(&rule, &HashMap::new())],
+    &mut parser,
+    None,
+  );
+  assert_eq!(
+    source_code_unit.code(),
+    "package main\n\n// Cleaned up stale_flag\nvar enabled = false\n"
+  );
+}
+
+/// A hook streaming its output while reading its input (e.g. `cat`) does not block, even when both exceed the capacity of a pipe
+#[test]
+fn test_post_processing_hook_streamed_output() {
+  let mut source_code_unit = get_rewritten_source_code_unit("cat; exit 1");
+  let edit = source_code_unit.rewrites()[0].clone();
+  source_code_unit.rewrites_mut().extend(vec![edit; 10000]);
+  source_code_unit.apply_post_processing_hook();
+  assert_eq!(source_code_unit.code(), REWRITTEN_CONTENT);
+  assert_eq!(source_code_unit.rewrites().len(), 10001);
+}
+
+/// The rewrites are kept as they are when the hook fails
+#[test]
+fn test_post_processing_hook_failure() {
+  for post_processing_hook in ["exit 1", "echo 'not json'"] {
+    let mut source_code_unit = get_rewritten_source_code_unit(post_processing_hook);
+    source_code_unit.apply_post_processing_hook();
+    assert_eq!(source_code_unit.code(), REWRITTEN_CONTENT);
+    assert_eq!(source_code_unit.rewrites().len(), 1);
+  }
+}
@@ -0,0 +1,67 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+};
+
+use super::Provenance;
+
+fn provenance(origin: &str, inter_procedural: bool) -> Provenance {
+  Provenance {
+    origin: origin.to_string(),
+    inter_procedural,
+  }
+}
+
+#[test]
+fn test_provenance() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .aggressive_dead_code(true)
+    .rule_packs(vec!["launchdarkly".to_string()])
+    .build();
+  assert_eq!(
+    Provenance::of("boolean_literal_cleanup", &piranha_arguments),
+    provenance("built_in", false)
+  );
+  // Applied to the calls in any file of the package (i.e. the target of a `Global` edge)
+  assert_eq!(
+    Provenance::of(
+      "replace_call_to_function_returning_boolean_literal",
+      &piranha_arguments
+    ),
+    provenance("built_in", true)
+  );
+  assert_eq!(
+    Provenance::of("delete_call_to_empty_function", &piranha_arguments),
+    provenance("aggressive_dead_code", true)
+  );
+  assert_eq!(
+    Provenance::of("replace_launchdarkly_bool_variation", &piranha_arguments),
+    provenance("rule_pack", false)
+  );
+  assert_eq!(
+    Provenance::of("specialize_boolean_parameter", &piranha_arguments),
+    provenance("pass", true)
+  );
+  assert_eq!(
+    Provenance::of("remove_unused_imports", &piranha_arguments),
+    provenance("pass", false)
+  );
+  assert_eq!(
+    Provenance::of("replace_is_enabled", &piranha_arguments),
+    provenance("user", false)
+  );
+}
@@ -0,0 +1,88 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::Path};
+
+use tree_sitter::{Point, Range};
+
+use crate::models::{
+  default_configs::GO, edit::Edit, language::PiranhaLanguage, matches::Match,
+  piranha_arguments::PiranhaArgumentsBuilder, piranha_output::PiranhaOutputSummary,
+  source_code_unit::SourceCodeUnit, statistics::RunStatistics,
+};
+
+use super::{get_body, render_template};
+
+#[test]
+fn test_render_template() {
+  let substitutions = HashMap::from([
+    ("flag".to_string(), "enable_search".to_string()),
+    ("treated".to_string(), "true".to_string()),
+  ]);
+  assert_eq!(
+    render_template("piranha/{flag}-{treated}", &substitutions),
+    "piranha/enable_search-true"
+  );
+  // Unknown placeholders are left as they are
+  assert_eq!(
+    render_template("Clean up {flag} ({owner})", &substitutions),
+    "Clean up enable_search ({owner})"
+  );
+}
+
+#[test]
+fn test_get_body() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let original_content = "package main\n\nfunc run() {\n\tif true {\n\t\tprintln()\n\t}\n}\n";
+  let content = "package main\n\nfunc run() {\n\tprintln()\n}\n";
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    original_content.to_string(),
+    &HashMap::new(),
+    Path::new("search/search.go"),
+    &piranha_arguments,
+  );
+  let edit = Edit::new(
+    Match::new(
+      original_content.to_string(),
+      Range {
+        start_byte: 0,
+        end_byte: original_content.len(),
+        start_point: Point::new(0, 0),
+        end_point: Point::new(7, 0),
+      },
+      HashMap::new(),
+    ),
+    content.to_string(),
+    "rewrite".to_string(),
+    &original_content.to_string(),
+  );
+  source_code_unit.apply_edit(&edit, &mut parser);
+  let summary = PiranhaOutputSummary::new(&source_code_unit);
+
+  let body = get_body(
+    "Part of the search rollout.",
+    "enable_search",
+    Some("true"),
+    &[("search/search.go".to_string(), &summary)],
+    &RunStatistics::default(),
+  );
+  assert!(body.starts_with(
+    "Part of the search rollout.\n\nCleans up the stale flag `enable_search` (treated as `true`) with Piranha.\n"
+  ));
+  assert!(body.contains("| `search/search.go` | 1 | 3 |\n"));
+  assert!(body.contains("<summary>Statistics</summary>"));
+}
@@ -0,0 +1,224 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, fs, path::PathBuf};
+
+use serde_json::{json, Value};
+use tempdir::TempDir;
+use tree_sitter::{Point, Range};
+
+use crate::models::{
+  build_verification::TEST_FAILURE, default_configs::GO, edit::Edit, language::PiranhaLanguage,
+  matches::Match, piranha_arguments::PiranhaArgumentsBuilder, piranha_output::PiranhaOutputSummary,
+  source_code_unit::SourceCodeUnit,
+};
+
+use super::ChangeReport;
+
+static ORIGINAL_CONTENT: &str = "package main
+
+var enabled = isEnabled(\"stale_flag\")
+var legacy = isEnabled(\"stale_flag\") // piranha:ignore
+";
+
+static REWRITTEN_CONTENT: &str = "package main
+
+var enabled = true
+var legacy = isEnabled(\"stale_flag\") // piranha:ignore
+";
+
+/// Returns the match of the `index`-th call to `isEnabled` (on the `row`)
+fn get_match(index: usize, row: usize) -> Match {
+  let (start_byte, _) = ORIGINAL_CONTENT
+    .match_indices("isEnabled")
+    .nth(index)
+    .unwrap();
+  let end_byte = start_byte + "isEnabled(\"stale_flag\")".len();
+  let column = start_byte - ORIGINAL_CONTENT[..start_byte].rfind('\n').unwrap() - 1;
+  Match::new(
+    ORIGINAL_CONTENT[start_byte..end_byte].to_string(),
+    Range {
+      start_byte,
+      end_byte,
+      start_point: Point::new(row, column),
+      end_point: Point::new(row, column + end_byte - start_byte),
+    },
+    HashMap::new(),
+  )
+}
+
+#[test]
+fn test_change_report() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![
+      ("stale_flag_name".to_string(), "stale_flag".to_string()),
+      ("treated".to_string(), "true".to_string()),
+    ])
+    .report("json".to_string())
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    ORIGINAL_CONTENT.to_string(),
+    &HashMap::new(),
+    PathBuf::from("main.go").as_path(),
+    &piranha_arguments,
+  );
+  let edit = Edit::new(
+    get_match(0, 2),
+    "true".to_string(),
+    "replace_is_enabled".to_string(),
+    &ORIGINAL_CONTENT.to_string(),
+  );
+  source_code_unit.rewrites_mut().push(edit);
+  source_code_unit
+    .suppressed_matches_mut()
+    .push(("replace_is_enabled".to_string(), get_match(1, 3)));
+  source_code_unit.set_code(REWRITTEN_CONTENT.to_string());
+
+  let summaries = vec![PiranhaOutputSummary::new(&source_code_unit)];
+  let report: Value =
+    serde_json::from_str(&ChangeReport::new(&summaries, &piranha_arguments).to_json()).unwrap();
+
+  assert_eq!(report["lines_added"], json!(1));
+  assert_eq!(report["lines_removed"], json!(1));
+  let file = &report["files"][0];
+  assert_eq!(file["path"], json!("main.go"));
+  assert_eq!(
+    file["rewrites"],
+    json!([{
+      "flag": "stale_flag",
+      "rule": "replace_is_enabled",
+      "origin": "user",
+      "inter_procedural": false,
+      "range": {"start_byte": 28, "end_byte": 51, "start_line": 3, "end_line": 3},
+      "code": "isEnabled(\"stale_flag\")",
+      "replacement": "true",
+      "lines_removed": 1,
+      "lines_added": 1
+    }])
+  );
+  assert_eq!(file["warnings"][0]["kind"], json!("suppressed_match"));
+  assert_eq!(file["warnings"][0]["rule"], json!("replace_is_enabled"));
+  assert_eq!(file["warnings"][0]["range"]["start_line"], json!(4));
+}
+
+#[test]
+fn test_change_report_modules() {
+  let temp_dir = TempDir::new("report_modules").unwrap();
+  let root = temp_dir.path().canonicalize().unwrap();
+  fs::write(
+    root.join("go.work"),
+    "go 1.21\n\nuse (\n\t./payments\n\t./search\n)\n",
+  )
+  .unwrap();
+  for (directory, module_path) in [
+    ("payments", "example.com/shop/payments"),
+    ("search", "example.com/shop/search"),
+  ] {
+    fs::create_dir_all(root.join(directory)).unwrap();
+    fs::write(
+      root.join(directory).join("go.mod"),
+      format!("module {module_path}\n"),
+    )
+    .unwrap();
+  }
+  fs::write(root.join("payments/OWNERS"), "payments@example.com\n").unwrap();
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(root.to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![
+      ("stale_flag_name".to_string(), "stale_flag".to_string()),
+      ("treated".to_string(), "true".to_string()),
+    ])
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    ORIGINAL_CONTENT.to_string(),
+    &HashMap::new(),
+    root.join("payments/main.go").as_path(),
+    &piranha_arguments,
+  );
+  source_code_unit.rewrites_mut().push(Edit::new(
+    get_match(0, 2),
+    "true".to_string(),
+    "replace_is_enabled".to_string(),
+    &ORIGINAL_CONTENT.to_string(),
+  ));
+  source_code_unit.set_code(REWRITTEN_CONTENT.to_string());
+
+  let summaries = vec![PiranhaOutputSummary::new(&source_code_unit)];
+  let report: Value =
+    serde_json::from_str(&ChangeReport::new(&summaries, &piranha_arguments).to_json()).unwrap();
+
+  assert_eq!(
+    report["files"][0]["module"],
+    json!("example.com/shop/payments")
+  );
+  // The files are annotated with their owners
+  assert_eq!(
+    report["files"][0]["owners"],
+    json!(["payments@example.com"])
+  );
+  // The modules without changes are not reported
+  assert_eq!(
+    report["modules"],
+    json!([{
+      "module": "example.com/shop/payments",
+      "files": 1,
+      "lines_added": 1,
+      "lines_removed": 1
+    }])
+  );
+}
+
+#[test]
+fn test_change_report_test_failure() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .verify_tests("rollback".to_string())
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    ORIGINAL_CONTENT.to_string(),
+    &HashMap::new(),
+    PathBuf::from("main.go").as_path(),
+    &piranha_arguments,
+  );
+  // The rewrites of the package are rolled back, since its tests fail
+  source_code_unit
+    .report_package_verification(TEST_FAILURE, Some("--- FAIL: TestEnabled (0.00s)\nFAIL"));
+
+  let summaries = vec![PiranhaOutputSummary::new(&source_code_unit)];
+  let report: Value =
+    serde_json::from_str(&ChangeReport::new(&summaries, &piranha_arguments).to_json()).unwrap();
+
+  let file = &report["files"][0];
+  assert_eq!(file["tests"], json!("failed"));
+  assert_eq!(file["rewrites"], json!([]));
+  assert_eq!(
+    file["warnings"],
+    json!([{
+      "kind": "test_failure",
+      "rule": null,
+      "range": {"start_byte": 0, "end_byte": 12, "start_line": 1, "end_line": 1},
+      "code": "package main",
+      "message": "--- FAIL: TestEnabled (0.00s)\nFAIL"
+    }])
+  );
+}
@@ -0,0 +1,88 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashMap;
+
+use tree_sitter::{Point, Range};
+
+use crate::models::{edit::Edit, matches::Match};
+
+use super::{get_region, map_to_original, SarifRegion};
+
+/// Returns the match of the bytes `start_byte..end_byte` of the code (on its first two lines)
+fn get_match(code: &str, start_byte: usize, end_byte: usize) -> Match {
+  let point = |offset: usize| {
+    let row = code[..offset].matches('\n').count();
+    let column = offset - code[..offset].rfind('\n').map(|i| i + 1).unwrap_or(0);
+    Point::new(row, column)
+  };
+  Match::new(
+    code[start_byte..end_byte].to_string(),
+    Range {
+      start_byte,
+      end_byte,
+      start_point: point(start_byte),
+      end_point: point(end_byte),
+    },
+    HashMap::new(),
+  )
+}
+
+#[test]
+fn test_map_to_original() {
+  let original_content = "a = f(x)\nb = f(x)\n";
+  let rewritten_content = "a = 1\nb = f(x)\n";
+  let rewrites = vec![
+    Edit::new(
+      get_match(original_content, 4, 8),
+      "1".to_string(),
+      "replace_f".to_string(),
+      &original_content.to_string(),
+    ),
+    Edit::new(
+      get_match(rewritten_content, 10, 14),
+      "1".to_string(),
+      "replace_f".to_string(),
+      &rewritten_content.to_string(),
+    ),
+  ];
+  // The second rewrite follows the first one
+  assert_eq!(
+    map_to_original(&rewrites, 1, rewrites[1].p_match()),
+    (13, 17)
+  );
+  // The replacement of the first rewrite is mapped to the code it replaced
+  assert_eq!(
+    map_to_original(&rewrites, 1, &get_match(rewritten_content, 4, 5)),
+    (4, 8)
+  );
+  // The code preceding the rewrites is left as it is
+  assert_eq!(
+    map_to_original(&rewrites, 2, &get_match(rewritten_content, 0, 1)),
+    (0, 1)
+  );
+}
+
+#[test]
+fn test_get_region() {
+  assert_eq!(
+    get_region("ab\ncdé\n", (3, 7)),
+    SarifRegion {
+      start_line: Some(2),
+      start_column: Some(1),
+      end_line: Some(2),
+      end_column: Some(4),
+      ..Default::default()
+    }
+  );
+}
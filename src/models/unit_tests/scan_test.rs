@@ -0,0 +1,156 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{fs, path::Path, process::Command};
+
+use tempdir::TempDir;
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+};
+
+use super::{format_date, parse_blame, parse_duration, scan_stale_flags};
+
+/// Commits all the files of the repository, authored at `date`
+fn commit(directory: &Path, date: &str, message: &str) {
+  for args in [
+    vec!["add", "-A"],
+    vec![
+      "-c",
+      "user.name=piranha",
+      "-c",
+      "user.email=piranha@example.com",
+      "commit",
+      "-m",
+      message,
+    ],
+  ] {
+    let output = Command::new("git")
+      .arg("-C")
+      .arg(directory)
+      .args(args)
+      .env("GIT_AUTHOR_DATE", date)
+      .env("GIT_COMMITTER_DATE", date)
+      .output()
+      .unwrap();
+    assert!(output.status.success());
+  }
+}
+
+#[test]
+fn test_scan_stale_flags() {
+  let temp_dir = TempDir::new("scan").unwrap();
+  let codebase = temp_dir.path().join("codebase");
+  let configurations = temp_dir.path().join("configurations");
+  fs::create_dir_all(&codebase).unwrap();
+  fs::create_dir_all(&configurations).unwrap();
+  fs::write(
+    configurations.join("flag_apis.toml"),
+    "[[flag_apis]]\npackage = \"github.com/company/exp\"\nfunction = \"IsEnabled\"\nflag_argument_index = 1\n",
+  )
+  .unwrap();
+  Command::new("git")
+    .arg("init")
+    .arg(&codebase)
+    .output()
+    .unwrap();
+  fs::write(
+    codebase.join("CODEOWNERS"),
+    "*.go @company/search\ncheckout.go @company/checkout\n",
+  )
+  .unwrap();
+  fs::write(
+    codebase.join("search.go"),
+    "package main\n\nimport \"github.com/company/exp\"\n\nfunc search() {\n\tif exp.IsEnabled(ctx, \"enable_search\") {\n\t\tquery()\n\t}\n\tif exp.IsEnabled(ctx, \"enable_ranking\") {\n\t\trank()\n\t}\n}\n",
+  )
+  .unwrap();
+  commit(&codebase, "2020-01-01T00:00:00Z", "Add the search");
+  // The second call site of the flag is modified recently, i.e. the flag is not stale
+  fs::write(
+    codebase.join("checkout.go"),
+    "package main\n\nimport experiments \"github.com/company/exp\"\n\nfunc checkout() {\n\tif experiments.IsEnabled(ctx, \"enable_search\") {\n\t\tpay()\n\t}\n\tif experiments.IsEnabled(ctx, \"enable_wallet\") {\n\t\twallet()\n\t}\n}\n",
+  )
+  .unwrap();
+  commit(&codebase, "2021-06-01T00:00:00Z", "Add the checkout");
+
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(codebase.to_string_lossy().to_string())
+    .path_to_configurations(configurations.to_string_lossy().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .scan(true)
+    .stale_after("1y".to_string())
+    .build();
+  let flags = scan_stale_flags(&piranha_arguments).unwrap();
+  assert_eq!(
+    flags.iter().map(|f| f.flag().as_str()).collect::<Vec<_>>(),
+    vec!["enable_ranking", "enable_search", "enable_wallet"]
+  );
+
+  let ranking = &flags[0];
+  assert_eq!(format_date(*ranking.introduced()), "2020-01-01");
+  assert_eq!(format_date(*ranking.last_modified()), "2020-01-01");
+  assert_eq!(ranking.owners(), &vec!["@company/search".to_string()]);
+  assert_eq!(ranking.authors(), &vec!["piranha@example.com".to_string()]);
+
+  let search = &flags[1];
+  assert_eq!(search.sites().len(), 2);
+  assert_eq!(search.sites()[0].path(), "checkout.go");
+  assert_eq!(*search.sites()[0].line(), 6);
+  assert_eq!(format_date(*search.introduced()), "2020-01-01");
+  assert_eq!(format_date(*search.last_modified()), "2021-06-01");
+  assert_eq!(
+    search.owners(),
+    &vec![
+      "@company/checkout".to_string(),
+      "@company/search".to_string()
+    ]
+  );
+
+  // The recently modified flags are not stale
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(codebase.to_string_lossy().to_string())
+    .path_to_configurations(configurations.to_string_lossy().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .scan(true)
+    .stale_after("100y".to_string())
+    .build();
+  assert!(scan_stale_flags(&piranha_arguments).unwrap().is_empty());
+}
+
+#[test]
+fn test_parse_blame() {
+  let lines = parse_blame(
+    "9f0e2d1b3c4a5f60718293a4b5c6d7e8f9012345 1 1 2\nauthor piranha\nauthor-mail <piranha@example.com>\nauthor-time 1577836800\nsummary Add the search\nfilename search.go\n\tpackage main\n9f0e2d1b3c4a5f60718293a4b5c6d7e8f9012345 2 2\nauthor piranha\nauthor-mail <piranha@example.com>\nauthor-time 1577836800\nfilename search.go\n\t\n0000000000000000000000000000000000000000 3 3 1\nauthor Not Committed Yet\nauthor-mail <not.committed.yet>\nauthor-time 1622505600\nfilename search.go\n\tfunc search() {}\n",
+  );
+  assert_eq!(lines.len(), 3);
+  assert_eq!(lines[&1], (1577836800, "piranha@example.com".to_string()));
+  assert_eq!(lines[&3], (1622505600, String::new()));
+}
+
+#[test]
+fn test_parse_duration() {
+  assert_eq!(parse_duration("180d"), Ok(180 * 86400));
+  assert_eq!(parse_duration("2w"), Ok(14 * 86400));
+  assert_eq!(parse_duration("6m"), Ok(180 * 86400));
+  assert_eq!(parse_duration("1y"), Ok(365 * 86400));
+  assert!(parse_duration("180").is_err());
+  assert!(parse_duration("d").is_err());
+  assert!(parse_duration("-1d").is_err());
+}
+
+#[test]
+fn test_format_date() {
+  assert_eq!(format_date(0), "1970-01-01");
+  assert_eq!(format_date(951782400), "2000-02-29");
+  assert_eq!(format_date(1622505600), "2021-06-01");
+}
@@ -0,0 +1,82 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use serde_json::json;
+
+use super::{get_error_message, get_github_reviewers, get_remote_path, get_usernames};
+
+#[test]
+fn test_get_remote_path() {
+  let expected = Some("uber/piranha".to_string());
+  assert_eq!(
+    get_remote_path("https://github.com/uber/piranha.git"),
+    expected
+  );
+  assert_eq!(get_remote_path("https://github.com/uber/piranha"), expected);
+  assert_eq!(
+    get_remote_path("ssh://git@github.com/uber/piranha.git"),
+    expected
+  );
+  assert_eq!(get_remote_path("git@github.com:uber/piranha.git"), expected);
+  // The nested groups of GitLab
+  assert_eq!(
+    get_remote_path("git@gitlab.example.com:platform/flags/piranha.git"),
+    Some("platform/flags/piranha".to_string())
+  );
+  // The HTTP and SSH URLs of Bitbucket Data Center
+  assert_eq!(
+    get_remote_path("https://bitbucket.example.com/scm/flags/piranha.git"),
+    Some("flags/piranha".to_string())
+  );
+  assert_eq!(
+    get_remote_path("ssh://git@bitbucket.example.com:7999/flags/piranha.git"),
+    Some("flags/piranha".to_string())
+  );
+  assert_eq!(get_remote_path("/tmp/piranha.git"), None);
+}
+
+#[test]
+fn test_get_reviewers() {
+  let owners = [
+    "@alice".to_string(),
+    "@org/payments".to_string(),
+    "docs@example.com".to_string(),
+  ];
+  assert_eq!(
+    get_github_reviewers(&owners),
+    (vec!["alice".to_string()], vec!["payments".to_string()])
+  );
+  assert_eq!(get_usernames(&owners), vec!["alice".to_string()]);
+}
+
+#[test]
+fn test_get_error_message() {
+  assert_eq!(
+    get_error_message(&json!({"message": "Validation Failed"})),
+    Some("Validation Failed".to_string())
+  );
+  assert_eq!(
+    get_error_message(&json!({"error": {"message": "branch not found"}})),
+    Some("branch not found".to_string())
+  );
+  assert_eq!(
+    get_error_message(&json!({"errors": [{"message": "reviewer unknown"}]})),
+    Some("reviewer unknown".to_string())
+  );
+  // The messages of GitLab may be lists of errors
+  assert_eq!(
+    get_error_message(&json!({"message": ["Another open merge request already exists"]})),
+    Some("[\"Another open merge request already exists\"]".to_string())
+  );
+  assert_eq!(get_error_message(&json!({})), None);
+}
@@ -193,3 +193,27 @@ fn test_get_scope_query_negative() {
   let mut rule_store = RuleStore::new(&piranha_args);
   let _ = source_code_unit.get_scope_query("Method", 9, 10, &mut rule_store);
 }
+
+/// The scope query is not found when the previous edit is not enclosed by the scope.
+#[test]
+fn test_find_scope_query_negative() {
+  let source_code = "class Test {
+      pub void foobar(int a, int b, int c, int d){
+        boolean isFlagTreated = true;
+      }
+    }";
+  let piranha_args = _get_piranha_args();
+  let mut parser = PiranhaLanguage::from(JAVA).parser();
+
+  let source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    source_code.to_string(),
+    &HashMap::new(),
+    PathBuf::new().as_path(),
+    &piranha_args,
+  );
+  let mut rule_store = RuleStore::new(&piranha_args);
+  assert!(source_code_unit
+    .find_scope_query("Method", 9, 10, &mut rule_store)
+    .is_none());
+}
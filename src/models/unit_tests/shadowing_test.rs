@@ -0,0 +1,71 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use tree_sitter::Node;
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, source_code_unit::SourceCodeUnit,
+  specialization::collect_nodes,
+};
+
+/// Returns the references (i.e. the arguments of the calls) to `staleFlag`, in the order they appear in the file
+fn get_references(source_code_unit: &SourceCodeUnit) -> Vec<Node> {
+  let mut references = collect_nodes(source_code_unit.root_node(), |n| {
+    n.kind() == "identifier"
+      && n.utf8_text(source_code_unit.code().as_bytes()).ok() == Some("staleFlag")
+      && n.parent().map(|p| p.kind() == "argument_list") == Some(true)
+  });
+  references.sort_by_key(|n| n.start_byte());
+  references
+}
+
+#[test]
+fn test_is_shadowed() {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let source_code_unit = SourceCodeUnit::default(
+    "package main
+
+    const staleFlag = \"stale_flag\"
+
+    func a() {
+      exp.BoolValue(staleFlag)
+      if x {
+        staleFlag := \"other\"
+        for i := range items {
+          exp.BoolValue(staleFlag)
+        }
+      }
+      exp.BoolValue(staleFlag)
+    }
+
+    func b(staleFlag string) {
+      go func() {
+        exp.BoolValue(staleFlag)
+      }()
+    }
+
+    func c() {
+      staleFlag := exp.BoolValue(staleFlag)
+      for _, staleFlag := range flags {
+        exp.BoolValue(staleFlag)
+      }
+    }",
+    &mut parser,
+    GO.to_string(),
+  );
+  let shadowed: Vec<bool> = get_references(&source_code_unit)
+    .iter()
+    .map(|r| source_code_unit.is_shadowed(*r))
+    .collect();
+  assert_eq!(shadowed, vec![false, true, false, true, false, true]);
+}
@@ -28,7 +28,7 @@ use crate::{
 };
 use {
   super::SourceCodeUnit,
-  crate::models::edit::Edit,
+  crate::models::{edit::Edit, matches::Match},
   std::{collections::HashMap, path::PathBuf},
   tree_sitter::Range,
 };
@@ -124,6 +124,79 @@ fn test_apply_edit_negative() {
   );
 }
 
+/// Checks that the consecutive newlines are only deleted around the edited code, leaving the rest of the file as it is.
+#[test]
+fn test_delete_consecutive_new_lines_only_around_edits() {
+  let source_code = "class Test {
+      void a() {
+        int x = 1;
+
+
+        int y = 2;
+      }
+
+      void b() {
+        int z = 3;
+
+        int w = 4;
+
+        int v = 5;
+      }
+    }";
+
+  let java = get_java_tree_sitter_language();
+  let mut parser = java.parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    source_code.to_string(),
+    &HashMap::new(),
+    PathBuf::new().as_path(),
+    &PiranhaArgumentsBuilder::default()
+      .path_to_codebase("some/test/path/".to_string())
+      .language(java)
+      .delete_consecutive_new_lines(true)
+      .build(),
+  );
+
+  let _ = source_code_unit.apply_edit(
+    &Edit::delete_range(source_code, range(124, 134, 11, 8, 11, 18)),
+    &mut parser,
+  );
+  let code = source_code_unit.code().to_string();
+  let _ = source_code_unit.apply_edit(
+    &Edit::new(
+      Match::new("a".to_string(), range(24, 25, 1, 11, 1, 12), HashMap::new()),
+      "c".to_string(),
+      "rename".to_string(),
+      &code,
+    ),
+    &mut parser,
+  );
+  assert_eq!(
+    source_code_unit.edited_ranges(),
+    &vec![(24, 25), (124, 124)]
+  );
+
+  source_code_unit.perform_delete_consecutive_new_lines();
+  assert_eq!(
+    source_code_unit.code(),
+    "class Test {
+      void c() {
+        int x = 1;
+
+
+        int y = 2;
+      }
+
+      void b() {
+        int z = 3;
+
+        int v = 5;
+      }
+    }"
+  );
+}
+
 /// Tests for contains, at_least, and at_most
 
 fn run_test_satisfies_filters(
@@ -631,3 +704,51 @@ fn test_contains_no_enclosing_positive() {
     |result| result,
   );
 }
+
+#[test]
+fn test_try_new_syntax_error() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .language(PiranhaLanguage::from(JAVA))
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let try_new = |code: &str, parser: &mut Parser| {
+    SourceCodeUnit::try_new(
+      parser,
+      code.to_string(),
+      &HashMap::new(),
+      PathBuf::from("A.java").as_path(),
+      &piranha_arguments,
+    )
+  };
+  assert!(try_new("class A { void f() { } }", &mut parser).is_some());
+  // The file is skipped instead of panicking
+  assert!(try_new("class A { void f( { } }", &mut parser).is_none());
+}
+
+#[test]
+fn test_discard_rewrites_re_parses_the_original_content() {
+  let source_code = "class Test {
+      public void foobar(){
+        boolean isFlagTreated = true;
+        isFlagTreated = true;
+      }
+    }";
+
+  let java = get_java_tree_sitter_language();
+  let mut parser = java.parser();
+
+  let mut source_code_unit =
+    SourceCodeUnit::default(source_code, &mut parser, java.extension().to_string());
+  let original_ast = source_code_unit.root_node().to_sexp();
+
+  let _ = source_code_unit.apply_edit(
+    &Edit::delete_range(source_code, range(49, 78, 3, 9, 3, 38)),
+    &mut parser,
+  );
+  source_code_unit.discard_rewrites();
+
+  assert_eq!(source_code_unit.code(), source_code);
+  // The AST matches the original content again, e.g. for the nodes reported afterwards
+  assert_eq!(source_code_unit.root_node().to_sexp(), original_ast);
+  assert_eq!(source_code_unit.root_node().end_byte(), source_code.len());
+}
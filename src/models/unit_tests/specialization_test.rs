@@ -0,0 +1,62 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, source_code_unit::SourceCodeUnit,
+};
+
+fn get_go_source_code_unit(code: &str) -> SourceCodeUnit {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  SourceCodeUnit::default(code, &mut parser, GO.to_string())
+}
+
+#[test]
+fn test_get_boolean_parameters() {
+  let source_code_unit = get_go_source_code_unit(
+    "package main
+
+    func process(ctx context.Context, a, b int, enabled bool) {}
+
+    func Exported(enabled bool) {}
+
+    func reassigned(enabled bool) {
+      enabled = !enabled
+    }
+
+    func (c *Client) method(enabled bool) {}",
+  );
+  let parameters = source_code_unit.get_boolean_parameters();
+  assert_eq!(parameters.len(), 1);
+  assert_eq!(parameters[0].function_name, "process");
+  assert_eq!(parameters[0].parameter_name, "enabled");
+  assert_eq!(parameters[0].position, 3);
+  assert_eq!(parameters[0].arity, 4);
+}
+
+#[test]
+fn test_get_call_arguments() {
+  let source_code_unit = get_go_source_code_unit(
+    "package main
+
+    func main() {
+      process(ctx, true)
+      process(ctx, values...)
+      other.process(ctx, false)
+    }",
+  );
+  assert_eq!(
+    source_code_unit.get_call_arguments("process"),
+    vec![vec!["ctx".to_string(), "true".to_string()]]
+  );
+  assert_eq!(source_code_unit.count_references("process"), 2);
+}
@@ -0,0 +1,150 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, fs, path::Path};
+
+use tempdir::TempDir;
+use tree_sitter::{Point, Range};
+
+use crate::models::{
+  code_owners::CodeOwners, default_configs::GO, edit::Edit, language::PiranhaLanguage,
+  matches::Match, piranha_arguments::PiranhaArgumentsBuilder, piranha_output::PiranhaOutputSummary,
+  source_code_unit::SourceCodeUnit,
+};
+
+use super::{get_chunk_key, split_summaries, Chunk};
+
+/// Returns the summary of the file at `path` whose code from `start` (a byte offset) to its end is replaced with `replacement`
+fn rewrite(
+  path: &Path, original_content: &str, start: usize, replacement: &str,
+) -> PiranhaOutputSummary {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .language(PiranhaLanguage::from(GO))
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    original_content.to_string(),
+    &HashMap::new(),
+    path,
+    &piranha_arguments,
+  );
+  let lines = original_content.lines().count();
+  let edit = Edit::new(
+    Match::new(
+      original_content[start..].to_string(),
+      Range {
+        start_byte: start,
+        end_byte: original_content.len(),
+        start_point: Point::new(2, 0),
+        end_point: Point::new(lines, 0),
+      },
+      HashMap::new(),
+    ),
+    replacement.to_string(),
+    "rewrite".to_string(),
+    &original_content.to_string(),
+  );
+  source_code_unit.apply_edit(&edit, &mut parser);
+  PiranhaOutputSummary::new(&source_code_unit)
+}
+
+#[test]
+fn test_split_summaries() {
+  let temp_dir = TempDir::new("split").unwrap();
+  let codebase = temp_dir.path();
+  for directory in ["checkout", "flags", "search"] {
+    fs::create_dir(codebase.join(directory)).unwrap();
+  }
+  let header = "package main\n\n";
+  let summaries = vec![
+    // Deletes the declaration of the flag, still used by `search` (but not `checkout`) before the cleanup
+    rewrite(
+      &codebase.join("flags/flags.go"),
+      &format!("{header}const EnableSearch = true\n"),
+      header.len(),
+      "",
+    ),
+    rewrite(
+      &codebase.join("search/search.go"),
+      &format!("{header}func run() {{\n\tif flags.EnableSearch {{\n\t\tsearch()\n\t}}\n}}\n"),
+      header.len(),
+      "func run() {\n\tsearch()\n}\n",
+    ),
+    rewrite(
+      &codebase.join("checkout/checkout.go"),
+      &format!("{header}func run() {{\n\tif true {{\n\t\tcheckout()\n\t}}\n}}\n"),
+      header.len(),
+      "func run() {\n\tcheckout()\n}\n",
+    ),
+  ];
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(codebase.to_string_lossy().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .split_by("package".to_string())
+    .path_to_patches(Some(codebase.join("patches").to_string_lossy().to_string()))
+    .build();
+  let chunks = split_summaries(
+    &summaries,
+    &piranha_arguments,
+    codebase,
+    &CodeOwners::default(),
+  );
+  // The keywords (e.g. `true`) deleted from `checkout` do not merge it with `flags`
+  assert_eq!(
+    chunks.iter().map(|c| c.name().as_str()).collect::<Vec<_>>(),
+    vec!["checkout", "flags+search"]
+  );
+  assert_eq!(chunks[1].summaries().len(), 2);
+}
+
+#[test]
+fn test_get_chunk_key() {
+  let temp_dir = TempDir::new("split").unwrap();
+  let codebase = temp_dir.path();
+  fs::create_dir_all(codebase.join("services/search")).unwrap();
+  let path = codebase.join("services/search/search.go");
+  let path = path.to_str().unwrap();
+  let code_owners = CodeOwners::parse("/services/search/ @org/search\n");
+  assert_eq!(
+    get_chunk_key(path, "package", codebase, codebase, &code_owners),
+    "services/search"
+  );
+  assert_eq!(
+    get_chunk_key(path, "directory", codebase, codebase, &code_owners),
+    "services"
+  );
+  assert_eq!(
+    get_chunk_key(path, "owner", codebase, codebase, &code_owners),
+    "@org/search"
+  );
+  assert_eq!(
+    get_chunk_key(
+      codebase.join("main.go").to_str().unwrap(),
+      "owner",
+      codebase,
+      codebase,
+      &code_owners
+    ),
+    "unowned"
+  );
+}
+
+#[test]
+fn test_slug() {
+  let chunk = Chunk {
+    name: "@org/search+services/checkout".to_string(),
+    summaries: vec![],
+  };
+  assert_eq!(chunk.slug(), "org-search-services-checkout");
+}
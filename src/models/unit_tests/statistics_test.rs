@@ -0,0 +1,144 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf, time::Duration};
+
+use tree_sitter::{Point, Range};
+
+use crate::{
+  models::{
+    default_configs::GO, edit::Edit, language::PiranhaLanguage, matches::Match,
+    piranha_arguments::PiranhaArgumentsBuilder, piranha_output::PiranhaOutputSummary,
+    rule_graph::RuleGraphBuilder, source_code_unit::SourceCodeUnit,
+  },
+  piranha_rule,
+};
+
+use super::RunStatistics;
+
+static ORIGINAL_CONTENT: &str = "package main
+
+const staleFlag = \"stale_flag\"
+
+func run() {
+	if isEnabled(staleFlag) {
+		println(\"new\")
+	}
+}
+";
+
+static REWRITTEN_CONTENT: &str = "package main
+
+func run() {
+	println(\"new\")
+}
+";
+
+/// Returns the edit of `rule` replacing the (first occurrence of the) `code` with the `replacement`
+fn get_edit(code: &str, replacement: &str, rule: &str) -> Edit {
+  let start_byte = ORIGINAL_CONTENT.find(code).unwrap();
+  let row = ORIGINAL_CONTENT[..start_byte].matches('\n').count();
+  Edit::new(
+    Match::new(
+      code.to_string(),
+      Range {
+        start_byte,
+        end_byte: start_byte + code.len(),
+        start_point: Point::new(row, 0),
+        end_point: Point::new(row, code.len()),
+      },
+      HashMap::new(),
+    ),
+    replacement.to_string(),
+    rule.to_string(),
+    &ORIGINAL_CONTENT.to_string(),
+  )
+}
+
+#[test]
+fn test_run_statistics() {
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase("some/test/path/".to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![
+      ("stale_flag_name".to_string(), "stale_flag".to_string()),
+      ("treated".to_string(), "true".to_string()),
+    ])
+    .rule_graph(
+      RuleGraphBuilder::default()
+        .rules(vec![piranha_rule! {
+          name = "simplify_if_statement_true",
+          query = "((if_statement) @if_statement)",
+          groups = ["if_cleanup"]
+        }])
+        .build(),
+    )
+    .build();
+  let mut parser = piranha_arguments.language().parser();
+  let mut source_code_unit = SourceCodeUnit::new(
+    &mut parser,
+    ORIGINAL_CONTENT.to_string(),
+    &HashMap::new(),
+    PathBuf::from("main.go").as_path(),
+    &piranha_arguments,
+  );
+  for edit in [
+    get_edit("isEnabled(staleFlag)", "true", "replace_is_enabled"),
+    get_edit(
+      "if isEnabled(staleFlag) {\n\t\tprintln(\"new\")\n\t}",
+      "println(\"new\")",
+      "simplify_if_statement_true",
+    ),
+    get_edit(
+      "const staleFlag = \"stale_flag\"\n",
+      "",
+      "delete_flag_constant",
+    ),
+  ] {
+    source_code_unit.rewrites_mut().push(edit);
+  }
+  source_code_unit.set_code(REWRITTEN_CONTENT.to_string());
+
+  let summaries = vec![PiranhaOutputSummary::new(&source_code_unit)];
+  let statistics = RunStatistics::new(
+    &summaries,
+    &piranha_arguments,
+    4,
+    Duration::from_millis(1500),
+  );
+
+  assert_eq!(*statistics.files_scanned(), 4);
+  assert_eq!(*statistics.files_modified(), 1);
+  assert_eq!(*statistics.rewrites(), 3);
+  assert_eq!(*statistics.lines_added(), 1);
+  assert_eq!(*statistics.lines_removed(), 5);
+  assert_eq!(*statistics.branches_deleted(), 1);
+  assert_eq!(*statistics.constants_deleted(), 1);
+  assert_eq!(*statistics.helpers_inlined(), 0);
+  assert_eq!(statistics.flags().len(), 1);
+  let flag_statistics = &statistics.flags()[0];
+  assert_eq!(flag_statistics.flag(), "stale_flag");
+  assert_eq!(*flag_statistics.files_modified(), 1);
+  assert_eq!(*flag_statistics.rewrites(), 3);
+  assert_eq!(*flag_statistics.lines_removed(), 5);
+  assert_eq!(*flag_statistics.branches_deleted(), 1);
+
+  let table = statistics.to_string();
+  assert!(table.starts_with("Files scanned: 4, files modified: 1, time elapsed: 1.50s\n"));
+  assert!(table.contains(
+    "stale_flag          1          3          1          5          1          1          0\n"
+  ));
+  assert!(table.contains(
+    "Total               1          3          1          5          1          1          0\n"
+  ));
+}
@@ -0,0 +1,135 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+use std::collections::HashSet;
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, source_code_unit::SourceCodeUnit,
+};
+
+use super::{STRANDED_FUNCTION, STRANDED_PROVIDER_SET, STRANDED_TYPE};
+
+static CODE: &str = "package main
+
+    var oldSet = wire.NewSet(newOldStore, wire.Bind(new(Store), new(*oldStore)))
+
+    var Set = wire.NewSet(newStore)
+
+    var timeout = time.Second
+
+    type oldStore struct{}
+
+    func (s *oldStore) Get() string {
+      return \"\"
+    }
+
+    func newOldStore() (*oldStore, error) {
+      return &oldStore{}, nil
+    }
+
+    func oldMiddleware(next http.Handler) http.Handler {
+      return logged(next)
+    }
+
+    func Exported() {}
+
+    func main() {}
+
+    func (s *Server) method() {}";
+
+fn get_go_source_code_unit() -> (SourceCodeUnit, tree_sitter::Parser) {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let source_code_unit = SourceCodeUnit::default(CODE, &mut parser, GO.to_string());
+  (source_code_unit, parser)
+}
+
+#[test]
+fn test_get_stranded_candidates() {
+  let (source_code_unit, _) = get_go_source_code_unit();
+  assert_eq!(
+    source_code_unit.get_stranded_candidates(),
+    vec![
+      ("newOldStore".to_string(), STRANDED_FUNCTION),
+      ("oldMiddleware".to_string(), STRANDED_FUNCTION),
+      ("oldSet".to_string(), STRANDED_PROVIDER_SET),
+    ]
+  );
+}
+
+#[test]
+fn test_report_stranded_declaration() {
+  let (mut source_code_unit, mut parser) = get_go_source_code_unit();
+  source_code_unit.retire_stranded_declaration(
+    "oldMiddleware",
+    STRANDED_FUNCTION,
+    false,
+    &mut parser,
+  );
+  assert!(source_code_unit.rewrites().is_empty());
+  assert_eq!(source_code_unit.matches().len(), 1);
+  let (rule, p_match) = &source_code_unit.matches()[0];
+  assert_eq!(rule, STRANDED_FUNCTION);
+  assert!(p_match
+    .matched_string()
+    .starts_with("func oldMiddleware(next http.Handler) http.Handler {"));
+}
+
+#[test]
+fn test_delete_stranded_declaration() {
+  let (mut source_code_unit, mut parser) = get_go_source_code_unit();
+  source_code_unit.retire_stranded_declaration(
+    "oldMiddleware",
+    STRANDED_FUNCTION,
+    true,
+    &mut parser,
+  );
+  source_code_unit.retire_stranded_declaration("oldSet", STRANDED_PROVIDER_SET, true, &mut parser);
+  assert!(!source_code_unit.code().contains("oldMiddleware"));
+  assert!(!source_code_unit.code().contains("oldSet"));
+  assert_eq!(source_code_unit.rewrites().len(), 2);
+  // The identifiers of the deleted declarations may be stranded in turn
+  let removed = source_code_unit.get_removed_identifiers();
+  assert!(removed.contains("logged"));
+  assert!(removed.contains("newOldStore"));
+  // Deleting a declaration that is not in the file is a no-op
+  source_code_unit.retire_stranded_declaration(
+    "newMiddleware",
+    STRANDED_FUNCTION,
+    true,
+    &mut parser,
+  );
+  assert_eq!(source_code_unit.rewrites().len(), 2);
+}
+
+#[test]
+fn test_report_stranded_type() {
+  let (mut source_code_unit, _) = get_go_source_code_unit();
+  assert_eq!(
+    source_code_unit.get_referenced_types("newOldStore"),
+    vec!["oldStore".to_string(), "oldStore".to_string()]
+  );
+  assert!(source_code_unit.declares_type("oldStore"));
+  // The references of the type by its declaration, its methods and the stranded declarations are ignored
+  let stranded = HashSet::from(["newOldStore".to_string(), "oldSet".to_string()]);
+  assert_eq!(
+    source_code_unit.count_type_references("oldStore", &stranded),
+    0
+  );
+  assert_eq!(
+    source_code_unit.count_type_references("oldStore", &HashSet::new()),
+    3
+  );
+  source_code_unit.report_stranded_type("oldStore");
+  let (rule, p_match) = &source_code_unit.matches()[0];
+  assert_eq!(rule, STRANDED_TYPE);
+  assert_eq!(p_match.matched_string(), "type oldStore struct{}");
+}
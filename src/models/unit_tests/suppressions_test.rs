@@ -0,0 +1,79 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::collections::HashMap;
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, matches::Match, source_code_unit::SourceCodeUnit,
+  specialization::collect_nodes,
+};
+
+/// Returns whether each call to `BoolValue` is suppressed, in the order they appear in the file
+fn get_suppressed(code: &str) -> Vec<bool> {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let source_code_unit = SourceCodeUnit::default(code, &mut parser, GO.to_string());
+  let mut calls = collect_nodes(source_code_unit.root_node(), |n| {
+    n.kind() == "call_expression"
+      && n
+        .utf8_text(source_code_unit.code().as_bytes())
+        .map(|t| t.starts_with("exp.BoolValue"))
+        .unwrap_or(false)
+  });
+  calls.sort_by_key(|n| n.start_byte());
+  calls
+    .iter()
+    .map(|n| {
+      let p_match = Match::new(
+        n.utf8_text(source_code_unit.code().as_bytes())
+          .unwrap()
+          .to_string(),
+        n.range(),
+        HashMap::new(),
+      );
+      source_code_unit.is_suppressed(&p_match)
+    })
+    .collect()
+}
+
+#[test]
+fn test_is_suppressed() {
+  let suppressed = get_suppressed(
+    "package main
+
+    func a() {
+      if exp.BoolValue(\"stale_flag\") { // piranha:ignore
+        fmt.Println(\"enabled\")
+      }
+      // piranha:ignore until the rollout is audited
+      enabled := exp.BoolValue(\"stale_flag\")
+      fmt.Println(enabled) // piranha:ignore
+      x := exp.BoolValue(\"stale_flag\")
+      y := exp.BoolValue(\"stale_flag\") // piranha:ignore-me
+    }",
+  );
+  assert_eq!(suppressed, vec![true, true, false, false]);
+}
+
+#[test]
+fn test_is_suppressed_file() {
+  let suppressed = get_suppressed(
+    "// piranha:ignore-file
+    package main
+
+    func a() {
+      x := exp.BoolValue(\"stale_flag\")
+      y := exp.BoolValue(\"other_flag\")
+    }",
+  );
+  assert_eq!(suppressed, vec![true, true]);
+}
@@ -0,0 +1,164 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{collections::HashMap, path::PathBuf};
+
+use super::{get_actions, Condition, TemplateFile, TemplateFlag};
+
+fn get_flag(value: &str) -> TemplateFlag {
+  TemplateFlag::new(&HashMap::from([
+    ("stale_flag_name".to_string(), "stale_flag".to_string()),
+    ("treated".to_string(), value.to_string()),
+    (
+      "template_field".to_string(),
+      ".Features.StaleFlag".to_string(),
+    ),
+    (
+      "template_function".to_string(),
+      "featureEnabled".to_string(),
+    ),
+  ]))
+  .unwrap()
+}
+
+fn cleanup(content: &str, value: &str) -> String {
+  let mut file = TemplateFile::new(PathBuf::from("index.html.tmpl"), content.to_string());
+  file.cleanup_flag(&get_flag(value));
+  file.content().to_string()
+}
+
+#[test]
+fn test_template_flag_new() {
+  let substitutions = HashMap::from([
+    ("stale_flag_name".to_string(), "stale_flag".to_string()),
+    ("treated".to_string(), "control".to_string()),
+  ]);
+  assert_eq!(
+    TemplateFlag::new(&substitutions),
+    Err("neither `template_field` nor `template_function` is substituted".to_string())
+  );
+  let mut substitutions = substitutions;
+  substitutions.insert(
+    "template_field".to_string(),
+    "Features.StaleFlag".to_string(),
+  );
+  assert_eq!(
+    TemplateFlag::new(&substitutions),
+    Err("the flag `stale_flag` is not boolean".to_string())
+  );
+}
+
+#[test]
+fn test_evaluate() {
+  let flag = get_flag("true");
+  assert_eq!(
+    flag.evaluate(".Features.StaleFlag"),
+    Condition::Constant(true)
+  );
+  assert_eq!(
+    flag.evaluate("$.Features.StaleFlag"),
+    Condition::Constant(true)
+  );
+  assert_eq!(
+    flag.evaluate("not (featureEnabled `stale_flag`)"),
+    Condition::Constant(false)
+  );
+  assert_eq!(
+    flag.evaluate("or .User.Admin .Features.StaleFlag"),
+    Condition::Constant(true)
+  );
+  assert_eq!(
+    flag.evaluate("and .Features.StaleFlag (eq .User.Role \"admin\") .Beta"),
+    Condition::Residual("and (eq .User.Role \"admin\") .Beta".to_string())
+  );
+  assert_eq!(
+    flag.evaluate("not (and .Features.StaleFlag (eq .User.Role \"admin\"))"),
+    Condition::Residual("not (eq .User.Role \"admin\")".to_string())
+  );
+  assert_eq!(
+    flag.evaluate("featureEnabled \"other_flag\""),
+    Condition::Unrelated
+  );
+  assert_eq!(flag.evaluate(".Features.StaleFlagV2"), Condition::Unrelated);
+}
+
+/// The delimiters inside the strings and the comments are not actions
+#[test]
+fn test_get_actions() {
+  let content = "{{/* {{ end }} */}}{{ printf \"}}\" }}{{- if .Beta -}}";
+  let bodies: Vec<&str> = get_actions(content)
+    .iter()
+    .map(|a| a.body(content))
+    .collect();
+  assert_eq!(bodies, vec!["/* {{ end }} */", "printf \"}}\"", "if .Beta"]);
+}
+
+#[test]
+fn test_cleanup_flag_false() {
+  let content = "<nav>
+  {{ if .Features.StaleFlag }}
+  <a href=\"/new\">New</a>
+  {{ else }}
+  <a href=\"/old\">Old</a>
+  {{ end }}
+  {{ if featureEnabled \"stale_flag\" }}
+  <p>New checkout</p>
+  {{ end }}
+</nav>
+";
+  assert_eq!(
+    cleanup(content, "false"),
+    "<nav>
+  <a href=\"/old\">Old</a>
+</nav>
+"
+  );
+}
+
+/// The next branch of a deleted first branch becomes the first one
+#[test]
+fn test_cleanup_flag_else_if() {
+  let content = "{{ if .Features.StaleFlag }}New{{ else if .Beta }}Beta{{ else }}Old{{ end }}
+{{ if .Beta }}Beta{{ else if .Features.StaleFlag }}New{{ else }}Old{{ end }}
+";
+  assert_eq!(
+    cleanup(content, "false"),
+    "{{ if .Beta }}Beta{{ else }}Old{{ end }}
+{{ if .Beta }}Beta{{ else }}Old{{ end }}
+"
+  );
+  assert_eq!(
+    cleanup(content, "true"),
+    "New
+{{ if .Beta }}Beta{{ else }}New{{ end }}
+"
+  );
+}
+
+/// The conditional blocks nested in the kept branch are simplified as well
+#[test]
+fn test_cleanup_flag_nested() {
+  let content = "{{ range .Items }}
+  {{ if .Features.StaleFlag }}
+    {{ if not $.Features.StaleFlag }}Old{{ else }}New{{ end }}
+  {{ end }}
+{{ end }}
+";
+  assert_eq!(
+    cleanup(content, "true"),
+    "{{ range .Items }}
+    New
+{{ end }}
+"
+  );
+}
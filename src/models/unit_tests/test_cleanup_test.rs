@@ -0,0 +1,116 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, source_code_unit::SourceCodeUnit,
+  specialization::collect_nodes,
+};
+
+use super::TestCleanup;
+
+/// Returns the cleanup of the test enclosing each call to `SetBoolValue`, in the order they appear in the file.
+/// The deleted code is represented by its first line.
+fn get_test_cleanups(code: &str) -> Vec<Option<String>> {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let source_code_unit = SourceCodeUnit::default(code, &mut parser, GO.to_string());
+  let mut calls = collect_nodes(source_code_unit.root_node(), |n| {
+    n.kind() == "call_expression"
+      && n
+        .utf8_text(source_code_unit.code().as_bytes())
+        .map(|t| t.starts_with("exp.SetBoolValue"))
+        .unwrap_or(false)
+  });
+  calls.sort_by_key(|n| n.start_byte());
+  calls
+    .iter()
+    .map(|n| {
+      source_code_unit
+        .get_test_cleanup_for(*n)
+        .map(|test_cleanup| match test_cleanup {
+          TestCleanup::Delete(range) => format!(
+            "delete {}",
+            source_code_unit.code()[range.start_byte..range.end_byte]
+              .lines()
+              .next()
+              .unwrap_or_default()
+          ),
+          TestCleanup::Mark(_, test_name) => format!("mark {test_name}"),
+        })
+    })
+    .collect()
+}
+
+#[test]
+fn test_get_test_cleanup_for() {
+  let test_cleanups = get_test_cleanups(
+    "package checkout
+
+    func TestDisabled(t *testing.T) {
+      exp.SetBoolValue(\"stale_flag\", false)
+    }
+
+    func TestSubtests(t *testing.T) {
+      t.Run(\"disabled\", func(t *testing.T) {
+        exp.SetBoolValue(\"stale_flag\", false)
+      })
+    }
+
+    func TestTable(t *testing.T) {
+      for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+          exp.SetBoolValue(\"stale_flag\", false)
+        })
+      }
+    }
+
+    func TestConditional(t *testing.T) {
+      if !enabled {
+        exp.SetBoolValue(\"stale_flag\", false)
+      }
+    }
+
+    func disable(t *testing.T) {
+      exp.SetBoolValue(\"stale_flag\", false)
+    }
+
+    func Testdisabled(t *testing.T) {
+      exp.SetBoolValue(\"stale_flag\", false)
+    }",
+  );
+  assert_eq!(
+    test_cleanups,
+    vec![
+      Some("delete func TestDisabled(t *testing.T) {".to_string()),
+      Some("delete t.Run(\"disabled\", func(t *testing.T) {".to_string()),
+      Some("mark TestTable".to_string()),
+      Some("mark TestConditional".to_string()),
+      None,
+      None,
+    ]
+  );
+}
+
+#[test]
+fn test_get_test_cleanup_for_marked_test() {
+  let test_cleanups = get_test_cleanups(
+    "package checkout
+
+    // TODO(piranha): TestConditional forces the eliminated value of the stale flag
+    func TestConditional(t *testing.T) {
+      if !enabled {
+        exp.SetBoolValue(\"stale_flag\", false)
+      }
+    }",
+  );
+  assert_eq!(test_cleanups, vec![None]);
+}
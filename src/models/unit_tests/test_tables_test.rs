@@ -0,0 +1,142 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, source_code_unit::SourceCodeUnit,
+  specialization::collect_nodes,
+};
+
+use itertools::Itertools;
+use tree_sitter::Range;
+
+use super::TableCleanup;
+
+/// Returns the next step of the cleanup of the table iterated over by the loop enclosing the call to `SetBoolValue`
+/// (setting the flag to `tc.flagOn`, for `treated = true`).
+/// The deleted test is represented by its first line, and the other deleted code by its text.
+fn get_table_cleanup(code: &str) -> Option<String> {
+  let mut parser = PiranhaLanguage::from(GO).parser();
+  let source_code_unit = SourceCodeUnit::default(code, &mut parser, GO.to_string());
+  let call = *collect_nodes(source_code_unit.root_node(), |n| {
+    n.kind() == "call_expression"
+      && n
+        .utf8_text(source_code_unit.code().as_bytes())
+        .map(|t| t.starts_with("exp.SetBoolValue"))
+        .unwrap_or(false)
+  })
+  .first()
+  .unwrap();
+  let text_of = |range: &Range| {
+    source_code_unit.code()[range.start_byte..range.end_byte]
+      .split_whitespace()
+      .join(" ")
+  };
+  source_code_unit
+    .get_table_cleanup_for(call, "tc", "flagOn", "true")
+    .map(|table_cleanup| match table_cleanup {
+      TableCleanup::DeleteTest(r) => format!(
+        "delete test {}",
+        source_code_unit.code()[r.start_byte..r.end_byte]
+          .lines()
+          .next()
+          .unwrap_or_default()
+      ),
+      TableCleanup::DeleteRow(r) => format!("delete row {}", text_of(&r)),
+      TableCleanup::DeleteField(ranges, test_name, field_name) => format!(
+        "delete field {test_name}.{field_name}: {}",
+        ranges.iter().map(text_of).join(" | ")
+      ),
+    })
+}
+
+/// Returns a test iterating over the `rows` of a table declaring the `flagOn` field
+fn get_test(rows: &str) -> String {
+  format!(
+    "package checkout
+
+    func TestCheckout(t *testing.T) {{
+      cases := []struct {{
+        name   string
+        flagOn bool
+      }}{{
+        {rows}
+      }}
+      for _, tc := range cases {{
+        exp.SetBoolValue(\"stale_flag\", tc.flagOn)
+      }}
+    }}"
+  )
+}
+
+#[test]
+fn test_get_table_cleanup_for_eliminated_row() {
+  assert_eq!(
+    get_table_cleanup(&get_test(
+      "{name: \"on\", flagOn: true},
+        {name: \"off\", flagOn: false},"
+    )),
+    Some("delete row , {name: \"off\", flagOn: false}".to_string())
+  );
+  // The rows not initializing the field set it to `false`
+  assert_eq!(
+    get_table_cleanup(&get_test(
+      "{name: \"off\"},
+        {name: \"on\", flagOn: true},"
+    )),
+    Some("delete row {name: \"off\"},".to_string())
+  );
+}
+
+#[test]
+fn test_get_table_cleanup_for_eliminated_rows_only() {
+  assert_eq!(
+    get_table_cleanup(&get_test(
+      "{name: \"off\", flagOn: false},
+        {name: \"default\"},"
+    )),
+    Some("delete test func TestCheckout(t *testing.T) {".to_string())
+  );
+}
+
+#[test]
+fn test_get_table_cleanup_for_constant_field() {
+  assert_eq!(
+    get_table_cleanup(&get_test(
+      "{name: \"on\", flagOn: true},
+        {flagOn: true, name: \"also on\"},"
+    )),
+    Some(
+      "delete field TestCheckout.flagOn: flagOn bool | , flagOn: true | flagOn: true,".to_string()
+    )
+  );
+}
+
+#[test]
+fn test_get_table_cleanup_for_unsupported_tables() {
+  // The field is not constant
+  assert_eq!(
+    get_table_cleanup(&get_test(
+      "{name: \"on\", flagOn: true},
+        {name: \"configured\", flagOn: enabled},"
+    )),
+    None
+  );
+  // The rows are positional
+  assert_eq!(
+    get_table_cleanup(&get_test(
+      "{\"on\", true},
+        {\"off\", false},"
+    )),
+    None
+  );
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::HashMap,
+  fs,
+  path::PathBuf,
+  time::{Duration, SystemTime},
+};
+
+use tempdir::TempDir;
+
+use crate::models::{
+  default_configs::GO, language::PiranhaLanguage, piranha_arguments::PiranhaArgumentsBuilder,
+};
+
+use super::{get_changed_paths, get_code_modification_times, get_configuration_modification_times};
+
+static RULE: &str = "[[rules]]
+name = \"find_for\"
+query = \"(for_statement) @for_stmt\"
+";
+
+#[test]
+fn test_get_changed_paths() {
+  let now = SystemTime::now();
+  let later = now + Duration::from_secs(1);
+  let previous = HashMap::from([
+    (PathBuf::from("a.go"), now),
+    (PathBuf::from("b.go"), now),
+    (PathBuf::from("c.go"), now),
+  ]);
+  let current = HashMap::from([
+    (PathBuf::from("a.go"), now),
+    (PathBuf::from("b.go"), later),
+    (PathBuf::from("d.go"), now),
+  ]);
+  assert_eq!(
+    get_changed_paths(&previous, &current),
+    vec![
+      PathBuf::from("b.go"),
+      PathBuf::from("c.go"),
+      PathBuf::from("d.go")
+    ]
+  );
+  assert!(get_changed_paths(&current, &current).is_empty());
+}
+
+#[test]
+fn test_get_modification_times() {
+  let temp_dir = TempDir::new("piranha_watch").unwrap();
+  let codebase = temp_dir.path().join("codebase");
+  let configurations = temp_dir.path().join("configurations");
+  fs::create_dir_all(codebase.join("pkg")).unwrap();
+  fs::create_dir_all(&configurations).unwrap();
+  fs::write(codebase.join("main.go"), "package main\n").unwrap();
+  fs::write(codebase.join("pkg").join("pkg.go"), "package pkg\n").unwrap();
+  fs::write(codebase.join("README.md"), "# README\n").unwrap();
+  fs::write(configurations.join("rules.toml"), RULE).unwrap();
+  fs::write(configurations.join("notes.txt"), "notes\n").unwrap();
+
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(codebase.to_str().unwrap().to_string())
+    .path_to_configurations(configurations.to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .watch(true)
+    .build();
+
+  let mut code_paths: Vec<PathBuf> = get_code_modification_times(&piranha_arguments)
+    .into_keys()
+    .collect();
+  code_paths.sort();
+  assert_eq!(
+    code_paths,
+    vec![
+      codebase.join("main.go"),
+      codebase.join("pkg").join("pkg.go")
+    ]
+  );
+  let configuration_paths: Vec<PathBuf> = get_configuration_modification_times(&piranha_arguments)
+    .into_keys()
+    .collect();
+  assert_eq!(configuration_paths, vec![configurations.join("rules.toml")]);
+}
+
+#[test]
+fn test_reload_rule_graph() {
+  let temp_dir = TempDir::new("piranha_watch").unwrap();
+  let configurations = temp_dir.path().join("configurations");
+  fs::create_dir_all(&configurations).unwrap();
+  fs::write(configurations.join("rules.toml"), RULE).unwrap();
+
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(configurations.to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .watch(true)
+    .build();
+  let find_go = "find_go".to_string();
+  assert!(piranha_arguments
+    .rule_graph()
+    .get_rule_named(&find_go)
+    .is_none());
+
+  fs::write(
+    configurations.join("rules.toml"),
+    format!("{RULE}\n[[rules]]\nname = \"find_go\"\nquery = \"(go_statement) @go_stmt\"\n"),
+  )
+  .unwrap();
+  let reloaded = piranha_arguments.reload_rule_graph();
+  assert!(reloaded.rule_graph().get_rule_named(&find_go).is_some());
+  assert!(reloaded
+    .rule_graph()
+    .get_rule_named(&"find_for".to_string())
+    .is_some());
+}
@@ -0,0 +1,144 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+use std::{
+  collections::HashMap,
+  panic::{catch_unwind, AssertUnwindSafe},
+  path::{Path, PathBuf},
+  thread,
+  time::{Duration, SystemTime},
+};
+
+use jwalk::WalkDir;
+use log::{error, info};
+
+use crate::execute_piranha;
+
+use super::{piranha_arguments::PiranhaArguments, piranha_output::PiranhaOutputSummary};
+
+/// The interval at which the watched files are polled for changes
+const POLL_INTERVAL: Duration = Duration::from_millis(500);
+
+/// The modification times of the watched files
+pub(crate) type ModificationTimes = HashMap<PathBuf, SystemTime>;
+
+/// Cleans up the code base in dry-run (i.e. `watch`), and cleans it up again whenever a file of the language in the code base,
+/// or the rule configuration (i.e. a file of the `path_to_configurations`) changes, until the process is interrupted.
+/// The rule graph is read again when the rule configuration changes. The output summaries are passed to `on_run`
+/// after each run (e.g. to print the updated diff). A run that fails (e.g. for an invalid query) is logged, and the
+/// files are watched until the next change.
+pub fn watch_piranha(
+  piranha_arguments: &PiranhaArguments, mut on_run: impl FnMut(&[PiranhaOutputSummary]),
+) {
+  let mut arguments = piranha_arguments.in_memory(piranha_arguments.path_to_codebase());
+  let mut code_times = get_code_modification_times(piranha_arguments);
+  let mut configuration_times = get_configuration_modification_times(piranha_arguments);
+  run(&arguments, &mut on_run);
+  loop {
+    thread::sleep(POLL_INTERVAL);
+    let current_code_times = get_code_modification_times(piranha_arguments);
+    let current_configuration_times = get_configuration_modification_times(piranha_arguments);
+    let changed_code = get_changed_paths(&code_times, &current_code_times);
+    let changed_configuration =
+      get_changed_paths(&configuration_times, &current_configuration_times);
+    if changed_code.is_empty() && changed_configuration.is_empty() {
+      continue;
+    }
+    for path in changed_code.iter().chain(&changed_configuration) {
+      info!("{} changed", path.display());
+    }
+    code_times = current_code_times;
+    configuration_times = current_configuration_times;
+    if !changed_configuration.is_empty() {
+      match catch_unwind(AssertUnwindSafe(|| arguments.reload_rule_graph())) {
+        Ok(reloaded) => arguments = reloaded,
+        Err(_) => {
+          error!("Could not read the rule configuration, waiting for the next change");
+          continue;
+        }
+      }
+    }
+    run(&arguments, &mut on_run);
+  }
+}
+
+/// Cleans up the code base (in dry-run), and passes the output summaries to `on_run`, unless the cleanup fails
+fn run(arguments: &PiranhaArguments, on_run: &mut impl FnMut(&[PiranhaOutputSummary])) {
+  match catch_unwind(AssertUnwindSafe(|| execute_piranha(arguments))) {
+    Ok(summaries) => on_run(&summaries),
+    Err(_) => error!("The cleanup failed, waiting for the next change"),
+  }
+}
+
+/// Returns the modification times of the files of the language in the code base
+pub(crate) fn get_code_modification_times(
+  piranha_arguments: &PiranhaArguments,
+) -> ModificationTimes {
+  let language = piranha_arguments.language();
+  get_modification_times(piranha_arguments.path_to_codebase(), |de| {
+    language.can_parse(de)
+  })
+}
+
+/// Returns the modification times of the files of the rule configuration (i.e. `rules.toml`, `edges.toml` and `flag_apis.toml`)
+pub(crate) fn get_configuration_modification_times(
+  piranha_arguments: &PiranhaArguments,
+) -> ModificationTimes {
+  if piranha_arguments.path_to_configurations().is_empty() {
+    return ModificationTimes::new();
+  }
+  get_modification_times(piranha_arguments.path_to_configurations(), |de| {
+    de.path().extension().map(|e| e == "toml").unwrap_or(false)
+  })
+}
+
+/// Returns the modification times of the files under `root` (or of `root` itself, if a file) satisfying the `predicate`
+fn get_modification_times(
+  root: &str, predicate: impl Fn(&jwalk::DirEntry<((), ())>) -> bool,
+) -> ModificationTimes {
+  if !Path::new(root).exists() {
+    return ModificationTimes::new();
+  }
+  WalkDir::new(root)
+    .into_iter()
+    .filter_map(|e| e.ok())
+    .filter(|de| de.file_type().is_file() && predicate(de))
+    .filter_map(|de| {
+      let modified = de.metadata().ok()?.modified().ok()?;
+      Some((de.path(), modified))
+    })
+    .collect()
+}
+
+/// Returns the (sorted) paths of the files added, modified or deleted between the `previous` and the `current` modification times
+pub(crate) fn get_changed_paths(
+  previous: &ModificationTimes, current: &ModificationTimes,
+) -> Vec<PathBuf> {
+  let mut changed: Vec<PathBuf> = current
+    .iter()
+    .filter(|(path, modified)| previous.get(*path) != Some(modified))
+    .map(|(path, _)| path.clone())
+    .chain(
+      previous
+        .keys()
+        .filter(|path| !current.contains_key(*path))
+        .cloned(),
+    )
+    .collect();
+  changed.sort();
+  changed
+}
+
+#[cfg(test)]
+#[path = "unit_tests/watch_test.rs"]
+mod watch_test;
@@ -11,11 +11,33 @@ Copyright (c) 2023 Uber Technologies, Inc.
  limitations under the License.
 */
 
-use std::collections::HashMap;
+use std::{
+  collections::{BTreeMap, HashMap},
+  fs,
+  path::PathBuf,
+};
 
-use super::{create_match_tests, create_rewrite_tests, substitutions};
+use glob::Pattern;
+use serde_json::{json, Value};
+use tempdir::TempDir;
 
-use crate::models::default_configs::GO;
+use super::{
+  copy_folder_to_temp_dir, create_match_tests, create_rewrite_tests,
+  execute_piranha_and_check_result, initialize, substitutions,
+};
+
+use crate::{
+  clean, execute_piranha, explain_piranha,
+  models::{
+    check::get_remaining_flag_usages,
+    default_configs::GO,
+    explain::RuleExplanation,
+    language::PiranhaLanguage,
+    lsp::serve_lsp,
+    piranha_arguments::{PiranhaArguments, PiranhaArgumentsBuilder},
+  },
+  utilities::eq_without_whitespace,
+};
 
 create_match_tests! {
   GO,
@@ -36,9 +58,746 @@ create_rewrite_tests! {
       "treated" => "true",
       "treated_complement" => "false"
     }, cleanup_comments = true;
+  test_builtin_switch_cleanup: "feature_flag/builtin_rules/switch_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_remove_unused_imports: "feature_flag/builtin_rules/remove_unused_imports", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, remove_unused_imports = true;
+  test_builtin_wrapper_cleanup: "feature_flag/builtin_rules/wrapper_cleanup", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_wrapper_cleanup_other_file: "feature_flag/builtin_rules/wrapper_cleanup_other_file", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_wrapper_cleanup_other_package: "feature_flag/builtin_rules/wrapper_cleanup_other_package", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, remove_unused_imports = true;
+  test_builtin_external_test_packages: "feature_flag/builtin_rules/external_test_packages", 3,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_generics: "feature_flag/builtin_rules/generics", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_build_constraints: "feature_flag/builtin_rules/build_constraints", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_package_variable_cleanup: "feature_flag/builtin_rules/package_variable_cleanup", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_cached_value_cleanup: "feature_flag/builtin_rules/cached_value_cleanup", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, remove_unused_imports = true;
+  test_builtin_context_value_cleanup: "feature_flag/builtin_rules/context_value_cleanup", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_tuple_assignment: "feature_flag/builtin_rules/tuple_assignment", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_map_bool_cleanup: "feature_flag/builtin_rules/map_bool_cleanup", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_loop_cleanup: "feature_flag/builtin_rules/loop_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_function_literal_cleanup: "feature_flag/builtin_rules/function_literal_cleanup", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_string_flag_cleanup: "feature_flag/builtin_rules/string_flag_cleanup", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "checkout_flow",
+      "treatment" => "treatment"
+    };
+  test_builtin_numeric_flag_cleanup: "feature_flag/builtin_rules/numeric_flag_cleanup", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "checkout_rollout",
+      "rollout" => "100",
+      "rollout_fraction" => "1.0"
+    };
+  test_builtin_context_first_api: "feature_flag/builtin_rules/context_first_api", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true"
+    };
+  test_builtin_error_returning_api: "feature_flag/builtin_rules/error_returning_api", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "checkout_flow",
+      "treatment" => "treatment"
+    };
+  test_builtin_method_chaining: "feature_flag/builtin_rules/method_chaining", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true"
+    };
+  test_builtin_else_if_chain: "feature_flag/builtin_rules/else_if_chain", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_unreachable_code: "feature_flag/builtin_rules/unreachable_code", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_multi_hop_locals: "feature_flag/builtin_rules/multi_hop_locals", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_import_aliases: "feature_flag/builtin_rules/import_aliases", 2,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true"
+    }, remove_unused_imports = true;
+  test_builtin_generated_files: "feature_flag/builtin_rules/generated_files", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_suppressions: "feature_flag/builtin_rules/suppressions", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_orphaned_comments: "feature_flag/builtin_rules/orphaned_comments", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_comments = true;
+  test_builtin_directives: "feature_flag/builtin_rules/directives", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_comments = true;
+  test_builtin_test_cleanup: "feature_flag/builtin_rules/test_cleanup", 2,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_comments = true, remove_unused_imports = true;
+  test_builtin_test_tables: "feature_flag/builtin_rules/test_tables", 2,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_comments = true, remove_unused_imports = true;
+  test_builtin_mock_expectations: "feature_flag/builtin_rules/mock_expectations", 2,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_comments = true;
+  test_builtin_test_skip_guards: "feature_flag/builtin_rules/test_skip_guards", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, cleanup_comments = true;
+  test_builtin_registry_cleanup: "feature_flag/builtin_rules/registry_cleanup", 2,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_flag_definitions: "feature_flag/builtin_rules/flag_definitions", 3,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, flag_definition_files = vec!["flags.yaml".to_string(), "flags.json".to_string()];
+  test_builtin_templates: "feature_flag/builtin_rules/templates", 3,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treated_complement" => "false",
+      "template_field" => ".Features.StaleFlag",
+      "template_function" => "featureEnabled"
+    }, template_files = vec!["*.tmpl".to_string()];
+  test_builtin_launchdarkly_rule_pack: "feature_flag/builtin_rules/launchdarkly_rule_pack", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true"
+    }, rule_packs = vec!["launchdarkly".to_string()], remove_unused_imports = true;
+  test_builtin_openfeature_rule_pack: "feature_flag/builtin_rules/openfeature_rule_pack", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true"
+    }, rule_packs = vec!["openfeature".to_string()], remove_unused_imports = true;
+  test_builtin_unleash_rule_pack: "feature_flag/builtin_rules/unleash_rule_pack", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treatment" => "dark"
+    }, rule_packs = vec!["unleash".to_string()];
+  test_builtin_flagsmith_rule_pack: "feature_flag/builtin_rules/flagsmith_rule_pack", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treatment" => "blue"
+    }, rule_packs = vec!["flagsmith".to_string()];
+  test_builtin_flag_apis: "feature_flag/builtin_rules/flag_apis", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treated_complement" => "false",
+      "treatment" => "dark"
+    };
+  test_builtin_batch_flags: "feature_flag/builtin_rules/batch_flags", 1,
+    flags = vec![
+      "stale_flag_name=flag_a,treated=true".to_string(),
+      "stale_flag_name=flag_b,treated=false".to_string()
+    ], cleanup_comments = true;
+  test_builtin_flag_file: "feature_flag/builtin_rules/flag_file", 2,
+    flag_file = "test-resources/go/feature_flag/builtin_rules/flag_file/configurations/flags.json".to_string();
+  test_builtin_substitute_only: "feature_flag/builtin_rules/substitute_only", 1,
+    flags = vec![
+      "stale_flag_name=flag_a,treated=true".to_string(),
+      "stale_flag_name=flag_b,treated=false,mode=substitute-only".to_string()
+    ];
+  test_builtin_piranha_ignore: "feature_flag/builtin_rules/piranha_ignore", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
+  test_builtin_confidence_threshold: "feature_flag/builtin_rules/confidence_threshold", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "new_flow",
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, confidence_threshold = "high".to_string();
+  test_builtin_aggressive_dead_code: "feature_flag/builtin_rules/aggressive_dead_code", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, aggressive_dead_code = true;
+  test_builtin_stranded_functions: "feature_flag/builtin_rules/stranded_functions", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, aggressive_dead_code = true;
+  test_builtin_provider_selection: "feature_flag/builtin_rules/provider_selection", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, aggressive_dead_code = true;
+  test_builtin_retire_constant_methods: "feature_flag/builtin_rules/retire_constant_methods", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, aggressive_dead_code = true;
+  test_builtin_specialize_boolean_parameters: "feature_flag/builtin_rules/specialize_boolean_parameters", 3,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, specialize_boolean_parameters = true;
+  test_builtin_fixed_point: "feature_flag/builtin_rules/fixed_point", 1,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    }, specialize_boolean_parameters = true;
+  test_builtin_field_cleanup: "feature_flag/builtin_rules/field_cleanup", 2,
+    substitutions= substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    };
   test_const_same_file: "feature_flag/system_1/const_same_file", 1,
     substitutions= substitutions! {
       "stale_flag_name" => "staleFlag",
       "treated" => "false"
     };
+  test_const_other_file: "feature_flag/system_1/const_other_file", 3,
+    substitutions= substitutions! {
+      "stale_flag_name" => "staleFlag",
+      "treated" => "false"
+    };
+  test_const_typed: "feature_flag/system_1/const_typed", 4,
+    substitutions= substitutions! {
+      "stale_flag_name" => "staleFlag",
+      "treated" => "false"
+    };
+  test_var_flag: "feature_flag/system_1/var_flag", 3,
+    substitutions= substitutions! {
+      "stale_flag_name" => "staleFlag",
+      "treated" => "false"
+    };
+  test_const_iota: "feature_flag/system_1/const_iota", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "StaleFlag",
+      "treated" => "true"
+    };
+  test_shadowing: "feature_flag/system_1/shadowing", 1,
+    substitutions= substitutions! {
+      "stale_flag_name" => "staleFlag",
+      "treated" => "false"
+    };
+  test_const_other_package: "feature_flag/system_1/const_other_package", 4,
+    substitutions= substitutions! {
+      "stale_flag_name" => "staleFlag",
+      "treated" => "true"
+    };
+}
+
+#[test]
+fn test_check_remaining_flag_usages() {
+  initialize();
+  let path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/context_first_api");
+  let temp_dir = copy_folder_to_temp_dir(&path.join("input"));
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .flags(vec!["stale_flag".to_string()])
+    .check(true)
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+
+  let usages = get_remaining_flag_usages(&summaries, &piranha_arguments);
+  assert!(!usages.is_empty());
+  assert!(usages
+    .iter()
+    .all(|u| *u.flag() == Some("stale_flag".to_string())));
+  // No edit is made in the check mode
+  for summary in &summaries {
+    assert_eq!(
+      fs::read_to_string(summary.path()).unwrap(),
+      *summary.original_content()
+    );
+  }
+  _ = temp_dir.close().unwrap();
+}
+
+#[test]
+fn test_include_from_cli() {
+  initialize();
+  let path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/build_constraints");
+  let temp_dir = copy_folder_to_temp_dir(&path.join("input"));
+  let args = [
+    "polyglot_piranha",
+    "-c",
+    temp_dir.path().to_str().unwrap(),
+    "-f",
+    path.join("configurations").to_str().unwrap(),
+    "-l",
+    GO,
+    "-s",
+    "treated=true",
+    "-s",
+    "treated_complement=false",
+    "--include",
+    "run_linux.go",
+  ];
+  let piranha_arguments = PiranhaArguments::from_args(args.iter().map(|a| a.to_string()));
+  let summaries = execute_piranha(&piranha_arguments);
+
+  // Only the included file is rewritten
+  assert_eq!(summaries.len(), 1);
+  let read = |dir: &std::path::Path, name: &str| fs::read_to_string(dir.join(name)).unwrap();
+  assert!(eq_without_whitespace(
+    &read(temp_dir.path(), "run_linux.go"),
+    &read(&path.join("expected"), "run_linux.go")
+  ));
+  assert_eq!(
+    read(temp_dir.path(), "run_other.go"),
+    read(&path.join("input"), "run_other.go")
+  );
+  _ = temp_dir.close().unwrap();
+}
+
+/// Same as `test_builtin_switch_cleanup`, for the control (i.e. the switches on the flag value become `switch false`)
+#[test]
+fn test_builtin_switch_cleanup_treated_false() {
+  initialize();
+  let path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/switch_cleanup");
+  let temp_dir = copy_folder_to_temp_dir(&path.join("input"));
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "false",
+      "treated_complement" => "true"
+    })
+    .build();
+  execute_piranha_and_check_result(
+    &piranha_arguments,
+    &path.join("expected_treated_false"),
+    1,
+    true,
+  );
+  _ = temp_dir.close().unwrap();
+}
+
+#[test]
+fn test_stranded_functions_excluded_file() {
+  initialize();
+  let path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/stranded_functions");
+  let temp_dir = copy_folder_to_temp_dir(&path.join("input"));
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "treated" => "true",
+      "treated_complement" => "false"
+    })
+    .aggressive_dead_code(true)
+    .exclude(vec![Pattern::new("middleware.go").unwrap()])
+    .build();
+  let summaries = execute_piranha(&piranha_arguments);
+
+  // The stranded functions are not deleted from the excluded file of the package
+  assert_eq!(summaries.len(), 1);
+  let read = |dir: &std::path::Path, name: &str| fs::read_to_string(dir.join(name)).unwrap();
+  assert!(eq_without_whitespace(
+    &read(temp_dir.path(), "router.go"),
+    &read(&path.join("expected"), "router.go")
+  ));
+  assert_eq!(
+    read(temp_dir.path(), "middleware.go"),
+    read(&path.join("input"), "middleware.go")
+  );
+  _ = temp_dir.close().unwrap();
+}
+
+#[test]
+fn test_explain_position() {
+  initialize();
+  let path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/suppressions");
+  let temp_dir = copy_folder_to_temp_dir(&path.join("input"));
+  let sample = temp_dir.path().join("sample.go");
+  let explain = |line: usize| {
+    explain_piranha(
+      &PiranhaArgumentsBuilder::default()
+        .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+        .path_to_configurations(path.join("configurations").to_str().unwrap().to_string())
+        .language(PiranhaLanguage::from(GO))
+        .substitutions(vec![
+          ("treated".to_string(), "true".to_string()),
+          ("treated_complement".to_string(), "false".to_string()),
+        ])
+        .explain(format!("{}:{line}", sample.to_str().unwrap()))
+        .build(),
+    )
+  };
+  let outcome_of = |explanations: &[RuleExplanation], rule: &str| {
+    explanations
+      .iter()
+      .find(|e| e.rule() == rule)
+      .map(|e| (e.outcome().to_string(), e.reason().to_string()))
+  };
+
+  // The evaluation of the flag is suppressed by the directive on the same line
+  let (outcome, reason) = outcome_of(&explain(20), "true_flag").unwrap();
+  assert_eq!(outcome, "rejected");
+  assert!(reason.contains("piranha:ignore"));
+  // The seed rule of the complement does not match the evaluation
+  let (outcome, _) = outcome_of(&explain(20), "false_flag").unwrap();
+  assert_eq!(outcome, "not matched");
+  // The evaluation that is not suppressed is cleaned up
+  let (outcome, _) = outcome_of(&explain(27), "true_flag").unwrap();
+  assert_eq!(outcome, "applied");
+
+  // No edit is made when explaining a position
+  assert_eq!(
+    fs::read_to_string(&sample).unwrap(),
+    fs::read_to_string(path.join("input").join("sample.go")).unwrap()
+  );
+  _ = temp_dir.close().unwrap();
+}
+
+#[test]
+fn test_parallel_packages() {
+  initialize();
+  let path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/parallel_packages");
+  // The output does not depend on the number of workers, nor on the order the packages are processed in
+  for jobs in [1, 4] {
+    let temp_dir = copy_folder_to_temp_dir(&path.join("input"));
+    let piranha_arguments = PiranhaArgumentsBuilder::default()
+      .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+      .path_to_configurations(path.join("configurations").to_str().unwrap().to_string())
+      .language(PiranhaLanguage::from(GO))
+      .substitutions(vec![
+        ("stale_flag_name".to_string(), "stale_flag".to_string()),
+        ("treated".to_string(), "true".to_string()),
+        ("treated_complement".to_string(), "false".to_string()),
+      ])
+      .jobs(jobs)
+      .build();
+    let summaries = execute_piranha(&piranha_arguments);
+
+    let paths = summaries
+      .iter()
+      .map(|s| {
+        PathBuf::from(s.path())
+          .strip_prefix(temp_dir.path())
+          .unwrap()
+          .to_path_buf()
+      })
+      .collect::<Vec<_>>();
+    assert_eq!(
+      paths,
+      vec![
+        PathBuf::from("checkout/checkout.go"),
+        PathBuf::from("payments/payments.go"),
+        PathBuf::from("payments/refunds.go"),
+      ]
+    );
+    for relative_path in [
+      "checkout/checkout.go",
+      "payments/payments.go",
+      "payments/refunds.go",
+      "search/search.go",
+    ] {
+      assert!(eq_without_whitespace(
+        &fs::read_to_string(temp_dir.path().join(relative_path)).unwrap(),
+        &fs::read_to_string(path.join("expected").join(relative_path)).unwrap()
+      ));
+    }
+    _ = temp_dir.close().unwrap();
+  }
+}
+
+#[test]
+fn test_analysis_cache() {
+  initialize();
+  let path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/parallel_packages");
+  let temp_dir = copy_folder_to_temp_dir(&path.join("input"));
+  let cache_dir = TempDir::new("analysis_cache").unwrap();
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(temp_dir.path().to_str().unwrap().to_string())
+    .path_to_configurations(path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![
+      ("stale_flag_name".to_string(), "stale_flag".to_string()),
+      ("treated".to_string(), "true".to_string()),
+      ("treated_complement".to_string(), "false".to_string()),
+    ])
+    .dry_run(true)
+    .jobs(1)
+    .cache_dir(cache_dir.path().to_str().unwrap().to_string())
+    .build();
+  // The second run skips the files found clean by the first one (i.e. `search/search.go`), with the same output
+  let summaries = execute_piranha(&piranha_arguments);
+  assert!(cache_dir.path().join("analysis_cache.json").exists());
+  let cached_summaries = execute_piranha(&piranha_arguments);
+  assert_eq!(summaries.len(), 3);
+  assert_eq!(cached_summaries.len(), 3);
+  for (summary, cached_summary) in summaries.iter().zip(cached_summaries.iter()) {
+    assert_eq!(summary.path(), cached_summary.path());
+    assert_eq!(summary.content(), cached_summary.content());
+  }
+  assert!(
+    fs::read_to_string(cache_dir.path().join("analysis_cache.json"))
+      .unwrap()
+      .contains("\":[\"")
+  );
+  _ = temp_dir.close().unwrap();
+}
+
+#[test]
+fn test_clean_in_memory() {
+  initialize();
+  let path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/parallel_packages");
+  let relative_paths = [
+    "checkout/checkout.go",
+    "payments/payments.go",
+    "payments/refunds.go",
+    "search/search.go",
+  ];
+  let files: BTreeMap<String, String> = relative_paths
+    .iter()
+    .map(|p| {
+      (
+        p.to_string(),
+        fs::read_to_string(path.join("input").join(p)).unwrap(),
+      )
+    })
+    .collect();
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_configurations(path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(vec![
+      ("stale_flag_name".to_string(), "stale_flag".to_string()),
+      ("treated".to_string(), "true".to_string()),
+      ("treated_complement".to_string(), "false".to_string()),
+    ])
+    .build();
+  let result = clean(&files, &piranha_arguments).unwrap();
+
+  assert_eq!(
+    result
+      .summaries()
+      .iter()
+      .map(|s| s.path().as_str())
+      .collect::<Vec<_>>(),
+    vec![
+      "checkout/checkout.go",
+      "payments/payments.go",
+      "payments/refunds.go"
+    ]
+  );
+  for relative_path in relative_paths {
+    assert!(eq_without_whitespace(
+      &result.files()[relative_path],
+      &fs::read_to_string(path.join("expected").join(relative_path)).unwrap()
+    ));
+  }
+  assert_eq!(*result.statistics().files_modified(), 3);
+  // The paths escaping the root of the files are rejected
+  assert!(clean(
+    &BTreeMap::from([("../main.go".to_string(), "package main\n".to_string())]),
+    &piranha_arguments
+  )
+  .is_err());
+}
+
+#[test]
+fn test_lsp_remove_stale_flag() {
+  initialize();
+  let path = PathBuf::from("test-resources")
+    .join(GO)
+    .join("feature_flag/builtin_rules/parallel_packages")
+    .canonicalize()
+    .unwrap();
+  let payments = path.join("input/payments/payments.go");
+  let uri = format!("file://{}", payments.to_str().unwrap());
+  let piranha_arguments = PiranhaArgumentsBuilder::default()
+    .path_to_codebase(path.join("input").to_str().unwrap().to_string())
+    .path_to_configurations(path.join("configurations").to_str().unwrap().to_string())
+    .language(PiranhaLanguage::from(GO))
+    .substitutions(substitutions! {
+      "stale_flag_name" => "stale_flag",
+      "treated" => "true",
+      "treated_complement" => "false"
+    })
+    .serve(true)
+    .lsp(true)
+    .build();
+  let line = fs::read_to_string(&payments)
+    .unwrap()
+    .lines()
+    .position(|l| l.contains("exp.BoolValue"))
+    .unwrap();
+  let range =
+    json!({ "start": { "line": line, "character": 0 }, "end": { "line": line, "character": 0 } });
+  let messages = [
+    json!({ "jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {} }),
+    json!({ "jsonrpc": "2.0", "method": "textDocument/didOpen", "params": { "textDocument": {
+      "uri": uri, "languageId": "go", "version": 1, "text": fs::read_to_string(&payments).unwrap()
+    } } }),
+    json!({ "jsonrpc": "2.0", "id": 2, "method": "textDocument/codeAction", "params": {
+      "textDocument": { "uri": uri }, "range": range, "context": { "diagnostics": [] }
+    } }),
+    json!({ "jsonrpc": "2.0", "id": 3, "method": "workspace/executeCommand", "params": {
+      "command": "piranha.removeStaleFlag",
+      "arguments": [{ "uri": uri, "flag": "stale_flag", "scope": "package" }]
+    } }),
+    json!({ "jsonrpc": "2.0", "id": 4, "method": "shutdown" }),
+    json!({ "jsonrpc": "2.0", "method": "exit" }),
+  ];
+  let input: String = messages
+    .iter()
+    .map(|m| format!("Content-Length: {}\r\n\r\n{m}", m.to_string().len()))
+    .collect();
+  let mut output = vec![];
+  serve_lsp(&piranha_arguments, &mut input.as_bytes(), &mut output).unwrap();
+  let output: Vec<Value> = String::from_utf8(output)
+    .unwrap()
+    .split("Content-Length: ")
+    .filter_map(|m| m.split_once("\r\n\r\n"))
+    .map(|(_, m)| serde_json::from_str(m).unwrap())
+    .collect();
+
+  // The usage of the flag is published as a diagnostic
+  assert_eq!(
+    output[1]["method"],
+    json!("textDocument/publishDiagnostics")
+  );
+  let diagnostics = output[1]["params"]["diagnostics"].as_array().unwrap();
+  assert_eq!(diagnostics.len(), 1);
+  assert_eq!(diagnostics[0]["range"]["start"]["line"], json!(line));
+  // The flag is cleaned up in the file, or in its package
+  let titles: Vec<&str> = output[2]["result"]
+    .as_array()
+    .unwrap()
+    .iter()
+    .map(|a| a["title"].as_str().unwrap())
+    .collect();
+  assert_eq!(
+    titles,
+    vec![
+      "Remove stale flag: stale_flag",
+      "Remove stale flag: stale_flag (package)"
+    ]
+  );
+  // The edits of the files of the package are applied by the client
+  assert_eq!(
+    output[3],
+    json!({ "jsonrpc": "2.0", "id": 3, "result": null })
+  );
+  assert_eq!(output[4]["method"], json!("workspace/applyEdit"));
+  let changes = output[4]["params"]["edit"]["changes"].as_object().unwrap();
+  let mut uris: Vec<&String> = changes.keys().collect();
+  uris.sort();
+  assert_eq!(
+    uris,
+    vec![
+      &uri,
+      &format!(
+        "file://{}",
+        path.join("input/payments/refunds.go").to_str().unwrap()
+      )
+    ]
+  );
+  // The files are left as they are
+  assert!(fs::read_to_string(&payments)
+    .unwrap()
+    .contains("exp.BoolValue"));
 }
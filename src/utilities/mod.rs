@@ -21,15 +21,28 @@ use std::hash::Hash;
 use std::io::{BufReader, Read};
 use std::path::PathBuf;
 
+use log::{debug, trace};
+use similar::{ChangeTag, DiffTag, TextDiff};
+
 // Reads a file.
 pub(crate) fn read_file(file_path: &PathBuf) -> Result<String, String> {
+  trace!("Reading the file {:?}", file_path);
   File::open(file_path)
     .map(|file| {
       let mut content = String::new();
-      let _ = BufReader::new(file).read_to_string(&mut content);
+      // The files that are not valid UTF-8 (e.g. UTF-16) are read as empty, i.e. left as they are
+      if let Err(error) = BufReader::new(file).read_to_string(&mut content) {
+        debug!(
+          "Could not read the file {:?} as UTF-8 : {}",
+          file_path, error
+        );
+      }
       content
     })
-    .map_err(|error| error.to_string())
+    .map_err(|error| {
+      debug!("Could not read the file {:?} : {}", file_path, error);
+      error.to_string()
+    })
 }
 
 // Reads a toml file. In case of error, it returns a default value (if return_default is true) else panics.
@@ -101,12 +114,64 @@ pub(crate) fn parse_key_val(
   Ok((s[..pos].parse()?, s[pos + 1..].parse()?))
 }
 
+/// Parse comma-separated key-value pairs (e.g. `stale_flag_name=SOME_FLAG,treated=true`)
+pub(crate) fn parse_key_vals(
+  s: &str,
+) -> Result<Vec<(String, String)>, Box<dyn Error + Send + Sync + 'static>> {
+  s.split(',').map(parse_key_val).collect()
+}
+
 pub(crate) fn parse_glob_pattern(
   s: &str,
 ) -> Result<Pattern, Box<dyn Error + Send + Sync + 'static>> {
   Ok(Pattern::new(s)?)
 }
 
+/// A hunk of the diff of a file, i.e. the bytes `start..end` of the original content replaced with `text`
+pub(crate) struct Hunk {
+  pub(crate) start: usize,
+  pub(crate) end: usize,
+  pub(crate) text: String,
+}
+
+/// Returns the hunks of the (line) diff of the original content and the content of the file
+pub(crate) fn get_hunks(original_content: &str, content: &str) -> Vec<Hunk> {
+  let diff = TextDiff::from_lines(original_content, content);
+  let (old_lines, new_lines) = (diff.old_slices(), diff.new_slices());
+  let offset = |line: usize| old_lines[..line].iter().map(|l| l.len()).sum::<usize>();
+  let mut hunks: Vec<Hunk> = vec![];
+  for op in diff.ops().iter().filter(|op| op.tag() != DiffTag::Equal) {
+    let (old_range, new_range) = (op.old_range(), op.new_range());
+    let (start, end, text) = (
+      offset(old_range.start),
+      offset(old_range.end),
+      new_lines[new_range].concat(),
+    );
+    // Merge the adjacent changes (e.g. a deletion followed by an insertion)
+    match hunks.last_mut() {
+      Some(last) if last.end == start => {
+        last.end = end;
+        last.text.push_str(&text);
+      }
+      _ => hunks.push(Hunk { start, end, text }),
+    }
+  }
+  hunks
+}
+
+/// Returns the number of lines added and removed (i.e. the ones of the unified diff) from the original content to the content
+pub(crate) fn count_changed_lines(original_content: &str, content: &str) -> (usize, usize) {
+  let (mut lines_added, mut lines_removed) = (0, 0);
+  for change in TextDiff::from_lines(original_content, content).iter_all_changes() {
+    match change.tag() {
+      ChangeTag::Insert => lines_added += 1,
+      ChangeTag::Delete => lines_removed += 1,
+      ChangeTag::Equal => (),
+    }
+  }
+  (lines_added, lines_removed)
+}
+
 /// Returns the file with the given name within the given directory.
 #[cfg(test)] // Rust analyzer FP
 pub(crate) fn find_file(input_dir: &PathBuf, name: &str) -> PathBuf {
@@ -231,7 +231,7 @@ pub(crate) fn get_tree_sitter_edit(code: String, edit: &Edit) -> (String, InputE
 }
 
 // Finds the position (col and row number) for a given offset.
-fn position_for_offset(input: &[u8], offset: usize) -> Point {
+pub(crate) fn position_for_offset(input: &[u8], offset: usize) -> Point {
   let mut result = Point { row: 0, column: 0 };
   for c in &input[0..offset] {
     if *c as char == '\n' {
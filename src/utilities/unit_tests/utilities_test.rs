@@ -15,7 +15,7 @@ use crate::utilities::find_file;
 use serde_derive::Deserialize;
 use std::path::PathBuf;
 
-use super::{read_file, read_toml};
+use super::{get_hunks, read_file, read_toml};
 
 #[derive(Deserialize, Default)]
 struct TestStruct {
@@ -66,3 +66,17 @@ fn test_find_file_negative() {
   let f = find_file(&project_root, "another_sample.toml.toml");
   assert!(f.is_file());
 }
+
+#[test]
+fn test_get_hunks() {
+  let hunks = get_hunks("a\nb\nc\n", "a\nx\nc\nd\n");
+  assert_eq!(hunks.len(), 2);
+  assert_eq!(
+    (hunks[0].start, hunks[0].end, hunks[0].text.as_str()),
+    (2, 4, "x\n")
+  );
+  assert_eq!(
+    (hunks[1].start, hunks[1].end, hunks[1].text.as_str()),
+    (6, 6, "d\n")
+  );
+}
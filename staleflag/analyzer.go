@@ -0,0 +1,227 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package staleflag defines an analyzer reporting the usages of the stale flags remaining in a package,
+// along with their cleanup computed by Piranha as suggested fixes, e.g. to run the check under `go vet`, nogo or golangci-lint.
+//
+// The analyzer runs the Piranha binary (i.e. `polyglot_piranha`) in the check mode (i.e. `--check --report analysis`)
+// on the files of the package, and converts the usages it reports into diagnostics.
+package staleflag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const doc = `report the usages of the stale flags remaining in the package
+
+The usages are found by Piranha (i.e. the matches of its seed rules, except the ones
+exempted by piranha:ignore), and come with their cleanup as a suggested fix.`
+
+// Analyzer reports the usages of the stale flags, configured by its flags (e.g. -flag and -configurations).
+var Analyzer = &analysis.Analyzer{
+	Name: "staleflag",
+	Doc:  doc,
+	Run:  run,
+}
+
+var (
+	// The path of the Piranha binary, i.e. the `polyglot_piranha` binary of the crate
+	piranha = "polyglot_piranha"
+	// The directory containing the rules of the flag APIs (i.e. `rules.toml` and `edges.toml`)
+	configurations string
+	// The stale flags, each as comma-separated substitutions (e.g. `stale_flag_name=SOME_FLAG,treated=true`) or only as its name
+	flags stringList
+	// The file listing the stale flags (e.g. exported by the flag management system)
+	flagFile string
+	// The comma-separated built-in rule packs of the flag SDKs (e.g. `launchdarkly`)
+	rulePacks string
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&piranha, "piranha", piranha, "path of the Piranha binary (polyglot_piranha)")
+	Analyzer.Flags.StringVar(&configurations, "configurations", "", "directory containing the rules of the flag APIs (i.e. rules.toml and edges.toml)")
+	Analyzer.Flags.Var(&flags, "flag", "stale flag, as comma-separated substitutions (e.g. stale_flag_name=SOME_FLAG,treated=true) or only as its name (repeatable)")
+	Analyzer.Flags.StringVar(&flagFile, "flag-file", "", "file (JSON or CSV) listing the stale flags")
+	Analyzer.Flags.StringVar(&rulePacks, "rule-packs", "", "comma-separated built-in rule packs of the flag SDKs (e.g. launchdarkly)")
+}
+
+// The report of the remaining usages of the stale flags (i.e. `--report analysis`, see `src/models/analysis.rs`)
+type report struct {
+	Diagnostics []diagnostic `json:"diagnostics"`
+}
+
+// A usage of a stale flag, whose offsets are bytes of the file
+type diagnostic struct {
+	Path           string         `json:"path"`
+	Start          int            `json:"start"`
+	End            int            `json:"end"`
+	Category       string         `json:"category"`
+	Message        string         `json:"message"`
+	SuggestedFixes []suggestedFix `json:"suggested_fixes"`
+}
+
+type suggestedFix struct {
+	Message   string     `json:"message"`
+	TextEdits []textEdit `json:"text_edits"`
+}
+
+type textEdit struct {
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	NewText string `json:"new_text"`
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	if len(flags) == 0 && flagFile == "" {
+		return nil, errors.New("no stale flag is configured, use -flag or -flag-file")
+	}
+	files := map[string]*token.File{}
+	for _, f := range pass.Files {
+		file := pass.Fset.File(f.Pos())
+		if path, err := filepath.Abs(file.Name()); err == nil {
+			files[path] = file
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	dir := filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name())
+	r, err := check(dir, files)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range r.Diagnostics {
+		path := d.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		file, ok := files[filepath.Clean(path)]
+		if !ok {
+			continue
+		}
+		start, end, ok := getRange(file, d.Start, d.End)
+		if !ok {
+			continue
+		}
+		diagnostic := analysis.Diagnostic{
+			Pos:      start,
+			End:      end,
+			Category: d.Category,
+			Message:  d.Message,
+		}
+		for _, f := range d.SuggestedFixes {
+			fix := analysis.SuggestedFix{Message: f.Message}
+			for _, e := range f.TextEdits {
+				if start, end, ok := getRange(file, e.Start, e.End); ok {
+					fix.TextEdits = append(fix.TextEdits, analysis.TextEdit{Pos: start, End: end, NewText: []byte(e.NewText)})
+				}
+			}
+			if len(fix.TextEdits) == len(f.TextEdits) {
+				diagnostic.SuggestedFixes = append(diagnostic.SuggestedFixes, fix)
+			}
+		}
+		pass.Report(diagnostic)
+	}
+	return nil, nil
+}
+
+// Runs Piranha in the check mode on the files (of the package in dir), and returns the report of the remaining usages
+func check(dir string, files map[string]*token.File) (*report, error) {
+	output, err := os.CreateTemp("", "staleflag-*.json")
+	if err != nil {
+		return nil, err
+	}
+	output.Close()
+	defer os.Remove(output.Name())
+
+	args := []string{"--check", "-c", dir, "-l", "go", "--report", "analysis", "--path-to-report", output.Name()}
+	if configurations != "" {
+		args = append(args, "-f", configurations)
+	}
+	for _, f := range flags {
+		args = append(args, "--flags="+f)
+	}
+	if flagFile != "" {
+		args = append(args, "--flag-file", flagFile)
+	}
+	for _, p := range strings.Split(rulePacks, ",") {
+		if p != "" {
+			args = append(args, "--rule-packs="+p)
+		}
+	}
+	for path := range files {
+		args = append(args, "--include="+escapeGlob(path))
+	}
+	cmd := exec.Command(piranha, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	// Piranha exits with 1 when the stale flags are still used
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			return nil, fmt.Errorf("running %s: %v\n%s", piranha, err, stderr.String())
+		}
+	}
+	content, err := os.ReadFile(output.Name())
+	if err != nil {
+		return nil, err
+	}
+	var r report
+	if err := json.Unmarshal(content, &r); err != nil {
+		return nil, fmt.Errorf("reading the report of %s: %v", piranha, err)
+	}
+	return &r, nil
+}
+
+// Returns the positions of the byte offsets of the file, if they are within the file
+func getRange(file *token.File, start, end int) (token.Pos, token.Pos, bool) {
+	if start < 0 || start > end || end > file.Size() {
+		return token.NoPos, token.NoPos, false
+	}
+	return file.Pos(start), file.Pos(end), true
+}
+
+// Escapes the metacharacters of the glob patterns (i.e. of `--include`) in the path
+func escapeGlob(path string) string {
+	var b strings.Builder
+	for _, c := range path {
+		switch c {
+		case '*', '?', '[', ']':
+			b.WriteString("[" + string(c) + "]")
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// A repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package staleflag
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	testdata := analysistest.TestData()
+	content, err := os.ReadFile(filepath.Join(testdata, "src", "a", "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := `isEnabled("stale_flag")`
+	start := strings.Index(string(content), usage)
+	end := start + len(usage)
+
+	// The fake piranha writes the report of a usage of the stale flag in the included file (i.e. `%s`), and exits with 1
+	report := fmt.Sprintf(`{"diagnostics": [{"path": "%%s", "start": %d, "end": %d, "category": "replace_is_enabled",
+"message": "the stale flag %[3]sstale_flag%[3]s is still used (matched by %[3]sreplace_is_enabled%[3]s)",
+"suggested_fixes": [{"message": "Clean up the stale flag", "text_edits": [{"start": %[1]d, "end": %[2]d, "new_text": "true"}]}]}]}`,
+		start, end, "`")
+	script := filepath.Join(t.TempDir(), "piranha")
+	err = os.WriteFile(script, []byte(`#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --path-to-report) report=$2; shift ;;
+    --include=*) file=${1#--include=} ;;
+  esac
+  shift
+done
+printf '`+report+`' "$file" > "$report"
+exit 1
+`), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setFlags(t, map[string]string{"piranha": script, "flag": "stale_flag"})
+	analysistest.RunWithSuggestedFixes(t, testdata, Analyzer, "a")
+}
+
+// Runs the analyzer with the Piranha binary itself, on the rules of `testdata/configurations`
+func TestAnalyzerWithPiranha(t *testing.T) {
+	binary, err := exec.LookPath("polyglot_piranha")
+	if err != nil {
+		t.Skip("polyglot_piranha is not installed")
+	}
+	testdata := analysistest.TestData()
+	setFlags(t, map[string]string{
+		"piranha":        binary,
+		"flag":           "stale_flag_name=stale_flag,treated=true",
+		"configurations": filepath.Join(testdata, "configurations"),
+	})
+	analysistest.RunWithSuggestedFixes(t, testdata, Analyzer, "a")
+}
+
+// Sets the flags of the analyzer, and restores their previous values at the end of the test
+func setFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	previous := map[string]string{}
+	for name, value := range values {
+		previous[name] = Analyzer.Flags.Lookup(name).Value.String()
+		if err := Analyzer.Flags.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for name, value := range previous {
+			if name == "flag" {
+				// -flag is repeatable, i.e. setting it appends to the stale flags
+				flags = nil
+			} else {
+				Analyzer.Flags.Set(name, value)
+			}
+		}
+	})
+}
+
+func TestEscapeGlob(t *testing.T) {
+	if got, want := escapeGlob("/src/a[1]/*.go"), "/src/a[[]1[]]/[*].go"; got != want {
+		t.Errorf("escapeGlob() = %q, want %q", got, want)
+	}
+}
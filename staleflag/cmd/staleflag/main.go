@@ -0,0 +1,29 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// The staleflag command runs the staleflag analyzer, e.g. as a tool of `go vet`:
+//
+//	go vet -vettool=$(which staleflag) -staleflag.flag=SOME_FLAG -staleflag.configurations=./configurations ./...
+//
+// or on its own (with -fix to apply the cleanup of the usages):
+//
+//	staleflag -flag=SOME_FLAG -configurations=./configurations -fix ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/uber/piranha/staleflag"
+)
+
+func main() { singlechecker.Main(staleflag.Analyzer) }
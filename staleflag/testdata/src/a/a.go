@@ -0,0 +1,8 @@
+package a
+
+func isEnabled(name string) bool { return name != "" }
+
+func run() {
+	enabled := isEnabled("stale_flag") // want `the stale flag .stale_flag. is still used`
+	println(enabled)
+}
@@ -0,0 +1,48 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+func logOldFlow() {
+    if exp.BoolValue("false") {
+        fmt.Println("old flow")
+    }
+}
+
+func (c *Client) trackOldFlow(name string) {
+    if exp.BoolValue("false") {
+        c.metrics.Inc(name)
+    }
+}
+
+func (c *Client) runOldFlow() {
+    c.trackOldFlow("old")
+    logOldFlow()
+}
+
+// exported functions are kept, since they may be called from other packages
+func LogOldFlow() {
+    if exp.BoolValue("false") {
+        fmt.Println("old flow")
+    }
+}
+
+func (c *Client) run() {
+    logOldFlow()
+    c.runOldFlow()
+    LogOldFlow()
+    fmt.Println("new flow")
+}
@@ -53,22 +53,20 @@ func simplify_false_and_something(something bool) {
     fmt.Println("else 4")
     // selector_expression: simplify
     fmt.Println("else 5")
-    // does not simplify binary_expression; left call may contain side-effects
-    if exp.BoolValue("random") && false {
-        fmt.Println("keep 1")
-    } else {
-        fmt.Println("keep 2")
-    }
+    // hoists the left call, since it may contain side-effects
+    exp.BoolValue("random")
+    fmt.Println("keep 2")
 
     // function call && false
-    if f1() && false {
-        fmt.Println("keep as it is")
-    }
+    f1()
 
     // function call || true
-    if f1() || true {
-        fmt.Println("keep as it is")
-    }
+    f1()
+    fmt.Println("keep as it is")
+
+    // does not simplify binary_expression outside an if statement
+    enabled := f1() && false
+    fmt.Println(enabled)
 }
 
 // simplify `!true` and `!false` and also:
@@ -81,10 +79,9 @@ func simplify_true_or_something(something bool) {
     fmt.Println("only true 4")
     // selector_expression: simplify
     fmt.Println("only true 5")
-    // does not simplify binary_expression; left call may contain side-effects
-    if exp.BoolValue("random") || true {
-        fmt.Println("keep")
-    }
+    // hoists the left call, since it may contain side-effects
+    exp.BoolValue("random")
+    fmt.Println("keep")
 }
 
 // simplify `!true` and `!false` and also:
@@ -138,3 +135,84 @@ func simplify_identity_eq_nil() {
 func simplify_identity_neq_nil() {
     fmt.Println("keep")
 }
+
+// compound conditions fold down to the operands that are left
+func simplify_compound(a bool, b bool) {
+    fmt.Println("a && b && false")
+    fmt.Println("a || (b || true)")
+    if a || b {
+        fmt.Println("a || b")
+    }
+    // does not simplify; the left operand contains a call
+    if a && f1() && false {
+        fmt.Println("keep")
+    }
+}
+
+func simplify_negations(a bool, x int) {
+    fmt.Println("double negation")
+    if a {
+        fmt.Println("a")
+    }
+    if x != 0 {
+        fmt.Println("x != 0")
+    }
+    if x == 0 {
+        fmt.Println("x == 0")
+    }
+    // the parentheses delimit the binary operand
+    if !(x > 0 == a) {
+        fmt.Println("x > 0 != a")
+    }
+}
+
+func simplify_compare_with_literal(a bool) {
+    if a {
+        fmt.Println("a == true")
+    }
+    if !a {
+        fmt.Println("a != true")
+    }
+    if !a {
+        fmt.Println("a == false")
+    }
+    if a {
+        fmt.Println("a != false")
+    }
+}
+
+func simplify_de_morgan(a bool, b bool) {
+    if a || b {
+        fmt.Println("a || b")
+    }
+    if a && b {
+        fmt.Println("a && b")
+    }
+}
+
+// does not hoist the call out of an `else if`, out of an if statement with an initializer,
+// nor a call to a builtin or a conversion, which are not valid statements
+func simplify_hoist_exceptions(a bool, b bool, items []int) {
+    if a {
+        fmt.Println("a")
+    } else if f1() || true {
+        fmt.Println("else if or true")
+    }
+    if a {
+        fmt.Println("a")
+    } else if f1() && false {
+        fmt.Println("else if and false")
+    }
+    if v := f2(); f1() || true {
+        fmt.Println(v)
+    }
+    if v := f2(); f1() && false {
+        fmt.Println(v)
+    }
+    if bool(b) || true {
+        fmt.Println("conversion")
+    }
+    if len(items) > 0 || true {
+        fmt.Println("builtin")
+    }
+}
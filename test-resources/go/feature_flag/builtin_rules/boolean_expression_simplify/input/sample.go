@@ -91,7 +91,7 @@ func simplify_false_and_something(something bool) {
     } else {
         fmt.Println("else 5")
     }
-    // does not simplify binary_expression; left call may contain side-effects
+    // hoists the left call, since it may contain side-effects
     if exp.BoolValue("random") && exp.BoolValue("false") {
         fmt.Println("keep 1")
     } else {
@@ -106,6 +106,10 @@ func simplify_false_and_something(something bool) {
     if f1() || exp.BoolValue("true") {
         fmt.Println("keep as it is")
     }
+
+    // does not simplify binary_expression outside an if statement
+    enabled := f1() && exp.BoolValue("false")
+    fmt.Println(enabled)
 }
 
 // simplify `!true` and `!false` and also:
@@ -130,7 +134,7 @@ func simplify_true_or_something(something bool) {
         fmt.Println("only true 5")
     }
 
-    // does not simplify binary_expression; left call may contain side-effects
+    // hoists the left call, since it may contain side-effects
     if exp.BoolValue("random") || exp.BoolValue("true") {
         fmt.Println("keep")
     }
@@ -230,3 +234,92 @@ func simplify_identity_neq_nil() {
         fmt.Println("keep")
     }
 }
+
+// compound conditions fold down to the operands that are left
+func simplify_compound(a bool, b bool) {
+    if a && b && exp.BoolValue("false") {
+        fmt.Println("removed")
+    } else {
+        fmt.Println("a && b && false")
+    }
+    if a || (b || exp.BoolValue("true")) {
+        fmt.Println("a || (b || true)")
+    }
+    if exp.BoolValue("false") || a || b {
+        fmt.Println("a || b")
+    }
+    // does not simplify; the left operand contains a call
+    if a && f1() && exp.BoolValue("false") {
+        fmt.Println("keep")
+    }
+}
+
+func simplify_negations(a bool, x int) {
+    if !!exp.BoolValue("true") {
+        fmt.Println("double negation")
+    }
+    if !(!a || exp.BoolValue("false")) {
+        fmt.Println("a")
+    }
+    if !(x == 0) || exp.BoolValue("false") {
+        fmt.Println("x != 0")
+    }
+    if !(x != 0) && exp.BoolValue("true") {
+        fmt.Println("x == 0")
+    }
+    // the parentheses delimit the binary operand
+    if !(x > 0 == a) || exp.BoolValue("false") {
+        fmt.Println("x > 0 != a")
+    }
+}
+
+func simplify_compare_with_literal(a bool) {
+    if a == exp.BoolValue("true") {
+        fmt.Println("a == true")
+    }
+    if a != exp.BoolValue("true") {
+        fmt.Println("a != true")
+    }
+    if a == exp.BoolValue("false") {
+        fmt.Println("a == false")
+    }
+    if a != exp.BoolValue("false") {
+        fmt.Println("a != false")
+    }
+}
+
+func simplify_de_morgan(a bool, b bool) {
+    if !(!a && !b && exp.BoolValue("true")) {
+        fmt.Println("a || b")
+    }
+    if !(!a || !b || exp.BoolValue("false")) {
+        fmt.Println("a && b")
+    }
+}
+
+// does not hoist the call out of an `else if`, out of an if statement with an initializer,
+// nor a call to a builtin or a conversion, which are not valid statements
+func simplify_hoist_exceptions(a bool, b bool, items []int) {
+    if a {
+        fmt.Println("a")
+    } else if f1() || exp.BoolValue("true") {
+        fmt.Println("else if or true")
+    }
+    if a {
+        fmt.Println("a")
+    } else if f1() && exp.BoolValue("false") {
+        fmt.Println("else if and false")
+    }
+    if v := f2(); f1() || exp.BoolValue("true") {
+        fmt.Println(v)
+    }
+    if v := f2(); f1() && exp.BoolValue("false") {
+        fmt.Println(v)
+    }
+    if bool(b) || exp.BoolValue("true") {
+        fmt.Println("conversion")
+    }
+    if len(items) > 0 || exp.BoolValue("true") {
+        fmt.Println("builtin")
+    }
+}
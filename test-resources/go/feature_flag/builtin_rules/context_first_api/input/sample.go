@@ -0,0 +1,42 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import (
+    "context"
+    "fmt"
+)
+
+func run(ctx context.Context, client *Client) {
+    if exp.BoolValue(ctx, "stale_flag") {
+        fmt.Println("enabled")
+    } else {
+        fmt.Println("disabled")
+    }
+
+    if !client.BoolVariation(ctx, "stale_flag", false) {
+        fmt.Println("variation disabled")
+    }
+
+    // does not match, the flag name is not the second argument
+    if client.BoolVariation("stale_flag", ctx, false) {
+        fmt.Println("unexpected signature")
+    }
+
+    // does not match, another flag
+    if client.BoolVariation(ctx, "other_flag", false) {
+        fmt.Println("other flag")
+    }
+}
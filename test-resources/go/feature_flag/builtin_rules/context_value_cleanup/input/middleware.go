@@ -0,0 +1,43 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+const newCheckoutKey contextKey = "new-checkout"
+
+const (
+	requestIDKey  contextKey = "request-id"
+	newPricingKey contextKey = "new-pricing"
+)
+
+func withCheckoutFlag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), requestIDKey, r.Header.Get("X-Request-ID"))
+		ctx = context.WithValue(ctx, newCheckoutKey, exp.BoolValue("true"))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func withPricingFlag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), newPricingKey, exp.BoolValue("false")))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,61 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+func treated_first_arm(other bool) {
+    fmt.Println("A")
+}
+
+func control_first_arm(other bool) {
+    if other {
+        fmt.Println("B")
+    } else {
+        fmt.Println("C")
+    }
+}
+
+func treated_middle_arm(a bool) {
+    if a {
+        fmt.Println("A")
+    } else {
+        fmt.Println("B")
+    }
+}
+
+func control_middle_arm(a bool) {
+    if a {
+        fmt.Println("A")
+    } else if f1() {
+        fmt.Println("C")
+    } else {
+        fmt.Println("D")
+    }
+}
+
+func control_last_arm(a bool, b bool) {
+    if a {
+        fmt.Println("A")
+    } else if b {
+        fmt.Println("B")
+    }
+}
+
+func control_last_arm_with_initializer() {
+    if v := f1(); v {
+        fmt.Println("A")
+    }
+}
@@ -0,0 +1,53 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+func checkout() string {
+    variant, err := exp.StrValue("checkout_flow")
+    if err != nil {
+        return "error"
+    }
+    if variant == "treatment" {
+        return "new checkout"
+    }
+    return "old checkout"
+}
+
+func isNewCheckout() bool {
+    variant, _ := exp.StrValue("checkout_flow")
+    return variant == "treatment"
+}
+
+func banner() {
+    variant, err := exp.StrValue("checkout_flow")
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    if variant != "control" {
+        fmt.Println("new banner")
+    }
+}
+
+// only completes the declaration, since the error is reassigned
+func reassigned() error {
+    variant, err := exp.StrValue("checkout_flow")
+    if variant == "treatment" {
+        err = publish()
+    }
+    return err
+}
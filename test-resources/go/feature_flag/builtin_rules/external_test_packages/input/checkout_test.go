@@ -0,0 +1,36 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package checkout_test
+
+import (
+    "os"
+    "testing"
+
+    co "example.com/app/checkout"
+)
+
+// not the wrapper of the package under test, since the external test package is a package of its own
+func isNewFlowEnabled() bool {
+    return os.Getenv("NEW_FLOW") != ""
+}
+
+func TestRun(t *testing.T) {
+    if co.IsNewCheckoutEnabled() {
+        t.Log("new checkout")
+    }
+    if co.NewFlowEnabledForTest() && isNewFlowEnabled() {
+        co.Run()
+    }
+}
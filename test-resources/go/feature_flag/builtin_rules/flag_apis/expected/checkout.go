@@ -0,0 +1,56 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+    "context"
+    "fmt"
+
+    fx "github.com/acme/experiments/v2"
+)
+
+func checkout(ctx context.Context) string {
+    return "new checkout"
+}
+
+func banner(client *fx.Client) string {
+    return "new banner"
+}
+
+func theme(client *fx.Client) string {
+    return "dark theme"
+}
+
+func payment() string {
+    return "new payment"
+}
+
+func refund(ctx context.Context, client *fx.Client) string {
+    return "new refund"
+}
+
+func shipping(ctx context.Context, client *fx.Client) string {
+    // the live flag is evaluated along with the stale one
+    if fx.IsEnabled(ctx, "live_flag") {
+        return "new shipping"
+    }
+    return "default shipping"
+}
+
+func search(ctx context.Context) {
+    // does not match, another flag
+    if fx.IsEnabled(ctx, "other_flag") {
+        fmt.Println("new search")
+    }
+}
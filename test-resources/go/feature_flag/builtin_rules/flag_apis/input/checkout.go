@@ -0,0 +1,77 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+    "context"
+    "fmt"
+
+    fx "github.com/acme/experiments/v2"
+)
+
+func checkout(ctx context.Context) string {
+    if fx.IsEnabled(ctx, "stale_flag") {
+        return "new checkout"
+    } else {
+        return "old checkout"
+    }
+}
+
+func banner(client *fx.Client) string {
+    if client.IsDisabled("stale_flag") {
+        return "old banner"
+    }
+    return "new banner"
+}
+
+func theme(client *fx.Client) string {
+    if client.Variant("stale_flag") == "dark" {
+        return "dark theme"
+    }
+    return "light theme"
+}
+
+func payment() string {
+    if fx.Bool(&fx.Options{Flag: "stale_flag", Default: false}) {
+        return "new payment"
+    } else {
+        return "old payment"
+    }
+}
+
+func refund(ctx context.Context, client *fx.Client) string {
+    if client.Enabled(ctx, fx.WithDefault(false), fx.WithFlag("stale_flag")) {
+        return "new refund"
+    } else {
+        return "old refund"
+    }
+}
+
+func shipping(ctx context.Context, client *fx.Client) string {
+    // the live flag is evaluated along with the stale one
+    if fx.IsEnabled(ctx, "live_flag") && client.IsDisabled("stale_flag") {
+        return "old shipping"
+    }
+    if fx.IsEnabled(ctx, "live_flag") && fx.IsEnabled(ctx, "stale_flag") {
+        return "new shipping"
+    }
+    return "default shipping"
+}
+
+func search(ctx context.Context) {
+    // does not match, another flag
+    if fx.IsEnabled(ctx, "other_flag") {
+        fmt.Println("new search")
+    }
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+func run(ctx context.Context, done chan bool) {
+    go func() {
+        if exp.BoolValue("false") {
+            fmt.Println("old worker")
+        }
+    }()
+
+    go func(c context.Context) {
+        if exp.BoolValue("true") {
+            fmt.Println("new worker", c)
+        } else {
+            fmt.Println("old worker", c)
+        }
+    }(ctx)
+
+    defer func() {
+        if exp.BoolValue("false") {
+            fmt.Println("old cleanup")
+        }
+    }()
+
+    // kept, since the argument is evaluated right away
+    defer func(n int) {
+        if exp.BoolValue("false") {
+            fmt.Println("old cleanup", n)
+        }
+    }(compute())
+
+    func() {
+        enabled := exp.BoolValue("false")
+        if enabled {
+            fmt.Println("old setup")
+        }
+    }()
+
+    register(func() {
+        if !exp.BoolValue("true") {
+            fmt.Println("old callback")
+        }
+        done <- true
+    })
+}
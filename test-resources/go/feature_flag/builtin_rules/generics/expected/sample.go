@@ -0,0 +1,38 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+type Cache[K comparable, V any] struct {
+    items map[K]V
+}
+
+func Process[T any](items []T) {
+    fmt.Println("new flow", len(items))
+}
+
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+    v, ok := c.items[key]
+    return v, ok
+}
+
+func run() {
+    Process[int]([]int{1, 2})
+    fmt.Println("generic wrapper")
+    // flag checks passed along with the type arguments
+    values := Map[int, bool]([]int{1}, false)
+    fmt.Println(values)
+}
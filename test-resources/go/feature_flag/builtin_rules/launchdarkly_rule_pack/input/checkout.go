@@ -0,0 +1,59 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+    "context"
+    "log"
+
+    "github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+    ld "github.com/launchdarkly/go-server-sdk/v6"
+)
+
+func checkout(client *ld.LDClient, user ldcontext.Context) string {
+    enabled, err := client.BoolVariation("stale_flag", user, false)
+    if err != nil {
+        return "error"
+    }
+    if enabled {
+        return "new checkout"
+    }
+    return "old checkout"
+}
+
+func banner(ctx context.Context, client *ld.LDClient, user ldcontext.Context) string {
+    enabled, _ := client.BoolVariationCtx(ctx, "stale_flag", user, false)
+    if !enabled {
+        return "old banner"
+    }
+    return "new banner"
+}
+
+func pricing(client *ld.LDClient, user ldcontext.Context) string {
+    enabled, detail, err := client.BoolVariationDetail("stale_flag", user, false)
+    log.Printf("stale_flag evaluated: %v", detail.Reason)
+    if err != nil {
+        return "error"
+    }
+    if enabled {
+        return "new pricing"
+    }
+    return "old pricing"
+}
+
+// not cleaned up, since the flag is live
+func search(client *ld.LDClient, user ldcontext.Context) bool {
+    enabled, _ := client.BoolVariation("live_flag", user, false)
+    return enabled
+}
@@ -0,0 +1,84 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+func poll() {
+	for exp.BoolValue("false") {
+		fmt.Println("polling")
+	}
+	fmt.Println("polled")
+}
+
+func retry() {
+outer:
+	for exp.BoolValue("false") {
+		for {
+			if done() {
+				break outer
+			}
+		}
+	}
+	fmt.Println("retried")
+}
+
+func serve() {
+	for exp.BoolValue("true") {
+		fmt.Println("serving")
+	}
+}
+
+func count() {
+	for i := 0; exp.BoolValue("true"); i++ {
+		fmt.Println(i)
+	}
+}
+
+func process(items []string) {
+outer:
+	for _, item := range items {
+		for _, c := range item {
+			if exp.BoolValue("false") {
+				continue outer
+			}
+			fmt.Println(c)
+		}
+	}
+}
+
+// kept, since the label is still referenced
+func scan(items []string) {
+outer:
+	for _, item := range items {
+		for _, c := range item {
+			if exp.BoolValue("false") {
+				continue outer
+			}
+			if c == ' ' {
+				break outer
+			}
+		}
+	}
+}
+
+func drain() {
+	for {
+		if exp.BoolValue("true") {
+			break
+		}
+		fmt.Println("draining")
+	}
+	fmt.Println("drained")
+}
@@ -0,0 +1,32 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+type Experiments interface {
+    BoolValue(name string) bool
+}
+
+func Checkout(exp Experiments) string {
+    if exp.BoolValue("stale_flag") {
+        return "new"
+    }
+    return "old"
+}
+
+func Discount(exp Experiments) int {
+    if exp.BoolValue("other_flag") {
+        return 10
+    }
+    return 0
+}
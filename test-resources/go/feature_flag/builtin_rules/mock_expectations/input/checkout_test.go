@@ -0,0 +1,60 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+    "testing"
+
+    "github.com/golang/mock/gomock"
+    "github.com/stretchr/testify/mock"
+)
+
+type testifyExperiments struct {
+    mock.Mock
+}
+
+func (m *testifyExperiments) BoolValue(name string) bool {
+    return m.Called(name).Bool(0)
+}
+
+func TestCheckout_Gomock(t *testing.T) {
+    ctrl := gomock.NewController(t)
+    mockExp := NewMockExperiments(ctrl)
+    // The new flow is enabled
+    mockExp.EXPECT().BoolValue("stale_flag").Return(true)
+    mockExp.EXPECT().BoolValue("other_flag").Return(false).AnyTimes()
+    if got := Checkout(mockExp); got != "new" {
+        t.Errorf("got %q", got)
+    }
+}
+
+func TestDiscount_Gomock(t *testing.T) {
+    ctrl := gomock.NewController(t)
+    mockExp := NewMockExperiments(ctrl)
+    mockExp.EXPECT().BoolValue("stale_flag").Return(true).Times(1)
+    mockExp.EXPECT().BoolValue("other_flag").Return(true)
+    if got := Discount(mockExp); got != 10 {
+        t.Errorf("got %d", got)
+    }
+}
+
+func TestCheckout_Testify(t *testing.T) {
+    m := &testifyExperiments{}
+    m.On("BoolValue", "stale_flag").Return(true).Once()
+    m.On("BoolValue", "other_flag").Return(false)
+    if got := Checkout(m); got != "new" {
+        t.Errorf("got %q", got)
+    }
+    m.AssertExpectations(t)
+}
@@ -0,0 +1,43 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+    "context"
+
+    "github.com/open-feature/go-sdk/openfeature"
+)
+
+func checkout(ctx context.Context, client *openfeature.Client, evalCtx openfeature.EvaluationContext) string {
+    return "new checkout"
+}
+
+func banner(ctx context.Context, client *openfeature.Client, evalCtx openfeature.EvaluationContext) string {
+    return "new banner"
+}
+
+func pricing(ctx context.Context, client *openfeature.Client, evalCtx openfeature.EvaluationContext) string {
+    return "new pricing"
+}
+
+// only the value is replaced, since the details are returned
+func evaluate(ctx context.Context, client *openfeature.Client, evalCtx openfeature.EvaluationContext) (string, openfeature.BooleanEvaluationDetails) {
+    details, _ := client.BooleanValueDetails(ctx, "stale_flag", false, evalCtx)
+    return "new", details
+}
+
+// not cleaned up, since the flag is live
+func search(ctx context.Context, client *openfeature.Client, evalCtx openfeature.EvaluationContext) bool {
+    return client.Boolean(ctx, "live_flag", false, evalCtx)
+}
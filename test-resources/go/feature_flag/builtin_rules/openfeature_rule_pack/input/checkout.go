@@ -0,0 +1,65 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+    "context"
+    "log"
+
+    "github.com/open-feature/go-sdk/openfeature"
+)
+
+func checkout(ctx context.Context, client *openfeature.Client, evalCtx openfeature.EvaluationContext) string {
+    enabled, err := client.BooleanValue(ctx, "stale_flag", false, evalCtx)
+    if err != nil {
+        return "error"
+    }
+    if enabled {
+        return "new checkout"
+    }
+    return "old checkout"
+}
+
+func banner(ctx context.Context, client *openfeature.Client, evalCtx openfeature.EvaluationContext) string {
+    if client.Boolean(ctx, "stale_flag", false, evalCtx) {
+        return "new banner"
+    }
+    return "old banner"
+}
+
+func pricing(ctx context.Context, client *openfeature.Client, evalCtx openfeature.EvaluationContext) string {
+    details, err := client.BooleanValueDetails(ctx, "stale_flag", false, evalCtx)
+    log.Printf("stale_flag evaluated to %s (%s)", details.Variant, details.Reason)
+    if err != nil {
+        return "error"
+    }
+    if details.Value {
+        return "new pricing"
+    }
+    return "old pricing"
+}
+
+// only the value is replaced, since the details are returned
+func evaluate(ctx context.Context, client *openfeature.Client, evalCtx openfeature.EvaluationContext) (string, openfeature.BooleanEvaluationDetails) {
+    details, _ := client.BooleanValueDetails(ctx, "stale_flag", false, evalCtx)
+    if details.Value {
+        return "new", details
+    }
+    return "old", details
+}
+
+// not cleaned up, since the flag is live
+func search(ctx context.Context, client *openfeature.Client, evalCtx openfeature.EvaluationContext) bool {
+    return client.Boolean(ctx, "live_flag", false, evalCtx)
+}
@@ -0,0 +1,55 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+func compute() int {
+    return 42
+}
+
+// the comment of an unwrapped `if` is deleted along with it
+func unwrap_consequence() {
+    x := compute() // computed eagerly
+    // TODO: remove after the experiment ships
+    if exp.BoolValue("true") {
+        fmt.Println("new", x)
+        // kept, it documents the retained code
+        fmt.Println("new path")
+    } else {
+        fmt.Println("old", x)
+    } // end of the experiment
+    fmt.Println("done")
+}
+
+func unwrap_alternative() {
+    // TODO: delete the new path when the experiment is over
+    if exp.BoolValue("false") {
+        fmt.Println("new")
+    } else {
+        fmt.Println("old")
+    }
+    // logs the completion
+    fmt.Println("done")
+}
+
+func delete_statement() {
+    fmt.Println("before")
+    // TODO: remove after the experiment ships
+    if exp.BoolValue("false") {
+        fmt.Println("new")
+    }
+    // logs the completion
+    fmt.Println("done")
+}
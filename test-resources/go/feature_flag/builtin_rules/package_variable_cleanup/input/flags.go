@@ -0,0 +1,46 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+var useNewPath = exp.BoolValue("true")
+
+var (
+    useOldCache = exp.BoolValue("false")
+    retries     = 3
+)
+
+// kept, since it is assigned in the package
+var useFallback = exp.BoolValue("true")
+
+func init() {
+    if exp.BoolValue("false") {
+        fmt.Println("registering the old handlers")
+    }
+}
+
+func main() {
+    if useNewPath {
+        fmt.Println("new path")
+    } else {
+        fmt.Println("old path")
+    }
+    fmt.Println(retries)
+}
+
+func disableFallback() {
+    useFallback = false
+}
@@ -0,0 +1,47 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+    "github.com/google/wire"
+    "go.uber.org/fx"
+)
+
+var legacyCacheModule = fx.Options(
+    fx.Provide(newLegacyCache),
+)
+
+var StoreSet = wire.NewSet(provideStore)
+
+func storeModule(exp Experiments) fx.Option {
+    newStore := newOldStore
+    if exp.BoolValue("true") {
+        newStore = newRedisStore
+    }
+    return fx.Provide(newStore)
+}
+
+func cacheModule(exp Experiments) fx.Option {
+    if exp.BoolValue("true") {
+        return fx.Provide(newRedisCache)
+    }
+    return legacyCacheModule
+}
+
+func provideStore(exp Experiments) Store {
+    if exp.BoolValue("true") {
+        return newRedisStore()
+    }
+    return newOldStore()
+}
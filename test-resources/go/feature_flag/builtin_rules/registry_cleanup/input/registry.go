@@ -0,0 +1,49 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package flags
+
+// AllFlags lists the flags validated at startup
+var AllFlags = []string{
+    "checkout_flow",
+    "stale_flag",
+    "other_flag",
+}
+
+var Owners = map[string]string{
+    "other_flag": "payments-team",
+    "stale_flag": "checkout-team",
+}
+
+type Flag struct {
+    Name  string
+    Owner string
+}
+
+var Definitions = []Flag{
+    {Name: "stale_flag", Owner: "checkout-team"},
+    {Name: "other_flag", Owner: "payments-team"},
+    {Owner: "stale_flag"},
+}
+
+var Pointers = []*Flag{
+    &Flag{Name: "other_flag"},
+    &Flag{Name: "stale_flag"},
+}
+
+var Positional = []Flag{
+    {"stale_flag", "checkout-team"},
+    {"other_flag", "payments-team"},
+}
+
+var Deprecated = [1]string{"stale_flag"}
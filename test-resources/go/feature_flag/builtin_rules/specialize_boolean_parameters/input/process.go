@@ -0,0 +1,42 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import (
+    "context"
+    "fmt"
+)
+
+func process(ctx context.Context, enabled bool, name string) {
+    if enabled {
+        fmt.Println("new flow", name)
+    } else {
+        fmt.Println("old flow", name)
+    }
+    // specialized in turn, once `process` is specialized
+    trace(ctx, enabled)
+}
+
+func trace(ctx context.Context, enabled bool) {
+    if !enabled {
+        fmt.Println(ctx, "old flow")
+    }
+}
+
+func render(enabled bool) {
+    if enabled {
+        fmt.Println("enabled")
+    }
+}
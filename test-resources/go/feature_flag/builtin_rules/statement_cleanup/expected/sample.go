@@ -15,10 +15,6 @@ package main
 
 import "fmt"
 
-func a() bool {
-    return true
-}
-
 func b() string {
     s, err := exp.StrValue("str")
     if err != nil {
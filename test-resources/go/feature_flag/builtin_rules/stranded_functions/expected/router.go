@@ -0,0 +1,44 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package server
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/go-chi/chi/v5"
+    "github.com/gorilla/mux"
+)
+
+func newChiRouter() http.Handler {
+    r := chi.NewRouter()
+    r.Use(newMiddleware)
+    r.Route("/v1", func(r chi.Router) {
+        r.Get("/orders", orders)
+    })
+    return r
+}
+
+func newMuxRouter() *mux.Router {
+    r := mux.NewRouter()
+    r.Use(newMiddleware)
+    return r
+}
+
+func newGinEngine() *gin.Engine {
+    e := gin.New()
+    v1 := e.Group("/v1")
+    v1.Use(tracing())
+    return e
+}
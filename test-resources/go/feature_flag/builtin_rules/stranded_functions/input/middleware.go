@@ -0,0 +1,65 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package server
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+)
+
+func newMiddleware(next http.Handler) http.Handler {
+    return withRequestID(next)
+}
+
+func oldMiddleware(next http.Handler) http.Handler {
+    return withLegacyHeaders(withRequestID(next))
+}
+
+func withRequestID(next http.Handler) http.Handler {
+    return next
+}
+
+func withLegacyHeaders(next http.Handler) http.Handler {
+    return next
+}
+
+func oldAuth(next http.Handler) http.Handler {
+    return next
+}
+
+func oldCheckout(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusGone)
+}
+
+func orders(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusOK)
+}
+
+// exported handlers may be registered by other packages
+func LegacyHandler() http.Handler {
+    return http.NotFoundHandler()
+}
+
+func tracing() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        c.Next()
+    }
+}
+
+func legacyTracing() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        c.Next()
+    }
+}
@@ -0,0 +1,114 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+func switch_first_case_true(x int) {
+    switch {
+    case exp.BoolValue("true"):
+        fmt.Println("treated")
+    case x > 0:
+        fmt.Println("positive")
+    default:
+        fmt.Println("default")
+    }
+}
+
+func switch_first_case_false(x int) {
+    switch {
+    case exp.BoolValue("false"):
+        fmt.Println("control")
+    case x > 0:
+        fmt.Println("positive")
+    default:
+        fmt.Println("default")
+    }
+}
+
+func switch_case_false(x int) {
+    switch {
+    case x > 0:
+        fmt.Println("positive")
+    case exp.BoolValue("false"):
+        fmt.Println("control")
+    default:
+        fmt.Println("default")
+    }
+}
+
+func switch_only_default_left() {
+    switch {
+    case exp.BoolValue("false"):
+        fmt.Println("control")
+    default:
+        fmt.Println("default")
+    }
+}
+
+func switch_all_cases_false() {
+    fmt.Println("before")
+    switch {
+    case exp.BoolValue("false"):
+        fmt.Println("control 1")
+    case !exp.BoolValue("true"):
+        fmt.Println("control 2")
+    }
+    fmt.Println("after")
+}
+
+func switch_on_flag_value() {
+    switch exp.BoolValue("true") {
+    case true:
+        fmt.Println("treated")
+    case false:
+        fmt.Println("control")
+    }
+}
+
+// the `false` case is reachable through `fallthrough`
+func switch_fallthrough_into_false_case(x int) {
+    switch {
+    case x > 0:
+        fmt.Println("positive")
+        fallthrough
+    case exp.BoolValue("false"):
+        fmt.Println("reached through fallthrough")
+    }
+}
+
+// `fallthrough` continues into the next case, it cannot be collapsed
+func switch_true_case_with_fallthrough(x int) {
+    switch {
+    case exp.BoolValue("true"):
+        fmt.Println("treated")
+        fallthrough
+    case x > 0:
+        fmt.Println("positive")
+    }
+}
+
+// `break` would bind to the enclosing loop once the switch is removed
+func switch_true_case_with_break(items []int) {
+    for _, item := range items {
+        switch {
+        case exp.BoolValue("true"):
+            if item > 0 {
+                break
+            }
+            fmt.Println(item)
+        }
+    }
+}
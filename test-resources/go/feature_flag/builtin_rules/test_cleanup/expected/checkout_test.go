@@ -0,0 +1,66 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import (
+    "testing"
+)
+
+type fakeExperiments struct {
+    values map[string]bool
+}
+
+func newFakeExperiments() *fakeExperiments {
+    return &fakeExperiments{values: map[string]bool{}}
+}
+
+func (f *fakeExperiments) BoolValue(name string) bool {
+    return f.values[name]
+}
+
+func (f *fakeExperiments) SetBoolValue(name string, value bool) {
+    f.values[name] = value
+}
+
+// TestCheckout_FlagEnabled checks the new flow
+func TestCheckout_FlagEnabled(t *testing.T) {
+    exp := newFakeExperiments()
+    exp.SetBoolValue("stale_flag", true)
+    if got := Checkout(exp); got != "new" {
+        t.Errorf("got %q", got)
+    }
+}
+
+func TestCheckout_Subtests(t *testing.T) {
+    t.Run("enabled", func(t *testing.T) {
+        exp := newFakeExperiments()
+        exp.SetBoolValue("stale_flag", true)
+        if got := Checkout(exp); got != "new" {
+            t.Errorf("got %q", got)
+        }
+    })
+}
+
+// TODO(piranha): TestCheckout_AllValues forces the eliminated value of the stale flag, delete it if it only exercises the removed branch
+func TestCheckout_AllValues(t *testing.T) {
+    for _, enabled := range []bool{true, false} {
+        exp := newFakeExperiments()
+        if !enabled {
+            exp.SetBoolValue("stale_flag", false)
+        }
+        if got := Checkout(exp); got == "" {
+            t.Errorf("got %q", got)
+        }
+    }
+}
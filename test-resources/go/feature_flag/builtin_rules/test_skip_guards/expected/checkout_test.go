@@ -0,0 +1,40 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import "testing"
+
+type fakeExperiments struct {
+    values map[string]bool
+}
+
+func (f *fakeExperiments) BoolValue(name string) bool {
+    return f.values[name]
+}
+
+func TestCheckout_NewFlow(t *testing.T) {
+    exp := &fakeExperiments{values: map[string]bool{"stale_flag": true}}
+    if got := Checkout(exp); got != "new" {
+        t.Errorf("got %q", got)
+    }
+}
+
+func TestCheckout_Subtests(t *testing.T) {
+    exp := &fakeExperiments{values: map[string]bool{}}
+    t.Run("new flow", func(t *testing.T) {
+        if got := Checkout(exp); got != "new" {
+            t.Errorf("got %q", got)
+        }
+    })
+}
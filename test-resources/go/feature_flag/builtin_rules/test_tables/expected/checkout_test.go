@@ -0,0 +1,49 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import "testing"
+
+type fakeExperiments struct {
+    values map[string]bool
+}
+
+func (f *fakeExperiments) BoolValue(name string) bool {
+    return f.values[name]
+}
+
+func (f *fakeExperiments) SetBoolValue(name string, value bool) {
+    f.values[name] = value
+}
+
+func TestCheckout(t *testing.T) {
+    cases := []struct {
+        name   string
+        guest  bool
+    }{
+        {name: "flag on"},
+        {name: "flag on, guest", guest: true},
+    }
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            exp := &fakeExperiments{values: map[string]bool{}}
+            exp.SetBoolValue("stale_flag", true)
+            want := "old"
+            want = "new"
+            if got := Checkout(exp); got != want {
+                t.Errorf("got %q, want %q", got, want)
+            }
+        })
+    }
+}
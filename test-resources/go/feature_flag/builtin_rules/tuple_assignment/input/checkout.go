@@ -0,0 +1,42 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+func checkout() {
+	useNewCheckout, useNewPricing := exp.BoolValue("true"), exp.IsEnabled("new_pricing")
+	if useNewCheckout {
+		fmt.Println("new checkout")
+	} else {
+		fmt.Println("old checkout")
+	}
+	if useNewPricing {
+		fmt.Println("new pricing")
+	}
+}
+
+func cart() {
+	retries, useOldCart := 3, exp.BoolValue("false")
+	if useOldCart {
+		fmt.Println("old cart")
+	}
+	fmt.Println(retries)
+}
+
+// kept, since the live value refers to the stale one
+func refresh(useNewCart bool) {
+	useNewCart, useOldCart := exp.BoolValue("true"), !useNewCart
+	fmt.Println(useNewCart, useOldCart)
+}
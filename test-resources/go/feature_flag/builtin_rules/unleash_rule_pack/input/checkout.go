@@ -0,0 +1,48 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package checkout
+
+import "github.com/Unleash/unleash-client-go/v3"
+
+func checkout() string {
+    if unleash.IsEnabled("stale_flag", unleash.WithFallback(false)) {
+        return "new checkout"
+    }
+    return "old checkout"
+}
+
+func theme(client *unleash.Client) string {
+    variant := client.GetVariant("stale_flag")
+    if !variant.Enabled {
+        return "default theme"
+    }
+    if variant.Name == "dark" {
+        return "dark theme"
+    }
+    return "light theme"
+}
+
+// only the name is replaced, since the payload is used
+func banner() string {
+    variant := unleash.GetVariant("stale_flag")
+    if variant.Name == "dark" {
+        return variant.Payload.Value
+    }
+    return "light banner"
+}
+
+// not cleaned up, since the flag is live
+func search() bool {
+    return unleash.IsEnabled("live_flag")
+}
@@ -0,0 +1,66 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+func isNewFlowEnabled() bool {
+    return exp.BoolValue("true")
+}
+
+func isOldFlowEnabled(ctx context.Context) bool {
+    return exp.BoolValue("false")
+}
+
+// exported functions are kept, since they may be called from other packages
+func IsNewFlowEnabled() bool {
+    return exp.BoolValue("true")
+}
+
+// methods are kept, since they may implement an interface
+func (c *Client) isCheckoutEnabled() bool {
+    enabled := exp.BoolValue("true")
+    return enabled
+}
+
+// not a wrapper, since it has side-effects
+func isLoggedFlowEnabled() bool {
+    fmt.Println("checking the flow")
+    return exp.BoolValue("true")
+}
+
+func (c *Client) run(ctx context.Context) {
+    if isNewFlowEnabled() {
+        fmt.Println("new flow")
+    } else {
+        fmt.Println("old flow")
+    }
+
+    if isOldFlowEnabled(ctx) {
+        fmt.Println("old flow")
+    }
+
+    if c.isCheckoutEnabled() {
+        fmt.Println("checkout")
+    }
+
+    if IsNewFlowEnabled() {
+        fmt.Println("exported")
+    }
+
+    if isLoggedFlowEnabled() {
+        fmt.Println("logged flow")
+    }
+}
@@ -0,0 +1,42 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import "fmt"
+
+// kept, since it is still referenced by handler.go
+func isNewFlowEnabled() bool {
+    return true
+}
+
+func (c *Client) isCheckoutEnabled() bool {
+    return true
+}
+
+func (c *Server) isCheckoutEnabled() bool {
+    return c.ready
+}
+
+func (c *Client) run() {
+    fmt.Println("new flow")
+
+    fmt.Println("checkout")
+}
+
+// not the wrapper, since the receiver is a `Server`
+func (c *Server) serve() {
+    if c.isCheckoutEnabled() {
+        fmt.Println("serving the checkout")
+    }
+}
@@ -0,0 +1,48 @@
+/*
+Copyright (c) 2023 Uber Technologies, Inc.
+
+ <p>Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+ except in compliance with the License. You may obtain a copy of the License at
+ <p>http://www.apache.org/licenses/LICENSE-2.0
+
+ <p>Unless required by applicable law or agreed to in writing, software distributed under the
+ License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+ express or implied. See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+
+package main
+
+import "fmt"
+
+const staleFlagConst = "staleFlag"
+
+func a() {
+}
+
+// the parameter shadows the flag constant
+func b(staleFlagConst string) {
+    if exp.BoolValue(staleFlagConst) {
+        fmt.Println("parameter")
+    }
+}
+
+// the loop variable shadows the flag constant
+func c(items []string) {
+    for _, staleFlagConst := range items {
+        if exp.BoolValue(staleFlagConst) {
+            fmt.Println("item")
+        }
+    }
+}
+
+// the inner variable shadows the tracked variable
+func d(other bool) {
+    if other {
+        enabled := compute()
+        for i := 0; i < 3; i++ {
+            fmt.Println(enabled)
+        }
+    }
+}
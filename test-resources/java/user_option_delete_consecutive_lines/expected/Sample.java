@@ -6,6 +6,7 @@ public class Sample {
 
         System.out.println("Hello World!");
   
+  
         System.out.println();
     }
 
@@ -13,7 +14,17 @@ public class Sample {
 
         System.out.println("Hello World!");
   
+  
+  
+  
+        System.out.println();
+    }
+
+    public void foobar2() {
+        System.out.println("Hello World!");
+
         System.out.println();
     }
 
+
 }
@@ -17,6 +17,14 @@ public class Sample {
   
   
   
+        System.out.println();
+    }
+
+    public void foobar2() {
+        System.out.println("Hello World!");
+
+        System.out.println("Delete me");
+
         System.out.println();
     }
 